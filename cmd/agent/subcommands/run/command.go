@@ -52,6 +52,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/netflow"
 	"github.com/DataDog/datadog-agent/pkg/otlp"
 	"github.com/DataDog/datadog-agent/pkg/pidfile"
+	"github.com/DataDog/datadog-agent/pkg/sbom/format"
 	"github.com/DataDog/datadog-agent/pkg/snmp/traps"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
@@ -62,6 +63,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/hostname"
 	pkglog "github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/version"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 
 	// runtime init routines
 	ddruntime "github.com/DataDog/datadog-agent/pkg/runtime"
@@ -121,6 +123,8 @@ func Commands(globalParams *command.GlobalParams) []*cobra.Command {
 				ConfigParams: config.NewAgentParamsWithSecrets(globalParams.ConfFilePath),
 				LogParams:    log.LogForDaemon("CORE", "log_file", common.DefaultLogFile)}),
 			core.Bundle,
+			fx.Provide(workloadmeta.NewFlareProvider),
+			fx.Provide(format.NewFlareProvider),
 		)
 	}
 
@@ -207,6 +211,8 @@ func StartAgentWithDefaults() error {
 			ConfigParams: config.NewAgentParamsWithSecrets(""),
 			LogParams:    log.LogForDaemon("CORE", "log_file", common.DefaultLogFile)}),
 		core.Bundle,
+		fx.Provide(workloadmeta.NewFlareProvider),
+		fx.Provide(format.NewFlareProvider),
 	)
 }
 
@@ -15,9 +15,14 @@ import (
 
 // Merge will merge additional configuration into an existing configuration. The default is merging security-agent.yaml into datadog.yaml.
 func Merge(configPaths []string) error {
+	return MergeInto(pkgconfig.Datadog, configPaths)
+}
+
+// MergeInto will merge additional configuration into the given configuration.
+func MergeInto(cfg pkgconfig.Config, configPaths []string) error {
 	for _, configPath := range configPaths {
 		if f, err := os.Open(configPath); err == nil {
-			err = pkgconfig.Datadog.MergeConfig(f)
+			err = cfg.MergeConfig(f)
 			_ = f.Close()
 			if err != nil {
 				return fmt.Errorf("error merging %s config file: %w", configPath, err)
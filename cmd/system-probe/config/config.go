@@ -97,12 +97,17 @@ func New(configPath string) (*Config, error) {
 			return nil, err
 		}
 	}
-	return load(configPath)
+	return load(aconfig.Datadog, configPath)
 }
 
 // Merge will merge the system-probe configuration into the existing datadog configuration
 func Merge(configPath string) (*Config, error) {
-	aconfig.InitSystemProbeConfig(aconfig.Datadog)
+	return MergeInto(aconfig.Datadog, configPath)
+}
+
+// MergeInto will merge the system-probe configuration into the given datadog configuration
+func MergeInto(cfg aconfig.Config, configPath string) (*Config, error) {
+	aconfig.InitSystemProbeConfig(cfg)
 	if configPath != "" {
 		if !strings.HasSuffix(configPath, ".yaml") {
 			configPath = path.Join(configPath, defaultConfigFileName)
@@ -112,7 +117,7 @@ func Merge(configPath string) (*Config, error) {
 	}
 
 	if f, err := os.Open(configPath); err == nil {
-		err = aconfig.Datadog.MergeConfig(f)
+		err = cfg.MergeConfig(f)
 		_ = f.Close()
 		if err != nil {
 			return nil, fmt.Errorf("error merging system-probe config file: %s", err)
@@ -121,12 +126,10 @@ func Merge(configPath string) (*Config, error) {
 		log.Infof("no config exists at %s, ignoring...", configPath)
 	}
 
-	return load(configPath)
+	return load(cfg, configPath)
 }
 
-func load(configPath string) (*Config, error) {
-	cfg := aconfig.Datadog
-
+func load(cfg aconfig.Config, configPath string) (*Config, error) {
 	if err := aconfig.ResolveSecrets(cfg, filepath.Base(configPath)); err != nil {
 		return nil, err
 	}
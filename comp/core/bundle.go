@@ -13,9 +13,18 @@
 package core
 
 import (
+	"fmt"
+
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/flare"
+	"github.com/DataDog/datadog-agent/comp/core/health"
+	"github.com/DataDog/datadog-agent/comp/core/hostname"
 	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/comp/core/reload"
+	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	"github.com/DataDog/datadog-agent/comp/core/settings"
+	"github.com/DataDog/datadog-agent/comp/core/status"
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"go.uber.org/fx"
 )
@@ -25,17 +34,54 @@ import (
 // Bundle defines the fx options for this bundle.
 var Bundle = fxutil.Bundle(
 	// As `config.Module` expects `config.Params` as a parameter, it is require to define how to get `config.Params` from `BundleParams`.
-	fx.Provide(func(params BundleParams) config.Params { return params.ConfigParams }),
+	// The params are validated at this point, as this is the first component to be built, except the disabled
+	// components which are validated against the dependency graph of the app below.
+	fx.Provide(func(params BundleParams) (config.Params, error) {
+		if err := params.validate(); err != nil {
+			return config.Params{}, err
+		}
+		return params.ConfigParams, nil
+	}),
 	config.Module,
 	fx.Provide(func(params BundleParams) log.Params { return params.LogParams }),
 	log.Module,
+	fx.Provide(func(params BundleParams, dot fx.DotGraph) (fxutil.DisabledComponents, error) {
+		if err := params.validateDisabled(parseComponentGraph(string(dot))); err != nil {
+			return nil, fmt.Errorf("invalid core bundle disabled components: %w", err)
+		}
+		return params.Disabled, nil
+	}),
+	lifecycleOptions(),
 	flare.Module,
+	fx.Provide(newComponentGraphFlareProvider),
+	fx.Provide(newConfigProvenanceFlareProvider),
+	telemetry.Module,
+	hostname.Module,
+	health.Module,
+	settings.Module,
+	secrets.Module,
+	status.Module,
+	reload.Module,
 )
 
 // MockBundle defines the mock fx options for this bundle.
+//
+// The log component is log.MockModule, so the bundle requires a testing.TB.
+// Logged entries can be checked with the log.Mock methods.
+//
+// Use MockConfigError, MockFailingConfig, MockLogError and MockFailingLog to
+// inject failures in its components.
 var MockBundle = fxutil.Bundle(
 	fx.Provide(func(params BundleParams) config.Params { return params.ConfigParams }),
 	config.Module,
 	fx.Provide(func(params BundleParams) log.Params { return params.LogParams }),
-	log.Module,
+	log.MockModule,
+	fx.Provide(func(params BundleParams) fxutil.DisabledComponents { return params.Disabled }),
+	telemetry.MockModule,
+	hostname.MockModule,
+	health.Module,
+	settings.Module,
+	secrets.MockModule,
+	status.Module,
+	reload.Module,
 )
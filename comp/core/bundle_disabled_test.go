@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare"
+	"github.com/DataDog/datadog-agent/comp/core/health"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func disabledBundleParams(disabled map[string]bool) BundleParams {
+	return BundleParams{
+		ConfigParams: config.NewParams("", config.WithConfigMissingOK(true)),
+		LogParams:    log.LogForOneShot("TEST", "info", false),
+		Disabled:     disabled,
+	}
+}
+
+func TestDisabledOptionalComponent(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(disabledBundleParams(map[string]bool{
+			"comp/core/flare":  true,
+			"comp/core/health": true,
+		})),
+		Bundle,
+	), func(f flare.Component, h health.Component) {
+		_, err := f.BuildFor("comp/core/hostname")
+		assert.EqualError(t, err, "the flare component is disabled")
+
+		h.Register("check").Heartbeat()
+		assert.True(t, h.Status().IsHealthy())
+		assert.Empty(t, h.Status().Healthy)
+	})
+}
+
+func TestDisabledRequiredComponent(t *testing.T) {
+	app := fx.New(
+		fx.NopLogger,
+		fx.Supply(disabledBundleParams(map[string]bool{"comp/core/config": true})),
+		Bundle,
+		fx.Invoke(func(health.Component) {}),
+	)
+	require.Error(t, app.Err())
+	assert.Contains(t, app.Err().Error(),
+		"invalid core bundle disabled components: component 'comp/core/config' cannot be disabled, as it is required by comp/core/flare, comp/core/health, comp/core/hostname, comp/core/log, comp/core/reload")
+}
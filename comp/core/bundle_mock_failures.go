@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/spf13/pflag"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// The options below inject failures in the components of MockBundle, to
+// exercise the error paths of the components depending on them.  They
+// decorate the components, so they must be given at the top level of the app,
+// next to MockBundle:
+//
+//	fxutil.Test(t, fx.Options(
+//		fx.Supply(core.BundleParams{...}),
+//		core.MockBundle,
+//		core.MockConfigError(errors.New("boom")),
+//		myComponent.Module,
+//	), ...)
+
+// errMockFailure is returned by the methods of the failing components.
+var errMockFailure = errors.New("mock failure injected in the core bundle")
+
+// MockConfigError makes the config component fail to build with the given
+// error.
+func MockConfigError(err error) fx.Option {
+	return fx.Decorate(func(config.Component) (config.Component, error) {
+		return nil, fmt.Errorf("mock config component: %w", err)
+	})
+}
+
+// MockFailingConfig replaces the config component with one behaving as if
+// nothing is set: every getter returns its zero value, and those returning an
+// error fail.
+func MockFailingConfig() fx.Option {
+	return fx.Decorate(func(config.Component) config.Component {
+		return failingConfig{}
+	})
+}
+
+// MockLogError makes the log component fail to build with the given error.
+func MockLogError(err error) fx.Option {
+	return fx.Decorate(func(log.Component) (log.Component, error) {
+		return nil, fmt.Errorf("mock log component: %w", err)
+	})
+}
+
+// MockFailingLog replaces the log component with one dropping all messages,
+// and whose methods returning an error always fail.
+func MockFailingLog() fx.Option {
+	return fx.Decorate(func(log.Component) log.Component {
+		return failingLog{}
+	})
+}
+
+// failingConfig implements config.Component, with nothing set.
+type failingConfig struct{}
+
+func (failingConfig) IsSet(key string) bool                                  { return false }
+func (failingConfig) Get(key string) interface{}                             { return nil }
+func (failingConfig) GetString(key string) string                            { return "" }
+func (failingConfig) GetBool(key string) bool                                { return false }
+func (failingConfig) GetInt(key string) int                                  { return 0 }
+func (failingConfig) GetInt32(key string) int32                              { return 0 }
+func (failingConfig) GetInt64(key string) int64                              { return 0 }
+func (failingConfig) GetFloat64(key string) float64                          { return 0 }
+func (failingConfig) GetTime(key string) time.Time                           { return time.Time{} }
+func (failingConfig) GetDuration(key string) time.Duration                   { return 0 }
+func (failingConfig) GetStringSlice(key string) []string                     { return nil }
+func (failingConfig) GetStringMap(key string) map[string]interface{}         { return nil }
+func (failingConfig) GetStringMapString(key string) map[string]string        { return nil }
+func (failingConfig) GetStringMapStringSlice(key string) map[string][]string { return nil }
+func (failingConfig) GetSizeInBytes(key string) uint                         { return 0 }
+func (failingConfig) AllSettings() map[string]interface{}                    { return nil }
+func (failingConfig) AllSettingsWithoutDefault() map[string]interface{}      { return nil }
+func (failingConfig) AllKeys() []string                                      { return nil }
+func (failingConfig) KeysUnder(prefix string) []string                       { return nil }
+func (failingConfig) GetKnownKeys() map[string]interface{}                   { return nil }
+func (failingConfig) GetEnvVars() []string                                   { return nil }
+func (failingConfig) IsSectionSet(section string) bool                       { return false }
+func (failingConfig) Source(key string) pkgconfig.Source                     { return pkgconfig.SourceUnknown }
+func (failingConfig) Warnings() *pkgconfig.Warnings                          { return &pkgconfig.Warnings{} }
+
+func (failingConfig) GetFloat64SliceE(key string) ([]float64, error) {
+	return nil, errMockFailure
+}
+
+func (failingConfig) ScrubbedAll() (map[string]interface{}, error) {
+	return nil, errMockFailure
+}
+
+func (failingConfig) MarshalYAML() ([]byte, error) {
+	return nil, errMockFailure
+}
+
+func (failingConfig) BindPFlags(fs *pflag.FlagSet) error {
+	return errMockFailure
+}
+
+func (failingConfig) DumpDefaults() ([]byte, error) {
+	return nil, errMockFailure
+}
+
+func (failingConfig) Reload() error {
+	return errMockFailure
+}
+
+// failingLog implements log.Component, dropping all messages.
+type failingLog struct{}
+
+func (failingLog) Trace(v ...interface{})                               {}
+func (failingLog) Tracef(format string, params ...interface{})          {}
+func (failingLog) Debug(v ...interface{})                               {}
+func (failingLog) Debugf(format string, params ...interface{})          {}
+func (failingLog) Info(v ...interface{})                                {}
+func (failingLog) Infof(format string, params ...interface{})           {}
+func (failingLog) Warn(v ...interface{}) error                          { return errMockFailure }
+func (failingLog) Warnf(format string, params ...interface{}) error     { return errMockFailure }
+func (failingLog) Error(v ...interface{}) error                         { return errMockFailure }
+func (failingLog) Errorf(format string, params ...interface{}) error    { return errMockFailure }
+func (failingLog) Critical(v ...interface{}) error                      { return errMockFailure }
+func (failingLog) Criticalf(format string, params ...interface{}) error { return errMockFailure }
+func (failingLog) Flush()                                               {}
+
+func (failingLog) SetLevelFor(level seelog.LogLevel, d time.Duration) (log.LevelOverride, error) {
+	return nil, errMockFailure
+}
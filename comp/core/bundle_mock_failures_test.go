@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// endpoint is a component requiring an API key from the config, failing
+// gracefully when it is not available.
+type endpoint struct {
+	apiKey string
+}
+
+func newEndpoint(cfg config.Component) (*endpoint, error) {
+	apiKey := cfg.GetString("api_key")
+	if apiKey == "" {
+		return nil, errors.New("no api_key configured")
+	}
+	return &endpoint{apiKey: apiKey}, nil
+}
+
+func mockBundleParams() BundleParams {
+	return BundleParams{
+		ConfigParams: config.NewParams("", config.WithConfigMissingOK(true)),
+		LogParams:    log.LogForOneShot("TEST", "info", false),
+	}
+}
+
+func mockBundleApp(t *testing.T, opts ...fx.Option) *fx.App {
+	return fx.New(
+		fx.NopLogger,
+		fx.Supply(fx.Annotate(t, fx.As(new(testing.TB)))),
+		fx.Supply(mockBundleParams()),
+		MockBundle,
+		fx.Options(opts...),
+		fx.Provide(newEndpoint),
+		fx.Invoke(func(*endpoint) {}),
+	)
+}
+
+func TestMockBundleNoFailure(t *testing.T) {
+	t.Setenv("DD_API_KEY", "abcdef")
+	app := mockBundleApp(t)
+	require.NoError(t, app.Err())
+}
+
+func TestMockConfigError(t *testing.T) {
+	app := mockBundleApp(t, MockConfigError(errors.New("boom")))
+	require.Error(t, app.Err())
+	assert.Contains(t, app.Err().Error(), "mock config component: boom")
+}
+
+func TestMockFailingConfig(t *testing.T) {
+	t.Setenv("DD_API_KEY", "abcdef")
+	app := mockBundleApp(t, MockFailingConfig())
+	require.Error(t, app.Err())
+	assert.Contains(t, app.Err().Error(), "no api_key configured")
+}
+
+func TestMockFailingConfigMethods(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(mockBundleParams()),
+		MockBundle,
+		MockFailingConfig(),
+	), func(cfg config.Component) {
+		assert.False(t, cfg.IsSet("api_key"))
+		_, err := cfg.GetFloat64SliceE("histogram_percentiles")
+		assert.Error(t, err)
+	})
+}
+
+func TestMockLogError(t *testing.T) {
+	app := fx.New(
+		fx.NopLogger,
+		fx.Supply(fx.Annotate(t, fx.As(new(testing.TB)))),
+		fx.Supply(mockBundleParams()),
+		MockBundle,
+		MockLogError(errors.New("boom")),
+		fx.Invoke(func(log.Component) {}),
+	)
+	require.Error(t, app.Err())
+	assert.Contains(t, app.Err().Error(), "mock log component: boom")
+}
+
+func TestMockFailingLog(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(mockBundleParams()),
+		MockBundle,
+		MockFailingLog(),
+	), func(l log.Component) {
+		assert.Error(t, l.Warnf("disk is %s", "full"))
+		assert.Error(t, l.Error(fmt.Errorf("oops")))
+	})
+}
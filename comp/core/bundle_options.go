@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+)
+
+const (
+	// defaultLoggerName is the logger name used by NewBundleParams when none
+	// of WithOneShotLogs or WithDaemonLogs is given.
+	defaultLoggerName = "CORE"
+
+	// defaultLogLevel is the log level used by NewBundleParams when none of
+	// WithOneShotLogs, WithDaemonLogs or WithLogLevel is given.
+	defaultLogLevel = "info"
+)
+
+// Option modifies the BundleParams built by NewBundleParams.
+type Option func(*BundleParams)
+
+// NewBundleParams builds the parameters for this bundle from the given
+// options, applied in order.
+//
+// Without options, the parameters load the Agent configuration from the
+// default path, without resolving secrets, and log one-shot messages to the
+// console at the `info` level (overridable with DD_LOG_LEVEL).  Since options
+// are applied in order, options tweaking the logs (such as WithLogLevel) must
+// come after WithOneShotLogs or WithDaemonLogs.
+func NewBundleParams(opts ...Option) BundleParams {
+	params := BundleParams{
+		ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+		LogParams:    log.LogForOneShot(defaultLoggerName, defaultLogLevel, true),
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return params
+}
+
+// WithConfigParams replaces the config parameters.
+func WithConfigParams(configParams ConfigParams) Option {
+	return func(p *BundleParams) {
+		p.ConfigParams = configParams
+	}
+}
+
+// WithConfigFile sets the path at which to look for the configuration.
+func WithConfigFile(path string) Option {
+	return WithConfigOptions(config.WithConfFilePath(path))
+}
+
+// WithConfigOptions applies the given options, such as
+// config.WithConfigLoadSecrets, to the config parameters.
+func WithConfigOptions(options ...func(*config.Params)) Option {
+	return func(p *BundleParams) {
+		for _, o := range options {
+			o(&p.ConfigParams)
+		}
+	}
+}
+
+// WithLogParams replaces the log parameters.
+func WithLogParams(logParams LogParams) Option {
+	return func(p *BundleParams) {
+		p.LogParams = logParams
+	}
+}
+
+// WithOneShotLogs sets up the log parameters for a one-shot command.  See
+// log.LogForOneShot.
+func WithOneShotLogs(loggerName, level string, overrideFromEnv bool) Option {
+	return WithLogParams(log.LogForOneShot(loggerName, level, overrideFromEnv))
+}
+
+// WithDaemonLogs sets up the log parameters for a daemon.  See
+// log.LogForDaemon.
+func WithDaemonLogs(loggerName, logFileConfig, defaultLogFile string) Option {
+	return WithLogParams(log.LogForDaemon(loggerName, logFileConfig, defaultLogFile))
+}
+
+// WithLogLevel sets the log level, overriding any level given by the log
+// parameters or the configuration.
+func WithLogLevel(level string) Option {
+	return func(p *BundleParams) {
+		p.LogParams.OverrideLogLevel(level)
+	}
+}
+
+// WithLogToConsole enables or disables writing logs to the console.
+func WithLogToConsole(enabled bool) Option {
+	return func(p *BundleParams) {
+		p.LogParams.LogToConsole(enabled)
+	}
+}
+
+// WithLogFile sets the destination log file.
+func WithLogFile(logFile string) Option {
+	return func(p *BundleParams) {
+		p.LogParams.LogToFile(logFile)
+	}
+}
+
+// WithLogLifecycle enables the logging of the OnStart and OnStop hooks of the
+// bundle.  See BundleParams.LogLifecycle.
+func WithLogLifecycle() Option {
+	return func(p *BundleParams) {
+		p.LogLifecycle = true
+	}
+}
+
+// WithDeadlines limits the duration of the OnStart and OnStop hooks of the
+// bundle.  See BundleParams.StartDeadline and BundleParams.StopDeadline.
+func WithDeadlines(start, stop time.Duration) Option {
+	return func(p *BundleParams) {
+		p.StartDeadline = start
+		p.StopDeadline = stop
+	}
+}
+
+// WithDisabledComponents disables the named components.  See
+// BundleParams.Disabled.
+func WithDisabledComponents(names ...string) Option {
+	return func(p *BundleParams) {
+		if p.Disabled == nil {
+			p.Disabled = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			p.Disabled[name] = true
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+)
+
+type optionsGetter struct {
+	strs  map[string]string
+	bools map[string]bool
+}
+
+func (g *optionsGetter) GetString(k string) string {
+	return g.strs[k]
+}
+
+func (g *optionsGetter) GetBool(k string) bool {
+	return g.bools[k]
+}
+
+func TestNewBundleParamsDefaults(t *testing.T) {
+	// t.Setenv restores the variable at the end of the test
+	t.Setenv("DD_LOG_LEVEL", "")
+	os.Unsetenv("DD_LOG_LEVEL")
+	params := NewBundleParams()
+	g := &optionsGetter{}
+
+	require.NoError(t, params.Validate())
+	require.Equal(t, "", params.ConfFilePath())
+	require.False(t, params.ConfigLoadSecrets())
+	require.Equal(t, defaultLoggerName, params.LoggerName())
+	require.Equal(t, defaultLogLevel, params.LogLevelFn(g))
+	require.True(t, params.LogToConsoleFn(g))
+	require.False(t, params.LogLifecycle)
+	require.Zero(t, params.StartDeadline)
+	require.Zero(t, params.StopDeadline)
+	require.Empty(t, params.Disabled)
+}
+
+func TestNewBundleParamsOptions(t *testing.T) {
+	g := &optionsGetter{
+		strs:  map[string]string{"log_level": "warn", "log_file": "/from/config.log"},
+		bools: map[string]bool{"log_to_console": true},
+	}
+
+	t.Run("WithConfigFile", func(t *testing.T) {
+		params := NewBundleParams(WithConfigFile("/etc/datadog-agent/datadog.yaml"))
+		require.Equal(t, "/etc/datadog-agent/datadog.yaml", params.ConfFilePath())
+	})
+
+	t.Run("WithConfigOptions", func(t *testing.T) {
+		params := NewBundleParams(WithConfigOptions(config.WithConfigLoadSecrets(true), config.WithConfigMissingOK(true)))
+		require.True(t, params.ConfigLoadSecrets())
+		require.True(t, params.ConfigMissingOK())
+	})
+
+	t.Run("WithConfigParams", func(t *testing.T) {
+		params := NewBundleParams(WithConfigParams(config.NewParams("", config.WithConfigLoadSysProbe(true))))
+		require.True(t, params.ConfigLoadSysProbe())
+	})
+
+	t.Run("WithOneShotLogs", func(t *testing.T) {
+		params := NewBundleParams(WithOneShotLogs("TEST", "trace", false))
+		require.Equal(t, "TEST", params.LoggerName())
+		require.Equal(t, "trace", params.LogLevelFn(g))
+		require.Equal(t, "", params.LogFileFn(g))
+	})
+
+	t.Run("WithDaemonLogs", func(t *testing.T) {
+		params := NewBundleParams(WithDaemonLogs("TEST", "log_file", "/default.log"))
+		require.Equal(t, "TEST", params.LoggerName())
+		require.Equal(t, "warn", params.LogLevelFn(g))
+		require.Equal(t, "/from/config.log", params.LogFileFn(g))
+	})
+
+	t.Run("WithLogLevel", func(t *testing.T) {
+		params := NewBundleParams(WithDaemonLogs("TEST", "log_file", "/default.log"), WithLogLevel("debug"))
+		require.Equal(t, "debug", params.LogLevelFn(g))
+	})
+
+	t.Run("WithLogToConsole", func(t *testing.T) {
+		params := NewBundleParams(WithDaemonLogs("TEST", "log_file", "/default.log"), WithLogToConsole(false))
+		require.False(t, params.LogToConsoleFn(g))
+	})
+
+	t.Run("WithLogFile", func(t *testing.T) {
+		params := NewBundleParams(WithLogFile("/some/file.log"))
+		require.Equal(t, "/some/file.log", params.LogFileFn(g))
+	})
+
+	t.Run("WithLogLifecycle", func(t *testing.T) {
+		params := NewBundleParams(WithLogLifecycle())
+		require.True(t, params.LogLifecycle)
+	})
+
+	t.Run("WithDeadlines", func(t *testing.T) {
+		params := NewBundleParams(WithDeadlines(time.Second, 2*time.Second))
+		require.Equal(t, time.Second, params.StartDeadline)
+		require.Equal(t, 2*time.Second, params.StopDeadline)
+	})
+
+	t.Run("WithDisabledComponents", func(t *testing.T) {
+		params := NewBundleParams(WithDisabledComponents("comp/core/flare"), WithDisabledComponents("comp/core/status"))
+		require.Equal(t, map[string]bool{"comp/core/flare": true, "comp/core/status": true}, params.Disabled)
+		require.NoError(t, params.Validate())
+	})
+
+	t.Run("options are applied in order", func(t *testing.T) {
+		params := NewBundleParams(WithLogLevel("debug"), WithOneShotLogs("TEST", "trace", false))
+		require.Equal(t, "trace", params.LogLevelFn(g))
+	})
+}
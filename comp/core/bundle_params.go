@@ -6,6 +6,11 @@
 package core
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/log"
 )
@@ -16,9 +21,89 @@ import (
 // callbacks.  These fields can be set with the `LogXxx()` methods, which
 // return the updated BundleParams.  One of `LogForOneShot` or `LogForDaemon`
 // must be called.
+//
+// NewBundleParams builds BundleParams from options, without filling the
+// nested parameters by hand.
 type BundleParams struct {
 	ConfigParams
 	LogParams
+
+	// LogLifecycle, when set, logs the execution and duration of every
+	// OnStart and OnStop hook registered by the components of the bundle.
+	// This helps finding the component blocking the startup or shutdown.
+	LogLifecycle bool
+
+	// StartDeadline and StopDeadline, when not zero, limit the duration of
+	// every OnStart and OnStop hook registered by the components of the
+	// bundle.  The context given to a hook is cancelled once its deadline is
+	// exceeded, and the hook fails with an error naming it, even if it does
+	// not honor the context.
+	StartDeadline time.Duration
+	StopDeadline  time.Duration
+
+	// Disabled lists the components to disable at runtime, by name (such as
+	// `comp/core/flare`).  Disabled components are replaced by a no-op
+	// implementation.  Only the components listed in disableableComponents
+	// can be disabled.
+	Disabled map[string]bool
+}
+
+// disableableComponents are the components of the bundle with a no-op
+// implementation.
+var disableableComponents = map[string]struct{}{
+	"comp/core/flare":  {},
+	"comp/core/health": {},
+	"comp/core/status": {},
+}
+
+// Validate checks the consistency of the parameters, so that bad combinations
+// fail early with an actionable message instead of deep inside fx.
+func (p BundleParams) Validate() error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	if err := p.validateDisabled(nil); err != nil {
+		return fmt.Errorf("invalid core bundle disabled components: %w", err)
+	}
+	return nil
+}
+
+// validate checks the parameters, except the disabled components.
+func (p BundleParams) validate() error {
+	if err := p.ConfigParams.Validate(); err != nil {
+		return fmt.Errorf("invalid core bundle config params: %w", err)
+	}
+	if err := p.LogParams.Validate(); err != nil {
+		return fmt.Errorf("invalid core bundle log params: %w", err)
+	}
+	if p.StartDeadline < 0 || p.StopDeadline < 0 {
+		return fmt.Errorf("invalid core bundle lifecycle deadlines: they cannot be negative")
+	}
+	return nil
+}
+
+// validateDisabled checks that all the disabled components can be disabled.
+// When the dependency graph of the components is given, the error names the
+// components depending on a component which cannot be disabled.
+func (p BundleParams) validateDisabled(graph *componentGraph) error {
+	names := make([]string, 0, len(p.Disabled))
+	for name, disabled := range p.Disabled {
+		if disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, found := disableableComponents[name]; found {
+			continue
+		}
+		if dependents := graph.dependents(name); len(dependents) > 0 {
+			return fmt.Errorf("component '%s' cannot be disabled, as it is required by %s", name, strings.Join(dependents, ", "))
+		}
+		return fmt.Errorf("component '%s' cannot be disabled", name)
+	}
+	return nil
 }
 
 type ConfigParams = config.Params
@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+)
+
+func TestBundleParamsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params BundleParams
+		errMsg string
+	}{
+		{
+			name: "valid agent params",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+			},
+		},
+		{
+			name: "valid params without a config file",
+			params: BundleParams{
+				ConfigParams: config.NewParams("", config.WithConfigMissingOK(true)),
+				LogParams:    log.LogForDaemon("TEST", "log_file", "/dev/null"),
+			},
+		},
+		{
+			name: "config file required but no path",
+			params: BundleParams{
+				ConfigParams: config.NewParams(""),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+			},
+			errMsg: "invalid core bundle config params: a config file is required but no path was given",
+		},
+		{
+			name: "system-probe path without loading system-probe config",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets("", config.WithSysProbeConfFilePath("/etc/datadog-agent/system-probe.yaml")),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+			},
+			errMsg: "invalid core bundle config params: a system-probe config path was given",
+		},
+		{
+			name: "security-agent paths without loading security-agent config",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets("", config.WithSecurityAgentConfigFilePaths([]string{"security-agent.yaml"})),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+			},
+			errMsg: "invalid core bundle config params: security-agent config paths were given",
+		},
+		{
+			name: "disabled optional component",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+				Disabled:     map[string]bool{"comp/core/flare": true, "comp/core/config": false},
+			},
+		},
+		{
+			name: "disabled required component",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+				Disabled:     map[string]bool{"comp/core/log": true},
+			},
+			// the components requiring it are only known from the
+			// dependency graph of the app
+			errMsg: "invalid core bundle disabled components: component 'comp/core/log' cannot be disabled",
+		},
+		{
+			name: "disabled component without a no-op implementation",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+				LogParams:    log.LogForOneShot("TEST", "info", false),
+				Disabled:     map[string]bool{"comp/core/hostname": true},
+			},
+			errMsg: "invalid core bundle disabled components: component 'comp/core/hostname' cannot be disabled",
+		},
+		{
+			name: "negative lifecycle deadline",
+			params: BundleParams{
+				ConfigParams:  config.NewAgentParamsWithoutSecrets(""),
+				LogParams:     log.LogForOneShot("TEST", "info", false),
+				StartDeadline: -time.Second,
+			},
+			errMsg: "invalid core bundle lifecycle deadlines: they cannot be negative",
+		},
+		{
+			name: "log params not set up",
+			params: BundleParams{
+				ConfigParams: config.NewAgentParamsWithoutSecrets(""),
+			},
+			errMsg: "invalid core bundle log params: must call one of core.BundleParams.LogForOneShot or LogForDaemon",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.params.Validate()
+			if test.errMsg == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.errMsg)
+			}
+		})
+	}
+}
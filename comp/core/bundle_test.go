@@ -13,7 +13,14 @@ import (
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/flare"
+	"github.com/DataDog/datadog-agent/comp/core/health"
+	"github.com/DataDog/datadog-agent/comp/core/hostname"
 	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/comp/core/reload"
+	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	"github.com/DataDog/datadog-agent/comp/core/settings"
+	"github.com/DataDog/datadog-agent/comp/core/status"
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 )
 
 func TestBundleDependencies(t *testing.T) {
@@ -23,6 +30,13 @@ func TestBundleDependencies(t *testing.T) {
 		fx.Invoke(func(config.Component) {}),
 		fx.Invoke(func(log.Component) {}),
 		fx.Invoke(func(flare.Component) {}),
+		fx.Invoke(func(telemetry.Component) {}),
+		fx.Invoke(func(hostname.Component) {}),
+		fx.Invoke(func(health.Component) {}),
+		fx.Invoke(func(settings.Component) {}),
+		fx.Invoke(func(secrets.Component) {}),
+		fx.Invoke(func(status.Component) {}),
+		fx.Invoke(func(reload.Component) {}),
 
 		fx.Supply(BundleParams{}),
 		Bundle))
@@ -36,6 +50,13 @@ func TestMockBundleDependencies(t *testing.T) {
 		// automatically.
 		fx.Invoke(func(config.Component) {}),
 		fx.Invoke(func(log.Component) {}),
+		fx.Invoke(func(telemetry.Component) {}),
+		fx.Invoke(func(hostname.Component) {}),
+		fx.Invoke(func(health.Component) {}),
+		fx.Invoke(func(settings.Component) {}),
+		fx.Invoke(func(secrets.Component) {}),
+		fx.Invoke(func(status.Component) {}),
+		fx.Invoke(func(reload.Component) {}),
 
 		fx.Supply(BundleParams{}),
 		MockBundle))
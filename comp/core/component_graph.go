@@ -0,0 +1,185 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+)
+
+const (
+	componentGraphDOTFile  = "component-graph.dot"
+	componentGraphJSONFile = "component-graph.json"
+)
+
+var (
+	// these match the lines of the DOT graph generated by fx (see
+	// fx.DotGraph), which has a cluster for each constructor, containing the
+	// types it provides, and an edge from the constructor to each of the types
+	// it depends on.
+	dotClusterRe = regexp.MustCompile(`^subgraph (cluster_\d+) \{$`)
+	dotLabelRe   = regexp.MustCompile(`^label = "([^"]*)";$`)
+	dotNodeRe    = regexp.MustCompile(`^"([^"]+)" \[label=`)
+	dotEdgeRe    = regexp.MustCompile(`^constructor_(\d+) -> "([^"]+)"`)
+)
+
+// componentGraph is the dependency graph between components.
+type componentGraph struct {
+	// Components are the names of all the components, sorted.
+	Components []string `json:"components"`
+	// Dependencies maps each component to the sorted names of the components
+	// it depends on.
+	Dependencies map[string][]string `json:"dependencies"`
+}
+
+// newComponentGraphFlareProvider registers a flare provider adding the
+// dependency graph between the components of the app to the flare.
+//
+// fx.DotGraph is captured when the provider is built, at which point every
+// constructor of the app has been registered.
+func newComponentGraphFlareProvider(dot fx.DotGraph) helpers.Provider {
+	return helpers.NewNamedProvider("core", func(fb helpers.FlareBuilder) error {
+		graph := parseComponentGraph(string(dot))
+
+		content, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := fb.AddFile(componentGraphJSONFile, content); err != nil {
+			return err
+		}
+		return fb.AddFile(componentGraphDOTFile, []byte(graph.dot()))
+	})
+}
+
+// parseComponentGraph computes the dependency graph between components from
+// the DOT graph generated by fx.  Constructors outside of a component (such as
+// those of fx itself) and value groups are ignored.
+func parseComponentGraph(dot string) *componentGraph {
+	// component of each cluster, and the types each cluster depends on
+	clusterComponent := map[string]string{}
+	clusterDeps := map[string][]string{}
+	// components providing each type
+	providers := map[string][]string{}
+
+	var cluster string
+	scanner := bufio.NewScanner(strings.NewReader(dot))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if m := dotClusterRe.FindStringSubmatch(line); m != nil {
+			cluster = strings.TrimPrefix(m[1], "cluster_")
+			continue
+		}
+		if line == "}" {
+			cluster = ""
+			continue
+		}
+		if m := dotEdgeRe.FindStringSubmatch(line); m != nil {
+			clusterDeps[m[1]] = append(clusterDeps[m[1]], m[2])
+			continue
+		}
+		if cluster == "" {
+			continue
+		}
+		if m := dotLabelRe.FindStringSubmatch(line); m != nil {
+			if component, ok := componentName(m[1]); ok {
+				clusterComponent[cluster] = component
+			}
+			continue
+		}
+		if m := dotNodeRe.FindStringSubmatch(line); m != nil {
+			if component, found := clusterComponent[cluster]; found {
+				providers[m[1]] = append(providers[m[1]], component)
+			}
+		}
+	}
+
+	deps := map[string]map[string]struct{}{}
+	for cluster, component := range clusterComponent {
+		if _, found := deps[component]; !found {
+			deps[component] = map[string]struct{}{}
+		}
+		for _, typ := range clusterDeps[cluster] {
+			for _, provider := range providers[typ] {
+				if provider != component {
+					deps[component][provider] = struct{}{}
+				}
+			}
+		}
+	}
+
+	graph := &componentGraph{Dependencies: map[string][]string{}}
+	for component, set := range deps {
+		graph.Components = append(graph.Components, component)
+		list := make([]string, 0, len(set))
+		for dep := range set {
+			list = append(list, dep)
+		}
+		sort.Strings(list)
+		graph.Dependencies[component] = list
+	}
+	sort.Strings(graph.Components)
+	return graph
+}
+
+// componentName returns the name of the component implemented in the given
+// package, of the form `comp/<bundle>/<component>`.
+func componentName(pkg string) (string, bool) {
+	idx := strings.LastIndex(pkg, "/comp/")
+	if idx < 0 {
+		return "", false
+	}
+	name := pkg[idx+1:]
+	if strings.Count(name, "/") < 2 {
+		// this is a bundle
+		return "", false
+	}
+	return name, true
+}
+
+// dependents returns the sorted names of the components depending on the given
+// component.  It returns nil for a nil graph.
+func (g *componentGraph) dependents(component string) []string {
+	if g == nil {
+		return nil
+	}
+
+	var dependents []string
+	for _, name := range g.Components {
+		for _, dep := range g.Dependencies[name] {
+			if dep == component {
+				dependents = append(dependents, name)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// dot renders the graph in the DOT format.
+func (g *componentGraph) dot() string {
+	var b strings.Builder
+	b.WriteString("digraph components {\n")
+	for _, component := range g.Components {
+		fmt.Fprintf(&b, "\t%q;\n", component)
+	}
+	for _, component := range g.Components {
+		for _, dep := range g.Dependencies[component] {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", component, dep)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
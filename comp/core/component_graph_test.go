@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestComponentGraphFlareProvider(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(BundleParams{
+			ConfigParams: config.NewParams("", config.WithConfigMissingOK(true)),
+			LogParams:    log.LogForOneShot("TEST", "info", false),
+		}),
+		Bundle,
+	), func(dot fx.DotGraph) {
+		graph := parseComponentGraph(string(dot))
+
+		assert.Contains(t, graph.Components, "comp/core/config")
+		assert.Contains(t, graph.Components, "comp/core/log")
+		assert.Contains(t, graph.Components, "comp/core/flare")
+		assert.Empty(t, graph.Dependencies["comp/core/config"])
+		assert.Equal(t, []string{"comp/core/config", "comp/core/telemetry"}, graph.Dependencies["comp/core/log"])
+		assert.Equal(t, []string{"comp/core/config", "comp/core/log"}, graph.Dependencies["comp/core/flare"])
+
+		provider := newComponentGraphFlareProvider(dot)
+		assert.Equal(t, "core", provider.Provider.Name)
+
+		fb := helpers.NewFlareBuilderMock(t)
+		require.NoError(t, provider.Provider.Callback(fb.Fb))
+
+		fb.AssertFileContentMatch(`"comp/core/flare" -> "comp/core/log";`, "component-graph.dot")
+		fb.AssertFileContentMatch(`"comp/core/log": \[\s*"comp/core/config",\s*"comp/core/telemetry"\s*\]`, "component-graph.json")
+	})
+}
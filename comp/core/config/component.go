@@ -18,6 +18,7 @@ package config
 import (
 	"time"
 
+	"github.com/spf13/pflag"
 	"go.uber.org/fx"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
@@ -93,10 +94,25 @@ type Component interface {
 	// applied based on defaults.
 	AllSettingsWithoutDefault() map[string]interface{}
 
+	// ScrubbedAll is like AllSettings, with the secrets (API keys, passwords,
+	// ...) redacted as in flares.
+	ScrubbedAll() (map[string]interface{}, error)
+
+	// MarshalYAML returns the settings merged from all the sources, as in
+	// AllSettings, as YAML with the keys sorted, so that it can be diffed,
+	// and the secrets redacted as in ScrubbedAll.
+	MarshalYAML() ([]byte, error)
+
 	// AllKeys returns all keys holding a value, regardless of where they are
 	// set. Nested keys are returned with a v.keyDelim separator
 	AllKeys() []string
 
+	// KeysUnder returns the immediate child keys of the given section, sorted,
+	// e.g. "b" and "c" for prefix "a" with keys "a.b" and "a.c.d".  The keys
+	// are those of AllKeys.  With an empty prefix, it returns the top-level
+	// keys.
+	KeysUnder(prefix string) []string
+
 	// GetKnownKeys returns all the keys that meet at least one of these criteria:
 	// 1) have a default, 2) have an environment variable binded, 3) are an alias or 4) have been SetKnown()
 	GetKnownKeys() map[string]interface{}
@@ -109,8 +125,40 @@ type Component interface {
 	// its subkeys is set.
 	IsSectionSet(section string) bool
 
+	// Source returns where the effective value of a key comes from: Set, a
+	// flag, an environment variable, the config file or the default value.
+	Source(key string) config.Source
+
+	// BindPFlags binds every flag of the set to the config key of its name,
+	// with dashes replaced by underscores.  The flags given on the command
+	// line take precedence over the environment and the config file, the
+	// others don't change the configuration.  The flags stay bound when the
+	// configuration is reloaded.
+	BindPFlags(fs *pflag.FlagSet) error
+
 	// Warnings returns config warnings collected during setup.
 	Warnings() *config.Warnings
+
+	// DumpDefaults returns a JSON object with an entry for every known key,
+	// holding its default value and type (see KeyDefault).  This is used to
+	// generate the documentation of the configuration.
+	DumpDefaults() ([]byte, error)
+
+	// Reload loads the configuration again from its sources (config files and
+	// environment).  On failure, the previous configuration is kept.  The
+	// mock component is never reloaded.
+	Reload() error
+}
+
+// KeyDefault describes the default of a config key, as dumped by
+// Component#DumpDefaults.
+type KeyDefault struct {
+	// Default is the default value of the key, or nil if it has none.
+	Default interface{} `json:"default"`
+	// Type is the Go type of the default value, to which the values from all
+	// sources (config files, environment) are cast.  It is empty for keys
+	// without a default.
+	Type string `json:"type"`
 }
 
 // Mock implements mock-specific methods.
@@ -6,16 +6,23 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/pflag"
 	"go.uber.org/fx"
+	"gopkg.in/yaml.v3"
 
 	secconfig "github.com/DataDog/datadog-agent/cmd/security-agent/config"
 	sysconfig "github.com/DataDog/datadog-agent/cmd/system-probe/config"
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/scrubber"
 )
 
 // cfg implements the Component.
@@ -25,6 +32,19 @@ type cfg struct {
 
 	// warnings are the warnings generated during setup
 	warnings *config.Warnings
+
+	// params are used to reload the configuration.  They are nil for the
+	// mock, which cannot be reloaded.
+	params *Params
+
+	// flagSets are the flag sets given to BindPFlags, bound again when the
+	// configuration is reloaded.
+	flagSets []*pflag.FlagSet
+
+	// loadOverrides are the values given to Set while loading the
+	// configuration, like the sanitized API key.  The other ones are given
+	// at runtime, like the log level, and are kept when reloading.
+	loadOverrides map[string]interface{}
 }
 
 type dependencies struct {
@@ -34,25 +54,100 @@ type dependencies struct {
 }
 
 func newConfig(deps dependencies) (Component, error) {
-	warnings, err := setupConfig(deps)
+	warnings, err := load(config.Datadog, deps)
+	if warnings == nil {
+		return nil, err
+	}
+	return &cfg{warnings: warnings, params: &deps.Params, loadOverrides: config.Datadog.GetOverrides()}, err
+}
+
+// load loads the configuration into cfg, which is pkg/config.Datadog but when
+// reloading, according to the params.  The warnings are nil if the main
+// configuration could not be loaded.
+func load(cfg config.Config, deps dependencies) (*config.Warnings, error) {
+	warnings, err := setupConfig(cfg, deps)
 	if err != nil {
 		return nil, err
 	}
 
 	if deps.Params.configLoadSysProbe {
-		_, err := sysconfig.Merge(deps.Params.sysProbeConfFilePath)
+		_, err := sysconfig.MergeInto(cfg, deps.Params.sysProbeConfFilePath)
 		if err != nil {
-			return &cfg{warnings}, err
+			return warnings, err
 		}
 	}
 
 	if deps.Params.configLoadSecurityAgent {
-		if err := secconfig.Merge(deps.Params.securityAgentConfigFilePaths); err != nil {
-			return &cfg{warnings}, err
+		if err := secconfig.MergeInto(cfg, deps.Params.securityAgentConfigFilePaths); err != nil {
+			return warnings, err
+		}
+	}
+
+	return warnings, nil
+}
+
+// DumpDefaults implements Component#DumpDefaults.
+func (c *cfg) DumpDefaults() ([]byte, error) {
+	defaults := config.Datadog.GetDefaults()
+
+	keys := map[string]KeyDefault{}
+	for key := range config.Datadog.GetKnownKeys() {
+		keys[key] = KeyDefault{}
+	}
+	for key, value := range defaults {
+		kd := KeyDefault{Default: value}
+		if value != nil {
+			kd.Type = fmt.Sprintf("%T", value)
 		}
+		keys[key] = kd
 	}
 
-	return &cfg{warnings}, nil
+	return json.Marshal(keys)
+}
+
+// Reload implements Component#Reload.
+func (c *cfg) Reload() error {
+	if c.params == nil {
+		return nil
+	}
+
+	// the configuration is loaded from scratch into a new config, which then
+	// replaces the settings of pkg/config.Datadog at once, so that the
+	// packages holding it see the new settings, and that it is left untouched
+	// on failure.
+	reloaded := config.NewConfig("datadog", "DD", strings.NewReplacer(".", "_"))
+	config.InitConfig(reloaded)
+
+	// the defaults set out of InitConfig, like the ones set by packages in
+	// their init functions, are only set once and carried over
+	for key, value := range config.Datadog.GetDefaults() {
+		reloaded.SetDefault(key, value)
+	}
+
+	warnings, err := load(reloaded, dependencies{Params: *c.params})
+	if err != nil {
+		return fmt.Errorf("could not reload the configuration: %w", err)
+	}
+	loadOverrides := reloaded.GetOverrides()
+
+	for _, fs := range c.flagSets {
+		if err := bindPFlags(reloaded, fs); err != nil {
+			return fmt.Errorf("could not reload the configuration: %w", err)
+		}
+	}
+
+	// the values given at runtime take precedence over the configuration
+	// files, as before the reload
+	for key, value := range config.Datadog.GetOverrides() {
+		if loadValue, found := c.loadOverrides[key]; !found || !reflect.DeepEqual(value, loadValue) {
+			reloaded.Set(key, value)
+		}
+	}
+
+	config.Datadog.CopyConfig(reloaded)
+	c.warnings = warnings
+	c.loadOverrides = loadOverrides
+	return nil
 }
 
 func (c *cfg) IsSet(key string) bool {
@@ -109,9 +204,58 @@ func (c *cfg) AllSettings() map[string]interface{} {
 func (c *cfg) AllSettingsWithoutDefault() map[string]interface{} {
 	return config.Datadog.AllSettingsWithoutDefault()
 }
+
+// ScrubbedAll implements Component#ScrubbedAll.
+func (c *cfg) ScrubbedAll() (map[string]interface{}, error) {
+	scrubbed, err := c.MarshalYAML()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(scrubbed, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// MarshalYAML implements Component#MarshalYAML.
+func (c *cfg) MarshalYAML() ([]byte, error) {
+	// the scrubber works on the YAML representation of the settings, like
+	// the config files, and the keys of the maps are marshalled sorted
+	raw, err := yaml.Marshal(config.Datadog.AllSettings())
+	if err != nil {
+		return nil, err
+	}
+	return scrubber.ScrubBytes(raw)
+}
+
 func (c *cfg) AllKeys() []string {
 	return config.Datadog.AllKeys()
 }
+
+// KeysUnder implements Component#KeysUnder.
+func (c *cfg) KeysUnder(prefix string) []string {
+	if prefix != "" {
+		prefix = strings.ToLower(prefix) + "."
+	}
+
+	seen := map[string]struct{}{}
+	var keys []string
+	for _, key := range config.Datadog.AllKeys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		child := strings.SplitN(key[len(prefix):], ".", 2)[0]
+		if _, found := seen[child]; !found {
+			seen[child] = struct{}{}
+			keys = append(keys, child)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (c *cfg) GetKnownKeys() map[string]interface{} {
 	return config.Datadog.GetKnownKeys()
 }
@@ -121,6 +265,29 @@ func (c *cfg) GetEnvVars() []string {
 func (c *cfg) IsSectionSet(section string) bool {
 	return config.Datadog.IsSectionSet(section)
 }
+func (c *cfg) Source(key string) config.Source {
+	return config.Datadog.GetSource(key)
+}
+
+// BindPFlags implements Component#BindPFlags.
+func (c *cfg) BindPFlags(fs *pflag.FlagSet) error {
+	if err := bindPFlags(config.Datadog, fs); err != nil {
+		return err
+	}
+	c.flagSets = append(c.flagSets, fs)
+	return nil
+}
+
+func bindPFlags(cfg config.Config, fs *pflag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(flag *pflag.Flag) {
+		if err == nil {
+			err = cfg.BindPFlag(strings.ReplaceAll(flag.Name, "-", "_"), flag)
+		}
+	})
+	return err
+}
+
 func (c *cfg) Warnings() *config.Warnings {
 	return c.warnings
 }
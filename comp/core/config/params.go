@@ -5,7 +5,11 @@
 
 package config
 
-import "github.com/DataDog/datadog-agent/cmd/agent/common"
+import (
+	"errors"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+)
 
 // Params defines the parameters for the config component.
 type Params struct {
@@ -47,6 +51,10 @@ type Params struct {
 	// defaultConfPath determines the default configuration path.
 	// if defaultConfPath is empty, then no default configuration path is used.
 	defaultConfPath string
+
+	// watchFiles determines whether the configuration is reloaded when its
+	// file changes, see comp/core/reload.
+	watchFiles bool
 }
 
 // NewParams creates a new instance of Params
@@ -147,8 +155,45 @@ func WithSysProbeConfFilePath(sysProbeConfFilePath string) func(*Params) {
 	}
 }
 
+// WithWatchFiles determines whether the configuration is reloaded when its
+// file changes.
+func WithWatchFiles(watchFiles bool) func(*Params) {
+	return func(b *Params) {
+		b.watchFiles = watchFiles
+	}
+}
+
+// Validate checks that the parameters describe a configuration that can be
+// loaded, returning an error describing how to fix them otherwise.
+func (p Params) Validate() error {
+	if !p.configMissingOK && p.confFilePath == "" && p.defaultConfPath == "" {
+		return errors.New("a config file is required but no path was given: use WithConfFilePath, a non-empty default config path, or WithConfigMissingOK(true)")
+	}
+
+	if p.sysProbeConfFilePath != "" && !p.configLoadSysProbe {
+		return errors.New("a system-probe config path was given but system-probe config loading is disabled: use WithConfigLoadSysProbe(true)")
+	}
+
+	if len(p.securityAgentConfigFilePaths) > 0 && !p.configLoadSecurityAgent {
+		return errors.New("security-agent config paths were given but security-agent config loading is disabled: use WithConfigLoadSecurityAgent(true)")
+	}
+
+	return nil
+}
+
+// WatchFiles determines whether the configuration is reloaded when its file
+// changes.
+func (p Params) WatchFiles() bool {
+	return p.watchFiles
+}
+
 // These functions are used in unit tests.
 
+// ConfFilePath is the path at which to look for configuration.
+func (p Params) ConfFilePath() string {
+	return p.confFilePath
+}
+
 // ConfigLoadSecrets determines whether secrets in the configuration file
 // should be evaluated.  This is typically false for one-shot commands.
 func (p Params) ConfigLoadSecrets() bool {
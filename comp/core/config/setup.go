@@ -17,8 +17,9 @@ import (
 	"github.com/DataDog/viper"
 )
 
-// setupConfig is copied from cmd/agent/common/helpers.go.
-func setupConfig(deps dependencies) (*config.Warnings, error) {
+// setupConfig is copied from cmd/agent/common/helpers.go.  It loads the
+// configuration into cfg.
+func setupConfig(cfg config.Config, deps dependencies) (*config.Warnings, error) {
 	confFilePath := deps.Params.confFilePath
 	configName := deps.Params.configName
 	withoutSecrets := !deps.Params.configLoadSecrets
@@ -26,32 +27,28 @@ func setupConfig(deps dependencies) (*config.Warnings, error) {
 	defaultConfPath := deps.Params.defaultConfPath
 
 	if configName != "" {
-		config.Datadog.SetConfigName(configName)
+		cfg.SetConfigName(configName)
 	}
 
 	// set the paths where a config file is expected
 	if len(confFilePath) != 0 {
 		// if the configuration file path was supplied on the command line,
 		// add that first so it's first in line
-		config.Datadog.AddConfigPath(confFilePath)
+		cfg.AddConfigPath(confFilePath)
 		// If they set a config file directly, let's try to honor that
 		if strings.HasSuffix(confFilePath, ".yaml") {
-			config.Datadog.SetConfigFile(confFilePath)
+			cfg.SetConfigFile(confFilePath)
 		}
 	}
 	if defaultConfPath != "" {
-		config.Datadog.AddConfigPath(defaultConfPath)
+		cfg.AddConfigPath(defaultConfPath)
 	}
 
 	// load the configuration
 	var err error
 	var warnings *config.Warnings
 
-	if withoutSecrets {
-		warnings, err = config.LoadWithoutSecret()
-	} else {
-		warnings, err = config.Load()
-	}
+	warnings, err = config.LoadCustom(cfg, "datadog.yaml", !withoutSecrets)
 	// If `!failOnMissingFile`, do not issue an error if we cannot find the default config file.
 	var e viper.ConfigFileNotFoundError
 	if err != nil && (failOnMissingFile || !errors.As(err, &e) || confFilePath != "") {
@@ -98,7 +95,7 @@ func MergeConfigurationFiles(configName string, configurationFilesArray []string
 			deps.Params.defaultConfPath = ""
 			deps.Params.configLoadSecurityAgent = true
 
-			w, err := setupConfig(deps)
+			w, err := setupConfig(config.Datadog, deps)
 			if err != nil {
 				if userDefined {
 					fmt.Printf("Warning: unable to open %s\n", configurationFilename)
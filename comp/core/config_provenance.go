@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+)
+
+const configProvenanceFile = "config-provenance.json"
+
+// configProvenance is the effective value of a config key, and where it comes
+// from.
+type configProvenance struct {
+	Value  interface{}      `json:"value"`
+	Source pkgconfig.Source `json:"source"`
+}
+
+// newConfigProvenanceFlareProvider registers a flare provider adding every
+// key of the configuration to the flare, with its scrubbed value and its
+// source, to tell which of the config file, the environment or the defaults
+// configured the agent.
+func newConfigProvenanceFlareProvider(cfg config.Component) helpers.Provider {
+	return helpers.NewNamedProvider("config", func(fb helpers.FlareBuilder) error {
+		settings, err := cfg.ScrubbedAll()
+		if err != nil {
+			return err
+		}
+
+		provenance := map[string]configProvenance{}
+		for _, key := range cfg.AllKeys() {
+			provenance[key] = configProvenance{
+				Value:  lookupSetting(settings, key),
+				Source: cfg.Source(key),
+			}
+		}
+
+		content, err := json.MarshalIndent(provenance, "", "  ")
+		if err != nil {
+			return err
+		}
+		return fb.AddFile(configProvenanceFile, content)
+	})
+}
+
+// lookupSetting returns the value of the key in the nested settings, as
+// returned by AllSettings, or nil if it's not there.
+func lookupSetting(settings map[string]interface{}, key string) interface{} {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		section, ok := settings[part].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		settings = section
+	}
+	return settings[parts[len(parts)-1]]
+}
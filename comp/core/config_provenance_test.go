@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestConfigProvenanceFlareProvider(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		config.MockModule,
+	), func(cfg config.Component) {
+		cfg.(config.Mock).Set("api_key", "aaaaaaaaaaaaaaaaaaaaaaaaaaaabcde")
+		cfg.(config.Mock).Set("logs_config.enabled", true)
+
+		provider := newConfigProvenanceFlareProvider(cfg)
+		assert.Equal(t, "config", provider.Provider.Name)
+
+		fb := helpers.NewFlareBuilderMock(t)
+		require.NoError(t, provider.Provider.Callback(fb.Fb))
+
+		content, err := os.ReadFile(filepath.Join(fb.Root, "config-provenance.json"))
+		require.NoError(t, err)
+
+		var provenance map[string]configProvenance
+		require.NoError(t, json.Unmarshal(content, &provenance))
+
+		assert.Equal(t, configProvenance{Value: "***************************abcde", Source: pkgconfig.SourceOverride}, provenance["api_key"])
+		assert.Equal(t, configProvenance{Value: true, Source: pkgconfig.SourceOverride}, provenance["logs_config.enabled"])
+		assert.Equal(t, configProvenance{Value: float64(30000), Source: pkgconfig.SourceDefault}, provenance["ac_load_timeout"])
+		assert.NotContains(t, string(content), "aaaaaaaaaaaaaaaaaaaaaaaaaaaabcde")
+	})
+}
@@ -21,6 +21,10 @@ import (
 type Component interface {
 	// Create creates a new flare locally and returns the path to the flare file.
 	Create(local bool, distPath, pyChecksPath string, logFilePaths []string, pdata pkgFlare.ProfileData, ipcError error) (string, error)
+
+	// BuildFor creates a new flare locally containing only the data from the providers registered under the given
+	// component name (see helpers.NewNamedProvider), and returns the path to the flare file.
+	BuildFor(componentName string) (string, error)
 }
 
 // Module defines the fx options for this component.
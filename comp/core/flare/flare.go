@@ -6,12 +6,15 @@
 package flare
 
 import (
+	"fmt"
 	"reflect"
 	"runtime"
 
+	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
 	"github.com/DataDog/datadog-agent/comp/core/log"
 	pkgFlare "github.com/DataDog/datadog-agent/pkg/flare"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"go.uber.org/fx"
 )
 
@@ -19,36 +22,86 @@ type dependencies struct {
 	fx.In
 
 	Log       log.Component
-	Providers []helpers.FlareProvider `group:"flare"`
+	Config    config.Component
+	Providers []helpers.FlareProvider   `group:"flare"`
+	Disabled  fxutil.DisabledComponents `optional:"true"`
 }
 
 type flare struct {
 	log       log.Component
+	config    config.Component
 	providers []helpers.FlareProvider
 }
 
 func newFlare(deps dependencies) (Component, error) {
+	if deps.Disabled.IsDisabled(componentName) {
+		return noopFlare{}, nil
+	}
 	return &flare{
 		log:       deps.Log,
+		config:    deps.Config,
 		providers: deps.Providers,
 	}, nil
 }
 
+// sizeLimits returns the flare size limits from the configuration
+func (f *flare) sizeLimits() helpers.SizeLimits {
+	return helpers.SizeLimits{
+		MaxFileSize:  int64(f.config.GetSizeInBytes("flare_max_file_size")),
+		MaxTotalSize: int64(f.config.GetSizeInBytes("flare_max_total_size")),
+	}
+}
+
 func (f *flare) Create(local bool, distPath, pyChecksPath string, logFilePaths []string, pdata pkgFlare.ProfileData, ipcError error) (string, error) {
-	fb, err := helpers.NewFlareBuilder()
+	fb, err := helpers.NewFlareBuilderWithLimits(f.sizeLimits())
 	if err != nil {
 		return "", err
 	}
 
-	for _, p := range f.providers {
-		err = p.Callback(fb)
-		f.log.Error("error calling '%s' for flare creation: %s",
-			runtime.FuncForPC(reflect.ValueOf(p.Callback).Pointer()).Name(), // reflect p.Callback function name
-			err)
-	}
+	f.runProviders(fb, f.providers)
 
 	// Legacy flare code
 	pkgFlare.CompleteFlare(fb, local, distPath, pyChecksPath, logFilePaths, pdata, ipcError)
 
 	return fb.Save()
 }
+
+func (f *flare) BuildFor(componentName string) (string, error) {
+	var providers []helpers.FlareProvider
+	for _, p := range f.providers {
+		if componentName != "" && p.Name == componentName {
+			providers = append(providers, p)
+		}
+	}
+
+	if len(providers) == 0 {
+		return "", fmt.Errorf("no flare provider registered for component '%s'", componentName)
+	}
+
+	fb, err := helpers.NewFlareBuilderWithLimits(f.sizeLimits())
+	if err != nil {
+		return "", err
+	}
+
+	f.runProviders(fb, providers)
+
+	return fb.Save()
+}
+
+// runProviders calls each provider with the given FlareBuilder. Once the flare size limit has been reached the
+// remaining providers are skipped and a note is added to the flare log.
+func (f *flare) runProviders(fb helpers.FlareBuilder, providers []helpers.FlareProvider) {
+	for _, p := range providers {
+		name := runtime.FuncForPC(reflect.ValueOf(p.Callback).Pointer()).Name() // reflect p.Callback function name
+
+		if fb.IsFull() {
+			fb.Logf("skipping '%s': the flare size limit has been reached", name)
+			f.log.Warnf("skipping '%s' for flare creation: the flare size limit has been reached", name)
+			continue
+		}
+
+		if err := p.Callback(fb); err != nil {
+			f.log.Errorf("error calling '%s' for flare creation: %s", name, err)
+		}
+	}
+}
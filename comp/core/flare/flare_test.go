@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package flare
+
+import (
+	"archive/zip"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestRunProvidersSkippedWhenFull(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		fx.Supply(log.Params{}),
+		config.MockModule,
+		log.MockModule,
+	), func(logComp log.Component, cfg config.Component) {
+		f := &flare{log: logComp, config: cfg}
+
+		fb, err := helpers.NewFlareBuilderWithLimits(helpers.SizeLimits{MaxTotalSize: 10})
+		require.NoError(t, err)
+
+		called := []string{}
+		providers := []helpers.FlareProvider{
+			{Callback: func(fb helpers.FlareBuilder) error {
+				called = append(called, "first")
+				return fb.AddFile("first", []byte("0123456789abcdef"))
+			}},
+			{Callback: func(fb helpers.FlareBuilder) error {
+				called = append(called, "second")
+				return fb.AddFile("second", []byte("a"))
+			}},
+		}
+
+		f.runProviders(fb, providers)
+		assert.Equal(t, []string{"first"}, called)
+
+		archivePath, err := fb.Save()
+		require.NoError(t, err)
+		defer os.Remove(archivePath)
+		assert.FileExists(t, archivePath)
+	})
+}
+
+func TestSizeLimitsFromConfig(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		config.MockModule,
+	), func(cfg config.Component) {
+		cfg.(config.Mock).Set("flare_max_file_size", "1Mb")
+		cfg.(config.Mock).Set("flare_max_total_size", 2048)
+
+		f := &flare{config: cfg}
+		assert.Equal(t, helpers.SizeLimits{MaxFileSize: 1024 * 1024, MaxTotalSize: 2048}, f.sizeLimits())
+	})
+}
+
+// archiveFiles returns the base names of the files in a flare archive
+func archiveFiles(t *testing.T, archivePath string) []string {
+	r, err := zip.OpenReader(archivePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	files := []string{}
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			files = append(files, path.Base(f.Name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestBuildFor(t *testing.T) {
+	newProvider := func(name, file string) func() helpers.Provider {
+		return func() helpers.Provider {
+			return helpers.NewNamedProvider(name, func(fb helpers.FlareBuilder) error {
+				return fb.AddFile(file, []byte("some data"))
+			})
+		}
+	}
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		fx.Supply(log.Params{}),
+		config.MockModule,
+		log.MockModule,
+		Module,
+		fx.Provide(newProvider("comp-a", "a1.log")),
+		fx.Provide(newProvider("comp-a", "a2.log")),
+		fx.Provide(newProvider("comp-b", "b.log")),
+		fx.Provide(func() helpers.Provider {
+			return helpers.NewProvider(func(fb helpers.FlareBuilder) error {
+				return fb.AddFile("unnamed.log", []byte("some data"))
+			})
+		}),
+	), func(f Component) {
+		archivePath, err := f.BuildFor("comp-a")
+		require.NoError(t, err)
+		defer os.Remove(archivePath)
+
+		assert.Equal(t, []string{"a1.log", "a2.log", "flare_creation.log", "permissions.log"}, archiveFiles(t, archivePath))
+
+		_, err = f.BuildFor("unknown")
+		assert.EqualError(t, err, "no flare provider registered for component 'unknown'")
+
+		// unnamed providers can't be selected
+		_, err = f.BuildFor("")
+		assert.Error(t, err)
+	})
+}
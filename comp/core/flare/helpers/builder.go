@@ -24,12 +24,39 @@ import (
 
 const (
 	filePerm = 0644
+
+	// DefaultMaxFileSize is the default maximum size, in bytes, of a single file in the flare
+	DefaultMaxFileSize = 20 * 1024 * 1024
+	// DefaultMaxTotalSize is the default maximum size, in bytes, of all the files in the flare
+	DefaultMaxTotalSize = 100 * 1024 * 1024
+
+	truncationMarker = "\n[... %d bytes truncated from the flare ...]\n"
 )
 
-func newBuilder(root string, hostname string) (*builder, error) {
+// SizeLimits defines the size caps applied to the data added to a flare. A zero value disables the corresponding
+// limit.
+type SizeLimits struct {
+	// MaxFileSize is the maximum size, in bytes, of a single file. Larger files are truncated, keeping their head and
+	// their tail.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum size, in bytes, of all the files added to the flare. Once reached, any further data
+	// is dropped.
+	MaxTotalSize int64
+}
+
+// DefaultSizeLimits returns the default size limits for a flare
+func DefaultSizeLimits() SizeLimits {
+	return SizeLimits{
+		MaxFileSize:  DefaultMaxFileSize,
+		MaxTotalSize: DefaultMaxTotalSize,
+	}
+}
+
+func newBuilder(root string, hostname string, limits SizeLimits) (*builder, error) {
 	fb := &builder{
 		tmpDir:     root,
 		permsInfos: permissionsInfos{},
+		limits:     limits,
 	}
 
 	fb.flareDir = filepath.Join(fb.tmpDir, hostname)
@@ -75,7 +102,15 @@ func newBuilder(root string, hostname string) (*builder, error) {
 // NewFlareBuilder returns a new FlareBuilder ready to be used. You need to call the Save method to archive all the data
 // pushed to the flare as well as cleanup the temporary directories created. Not calling 'Save' after NewFlareBuilder
 // will leave temporary directory on the file system.
+//
+// The default size limits are applied to the flare (see DefaultSizeLimits).
 func NewFlareBuilder() (FlareBuilder, error) {
+	return NewFlareBuilderWithLimits(DefaultSizeLimits())
+}
+
+// NewFlareBuilderWithLimits returns a new FlareBuilder, like NewFlareBuilder, applying the given size limits to the
+// data added to the flare.
+func NewFlareBuilderWithLimits(limits SizeLimits) (FlareBuilder, error) {
 	tmpDir, err := os.MkdirTemp("", "")
 	if err != nil {
 		return nil, fmt.Errorf("Could not create temp dir for flare: %s", err)
@@ -98,7 +133,7 @@ func NewFlareBuilder() (FlareBuilder, error) {
 		return nil, err
 	}
 
-	return newBuilder(tmpDir, hostname)
+	return newBuilder(tmpDir, hostname, limits)
 }
 
 // builder implements the FlareBuilder interface
@@ -114,6 +149,13 @@ type builder struct {
 	scrubber *scrubber.Scrubber
 
 	logFile *os.File
+
+	// limits are the size caps applied to the content of the flare
+	limits SizeLimits
+	// totalSize is the size of all the files written to the flare so far
+	totalSize int64
+	// full is set once MaxTotalSize has been reached
+	full bool
 }
 
 func getArchiveName() string {
@@ -132,7 +174,16 @@ func getArchiveName() string {
 func (fb *builder) Save() (string, error) {
 	defer fb.clean()
 
-	_ = fb.AddFileFromFunc("permissions.log", fb.permsInfos.commit)
+	if content, err := fb.permsInfos.commit(); err != nil {
+		_ = fb.logError("error collecting data from callback for 'permissions.log': %s", err)
+	} else {
+		// permissions.log is a reserved file which is always added, regardless of the size limits
+		if content, err = fb.scrubber.ScrubBytes(content); err != nil {
+			_ = fb.logError("error scrubbing content for 'permissions.log': %s", err)
+		} else {
+			_ = fb.writeFile("permissions.log", content, false)
+		}
+	}
 	_ = fb.logFile.Close()
 
 	archiveName := getArchiveName()
@@ -171,6 +222,76 @@ func (fb *builder) logError(format string, params ...interface{}) error {
 	return err
 }
 
+// Logf implements FlareBuilder#Logf
+func (fb *builder) Logf(format string, params ...interface{}) {
+	_, _ = fb.logFile.WriteString(fmt.Sprintf(format, params...) + "\n")
+}
+
+// IsFull implements FlareBuilder#IsFull
+func (fb *builder) IsFull() bool {
+	return fb.full
+}
+
+// truncate returns the content with its middle replaced by a marker if it's bigger than MaxFileSize. The head and the
+// tail of the content are kept since they are usually the most relevant parts of a log file. The returned content is
+// never bigger than MaxFileSize.
+func (fb *builder) truncate(content []byte) []byte {
+	maxSize := fb.limits.MaxFileSize
+	if maxSize <= 0 || int64(len(content)) <= maxSize {
+		return content
+	}
+
+	// the marker size depends on the number of bytes dropped, which depends on the size of the marker. Using the
+	// size of the whole content as an upper bound is good enough.
+	marker := fmt.Sprintf(truncationMarker, len(content))
+	markerSize := int64(len(marker))
+	if markerSize >= maxSize {
+		// not even the marker fits, it's cut to the limit
+		return []byte(marker[:maxSize])
+	}
+	keep := maxSize - markerSize
+	head := keep / 2
+	tail := keep - head
+	dropped := int64(len(content)) - head - tail
+
+	truncated := make([]byte, 0, maxSize)
+	truncated = append(truncated, content[:head]...)
+	truncated = append(truncated, fmt.Sprintf(truncationMarker, dropped)...)
+	truncated = append(truncated, content[int64(len(content))-tail:]...)
+	return truncated
+}
+
+// writeFile writes content to 'destFile' in the flare. When 'enforceLimits' is true the content is truncated to
+// MaxFileSize and dropped if it would make the flare exceed MaxTotalSize.
+func (fb *builder) writeFile(destFile string, content []byte, enforceLimits bool) error {
+	if enforceLimits {
+		if fb.full {
+			return fb.logError("skipping '%s': the flare size limit of %d bytes has been reached", destFile, fb.limits.MaxTotalSize)
+		}
+
+		if size := int64(len(content)); fb.limits.MaxFileSize > 0 && size > fb.limits.MaxFileSize {
+			content = fb.truncate(content)
+			fb.Logf("'%s' was truncated from %d to %d bytes", destFile, size, len(content))
+		}
+
+		if fb.limits.MaxTotalSize > 0 && fb.totalSize+int64(len(content)) > fb.limits.MaxTotalSize {
+			fb.full = true
+			return fb.logError("skipping '%s': the flare size limit of %d bytes has been reached", destFile, fb.limits.MaxTotalSize)
+		}
+	}
+
+	path, err := fb.PrepareFilePath(destFile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, content, filePerm); err != nil {
+		return fb.logError("error writing data to '%s': %s", destFile, err)
+	}
+	fb.totalSize += int64(len(content))
+	return nil
+}
+
 func (fb *builder) AddFileFromFunc(destFile string, cb func() ([]byte, error)) error {
 	content, err := cb()
 	if err != nil {
@@ -186,25 +307,12 @@ func (fb *builder) AddFile(destFile string, content []byte) error {
 		return fb.logError("error scrubbing content for '%s': %s", destFile, err)
 	}
 
-	f, err := fb.PrepareFilePath(destFile)
-	if err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(f, content, filePerm); err != nil {
-		return fb.logError("error writing data to '%s': %s", destFile, err)
-	}
-	return nil
+	return fb.writeFile(destFile, content, true)
 }
 
 func (fb *builder) copyFileTo(shouldScrub bool, srcFile string, destFile string) error {
 	fb.permsInfos.add(srcFile)
 
-	path, err := fb.PrepareFilePath(destFile)
-	if err != nil {
-		return err
-	}
-
 	content, err := os.ReadFile(srcFile)
 	if err != nil {
 		return fb.logError("error reading file '%s' to be copy to '%s': %s", srcFile, destFile, err)
@@ -218,12 +326,7 @@ func (fb *builder) copyFileTo(shouldScrub bool, srcFile string, destFile string)
 		}
 	}
 
-	err = os.WriteFile(path, content, filePerm)
-	if err != nil {
-		return fb.logError("error writing file '%s': %s", destFile, err)
-	}
-
-	return nil
+	return fb.writeFile(destFile, content, true)
 }
 
 func (fb *builder) CopyFileTo(srcFile string, destFile string) error {
@@ -23,7 +23,7 @@ type FlareBuilderMock struct {
 func NewFlareBuilderMock(t *testing.T) *FlareBuilderMock {
 	root := t.TempDir()
 
-	builder, err := newBuilder(root, "test-hostname")
+	builder, err := newBuilder(root, "test-hostname", DefaultSizeLimits())
 	require.NoError(t, err)
 
 	fb := &FlareBuilderMock{
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mholt/archiver/v3"
@@ -264,3 +265,74 @@ func TestRegisterDirPerm(t *testing.T) {
 		assert.Contains(t, fb.permsInfos, path)
 	}
 }
+
+func TestAddFileTruncated(t *testing.T) {
+	fb, err := newBuilder(t.TempDir(), "test-hostname", SizeLimits{MaxFileSize: 100})
+	require.NoError(t, err)
+	defer fb.logFile.Close()
+
+	content := strings.Repeat("h", 200) + strings.Repeat("t", 200)
+	require.NoError(t, fb.AddFile("big.log", []byte(content)))
+
+	data, err := os.ReadFile(filepath.Join(fb.flareDir, "big.log"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 100)
+	assert.True(t, strings.HasPrefix(string(data), "hhhh"))
+	assert.True(t, strings.HasSuffix(string(data), "tttt"))
+	assert.Regexp(t, `\[\.\.\. \d+ bytes truncated from the flare \.\.\.\]`, string(data))
+
+	// files under the limit are left untouched
+	require.NoError(t, fb.AddFile("small.log", []byte("some data")))
+	assertFileContent(t, fb, "some data", "small.log")
+}
+
+func TestAddFileTruncatedBelowMarkerSize(t *testing.T) {
+	fb, err := newBuilder(t.TempDir(), "test-hostname", SizeLimits{MaxFileSize: 10})
+	require.NoError(t, err)
+	defer fb.logFile.Close()
+
+	require.NoError(t, fb.AddFile("big.log", []byte(strings.Repeat("a", 1000))))
+
+	data, err := os.ReadFile(filepath.Join(fb.flareDir, "big.log"))
+	require.NoError(t, err)
+	assert.Len(t, data, 10)
+}
+
+func TestCopyFileTruncated(t *testing.T) {
+	fb, err := newBuilder(t.TempDir(), "test-hostname", SizeLimits{MaxFileSize: 100})
+	require.NoError(t, err)
+	defer fb.logFile.Close()
+
+	path := filepath.Join(t.TempDir(), "test.data")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("a", 1000)), os.ModePerm))
+
+	require.NoError(t, fb.CopyFile(path))
+	data, err := os.ReadFile(filepath.Join(fb.flareDir, "test.data"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 100)
+}
+
+func TestTotalSizeLimit(t *testing.T) {
+	fb, err := newBuilder(t.TempDir(), "test-hostname", SizeLimits{MaxTotalSize: 20})
+	require.NoError(t, err)
+	defer fb.logFile.Close()
+
+	require.NoError(t, fb.AddFile("file1", []byte("0123456789")))
+	assert.False(t, fb.IsFull())
+
+	// this file would make the flare exceed the limit
+	assert.Error(t, fb.AddFile("file2", []byte("0123456789abcdef")))
+	assert.True(t, fb.IsFull())
+
+	// once full, even small files are dropped
+	assert.Error(t, fb.AddFile("file3", []byte("a")))
+
+	assertFileContent(t, fb, "0123456789", "file1")
+	assert.NoFileExists(t, filepath.Join(fb.flareDir, "file2"))
+	assert.NoFileExists(t, filepath.Join(fb.flareDir, "file3"))
+
+	logs, err := os.ReadFile(filepath.Join(fb.flareDir, "flare_creation.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(logs), "skipping 'file2': the flare size limit of 20 bytes has been reached")
+	assert.Contains(t, string(logs), "skipping 'file3': the flare size limit of 20 bytes has been reached")
+}
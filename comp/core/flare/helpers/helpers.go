@@ -25,6 +25,9 @@ import (
 //
 // Everytime a file is copied to the flare the original permissions and ownership of the file is recorded (Unix only).
 //
+// The builder enforces size limits (see SizeLimits): files bigger than the per-file limit are truncated, keeping their
+// head and tail, and once the total size limit is reached any further data is dropped and noted in the flare log.
+//
 // There are reserved path in the flare: "permissions.log" and "flare-creationg.log" (both at the root of the flare).
 // Note as well that the flare does nothing to prevent files to be overwritten by different calls. It's up to the caller
 // to make sure the path used in the flare doesn't clash with other modules.
@@ -109,6 +112,14 @@ type FlareBuilder interface {
 	// RegisterDirPerm add the current permissions for all the files in a directory to the flare's permissions.log.
 	RegisterDirPerm(path string)
 
+	// IsFull returns true once the total size limit of the flare has been reached. Any data added after this point is
+	// dropped.
+	IsFull() bool
+
+	// Logf adds a formatted message to the flare's log ("flare_creation.log"). Messages are not subject to the flare
+	// size limits.
+	Logf(format string, params ...interface{})
+
 	// Save archives all the data added to the flare, cleanup all the temporary directories and return the path to
 	// the archive file. Upon error the cleanup is still done.
 	// Error or not, once Save as been called the FlareBuilder is no longer capable of receiving new data. It is the caller
@@ -123,6 +134,9 @@ type flareCallback func(fb FlareBuilder) error
 
 // FlareProvider represents a callback to be used when creating a flare
 type FlareProvider struct {
+	// Name is the name of the component registering the callback. It is used to build a flare containing only the
+	// data from a single component. It can be empty.
+	Name     string
 	Callback flareCallback
 }
 
@@ -135,8 +149,15 @@ type Provider struct {
 
 // NewProvider returns a new Provider to be called when a flare is created
 func NewProvider(callback flareCallback) Provider {
+	return NewNamedProvider("", callback)
+}
+
+// NewNamedProvider returns a new Provider, registered under the name of a component, to be called when a flare is
+// created.
+func NewNamedProvider(name string, callback flareCallback) Provider {
 	return Provider{
 		Provider: FlareProvider{
+			Name:     name,
 			Callback: callback,
 		},
 	}
@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package flare
+
+import (
+	"errors"
+
+	pkgFlare "github.com/DataDog/datadog-agent/pkg/flare"
+)
+
+// componentName is the name of this component in fxutil.DisabledComponents.
+const componentName = "comp/core/flare"
+
+var errDisabled = errors.New("the flare component is disabled")
+
+// noopFlare implements the Component when it is disabled, refusing to create
+// flares.
+type noopFlare struct{}
+
+// Create implements Component#Create.
+func (noopFlare) Create(bool, string, string, []string, pkgFlare.ProfileData, error) (string, error) {
+	return "", errDisabled
+}
+
+// BuildFor implements Component#BuildFor.
+func (noopFlare) BuildFor(string) (string, error) {
+	return "", errDisabled
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package health implements a component to track the liveness of the
+// agent's subsystems.
+//
+// Subsystems register a named check and call Heartbeat on the returned handle
+// regularly.  A check that has not sent a heartbeat within the stale
+// threshold (`health_stale_threshold`) is reported as stale, and the agent is
+// healthy only when no check is stale.
+package health
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// Register registers a new liveness check with the given name.  The check
+	// is considered healthy until the stale threshold elapses without a call
+	// to Heartbeat on the returned handle.
+	Register(name string) Handle
+
+	// Status returns the current state of all registered checks.
+	Status() Status
+}
+
+// Handle is returned by Register and allows a subsystem to report its
+// liveness.
+type Handle interface {
+	// Heartbeat signals that the subsystem is alive.
+	Heartbeat()
+
+	// Deregister removes the check.  It returns an error if the check was
+	// already deregistered.
+	Deregister() error
+}
+
+// Status is the state of the registered checks.  Both lists are sorted by
+// name.
+type Status struct {
+	// Healthy lists the checks that sent a heartbeat recently.
+	Healthy []string
+	// Stale lists the checks that did not send a heartbeat within the stale
+	// threshold.
+	Stale []string
+}
+
+// IsHealthy returns true if no check is stale.
+func (s Status) IsHealthy() bool {
+	return len(s.Stale) == 0
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newHealth),
+)
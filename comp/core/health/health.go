@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+type dependencies struct {
+	fx.In
+
+	Config   config.Component
+	Disabled fxutil.DisabledComponents `optional:"true"`
+}
+
+// health implements the Component.
+type health struct {
+	sync.Mutex
+
+	clock          clock.Clock
+	staleThreshold time.Duration
+	checks         map[*handle]struct{}
+}
+
+// handle implements Handle.
+type handle struct {
+	h             *health
+	name          string
+	lastHeartbeat time.Time
+}
+
+func newHealth(deps dependencies) Component {
+	if deps.Disabled.IsDisabled(componentName) {
+		return noopHealth{}
+	}
+	return newHealthWithClock(clock.New(), deps.Config.GetDuration("health_stale_threshold"))
+}
+
+func newHealthWithClock(clk clock.Clock, staleThreshold time.Duration) *health {
+	return &health{
+		clock:          clk,
+		staleThreshold: staleThreshold,
+		checks:         make(map[*handle]struct{}),
+	}
+}
+
+// Register implements Component#Register.
+func (h *health) Register(name string) Handle {
+	h.Lock()
+	defer h.Unlock()
+
+	hdl := &handle{
+		h:             h,
+		name:          name,
+		lastHeartbeat: h.clock.Now(),
+	}
+	h.checks[hdl] = struct{}{}
+	return hdl
+}
+
+// Status implements Component#Status.
+func (h *health) Status() Status {
+	h.Lock()
+	defer h.Unlock()
+
+	status := Status{}
+	now := h.clock.Now()
+	for hdl := range h.checks {
+		if now.Sub(hdl.lastHeartbeat) > h.staleThreshold {
+			status.Stale = append(status.Stale, hdl.name)
+		} else {
+			status.Healthy = append(status.Healthy, hdl.name)
+		}
+	}
+
+	sort.Strings(status.Healthy)
+	sort.Strings(status.Stale)
+	return status
+}
+
+// Heartbeat implements Handle#Heartbeat.
+func (hdl *handle) Heartbeat() {
+	hdl.h.Lock()
+	defer hdl.h.Unlock()
+
+	hdl.lastHeartbeat = hdl.h.clock.Now()
+}
+
+// Deregister implements Handle#Deregister.
+func (hdl *handle) Deregister() error {
+	hdl.h.Lock()
+	defer hdl.h.Unlock()
+
+	if _, found := hdl.h.checks[hdl]; !found {
+		return errors.New("check not registered")
+	}
+	delete(hdl.h.checks, hdl)
+	return nil
+}
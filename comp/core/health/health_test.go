@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestHealthyCheck(t *testing.T) {
+	clk := clock.NewMock()
+	h := newHealthWithClock(clk, 30*time.Second)
+
+	handle := h.Register("check")
+	clk.Add(20 * time.Second)
+	handle.Heartbeat()
+	clk.Add(20 * time.Second)
+
+	status := h.Status()
+	assert.True(t, status.IsHealthy())
+	assert.Equal(t, []string{"check"}, status.Healthy)
+	assert.Empty(t, status.Stale)
+}
+
+func TestStaleCheck(t *testing.T) {
+	clk := clock.NewMock()
+	h := newHealthWithClock(clk, 30*time.Second)
+
+	h.Register("stale")
+	fresh := h.Register("fresh")
+	clk.Add(31 * time.Second)
+	fresh.Heartbeat()
+
+	status := h.Status()
+	assert.False(t, status.IsHealthy())
+	assert.Equal(t, []string{"fresh"}, status.Healthy)
+	assert.Equal(t, []string{"stale"}, status.Stale)
+}
+
+func TestDeregister(t *testing.T) {
+	clk := clock.NewMock()
+	h := newHealthWithClock(clk, 30*time.Second)
+
+	handle := h.Register("check")
+	clk.Add(time.Minute)
+	assert.False(t, h.Status().IsHealthy())
+
+	assert.NoError(t, handle.Deregister())
+	assert.Equal(t, Status{}, h.Status())
+	assert.True(t, h.Status().IsHealthy())
+
+	assert.Error(t, handle.Deregister())
+}
+
+func TestStaleThresholdFromConfig(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		config.MockModule,
+		Module,
+	), func(c Component) {
+		assert.Equal(t, 30*time.Second, c.(*health).staleThreshold)
+	})
+}
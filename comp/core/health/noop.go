@@ -0,0 +1,28 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+// componentName is the name of this component in fxutil.DisabledComponents.
+const componentName = "comp/core/health"
+
+// noopHealth implements the Component when it is disabled: nothing is
+// tracked, and the agent is always reported healthy.
+type noopHealth struct{}
+
+// noopHandle implements Handle for noopHealth.
+type noopHandle struct{}
+
+// Register implements Component#Register.
+func (noopHealth) Register(string) Handle { return noopHandle{} }
+
+// Status implements Component#Status.
+func (noopHealth) Status() Status { return Status{} }
+
+// Heartbeat implements Handle#Heartbeat.
+func (noopHandle) Heartbeat() {}
+
+// Deregister implements Handle#Deregister.
+func (noopHandle) Deregister() error { return nil }
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package hostname implements a component to resolve the hostname of the
+// agent.  This component temporarily wraps pkg/util/hostname.
+//
+// The hostname is resolved by going down a list of providers: the `hostname`
+// and `hostname_file` configuration, cloud metadata, container runtimes, FQDN
+// and finally the OS hostname.  The result is cached for the lifetime of the
+// agent.
+//
+// The mock component always returns the same hostname.
+package hostname
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// Get returns the hostname of the agent.
+	Get(ctx context.Context) (string, error)
+
+	// GetWithProvider returns the hostname of the agent and the name of the
+	// provider that was used to resolve it.
+	GetWithProvider(ctx context.Context) (hostname string, provider string, err error)
+}
+
+// Mock implements mock-specific methods.
+type Mock interface {
+	Component
+
+	// Set sets the hostname returned by the mock.
+	Set(hostname string)
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newHostname),
+)
+
+// MockModule defines the fx options for the mock component.
+var MockModule = fxutil.Component(
+	fx.Provide(newMock),
+)
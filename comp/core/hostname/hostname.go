@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package hostname
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/util/hostname"
+)
+
+type dependencies struct {
+	fx.In
+
+	// Config is not used directly, but pkg/util/hostname reads the
+	// configuration and thus requires it to be loaded.
+	Config config.Component
+}
+
+// resolver implements the Component.
+type resolver struct {
+	// this component is currently implementing a thin wrapper around
+	// pkg/util/hostname, and uses the cache in that package.
+}
+
+func newHostname(deps dependencies) Component {
+	return &resolver{}
+}
+
+// Get implements Component#Get.
+func (*resolver) Get(ctx context.Context) (string, error) {
+	return hostname.Get(ctx)
+}
+
+// GetWithProvider implements Component#GetWithProvider.
+func (*resolver) GetWithProvider(ctx context.Context) (string, string, error) {
+	data, err := hostname.GetWithProvider(ctx)
+	return data.Hostname, data.Provider, err
+}
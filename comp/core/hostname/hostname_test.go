@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package hostname
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/util/cache"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func resetCache(t *testing.T) {
+	cache.Cache.Delete(cache.BuildAgentKey("hostname"))
+	t.Cleanup(func() { cache.Cache.Delete(cache.BuildAgentKey("hostname")) })
+}
+
+func TestGetFromConfig(t *testing.T) {
+	resetCache(t)
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		config.MockModule,
+		Module,
+	), func(cfg config.Component, h Component) {
+		cfg.(config.Mock).Set("hostname", "hostname-from-configuration")
+
+		hostname, err := h.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "hostname-from-configuration", hostname)
+
+		hostname, provider, err := h.GetWithProvider(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "hostname-from-configuration", hostname)
+		assert.Equal(t, "configuration", provider)
+	})
+}
+
+func TestGetFromHostnameFile(t *testing.T) {
+	resetCache(t)
+
+	hostnameFile := filepath.Join(t.TempDir(), "hostname")
+	require.NoError(t, os.WriteFile(hostnameFile, []byte("hostname-from-file"), 0o644))
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.Params{}),
+		config.MockModule,
+		Module,
+	), func(cfg config.Component, h Component) {
+		// without a 'hostname', the next provider in the chain is used
+		cfg.(config.Mock).Set("hostname_file", hostnameFile)
+
+		hostname, provider, err := h.GetWithProvider(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "hostname-from-file", hostname)
+		assert.Equal(t, "hostnameFile", provider)
+	})
+}
+
+func TestMock(t *testing.T) {
+	fxutil.Test(t, MockModule, func(h Component) {
+		hostname, provider, err := h.GetWithProvider(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MockHostname, hostname)
+		assert.Equal(t, "mock", provider)
+
+		h.(Mock).Set("other-hostname")
+		hostname, err = h.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "other-hostname", hostname)
+	})
+}
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package hostname
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// MockHostname is the hostname returned by the mock component, unless
+	// changed with Mock#Set.
+	MockHostname = "my-hostname"
+
+	mockProvider = "mock"
+)
+
+// mock implements the Mock component.
+type mock struct {
+	sync.Mutex
+	hostname string
+}
+
+func newMock() Component {
+	return &mock{hostname: MockHostname}
+}
+
+// Get implements Component#Get.
+func (m *mock) Get(context.Context) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.hostname, nil
+}
+
+// GetWithProvider implements Component#GetWithProvider.
+func (m *mock) GetWithProvider(ctx context.Context) (string, string, error) {
+	h, err := m.Get(ctx)
+	return h, mockProvider, err
+}
+
+// Set implements Mock#Set.
+func (m *mock) Set(hostname string) {
+	m.Lock()
+	defer m.Unlock()
+	m.hostname = hostname
+}
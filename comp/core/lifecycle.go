@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/log"
+)
+
+// hookFunc is the type of the OnStart and OnStop functions of an fx.Hook.
+type hookFunc func(context.Context) error
+
+// lifecycleLogger holds the logger used to log lifecycle hooks.
+//
+// The log component itself depends on fx.Lifecycle, so it cannot be a
+// dependency of the fx.Lifecycle decorator.  Instead, it is filled in by an
+// fx.Invoke, which always runs before any hook is executed.
+type lifecycleLogger struct {
+	log log.Component
+}
+
+// bundleLifecycle wraps an fx.Lifecycle so that every OnStart and OnStop hook
+// is logged along with its duration (when logHooks is set), and/or is given a
+// deadline (when startDeadline or stopDeadline is set).
+type bundleLifecycle struct {
+	fx.Lifecycle
+	logger *lifecycleLogger
+
+	logHooks      bool
+	startDeadline time.Duration
+	stopDeadline  time.Duration
+}
+
+// lifecycleOptions returns the fx options that decorate fx.Lifecycle within
+// the bundle, according to BundleParams.LogLifecycle, StartDeadline and
+// StopDeadline.
+func lifecycleOptions() fx.Option {
+	return fx.Options(
+		fx.Provide(func() *lifecycleLogger { return &lifecycleLogger{} }),
+		fx.Decorate(func(lc fx.Lifecycle, params BundleParams, logger *lifecycleLogger) fx.Lifecycle {
+			if !params.wrapsLifecycle() {
+				return lc
+			}
+			return &bundleLifecycle{
+				Lifecycle:     lc,
+				logger:        logger,
+				logHooks:      params.LogLifecycle,
+				startDeadline: params.StartDeadline,
+				stopDeadline:  params.StopDeadline,
+			}
+		}),
+		fx.Invoke(func(params BundleParams, logger *lifecycleLogger, log log.Component) {
+			if params.wrapsLifecycle() {
+				logger.log = log
+			}
+		}),
+	)
+}
+
+// wrapsLifecycle returns true if the lifecycle hooks need to be wrapped.
+func (p BundleParams) wrapsLifecycle() bool {
+	return p.LogLifecycle || p.StartDeadline > 0 || p.StopDeadline > 0
+}
+
+// Append implements fx.Lifecycle#Append.
+func (lc *bundleLifecycle) Append(hook fx.Hook) {
+	if hook.OnStart != nil {
+		hook.OnStart = lc.wrap("OnStart", hook.OnStart, lc.startDeadline)
+	}
+	if hook.OnStop != nil {
+		hook.OnStop = lc.wrap("OnStop", hook.OnStop, lc.stopDeadline)
+	}
+	lc.Lifecycle.Append(hook)
+}
+
+// wrap returns a hook function running fn with the given deadline (if not
+// zero), and logging its execution (if enabled).
+func (lc *bundleLifecycle) wrap(kind string, fn hookFunc, deadline time.Duration) hookFunc {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+
+	if deadline > 0 {
+		fn = lc.withDeadline(kind, name, fn, deadline)
+	}
+	if lc.logHooks {
+		fn = lc.withLogging(kind, name, fn)
+	}
+	return fn
+}
+
+// withDeadline returns a hook function cancelling the context given to fn once
+// the deadline is exceeded.  If fn does not honor the context, it is left
+// running in the background and the returned function fails anyway, so that
+// a single hook cannot wedge the whole agent.
+func (lc *bundleLifecycle) withDeadline(kind, name string, fn hookFunc, deadline time.Duration) hookFunc {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, deadline)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() { done <- fn(ctx) }()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			err := fmt.Errorf("%s hook %s did not complete within %s: %w", kind, name, deadline, ctx.Err())
+			if log := lc.logger.log; log != nil {
+				_ = log.Errorf("fx lifecycle: %v", err)
+			}
+			return err
+		}
+	}
+}
+
+// withLogging returns a hook function logging the execution of fn.
+func (lc *bundleLifecycle) withLogging(kind, name string, fn hookFunc) hookFunc {
+	return func(ctx context.Context) error {
+		log := lc.logger.log
+		if log == nil {
+			return fn(ctx)
+		}
+
+		log.Infof("fx lifecycle: running %s hook %s", kind, name)
+		start := time.Now()
+		err := fn(ctx)
+		if err != nil {
+			log.Infof("fx lifecycle: %s hook %s failed after %s: %v", kind, name, time.Since(start), err)
+		} else {
+			log.Infof("fx lifecycle: %s hook %s done in %s", kind, name, time.Since(start))
+		}
+		return err
+	}
+}
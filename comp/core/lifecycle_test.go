@@ -0,0 +1,161 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// recordingLogger records the messages logged with Infof.
+type recordingLogger struct {
+	log.Component
+	sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Infof(format string, params ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, params...))
+}
+
+func (l *recordingLogger) Errorf(format string, params ...interface{}) error {
+	l.Infof(format, params...)
+	return fmt.Errorf(format, params...)
+}
+
+func (l *recordingLogger) lifecycleMessages() []string {
+	l.Lock()
+	defer l.Unlock()
+	msgs := []string{}
+	for _, msg := range l.messages {
+		if strings.HasPrefix(msg, "fx lifecycle:") {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func runWithLifecycleLogging(t *testing.T, enabled bool) *recordingLogger {
+	recorder := &recordingLogger{}
+	fxutil.Test(t, fx.Options(
+		fx.Supply(BundleParams{
+			ConfigParams: config.NewParams("", config.WithConfigMissingOK(true)),
+			LogParams:    log.LogForOneShot("TEST", "info", false),
+			LogLifecycle: enabled,
+		}),
+		fx.Decorate(func(l log.Component) log.Component {
+			recorder.Component = l
+			return recorder
+		}),
+		Bundle,
+	), func(log.Component) {})
+	return recorder
+}
+
+func TestLifecycleLogging(t *testing.T) {
+	recorder := runWithLifecycleLogging(t, true)
+
+	// the log component registers an OnStop hook to flush the logs
+	hook := "OnStop hook github.com/DataDog/datadog-agent/comp/core/log.newLogger.func1"
+	assert.Equal(t, []string{"fx lifecycle: running " + hook}, recorder.lifecycleMessages()[:1])
+	assert.Len(t, recorder.lifecycleMessages(), 2)
+	assert.True(t, strings.HasPrefix(recorder.lifecycleMessages()[1], "fx lifecycle: "+hook+" done in "))
+}
+
+func TestLifecycleLoggingDisabled(t *testing.T) {
+	recorder := runWithLifecycleLogging(t, false)
+	assert.Empty(t, recorder.lifecycleMessages())
+}
+
+func slowStart(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// stuckStart returns a hook ignoring its context, which is stuck until the end
+// of the test.
+func stuckStart(t *testing.T) hookFunc {
+	unblock := make(chan struct{})
+	t.Cleanup(func() { close(unblock) })
+	return func(context.Context) error {
+		<-unblock
+		return nil
+	}
+}
+
+func newDeadlineLifecycle(t *testing.T) (*fxtest.Lifecycle, *bundleLifecycle, *recordingLogger) {
+	recorder := &recordingLogger{}
+	inner := fxtest.NewLifecycle(t)
+	lc := &bundleLifecycle{
+		Lifecycle:     inner,
+		logger:        &lifecycleLogger{log: recorder},
+		startDeadline: 10 * time.Millisecond,
+	}
+	return inner, lc, recorder
+}
+
+func TestLifecycleStartDeadline(t *testing.T) {
+	for name, newHook := range map[string]func(t *testing.T) hookFunc{
+		"honoring the context": func(*testing.T) hookFunc { return slowStart },
+		"ignoring the context": stuckStart,
+	} {
+		t.Run(name, func(t *testing.T) {
+			inner, lc, recorder := newDeadlineLifecycle(t)
+			lc.Append(fx.Hook{OnStart: newHook(t)})
+
+			err := inner.Start(context.Background())
+			require.Error(t, err)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Contains(t, err.Error(), "OnStart hook github.com/DataDog/datadog-agent/comp/core.")
+			assert.Contains(t, err.Error(), "did not complete within 10ms")
+
+			// the offender is logged
+			msgs := recorder.lifecycleMessages()
+			require.Len(t, msgs, 1)
+			assert.Contains(t, msgs[0], "did not complete within 10ms")
+		})
+	}
+}
+
+func TestLifecycleDeadlineNotExceeded(t *testing.T) {
+	inner, lc, recorder := newDeadlineLifecycle(t)
+	lc.stopDeadline = 10 * time.Millisecond
+
+	var started, stopped bool
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			started = hasDeadline
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			stopped = hasDeadline
+			return nil
+		},
+	})
+
+	require.NoError(t, inner.Start(context.Background()))
+	require.NoError(t, inner.Stop(context.Background()))
+	assert.True(t, started)
+	assert.True(t, stopped)
+	assert.Empty(t, recorder.lifecycleMessages())
+}
@@ -8,13 +8,19 @@
 // The component uses a number of values in BundleParams to decide how to
 // initialize itself, reading values from the comp/core/config component when
 // necessary.  At present, it configures and wraps the global logger in
-// pkg/util/log, but will eventually be self-sufficient.
+// pkg/util/log, but will eventually be self-sufficient.  When the
+// comp/core/telemetry component is available, the logged entries are counted
+// by level.
 //
 // The mock component does not read any configuration values, and redirects
 // logging output to `t.Log(..)`, for ease of investigation when a test fails.
 package log
 
 import (
+	"testing"
+	"time"
+
+	"github.com/cihub/seelog"
 	"go.uber.org/fx"
 
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
@@ -62,13 +68,47 @@ type Component interface {
 
 	// Flush will flush the contents of the logs to the sinks
 	Flush()
+
+	// SetLevelFor sets the log level for the given duration, after which
+	// the previous level is restored, or earlier if the returned override is
+	// cancelled.  While several overrides are active, the most recent one
+	// is in use.
+	SetLevelFor(level seelog.LogLevel, d time.Duration) (LevelOverride, error)
+}
+
+// LevelOverride is a log level set temporarily with Component#SetLevelFor.
+type LevelOverride interface {
+	// Cancel ends the override before its duration.  It does nothing if the
+	// override is already over.
+	Cancel()
 }
 
 // Mock is the mocked component type.
 type Mock interface {
 	Component
 
-	// no further methods are defined.
+	// LoggedEntries returns all the entries logged so far, in order.
+	LoggedEntries() []Entry
+
+	// AssertLogged asserts that an entry containing the given substring was
+	// logged at the given level, failing the test otherwise.  It returns
+	// whether the assertion succeeded.
+	AssertLogged(t testing.TB, level seelog.LogLevel, substring string) bool
+
+	// FailOnError makes every entry logged at the error level or above fail
+	// the given test, unless it contains a substring expected with
+	// ExpectError.
+	FailOnError(t testing.TB)
+
+	// ExpectError allows the error entries containing the given substring,
+	// when FailOnError is enabled.
+	ExpectError(substring string)
+}
+
+// Entry is a log entry recorded by the mock component.
+type Entry struct {
+	Level   seelog.LogLevel
+	Message string
 }
 
 // Module defines the fx options for this component.
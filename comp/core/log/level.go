@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// levelOverrides coordinates the temporary log levels set with SetLevelFor.
+// The level of the most recent override still active is in use, or the level
+// from before the first one once they are all over.
+type levelOverrides struct {
+	sync.Mutex
+
+	// setLevel changes the level of the logger
+	setLevel func(seelog.LogLevel) error
+
+	// base is the level before the active overrides
+	base   seelog.LogLevel
+	active []*levelOverride
+}
+
+// levelOverride implements LevelOverride.
+type levelOverride struct {
+	overrides *levelOverrides
+	level     seelog.LogLevel
+	timer     *time.Timer
+}
+
+func newLevelOverrides(setLevel func(seelog.LogLevel) error) *levelOverrides {
+	return &levelOverrides{setLevel: setLevel}
+}
+
+// add sets the level for the given duration.
+func (o *levelOverrides) add(level seelog.LogLevel, d time.Duration) (LevelOverride, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.active) == 0 {
+		// the error is for an uninitialized logger, in which case the
+		// level set below fails too
+		o.base, _ = log.GetLogLevel()
+	}
+	if err := o.setLevel(level); err != nil {
+		return nil, err
+	}
+
+	override := &levelOverride{overrides: o, level: level}
+	o.active = append(o.active, override)
+	override.timer = time.AfterFunc(d, override.Cancel)
+	return override, nil
+}
+
+// Cancel implements LevelOverride#Cancel.
+func (override *levelOverride) Cancel() {
+	o := override.overrides
+	o.Lock()
+	defer o.Unlock()
+
+	override.timer.Stop()
+
+	found := false
+	for i, active := range o.active {
+		if active == override {
+			o.active = append(o.active[:i], o.active[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		// already over
+		return
+	}
+
+	level := o.base
+	if len(o.active) > 0 {
+		level = o.active[len(o.active)-1].level
+	}
+	if current, _ := log.GetLogLevel(); current == level {
+		return
+	}
+	if err := o.setLevel(level); err != nil {
+		log.Warnf("Could not restore the log level to %s: %v", level, err)
+	}
+}
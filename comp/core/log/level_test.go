@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+func currentLevel(t *testing.T) seelog.LogLevel {
+	level, err := log.GetLogLevel()
+	require.NoError(t, err)
+	return level
+}
+
+func assertLevel(t *testing.T, expected seelog.LogLevel) {
+	t.Helper()
+	assert.Equal(t, expected, currentLevel(t))
+}
+
+func TestSetLevelFor(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(Params{}),
+		config.MockModule,
+		MockModule,
+	), func(logger Component) {
+		assertLevel(t, seelog.TraceLvl)
+
+		// the level is restored after the duration
+		_, err := logger.SetLevelFor(seelog.InfoLvl, 50*time.Millisecond)
+		require.NoError(t, err)
+		assertLevel(t, seelog.InfoLvl)
+		assert.Eventually(t, func() bool { return currentLevel(t) == seelog.TraceLvl }, 5*time.Second, 10*time.Millisecond)
+
+		// or when cancelled
+		override, err := logger.SetLevelFor(seelog.DebugLvl, time.Hour)
+		require.NoError(t, err)
+		assertLevel(t, seelog.DebugLvl)
+		override.Cancel()
+		assertLevel(t, seelog.TraceLvl)
+
+		// cancelling again does nothing
+		other, err := logger.SetLevelFor(seelog.WarnLvl, time.Hour)
+		require.NoError(t, err)
+		override.Cancel()
+		assertLevel(t, seelog.WarnLvl)
+		other.Cancel()
+		assertLevel(t, seelog.TraceLvl)
+	})
+}
+
+func TestSetLevelForConcurrentOverrides(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(Params{}),
+		config.MockModule,
+		MockModule,
+	), func(logger Component) {
+		first, err := logger.SetLevelFor(seelog.DebugLvl, time.Hour)
+		require.NoError(t, err)
+		second, err := logger.SetLevelFor(seelog.InfoLvl, time.Hour)
+		require.NoError(t, err)
+		third, err := logger.SetLevelFor(seelog.WarnLvl, time.Hour)
+		require.NoError(t, err)
+
+		// the last one wins
+		assertLevel(t, seelog.WarnLvl)
+
+		// until it's over, then the most recent one still active is used
+		second.Cancel()
+		assertLevel(t, seelog.WarnLvl)
+		third.Cancel()
+		assertLevel(t, seelog.DebugLvl)
+
+		// and the level from before the first one is restored at the end
+		first.Cancel()
+		assertLevel(t, seelog.TraceLvl)
+	})
+}
@@ -7,23 +7,46 @@ package log
 
 import (
 	"context"
-	"errors"
+	"time"
+
+	"github.com/cihub/seelog"
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+	pkgtelemetry "github.com/DataDog/datadog-agent/pkg/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"go.uber.org/fx"
 )
 
+type dependencies struct {
+	fx.In
+
+	Lc     fx.Lifecycle
+	Params Params
+	Config config.Component
+
+	// Telemetry is optional, the logged entries are counted by level when
+	// it's available.
+	Telemetry telemetry.Component `optional:"true"`
+}
+
 // logger implements the component
 type logger struct {
 	// this component is currently implementing a thin wrapper around
 	// pkg/util/log, and uses globals in that package.
+
+	levels *levelOverrides
+
+	// entries counts the logged entries, by level, when the telemetry
+	// component is available
+	entries [seelog.Off]pkgtelemetry.SimpleCounter
 }
 
-func newLogger(lc fx.Lifecycle, params Params, config config.Component) (Component, error) {
-	if params.logLevelFn == nil {
-		return nil, errors.New("must call one of core.BundleParams.LogForOneShot or LogForDaemon")
+func newLogger(deps dependencies) (Component, error) {
+	params, config := deps.Params, deps.Config
+	if err := params.Validate(); err != nil {
+		return nil, err
 	}
 	err := pkgconfig.SetupLogger(
 		pkgconfig.LoggerName(params.loggerName),
@@ -37,8 +60,14 @@ func newLogger(lc fx.Lifecycle, params Params, config config.Component) (Compone
 		return nil, err
 	}
 
-	logger := &logger{}
-	lc.Append(fx.Hook{OnStop: func(context.Context) error {
+	logger := &logger{levels: newLevelOverrides(changeLogLevel)}
+	if deps.Telemetry != nil {
+		entries := deps.Telemetry.NewCounter("log", "entries", []string{"level"}, "Number of entries logged by the agent, by level")
+		for level := seelog.LogLevel(seelog.TraceLvl); level < seelog.Off; level++ {
+			logger.entries[level] = entries.WithValues(level.String())
+		}
+	}
+	deps.Lc.Append(fx.Hook{OnStop: func(context.Context) error {
 		logger.Flush()
 		return nil
 	}})
@@ -47,45 +76,94 @@ func newLogger(lc fx.Lifecycle, params Params, config config.Component) (Compone
 }
 
 // Trace implements Component#Trace.
-func (*logger) Trace(v ...interface{}) { log.Trace(v...) }
+func (l *logger) Trace(v ...interface{}) {
+	l.count(seelog.TraceLvl)
+	log.Trace(v...)
+}
 
 // Tracef implements Component#Tracef.
-func (*logger) Tracef(format string, params ...interface{}) { log.Tracef(format, params...) }
+func (l *logger) Tracef(format string, params ...interface{}) {
+	l.count(seelog.TraceLvl)
+	log.Tracef(format, params...)
+}
 
 // Debug implements Component#Debug.
-func (*logger) Debug(v ...interface{}) { log.Debug(v...) }
+func (l *logger) Debug(v ...interface{}) {
+	l.count(seelog.DebugLvl)
+	log.Debug(v...)
+}
 
 // Debugf implements Component#Debugf.
-func (*logger) Debugf(format string, params ...interface{}) { log.Debugf(format, params...) }
+func (l *logger) Debugf(format string, params ...interface{}) {
+	l.count(seelog.DebugLvl)
+	log.Debugf(format, params...)
+}
 
 // Info implements Component#Info.
-func (*logger) Info(v ...interface{}) { log.Info(v...) }
+func (l *logger) Info(v ...interface{}) {
+	l.count(seelog.InfoLvl)
+	log.Info(v...)
+}
 
 // Infof implements Component#Infof.
-func (*logger) Infof(format string, params ...interface{}) { log.Infof(format, params...) }
+func (l *logger) Infof(format string, params ...interface{}) {
+	l.count(seelog.InfoLvl)
+	log.Infof(format, params...)
+}
 
 // Warn implements Component#Warn.
-func (*logger) Warn(v ...interface{}) error { return log.Warn(v...) }
+func (l *logger) Warn(v ...interface{}) error {
+	l.count(seelog.WarnLvl)
+	return log.Warn(v...)
+}
 
 // Warnf implements Component#Warnf.
-func (*logger) Warnf(format string, params ...interface{}) error { return log.Warnf(format, params...) }
+func (l *logger) Warnf(format string, params ...interface{}) error {
+	l.count(seelog.WarnLvl)
+	return log.Warnf(format, params...)
+}
 
 // Error implements Component#Error.
-func (*logger) Error(v ...interface{}) error { return log.Error(v...) }
+func (l *logger) Error(v ...interface{}) error {
+	l.count(seelog.ErrorLvl)
+	return log.Error(v...)
+}
 
 // Errorf implements Component#Errorf.
-func (*logger) Errorf(format string, params ...interface{}) error {
+func (l *logger) Errorf(format string, params ...interface{}) error {
+	l.count(seelog.ErrorLvl)
 	return log.Errorf(format, params...)
 }
 
 // Critical implements Component#Critical.
-func (*logger) Critical(v ...interface{}) error { return log.Critical(v...) }
+func (l *logger) Critical(v ...interface{}) error {
+	l.count(seelog.CriticalLvl)
+	return log.Critical(v...)
+}
 
 // Criticalf implements Component#Criticalf.
-func (*logger) Criticalf(format string, params ...interface{}) error {
+func (l *logger) Criticalf(format string, params ...interface{}) error {
+	l.count(seelog.CriticalLvl)
 	return log.Criticalf(format, params...)
 }
 
+// count counts an entry logged at the given level, if it's emitted.
+func (l *logger) count(level seelog.LogLevel) {
+	if l.entries[level] != nil && log.ShouldLog(level) {
+		l.entries[level].Inc()
+	}
+}
+
+// changeLogLevel changes the level of the logger set up by pkg/config.
+func changeLogLevel(level seelog.LogLevel) error {
+	return pkgconfig.ChangeLogLevel(level.String())
+}
+
+// SetLevelFor implements Component#SetLevelFor.
+func (l *logger) SetLevelFor(level seelog.LogLevel, d time.Duration) (LevelOverride, error) {
+	return l.levels.add(level, d)
+}
+
 // Flush implements Component#Flush.
 func (*logger) Flush() {
 	log.Flush()
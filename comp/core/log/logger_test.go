@@ -8,9 +8,11 @@ package log
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/fx"
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
 
@@ -24,3 +26,39 @@ func TestLogging(t *testing.T) {
 		log.Debugf("hello, world. %s", "hi")
 	})
 }
+
+func TestLoggingTelemetry(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(LogForOneShot("TEST", "info", false)),
+		fx.Supply(config.Params{}),
+		config.MockModule,
+		telemetry.MockModule,
+		Module,
+	), func(log Component, tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		log.Info("starting")
+		log.Infof("started in %ds", 1)
+		_ = log.Warn("disk usage is high")
+		_ = log.Errorf("cannot connect: %s", "timeout")
+		// below the level, so not emitted nor counted
+		log.Debug("connecting")
+
+		assert.Equal(t, 2.0, mock.Value("log", "entries", "info"))
+		assert.Equal(t, 1.0, mock.Value("log", "entries", "warn"))
+		assert.Equal(t, 1.0, mock.Value("log", "entries", "error"))
+		assert.Equal(t, 0.0, mock.Value("log", "entries", "debug"))
+		assert.Equal(t, 0.0, mock.Value("log", "entries", "critical"))
+	})
+}
+
+func TestLoggingWithoutTelemetry(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(LogForOneShot("TEST", "info", false)),
+		fx.Supply(config.Params{}),
+		config.MockModule,
+		Module,
+	), func(log Component) {
+		log.Info("no telemetry component, so nothing is counted")
+	})
+}
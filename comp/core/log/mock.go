@@ -7,7 +7,9 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cihub/seelog"
@@ -30,10 +32,14 @@ func (tbw *tbWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func newMockLogger(t testing.TB, lc fx.Lifecycle) (Component, error) {
-	// Build a logger that only logs to t.Log(..)
-	iface, err := seelog.LoggerFromWriterWithMinLevelAndFormat(&tbWriter{t}, seelog.TraceLvl,
+// newTBLogger builds a logger that only logs to t.Log(..), from the given level
+func newTBLogger(t testing.TB, level seelog.LogLevel) (seelog.LoggerInterface, error) {
+	return seelog.LoggerFromWriterWithMinLevelAndFormat(&tbWriter{t}, level,
 		"%Date(2006-01-02 15:04:05 MST) | %LEVEL | (%ShortFilePath:%Line in %FuncShort) | %ExtraTextContext%Msg%n")
+}
+
+func newMockLogger(t testing.TB, lc fx.Lifecycle) (Component, error) {
+	iface, err := newTBLogger(t, seelog.TraceLvl)
 	if err != nil {
 		return nil, err
 	}
@@ -47,5 +53,160 @@ func newMockLogger(t testing.TB, lc fx.Lifecycle) (Component, error) {
 	// install the logger into pkg/util/log
 	log.SetupLogger(iface, "trace")
 
-	return &logger{}, nil
+	// changing the level replaces the logger, as for the real component
+	levels := newLevelOverrides(func(level seelog.LogLevel) error {
+		iface, err := newTBLogger(t, level)
+		if err != nil {
+			return err
+		}
+		return log.ChangeLogLevel(iface, level.String())
+	})
+
+	return &mockLogger{logger: &logger{levels: levels}}, nil
+}
+
+// mockLogger implements the Mock component.  It records every entry, and
+// forwards it to the logger installed in pkg/util/log.
+type mockLogger struct {
+	*logger
+
+	sync.Mutex
+	entries []Entry
+
+	// failOnError is the test failed by unexpected error entries, if any
+	failOnError    testing.TB
+	expectedErrors []string
+}
+
+// record records an entry.
+func (m *mockLogger) record(level seelog.LogLevel, message string) {
+	m.Lock()
+	defer m.Unlock()
+	m.entries = append(m.entries, Entry{Level: level, Message: message})
+
+	if m.failOnError != nil && level >= seelog.ErrorLvl && !m.isExpectedError(message) {
+		m.failOnError.Errorf("unexpected %s entry logged: %q", level, message)
+	}
+}
+
+// isExpectedError returns whether the message contains an expected error.
+// Must be called with the lock held.
+func (m *mockLogger) isExpectedError(message string) bool {
+	for _, substring := range m.expectedErrors {
+		if strings.Contains(message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// sprint formats the given arguments, separated by spaces.
+func sprint(v ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// LoggedEntries implements Mock#LoggedEntries.
+func (m *mockLogger) LoggedEntries() []Entry {
+	m.Lock()
+	defer m.Unlock()
+	return append([]Entry{}, m.entries...)
+}
+
+// AssertLogged implements Mock#AssertLogged.
+func (m *mockLogger) AssertLogged(t testing.TB, level seelog.LogLevel, substring string) bool {
+	t.Helper()
+	entries := m.LoggedEntries()
+	for _, e := range entries {
+		if e.Level == level && strings.Contains(e.Message, substring) {
+			return true
+		}
+	}
+	t.Errorf("no %s entry containing %q was logged; logged entries: %v", level, substring, entries)
+	return false
+}
+
+// FailOnError implements Mock#FailOnError.
+func (m *mockLogger) FailOnError(t testing.TB) {
+	m.Lock()
+	defer m.Unlock()
+	m.failOnError = t
+}
+
+// ExpectError implements Mock#ExpectError.
+func (m *mockLogger) ExpectError(substring string) {
+	m.Lock()
+	defer m.Unlock()
+	m.expectedErrors = append(m.expectedErrors, substring)
+}
+
+// Trace implements Component#Trace.
+func (m *mockLogger) Trace(v ...interface{}) {
+	m.record(seelog.TraceLvl, sprint(v...))
+	m.logger.Trace(v...)
+}
+
+// Tracef implements Component#Tracef.
+func (m *mockLogger) Tracef(format string, params ...interface{}) {
+	m.record(seelog.TraceLvl, fmt.Sprintf(format, params...))
+	m.logger.Tracef(format, params...)
+}
+
+// Debug implements Component#Debug.
+func (m *mockLogger) Debug(v ...interface{}) {
+	m.record(seelog.DebugLvl, sprint(v...))
+	m.logger.Debug(v...)
+}
+
+// Debugf implements Component#Debugf.
+func (m *mockLogger) Debugf(format string, params ...interface{}) {
+	m.record(seelog.DebugLvl, fmt.Sprintf(format, params...))
+	m.logger.Debugf(format, params...)
+}
+
+// Info implements Component#Info.
+func (m *mockLogger) Info(v ...interface{}) {
+	m.record(seelog.InfoLvl, sprint(v...))
+	m.logger.Info(v...)
+}
+
+// Infof implements Component#Infof.
+func (m *mockLogger) Infof(format string, params ...interface{}) {
+	m.record(seelog.InfoLvl, fmt.Sprintf(format, params...))
+	m.logger.Infof(format, params...)
+}
+
+// Warn implements Component#Warn.
+func (m *mockLogger) Warn(v ...interface{}) error {
+	m.record(seelog.WarnLvl, sprint(v...))
+	return m.logger.Warn(v...)
+}
+
+// Warnf implements Component#Warnf.
+func (m *mockLogger) Warnf(format string, params ...interface{}) error {
+	m.record(seelog.WarnLvl, fmt.Sprintf(format, params...))
+	return m.logger.Warnf(format, params...)
+}
+
+// Error implements Component#Error.
+func (m *mockLogger) Error(v ...interface{}) error {
+	m.record(seelog.ErrorLvl, sprint(v...))
+	return m.logger.Error(v...)
+}
+
+// Errorf implements Component#Errorf.
+func (m *mockLogger) Errorf(format string, params ...interface{}) error {
+	m.record(seelog.ErrorLvl, fmt.Sprintf(format, params...))
+	return m.logger.Errorf(format, params...)
+}
+
+// Critical implements Component#Critical.
+func (m *mockLogger) Critical(v ...interface{}) error {
+	m.record(seelog.CriticalLvl, sprint(v...))
+	return m.logger.Critical(v...)
+}
+
+// Criticalf implements Component#Criticalf.
+func (m *mockLogger) Criticalf(format string, params ...interface{}) error {
+	m.record(seelog.CriticalLvl, fmt.Sprintf(format, params...))
+	return m.logger.Criticalf(format, params...)
 }
@@ -8,6 +8,8 @@ package log
 import (
 	"testing"
 
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/fx"
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
@@ -23,3 +25,73 @@ func TestMockLogging(t *testing.T) {
 		log.Debugf("hello, world. %s", "hi")
 	})
 }
+
+// failureRecorder is a testing.TB recording whether the test failed.
+type failureRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (f *failureRecorder) Errorf(format string, args ...interface{}) { f.failed = true }
+
+func TestMockAssertLogged(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(Params{}),
+		config.MockModule,
+		MockModule,
+	), func(log Component) {
+		mock := log.(Mock)
+
+		_ = log.Warnf("disk usage is at %d%%", 95)
+		log.Info("starting", "component")
+
+		assert.Equal(t, []Entry{
+			{Level: seelog.WarnLvl, Message: "disk usage is at 95%"},
+			{Level: seelog.InfoLvl, Message: "starting component"},
+		}, mock.LoggedEntries())
+
+		assert.True(t, mock.AssertLogged(t, seelog.WarnLvl, "disk usage"))
+
+		// absent entries or entries at another level fail the assertion
+		for _, tc := range []struct {
+			level     seelog.LogLevel
+			substring string
+		}{
+			{seelog.WarnLvl, "memory usage"},
+			{seelog.ErrorLvl, "disk usage"},
+		} {
+			rec := &failureRecorder{TB: t}
+			assert.False(t, mock.AssertLogged(rec, tc.level, tc.substring))
+			assert.True(t, rec.failed)
+		}
+	})
+}
+
+func TestMockFailOnError(t *testing.T) {
+	fxutil.Test(t, fx.Options(
+		fx.Supply(Params{}),
+		config.MockModule,
+		MockModule,
+	), func(log Component) {
+		mock := log.(Mock)
+
+		rec := &failureRecorder{TB: t}
+		mock.FailOnError(rec)
+		mock.ExpectError("connection refused")
+
+		// expected errors and entries below the error level are fine
+		_ = log.Errorf("could not connect: %s", "connection refused")
+		_ = log.Criticalf("giving up: connection refused")
+		_ = log.Warn("retrying")
+		assert.False(t, rec.failed)
+
+		// but any other error fails the test
+		_ = log.Error("could not parse the payload")
+		assert.True(t, rec.failed)
+
+		rec = &failureRecorder{TB: t}
+		mock.FailOnError(rec)
+		_ = log.Critical("out of memory")
+		assert.True(t, rec.failed)
+	})
+}
@@ -6,6 +6,7 @@
 package log
 
 import (
+	"errors"
 	"runtime"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
@@ -131,6 +132,27 @@ func (params *Params) LogToFile(logFile string) {
 	params.logFileFn = func(configGetter) string { return logFile }
 }
 
+// OverrideLogLevel modifies the parameters to set the log level, overriding any
+// previous log level parameter.
+func (params *Params) OverrideLogLevel(level string) {
+	params.logLevelFn = func(configGetter) string { return level }
+}
+
+// LogToConsole modifies the parameters to enable or disable writing logs to
+// the console, overriding any previous console parameter.
+func (params *Params) LogToConsole(enabled bool) {
+	params.logToConsoleFn = func(configGetter) bool { return enabled }
+}
+
+// Validate checks that the parameters have been set up with one of
+// LogForOneShot or LogForDaemon.
+func (params Params) Validate() error {
+	if params.logLevelFn == nil {
+		return errors.New("must call one of core.BundleParams.LogForOneShot or LogForDaemon")
+	}
+	return nil
+}
+
 // LoggerName is the name that appears in the logfile
 func (params Params) LoggerName() string {
 	return params.loggerName
@@ -147,3 +169,8 @@ func (params Params) LogLevelFn(c configGetter) string {
 func (params Params) LogFileFn(c configGetter) string {
 	return params.logFileFn(c)
 }
+
+// LogToConsoleFn returns whether logs are written to the console
+func (params Params) LogToConsoleFn(c configGetter) bool {
+	return params.logToConsoleFn(c)
+}
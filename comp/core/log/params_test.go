@@ -171,3 +171,19 @@ func TestLogToFile(t *testing.T) {
 
 	require.Equal(t, "/some/file", params.logFileFn(g))
 }
+
+func TestOverrideLogLevel(t *testing.T) {
+	params := LogForDaemon("TEST", "log_file", "/default/log")
+	params.OverrideLogLevel("debug")
+	g := &getter{strs: map[string]string{"log_level": "trace"}}
+
+	require.Equal(t, "debug", params.logLevelFn(g))
+}
+
+func TestLogToConsole(t *testing.T) {
+	params := LogForOneShot("TEST", "trace", true)
+	params.LogToConsole(false)
+	g := &getter{}
+
+	require.Equal(t, false, params.logToConsoleFn(g))
+}
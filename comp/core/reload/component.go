@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package reload implements a component coordinating configuration reloads,
+// such as on SIGHUP.
+//
+// With config.WithWatchFiles(true), the configuration is also reloaded when
+// its file changes.
+//
+// Reloading calls the config component's Reload method, then notifies every
+// registered Reloadable (see NewProvider) of the result.  A failed reload is
+// logged, and the previous configuration is kept.
+package reload
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// Reload reloads the configuration and notifies all Reloadables.  It
+	// returns the error of the config component, if the reload failed.
+	Reload() error
+}
+
+// Reloadable is implemented by components reacting to configuration reloads.
+type Reloadable interface {
+	// OnConfigReload is called after each reload attempt.  The error is nil
+	// if the configuration was reloaded; otherwise the reload failed and the
+	// previous configuration is still in use.
+	OnConfigReload(err error)
+}
+
+// Provider is provided by other components to register themselves to be
+// notified of configuration reloads.
+type Provider struct {
+	fx.Out
+
+	Reloadable Reloadable `group:"reloadable"`
+}
+
+// NewProvider returns a new Provider registering the given Reloadable.
+func NewProvider(reloadable Reloadable) Provider {
+	return Provider{
+		Reloadable: reloadable,
+	}
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newReload),
+)
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package reload
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+)
+
+type dependencies struct {
+	fx.In
+
+	Lc          fx.Lifecycle
+	Config      config.Component
+	Params      config.Params `optional:"true"`
+	Log         log.Component
+	Reloadables []Reloadable `group:"reloadable"`
+}
+
+// reload implements the Component.
+type reload struct {
+	// Mutex serializes reloads, so that Reloadables are notified in order.
+	sync.Mutex
+
+	config      config.Component
+	log         log.Component
+	reloadables []Reloadable
+}
+
+func newReload(deps dependencies) Component {
+	reloadables := make([]Reloadable, 0, len(deps.Reloadables))
+	for _, r := range deps.Reloadables {
+		if r != nil {
+			reloadables = append(reloadables, r)
+		}
+	}
+	r := &reload{
+		config:      deps.Config,
+		log:         deps.Log,
+		reloadables: reloadables,
+	}
+
+	if deps.Params.WatchFiles() {
+		var watcher *fileWatcher
+		deps.Lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				// the file is only known once the configuration is loaded
+				file := pkgconfig.Datadog.ConfigFileUsed()
+				if file == "" {
+					r.log.Warn("No configuration file to watch, the configuration won't be reloaded when it changes")
+					return nil
+				}
+
+				var err error
+				if watcher, err = r.watchFiles([]string{file}); err != nil {
+					r.log.Errorf("Could not watch the configuration file %s, the configuration won't be reloaded when it changes: %v", file, err)
+				}
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				if watcher != nil {
+					return watcher.stop()
+				}
+				return nil
+			},
+		})
+	}
+
+	return r
+}
+
+// Reload implements Component#Reload.
+func (r *reload) Reload() error {
+	r.Lock()
+	defer r.Unlock()
+
+	err := r.config.Reload()
+	if err != nil {
+		r.log.Errorf("Configuration reload failed, keeping the previous configuration: %v", err)
+	} else {
+		r.log.Infof("Configuration reloaded, notifying %d components", len(r.reloadables))
+	}
+
+	for _, reloadable := range r.reloadables {
+		reloadable.OnConfigReload(err)
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log"
+	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// observer records the reloads it is notified of, along with the config value
+// it reads at that time.
+type observer struct {
+	sync.Mutex
+
+	config   config.Component
+	errors   []error
+	hostname []string
+}
+
+func (o *observer) OnConfigReload(err error) {
+	o.Lock()
+	defer o.Unlock()
+	o.errors = append(o.errors, err)
+	o.hostname = append(o.hostname, o.config.GetString("hostname"))
+}
+
+func (o *observer) reloads() int {
+	o.Lock()
+	defer o.Unlock()
+	return len(o.errors)
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestReload(t *testing.T) {
+	// the real config component loads the global configuration
+	previous := pkgconfig.Datadog
+	t.Cleanup(func() { pkgconfig.Datadog = previous })
+
+	confPath := filepath.Join(t.TempDir(), "datadog.yaml")
+	writeConfig(t, confPath, "hostname: first")
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.NewAgentParamsWithoutSecrets(confPath)),
+		config.Module,
+		log.MockModule,
+		fx.Provide(func(cfg config.Component) *observer { return &observer{config: cfg} }),
+		fx.Provide(func(o *observer) Provider { return NewProvider(o) }),
+		Module,
+	), func(r Component, cfg config.Component, o *observer) {
+		require.Equal(t, "first", cfg.GetString("hostname"))
+		// the global config is reloaded in place, so that the packages
+		// holding it see the new settings
+		held := pkgconfig.Datadog
+
+		// successful reload
+		writeConfig(t, confPath, "hostname: second")
+		require.NoError(t, r.Reload())
+		assert.Equal(t, "second", cfg.GetString("hostname"))
+		assert.Equal(t, "second", held.GetString("hostname"))
+
+		// failed reload: the configuration is rolled back
+		writeConfig(t, confPath, "hostname: [third")
+		require.Error(t, r.Reload())
+		assert.Equal(t, "second", cfg.GetString("hostname"))
+
+		require.Len(t, o.errors, 2)
+		assert.NoError(t, o.errors[0])
+		assert.Error(t, o.errors[1])
+		assert.Equal(t, []string{"second", "second"}, o.hostname)
+	})
+}
+
+func TestReloadOnFileChange(t *testing.T) {
+	previous := pkgconfig.Datadog
+	t.Cleanup(func() { pkgconfig.Datadog = previous })
+
+	previousDebounce := watchDebounce
+	watchDebounce = 100 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = previousDebounce })
+
+	confPath := filepath.Join(t.TempDir(), "datadog.yaml")
+	writeConfig(t, confPath, "hostname: first")
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.NewAgentParamsWithoutSecrets(confPath, config.WithWatchFiles(true))),
+		config.Module,
+		log.MockModule,
+		fx.Provide(func(cfg config.Component) *observer { return &observer{config: cfg} }),
+		fx.Provide(func(o *observer) Provider { return NewProvider(o) }),
+		Module,
+	), func(r Component, cfg config.Component, o *observer) {
+		// rapid writes are coalesced in a single reload
+		writeConfig(t, confPath, "hostname: second")
+		writeConfig(t, confPath, "hostname: third")
+		writeConfig(t, confPath, "hostname: fourth")
+
+		require.Eventually(t, func() bool { return o.reloads() > 0 }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(3 * watchDebounce)
+
+		o.Lock()
+		defer o.Unlock()
+		assert.Equal(t, []error{nil}, o.errors)
+		assert.Equal(t, []string{"fourth"}, o.hostname)
+	})
+}
+
+func TestNoReloadOnFileChangeByDefault(t *testing.T) {
+	previous := pkgconfig.Datadog
+	t.Cleanup(func() { pkgconfig.Datadog = previous })
+
+	previousDebounce := watchDebounce
+	watchDebounce = 10 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = previousDebounce })
+
+	confPath := filepath.Join(t.TempDir(), "datadog.yaml")
+	writeConfig(t, confPath, "hostname: first")
+
+	fxutil.Test(t, fx.Options(
+		fx.Supply(config.NewAgentParamsWithoutSecrets(confPath)),
+		config.Module,
+		log.MockModule,
+		fx.Provide(func(cfg config.Component) *observer { return &observer{config: cfg} }),
+		fx.Provide(func(o *observer) Provider { return NewProvider(o) }),
+		Module,
+	), func(r Component, cfg config.Component, o *observer) {
+		writeConfig(t, confPath, "hostname: second")
+		time.Sleep(10 * watchDebounce)
+
+		assert.Zero(t, o.reloads())
+		assert.Equal(t, "first", cfg.GetString("hostname"))
+	})
+}
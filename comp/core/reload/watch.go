@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package reload
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is the time without changes to the watched files after which
+// the configuration is reloaded, so that the writes of a single update
+// trigger a single reload.  Overridden in tests.
+var watchDebounce = time.Second
+
+// fileWatcher reloads the configuration when its files change.
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// watchFiles starts reloading the configuration when any of the given files
+// changes.  The directories of the files are watched, rather than the files
+// themselves, to keep watching them when they are replaced, as most editors
+// do.
+func (r *reload) watchFiles(files []string) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		file = filepath.Clean(file)
+		names[file] = struct{}{}
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	w := &fileWatcher{watcher: watcher, done: make(chan struct{})}
+	go r.runWatcher(w, names)
+	return w, nil
+}
+
+func (r *reload) runWatcher(w *fileWatcher, names map[string]struct{}) {
+	defer close(w.done)
+
+	var debounce *time.Timer
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if _, found := names[filepath.Clean(event.Name)]; !found || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.AfterFunc(watchDebounce, func() {
+					r.log.Info("Configuration files changed, reloading the configuration")
+					_ = r.Reload()
+				})
+			} else {
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.log.Warnf("Error watching the configuration files, changes might not be reloaded: %v", err)
+		}
+	}
+}
+
+// stop stops watching the files.
+func (w *fileWatcher) stop() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package secrets implements a component to resolve secret handles, such as
+// `ENC[api_key]`, to their value.
+//
+// Secrets are fetched from a Backend, which defaults to executing the
+// `secret_backend_command` (see pkg/secrets).  Another backend can be used by
+// providing a Backend in the app.  Resolved secrets are cached in memory until
+// the cache is cleared.
+//
+// The mock component resolves secrets from an in-memory map, set with
+// Mock#SetSecret.
+package secrets
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// Resolve returns the value of the given secret.  The reference is either
+	// a bare handle (`api_key`) or an encrypted value (`ENC[api_key]`).
+	Resolve(ref string) (string, error)
+
+	// ClearCache forgets all the resolved secrets, so that they are fetched
+	// again from the backend on the next call to Resolve.
+	ClearCache()
+}
+
+// Mock implements mock-specific methods.
+type Mock interface {
+	Component
+
+	// SetSecret sets the value returned by the mock for the given handle.
+	SetSecret(handle string, value string)
+}
+
+// Backend fetches the value of secrets.
+type Backend interface {
+	// FetchSecrets returns the value of each of the given handles.  It must
+	// return an error if any of them cannot be fetched.
+	FetchSecrets(handles []string) (map[string]string, error)
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newSecrets),
+)
+
+// MockModule defines the fx options for the mock component.
+var MockModule = fxutil.Component(
+	fx.Provide(newMock),
+)
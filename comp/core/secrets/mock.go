@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package secrets
+
+import (
+	"fmt"
+	"sync"
+)
+
+// mock implements the Mock component.
+type mock struct {
+	sync.Mutex
+	secrets map[string]string
+}
+
+func newMock() Component {
+	return &mock{secrets: make(map[string]string)}
+}
+
+// Resolve implements Component#Resolve.
+func (m *mock) Resolve(ref string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	handle := parseRef(ref)
+	if value, found := m.secrets[handle]; found {
+		return value, nil
+	}
+	return "", fmt.Errorf("could not resolve secret '%s': not set in the mock", handle)
+}
+
+// ClearCache implements Component#ClearCache.
+func (m *mock) ClearCache() {}
+
+// SetSecret implements Mock#SetSecret.
+func (m *mock) SetSecret(handle string, value string) {
+	m.Lock()
+	defer m.Unlock()
+	m.secrets[handle] = value
+}
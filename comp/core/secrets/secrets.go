@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package secrets
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/fx"
+
+	pkgsecrets "github.com/DataDog/datadog-agent/pkg/secrets"
+)
+
+// origin is the origin reported to pkg/secrets for the secrets fetched by
+// this component.
+const origin = "secrets component"
+
+type dependencies struct {
+	fx.In
+
+	// Backend is optional, and defaults to commandBackend.
+	Backend Backend `optional:"true"`
+}
+
+// commandBackend fetches secrets by executing the `secret_backend_command`.
+type commandBackend struct{}
+
+// FetchSecrets implements Backend#FetchSecrets.
+func (commandBackend) FetchSecrets(handles []string) (map[string]string, error) {
+	return pkgsecrets.FetchSecrets(handles, origin)
+}
+
+// secrets implements the Component.
+type secrets struct {
+	// Mutex is held while fetching a secret, so that concurrent resolutions
+	// of the same handle execute the backend once.
+	sync.Mutex
+
+	backend Backend
+	cache   map[string]string
+}
+
+func newSecrets(deps dependencies) Component {
+	backend := deps.Backend
+	if backend == nil {
+		backend = commandBackend{}
+	}
+	return &secrets{
+		backend: backend,
+		cache:   make(map[string]string),
+	}
+}
+
+// Resolve implements Component#Resolve.
+func (s *secrets) Resolve(ref string) (string, error) {
+	handle := parseRef(ref)
+	if handle == "" {
+		return "", fmt.Errorf("invalid secret reference '%s'", ref)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if value, found := s.cache[handle]; found {
+		return value, nil
+	}
+
+	values, err := s.backend.FetchSecrets([]string{handle})
+	if err != nil {
+		return "", fmt.Errorf("could not resolve secret '%s': %w", handle, err)
+	}
+	value, found := values[handle]
+	if !found {
+		return "", fmt.Errorf("could not resolve secret '%s': not returned by the backend", handle)
+	}
+
+	s.cache[handle] = value
+	return value, nil
+}
+
+// ClearCache implements Component#ClearCache.
+func (s *secrets) ClearCache() {
+	s.Lock()
+	defer s.Unlock()
+	s.cache = make(map[string]string)
+}
+
+// parseRef returns the handle of a secret reference, removing the `ENC[..]`
+// wrapper if present.
+func parseRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "ENC[") && strings.HasSuffix(ref, "]") {
+		ref = ref[len("ENC[") : len(ref)-1]
+	}
+	return ref
+}
@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// fakeBackend serves secrets from a map, and counts how often it is called.
+type fakeBackend struct {
+	secrets map[string]string
+	err     error
+	calls   int
+}
+
+func (b *fakeBackend) FetchSecrets(handles []string) (map[string]string, error) {
+	b.calls++
+	if b.err != nil {
+		return nil, b.err
+	}
+	res := map[string]string{}
+	for _, h := range handles {
+		if v, ok := b.secrets[h]; ok {
+			res[h] = v
+		}
+	}
+	return res, nil
+}
+
+func withBackend(backend *fakeBackend) fx.Option {
+	return fx.Options(
+		fx.Supply(fx.Annotate(backend, fx.As(new(Backend)))),
+		Module,
+	)
+}
+
+func TestResolveCacheMiss(t *testing.T) {
+	backend := &fakeBackend{secrets: map[string]string{"api_key": "s3cr3t"}}
+	fxutil.Test(t, withBackend(backend), func(s Component) {
+		value, err := s.Resolve("ENC[api_key]")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", value)
+		assert.Equal(t, 1, backend.calls)
+	})
+}
+
+func TestResolveCacheHit(t *testing.T) {
+	backend := &fakeBackend{secrets: map[string]string{"api_key": "s3cr3t"}}
+	fxutil.Test(t, withBackend(backend), func(s Component) {
+		_, err := s.Resolve("ENC[api_key]")
+		require.NoError(t, err)
+
+		// both forms of the reference hit the cache
+		for _, ref := range []string{"ENC[api_key]", "api_key"} {
+			value, err := s.Resolve(ref)
+			require.NoError(t, err)
+			assert.Equal(t, "s3cr3t", value)
+		}
+		assert.Equal(t, 1, backend.calls)
+
+		// the cached value is kept even if the secret is rotated...
+		backend.secrets["api_key"] = "n3w"
+		value, _ := s.Resolve("api_key")
+		assert.Equal(t, "s3cr3t", value)
+
+		// ...until the cache is cleared
+		s.ClearCache()
+		value, err = s.Resolve("api_key")
+		require.NoError(t, err)
+		assert.Equal(t, "n3w", value)
+		assert.Equal(t, 2, backend.calls)
+	})
+}
+
+func TestResolveFailure(t *testing.T) {
+	backend := &fakeBackend{err: errors.New("backend exploded")}
+	fxutil.Test(t, withBackend(backend), func(s Component) {
+		_, err := s.Resolve("ENC[api_key]")
+		require.EqualError(t, err, "could not resolve secret 'api_key': backend exploded")
+
+		// failures are not cached
+		backend.err = nil
+		_, err = s.Resolve("ENC[api_key]")
+		require.EqualError(t, err, "could not resolve secret 'api_key': not returned by the backend")
+		assert.Equal(t, 2, backend.calls)
+
+		_, err = s.Resolve("ENC[]")
+		require.Error(t, err)
+		assert.Equal(t, 2, backend.calls)
+	})
+}
+
+func TestMock(t *testing.T) {
+	fxutil.Test(t, MockModule, func(s Component) {
+		_, err := s.Resolve("ENC[api_key]")
+		require.Error(t, err)
+
+		s.(Mock).SetSecret("api_key", "s3cr3t")
+		value, err := s.Resolve("ENC[api_key]")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", value)
+	})
+}
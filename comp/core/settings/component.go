@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package settings implements a component to hold runtime-mutable settings,
+// such as the log level or profiling toggles.
+//
+// Unlike the static configuration, settings can be changed while the agent
+// is running, typically through the agent API.  Each setting is registered
+// with a name, a type, a default value and an optional validator, and other
+// components can be notified when a setting changes.
+package settings
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// Register registers a new setting.  It returns an error if a setting
+	// with the same name already exists, or if the default value does not
+	// match the type of the setting or is rejected by its validator.
+	Register(setting Setting) error
+
+	// Get returns the current value of a setting.
+	Get(name string) (interface{}, error)
+
+	// GetString returns the current value of a string setting.
+	GetString(name string) (string, error)
+
+	// GetBool returns the current value of a bool setting.
+	GetBool(name string) (bool, error)
+
+	// GetInt returns the current value of an int setting.
+	GetInt(name string) (int, error)
+
+	// GetFloat64 returns the current value of a float64 setting.
+	GetFloat64(name string) (float64, error)
+
+	// GetDuration returns the current value of a duration setting.
+	GetDuration(name string) (time.Duration, error)
+
+	// Set changes the value of a setting.  The value must either have the
+	// type of the setting, or be a string that can be parsed to that type
+	// (as received from the API or the CLI).  The converted value is checked
+	// by the validator of the setting before being stored, and subscribers
+	// are notified if it changed.
+	Set(name string, value interface{}) error
+
+	// OnChange registers a callback, called each time the value of the given
+	// setting changes.  Callbacks are called synchronously by Set, in
+	// registration order.
+	OnChange(name string, cb ChangeCallback) error
+
+	// List returns all the registered settings, sorted by name.
+	List() []Setting
+}
+
+// Type is the type of a setting's value.
+type Type string
+
+const (
+	// StringType settings hold a string.
+	StringType Type = "string"
+	// BoolType settings hold a bool.
+	BoolType Type = "bool"
+	// IntType settings hold an int.
+	IntType Type = "int"
+	// Float64Type settings hold a float64.
+	Float64Type Type = "float64"
+	// DurationType settings hold a time.Duration.
+	DurationType Type = "duration"
+)
+
+// Setting describes a runtime setting.
+type Setting struct {
+	// Name is the unique name of the setting.
+	Name string
+	// Description is a human-readable description of the setting.
+	Description string
+	// Type is the type of the setting's value.
+	Type Type
+	// Default is the initial value of the setting.  It must have the Go type
+	// corresponding to Type.
+	Default interface{}
+	// Validator, if not nil, is called with each new value (already
+	// converted to Type) and rejects it by returning an error.
+	Validator func(value interface{}) error
+}
+
+// ChangeCallback is called when the value of a setting changes.
+type ChangeCallback func(name string, oldValue, newValue interface{})
+
+// ErrNotFound is returned (wrapped) when accessing a setting that is not
+// registered.
+var ErrNotFound = errors.New("setting not found")
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newSettings),
+)
@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// registeredSetting is a setting along with its current value and subscribers.
+type registeredSetting struct {
+	Setting
+	value     interface{}
+	callbacks []ChangeCallback
+}
+
+// settings implements the Component.
+type settings struct {
+	sync.RWMutex
+	settings map[string]*registeredSetting
+}
+
+func newSettings() Component {
+	return &settings{
+		settings: make(map[string]*registeredSetting),
+	}
+}
+
+// Register implements Component#Register.
+func (s *settings) Register(setting Setting) error {
+	if setting.Name == "" {
+		return fmt.Errorf("a setting must have a name")
+	}
+
+	value, err := convert(setting.Type, setting.Default)
+	if err != nil {
+		return fmt.Errorf("invalid default value for setting %q: %w", setting.Name, err)
+	}
+	if setting.Validator != nil {
+		if err := setting.Validator(value); err != nil {
+			return fmt.Errorf("invalid default value for setting %q: %w", setting.Name, err)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.settings[setting.Name]; found {
+		return fmt.Errorf("setting %q is already registered", setting.Name)
+	}
+	s.settings[setting.Name] = &registeredSetting{
+		Setting: setting,
+		value:   value,
+	}
+	return nil
+}
+
+// Get implements Component#Get.
+func (s *settings) Get(name string) (interface{}, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	setting, found := s.settings[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return setting.value, nil
+}
+
+// getTyped returns the value of a setting after checking its type.
+func (s *settings) getTyped(name string, typ Type) (interface{}, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	setting, found := s.settings[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	if setting.Type != typ {
+		return nil, fmt.Errorf("setting %q has type %s, not %s", name, setting.Type, typ)
+	}
+	return setting.value, nil
+}
+
+// GetString implements Component#GetString.
+func (s *settings) GetString(name string) (string, error) {
+	v, err := s.getTyped(name, StringType)
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+// GetBool implements Component#GetBool.
+func (s *settings) GetBool(name string) (bool, error) {
+	v, err := s.getTyped(name, BoolType)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// GetInt implements Component#GetInt.
+func (s *settings) GetInt(name string) (int, error) {
+	v, err := s.getTyped(name, IntType)
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// GetFloat64 implements Component#GetFloat64.
+func (s *settings) GetFloat64(name string) (float64, error) {
+	v, err := s.getTyped(name, Float64Type)
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+// GetDuration implements Component#GetDuration.
+func (s *settings) GetDuration(name string) (time.Duration, error) {
+	v, err := s.getTyped(name, DurationType)
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+// Set implements Component#Set.
+func (s *settings) Set(name string, value interface{}) error {
+	s.RLock()
+	setting, found := s.settings[name]
+	s.RUnlock()
+	if !found {
+		return fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+
+	// the type and the validator of a setting don't change once it's
+	// registered, so the value is validated without holding the lock, as the
+	// validator may access the settings.
+	newValue, err := convert(setting.Type, value)
+	if err != nil {
+		return fmt.Errorf("invalid value for setting %q: %w", name, err)
+	}
+	if setting.Validator != nil {
+		if err := setting.Validator(newValue); err != nil {
+			return fmt.Errorf("invalid value for setting %q: %w", name, err)
+		}
+	}
+
+	s.Lock()
+	oldValue := setting.value
+	setting.value = newValue
+	callbacks := append([]ChangeCallback{}, setting.callbacks...)
+
+	// callbacks are called without holding the lock, so that they can access
+	// the settings.
+	s.Unlock()
+
+	if oldValue != newValue {
+		for _, cb := range callbacks {
+			cb(name, oldValue, newValue)
+		}
+	}
+	return nil
+}
+
+// OnChange implements Component#OnChange.
+func (s *settings) OnChange(name string, cb ChangeCallback) error {
+	s.Lock()
+	defer s.Unlock()
+
+	setting, found := s.settings[name]
+	if !found {
+		return fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	setting.callbacks = append(setting.callbacks, cb)
+	return nil
+}
+
+// List implements Component#List.
+func (s *settings) List() []Setting {
+	s.RLock()
+	defer s.RUnlock()
+
+	list := make([]Setting, 0, len(s.settings))
+	for _, setting := range s.settings {
+		list = append(list, setting.Setting)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// convert converts a value to the Go type corresponding to typ.  Strings are
+// parsed, to support values coming from the API or the CLI.
+func convert(typ Type, value interface{}) (interface{}, error) {
+	str, isString := value.(string)
+
+	switch typ {
+	case StringType:
+		if isString {
+			return str, nil
+		}
+	case BoolType:
+		if isString {
+			return strconv.ParseBool(str)
+		}
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case IntType:
+		if isString {
+			return strconv.Atoi(str)
+		}
+		if i, ok := value.(int); ok {
+			return i, nil
+		}
+	case Float64Type:
+		if isString {
+			return strconv.ParseFloat(str, 64)
+		}
+		switch f := value.(type) {
+		case float64:
+			return f, nil
+		case int:
+			return float64(f), nil
+		}
+	case DurationType:
+		if isString {
+			return time.ParseDuration(str)
+		}
+		if d, ok := value.(time.Duration); ok {
+			return d, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown setting type %q", typ)
+	}
+
+	return nil, fmt.Errorf("cannot use %v (%T) as a %s", value, value, typ)
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func logLevelValidator(v interface{}) error {
+	switch v.(string) {
+	case "trace", "debug", "info", "warn", "error", "critical", "off":
+		return nil
+	}
+	return errors.New("unknown log level")
+}
+
+func TestRegister(t *testing.T) {
+	fxutil.Test(t, Module, func(s Component) {
+		require.NoError(t, s.Register(Setting{
+			Name:      "log_level",
+			Type:      StringType,
+			Default:   "info",
+			Validator: logLevelValidator,
+		}))
+		require.NoError(t, s.Register(Setting{Name: "profiling", Type: BoolType, Default: false}))
+
+		level, err := s.GetString("log_level")
+		require.NoError(t, err)
+		assert.Equal(t, "info", level)
+
+		profiling, err := s.GetBool("profiling")
+		require.NoError(t, err)
+		assert.False(t, profiling)
+
+		assert.Equal(t, []string{"log_level", "profiling"}, []string{s.List()[0].Name, s.List()[1].Name})
+
+		// duplicates, bad defaults and unknown settings are rejected
+		assert.Error(t, s.Register(Setting{Name: "profiling", Type: BoolType, Default: true}))
+		assert.Error(t, s.Register(Setting{Name: "rate", Type: IntType, Default: "not an int"}))
+		assert.Error(t, s.Register(Setting{Name: "level", Type: StringType, Default: "verbose", Validator: logLevelValidator}))
+		_, err = s.Get("unknown")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		// typed getters check the type of the setting
+		_, err = s.GetInt("profiling")
+		assert.Error(t, err)
+	})
+}
+
+func TestSet(t *testing.T) {
+	fxutil.Test(t, Module, func(s Component) {
+		require.NoError(t, s.Register(Setting{Name: "log_level", Type: StringType, Default: "info", Validator: logLevelValidator}))
+		require.NoError(t, s.Register(Setting{Name: "rate", Type: IntType, Default: 0}))
+		require.NoError(t, s.Register(Setting{Name: "period", Type: DurationType, Default: time.Second}))
+
+		require.NoError(t, s.Set("log_level", "debug"))
+		level, err := s.GetString("log_level")
+		require.NoError(t, err)
+		assert.Equal(t, "debug", level)
+
+		// strings are parsed to the type of the setting
+		require.NoError(t, s.Set("rate", "12"))
+		rate, err := s.GetInt("rate")
+		require.NoError(t, err)
+		assert.Equal(t, 12, rate)
+
+		require.NoError(t, s.Set("period", "1m"))
+		period, err := s.GetDuration("period")
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, period)
+
+		// invalid values are rejected, and the previous value is kept
+		assert.Error(t, s.Set("log_level", "verbose"))
+		assert.Error(t, s.Set("rate", "twelve"))
+		assert.Error(t, s.Set("rate", true))
+		assert.True(t, errors.Is(s.Set("unknown", 1), ErrNotFound))
+
+		level, _ = s.GetString("log_level")
+		assert.Equal(t, "debug", level)
+		rate, _ = s.GetInt("rate")
+		assert.Equal(t, 12, rate)
+	})
+}
+
+func TestSetValidatorReadingSettings(t *testing.T) {
+	fxutil.Test(t, Module, func(s Component) {
+		require.NoError(t, s.Register(Setting{Name: "max_rate", Type: IntType, Default: 10}))
+		require.NoError(t, s.Register(Setting{
+			Name:    "rate",
+			Type:    IntType,
+			Default: 0,
+			Validator: func(v interface{}) error {
+				maxRate, err := s.GetInt("max_rate")
+				if err != nil {
+					return err
+				}
+				if v.(int) > maxRate {
+					return errors.New("rate above max_rate")
+				}
+				return nil
+			},
+		}))
+
+		require.NoError(t, s.Set("rate", 5))
+		assert.Error(t, s.Set("rate", 20))
+
+		rate, err := s.GetInt("rate")
+		require.NoError(t, err)
+		assert.Equal(t, 5, rate)
+	})
+}
+
+func TestOnChange(t *testing.T) {
+	fxutil.Test(t, Module, func(s Component) {
+		require.NoError(t, s.Register(Setting{Name: "log_level", Type: StringType, Default: "info", Validator: logLevelValidator}))
+
+		type change struct{ old, new interface{} }
+		var changes []change
+		require.NoError(t, s.OnChange("log_level", func(name string, oldValue, newValue interface{}) {
+			assert.Equal(t, "log_level", name)
+			changes = append(changes, change{oldValue, newValue})
+		}))
+
+		require.NoError(t, s.Set("log_level", "debug"))
+		require.NoError(t, s.Set("log_level", "debug")) // unchanged, no notification
+		assert.Error(t, s.Set("log_level", "verbose"))  // invalid, no notification
+		require.NoError(t, s.Set("log_level", "warn"))
+
+		assert.Equal(t, []change{{"info", "debug"}, {"debug", "warn"}}, changes)
+
+		assert.Error(t, s.OnChange("unknown", func(string, interface{}, interface{}) {}))
+	})
+}
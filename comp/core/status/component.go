@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package status implements a component to render the status of the agent.
+//
+// Components contribute a named section to the status by providing a
+// StatusProvider (see NewProvider).  The component renders all sections,
+// sorted by name, either as text or as JSON.
+package status
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// GetStatus renders the status of all the registered sections in the
+	// given format.
+	GetStatus(format Format) ([]byte, error)
+
+	// Sections returns the names of the registered sections, sorted.
+	Sections() []string
+}
+
+// Format is a format in which the status can be rendered.
+type Format string
+
+const (
+	// TextFormat renders the status as human-readable text.
+	TextFormat Format = "text"
+	// JSONFormat renders the status as a JSON object, with one key per
+	// section.
+	JSONFormat Format = "json"
+)
+
+// StatusProvider provides a section of the status.
+type StatusProvider interface {
+	// Name returns the name of the section.  It must be unique.
+	Name() string
+
+	// Text returns the content of the section in the text status.
+	Text() (string, error)
+
+	// JSON returns the content of the section in the JSON status.  It must
+	// be serializable with encoding/json.
+	JSON() (interface{}, error)
+}
+
+// Provider is provided by other components to register a section of the
+// status.
+type Provider struct {
+	fx.Out
+
+	Provider StatusProvider `group:"status"`
+}
+
+// NewProvider returns a new Provider registering the given section.
+func NewProvider(provider StatusProvider) Provider {
+	return Provider{
+		Provider: provider,
+	}
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newStatus),
+)
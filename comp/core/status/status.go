@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// componentName is the name of this component in fxutil.DisabledComponents.
+const componentName = "comp/core/status"
+
+type dependencies struct {
+	fx.In
+
+	Providers []StatusProvider          `group:"status"`
+	Disabled  fxutil.DisabledComponents `optional:"true"`
+}
+
+// status implements the Component.
+type status struct {
+	// providers are sorted by name
+	providers []StatusProvider
+}
+
+func newStatus(deps dependencies) (Component, error) {
+	// when disabled, the status is empty
+	if deps.Disabled.IsDisabled(componentName) {
+		return &status{}, nil
+	}
+
+	providers := make([]StatusProvider, 0, len(deps.Providers))
+	seen := map[string]struct{}{}
+	for _, p := range deps.Providers {
+		// components may provide a nil StatusProvider when they have no status
+		if p == nil {
+			continue
+		}
+		if _, found := seen[p.Name()]; found {
+			return nil, fmt.Errorf("status section '%s' is registered more than once", p.Name())
+		}
+		seen[p.Name()] = struct{}{}
+		providers = append(providers, p)
+	}
+	sort.SliceStable(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+
+	return &status{providers: providers}, nil
+}
+
+// GetStatus implements Component#GetStatus.
+func (s *status) GetStatus(format Format) ([]byte, error) {
+	switch format {
+	case TextFormat:
+		return s.text(), nil
+	case JSONFormat:
+		return s.json()
+	default:
+		return nil, fmt.Errorf("unknown status format '%s'", format)
+	}
+}
+
+// Sections implements Component#Sections.
+func (s *status) Sections() []string {
+	names := make([]string, 0, len(s.providers))
+	for _, p := range s.providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// text renders the text status.  A section failing to render shows the error
+// instead of its content, without failing the whole status.
+func (s *status) text() []byte {
+	var b bytes.Buffer
+	for _, p := range s.providers {
+		name := p.Name()
+		fmt.Fprintf(&b, "%s\n%s\n", name, strings.Repeat("=", len(name)))
+
+		content, err := p.Text()
+		if err != nil {
+			content = fmt.Sprintf("  Error: %v", err)
+		}
+		b.WriteString(strings.TrimRight(content, "\n"))
+		b.WriteString("\n\n")
+	}
+	return b.Bytes()
+}
+
+// json renders the JSON status.  A section failing to render contains an
+// `error` key instead of its content.
+func (s *status) json() ([]byte, error) {
+	sections := make(map[string]interface{}, len(s.providers))
+	for _, p := range s.providers {
+		content, err := p.JSON()
+		if err != nil {
+			content = map[string]string{"error": err.Error()}
+		}
+		sections[p.Name()] = content
+	}
+	return json.Marshal(sections)
+}
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+type testProvider struct {
+	name string
+	text string
+	json interface{}
+	err  error
+}
+
+func (p testProvider) Name() string { return p.name }
+
+func (p testProvider) Text() (string, error) { return p.text, p.err }
+
+func (p testProvider) JSON() (interface{}, error) { return p.json, p.err }
+
+func withProviders(providers ...StatusProvider) fx.Option {
+	opts := []fx.Option{Module}
+	for _, p := range providers {
+		p := p
+		opts = append(opts, fx.Provide(func() Provider { return NewProvider(p) }))
+	}
+	return fx.Options(opts...)
+}
+
+func TestStatus(t *testing.T) {
+	fxutil.Test(t, withProviders(
+		// registered out of order, to check the sections are sorted
+		testProvider{name: "Forwarder", text: "  Transactions: 3\n", json: map[string]int{"transactions": 3}},
+		testProvider{name: "Aggregator", text: "  Series flushed: 12", json: map[string]int{"series": 12}},
+	), func(s Component) {
+		assert.Equal(t, []string{"Aggregator", "Forwarder"}, s.Sections())
+
+		text, err := s.GetStatus(TextFormat)
+		require.NoError(t, err)
+		assert.Equal(t, "Aggregator\n==========\n  Series flushed: 12\n\nForwarder\n=========\n  Transactions: 3\n\n", string(text))
+
+		js, err := s.GetStatus(JSONFormat)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"Aggregator": {"series": 12}, "Forwarder": {"transactions": 3}}`, string(js))
+
+		_, err = s.GetStatus("yaml")
+		assert.Error(t, err)
+	})
+}
+
+func TestStatusSectionError(t *testing.T) {
+	fxutil.Test(t, withProviders(
+		testProvider{name: "Broken", err: errors.New("not ready")},
+	), func(s Component) {
+		text, err := s.GetStatus(TextFormat)
+		require.NoError(t, err)
+		assert.Equal(t, "Broken\n======\n  Error: not ready\n\n", string(text))
+
+		js, err := s.GetStatus(JSONFormat)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"Broken": {"error": "not ready"}}`, string(js))
+	})
+}
+
+func TestStatusDuplicateSection(t *testing.T) {
+	app := fx.New(
+		withProviders(testProvider{name: "Dup"}, testProvider{name: "Dup"}),
+		fx.Invoke(func(Component) {}),
+	)
+	require.Error(t, app.Err())
+	assert.Contains(t, app.Err().Error(), "status section 'Dup' is registered more than once")
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package telemetry implements a component to register internal metrics
+// (counters, gauges and histograms) about the agent.
+//
+// This component temporarily wraps pkg/telemetry, and metrics are exposed
+// through the same Prometheus registry as that package.  As that registry is
+// global, creating a metric with the kind, subsystem and name of a metric
+// already created by any instance of the component returns that metric, and
+// panics if it was created with other tags or buckets.
+//
+// The mock component keeps metrics in memory and records each registration, so
+// that tests can assert on the metrics and their values.
+package telemetry
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+// team: agent-shared-components
+
+// Component is the component type.
+type Component interface {
+	// NewCounter creates a counter with the given tag (label) names.
+	NewCounter(subsystem, name string, tags []string, help string) telemetry.Counter
+
+	// NewGauge creates a gauge with the given tag (label) names.
+	NewGauge(subsystem, name string, tags []string, help string) telemetry.Gauge
+
+	// NewHistogram creates a histogram with the given tag (label) names and
+	// bucket boundaries.
+	NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) telemetry.Histogram
+}
+
+// Mock implements mock-specific methods.
+type Mock interface {
+	Component
+
+	// Registrations returns the metrics registered with the component, in
+	// registration order.
+	Registrations() []Registration
+
+	// Value returns the current value of a counter or a gauge for the given
+	// tag values.  It returns 0 for unknown metrics or tag values.
+	Value(subsystem, name string, tagsValue ...string) float64
+
+	// Observations returns the values observed by a histogram for the given
+	// tag values.
+	Observations(subsystem, name string, tagsValue ...string) []float64
+}
+
+// MetricKind is the kind of a registered metric.
+type MetricKind string
+
+const (
+	// CounterKind is the kind of metrics created with NewCounter.
+	CounterKind MetricKind = "counter"
+	// GaugeKind is the kind of metrics created with NewGauge.
+	GaugeKind MetricKind = "gauge"
+	// HistogramKind is the kind of metrics created with NewHistogram.
+	HistogramKind MetricKind = "histogram"
+)
+
+// Registration describes a metric registered with the mock component.
+type Registration struct {
+	Kind      MetricKind
+	Subsystem string
+	Name      string
+	Tags      []string
+	Help      string
+	Buckets   []float64
+}
+
+// Module defines the fx options for this component.
+var Module = fxutil.Component(
+	fx.Provide(newTelemetry),
+)
+
+// MockModule defines the fx options for the mock component.
+var MockModule = fxutil.Component(
+	fx.Provide(newMock),
+)
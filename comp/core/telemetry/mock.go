@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+// mock implements the Mock component, keeping all metrics in memory.
+type mock struct {
+	sync.Mutex
+
+	registrations []Registration
+	metrics       map[string]*mockMetric
+}
+
+func newMock() Component {
+	return &mock{
+		metrics: make(map[string]*mockMetric),
+	}
+}
+
+func metricKey(subsystem, name string) string {
+	return subsystem + "." + name
+}
+
+func (m *mock) register(reg Registration) *mockMetric {
+	m.Lock()
+	defer m.Unlock()
+
+	m.registrations = append(m.registrations, reg)
+	metric := &mockMetric{
+		mock:         m,
+		tags:         reg.Tags,
+		values:       make(map[string]float64),
+		observations: make(map[string][]float64),
+	}
+	m.metrics[metricKey(reg.Subsystem, reg.Name)] = metric
+	return metric
+}
+
+// NewCounter implements Component#NewCounter.
+func (m *mock) NewCounter(subsystem, name string, tags []string, help string) telemetry.Counter {
+	return m.register(Registration{Kind: CounterKind, Subsystem: subsystem, Name: name, Tags: tags, Help: help})
+}
+
+// NewGauge implements Component#NewGauge.
+func (m *mock) NewGauge(subsystem, name string, tags []string, help string) telemetry.Gauge {
+	return m.register(Registration{Kind: GaugeKind, Subsystem: subsystem, Name: name, Tags: tags, Help: help})
+}
+
+// NewHistogram implements Component#NewHistogram.
+func (m *mock) NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) telemetry.Histogram {
+	return m.register(Registration{Kind: HistogramKind, Subsystem: subsystem, Name: name, Tags: tags, Help: help, Buckets: buckets})
+}
+
+// Registrations implements Mock#Registrations.
+func (m *mock) Registrations() []Registration {
+	m.Lock()
+	defer m.Unlock()
+
+	return append([]Registration{}, m.registrations...)
+}
+
+// Value implements Mock#Value.
+func (m *mock) Value(subsystem, name string, tagsValue ...string) float64 {
+	m.Lock()
+	defer m.Unlock()
+
+	metric, found := m.metrics[metricKey(subsystem, name)]
+	if !found {
+		return 0
+	}
+	return metric.values[valuesKey(tagsValue)]
+}
+
+// Observations implements Mock#Observations.
+func (m *mock) Observations(subsystem, name string, tagsValue ...string) []float64 {
+	m.Lock()
+	defer m.Unlock()
+
+	metric, found := m.metrics[metricKey(subsystem, name)]
+	if !found {
+		return nil
+	}
+	return append([]float64{}, metric.observations[valuesKey(tagsValue)]...)
+}
+
+func valuesKey(tagsValue []string) string {
+	return strings.Join(tagsValue, "\x00")
+}
+
+// mockMetric implements telemetry.Counter, telemetry.Gauge and
+// telemetry.Histogram in memory.  All accesses are protected by the mock's
+// lock.
+type mockMetric struct {
+	mock *mock
+	tags []string
+
+	values       map[string]float64
+	observations map[string][]float64
+}
+
+// tagsValues returns the tag values ordered like the tag names of the metric.
+func (mm *mockMetric) tagsValues(tags map[string]string) []string {
+	values := make([]string, 0, len(mm.tags))
+	for _, tag := range mm.tags {
+		values = append(values, tags[tag])
+	}
+	return values
+}
+
+func (mm *mockMetric) update(tagsValue []string, fn func(float64) float64) {
+	mm.mock.Lock()
+	defer mm.mock.Unlock()
+
+	key := valuesKey(tagsValue)
+	mm.values[key] = fn(mm.values[key])
+}
+
+func (mm *mockMetric) Initialize(tagsValue ...string) {
+	mm.update(tagsValue, func(v float64) float64 { return v })
+}
+
+func (mm *mockMetric) Inc(tagsValue ...string) {
+	mm.Add(1, tagsValue...)
+}
+
+func (mm *mockMetric) Dec(tagsValue ...string) {
+	mm.Add(-1, tagsValue...)
+}
+
+func (mm *mockMetric) Add(value float64, tagsValue ...string) {
+	mm.update(tagsValue, func(v float64) float64 { return v + value })
+}
+
+func (mm *mockMetric) Sub(value float64, tagsValue ...string) {
+	mm.Add(-value, tagsValue...)
+}
+
+func (mm *mockMetric) Set(value float64, tagsValue ...string) {
+	mm.update(tagsValue, func(float64) float64 { return value })
+}
+
+func (mm *mockMetric) Observe(value float64, tagsValue ...string) {
+	mm.mock.Lock()
+	defer mm.mock.Unlock()
+
+	key := valuesKey(tagsValue)
+	mm.observations[key] = append(mm.observations[key], value)
+}
+
+func (mm *mockMetric) Delete(tagsValue ...string) {
+	mm.mock.Lock()
+	defer mm.mock.Unlock()
+
+	key := valuesKey(tagsValue)
+	delete(mm.values, key)
+	delete(mm.observations, key)
+}
+
+func (mm *mockMetric) IncWithTags(tags map[string]string) {
+	mm.Inc(mm.tagsValues(tags)...)
+}
+
+func (mm *mockMetric) AddWithTags(value float64, tags map[string]string) {
+	mm.Add(value, mm.tagsValues(tags)...)
+}
+
+func (mm *mockMetric) DeleteWithTags(tags map[string]string) {
+	mm.Delete(mm.tagsValues(tags)...)
+}
+
+func (mm *mockMetric) WithValues(tagsValue ...string) telemetry.SimpleCounter {
+	return &mockSimpleCounter{metric: mm, tagsValue: tagsValue}
+}
+
+func (mm *mockMetric) WithTags(tags map[string]string) telemetry.SimpleCounter {
+	return mm.WithValues(mm.tagsValues(tags)...)
+}
+
+// mockSimpleCounter implements telemetry.SimpleCounter for fixed tag values.
+type mockSimpleCounter struct {
+	metric    *mockMetric
+	tagsValue []string
+}
+
+func (c *mockSimpleCounter) Inc() {
+	c.metric.Inc(c.tagsValue...)
+}
+
+func (c *mockSimpleCounter) Add(value float64) {
+	c.metric.Add(value, c.tagsValue...)
+}
@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+// tel implements the Component.
+type tel struct {
+	// this component is currently implementing a thin wrapper around
+	// pkg/telemetry, and uses the global registry in that package.
+}
+
+// registered holds the metrics registered by every instance of the component,
+// by kind, subsystem and name, as the global registry of pkg/telemetry panics
+// when a metric is registered twice, e.g. when the component is built again.
+var (
+	registeredMut sync.Mutex
+	registered    = make(map[string]registeredMetric)
+)
+
+// registeredMetric is a metric in registered, with the tags and buckets it was
+// created with.
+type registeredMetric struct {
+	metric  interface{}
+	tags    []string
+	buckets []float64
+}
+
+// register returns the metric of the given kind already registered with the
+// subsystem and name, or the one created with newMetric otherwise. It panics
+// if the metric already registered has other tags or buckets, as it could not
+// be used as the new one.
+func register(kind MetricKind, subsystem, name string, tags []string, buckets []float64, newMetric func() interface{}) interface{} {
+	registeredMut.Lock()
+	defer registeredMut.Unlock()
+
+	key := string(kind) + ":" + metricKey(subsystem, name)
+	if r, found := registered[key]; found {
+		if !slices.Equal(r.tags, tags) || !slices.Equal(r.buckets, buckets) {
+			panic(fmt.Sprintf("%s %s is already registered with tags %v and buckets %v, not %v and %v",
+				kind, metricKey(subsystem, name), r.tags, r.buckets, tags, buckets))
+		}
+		return r.metric
+	}
+	metric := newMetric()
+	registered[key] = registeredMetric{
+		metric:  metric,
+		tags:    slices.Clone(tags),
+		buckets: slices.Clone(buckets),
+	}
+	return metric
+}
+
+func newTelemetry() Component {
+	return &tel{}
+}
+
+// NewCounter implements Component#NewCounter.
+func (*tel) NewCounter(subsystem, name string, tags []string, help string) telemetry.Counter {
+	return register(CounterKind, subsystem, name, tags, nil, func() interface{} {
+		return telemetry.NewCounter(subsystem, name, tags, help)
+	}).(telemetry.Counter)
+}
+
+// NewGauge implements Component#NewGauge.
+func (*tel) NewGauge(subsystem, name string, tags []string, help string) telemetry.Gauge {
+	return register(GaugeKind, subsystem, name, tags, nil, func() interface{} {
+		return telemetry.NewGauge(subsystem, name, tags, help)
+	}).(telemetry.Gauge)
+}
+
+// NewHistogram implements Component#NewHistogram.
+func (*tel) NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) telemetry.Histogram {
+	return register(HistogramKind, subsystem, name, tags, buckets, func() interface{} {
+		return telemetry.NewHistogram(subsystem, name, tags, help, buckets)
+	}).(telemetry.Histogram)
+}
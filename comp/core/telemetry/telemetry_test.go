@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestCounter(t *testing.T) {
+	fxutil.Test(t, Module, func(tel Component) {
+		counter := tel.NewCounter("comp_telemetry_test", "requests", []string{"status"}, "number of requests")
+		counter.Inc("ok")
+		counter.Add(2, "ok")
+		counter.Inc("error")
+
+		rec := httptest.NewRecorder()
+		telemetry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/telemetry", nil))
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), `comp_telemetry_test__requests{status="ok"} 3`)
+		assert.Contains(t, string(body), `comp_telemetry_test__requests{status="error"} 1`)
+	})
+}
+
+func TestCounterRegisteredTwice(t *testing.T) {
+	newCounter := func() (counter telemetry.Counter) {
+		fxutil.Test(t, Module, func(tel Component) {
+			counter = tel.NewCounter("comp_telemetry_test", "restarts", nil, "number of restarts")
+		})
+		return counter
+	}
+
+	first := newCounter()
+	second := newCounter()
+	second.Inc()
+	assert.Same(t, first, second)
+}
+
+func TestMetricRegisteredTwiceWithOtherTagsOrBuckets(t *testing.T) {
+	fxutil.Test(t, Module, func(tel Component) {
+		tel.NewCounter("comp_telemetry_test", "conflicts", []string{"status"}, "number of conflicts")
+		assert.Panics(t, func() {
+			tel.NewCounter("comp_telemetry_test", "conflicts", []string{"method"}, "number of conflicts")
+		})
+
+		tel.NewHistogram("comp_telemetry_test", "latency", nil, "latency", []float64{1, 10})
+		assert.Panics(t, func() {
+			tel.NewHistogram("comp_telemetry_test", "latency", nil, "latency", []float64{1, 100})
+		})
+		assert.NotPanics(t, func() {
+			tel.NewHistogram("comp_telemetry_test", "latency", nil, "latency", []float64{1, 10})
+		})
+	})
+}
+
+func TestMockCounter(t *testing.T) {
+	fxutil.Test(t, MockModule, func(tel Component) {
+		mock := tel.(Mock)
+
+		counter := tel.NewCounter("subsystem", "requests", []string{"status", "method"}, "number of requests")
+		counter.Inc("ok", "GET")
+		counter.AddWithTags(2, map[string]string{"method": "GET", "status": "ok"})
+		counter.WithValues("error", "POST").Inc()
+
+		assert.Equal(t, 3.0, mock.Value("subsystem", "requests", "ok", "GET"))
+		assert.Equal(t, 1.0, mock.Value("subsystem", "requests", "error", "POST"))
+		assert.Equal(t, 0.0, mock.Value("subsystem", "requests", "error", "GET"))
+
+		counter.Delete("ok", "GET")
+		assert.Equal(t, 0.0, mock.Value("subsystem", "requests", "ok", "GET"))
+
+		assert.Equal(t, []Registration{{
+			Kind:      CounterKind,
+			Subsystem: "subsystem",
+			Name:      "requests",
+			Tags:      []string{"status", "method"},
+			Help:      "number of requests",
+		}}, mock.Registrations())
+	})
+}
+
+func TestMockGaugeAndHistogram(t *testing.T) {
+	fxutil.Test(t, fx.Options(MockModule), func(tel Component) {
+		mock := tel.(Mock)
+
+		gauge := tel.NewGauge("subsystem", "queue", []string{"name"}, "queue size")
+		gauge.Set(10, "q")
+		gauge.Dec("q")
+		assert.Equal(t, 9.0, mock.Value("subsystem", "queue", "q"))
+
+		histogram := tel.NewHistogram("subsystem", "duration", nil, "duration", []float64{1, 10})
+		histogram.Observe(0.5)
+		histogram.Observe(5)
+		assert.Equal(t, []float64{0.5, 5}, mock.Observations("subsystem", "duration"))
+
+		require.Len(t, mock.Registrations(), 2)
+		assert.Equal(t, GaugeKind, mock.Registrations()[0].Kind)
+		assert.Equal(t, HistogramKind, mock.Registrations()[1].Kind)
+	})
+}
@@ -96,6 +96,7 @@ func requestHealth(log log.Component, config config.Component) error {
 
 	sort.Strings(s.Unhealthy)
 	sort.Strings(s.Healthy)
+	sort.Strings(s.Degraded)
 
 	statusString := color.GreenString("PASS")
 	if len(s.Unhealthy) > 0 {
@@ -107,11 +108,27 @@ func requestHealth(log log.Component, config config.Component) error {
 		fmt.Fprintln(color.Output, fmt.Sprintf("=== %s healthy components ===", color.GreenString(strconv.Itoa(len(s.Healthy)))))
 		fmt.Fprintln(color.Output, strings.Join(s.Healthy, ", "))
 	}
+	if len(s.Degraded) > 0 {
+		fmt.Fprintln(color.Output, fmt.Sprintf("=== %s degraded components ===", color.YellowString(strconv.Itoa(len(s.Degraded)))))
+		fmt.Fprintln(color.Output, strings.Join(s.Degraded, ", "))
+		printReasons(s.Degraded, s.Reasons)
+	}
 	if len(s.Unhealthy) > 0 {
 		fmt.Fprintln(color.Output, fmt.Sprintf("=== %s unhealthy components ===", color.RedString(strconv.Itoa(len(s.Unhealthy)))))
 		fmt.Fprintln(color.Output, strings.Join(s.Unhealthy, ", "))
+		printReasons(s.Unhealthy, s.Reasons)
 		return fmt.Errorf("found %d unhealthy components", len(s.Unhealthy))
 	}
 
 	return nil
 }
+
+// printReasons prints why the components are unhealthy, for the ones that
+// gave a reason
+func printReasons(names []string, reasons map[string]string) {
+	for _, name := range names {
+		if reason, found := reasons[name]; found {
+			fmt.Fprintln(color.Output, fmt.Sprintf("  %s: %s", name, reason))
+		}
+	}
+}
@@ -23,6 +23,11 @@ var /* const */ (
 
 type processor struct {
 	queue chan *model.SBOMEntity
+
+	// sentBOMs are the serial numbers of the last SBOM sent for each image, so
+	// that the events of an image keeping its SBOM, like the heartbeats of
+	// the unchanged rescans, don't send it again
+	sentBOMs map[string]string
 }
 
 func newProcessor(sender aggregator.Sender, maxNbItem int, maxRetentionTime time.Duration) *processor {
@@ -36,6 +41,7 @@ func newProcessor(sender aggregator.Sender, maxNbItem int, maxRetentionTime time
 				},
 			})
 		}),
+		sentBOMs: make(map[string]string),
 	}
 }
 
@@ -45,11 +51,20 @@ func (p *processor) processEvents(evBundle workloadmeta.EventBundle) {
 	log.Tracef("Processing %d events", len(evBundle.Events))
 
 	for _, event := range evBundle.Events {
-		p.processSBOM(event.Entity.(*workloadmeta.ContainerImageMetadata))
+		img := event.Entity.(*workloadmeta.ContainerImageMetadata)
+		if img.CycloneDXBOM != nil && img.CycloneDXBOM.SerialNumber != "" && p.sentBOMs[img.ID] == img.CycloneDXBOM.SerialNumber {
+			log.Tracef("SBOM of image %s already sent, skipping it", img.ID)
+			continue
+		}
+
+		p.processSBOM(img)
 	}
 }
 
 func (p *processor) processRefresh(allImages []*workloadmeta.ContainerImageMetadata) {
+	// Forgets the images that were deleted
+	p.sentBOMs = make(map[string]string, len(allImages))
+
 	// So far, the check is refreshing all the images every 5 minutes all together.
 	for _, img := range allImages {
 		p.processSBOM(img)
@@ -61,6 +76,8 @@ func (p *processor) processSBOM(img *workloadmeta.ContainerImageMetadata) {
 		return
 	}
 
+	p.sentBOMs[img.ID] = img.CycloneDXBOM.SerialNumber
+
 	p.queue <- &model.SBOMEntity{
 		Type:        model.SBOMSourceType_CONTAINER_IMAGE_LAYERS,
 		Id:          img.ID,
@@ -17,6 +17,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/util/pointer"
 	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -147,3 +148,48 @@ func TestProcessEvents(t *testing.T) {
 
 	p.stop()
 }
+
+func TestProcessEventsSkipsSentSBOMs(t *testing.T) {
+	queue := make(chan *model.SBOMEntity, 10)
+	p := &processor{
+		queue:    queue,
+		sentBOMs: make(map[string]string),
+	}
+
+	newImage := func(serialNumber string, scannedAt time.Time) *workloadmeta.ContainerImageMetadata {
+		return &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   "sha256:1",
+			},
+			CycloneDXBOM: &cyclonedx.BOM{
+				SpecVersion:  cyclonedx.SpecVersion1_4,
+				SerialNumber: serialNumber,
+			},
+			SBOMScannedAt: scannedAt,
+		}
+	}
+
+	processEvent := func(img *workloadmeta.ContainerImageMetadata) {
+		p.processEvents(workloadmeta.EventBundle{
+			Events: []workloadmeta.Event{{Type: workloadmeta.EventTypeSet, Entity: img}},
+			Ch:     make(chan struct{}),
+		})
+	}
+
+	scannedAt := time.Now()
+	processEvent(newImage("urn:uuid:1", scannedAt))
+	assert.Len(t, queue, 1)
+
+	// The heartbeat of an unchanged rescan doesn't send the SBOM again
+	processEvent(newImage("urn:uuid:1", scannedAt.Add(time.Hour)))
+	assert.Len(t, queue, 1)
+
+	// A new SBOM is sent
+	processEvent(newImage("urn:uuid:2", scannedAt.Add(2*time.Hour)))
+	assert.Len(t, queue, 2)
+
+	// The refreshes send all the SBOMs
+	p.processRefresh([]*workloadmeta.ContainerImageMetadata{newImage("urn:uuid:2", scannedAt.Add(2*time.Hour))})
+	assert.Len(t, queue, 3)
+}
@@ -389,6 +389,14 @@ func InitConfig(config Config) {
 
 	// Yaml keys which values are stripped from flare
 	config.BindEnvAndSetDefault("flare_stripped_keys", []string{})
+	// Size limits applied to the content of a flare: files bigger than flare_max_file_size are truncated and data is
+	// dropped once flare_max_total_size is reached. A value of 0 disables the limit.
+	config.BindEnvAndSetDefault("flare_max_file_size", "20Mb")
+	config.BindEnvAndSetDefault("flare_max_total_size", "100Mb")
+
+	// Duration after which a liveness check registered with the health component is considered stale if it did not
+	// send a heartbeat
+	config.BindEnvAndSetDefault("health_stale_threshold", 30*time.Second)
 
 	// Agent GUI access port
 	config.BindEnvAndSetDefault("GUI_port", defaultGuiPort)
@@ -619,6 +627,20 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("containerd_namespace", []string{})
 	config.BindEnvAndSetDefault("containerd_namespaces", []string{}) // alias for containerd_namespace
 	config.BindEnvAndSetDefault("containerd_exclude_namespaces", []string{"moby"})
+	// Sandbox (a.k.a. pause) containers are ignored unless
+	// containerd_collect_sandbox_containers is set, in which case they are
+	// reported flagged as sandboxes. The patterns are regexes matching the
+	// image names of sandboxes, on top of the well-known pause images.
+	config.BindEnvAndSetDefault("containerd_collect_sandbox_containers", false)
+	config.BindEnvAndSetDefault("containerd_sandbox_image_patterns", []string{})
+	// The events received from containerd are buffered while they wait to be
+	// handled. When the buffer is full, the "drop-oldest" policy drops the
+	// oldest buffered event, and the "block" policy waits for up to
+	// containerd_event_buffer_block_timeout (integer seconds) for room before
+	// dropping the new event.
+	config.BindEnvAndSetDefault("containerd_event_buffer_size", 1000)
+	config.BindEnvAndSetDefault("containerd_event_buffer_overflow_policy", "block")
+	config.BindEnvAndSetDefault("containerd_event_buffer_block_timeout", 5)
 	config.BindEnvAndSetDefault("container_env_as_tags", map[string]string{})
 	config.BindEnvAndSetDefault("container_labels_as_tags", map[string]string{})
 
@@ -1105,11 +1127,78 @@ func InitConfig(config Config) {
 
 	// container_image_collection
 	config.BindEnvAndSetDefault("container_image_collection.metadata.enabled", false)
+	// Whether the images are reported with whether they are signed and
+	// attested, according to the cosign signatures and attestations, and the
+	// OCI referrers, pulled next to them in the runtime
+	config.BindEnvAndSetDefault("container_image_collection.signatures.enabled", false)
 	config.BindEnvAndSetDefault("container_image_collection.sbom.enabled", false)
 	config.BindEnvAndSetDefault("container_image_collection.sbom.use_mount", false)
 	config.BindEnvAndSetDefault("container_image_collection.sbom.scan_interval", 0)    // Integer seconds
 	config.BindEnvAndSetDefault("container_image_collection.sbom.scan_timeout", 10*60) // Integer seconds
+	config.BindEnvAndSetDefault("container_image_collection.sbom.scan_workers", 1)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.max_scans_per_minute", 0) // 0 means no limit
+	config.BindEnvAndSetDefault("container_image_collection.sbom.rescan_period", 0)        // Integer seconds, 0 means no rescan
+	config.BindEnvAndSetDefault("container_image_collection.sbom.retry.max_attempts", 3)   // 1 means no retry
+	config.BindEnvAndSetDefault("container_image_collection.sbom.retry.base_backoff", 30)  // Integer seconds
+	config.BindEnvAndSetDefault("container_image_collection.sbom.retry.max_backoff", 600)  // Integer seconds
+	// "os", "languages", or a subset of the languages like "python", "node" or
+	// "go". An empty list enables all the analyzers.
 	config.BindEnvAndSetDefault("container_image_collection.sbom.analyzers", []string{"os"})
+	config.BindEnvAndSetDefault("container_image_collection.sbom.export_format", "cyclonedx-json") // "cyclonedx-json" or "spdx-json"
+	config.BindEnvAndSetDefault("container_image_collection.sbom.status_events.enabled", true)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.include_repositories", []string{})
+	config.BindEnvAndSetDefault("container_image_collection.sbom.exclude_repositories", []string{})
+	config.BindEnvAndSetDefault("container_image_collection.sbom.include_labels", []string{})
+	config.BindEnvAndSetDefault("container_image_collection.sbom.exclude_labels", []string{})
+	// Types of the packages kept in the SBOMs, like "os", "gem", "pip", "npm",
+	// "go" or "java". Empty lists keep all the packages.
+	config.BindEnvAndSetDefault("container_image_collection.sbom.include_package_types", []string{})
+	config.BindEnvAndSetDefault("container_image_collection.sbom.exclude_package_types", []string{})
+	// Only scan the images used by at least one running container
+	config.BindEnvAndSetDefault("container_image_collection.sbom.used_images_only", false)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.container_scan.enabled", false)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.container_scan.max_scans_per_minute", 6) // 0 means no limit
+	config.BindEnvAndSetDefault("container_image_collection.sbom.container_scan.exclude_sandbox_containers", true)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.cache.enabled", true)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.cache.directory", filepath.Join(defaultRunPath, "sbom-cache"))
+	config.BindEnvAndSetDefault("container_image_collection.sbom.cache.ttl", 60*60*24)                // Integer seconds
+	config.BindEnvAndSetDefault("container_image_collection.sbom.cache.max_disk_size", 100*1000*1000) // Bytes
+	config.BindEnvAndSetDefault("container_image_collection.sbom.layer_cache.enabled", false)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.layer_cache.max_layers", 1000)
+	// OCI image layout directories with images to scan besides the ones of
+	// containerd. They are scanned once, when the agent starts.
+	config.BindEnvAndSetDefault("container_image_collection.sbom.oci_layout.directories", []string{})
+	// Limits of the SBOMs reported for the images and containers. The packages
+	// over the limits are dropped. 0 means no limit.
+	config.BindEnvAndSetDefault("container_image_collection.sbom.max_packages", 20000)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.max_size", 10*1000*1000) // Bytes
+	// Integer seconds given to the scans in progress to finish when the agent
+	// stops. 0 means they are cancelled right away.
+	config.BindEnvAndSetDefault("container_image_collection.sbom.shutdown_timeout", 10)
+	// Vulnerability DB of trivy. It's downloaded from the repository, a
+	// mirror of the default one of trivy when set, and updated every
+	// update_interval. In offline mode, it's never downloaded and must already
+	// be in the directory.
+	config.BindEnvAndSetDefault("container_image_collection.sbom.db.enabled", false)
+	config.BindEnvAndSetDefault("container_image_collection.sbom.db.directory", filepath.Join(defaultRunPath, "trivy-db"))
+	config.BindEnvAndSetDefault("container_image_collection.sbom.db.repository", "")
+	config.BindEnvAndSetDefault("container_image_collection.sbom.db.update_interval", 60*60*24) // Integer seconds, 0 means no update after the first download
+	config.BindEnvAndSetDefault("container_image_collection.sbom.db.offline", false)
+
+	// Workload metadata
+	// Sources of the entities, from the highest to the lowest priority, e.g.
+	// ["node_orchestrator", "runtime"]. When several sources set the same
+	// field of an entity to different values, the value of the source with
+	// the highest priority is kept. The sources not listed come after the
+	// other ones, and the most recently updated source wins between the
+	// sources of the same priority.
+	config.BindEnvAndSetDefault("workloadmeta.source_priority", []string{})
+	// Integer seconds after which the sources of an entity that didn't
+	// refresh it, e.g. as their collector died, are removed from the store.
+	// Only the entities that their collectors set again on every pull expire,
+	// the ones only updated on events, e.g. of containerd, never do. 0 means
+	// no expiry.
+	config.BindEnvAndSetDefault("workloadmeta.entity_ttl", 0)
 
 	// Datadog security agent (common)
 	config.BindEnvAndSetDefault("security_agent.cmd_port", 5010)
@@ -1369,6 +1458,12 @@ func LoadWithoutSecret() (*Warnings, error) {
 	return load(Datadog, "datadog.yaml", false)
 }
 
+// LoadCustom reads the config files of the given config and initializes it, like Load and LoadWithoutSecret do for
+// Datadog
+func LoadCustom(config Config, origin string, loadSecret bool) (*Warnings, error) {
+	return load(config, origin, loadSecret)
+}
+
 func findUnknownKeys(config Config) []string {
 	var unknownKeys []string
 	knownKeys := config.GetKnownKeys()
@@ -91,7 +91,44 @@ type Config interface {
 	// These have had the EnvPrefix applied, as well as the EnvKeyReplacer.
 	GetEnvVars() []string
 
+	// GetDefaults returns the default value of every key with a default, as
+	// given to SetDefault.  Keys are lower-cased, as in viper.
+	GetDefaults() map[string]interface{}
+
+	// GetOverrides returns the value of every key given to Set.  Keys are
+	// lower-cased, as in viper.
+	GetOverrides() map[string]interface{}
+
 	// IsSectionSet checks if a given section is set by checking if any of
 	// its subkeys is set.
 	IsSectionSet(section string) bool
+
+	// GetSource returns where the effective value of a key comes from, in
+	// the order of precedence of viper.
+	GetSource(key string) Source
+
+	// CopyConfig replaces all the settings of the config with the ones of
+	// the given config, built with NewConfig, at once.  The readers of the
+	// config see either all the previous settings or all the new ones.
+	CopyConfig(cfg Config)
 }
+
+// Source is where the value of a config key comes from
+type Source string
+
+const (
+	// SourceOverride is for values given to Set, by the agent itself or by
+	// the command line
+	SourceOverride Source = "override"
+	// SourceFlag is for values given on the command line, by a flag bound
+	// with BindPFlag
+	SourceFlag Source = "flag"
+	// SourceEnvVar is for values taken from an environment variable
+	SourceEnvVar Source = "environment-variable"
+	// SourceFile is for values read from the configuration file
+	SourceFile Source = "file"
+	// SourceDefault is for default values
+	SourceDefault Source = "default"
+	// SourceUnknown is for keys without a value
+	SourceUnknown Source = "unknown"
+)
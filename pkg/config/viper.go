@@ -8,6 +8,7 @@ package config
 import (
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,12 +33,27 @@ type safeConfig struct {
 	// configEnvVars is the set of env vars that are consulted for
 	// configuration values.
 	configEnvVars map[string]struct{}
+
+	// defaults are the default values given to SetDefault, since viper does
+	// not expose them.
+	defaults map[string]interface{}
+
+	// envVarsByKey are the env vars bound to each key, to find out whether
+	// the value of a key comes from the environment.
+	envVarsByKey map[string][]string
+
+	// overrides are the values given to Set.
+	overrides map[string]interface{}
+
+	// flags are the flags bound to each key with BindPFlag.
+	flags map[string]*pflag.Flag
 }
 
 // Set wraps Viper for concurrent access
 func (c *safeConfig) Set(key string, value interface{}) {
 	c.Lock()
 	defer c.Unlock()
+	c.overrides[strings.ToLower(key)] = value
 	c.Viper.Set(key, value)
 }
 
@@ -45,9 +61,98 @@ func (c *safeConfig) Set(key string, value interface{}) {
 func (c *safeConfig) SetDefault(key string, value interface{}) {
 	c.Lock()
 	defer c.Unlock()
+	c.defaults[strings.ToLower(key)] = value
 	c.Viper.SetDefault(key, value)
 }
 
+// GetDefaults returns the default values given to SetDefault
+func (c *safeConfig) GetDefaults() map[string]interface{} {
+	c.RLock()
+	defer c.RUnlock()
+
+	defaults := make(map[string]interface{}, len(c.defaults))
+	for key, value := range c.defaults {
+		defaults[key] = value
+	}
+	return defaults
+}
+
+// GetOverrides returns the values given to Set
+func (c *safeConfig) GetOverrides() map[string]interface{} {
+	c.RLock()
+	defer c.RUnlock()
+
+	overrides := make(map[string]interface{}, len(c.overrides))
+	for key, value := range c.overrides {
+		overrides[key] = value
+	}
+	return overrides
+}
+
+// GetSource returns where the effective value of a key comes from
+func (c *safeConfig) GetSource(key string) Source {
+	c.RLock()
+	defer c.RUnlock()
+
+	key = strings.ToLower(key)
+	if _, found := c.overrides[key]; found {
+		return SourceOverride
+	}
+	flag, flagBound := c.flags[key]
+	if flagBound && flag.Changed {
+		return SourceFlag
+	}
+	for _, envVar := range c.envVarsByKey[key] {
+		if _, found := os.LookupEnv(envVar); found {
+			return SourceEnvVar
+		}
+	}
+	// Viper falls back to the value of an unchanged flag when nothing else
+	// gives one to the key, so only the config file itself tells whether the
+	// key is set there
+	if flagBound {
+		if c.Viper.InConfig(key) {
+			return SourceFile
+		}
+		return SourceDefault
+	}
+	// Besides overrides, flags and env vars, only the config file gives values
+	// to keys without their default
+	if c.Viper.GetSkipDefault(key) != nil {
+		return SourceFile
+	}
+	if _, found := c.defaults[key]; found {
+		return SourceDefault
+	}
+	return SourceUnknown
+}
+
+// CopyConfig replaces the settings of the config with the ones of cfg, under
+// the lock
+func (c *safeConfig) CopyConfig(cfg Config) {
+	other, ok := cfg.(*safeConfig)
+	if !ok {
+		panic(fmt.Sprintf("cannot copy a config of type %T", cfg))
+	}
+	if other == c {
+		return
+	}
+
+	other.RLock()
+	defer other.RUnlock()
+	c.Lock()
+	defer c.Unlock()
+
+	c.Viper = other.Viper
+	c.envPrefix = other.envPrefix
+	c.envKeyReplacer = other.envKeyReplacer
+	c.configEnvVars = other.configEnvVars
+	c.defaults = other.defaults
+	c.envVarsByKey = other.envVarsByKey
+	c.overrides = other.overrides
+	c.flags = other.flags
+}
+
 // SetKnown adds a key to the set of known valid config keys
 func (c *safeConfig) SetKnown(key string) {
 	c.Lock()
@@ -316,12 +421,14 @@ func (c *safeConfig) BindEnv(input ...string) {
 		envKeys = input[1:]
 	}
 
+	configKey := strings.ToLower(input[0])
 	for _, key := range envKeys {
 		// apply EnvKeyReplacer to each key
 		if c.envKeyReplacer != nil {
 			key = c.envKeyReplacer.Replace(key)
 		}
 		c.configEnvVars[key] = struct{}{}
+		c.envVarsByKey[configKey] = append(c.envVarsByKey[configKey], key)
 	}
 
 	_ = c.Viper.BindEnv(input...)
@@ -444,6 +551,9 @@ func (c *safeConfig) ConfigFileUsed() string {
 func (c *safeConfig) BindPFlag(key string, flag *pflag.Flag) error {
 	c.Lock()
 	defer c.Unlock()
+	if flag != nil {
+		c.flags[strings.ToLower(key)] = flag
+	}
 	return c.Viper.BindPFlag(key, flag)
 }
 
@@ -467,6 +577,10 @@ func NewConfig(name string, envPrefix string, envKeyReplacer *strings.Replacer)
 	config := safeConfig{
 		Viper:         viper.New(),
 		configEnvVars: map[string]struct{}{},
+		defaults:      map[string]interface{}{},
+		envVarsByKey:  map[string][]string{},
+		overrides:     map[string]interface{}{},
+		flags:         map[string]*pflag.Flag{},
 	}
 	config.SetConfigName(name)
 	config.SetEnvPrefix(envPrefix)
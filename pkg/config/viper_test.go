@@ -12,7 +12,9 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConcurrencySetGet(t *testing.T) {
@@ -190,3 +192,95 @@ test:
 	res = config.IsSectionSet("yetanothertest")
 	assert.Equal(t, false, res)
 }
+
+func TestGetDefaults(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("Foo", "bar")
+	config.BindEnvAndSetDefault("section.value", 12)
+	config.BindEnv("no_default")
+
+	// setting a value does not change the default
+	config.Set("foo", "baz")
+
+	assert.Equal(t, map[string]interface{}{
+		"foo":           "bar",
+		"section.value": 12,
+	}, config.GetDefaults())
+}
+
+func TestGetOverrides(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("foo", "bar")
+	config.Set("Foo", "baz")
+	config.Set("section.value", 12)
+
+	assert.Equal(t, map[string]interface{}{
+		"foo":           "baz",
+		"section.value": 12,
+	}, config.GetOverrides())
+}
+
+func TestGetSource(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("from_default", "value")
+	config.BindEnvAndSetDefault("section.from_file", "value")
+	config.BindEnvAndSetDefault("from_env", "value")
+	config.BindEnvAndSetDefault("from_override", "value")
+	config.BindEnv("custom_env", "MY_CUSTOM_ENV")
+	config.BindEnvAndSetDefault("from_flag", "value")
+	config.BindEnvAndSetDefault("unchanged_flag", "value")
+	config.BindEnvAndSetDefault("unchanged_flag_in_file", "value")
+	config.SetConfigType("yaml")
+
+	yamlExample := []byte(`
+section:
+  from_file: file
+from_env: file
+unchanged_flag_in_file: file
+`)
+	config.ReadConfig(bytes.NewBuffer(yamlExample))
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("from_flag", "value", "")
+	fs.String("unchanged_flag", "value", "")
+	fs.String("unchanged_flag_in_file", "value", "")
+	require.NoError(t, fs.Parse([]string{"--from_flag=flag"}))
+	for _, name := range []string{"from_flag", "unchanged_flag", "unchanged_flag_in_file"} {
+		require.NoError(t, config.BindPFlag(name, fs.Lookup(name)))
+	}
+
+	t.Setenv("DD_FROM_ENV", "env")
+	t.Setenv("MY_CUSTOM_ENV", "env")
+	config.Set("from_override", "override")
+
+	assert.Equal(t, SourceDefault, config.GetSource("from_default"))
+	assert.Equal(t, SourceFile, config.GetSource("section.from_file"))
+	assert.Equal(t, SourceEnvVar, config.GetSource("from_env"))
+	assert.Equal(t, SourceEnvVar, config.GetSource("custom_env"))
+	assert.Equal(t, SourceOverride, config.GetSource("From_Override"))
+	assert.Equal(t, SourceFlag, config.GetSource("from_flag"))
+	assert.Equal(t, SourceDefault, config.GetSource("unchanged_flag"))
+	assert.Equal(t, SourceFile, config.GetSource("unchanged_flag_in_file"))
+	assert.Equal(t, SourceUnknown, config.GetSource("unknown"))
+}
+
+func TestCopyConfig(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("foo", "default")
+	config.Set("bar", "override")
+
+	other := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	other.BindEnvAndSetDefault("foo", "other default")
+	other.BindEnvAndSetDefault("baz", "value")
+
+	config.CopyConfig(other)
+	assert.Equal(t, "other default", config.GetString("foo"))
+	assert.Equal(t, "value", config.GetString("baz"))
+	// the settings of the config are all replaced
+	assert.False(t, config.IsSet("bar"))
+	assert.Equal(t, SourceDefault, config.GetSource("baz"))
+
+	// copying the config into itself has no effect
+	config.CopyConfig(config)
+	assert.Equal(t, "value", config.GetString("baz"))
+}
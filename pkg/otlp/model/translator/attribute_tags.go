@@ -0,0 +1,78 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/DataDog/datadog-agent/pkg/otlp/model/internal/utils"
+)
+
+// withPointAttributes returns the dimensions of a point with the given
+// attributes, see WithFlattenedAttributes.
+func (t *Translator) withPointAttributes(dims *Dimensions, attrs pcommon.Map) *Dimensions {
+	if t.cfg.attributesFlatteningDepth == 0 {
+		pointDims := dims.WithAttributeMap(attrs)
+		// the tags of the attributes come first, the others are shared
+		t.truncateTags(pointDims.tags[:len(pointDims.tags)-len(dims.tags)])
+		return pointDims
+	}
+
+	tags := make([]string, 0, attrs.Len())
+	attrs.Range(func(key string, value pcommon.Value) bool {
+		tags = t.appendFlattenedTags(tags, key, value, 0)
+		return true
+	})
+	t.truncateTags(tags)
+	return dims.AddTags(tags...)
+}
+
+// appendFlattenedTags appends the tags of the value of the attribute with the
+// given key, nested at the given depth in arrays and maps.
+func (t *Translator) appendFlattenedTags(tags []string, key string, value pcommon.Value, depth int) []string {
+	switch value.Type() {
+	case pcommon.ValueTypeSlice, pcommon.ValueTypeMap:
+		if depth >= t.cfg.attributesFlatteningDepth {
+			t.logger.Debug("Skipping attribute nested too deeply",
+				zap.String("attribute", key),
+				zap.Int("max depth", t.cfg.attributesFlatteningDepth),
+			)
+			return tags
+		}
+	case pcommon.ValueTypeBytes:
+		t.logger.Debug("Skipping attribute of unsupported type",
+			zap.String("attribute", key),
+			zap.Stringer("type", value.Type()),
+		)
+		return tags
+	}
+
+	switch value.Type() {
+	case pcommon.ValueTypeSlice:
+		s := value.Slice()
+		for i := 0; i < s.Len(); i++ {
+			tags = t.appendFlattenedTags(tags, key, s.At(i), depth+1)
+		}
+	case pcommon.ValueTypeMap:
+		value.Map().Range(func(k string, v pcommon.Value) bool {
+			tags = t.appendFlattenedTags(tags, key+"."+k, v, depth+1)
+			return true
+		})
+	default:
+		tags = append(tags, utils.FormatKeyValueTag(key, value.AsString()))
+	}
+	return tags
+}
@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestAttributes(t *testing.T) pcommon.Map {
+	attrs := pcommon.NewMap()
+	require.NoError(t, attrs.FromRaw(map[string]interface{}{
+		"scalar": "value",
+		"array":  []interface{}{"v1", 2},
+		"map": map[string]interface{}{
+			"key":   "v",
+			"array": []interface{}{"v3"},
+			"nested": map[string]interface{}{
+				"key": "deep",
+			},
+		},
+	}))
+	return attrs
+}
+
+func TestPointAttributesNotFlattened(t *testing.T) {
+	tr := newTranslator(t, zap.NewNop())
+
+	dims := tr.withPointAttributes(newDims("test.metric"), newTestAttributes(t))
+	assert.ElementsMatch(t, []string{
+		"scalar:value",
+		`array:["v1",2]`,
+		`map:{"array":["v3"],"key":"v","nested":{"key":"deep"}}`,
+	}, dims.Tags())
+}
+
+func TestPointAttributesFlattened(t *testing.T) {
+	tr := newTranslator(t, zap.NewNop(), WithFlattenedAttributes(2))
+
+	dims := tr.withPointAttributes(newDims("test.metric").AddTags("base:tag"), newTestAttributes(t))
+	assert.ElementsMatch(t, []string{
+		"base:tag",
+		"scalar:value",
+		"array:v1",
+		"array:2",
+		"map.key:v",
+		"map.array:v3",
+		"map.nested.key:deep",
+	}, dims.Tags())
+}
+
+func TestPointAttributesOverDepth(t *testing.T) {
+	core, observed := observer.New(zapcore.DebugLevel)
+	tr := newTranslator(t, zap.New(core), WithFlattenedAttributes(1))
+
+	attrs := newTestAttributes(t)
+	attrs.PutEmptyBytes("bytes").FromRaw([]byte("raw"))
+
+	dims := tr.withPointAttributes(newDims("test.metric"), attrs)
+	assert.ElementsMatch(t, []string{
+		"scalar:value",
+		"array:v1",
+		"array:2",
+		"map.key:v",
+	}, dims.Tags())
+
+	skipped := observed.FilterMessage("Skipping attribute nested too deeply").All()
+	require.Len(t, skipped, 2)
+	var keys []string
+	for _, entry := range skipped {
+		keys = append(keys, entry.ContextMap()["attribute"].(string))
+	}
+	assert.ElementsMatch(t, []string{"map.array", "map.nested"}, keys)
+	assert.Equal(t, 1, observed.FilterMessage("Skipping attribute of unsupported type").Len())
+
+	_, err := New(zap.NewNop(), WithFlattenedAttributes(0))
+	assert.Error(t, err)
+}
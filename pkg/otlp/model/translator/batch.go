@@ -0,0 +1,40 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// batchIDKey is the context key of the batch ID, see BatchIDFromContext.
+type batchIDKey struct{}
+
+// lastBatchID is the ID of the last batch of metrics mapped, by any Translator.
+var lastBatchID uint64
+
+// newBatchContext returns a copy of ctx carrying a new batch ID.
+func newBatchContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchIDKey{}, atomic.AddUint64(&lastBatchID, 1))
+}
+
+// BatchIDFromContext returns the ID of the batch of metrics being mapped, from
+// the context passed to the consumer methods. All the calls made while mapping
+// the same metrics, e.g. during one call to MapMetrics, share the same ID,
+// which is unique to these metrics. This lets consumers group them.
+func BatchIDFromContext(ctx context.Context) (uint64, bool) {
+	id, ok := ctx.Value(batchIDKey{}).(uint64)
+	return id, ok
+}
@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+// batchIDConsumer records the batch IDs of the contexts it's given.
+type batchIDConsumer struct {
+	mockFullConsumer
+	batchIDs []uint64
+}
+
+func (c *batchIDConsumer) record(ctx context.Context) {
+	// 0 when there's no batch ID
+	id, _ := BatchIDFromContext(ctx)
+	c.batchIDs = append(c.batchIDs, id)
+}
+
+func (c *batchIDConsumer) ConsumeTimeSeries(ctx context.Context, dimensions *Dimensions, typ MetricDataType, ts uint64, val float64) {
+	c.record(ctx)
+	c.mockFullConsumer.ConsumeTimeSeries(ctx, dimensions, typ, ts, val)
+}
+
+func (c *batchIDConsumer) ConsumeSketch(ctx context.Context, dimensions *Dimensions, ts uint64, sk *quantile.Sketch) {
+	c.record(ctx)
+	c.mockFullConsumer.ConsumeSketch(ctx, dimensions, ts, sk)
+}
+
+func createTestBatchMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metricsArray := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+
+	gauge := metricsArray.AppendEmpty()
+	gauge.SetName("test.gauge")
+	gauge.SetEmptyGauge()
+	gauge.Gauge().DataPoints().AppendEmpty().SetIntValue(1)
+	gauge.Gauge().DataPoints().AppendEmpty().SetIntValue(2)
+
+	histogram := metricsArray.AppendEmpty()
+	histogram.SetName("test.histogram")
+	histogram.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	hp := histogram.Histogram().DataPoints().AppendEmpty()
+	hp.SetCount(1)
+	hp.BucketCounts().FromRaw([]uint64{1})
+
+	return md
+}
+
+func TestBatchIDFromContext(t *testing.T) {
+	_, ok := BatchIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	tr := newTranslator(t, zap.NewNop())
+	ctx := context.Background()
+
+	first := &batchIDConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestBatchMetrics(), first))
+	second := &batchIDConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestBatchMetrics(), second))
+
+	// All the calls of one translate share the same ID
+	require.Len(t, first.batchIDs, 3)
+	require.Len(t, first.sketches, 1)
+	for _, id := range first.batchIDs {
+		assert.NotZero(t, id)
+		assert.Equal(t, first.batchIDs[0], id)
+	}
+	require.Len(t, second.batchIDs, 3)
+	for _, id := range second.batchIDs {
+		assert.Equal(t, second.batchIDs[0], id)
+	}
+
+	// And different translates have different IDs, even with the same
+	// parent context
+	assert.NotEqual(t, first.batchIDs[0], second.batchIDs[0])
+}
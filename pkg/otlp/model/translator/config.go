@@ -16,6 +16,7 @@ package translator
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/otlp/model/source"
 )
@@ -23,6 +24,9 @@ import (
 type translatorConfig struct {
 	// metrics export behavior
 	HistMode                 HistogramMode
+	HistSketchMinCount       uint64
+	HistSketchMerging        bool
+	HistSkipZeroCountBuckets bool
 	SendCountSum             bool
 	Quantiles                bool
 	SendMonotonic            bool
@@ -32,6 +36,48 @@ type translatorConfig struct {
 	// Both must not be enabled at the same time.
 	InstrumentationLibraryMetadataAsTags bool
 	InstrumentationScopeMetadataAsTags   bool
+	OriginalMetricNameAsTag              bool
+	SchemaURLAsTag                       bool
+
+	// tags of the bounds of the histogram buckets exported as counts
+	bucketLowerBoundTagKey string
+	bucketUpperBoundTagKey string
+	bucketBoundFormat      BucketBoundFormat
+	bucketBoundPrecision   int
+
+	// name of the gauge reported for every resource, if not empty
+	heartbeatMetricName string
+
+	// resource attributes reported as gauges, with the prefix of their name
+	resourceAttributeGauges      []string
+	resourceAttributeGaugePrefix string
+
+	// levels of arrays and maps flattened in the tags of the point attributes
+	attributesFlatteningDepth int
+
+	// reporting of the first point of the cumulative monotonic metrics
+	initialCumulativeValue InitialCumulativeValueMode
+
+	// metric type forced for the number metrics with these names
+	metricTypeOverrides map[string]MetricDataType
+
+	// APM stats of the resources matching any of these filters are dropped
+	apmStatsFilters []resourceAttributeFilter
+
+	// origins of the metrics, tried before the default ones
+	originRules []OriginRule
+
+	// called for every point dropped
+	dropCallback DropCallback
+
+	// points older than this are dropped, if not zero
+	maxTimestampAge time.Duration
+
+	// counts are consumed with their monotonicity by a CountConsumer
+	countMonotonicityHints bool
+
+	// only the latest point of the gauges of the same dimensions is consumed
+	gaugeCoalescing bool
 
 	// cache configuration
 	sweepInterval int64
@@ -110,6 +156,205 @@ func WithInstrumentationScopeMetadataAsTags() Option {
 	}
 }
 
+// WithOriginalMetricNameAsTag tags the metrics with the name of the OTLP metric
+// they are mapped from, as otel_metric_name:<name>, before any suffix like
+// .count or .bucket is added to it.
+// Disabled by default, as it adds a tag to every metric.
+func WithOriginalMetricNameAsTag() Option {
+	return func(t *translatorConfig) error {
+		t.OriginalMetricNameAsTag = true
+		return nil
+	}
+}
+
+// WithSchemaURLAsTag tags the metrics with the schema URL of their resource,
+// as otel_schema_url:<url>, e.g. to track the migrations between versions of
+// the semantic conventions. The resources without a schema URL add no tag.
+// Disabled by default.
+func WithSchemaURLAsTag() Option {
+	return func(t *translatorConfig) error {
+		t.SchemaURLAsTag = true
+		return nil
+	}
+}
+
+// WithAPMStatsResourceFilters drops the APM stats of the resources matching
+// any of the given expressions, of the form `<attribute> == <value>`, e.g.
+// `deployment.environment == "dev"`. The APM stats resources carry the fields
+// of the stats payloads, e.g. `dd.env`, which the attributes of the semantic
+// conventions, e.g. `deployment.environment`, match when they're not set.
+func WithAPMStatsResourceFilters(exprs ...string) Option {
+	return func(t *translatorConfig) error {
+		for _, expr := range exprs {
+			filter, err := parseResourceAttributeFilter(expr)
+			if err != nil {
+				return err
+			}
+			t.apmStatsFilters = append(t.apmStatsFilters, filter)
+		}
+		return nil
+	}
+}
+
+// DefaultHeartbeatMetricName is the default name of the heartbeat metric,
+// see WithResourceHeartbeat.
+const DefaultHeartbeatMetricName = "otel.up"
+
+// WithResourceHeartbeat reports a gauge with the given name and a value of 1
+// for every resource of the metrics mapped, tagged with the host and tags of
+// the resource, e.g. to alert when a source stops reporting.
+// Disabled by default.
+func WithResourceHeartbeat(metricName string) Option {
+	return func(t *translatorConfig) error {
+		if metricName == "" {
+			return fmt.Errorf("heartbeat metric name must not be empty")
+		}
+		t.heartbeatMetricName = metricName
+		return nil
+	}
+}
+
+// WithResourceAttributesAsGauges reports a gauge for every resource of the
+// metrics mapped and every one of the given resource attribute keys it has,
+// named after the key with the given prefix, e.g. otel.resource.process.pid
+// for the process.pid key and the otel.resource. prefix. The gauges are tagged
+// with the host and tags of the resource, and their value is the one of the
+// attribute. The attributes of other types than int and double are skipped.
+// Disabled by default.
+func WithResourceAttributesAsGauges(prefix string, keys ...string) Option {
+	return func(t *translatorConfig) error {
+		if prefix == "" {
+			return fmt.Errorf("resource attribute gauges prefix must not be empty")
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no resource attribute keys to report as gauges")
+		}
+		t.resourceAttributeGaugePrefix = prefix
+		t.resourceAttributeGauges = keys
+		return nil
+	}
+}
+
+// WithFlattenedAttributes maps the data point attributes of array and map
+// types to several tags: one tag per element for the arrays, e.g. key:v1 and
+// key:v2, and one tag per entry with a dotted key for the maps, e.g.
+// key.subkey:v. Up to maxDepth levels of nested arrays and maps are flattened,
+// the values nested more deeply are skipped, as are the byte values.
+// By default, the array and map values are mapped to a single tag with their
+// JSON representation.
+func WithFlattenedAttributes(maxDepth int) Option {
+	return func(t *translatorConfig) error {
+		if maxDepth <= 0 {
+			return fmt.Errorf("attributes flattening depth must be positive: %d", maxDepth)
+		}
+		t.attributesFlatteningDepth = maxDepth
+		return nil
+	}
+}
+
+// WithMetricTypeOverrides forces the type of the OTLP gauges and sums with
+// the given names. Gauges and cumulative sums overridden to Count are mapped
+// as cumulative monotonic sums, that is reported as the difference between
+// their consecutive values. Cumulative sums overridden to Gauge are reported
+// as their raw value. The other metrics keep the default mapping.
+func WithMetricTypeOverrides(overrides map[string]MetricDataType) Option {
+	return func(t *translatorConfig) error {
+		for name, dt := range overrides {
+			if dt != Gauge && dt != Count {
+				return fmt.Errorf("invalid metric type override for metric %q: %d", name, dt)
+			}
+		}
+		t.metricTypeOverrides = overrides
+		return nil
+	}
+}
+
+// InitialCumulativeValueMode is the reporting mode of the first point of the
+// cumulative monotonic metrics, which have no previous point to compute a
+// delta from.
+type InitialCumulativeValueMode string
+
+const (
+	// InitialCumulativeValueDrop drops the first point of the series, unless
+	// it started after the process of the Translator.
+	InitialCumulativeValueDrop InitialCumulativeValueMode = "drop"
+
+	// InitialCumulativeValueKeep reports the value of the first point of the
+	// series as the delta, to avoid a gap when the process sending it starts.
+	InitialCumulativeValueKeep InitialCumulativeValueMode = "keep"
+)
+
+// WithInitialCumulativeValue sets the reporting mode of the first point of
+// the cumulative monotonic metrics reported as counts.
+// The default mode is InitialCumulativeValueDrop.
+//
+// With InitialCumulativeValueKeep, the whole total of a series since its start
+// is counted again every time the Translator restarts, or the series expires
+// from its cache, which double counts the series that did not restart.
+func WithInitialCumulativeValue(mode InitialCumulativeValueMode) Option {
+	return func(t *translatorConfig) error {
+		switch mode {
+		case InitialCumulativeValueDrop, InitialCumulativeValueKeep:
+			t.initialCumulativeValue = mode
+		default:
+			return fmt.Errorf("unknown initial cumulative value mode: %q", mode)
+		}
+		return nil
+	}
+}
+
+// WithDropCallback sets a function called with the name of the metric and the
+// reason every time a point is dropped. The number of points dropped by reason
+// is also available in the Stats of the Translator.
+func WithDropCallback(callback DropCallback) Option {
+	return func(t *translatorConfig) error {
+		t.dropCallback = callback
+		return nil
+	}
+}
+
+// WithMaxTimestampAge drops the points of the gauges, and of the sums not
+// mapped as cumulative monotonic, whose timestamp is older than now minus
+// maxAge, e.g. the old points replayed by an exporter when it reconnects. They
+// are counted under DropReasonStale. A zero maxAge disables the check, which
+// is the default.
+func WithMaxTimestampAge(maxAge time.Duration) Option {
+	return func(t *translatorConfig) error {
+		if maxAge < 0 {
+			return fmt.Errorf("max timestamp age must not be negative: %s", maxAge)
+		}
+		t.maxTimestampAge = maxAge
+		return nil
+	}
+}
+
+// WithCountMonotonicityHints consumes the counts with a CountConsumer, when
+// the Consumer implements it, annotated with whether they are monotonic: the
+// counts of delta sums have the monotonicity of the sum, all other counts
+// (cumulative monotonic sums, histograms, summaries) are monotonic. Otherwise,
+// and by default, counts are consumed with ConsumeTimeSeries.
+func WithCountMonotonicityHints() Option {
+	return func(t *translatorConfig) error {
+		t.countMonotonicityHints = true
+		return nil
+	}
+}
+
+// WithOriginRules adds rules mapping OTLP metrics to the Origin reported to
+// an OriginConsumer. They are tried in order, before the default rules
+// mapping the languages of the OpenTelemetry SDKs.
+func WithOriginRules(rules ...OriginRule) Option {
+	return func(t *translatorConfig) error {
+		for _, rule := range rules {
+			if rule.Attribute == "" {
+				return fmt.Errorf("origin rule %+v has no attribute", rule)
+			}
+		}
+		t.originRules = append(t.originRules, rules...)
+		return nil
+	}
+}
+
 // HistogramMode is an export mode for OTLP Histogram metrics.
 type HistogramMode string
 
@@ -137,6 +382,112 @@ func WithHistogramMode(mode HistogramMode) Option {
 	}
 }
 
+// WithHistogramSketchMinCount sets the minimum number of observations of a
+// histogram point to map it to a sketch in HistogramModeDistributions mode.
+// Points with fewer observations are exported as bucket counts, like in
+// HistogramModeCounters mode, as a sketch of a handful of observations is less
+// accurate than the buckets themselves.
+// By default, every point is mapped to a sketch.
+func WithHistogramSketchMinCount(count uint64) Option {
+	return func(t *translatorConfig) error {
+		t.HistSketchMinCount = count
+		return nil
+	}
+}
+
+// WithHistogramSketchMerging merges the sketches of the histogram points with
+// the same dimensions in a call to MapMetrics, e.g. from several scopes of the
+// same resource, into a single sketch, reported with the latest timestamp of
+// the points. Only applies to the histograms mapped as distributions.
+func WithHistogramSketchMerging() Option {
+	return func(t *translatorConfig) error {
+		t.HistSketchMerging = true
+		return nil
+	}
+}
+
+// WithGaugeCoalescing keeps only the point with the latest timestamp of the
+// gauges with the same dimensions in a call to MapMetrics, e.g. when an
+// exporter sends several points of the same series in a payload. Of the points
+// with the same timestamp, the last one is kept. Applies to the gauges and the
+// sums mapped as gauges, which are consumed at the end of the call.
+func WithGaugeCoalescing() Option {
+	return func(t *translatorConfig) error {
+		t.gaugeCoalescing = true
+		return nil
+	}
+}
+
+// WithHistogramSkipZeroCountBuckets skips the buckets reported as counts
+// that are empty, e.g. in HistogramModeCounters mode, since sparse histograms
+// would otherwise produce many series of zeros. For cumulative histograms,
+// the buckets skipped are those that did not change since the previous
+// point. The count and sum of the histograms are still reported.
+func WithHistogramSkipZeroCountBuckets() Option {
+	return func(t *translatorConfig) error {
+		t.HistSkipZeroCountBuckets = true
+		return nil
+	}
+}
+
+// WithHistogramBucketBoundTagKeys sets the keys of the tags of the lower and
+// upper bounds of the histogram buckets exported as counts.
+// By default, lower_bound and upper_bound are used.
+func WithHistogramBucketBoundTagKeys(lower, upper string) Option {
+	return func(t *translatorConfig) error {
+		if lower == "" || upper == "" {
+			return fmt.Errorf("bucket bound tag keys must not be empty: %q, %q", lower, upper)
+		}
+		if lower == upper {
+			return fmt.Errorf("bucket bound tag keys must be different: %q", lower)
+		}
+		t.bucketLowerBoundTagKey = lower
+		t.bucketUpperBoundTagKey = upper
+		return nil
+	}
+}
+
+// BucketBoundFormat is a numeric format of the bounds of the histogram buckets
+// in their tags. Whatever the format, the bounds of the overflow buckets are
+// "-inf" and "inf".
+type BucketBoundFormat string
+
+const (
+	// BucketBoundFormatDefault formats the bounds like the OpenMetrics check
+	// of the Agent, e.g. 0, 1.0 or 0.25.
+	BucketBoundFormatDefault BucketBoundFormat = "default"
+	// BucketBoundFormatFixed formats the bounds without exponent, e.g. 1000000.
+	BucketBoundFormatFixed BucketBoundFormat = "fixed"
+	// BucketBoundFormatScientific formats the bounds with an exponent, e.g. 1e+06.
+	BucketBoundFormatScientific BucketBoundFormat = "scientific"
+)
+
+// WithHistogramBucketBoundFormat sets the numeric format of the bounds of the
+// histogram buckets exported as counts. The precision is the number of digits
+// after the decimal point of the fixed and scientific formats, -1 for the
+// smallest number of digits representing the bound exactly. It must be -1 with
+// the default format.
+// By default, BucketBoundFormatDefault is used.
+func WithHistogramBucketBoundFormat(format BucketBoundFormat, precision int) Option {
+	return func(t *translatorConfig) error {
+		switch format {
+		case BucketBoundFormatDefault:
+			if precision != -1 {
+				return fmt.Errorf("precision not supported with the %q bucket bound format: %d", format, precision)
+			}
+		case BucketBoundFormatFixed, BucketBoundFormatScientific:
+			if precision < -1 {
+				return fmt.Errorf("invalid bucket bound precision: %d", precision)
+			}
+		default:
+			return fmt.Errorf("unknown bucket bound format: %q", format)
+		}
+		t.bucketBoundFormat = format
+		t.bucketBoundPrecision = precision
+		return nil
+	}
+}
+
 // WithCountSumMetrics exports .count and .sum histogram metrics.
 func WithCountSumMetrics() Option {
 	return func(t *translatorConfig) error {
@@ -113,3 +113,47 @@ type TagsConsumer interface {
 	// ConsumeTag consumes a tag
 	ConsumeTag(tag string)
 }
+
+// ResourceMetadataConsumer is a resource metadata consumer.
+// It is an optional interface that can be implemented by a Consumer.
+// The metadata is a curated subset of the attributes of an OTLP resource,
+// describing where it runs (e.g. cloud provider, region, cluster name).
+// It is called once per resource having any of these attributes.
+type ResourceMetadataConsumer interface {
+	// ConsumeResourceMetadata consumes the metadata of a resource
+	ConsumeResourceMetadata(metadata map[string]string)
+}
+
+// OriginConsumer is a consumer of the origin of the metrics, see Origin.
+// It is an optional interface that can be implemented by a Consumer.
+// It is called once per metric.
+type OriginConsumer interface {
+	// ConsumeOrigin consumes the origin of a metric
+	ConsumeOrigin(product, category, service string)
+}
+
+// CountConsumer is a consumer of counts annotated with their monotonicity,
+// see WithCountMonotonicityHints.
+// It is an optional interface that can be implemented by a Consumer.
+// When it's implemented and the hints are enabled, counts are consumed with
+// it instead of ConsumeTimeSeries.
+type CountConsumer interface {
+	// ConsumeCount consumes a count, monotonic when it comes from a monotonic sum
+	ConsumeCount(ctx context.Context, dimensions *Dimensions, timestamp uint64, value float64, monotonic bool)
+}
+
+var _ Consumer = NoopConsumer{}
+
+// NoopConsumer is a Consumer dropping everything it consumes, e.g. to
+// benchmark a Translator.
+type NoopConsumer struct{}
+
+// ConsumeTimeSeries implements TimeSeriesConsumer.
+func (NoopConsumer) ConsumeTimeSeries(context.Context, *Dimensions, MetricDataType, uint64, float64) {
+}
+
+// ConsumeSketch implements SketchConsumer.
+func (NoopConsumer) ConsumeSketch(context.Context, *Dimensions, uint64, *quantile.Sketch) {}
+
+// ConsumeAPMStats implements APMStatsConsumer.
+func (NoopConsumer) ConsumeAPMStats(pb.ClientStatsPayload) {}
@@ -48,6 +48,15 @@ func (d *Dimensions) Tags() []string {
 	return d.tags
 }
 
+// SortedTags returns a sorted copy of the tags of the metric, identical for
+// the same set of tags regardless of the order they were added in.
+func (d *Dimensions) SortedTags() []string {
+	tags := make([]string, len(d.tags))
+	copy(tags, d.tags)
+	sort.Strings(tags)
+	return tags
+}
+
 // Host of the metric (may be empty).
 func (d *Dimensions) Host() string {
 	return d.host
@@ -58,9 +67,8 @@ func (d *Dimensions) OriginID() string {
 	return d.originID
 }
 
-// getTags maps an attributeMap into a slice of Datadog tags
-func getTags(labels pcommon.Map) []string {
-	tags := make([]string, 0, labels.Len())
+// appendTags maps an attributeMap into Datadog tags appended to tags
+func appendTags(tags []string, labels pcommon.Map) []string {
 	labels.Range(func(key string, value pcommon.Value) bool {
 		v := value.AsString()
 		tags = append(tags, utils.FormatKeyValueTag(key, v))
@@ -85,7 +93,28 @@ func (d *Dimensions) AddTags(tags ...string) *Dimensions {
 
 // WithAttributeMap creates a new metricDimensions struct with additional tags from attributes.
 func (d *Dimensions) WithAttributeMap(labels pcommon.Map) *Dimensions {
-	return d.AddTags(getTags(labels)...)
+	// This is called for every point, most of the time with points without
+	// attributes, the only point of their metric: the tags are read-only, so
+	// they are shared with the metric instead of copied.
+	if labels.Len() == 0 {
+		return &Dimensions{
+			name:     d.name,
+			tags:     d.tags,
+			host:     d.host,
+			originID: d.originID,
+		}
+	}
+
+	// same as AddTags, without an intermediate slice for the new tags
+	newTags := make([]string, 0, labels.Len()+len(d.tags))
+	newTags = appendTags(newTags, labels)
+	newTags = append(newTags, d.tags...)
+	return &Dimensions{
+		name:     d.name,
+		tags:     newTags,
+		host:     d.host,
+		originID: d.originID,
+	}
 }
 
 // WithSuffix creates a new dimensions struct with an extra name suffix.
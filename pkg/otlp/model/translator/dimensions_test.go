@@ -36,6 +36,23 @@ func TestWithAttributeMap(t *testing.T) {
 	)
 }
 
+func TestWithEmptyAttributeMap(t *testing.T) {
+	dims := &Dimensions{
+		name:     "example.name",
+		host:     "hostname",
+		tags:     []string{"tagOne:a"},
+		originID: "origin_id",
+	}
+
+	newDims := dims.WithAttributeMap(pcommon.NewMap())
+	assert.Equal(t, dims, newDims)
+	assert.NotSame(t, dims, newDims)
+
+	// The tags are shared, adding new ones doesn't change the original ones
+	assert.ElementsMatch(t, []string{"tagOne:a", "tagTwo:b"}, newDims.AddTags("tagTwo:b").Tags())
+	assert.ElementsMatch(t, []string{"tagOne:a"}, dims.Tags())
+}
+
 func TestMetricDimensionsString(t *testing.T) {
 	getKey := func(name string, tags []string, host string) string {
 		dims := Dimensions{name: name, tags: tags, host: host}
@@ -100,6 +117,29 @@ func TestAddTags(t *testing.T) {
 	assert.ElementsMatch(t, []string{"key:val"}, testDims.tags)
 }
 
+func TestSortedTags(t *testing.T) {
+	keys := []string{"key1", "key2", "key3"}
+	first, second := pcommon.NewMap(), pcommon.NewMap()
+	for i := range keys {
+		first.PutStr(keys[i], "val")
+		second.PutStr(keys[len(keys)-1-i], "val")
+	}
+
+	dims := &Dimensions{name: "test.metric", tags: []string{"base:tag"}}
+	firstDims := dims.WithAttributeMap(first).AddTags("added:1", "added:2")
+	secondDims := dims.WithAttributeMap(second).AddTags("added:2", "added:1")
+
+	// the tags are in the order of the attributes
+	assert.NotEqual(t, firstDims.Tags(), secondDims.Tags())
+
+	expected := []string{"added:1", "added:2", "base:tag", "key1:val", "key2:val", "key3:val"}
+	assert.Equal(t, expected, firstDims.SortedTags())
+	assert.Equal(t, expected, secondDims.SortedTags())
+
+	// the tags of the dimensions are left as is
+	assert.Equal(t, []string{"added:2", "added:1", "key3:val", "key2:val", "key1:val", "base:tag"}, secondDims.Tags())
+}
+
 func TestAllFieldsAreCopied(t *testing.T) {
 	dims := &Dimensions{
 		name:     "example.name",
@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// DropReason is the reason why the Translator drops OTLP points.
+//
+// There's no reason for a cardinality cap: the Translator doesn't cap the
+// number of series it maps. The cumulative series are kept in memory until
+// they expire after the delta TTL, see WithDeltaTTL, however many there are.
+type DropReason string
+
+const (
+	// DropReasonNaN is for the points with a NaN or infinite value,
+	// which are not supported by the backend.
+	DropReasonNaN DropReason = "nan"
+	// DropReasonFiltered is for the APM stats points filtered out,
+	// see WithAPMStatsResourceFilters.
+	DropReasonFiltered DropReason = "filtered"
+	// DropReasonEmpty is for the metrics without any point.
+	DropReasonEmpty DropReason = "empty"
+	// DropReasonNoRecordedValue is for the points flagged as having no
+	// recorded value, e.g. because their time series became stale.
+	DropReasonNoRecordedValue DropReason = "no_recorded_value"
+	// DropReasonStale is for the points older than the maximum age, see
+	// WithMaxTimestampAge.
+	DropReasonStale DropReason = "stale"
+	// DropReasonDuplicateTimestamp is for the points mapped as the diff of
+	// cumulative values, i.e. of cumulative monotonic sums, histograms and
+	// summaries, with the same timestamp as the previous point of their
+	// series. The previous point is kept.
+	DropReasonDuplicateTimestamp DropReason = "duplicate_timestamp"
+)
+
+var dropReasons = []DropReason{DropReasonNaN, DropReasonFiltered, DropReasonEmpty, DropReasonNoRecordedValue, DropReasonStale, DropReasonDuplicateTimestamp}
+
+// DropCallback is called with the name of the metric and the reason every
+// time the Translator drops a point.
+type DropCallback func(metricName string, reason DropReason)
+
+// Stats are statistics about the points mapped by a Translator.
+type Stats struct {
+	// DroppedPoints is the number of points dropped since the creation of
+	// the Translator, by reason.
+	DroppedPoints map[DropReason]uint64
+	// TruncatedTags is the number of tags truncated to MaxTagLength since
+	// the creation of the Translator.
+	TruncatedTags uint64
+}
+
+// dropCounters counts the dropped points by reason. The map itself is never
+// modified after its creation, only the counters, atomically.
+type dropCounters map[DropReason]*uint64
+
+func newDropCounters() dropCounters {
+	counters := make(dropCounters, len(dropReasons))
+	for _, reason := range dropReasons {
+		counters[reason] = new(uint64)
+	}
+	return counters
+}
+
+// Stats returns statistics about the points mapped by the Translator.
+func (t *Translator) Stats() Stats {
+	stats := Stats{
+		DroppedPoints: make(map[DropReason]uint64, len(t.drops)),
+		TruncatedTags: atomic.LoadUint64(&t.truncatedTags),
+	}
+	for reason, count := range t.drops {
+		stats.DroppedPoints[reason] = atomic.LoadUint64(count)
+	}
+	return stats
+}
+
+// drop records that n points of the metric with the given name are dropped.
+func (t *Translator) drop(name string, reason DropReason, n int) {
+	atomic.AddUint64(t.drops[reason], uint64(n))
+	if t.cfg.dropCallback != nil {
+		for i := 0; i < n; i++ {
+			t.cfg.dropCallback(name, reason)
+		}
+	}
+}
+
+// dropNoRecordedValue drops the point with the given flags if it has no
+// recorded value, and returns whether it did.
+func (t *Translator) dropNoRecordedValue(name string, flags pmetric.DataPointFlags) bool {
+	if !flags.NoRecordedValue() {
+		return false
+	}
+	t.drop(name, DropReasonNoRecordedValue, 1)
+	return true
+}
+
+// dataPointsLen returns the number of points of the metric.
+func dataPointsLen(md pmetric.Metric) int {
+	switch md.Type() {
+	case pmetric.MetricTypeGauge:
+		return md.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		return md.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		return md.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		return md.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		return md.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+// dropResourceMetrics records that all the points of the resource are dropped.
+func (t *Translator) dropResourceMetrics(rm pmetric.ResourceMetrics, reason DropReason) {
+	ilms := rm.ScopeMetrics()
+	for i := 0; i < ilms.Len(); i++ {
+		metricsArray := ilms.At(i).Metrics()
+		for j := 0; j < metricsArray.Len(); j++ {
+			md := metricsArray.At(j)
+			t.drop(md.Name(), reason, dataPointsLen(md))
+		}
+	}
+}
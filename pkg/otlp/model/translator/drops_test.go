@@ -0,0 +1,283 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+type droppedPoint struct {
+	name   string
+	reason DropReason
+}
+
+func newDropRecordingTranslator(t *testing.T, opts ...Option) (*Translator, *[]droppedPoint) {
+	var dropped []droppedPoint
+	opts = append(opts, WithDropCallback(func(metricName string, reason DropReason) {
+		dropped = append(dropped, droppedPoint{name: metricName, reason: reason})
+	}))
+	return newTranslator(t, zap.NewNop(), opts...), &dropped
+}
+
+func TestDroppedPointsNaN(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t)
+
+	md := pmetric.NewMetrics()
+	met := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	met.SetName("test.gauge")
+	met.SetEmptyGauge()
+	for _, val := range []float64{1, math.NaN(), math.Inf(1)} {
+		met.Gauge().DataPoints().AppendEmpty().SetDoubleValue(val)
+	}
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	assert.Len(t, consumer.metrics, 1)
+	assert.Equal(t, map[DropReason]uint64{
+		DropReasonNaN:                2,
+		DropReasonFiltered:           0,
+		DropReasonEmpty:              0,
+		DropReasonNoRecordedValue:    0,
+		DropReasonStale:              0,
+		DropReasonDuplicateTimestamp: 0,
+	}, tr.Stats().DroppedPoints)
+	assert.Equal(t, []droppedPoint{
+		{name: "test.gauge", reason: DropReasonNaN},
+		{name: "test.gauge", reason: DropReasonNaN},
+	}, *dropped)
+}
+
+func TestDroppedPointsFiltered(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t, WithAPMStatsResourceFilters(`deployment.environment == "dev"`))
+
+	md := tr.StatsPayloadToMetrics(pb.StatsPayload{
+		Stats: []pb.ClientStatsPayload{statsPayloads[0], statsPayloads[1]},
+	})
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr("deployment.environment", "dev")
+
+	// Every point of the filtered resource is dropped
+	var filteredPoints int
+	ilms := md.ResourceMetrics().At(0).ScopeMetrics()
+	for i := 0; i < ilms.Len(); i++ {
+		for j := 0; j < ilms.At(i).Metrics().Len(); j++ {
+			filteredPoints += dataPointsLen(ilms.At(i).Metrics().At(j))
+		}
+	}
+	require.NotZero(t, filteredPoints)
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	assert.Len(t, consumer.apmstats, 1)
+	assert.Equal(t, uint64(filteredPoints), tr.Stats().DroppedPoints[DropReasonFiltered])
+	assert.Zero(t, tr.Stats().DroppedPoints[DropReasonNaN])
+	assert.Len(t, *dropped, filteredPoints)
+	for _, point := range *dropped {
+		assert.Equal(t, DropReasonFiltered, point.reason)
+	}
+}
+
+func TestDroppedPointsEmpty(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t)
+
+	md := pmetric.NewMetrics()
+	metricsArray := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	metricsArray.AppendEmpty().SetName("test.empty.gauge")
+	metricsArray.At(0).SetEmptyGauge()
+	metricsArray.AppendEmpty().SetName("test.empty.histogram")
+	metricsArray.At(1).SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	metricsArray.AppendEmpty().SetName("test.gauge")
+	metricsArray.At(2).SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	assert.Len(t, consumer.metrics, 1)
+	assert.Equal(t, uint64(2), tr.Stats().DroppedPoints[DropReasonEmpty])
+	assert.Equal(t, []droppedPoint{
+		{name: "test.empty.gauge", reason: DropReasonEmpty},
+		{name: "test.empty.histogram", reason: DropReasonEmpty},
+	}, *dropped)
+}
+
+func TestDroppedPointsNoRecordedValue(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t)
+
+	md := pmetric.NewMetrics()
+	metricsArray := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+
+	gauge := metricsArray.AppendEmpty()
+	gauge.SetName("test.gauge")
+	gauge.SetEmptyGauge()
+	flagged := gauge.Gauge().DataPoints().AppendEmpty()
+	flagged.SetIntValue(0)
+	flagged.SetTimestamp(seconds(1))
+	flagged.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	normal := gauge.Gauge().DataPoints().AppendEmpty()
+	normal.SetIntValue(0)
+	normal.SetTimestamp(seconds(2))
+
+	histogram := metricsArray.AppendEmpty()
+	histogram.SetName("test.histogram")
+	histogram.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	hp := histogram.Histogram().DataPoints().AppendEmpty()
+	hp.SetTimestamp(seconds(1))
+	hp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	// Only the normal point is emitted, with its value of 0
+	require.Len(t, consumer.metrics, 1)
+	assert.Equal(t, uint64(seconds(2)), consumer.metrics[0].timestamp)
+	assert.Empty(t, consumer.sketches)
+
+	assert.Equal(t, uint64(2), tr.Stats().DroppedPoints[DropReasonNoRecordedValue])
+	assert.Equal(t, []droppedPoint{
+		{name: "test.gauge", reason: DropReasonNoRecordedValue},
+		{name: "test.histogram", reason: DropReasonNoRecordedValue},
+	}, *dropped)
+}
+
+func TestDroppedPointsStale(t *testing.T) {
+	now := time.Now()
+
+	md := pmetric.NewMetrics()
+	gauge := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("test.gauge")
+	gauge.SetEmptyGauge()
+	fresh := gauge.Gauge().DataPoints().AppendEmpty()
+	fresh.SetIntValue(1)
+	fresh.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Minute)))
+	stale := gauge.Gauge().DataPoints().AppendEmpty()
+	stale.SetIntValue(2)
+	stale.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-2 * time.Hour)))
+
+	// Only the fresh point is emitted
+	tr, dropped := newDropRecordingTranslator(t, WithMaxTimestampAge(time.Hour))
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	require.Len(t, consumer.metrics, 1)
+	assert.Equal(t, 1.0, consumer.metrics[0].value)
+	assert.Equal(t, uint64(1), tr.Stats().DroppedPoints[DropReasonStale])
+	assert.Equal(t, []droppedPoint{{name: "test.gauge", reason: DropReasonStale}}, *dropped)
+
+	// A zero age disables the check
+	tr, dropped = newDropRecordingTranslator(t, WithMaxTimestampAge(0))
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	assert.Len(t, consumer.metrics, 2)
+	assert.Empty(t, *dropped)
+
+	_, err := New(zap.NewNop(), WithMaxTimestampAge(-time.Hour))
+	assert.Error(t, err)
+}
+
+func TestDroppedPointsDuplicateTimestamp(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t)
+
+	md := pmetric.NewMetrics()
+	sum := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	sum.SetName("test.sum")
+	sum.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.Sum().SetIsMonotonic(true)
+	for _, point := range []struct {
+		ts  int
+		val int64
+	}{{1, 10}, {2, 15}, {2, 12}, {2, 15}, {3, 20}} {
+		p := sum.Sum().DataPoints().AppendEmpty()
+		p.SetStartTimestamp(seconds(0))
+		p.SetTimestamp(seconds(point.ts))
+		p.SetIntValue(point.val)
+	}
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	// The first point at each timestamp is kept, the diffs are computed from it
+	require.Len(t, consumer.metrics, 2)
+	for i, m := range consumer.metrics {
+		assert.Equal(t, Count, m.typ)
+		assert.Equal(t, uint64(seconds(i+2)), m.timestamp)
+		assert.Equal(t, 5.0, m.value)
+	}
+	assert.Equal(t, uint64(2), tr.Stats().DroppedPoints[DropReasonDuplicateTimestamp])
+	assert.Equal(t, []droppedPoint{
+		{name: "test.sum", reason: DropReasonDuplicateTimestamp},
+		{name: "test.sum", reason: DropReasonDuplicateTimestamp},
+	}, *dropped)
+}
+
+func TestDroppedPointsDuplicateTimestampHistogramsAndSummaries(t *testing.T) {
+	tr, dropped := newDropRecordingTranslator(t, WithCountSumMetrics())
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	hist := metrics.AppendEmpty()
+	hist.SetName("test.histogram")
+	hist.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	summary := metrics.AppendEmpty()
+	summary.SetName("test.summary")
+	summary.SetEmptySummary()
+	for _, point := range []struct {
+		ts    int
+		count uint64
+	}{{1, 10}, {2, 15}, {2, 12}, {3, 20}} {
+		p := hist.Histogram().DataPoints().AppendEmpty()
+		p.SetStartTimestamp(seconds(0))
+		p.SetTimestamp(seconds(point.ts))
+		p.SetCount(point.count)
+		p.SetSum(float64(point.count))
+		p.BucketCounts().FromRaw([]uint64{point.count})
+
+		s := summary.Summary().DataPoints().AppendEmpty()
+		s.SetStartTimestamp(seconds(0))
+		s.SetTimestamp(seconds(point.ts))
+		s.SetCount(point.count)
+		s.SetSum(float64(point.count))
+	}
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	// The diffs are computed from the first point at each timestamp
+	for _, m := range consumer.metrics {
+		assert.Equal(t, 5.0, m.value, m.name)
+	}
+	require.Len(t, consumer.sketches, 2)
+	for i, s := range consumer.sketches {
+		assert.Equal(t, uint64(seconds(i+2)), s.timestamp)
+		assert.Equal(t, int64(5), s.basic.Cnt)
+	}
+	assert.Equal(t, uint64(2), tr.Stats().DroppedPoints[DropReasonDuplicateTimestamp])
+	assert.Equal(t, []droppedPoint{
+		{name: "test.histogram", reason: DropReasonDuplicateTimestamp},
+		{name: "test.summary", reason: DropReasonDuplicateTimestamp},
+	}, *dropped)
+}
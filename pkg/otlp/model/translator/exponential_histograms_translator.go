@@ -91,16 +91,22 @@ func (t *Translator) mapExponentialHistogramMetrics(
 ) {
 	for i := 0; i < slice.Len(); i++ {
 		p := slice.At(i)
+		if t.dropNoRecordedValue(dims.name, p.Flags()) {
+			continue
+		}
 		startTs := uint64(p.StartTimestamp())
 		ts := uint64(p.Timestamp())
-		pointDims := dims.WithAttributeMap(p.Attributes())
+		pointDims := t.withPointAttributes(dims, p.Attributes())
 
 		histInfo := histogramInfo{ok: true}
 
 		countDims := pointDims.WithSuffix("count")
 		if delta {
 			histInfo.count = p.Count()
-		} else if dx, ok := t.prevPts.Diff(countDims, startTs, ts, float64(p.Count())); ok {
+		} else if dx, duplicate, ok := t.prevPts.DiffOrDuplicate(countDims, startTs, ts, float64(p.Count())); duplicate {
+			t.drop(pointDims.name, DropReasonDuplicateTimestamp, 1)
+			continue
+		} else if ok {
 			histInfo.count = uint64(dx)
 		} else { // not ok
 			histInfo.ok = false
@@ -121,8 +127,8 @@ func (t *Translator) mapExponentialHistogramMetrics(
 
 		if t.cfg.SendCountSum && histInfo.ok {
 			// We only send the sum and count if both values were ok.
-			consumer.ConsumeTimeSeries(ctx, countDims, Count, ts, float64(histInfo.count))
-			consumer.ConsumeTimeSeries(ctx, sumDims, Count, ts, histInfo.sum)
+			t.consumeCount(ctx, consumer, countDims, ts, float64(histInfo.count), true)
+			t.consumeCount(ctx, consumer, sumDims, ts, histInfo.sum, true)
 		}
 
 		expHistDDSketch, err := t.exponentialHistogramToDDSketch(p, delta)
@@ -0,0 +1,78 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+)
+
+// coalescedGauge is the latest point of the gauges of the same dimensions.
+type coalescedGauge struct {
+	dims  *Dimensions
+	ts    uint64
+	value float64
+}
+
+// gaugeCoalescer is a TimeSeriesConsumer keeping the latest point of the
+// gauges of the same dimensions until they are flushed, see
+// WithGaugeCoalescing. Other time series are forwarded to the wrapped
+// TimeSeriesConsumer.
+type gaugeCoalescer struct {
+	TimeSeriesConsumer
+
+	gauges map[string]*coalescedGauge
+	// gauges keys, in the order of their first point
+	keys []string
+}
+
+func newGaugeCoalescer(consumer TimeSeriesConsumer) *gaugeCoalescer {
+	return &gaugeCoalescer{
+		TimeSeriesConsumer: consumer,
+		gauges:             make(map[string]*coalescedGauge),
+	}
+}
+
+// ConsumeTimeSeries implements TimeSeriesConsumer.
+func (c *gaugeCoalescer) ConsumeTimeSeries(ctx context.Context, dimensions *Dimensions, typ MetricDataType, timestamp uint64, value float64) {
+	if typ != Gauge {
+		c.TimeSeriesConsumer.ConsumeTimeSeries(ctx, dimensions, typ, timestamp, value)
+		return
+	}
+
+	key := dimensions.String()
+	coalesced, found := c.gauges[key]
+	if !found {
+		c.gauges[key] = &coalescedGauge{dims: dimensions, ts: timestamp, value: value}
+		c.keys = append(c.keys, key)
+		return
+	}
+
+	// of the points with the same timestamp, the last one consumed is kept
+	if timestamp >= coalesced.ts {
+		coalesced.dims = dimensions
+		coalesced.ts = timestamp
+		coalesced.value = value
+	}
+}
+
+// flush consumes the latest point of the gauges of each dimensions.
+func (c *gaugeCoalescer) flush(ctx context.Context) {
+	for _, key := range c.keys {
+		coalesced := c.gauges[key]
+		c.TimeSeriesConsumer.ConsumeTimeSeries(ctx, coalesced.dims, Gauge, coalesced.ts, coalesced.value)
+	}
+	c.gauges = make(map[string]*coalescedGauge)
+	c.keys = nil
+}
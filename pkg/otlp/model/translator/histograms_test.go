@@ -6,10 +6,12 @@
 package translator
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -254,3 +256,206 @@ func TestExponentialHistogramOptions(t *testing.T) {
 		})
 	}
 }
+
+func newHistogramPoints(counts ...[]uint64) pmetric.HistogramDataPointSlice {
+	slice := pmetric.NewHistogramDataPointSlice()
+	for i, bucketCounts := range counts {
+		p := slice.AppendEmpty()
+		p.ExplicitBounds().FromRaw([]float64{0, 10})
+		p.BucketCounts().FromRaw(bucketCounts)
+		var count uint64
+		for _, c := range bucketCounts {
+			count += c
+		}
+		p.SetCount(count)
+		p.SetSum(float64(count))
+		p.SetStartTimestamp(seconds(0))
+		p.SetTimestamp(seconds(i + 1))
+	}
+	return slice
+}
+
+func TestHistogramSketchMinCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		counts       []uint64
+		options      []Option
+		expectSketch bool
+	}{
+		{
+			name:         "default",
+			counts:       []uint64{1, 0, 0},
+			expectSketch: true,
+		},
+		{
+			name:         "below threshold",
+			counts:       []uint64{1, 2, 1},
+			options:      []Option{WithHistogramSketchMinCount(5)},
+			expectSketch: false,
+		},
+		{
+			name:         "at threshold",
+			counts:       []uint64{1, 3, 1},
+			options:      []Option{WithHistogramSketchMinCount(5)},
+			expectSketch: true,
+		},
+		{
+			name:         "above threshold",
+			counts:       []uint64{2, 3, 1},
+			options:      []Option{WithHistogramSketchMinCount(5)},
+			expectSketch: true,
+		},
+	}
+
+	for _, testInstance := range tests {
+		t.Run(testInstance.name, func(t *testing.T) {
+			tr := newTranslator(t, zap.NewNop(), testInstance.options...)
+			consumer := &mockFullConsumer{}
+			dims := newDims("doubleHist.test")
+			tr.mapHistogramMetrics(context.Background(), consumer, dims, newHistogramPoints(testInstance.counts), true)
+
+			if testInstance.expectSketch {
+				assert.Empty(t, consumer.metrics)
+				require.Len(t, consumer.sketches, 1)
+				assert.Equal(t, "doubleHist.test", consumer.sketches[0].name)
+				return
+			}
+
+			assert.Empty(t, consumer.sketches)
+			bucketDims := dims.WithSuffix("bucket")
+			assert.ElementsMatch(t, []metric{
+				newCount(bucketDims.AddTags("lower_bound:-inf", "upper_bound:0"), uint64(seconds(1)), 1),
+				newCount(bucketDims.AddTags("lower_bound:0", "upper_bound:10.0"), uint64(seconds(1)), 2),
+				newCount(bucketDims.AddTags("lower_bound:10.0", "upper_bound:inf"), uint64(seconds(1)), 1),
+			}, consumer.metrics)
+		})
+	}
+}
+
+func TestCumulativeHistogramSketchMinCount(t *testing.T) {
+	tr := newTranslator(t, zap.NewNop(), WithHistogramSketchMinCount(5))
+	consumer := &mockFullConsumer{}
+	dims := newDims("doubleHist.test")
+
+	// The first point is only used as a reference, then the increases of the
+	// bucket counts are mapped to bucket counts or to a sketch.
+	tr.mapHistogramMetrics(context.Background(), consumer, dims, newHistogramPoints(
+		[]uint64{10, 10, 10},
+		[]uint64{11, 12, 11},
+		[]uint64{13, 15, 13},
+	), false)
+
+	bucketDims := dims.WithSuffix("bucket")
+	assert.ElementsMatch(t, []metric{
+		newCount(bucketDims.AddTags("lower_bound:-inf", "upper_bound:0"), uint64(seconds(2)), 1),
+		newCount(bucketDims.AddTags("lower_bound:0", "upper_bound:10.0"), uint64(seconds(2)), 2),
+		newCount(bucketDims.AddTags("lower_bound:10.0", "upper_bound:inf"), uint64(seconds(2)), 1),
+	}, consumer.metrics)
+
+	// The sketch only has the observations since the previous point
+	require.Len(t, consumer.sketches, 1)
+	assert.Equal(t, uint64(seconds(3)), consumer.sketches[0].timestamp)
+	assert.Equal(t, int64(7), consumer.sketches[0].basic.Cnt)
+}
+
+func TestHistogramSketchMerging(t *testing.T) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	// the same histogram in two scopes, and one with other dimensions
+	for i, env := range []string{"prod", "prod", "dev"} {
+		met := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		met.SetName("test.histogram")
+		met.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		points := newHistogramPoints([]uint64{1, uint64(i + 2), 1})
+		points.At(0).Attributes().PutStr("env", env)
+		points.At(0).SetTimestamp(seconds(i + 1))
+		points.CopyTo(met.Histogram().DataPoints())
+	}
+
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+	assert.Len(t, consumer.sketches, 3)
+
+	tr = newTranslator(t, zap.NewNop(), WithHistogramSketchMerging())
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+	require.Len(t, consumer.sketches, 2)
+
+	merged := consumer.sketches[0]
+	assert.Equal(t, []string{"env:prod"}, merged.tags)
+	assert.Equal(t, uint64(seconds(2)), merged.timestamp)
+	assert.Equal(t, int64(4+5), merged.basic.Cnt)
+	assert.Equal(t, float64(4+5), merged.basic.Sum)
+
+	other := consumer.sketches[1]
+	assert.Equal(t, []string{"env:dev"}, other.tags)
+	assert.Equal(t, int64(6), other.basic.Cnt)
+}
+
+func TestHistogramSkipZeroCountBuckets(t *testing.T) {
+	points := pmetric.NewHistogramDataPointSlice()
+	p := points.AppendEmpty()
+	p.ExplicitBounds().FromRaw([]float64{0, 10, 100, 1000})
+	p.BucketCounts().FromRaw([]uint64{0, 3, 0, 0, 1})
+	p.SetCount(4)
+	p.SetSum(1500)
+	p.SetTimestamp(seconds(1))
+
+	dims := newDims("doubleHist.test")
+	bucketDims := dims.WithSuffix("bucket")
+	countSum := []metric{
+		newCount(dims.WithSuffix("count"), uint64(seconds(1)), 4),
+		newCount(dims.WithSuffix("sum"), uint64(seconds(1)), 1500),
+	}
+	nonEmptyBuckets := []metric{
+		newCount(bucketDims.AddTags("lower_bound:0", "upper_bound:10.0"), uint64(seconds(1)), 3),
+		newCount(bucketDims.AddTags("lower_bound:1000.0", "upper_bound:inf"), uint64(seconds(1)), 1),
+	}
+	emptyBuckets := []metric{
+		newCount(bucketDims.AddTags("lower_bound:-inf", "upper_bound:0"), uint64(seconds(1)), 0),
+		newCount(bucketDims.AddTags("lower_bound:10.0", "upper_bound:100.0"), uint64(seconds(1)), 0),
+		newCount(bucketDims.AddTags("lower_bound:100.0", "upper_bound:1000.0"), uint64(seconds(1)), 0),
+	}
+
+	t.Run("off", func(t *testing.T) {
+		tr := newTranslator(t, zap.NewNop(), WithHistogramMode(HistogramModeCounters), WithCountSumMetrics())
+		consumer := &mockFullConsumer{}
+		tr.mapHistogramMetrics(context.Background(), consumer, dims, points, true)
+
+		var expected []metric
+		expected = append(expected, countSum...)
+		expected = append(expected, nonEmptyBuckets...)
+		expected = append(expected, emptyBuckets...)
+		assert.ElementsMatch(t, expected, consumer.metrics)
+	})
+
+	t.Run("on", func(t *testing.T) {
+		tr := newTranslator(t, zap.NewNop(), WithHistogramMode(HistogramModeCounters), WithCountSumMetrics(), WithHistogramSkipZeroCountBuckets())
+		consumer := &mockFullConsumer{}
+		tr.mapHistogramMetrics(context.Background(), consumer, dims, points, true)
+
+		var expected []metric
+		expected = append(expected, countSum...)
+		expected = append(expected, nonEmptyBuckets...)
+		assert.ElementsMatch(t, expected, consumer.metrics)
+	})
+}
+
+func TestCumulativeHistogramSkipZeroCountBuckets(t *testing.T) {
+	tr := newTranslator(t, zap.NewNop(), WithHistogramMode(HistogramModeCounters), WithHistogramSkipZeroCountBuckets())
+	consumer := &mockFullConsumer{}
+	dims := newDims("doubleHist.test")
+
+	// Only the buckets whose count increased since the previous point are
+	// reported
+	tr.mapHistogramMetrics(context.Background(), consumer, dims, newHistogramPoints(
+		[]uint64{10, 10, 10},
+		[]uint64{10, 12, 10},
+	), false)
+
+	bucketDims := dims.WithSuffix("bucket")
+	assert.Equal(t, []metric{
+		newCount(bucketDims.AddTags("lower_bound:0", "upper_bound:10.0"), uint64(seconds(2)), 2),
+	}, consumer.metrics)
+}
@@ -45,9 +45,14 @@ func (*noSourceProvider) Source(context.Context) (source.Source, error) {
 
 // Translator is a metrics translator.
 type Translator struct {
+	// truncatedTags is the number of tags truncated to MaxTagLength. First
+	// field for the alignment of atomic operations.
+	truncatedTags uint64
+
 	prevPts *ttlCache
 	logger  *zap.Logger
 	cfg     translatorConfig
+	drops   dropCounters
 }
 
 // New creates a new translator with given options.
@@ -61,6 +66,11 @@ func New(logger *zap.Logger, options ...Option) (*Translator, error) {
 		InstrumentationLibraryMetadataAsTags: false,
 		sweepInterval:                        1800,
 		deltaTTL:                             3600,
+		bucketLowerBoundTagKey:               "lower_bound",
+		bucketUpperBoundTagKey:               "upper_bound",
+		bucketBoundFormat:                    BucketBoundFormatDefault,
+		bucketBoundPrecision:                 -1,
+		initialCumulativeValue:               InitialCumulativeValueDrop,
 		fallbackSourceProvider:               &noSourceProvider{},
 	}
 
@@ -80,6 +90,7 @@ func New(logger *zap.Logger, options ...Option) (*Translator, error) {
 		prevPts: cache,
 		logger:  logger.With(zap.String("component", "metrics translator")),
 		cfg:     cfg,
+		drops:   newDropCounters(),
 	}, nil
 }
 
@@ -99,22 +110,59 @@ func (t *Translator) isSkippable(name string, v float64) bool {
 	skippable := math.IsInf(v, 0) || math.IsNaN(v)
 	if skippable {
 		t.logger.Debug("Unsupported metric value", zap.String(metricName, name), zap.Float64("value", v))
+		t.drop(name, DropReasonNaN, 1)
 	}
 	return skippable
 }
 
-// mapNumberMetrics maps double datapoints into Datadog metrics
+// isStale checks if a timestamp is older than the maximum age of the points,
+// see WithMaxTimestampAge.
+func (t *Translator) isStale(ts pcommon.Timestamp) bool {
+	return t.cfg.maxTimestampAge > 0 && ts.AsTime().Before(time.Now().Add(-t.cfg.maxTimestampAge))
+}
+
+// consumeCount consumes a count, annotated with its monotonicity when the
+// consumer is a CountConsumer, see WithCountMonotonicityHints.
+func (t *Translator) consumeCount(ctx context.Context, consumer TimeSeriesConsumer, dims *Dimensions, ts uint64, val float64, monotonic bool) {
+	if t.cfg.countMonotonicityHints {
+		if countConsumer, ok := consumer.(CountConsumer); ok {
+			countConsumer.ConsumeCount(ctx, dims, ts, val, monotonic)
+			return
+		}
+	}
+	consumer.ConsumeTimeSeries(ctx, dims, Count, ts, val)
+}
+
+// metricType returns the type to map the number metric with the given name to,
+// the type inferred by default unless it's overridden.
+func (t *Translator) metricType(name string, inferred MetricDataType) MetricDataType {
+	if dt, ok := t.cfg.metricTypeOverrides[name]; ok {
+		return dt
+	}
+	return inferred
+}
+
+// mapNumberMetrics maps double datapoints into Datadog metrics.
+// monotonic is the monotonicity hint of the counts, see WithCountMonotonicityHints.
 func (t *Translator) mapNumberMetrics(
 	ctx context.Context,
 	consumer TimeSeriesConsumer,
 	dims *Dimensions,
 	dt MetricDataType,
+	monotonic bool,
 	slice pmetric.NumberDataPointSlice,
 ) {
 
 	for i := 0; i < slice.Len(); i++ {
 		p := slice.At(i)
-		pointDims := dims.WithAttributeMap(p.Attributes())
+		if t.dropNoRecordedValue(dims.name, p.Flags()) {
+			continue
+		}
+		if t.isStale(p.Timestamp()) {
+			t.drop(dims.name, DropReasonStale, 1)
+			continue
+		}
+		pointDims := t.withPointAttributes(dims, p.Attributes())
 		var val float64
 		switch p.ValueType() {
 		case pmetric.NumberDataPointValueTypeDouble:
@@ -127,7 +175,11 @@ func (t *Translator) mapNumberMetrics(
 			continue
 		}
 
-		consumer.ConsumeTimeSeries(ctx, pointDims, dt, uint64(p.Timestamp()), val)
+		if dt == Count {
+			t.consumeCount(ctx, consumer, pointDims, uint64(p.Timestamp()), val, monotonic)
+		} else {
+			consumer.ConsumeTimeSeries(ctx, pointDims, dt, uint64(p.Timestamp()), val)
+		}
 	}
 }
 
@@ -149,9 +201,12 @@ func (t *Translator) mapNumberMonotonicMetrics(
 ) {
 	for i := 0; i < slice.Len(); i++ {
 		p := slice.At(i)
+		if t.dropNoRecordedValue(dims.name, p.Flags()) {
+			continue
+		}
 		ts := uint64(p.Timestamp())
 		startTs := uint64(p.StartTimestamp())
-		pointDims := dims.WithAttributeMap(p.Attributes())
+		pointDims := t.withPointAttributes(dims, p.Attributes())
 
 		var val float64
 		switch p.ValueType() {
@@ -165,11 +220,16 @@ func (t *Translator) mapNumberMonotonicMetrics(
 			continue
 		}
 
-		if dx, ok := t.prevPts.MonotonicDiff(pointDims, startTs, ts, val); ok {
-			consumer.ConsumeTimeSeries(ctx, pointDims, Count, ts, dx)
+		if dx, first, duplicate, ok := t.prevPts.MonotonicDiffOrFirst(pointDims, startTs, ts, val); duplicate {
+			t.drop(pointDims.name, DropReasonDuplicateTimestamp, 1)
+		} else if ok {
+			t.consumeCount(ctx, consumer, pointDims, ts, dx, true)
+		} else if first && t.cfg.initialCumulativeValue == InitialCumulativeValueKeep {
+			// Report the first value of every timeseries, see WithInitialCumulativeValue.
+			t.consumeCount(ctx, consumer, pointDims, ts, val, true)
 		} else if i == 0 && getProcessStartTime() < startTs {
 			// Report the first value if the timeseries started after the Datadog Agent process started.
-			consumer.ConsumeTimeSeries(ctx, pointDims, Count, ts, val)
+			t.consumeCount(ctx, consumer, pointDims, ts, val, true)
 		}
 	}
 }
@@ -270,16 +330,23 @@ func (t *Translator) getLegacyBuckets(
 	for idx := 0; idx < p.BucketCounts().Len(); idx++ {
 		lowerBound, upperBound := getBounds(p, idx)
 		bucketDims := baseBucketDims.AddTags(
-			fmt.Sprintf("lower_bound:%s", formatFloat(lowerBound)),
-			fmt.Sprintf("upper_bound:%s", formatFloat(upperBound)),
+			fmt.Sprintf("%s:%s", t.cfg.bucketLowerBoundTagKey, t.formatBucketBound(lowerBound)),
+			fmt.Sprintf("%s:%s", t.cfg.bucketUpperBoundTagKey, t.formatBucketBound(upperBound)),
 		)
 
 		count := float64(p.BucketCounts().At(idx))
-		if delta {
-			consumer.ConsumeTimeSeries(ctx, bucketDims, Count, ts, count)
-		} else if dx, ok := t.prevPts.Diff(bucketDims, startTs, ts, count); ok {
-			consumer.ConsumeTimeSeries(ctx, bucketDims, Count, ts, dx)
+		if !delta {
+			dx, ok := t.prevPts.Diff(bucketDims, startTs, ts, count)
+			if !ok {
+				continue
+			}
+			count = dx
 		}
+
+		if count == 0 && t.cfg.HistSkipZeroCountBuckets {
+			continue
+		}
+		t.consumeCount(ctx, consumer, bucketDims, ts, count, true)
 	}
 }
 
@@ -305,16 +372,22 @@ func (t *Translator) mapHistogramMetrics(
 ) {
 	for i := 0; i < slice.Len(); i++ {
 		p := slice.At(i)
+		if t.dropNoRecordedValue(dims.name, p.Flags()) {
+			continue
+		}
 		startTs := uint64(p.StartTimestamp())
 		ts := uint64(p.Timestamp())
-		pointDims := dims.WithAttributeMap(p.Attributes())
+		pointDims := t.withPointAttributes(dims, p.Attributes())
 
 		histInfo := histogramInfo{ok: true}
 
 		countDims := pointDims.WithSuffix("count")
 		if delta {
 			histInfo.count = p.Count()
-		} else if dx, ok := t.prevPts.Diff(countDims, startTs, ts, float64(p.Count())); ok {
+		} else if dx, duplicate, ok := t.prevPts.DiffOrDuplicate(countDims, startTs, ts, float64(p.Count())); duplicate {
+			t.drop(pointDims.name, DropReasonDuplicateTimestamp, 1)
+			continue
+		} else if ok {
 			histInfo.count = uint64(dx)
 		} else { // not ok
 			histInfo.ok = false
@@ -335,19 +408,36 @@ func (t *Translator) mapHistogramMetrics(
 
 		if t.cfg.SendCountSum && histInfo.ok {
 			// We only send the sum and count if both values were ok.
-			consumer.ConsumeTimeSeries(ctx, countDims, Count, ts, float64(histInfo.count))
-			consumer.ConsumeTimeSeries(ctx, sumDims, Count, ts, histInfo.sum)
+			t.consumeCount(ctx, consumer, countDims, ts, float64(histInfo.count), true)
+			t.consumeCount(ctx, consumer, sumDims, ts, histInfo.sum, true)
 		}
 
 		switch t.cfg.HistMode {
 		case HistogramModeCounters:
 			t.getLegacyBuckets(ctx, consumer, pointDims, p, delta)
 		case HistogramModeDistributions:
-			t.getSketchBuckets(ctx, consumer, pointDims, p, histInfo, delta)
+			if histInfo.ok && histInfo.count < t.cfg.HistSketchMinCount {
+				t.getLegacyBuckets(ctx, consumer, pointDims, p, delta)
+				if !delta {
+					// Keep track of the bucket counts of the sketch too,
+					// the next points may have enough observations for it.
+					t.getSketchBuckets(ctx, NoopConsumer{}, pointDims, p, histInfo, delta)
+				}
+			} else {
+				t.getSketchBuckets(ctx, consumer, pointDims, p, histInfo, delta)
+				if !delta && t.cfg.HistSketchMinCount > 0 {
+					t.getLegacyBuckets(ctx, NoopConsumer{}, pointDims, p, delta)
+				}
+			}
 		}
 	}
 }
 
+// originalMetricNameTag returns the tag with the name of the OTLP metric.
+func originalMetricNameTag(name string) string {
+	return fmt.Sprintf("otel_metric_name:%s", name)
+}
+
 // formatFloat formats a float number as close as possible to what
 // we do on the Datadog Agent Python OpenMetrics check, which, in turn, tries to
 // follow https://github.com/OpenObservability/OpenMetrics/blob/v1.0.0/specification/OpenMetrics.md#considerations-canonical-numbers
@@ -370,6 +460,22 @@ func formatFloat(f float64) string {
 	return s
 }
 
+// formatBucketBound formats a bound of a histogram bucket with the configured
+// format, see WithHistogramBucketBoundFormat.
+func (t *Translator) formatBucketBound(f float64) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return formatFloat(f)
+	}
+
+	switch t.cfg.bucketBoundFormat {
+	case BucketBoundFormatFixed:
+		return strconv.FormatFloat(f, 'f', t.cfg.bucketBoundPrecision, 64)
+	case BucketBoundFormatScientific:
+		return strconv.FormatFloat(f, 'e', t.cfg.bucketBoundPrecision, 64)
+	}
+	return formatFloat(f)
+}
+
 // getQuantileTag returns the quantile tag for summary types.
 func getQuantileTag(quantile float64) string {
 	return fmt.Sprintf("quantile:%s", formatFloat(quantile))
@@ -385,15 +491,23 @@ func (t *Translator) mapSummaryMetrics(
 
 	for i := 0; i < slice.Len(); i++ {
 		p := slice.At(i)
+		if t.dropNoRecordedValue(dims.name, p.Flags()) {
+			continue
+		}
 		startTs := uint64(p.StartTimestamp())
 		ts := uint64(p.Timestamp())
-		pointDims := dims.WithAttributeMap(p.Attributes())
+		pointDims := t.withPointAttributes(dims, p.Attributes())
 
 		// count and sum are increasing; we treat them as cumulative monotonic sums.
 		{
 			countDims := pointDims.WithSuffix("count")
-			if dx, ok := t.prevPts.Diff(countDims, startTs, ts, float64(p.Count())); ok && !t.isSkippable(countDims.name, dx) {
-				consumer.ConsumeTimeSeries(ctx, countDims, Count, ts, dx)
+			dx, duplicate, ok := t.prevPts.DiffOrDuplicate(countDims, startTs, ts, float64(p.Count()))
+			if duplicate {
+				t.drop(pointDims.name, DropReasonDuplicateTimestamp, 1)
+				continue
+			}
+			if ok && !t.isSkippable(countDims.name, dx) {
+				t.consumeCount(ctx, consumer, countDims, ts, dx, true)
 			}
 		}
 
@@ -401,7 +515,7 @@ func (t *Translator) mapSummaryMetrics(
 			sumDims := pointDims.WithSuffix("sum")
 			if !t.isSkippable(sumDims.name, p.Sum()) {
 				if dx, ok := t.prevPts.Diff(sumDims, startTs, ts, p.Sum()); ok {
-					consumer.ConsumeTimeSeries(ctx, sumDims, Count, ts, dx)
+					t.consumeCount(ctx, consumer, sumDims, ts, dx, true)
 				}
 			}
 		}
@@ -435,12 +549,69 @@ func (t *Translator) source(m pcommon.Map) (source.Source, error) {
 	return src, nil
 }
 
+// mapResourceAttributeGauges reports the numeric resource attributes
+// configured with WithResourceAttributesAsGauges as gauges.
+func (t *Translator) mapResourceAttributeGauges(ctx context.Context, res pcommon.Resource, tags []string, host string, consumer Consumer) {
+	timestamp := uint64(time.Now().UnixNano())
+	originID := attributes.OriginIDFromAttributes(res.Attributes())
+	for _, key := range t.cfg.resourceAttributeGauges {
+		attr, ok := res.Attributes().Get(key)
+		if !ok {
+			continue
+		}
+
+		var val float64
+		switch attr.Type() {
+		case pcommon.ValueTypeInt:
+			val = float64(attr.Int())
+		case pcommon.ValueTypeDouble:
+			val = attr.Double()
+		default:
+			t.logger.Debug("Skipping non-numeric resource attribute",
+				zap.String("key", key), zap.Any("type", attr.Type()))
+			continue
+		}
+
+		dims := &Dimensions{
+			name:     t.cfg.resourceAttributeGaugePrefix + key,
+			tags:     tags,
+			host:     host,
+			originID: originID,
+		}
+		consumer.ConsumeTimeSeries(ctx, dims, Gauge, timestamp, val)
+	}
+}
+
 // MapMetrics maps OTLP metrics into the DataDog format
 func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consumer Consumer) error {
+	ctx = newBatchContext(ctx)
+
+	// the sketches of the histograms are merged until the end of the call,
+	// see WithHistogramSketchMerging
+	histConsumer := consumer
+	var merger *sketchMerger
+	if t.cfg.HistSketchMerging {
+		merger = newSketchMerger(consumer)
+		histConsumer = merger
+	}
+
+	// the gauges are coalesced until the end of the call, see
+	// WithGaugeCoalescing
+	var gaugeConsumer TimeSeriesConsumer = consumer
+	var coalescer *gaugeCoalescer
+	if t.cfg.gaugeCoalescing {
+		coalescer = newGaugeCoalescer(consumer)
+		gaugeConsumer = coalescer
+	}
+
 	rms := md.ResourceMetrics()
 	for i := 0; i < rms.Len(); i++ {
 		rm := rms.At(i)
 		if v, ok := rm.Resource().Attributes().Get(keyAPMStats); ok && v.Bool() {
+			if t.isAPMStatsFiltered(rm.Resource().Attributes()) {
+				t.dropResourceMetrics(rm, DropReasonFiltered)
+				continue
+			}
 			// these resource metrics are an APM Stats payload; consume it as such
 			sp, err := t.statsPayloadFromMetrics(rm)
 			if err != nil {
@@ -466,8 +637,31 @@ func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consume
 			}
 		}
 
+		if c, ok := consumer.(ResourceMetadataConsumer); ok {
+			if metadata := resourceMetadata(rm.Resource().Attributes()); len(metadata) > 0 {
+				c.ConsumeResourceMetadata(metadata)
+			}
+		}
+
 		// Fetch tags from attributes.
 		attributeTags := attributes.TagsFromAttributes(rm.Resource().Attributes())
+		if t.cfg.SchemaURLAsTag && rm.SchemaUrl() != "" {
+			attributeTags = append(attributeTags, fmt.Sprintf("otel_schema_url:%s", rm.SchemaUrl()))
+		}
+		t.truncateTags(attributeTags)
+
+		if t.cfg.heartbeatMetricName != "" {
+			heartbeatDims := &Dimensions{
+				name:     t.cfg.heartbeatMetricName,
+				tags:     attributeTags,
+				host:     host,
+				originID: attributes.OriginIDFromAttributes(rm.Resource().Attributes()),
+			}
+			consumer.ConsumeTimeSeries(ctx, heartbeatDims, Gauge, uint64(time.Now().UnixNano()), 1)
+		}
+		if len(t.cfg.resourceAttributeGauges) > 0 {
+			t.mapResourceAttributeGauges(ctx, rm.Resource(), attributeTags, host, consumer)
+		}
 		ilms := rm.ScopeMetrics()
 		for j := 0; j < ilms.Len(); j++ {
 			ilm := ilms.At(j)
@@ -481,28 +675,50 @@ func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consume
 			} else {
 				additionalTags = attributeTags
 			}
+			t.truncateTags(additionalTags[len(attributeTags):])
+
+			originConsumer, consumesOrigin := consumer.(OriginConsumer)
+			var origin Origin
+			if consumesOrigin {
+				origin = t.origin(rm.Resource(), ilm.Scope())
+			}
 
 			for k := 0; k < metricsArray.Len(); k++ {
 				md := metricsArray.At(k)
+				if consumesOrigin {
+					originConsumer.ConsumeOrigin(origin.Product, origin.Category, origin.Service)
+				}
 				baseDims := &Dimensions{
 					name:     md.Name(),
 					tags:     additionalTags,
 					host:     host,
 					originID: attributes.OriginIDFromAttributes(rm.Resource().Attributes()),
 				}
+				if t.cfg.OriginalMetricNameAsTag {
+					baseDims = baseDims.AddTags(originalMetricNameTag(md.Name()))
+					t.truncateTags(baseDims.tags[:1])
+				}
 				switch md.Type() {
 				case pmetric.MetricTypeGauge:
-					t.mapNumberMetrics(ctx, consumer, baseDims, Gauge, md.Gauge().DataPoints())
+					if t.metricType(md.Name(), Gauge) == Count {
+						t.mapNumberMonotonicMetrics(ctx, consumer, baseDims, md.Gauge().DataPoints())
+					} else {
+						t.mapNumberMetrics(ctx, gaugeConsumer, baseDims, Gauge, false, md.Gauge().DataPoints())
+					}
 				case pmetric.MetricTypeSum:
 					switch md.Sum().AggregationTemporality() {
 					case pmetric.AggregationTemporalityCumulative:
+						dt := Gauge
 						if t.cfg.SendMonotonic && isCumulativeMonotonic(md) {
+							dt = Count
+						}
+						if t.metricType(md.Name(), dt) == Count {
 							t.mapNumberMonotonicMetrics(ctx, consumer, baseDims, md.Sum().DataPoints())
 						} else {
-							t.mapNumberMetrics(ctx, consumer, baseDims, Gauge, md.Sum().DataPoints())
+							t.mapNumberMetrics(ctx, gaugeConsumer, baseDims, Gauge, false, md.Sum().DataPoints())
 						}
 					case pmetric.AggregationTemporalityDelta:
-						t.mapNumberMetrics(ctx, consumer, baseDims, Count, md.Sum().DataPoints())
+						t.mapNumberMetrics(ctx, consumer, baseDims, t.metricType(md.Name(), Count), md.Sum().IsMonotonic(), md.Sum().DataPoints())
 					default: // pmetric.AggregationTemporalityUnspecified or any other not supported type
 						t.logger.Debug("Unknown or unsupported aggregation temporality",
 							zap.String(metricName, md.Name()),
@@ -514,7 +730,7 @@ func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consume
 					switch md.Histogram().AggregationTemporality() {
 					case pmetric.AggregationTemporalityCumulative, pmetric.AggregationTemporalityDelta:
 						delta := md.Histogram().AggregationTemporality() == pmetric.AggregationTemporalityDelta
-						t.mapHistogramMetrics(ctx, consumer, baseDims, md.Histogram().DataPoints(), delta)
+						t.mapHistogramMetrics(ctx, histConsumer, baseDims, md.Histogram().DataPoints(), delta)
 					default: // pmetric.AggregationTemporalityUnspecified or any other not supported type
 						t.logger.Debug("Unknown or unsupported aggregation temporality",
 							zap.String("metric name", md.Name()),
@@ -526,7 +742,7 @@ func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consume
 					switch md.ExponentialHistogram().AggregationTemporality() {
 					case pmetric.AggregationTemporalityDelta:
 						delta := md.ExponentialHistogram().AggregationTemporality() == pmetric.AggregationTemporalityDelta
-						t.mapExponentialHistogramMetrics(ctx, consumer, baseDims, md.ExponentialHistogram().DataPoints(), delta)
+						t.mapExponentialHistogramMetrics(ctx, histConsumer, baseDims, md.ExponentialHistogram().DataPoints(), delta)
 					default: // pmetric.AggregationTemporalityCumulative, pmetric.AggregationTemporalityUnspecified or any other not supported type
 						t.logger.Debug("Unknown or unsupported aggregation temporality",
 							zap.String("metric name", md.Name()),
@@ -540,8 +756,33 @@ func (t *Translator) MapMetrics(ctx context.Context, md pmetric.Metrics, consume
 					t.logger.Debug("Unknown or unsupported metric type", zap.String(metricName, md.Name()), zap.Any("data type", md.Type()))
 					continue
 				}
+
+				if dataPointsLen(md) == 0 {
+					t.drop(md.Name(), DropReasonEmpty, 1)
+				}
 			}
 		}
 	}
+
+	if merger != nil {
+		merger.flush(ctx)
+	}
+	if coalescer != nil {
+		coalescer.flush(ctx)
+	}
 	return nil
 }
+
+// MapMetric maps a single OTLP metric, from the given resource and scope, into
+// the DataDog format. It goes through the same mapping as MapMetrics, without
+// having to build a whole payload, e.g. to reprocess a given metric. The
+// resource has no schema URL.
+func (t *Translator) MapMetric(ctx context.Context, res pcommon.Resource, scope pcommon.InstrumentationScope, metric pmetric.Metric, consumer Consumer) error {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	res.CopyTo(rm.Resource())
+	ilm := rm.ScopeMetrics().AppendEmpty()
+	scope.CopyTo(ilm.Scope())
+	metric.CopyTo(ilm.Metrics().AppendEmpty())
+	return t.MapMetrics(ctx, md, consumer)
+}
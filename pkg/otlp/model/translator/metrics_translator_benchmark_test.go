@@ -428,3 +428,50 @@ func BenchmarkMapDeltaSumMetrics10000000(b *testing.B) {
 
 	benchmarkMapMetrics(metrics, b)
 }
+
+// createBenchmarkSinglePointGaugeMetrics creates n Gauge metrics with a single
+// data point, with the given data point attributes. The resource attributes are
+// mapped to tags of all the metrics.
+func createBenchmarkSinglePointGaugeMetrics(n int, pointAttributes map[string]string) pmetric.Metrics {
+	md := createBenchmarkGaugeMetrics(n, map[string]string{
+		"deployment.environment": "prod",
+		"service.name":           "service",
+	})
+	metricsArray := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metricsArray.Len(); i++ {
+		if metricsArray.At(i).Type() != pmetric.MetricTypeGauge {
+			continue
+		}
+		attrs := metricsArray.At(i).Gauge().DataPoints().At(0).Attributes()
+		for attr, val := range pointAttributes {
+			attrs.PutStr(attr, val)
+		}
+	}
+	return md
+}
+
+// benchmarkMapMetricsAllocs maps the metrics with the same translator, to only
+// measure the allocations of the mapping.
+func benchmarkMapMetricsAllocs(metrics pmetric.Metrics, b *testing.B) {
+	ctx := context.Background()
+	tr := newBenchmarkTranslator(b, zap.NewNop())
+	consumer := &mockFullConsumer{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		consumer.metrics = consumer.metrics[:0]
+		err := tr.MapMetrics(ctx, metrics, consumer)
+		assert.NoError(b, err)
+	}
+}
+
+func BenchmarkMapSinglePointGaugeMetrics1000(b *testing.B) {
+	benchmarkMapMetricsAllocs(createBenchmarkSinglePointGaugeMetrics(1000, nil), b)
+}
+
+func BenchmarkMapSinglePointGaugeMetricsWithAttributes1000(b *testing.B) {
+	benchmarkMapMetricsAllocs(createBenchmarkSinglePointGaugeMetrics(1000, map[string]string{
+		"point_tag": "point_value",
+	}), b)
+}
@@ -16,7 +16,9 @@ package translator
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,7 +27,10 @@ import (
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/DataDog/datadog-agent/pkg/otlp/model/attributes"
 	"github.com/DataDog/datadog-agent/pkg/otlp/model/source"
 	"github.com/DataDog/datadog-agent/pkg/quantile"
 	"github.com/DataDog/datadog-agent/pkg/quantile/summary"
@@ -186,7 +191,7 @@ func TestMapIntMetrics(t *testing.T) {
 
 	consumer := &mockTimeSeriesConsumer{}
 	dims := newDims("int64.test")
-	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newGauge(dims, uint64(ts), 17)},
@@ -194,7 +199,7 @@ func TestMapIntMetrics(t *testing.T) {
 
 	consumer = &mockTimeSeriesConsumer{}
 	dims = newDims("int64.delta.test")
-	tr.mapNumberMetrics(ctx, consumer, dims, Count, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Count, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newCount(dims, uint64(ts), 17)},
@@ -203,7 +208,7 @@ func TestMapIntMetrics(t *testing.T) {
 	// With attribute tags
 	consumer = &mockTimeSeriesConsumer{}
 	dims = &Dimensions{name: "int64.test", tags: []string{"attribute_tag:attribute_value"}}
-	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newGauge(dims, uint64(ts), 17)},
@@ -221,7 +226,7 @@ func TestMapDoubleMetrics(t *testing.T) {
 
 	consumer := &mockTimeSeriesConsumer{}
 	dims := newDims("float64.test")
-	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newGauge(dims, uint64(ts), math.Pi)},
@@ -229,7 +234,7 @@ func TestMapDoubleMetrics(t *testing.T) {
 
 	consumer = &mockTimeSeriesConsumer{}
 	dims = newDims("float64.delta.test")
-	tr.mapNumberMetrics(ctx, consumer, dims, Count, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Count, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newCount(dims, uint64(ts), math.Pi)},
@@ -238,7 +243,7 @@ func TestMapDoubleMetrics(t *testing.T) {
 	// With attribute tags
 	consumer = &mockTimeSeriesConsumer{}
 	dims = &Dimensions{name: "float64.test", tags: []string{"attribute_tag:attribute_value"}}
-	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, slice)
+	tr.mapNumberMetrics(ctx, consumer, dims, Gauge, false, slice)
 	assert.ElementsMatch(t,
 		consumer.metrics,
 		[]metric{newGauge(dims, uint64(ts), math.Pi)},
@@ -376,7 +381,7 @@ func TestMapIntMonotonicReportDiffForFirstValue(t *testing.T) {
 	dims := &Dimensions{name: exampleDims.name, host: fallbackHostname}
 	startTs := int(getProcessStartTime()) + 1
 	// Add an entry to the cache about the timeseries, in this case we send the diff (9) rather than the first value (10).
-	tr.prevPts.MonotonicDiff(dims, uint64(seconds(startTs)), uint64(seconds(startTs+1)), 1)
+	tr.prevPts.MonotonicDiff(dims, uint64(seconds(startTs)), uint64(seconds(startTs)), 1)
 	tr.MapMetrics(ctx, createTestIntCumulativeMonotonicMetrics(), consumer)
 	assert.ElementsMatch(t,
 		consumer.metrics,
@@ -388,6 +393,75 @@ func TestMapIntMonotonicReportDiffForFirstValue(t *testing.T) {
 	)
 }
 
+func TestMapIntMonotonicInitialCumulativeValue(t *testing.T) {
+	// A fresh series, which started before the Translator process
+	newMetrics := func() pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		met := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		met.SetName(exampleDims.name)
+		met.SetEmptySum()
+		met.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		met.Sum().SetIsMonotonic(true)
+		for i, val := range []int64{10, 15} {
+			dp := met.Sum().DataPoints().AppendEmpty()
+			dp.SetStartTimestamp(seconds(1))
+			dp.SetTimestamp(seconds(i + 2))
+			dp.SetIntValue(val)
+		}
+		return md
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []metric
+	}{
+		{
+			name: "default",
+			expected: []metric{
+				newCountWithHost(exampleDims, uint64(seconds(3)), 5, fallbackHostname),
+			},
+		},
+		{
+			name: "drop",
+			opts: []Option{WithInitialCumulativeValue(InitialCumulativeValueDrop)},
+			expected: []metric{
+				newCountWithHost(exampleDims, uint64(seconds(3)), 5, fallbackHostname),
+			},
+		},
+		{
+			name: "keep",
+			opts: []Option{WithInitialCumulativeValue(InitialCumulativeValueKeep)},
+			expected: []metric{
+				newCountWithHost(exampleDims, uint64(seconds(2)), 10, fallbackHostname),
+				newCountWithHost(exampleDims, uint64(seconds(3)), 5, fallbackHostname),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTranslator(t, zap.NewNop(), tt.opts...)
+			consumer := &mockFullConsumer{}
+			require.NoError(t, tr.MapMetrics(context.Background(), newMetrics(), consumer))
+			assert.ElementsMatch(t, tt.expected, consumer.metrics)
+
+			// The series is not fresh anymore, a point older than the
+			// latest one is dropped in both modes
+			consumer = &mockFullConsumer{}
+			md := newMetrics()
+			md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints().RemoveIf(
+				func(dp pmetric.NumberDataPoint) bool { return dp.Timestamp() == seconds(3) },
+			)
+			require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+			assert.Empty(t, consumer.metrics)
+		})
+	}
+
+	_, err := New(zap.NewNop(), WithInitialCumulativeValue("sometimes"))
+	assert.Error(t, err)
+}
+
 func TestMapIntMonotonicOutOfOrder(t *testing.T) {
 	stamps := []int{1, 0, 2, 3}
 	values := []int64{0, 1, 2, 3}
@@ -552,7 +626,7 @@ func TestMapDoubleMonotonicReportDiffForFirstValue(t *testing.T) {
 	dims := &Dimensions{name: exampleDims.name, host: fallbackHostname}
 	startTs := int(getProcessStartTime()) + 1
 	// Add an entry to the cache about the timeseries, in this case we send the diff (9) rather than the first value (10).
-	tr.prevPts.MonotonicDiff(dims, uint64(seconds(startTs)), uint64(seconds(startTs+1)), 1)
+	tr.prevPts.MonotonicDiff(dims, uint64(seconds(startTs)), uint64(seconds(startTs)), 1)
 	tr.MapMetrics(ctx, createTestDoubleCumulativeMonotonicMetrics(), consumer)
 	assert.ElementsMatch(t,
 		consumer.metrics,
@@ -643,6 +717,543 @@ func TestLegacyBucketsTags(t *testing.T) {
 	assert.ElementsMatch(t, seriesTwo[0].tags, []string{"lower_bound:-inf", "upper_bound:1.0"})
 }
 
+func createTestMetricsWithNames() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metricsArray := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+
+	met := metricsArray.AppendEmpty()
+	met.SetName("test.gauge")
+	dp := met.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(1)
+	dp.SetTimestamp(seconds(1))
+
+	met = metricsArray.AppendEmpty()
+	met.SetName("test.histogram")
+	met.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	hp := met.Histogram().DataPoints().AppendEmpty()
+	hp.SetCount(2)
+	hp.SetSum(3)
+	hp.SetTimestamp(seconds(1))
+
+	return md
+}
+
+func TestMapMetricsOriginalMetricNameAsTag(t *testing.T) {
+	ctx := context.Background()
+
+	tr := newTranslator(t, zap.NewNop(), WithHistogramMode(HistogramModeNoBuckets), WithCountSumMetrics())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestMetricsWithNames(), consumer))
+	require.Len(t, consumer.metrics, 3)
+	for _, m := range consumer.metrics {
+		assert.Empty(t, m.tags, m.name)
+	}
+
+	tr = newTranslator(t, zap.NewNop(), WithHistogramMode(HistogramModeNoBuckets), WithCountSumMetrics(), WithOriginalMetricNameAsTag())
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestMetricsWithNames(), consumer))
+	tagsByName := make(map[string][]string)
+	for _, m := range consumer.metrics {
+		tagsByName[m.name] = m.tags
+	}
+	assert.Equal(t, map[string][]string{
+		"test.gauge":           {"otel_metric_name:test.gauge"},
+		"test.histogram.count": {"otel_metric_name:test.histogram"},
+		"test.histogram.sum":   {"otel_metric_name:test.histogram"},
+	}, tagsByName)
+}
+
+func createTestNumberMetrics(name string, values ...float64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metricsArray := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+
+	gauge := metricsArray.AppendEmpty()
+	gauge.SetName(name + ".gauge")
+	gauge.SetEmptyGauge()
+
+	cumulative := metricsArray.AppendEmpty()
+	cumulative.SetName(name + ".cumulative")
+	cumulative.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	cumulative.Sum().SetIsMonotonic(true)
+
+	delta := metricsArray.AppendEmpty()
+	delta.SetName(name + ".delta")
+	delta.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+
+	for i, val := range values {
+		for _, dps := range []pmetric.NumberDataPointSlice{gauge.Gauge().DataPoints(), cumulative.Sum().DataPoints(), delta.Sum().DataPoints()} {
+			dp := dps.AppendEmpty()
+			dp.SetDoubleValue(val)
+			dp.SetTimestamp(seconds(i + 1))
+		}
+	}
+
+	return md
+}
+
+func TestMapMetricsTypeOverrides(t *testing.T) {
+	ctx := context.Background()
+	tr := newTranslator(t, zap.NewNop(), WithMetricTypeOverrides(map[string]MetricDataType{
+		"overridden.gauge":      Count,
+		"overridden.cumulative": Gauge,
+		"overridden.delta":      Gauge,
+		"unknown.metric":        Count,
+	}))
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestNumberMetrics("overridden", 10, 15, 22), consumer))
+	require.NoError(t, tr.MapMetrics(ctx, createTestNumberMetrics("default", 10, 15, 22), consumer))
+
+	withHost := func(m metric) metric {
+		m.host = fallbackHostname
+		return m
+	}
+	assert.ElementsMatch(t, []metric{
+		// The gauge overridden to a count is reported as the difference
+		// between its consecutive values
+		withHost(newCount(newDims("overridden.gauge"), uint64(seconds(2)), 5)),
+		withHost(newCount(newDims("overridden.gauge"), uint64(seconds(3)), 7)),
+		withHost(newGauge(newDims("overridden.cumulative"), uint64(seconds(1)), 10)),
+		withHost(newGauge(newDims("overridden.cumulative"), uint64(seconds(2)), 15)),
+		withHost(newGauge(newDims("overridden.cumulative"), uint64(seconds(3)), 22)),
+		withHost(newGauge(newDims("overridden.delta"), uint64(seconds(1)), 10)),
+		withHost(newGauge(newDims("overridden.delta"), uint64(seconds(2)), 15)),
+		withHost(newGauge(newDims("overridden.delta"), uint64(seconds(3)), 22)),
+
+		withHost(newGauge(newDims("default.gauge"), uint64(seconds(1)), 10)),
+		withHost(newGauge(newDims("default.gauge"), uint64(seconds(2)), 15)),
+		withHost(newGauge(newDims("default.gauge"), uint64(seconds(3)), 22)),
+		withHost(newCount(newDims("default.cumulative"), uint64(seconds(2)), 5)),
+		withHost(newCount(newDims("default.cumulative"), uint64(seconds(3)), 7)),
+		withHost(newCount(newDims("default.delta"), uint64(seconds(1)), 10)),
+		withHost(newCount(newDims("default.delta"), uint64(seconds(2)), 15)),
+		withHost(newCount(newDims("default.delta"), uint64(seconds(3)), 22)),
+	}, consumer.metrics)
+
+	_, err := New(zap.NewNop(), WithMetricTypeOverrides(map[string]MetricDataType{"invalid": MetricDataType(42)}))
+	assert.Error(t, err)
+}
+
+func TestLegacyBucketsTagsFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		expected [][]string
+	}{
+		{
+			name: "default",
+			expected: [][]string{
+				{"lower_bound:-inf", "upper_bound:-0.25"},
+				{"lower_bound:-0.25", "upper_bound:0"},
+				{"lower_bound:0", "upper_bound:1.5e+06.0"},
+				{"lower_bound:1.5e+06.0", "upper_bound:inf"},
+			},
+		},
+		{
+			name: "tag keys",
+			options: []Option{
+				WithHistogramBucketBoundTagKeys("le_min", "le"),
+			},
+			expected: [][]string{
+				{"le_min:-inf", "le:-0.25"},
+				{"le_min:-0.25", "le:0"},
+				{"le_min:0", "le:1.5e+06.0"},
+				{"le_min:1.5e+06.0", "le:inf"},
+			},
+		},
+		{
+			name: "fixed",
+			options: []Option{
+				WithHistogramBucketBoundFormat(BucketBoundFormatFixed, -1),
+			},
+			expected: [][]string{
+				{"lower_bound:-inf", "upper_bound:-0.25"},
+				{"lower_bound:-0.25", "upper_bound:0"},
+				{"lower_bound:0", "upper_bound:1500000"},
+				{"lower_bound:1500000", "upper_bound:inf"},
+			},
+		},
+		{
+			name: "fixed with precision",
+			options: []Option{
+				WithHistogramBucketBoundFormat(BucketBoundFormatFixed, 1),
+			},
+			expected: [][]string{
+				{"lower_bound:-inf", "upper_bound:-0.2"},
+				{"lower_bound:-0.2", "upper_bound:0.0"},
+				{"lower_bound:0.0", "upper_bound:1500000.0"},
+				{"lower_bound:1500000.0", "upper_bound:inf"},
+			},
+		},
+		{
+			name: "scientific with precision",
+			options: []Option{
+				WithHistogramBucketBoundFormat(BucketBoundFormatScientific, 2),
+			},
+			expected: [][]string{
+				{"lower_bound:-inf", "upper_bound:-2.50e-01"},
+				{"lower_bound:-2.50e-01", "upper_bound:0.00e+00"},
+				{"lower_bound:0.00e+00", "upper_bound:1.50e+06"},
+				{"lower_bound:1.50e+06", "upper_bound:inf"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := newTranslator(t, zap.NewNop(), test.options...)
+
+			point := pmetric.NewHistogramDataPoint()
+			point.BucketCounts().FromRaw([]uint64{1, 2, 3, 4})
+			point.ExplicitBounds().FromRaw([]float64{-0.25, 0, 1.5e6})
+			point.SetTimestamp(seconds(0))
+			consumer := &mockTimeSeriesConsumer{}
+			tr.getLegacyBuckets(context.Background(), consumer, newDims("test.histogram"), point, true)
+
+			require.Len(t, consumer.metrics, len(test.expected))
+			for i, expected := range test.expected {
+				assert.ElementsMatch(t, expected, consumer.metrics[i].tags)
+			}
+		})
+	}
+}
+
+func TestHistogramBucketBoundOptionsValidation(t *testing.T) {
+	for _, opt := range []Option{
+		WithHistogramBucketBoundTagKeys("", "upper_bound"),
+		WithHistogramBucketBoundTagKeys("bound", "bound"),
+		WithHistogramBucketBoundFormat(BucketBoundFormatDefault, 2),
+		WithHistogramBucketBoundFormat(BucketBoundFormatFixed, -2),
+		WithHistogramBucketBoundFormat("hexadecimal", -1),
+	} {
+		_, err := New(zap.NewNop(), opt)
+		assert.Error(t, err)
+	}
+}
+
+func createTestHeartbeatMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	for _, host := range []string{"host-1", "host-2"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(attributes.AttributeDatadogHostname, host)
+		rm.Resource().Attributes().PutStr("deployment.environment", "prod")
+		metricsArray := rm.ScopeMetrics().AppendEmpty().Metrics()
+		for _, name := range []string{"test.gauge.1", "test.gauge.2"} {
+			met := metricsArray.AppendEmpty()
+			met.SetName(name)
+			met.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+		}
+	}
+	return md
+}
+
+func TestMapMetricsResourceHeartbeat(t *testing.T) {
+	ctx := context.Background()
+
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestHeartbeatMetrics(), consumer))
+	require.Len(t, consumer.metrics, 4)
+	for _, m := range consumer.metrics {
+		assert.NotEqual(t, DefaultHeartbeatMetricName, m.name)
+	}
+
+	tr = newTranslator(t, zap.NewNop(), WithResourceHeartbeat(DefaultHeartbeatMetricName))
+	for i := 0; i < 2; i++ {
+		consumer = &mockFullConsumer{}
+		require.NoError(t, tr.MapMetrics(ctx, createTestHeartbeatMetrics(), consumer))
+		require.Len(t, consumer.metrics, 6)
+
+		// One heartbeat per resource, on every call
+		var heartbeats []metric
+		for _, m := range consumer.metrics {
+			if m.name == DefaultHeartbeatMetricName {
+				heartbeats = append(heartbeats, m)
+			}
+		}
+		require.Len(t, heartbeats, 2)
+		for j, host := range []string{"host-1", "host-2"} {
+			assert.Equal(t, Gauge, heartbeats[j].typ)
+			assert.Equal(t, 1.0, heartbeats[j].value)
+			assert.Equal(t, host, heartbeats[j].host)
+			assert.Equal(t, []string{"env:prod"}, heartbeats[j].tags)
+		}
+	}
+
+	tr = newTranslator(t, zap.NewNop(), WithResourceHeartbeat("custom.up"))
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, createTestHeartbeatMetrics(), consumer))
+	assert.Equal(t, "custom.up", consumer.metrics[0].name)
+
+	_, err := New(zap.NewNop(), WithResourceHeartbeat(""))
+	assert.Error(t, err)
+}
+
+func TestMapMetricsResourceAttributesAsGauges(t *testing.T) {
+	ctx := context.Background()
+
+	md := createTestHeartbeatMetrics()
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		attrs := md.ResourceMetrics().At(i).Resource().Attributes()
+		attrs.PutInt("process.pid", int64(100+i))
+		attrs.PutDouble("host.cpu.cache.l2.size", 1.5)
+	}
+
+	tr := newTranslator(t, zap.NewNop(), WithResourceAttributesAsGauges("otel.resource.", "process.pid", "host.cpu.cache.l2.size", "missing"))
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	require.Len(t, consumer.metrics, 8)
+
+	var gauges []metric
+	for _, m := range consumer.metrics {
+		if strings.HasPrefix(m.name, "otel.resource.") {
+			gauges = append(gauges, m)
+		}
+	}
+	require.Len(t, gauges, 4)
+	for i, host := range []string{"host-1", "host-2"} {
+		pid, size := gauges[2*i], gauges[2*i+1]
+		assert.Equal(t, "otel.resource.process.pid", pid.name)
+		assert.Equal(t, float64(100+i), pid.value)
+		assert.Equal(t, "otel.resource.host.cpu.cache.l2.size", size.name)
+		assert.Equal(t, 1.5, size.value)
+		for _, m := range []metric{pid, size} {
+			assert.Equal(t, Gauge, m.typ)
+			assert.Equal(t, host, m.host)
+			assert.Equal(t, []string{"env:prod"}, m.tags)
+		}
+	}
+
+	_, err := New(zap.NewNop(), WithResourceAttributesAsGauges("", "process.pid"))
+	assert.Error(t, err)
+	_, err = New(zap.NewNop(), WithResourceAttributesAsGauges("otel.resource."))
+	assert.Error(t, err)
+}
+
+func TestMapMetricsResourceAttributesAsGaugesSkipsNonNumeric(t *testing.T) {
+	ctx := context.Background()
+
+	core, observed := observer.New(zapcore.DebugLevel)
+	tr := newTranslator(t, zap.New(core), WithResourceAttributesAsGauges("otel.resource.", "process.owner", "process.pid"))
+
+	md := createTestHeartbeatMetrics()
+	attrs := md.ResourceMetrics().At(0).Resource().Attributes()
+	attrs.PutStr("process.owner", "dd-agent")
+	attrs.PutBool("process.pid", true)
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	require.Len(t, consumer.metrics, 4)
+	for _, m := range consumer.metrics {
+		assert.False(t, strings.HasPrefix(m.name, "otel.resource."), m.name)
+	}
+
+	logs := observed.FilterMessage("Skipping non-numeric resource attribute").All()
+	require.Len(t, logs, 2)
+	assert.Equal(t, "process.owner", logs[0].ContextMap()["key"])
+	assert.Equal(t, "process.pid", logs[1].ContextMap()["key"])
+}
+
+// mockCountConsumer records the monotonicity hints of the counts, by name.
+type mockCountConsumer struct {
+	mockFullConsumer
+	monotonic map[string]bool
+}
+
+func (c *mockCountConsumer) ConsumeCount(_ context.Context, dimensions *Dimensions, _ uint64, _ float64, monotonic bool) {
+	c.monotonic[dimensions.Name()] = monotonic
+}
+
+func TestMapMetricsCountMonotonicityHints(t *testing.T) {
+	ctx := context.Background()
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, monotonic := range []bool{true, false} {
+		met := metrics.AppendEmpty()
+		met.SetName(fmt.Sprintf("test.delta.monotonic.%t", monotonic))
+		met.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		met.Sum().SetIsMonotonic(monotonic)
+		met.Sum().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	hist := metrics.AppendEmpty()
+	hist.SetName("test.histogram")
+	hist.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	point := hist.Histogram().DataPoints().AppendEmpty()
+	point.SetCount(2)
+	point.SetSum(3)
+
+	for _, merging := range []bool{false, true} {
+		opts := []Option{WithCountMonotonicityHints(), WithCountSumMetrics()}
+		if merging {
+			opts = append(opts, WithHistogramSketchMerging())
+		}
+		tr := newTranslator(t, zap.NewNop(), opts...)
+		consumer := &mockCountConsumer{monotonic: make(map[string]bool)}
+		require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+		assert.Equal(t, map[string]bool{
+			"test.delta.monotonic.true":  true,
+			"test.delta.monotonic.false": false,
+			"test.histogram.count":       true,
+			"test.histogram.sum":         true,
+		}, consumer.monotonic, "merging: %t", merging)
+		assert.Empty(t, consumer.metrics)
+	}
+
+	// Without the hints, counts are consumed as time series
+	tr := newTranslator(t, zap.NewNop(), WithCountSumMetrics())
+	consumer := &mockCountConsumer{monotonic: make(map[string]bool)}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	assert.Empty(t, consumer.monotonic)
+	require.Len(t, consumer.metrics, 4)
+	for _, m := range consumer.metrics {
+		assert.Equal(t, Count, m.typ)
+	}
+}
+
+func TestMapMetricsGaugeCoalescing(t *testing.T) {
+	ctx := context.Background()
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	// the same gauge from two scopes, with another series
+	for _, points := range [][]struct {
+		ts    int
+		value float64
+		tag   string
+	}{
+		{{2, 20, "a"}, {1, 10, "a"}, {2, 5, "b"}},
+		{{3, 30, "a"}, {2, 25, "a"}, {3, 35, "a"}},
+	} {
+		met := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		met.SetName("test.gauge")
+		dps := met.SetEmptyGauge().DataPoints()
+		for _, point := range points {
+			dp := dps.AppendEmpty()
+			dp.SetTimestamp(seconds(point.ts))
+			dp.SetDoubleValue(point.value)
+			dp.Attributes().PutStr("tag", point.tag)
+		}
+	}
+	sum := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	sum.SetName("test.sum")
+	sum.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	for i, value := range []int64{4, 3} {
+		dp := sum.Sum().DataPoints().AppendEmpty()
+		dp.SetTimestamp(seconds(2 - i))
+		dp.SetIntValue(value)
+	}
+
+	tr := newTranslator(t, zap.NewNop(), WithGaugeCoalescing())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	assert.Equal(t, []metric{
+		{name: "test.gauge", typ: Gauge, timestamp: uint64(seconds(3)), value: 35, tags: []string{"tag:a"}, host: fallbackHostname},
+		{name: "test.gauge", typ: Gauge, timestamp: uint64(seconds(2)), value: 5, tags: []string{"tag:b"}, host: fallbackHostname},
+		{name: "test.sum", typ: Gauge, timestamp: uint64(seconds(2)), value: 4, tags: []string{}, host: fallbackHostname},
+	}, consumer.metrics)
+
+	// Without the option, every point is consumed
+	tr = newTranslator(t, zap.NewNop())
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	assert.Len(t, consumer.metrics, 8)
+}
+
+func TestMapMetricsSchemaURLAsTag(t *testing.T) {
+	ctx := context.Background()
+	md := pmetric.NewMetrics()
+	for _, schemaURL := range []string{"https://opentelemetry.io/schemas/1.6.1", ""} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.SetSchemaUrl(schemaURL)
+		met := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		met.SetName("test.gauge")
+		met.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	require.Len(t, consumer.metrics, 2)
+	for _, m := range consumer.metrics {
+		assert.Empty(t, m.tags)
+	}
+
+	tr = newTranslator(t, zap.NewNop(), WithSchemaURLAsTag())
+	consumer = &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(ctx, md, consumer))
+	require.Len(t, consumer.metrics, 2)
+	assert.Equal(t, []string{"otel_schema_url:https://opentelemetry.io/schemas/1.6.1"}, consumer.metrics[0].tags)
+	assert.Empty(t, consumer.metrics[1].tags)
+}
+
+func TestMapMetric(t *testing.T) {
+	ctx := context.Background()
+	res := pcommon.NewResource()
+	res.Attributes().PutStr("host.name", "test-host")
+	res.Attributes().PutStr("deployment.environment", "prod")
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName("test-scope")
+	scope.SetVersion("1.0")
+	tags := []string{"env:prod", "instrumentation_scope:test-scope", "instrumentation_scope_version:1.0"}
+
+	tr := newTranslator(t, zap.NewNop(), WithInstrumentationScopeMetadataAsTags())
+
+	t.Run("gauge", func(t *testing.T) {
+		md := pmetric.NewMetric()
+		md.SetName("test.gauge")
+		p := md.SetEmptyGauge().DataPoints().AppendEmpty()
+		p.SetDoubleValue(1.5)
+		p.SetTimestamp(seconds(1))
+
+		consumer := &mockFullConsumer{}
+		require.NoError(t, tr.MapMetric(ctx, res, scope, md, consumer))
+		assert.Equal(t, []metric{
+			{name: "test.gauge", typ: Gauge, timestamp: uint64(seconds(1)), value: 1.5, tags: tags, host: "test-host"},
+		}, consumer.metrics)
+	})
+
+	t.Run("sum", func(t *testing.T) {
+		md := pmetric.NewMetric()
+		md.SetName("test.sum")
+		sum := md.SetEmptySum()
+		sum.SetIsMonotonic(true)
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		p := sum.DataPoints().AppendEmpty()
+		p.SetIntValue(10)
+		p.SetStartTimestamp(seconds(0))
+		p.SetTimestamp(seconds(1))
+
+		// The state of the cumulative metrics is kept between calls
+		consumer := &mockFullConsumer{}
+		require.NoError(t, tr.MapMetric(ctx, res, scope, md, consumer))
+		assert.Empty(t, consumer.metrics)
+
+		p.SetIntValue(15)
+		p.SetTimestamp(seconds(2))
+		require.NoError(t, tr.MapMetric(ctx, res, scope, md, consumer))
+		assert.Equal(t, []metric{
+			{name: "test.sum", typ: Count, timestamp: uint64(seconds(2)), value: 5, tags: tags, host: "test-host"},
+		}, consumer.metrics)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		md := pmetric.NewMetric()
+		md.SetName("test.histogram")
+		hist := md.SetEmptyHistogram()
+		hist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		newHistogramPoints([]uint64{1, 2, 1}).CopyTo(hist.DataPoints())
+
+		consumer := &mockFullConsumer{}
+		require.NoError(t, tr.MapMetric(ctx, res, scope, md, consumer))
+		assert.Empty(t, consumer.metrics)
+		require.Len(t, consumer.sketches, 1)
+		assert.Equal(t, "test.histogram", consumer.sketches[0].name)
+		assert.Equal(t, tags, consumer.sketches[0].tags)
+		assert.Equal(t, "test-host", consumer.sketches[0].host)
+		assert.Equal(t, uint64(seconds(1)), consumer.sketches[0].timestamp)
+		assert.Equal(t, int64(4), consumer.sketches[0].basic.Cnt)
+	})
+}
+
 func TestFormatFloat(t *testing.T) {
 	tests := []struct {
 		f float64
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Origin is the source of a metric, used for cost attribution.
+type Origin struct {
+	// Product is the product sending the metric, always "otlp" for the
+	// default rules.
+	Product string
+	// Category is the kind of source within the product, e.g. "sdk".
+	Category string
+	// Service is the source itself, e.g. the language of the SDK.
+	Service string
+}
+
+// OriginRule maps the OTLP metrics with a resource or scope attribute to
+// their Origin.
+type OriginRule struct {
+	// Attribute is the key of the resource or scope attribute.
+	Attribute string
+	// Value is the value of the attribute. An empty value matches any value.
+	Value string
+	// Origin is the origin of the metrics matching the rule.
+	Origin Origin
+}
+
+const (
+	originProductOTLP = "otlp"
+	sdkLanguageKey    = "telemetry.sdk.language"
+)
+
+// unknownOrigin is the origin of the metrics matching no rule.
+var unknownOrigin = Origin{Product: originProductOTLP, Category: "unknown", Service: "unknown"}
+
+// defaultOriginRules map the languages of the OpenTelemetry SDKs, see
+// https://opentelemetry.io/docs/reference/specification/resource/semantic_conventions/#telemetry-sdk
+var defaultOriginRules = func() []OriginRule {
+	var rules []OriginRule
+	for _, language := range []string{
+		"cpp", "dotnet", "erlang", "go", "java", "nodejs",
+		"php", "python", "ruby", "rust", "swift", "webjs",
+	} {
+		rules = append(rules, OriginRule{
+			Attribute: sdkLanguageKey,
+			Value:     language,
+			Origin:    Origin{Product: originProductOTLP, Category: "sdk", Service: language},
+		})
+	}
+	// SDKs in other languages
+	return append(rules, OriginRule{
+		Attribute: sdkLanguageKey,
+		Origin:    Origin{Product: originProductOTLP, Category: "sdk", Service: "other"},
+	})
+}()
+
+// matches returns whether the attributes match the rule.
+func (r OriginRule) matches(attrs pcommon.Map) bool {
+	v, ok := attrs.Get(r.Attribute)
+	return ok && (r.Value == "" || v.AsString() == r.Value)
+}
+
+// origin returns the origin of the metrics of the resource and scope. The
+// rules of WithOriginRules are tried before the default ones, each set on the
+// resource attributes, then on the scope attributes.
+func (t *Translator) origin(res pcommon.Resource, scope pcommon.InstrumentationScope) Origin {
+	for _, rules := range [][]OriginRule{t.cfg.originRules, defaultOriginRules} {
+		for _, attrs := range []pcommon.Map{res.Attributes(), scope.Attributes()} {
+			for _, rule := range rules {
+				if rule.matches(attrs) {
+					return rule.Origin
+				}
+			}
+		}
+	}
+	return unknownOrigin
+}
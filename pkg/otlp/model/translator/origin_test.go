@@ -0,0 +1,116 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+type mockOriginConsumer struct {
+	mockFullConsumer
+	origins []Origin
+}
+
+func (c *mockOriginConsumer) ConsumeOrigin(product, category, service string) {
+	c.origins = append(c.origins, Origin{Product: product, Category: category, Service: service})
+}
+
+// newOriginMetrics returns metrics with two gauges for a resource with the
+// given attributes.
+func newOriginMetrics(attrs map[string]string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	for k, v := range attrs {
+		rm.Resource().Attributes().PutStr(k, v)
+	}
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range []string{"test.gauge.1", "test.gauge.2"} {
+		met := metrics.AppendEmpty()
+		met.SetName(name)
+		met.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	return md
+}
+
+func TestMapMetricsOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		expected Origin
+	}{
+		{
+			name:     "go",
+			attrs:    map[string]string{"telemetry.sdk.language": "go", "telemetry.sdk.name": "opentelemetry"},
+			expected: Origin{Product: "otlp", Category: "sdk", Service: "go"},
+		},
+		{
+			name:     "java",
+			attrs:    map[string]string{"telemetry.sdk.language": "java"},
+			expected: Origin{Product: "otlp", Category: "sdk", Service: "java"},
+		},
+		{
+			name:     "python",
+			attrs:    map[string]string{"telemetry.sdk.language": "python"},
+			expected: Origin{Product: "otlp", Category: "sdk", Service: "python"},
+		},
+		{
+			name:     "unknown language",
+			attrs:    map[string]string{"telemetry.sdk.language": "cobol"},
+			expected: Origin{Product: "otlp", Category: "sdk", Service: "other"},
+		},
+		{
+			name:     "no sdk",
+			attrs:    map[string]string{"service.name": "checkout"},
+			expected: Origin{Product: "otlp", Category: "unknown", Service: "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTranslator(t, zap.NewNop())
+			consumer := &mockOriginConsumer{}
+			require.NoError(t, tr.MapMetrics(context.Background(), newOriginMetrics(tt.attrs), consumer))
+
+			// once per metric
+			assert.Equal(t, []Origin{tt.expected, tt.expected}, consumer.origins)
+		})
+	}
+}
+
+func TestMapMetricsOriginRules(t *testing.T) {
+	collectorOrigin := Origin{Product: "otlp", Category: "collector", Service: "hostmetrics"}
+	tr := newTranslator(t, zap.NewNop(), WithOriginRules(OriginRule{
+		Attribute: "otelcol.receiver",
+		Value:     "hostmetrics",
+		Origin:    collectorOrigin,
+	}))
+
+	// The rules are matched in the scope attributes too
+	md := newOriginMetrics(map[string]string{"telemetry.sdk.language": "go"})
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Scope().Attributes().PutStr("otelcol.receiver", "hostmetrics")
+
+	consumer := &mockOriginConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+	assert.Equal(t, []Origin{collectorOrigin, collectorOrigin}, consumer.origins)
+
+	_, err := New(zap.NewNop(), WithOriginRules(OriginRule{Value: "hostmetrics"}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+)
+
+// resourceMetadataKeys are the resource attributes forwarded to a
+// ResourceMetadataConsumer. They describe where the resource runs and have a
+// low cardinality, unlike per-process or per-instance attributes.
+var resourceMetadataKeys = []string{
+	conventions.AttributeCloudProvider,
+	conventions.AttributeCloudPlatform,
+	conventions.AttributeCloudRegion,
+	conventions.AttributeCloudAvailabilityZone,
+	conventions.AttributeCloudAccountID,
+	conventions.AttributeK8SClusterName,
+	conventions.AttributeDeploymentEnvironment,
+	conventions.AttributeHostType,
+	conventions.AttributeOSType,
+}
+
+// resourceMetadata returns the non-empty values of the resourceMetadataKeys
+// attributes of a resource.
+func resourceMetadata(attrs pcommon.Map) map[string]string {
+	metadata := make(map[string]string)
+	for _, key := range resourceMetadataKeys {
+		if value, ok := attrs.Get(key); ok {
+			if str := value.AsString(); str != "" {
+				metadata[key] = str
+			}
+		}
+	}
+
+	return metadata
+}
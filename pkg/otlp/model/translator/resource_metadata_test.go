@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+	"go.uber.org/zap"
+)
+
+var _ ResourceMetadataConsumer = (*mockResourceMetadataConsumer)(nil)
+
+type mockResourceMetadataConsumer struct {
+	mockFullConsumer
+	metadata []map[string]string
+}
+
+func (c *mockResourceMetadataConsumer) ConsumeResourceMetadata(metadata map[string]string) {
+	c.metadata = append(c.metadata, metadata)
+}
+
+func appendResourceMetrics(t *testing.T, md pmetric.Metrics, attributes map[string]any) {
+	rm := md.ResourceMetrics().AppendEmpty()
+	require.NoError(t, rm.Resource().Attributes().FromRaw(attributes))
+
+	met := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	met.SetName("test.gauge")
+	dp := met.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(1)
+	dp.SetTimestamp(seconds(0))
+}
+
+func TestConsumeResourceMetadata(t *testing.T) {
+	md := pmetric.NewMetrics()
+	appendResourceMetrics(t, md, map[string]any{
+		conventions.AttributeCloudProvider:         conventions.AttributeCloudProviderAWS,
+		conventions.AttributeCloudPlatform:         conventions.AttributeCloudPlatformAWSEKS,
+		conventions.AttributeCloudRegion:           "us-east-1",
+		conventions.AttributeCloudAvailabilityZone: "us-east-1a",
+		conventions.AttributeK8SClusterName:        "my-cluster",
+		conventions.AttributeDeploymentEnvironment: "prod",
+		// Noisy attributes, not forwarded
+		conventions.AttributeHostName:           "my-host",
+		conventions.AttributeHostID:             "i-0123456789",
+		conventions.AttributeContainerID:        "3c4d5e6f",
+		conventions.AttributeProcessPID:         1234,
+		conventions.AttributeProcessCommandLine: "/usr/bin/app --password=secret",
+		conventions.AttributeK8SPodUID:          "f2b8c1a0",
+	})
+	// No curated attribute: not consumed
+	appendResourceMetrics(t, md, map[string]any{
+		conventions.AttributeHostName:   "other-host",
+		conventions.AttributeProcessPID: 5678,
+	})
+	appendResourceMetrics(t, md, map[string]any{
+		conventions.AttributeCloudProvider: conventions.AttributeCloudProviderGCP,
+		conventions.AttributeCloudRegion:   "",
+	})
+
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockResourceMetadataConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	assert.Equal(t, []map[string]string{
+		{
+			conventions.AttributeCloudProvider:         "aws",
+			conventions.AttributeCloudPlatform:         "aws_eks",
+			conventions.AttributeCloudRegion:           "us-east-1",
+			conventions.AttributeCloudAvailabilityZone: "us-east-1a",
+			conventions.AttributeK8SClusterName:        "my-cluster",
+			conventions.AttributeDeploymentEnvironment: "prod",
+		},
+		{
+			conventions.AttributeCloudProvider: "gcp",
+		},
+	}, consumer.metadata)
+
+	// The metrics are mapped as usual
+	assert.Len(t, consumer.metrics, 3)
+}
+
+func TestConsumeResourceMetadataNotImplemented(t *testing.T) {
+	md := pmetric.NewMetrics()
+	appendResourceMetrics(t, md, map[string]any{
+		conventions.AttributeCloudProvider: conventions.AttributeCloudProviderAWS,
+	})
+
+	// Consumers not implementing ResourceMetadataConsumer are unaffected
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+	assert.Len(t, consumer.metrics, 1)
+}
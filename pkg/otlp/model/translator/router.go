@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// RouteRule routes the metrics whose name matches a pattern to a Consumer, see
+// NewRouter.
+type RouteRule struct {
+	// Pattern is a glob pattern of metric names, with the syntax of
+	// path.Match, e.g. "container.*".
+	Pattern string
+	// Consumer consumes the metrics whose name matches the pattern.
+	Consumer Consumer
+}
+
+// Router is a Consumer dispatching each metric, by name, to the Consumer of
+// the first rule whose pattern matches, or to the default Consumer otherwise,
+// e.g. to send some high cardinality metrics to a sampling Consumer. The APM
+// stats, and the calls of the optional interfaces not tied to a metric name
+// (HostConsumer, TagsConsumer, ResourceMetadataConsumer and OriginConsumer),
+// go to the default Consumer, when it implements them.
+type Router struct {
+	rules           []RouteRule
+	defaultConsumer Consumer
+}
+
+var (
+	_ Consumer                 = (*Router)(nil)
+	_ HostConsumer             = (*Router)(nil)
+	_ TagsConsumer             = (*Router)(nil)
+	_ ResourceMetadataConsumer = (*Router)(nil)
+	_ OriginConsumer           = (*Router)(nil)
+	_ CountConsumer            = (*Router)(nil)
+)
+
+// NewRouter returns a Router dispatching the metrics with the given rules, in
+// order, and to defaultConsumer when none matches.
+func NewRouter(defaultConsumer Consumer, rules ...RouteRule) (*Router, error) {
+	if defaultConsumer == nil {
+		return nil, errors.New("the default consumer of the router must not be nil")
+	}
+	for _, rule := range rules {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid route pattern %q: %w", rule.Pattern, err)
+		}
+		if rule.Consumer == nil {
+			return nil, fmt.Errorf("the consumer of route pattern %q must not be nil", rule.Pattern)
+		}
+	}
+	return &Router{rules: rules, defaultConsumer: defaultConsumer}, nil
+}
+
+// route returns the Consumer of the metric with the given name.
+func (r *Router) route(name string) Consumer {
+	for _, rule := range r.rules {
+		// the patterns are validated in NewRouter
+		if matched, _ := path.Match(rule.Pattern, name); matched {
+			return rule.Consumer
+		}
+	}
+	return r.defaultConsumer
+}
+
+// ConsumeTimeSeries implements TimeSeriesConsumer.
+func (r *Router) ConsumeTimeSeries(ctx context.Context, dimensions *Dimensions, typ MetricDataType, timestamp uint64, value float64) {
+	r.route(dimensions.Name()).ConsumeTimeSeries(ctx, dimensions, typ, timestamp, value)
+}
+
+// ConsumeSketch implements SketchConsumer.
+func (r *Router) ConsumeSketch(ctx context.Context, dimensions *Dimensions, timestamp uint64, sketch *quantile.Sketch) {
+	r.route(dimensions.Name()).ConsumeSketch(ctx, dimensions, timestamp, sketch)
+}
+
+// ConsumeCount implements CountConsumer. The count is consumed as a time
+// series when the Consumer it's routed to doesn't implement CountConsumer.
+func (r *Router) ConsumeCount(ctx context.Context, dimensions *Dimensions, timestamp uint64, value float64, monotonic bool) {
+	consumer := r.route(dimensions.Name())
+	if countConsumer, ok := consumer.(CountConsumer); ok {
+		countConsumer.ConsumeCount(ctx, dimensions, timestamp, value, monotonic)
+		return
+	}
+	consumer.ConsumeTimeSeries(ctx, dimensions, Count, timestamp, value)
+}
+
+// ConsumeAPMStats implements APMStatsConsumer.
+func (r *Router) ConsumeAPMStats(stats pb.ClientStatsPayload) {
+	r.defaultConsumer.ConsumeAPMStats(stats)
+}
+
+// ConsumeHost implements HostConsumer.
+func (r *Router) ConsumeHost(host string) {
+	if c, ok := r.defaultConsumer.(HostConsumer); ok {
+		c.ConsumeHost(host)
+	}
+}
+
+// ConsumeTag implements TagsConsumer.
+func (r *Router) ConsumeTag(tag string) {
+	if c, ok := r.defaultConsumer.(TagsConsumer); ok {
+		c.ConsumeTag(tag)
+	}
+}
+
+// ConsumeResourceMetadata implements ResourceMetadataConsumer.
+func (r *Router) ConsumeResourceMetadata(metadata map[string]string) {
+	if c, ok := r.defaultConsumer.(ResourceMetadataConsumer); ok {
+		c.ConsumeResourceMetadata(metadata)
+	}
+}
+
+// ConsumeOrigin implements OriginConsumer.
+func (r *Router) ConsumeOrigin(product, category, service string) {
+	if c, ok := r.defaultConsumer.(OriginConsumer); ok {
+		c.ConsumeOrigin(product, category, service)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+	"go.uber.org/zap"
+)
+
+// metricNames returns the names of the metrics consumed as time series.
+func metricNames(metrics []metric) []string {
+	var names []string
+	for _, m := range metrics {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func TestRouter(t *testing.T) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(conventions.AttributeCloudRegion, "us-east-1")
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range []string{"container.cpu.usage", "app.requests", "container.memory.usage"} {
+		met := metrics.AppendEmpty()
+		met.SetName(name)
+		met.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(1)
+	}
+	hist := metrics.AppendEmpty()
+	hist.SetName("container.io.latency")
+	hist.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	hp := hist.Histogram().DataPoints().AppendEmpty()
+	hp.SetCount(1)
+	hp.SetSum(2)
+	hp.BucketCounts().FromRaw([]uint64{1})
+	sum := metrics.AppendEmpty()
+	sum.SetName("container.restarts")
+	sum.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.Sum().SetIsMonotonic(true)
+	sum.Sum().DataPoints().AppendEmpty().SetIntValue(1)
+
+	sampling := &mockCountConsumer{monotonic: make(map[string]bool)}
+	memory := &mockFullConsumer{}
+	defaultConsumer := &mockResourceMetadataConsumer{}
+	router, err := NewRouter(defaultConsumer,
+		RouteRule{Pattern: "container.memory.*", Consumer: memory},
+		RouteRule{Pattern: "container.*", Consumer: sampling},
+	)
+	require.NoError(t, err)
+
+	tr := newTranslator(t, zap.NewNop(), WithCountMonotonicityHints())
+	require.NoError(t, tr.MapMetrics(context.Background(), md, router))
+
+	// The first matching rule wins
+	assert.Equal(t, []string{"container.cpu.usage"}, metricNames(sampling.metrics))
+	assert.Equal(t, []string{"container.memory.usage"}, metricNames(memory.metrics))
+	assert.Equal(t, []string{"app.requests"}, metricNames(defaultConsumer.metrics))
+
+	// Sketches and annotated counts are routed too
+	require.Len(t, sampling.sketches, 1)
+	assert.Equal(t, "container.io.latency", sampling.sketches[0].name)
+	assert.Empty(t, defaultConsumer.sketches)
+	assert.Equal(t, map[string]bool{"container.restarts": true}, sampling.monotonic)
+
+	// The optional interfaces not tied to a metric go to the default consumer
+	assert.Equal(t, []map[string]string{{conventions.AttributeCloudRegion: "us-east-1"}}, defaultConsumer.metadata)
+}
+
+func TestRouterCountsWithoutCountConsumer(t *testing.T) {
+	consumer := &mockFullConsumer{}
+	router, err := NewRouter(NoopConsumer{}, RouteRule{Pattern: "*", Consumer: consumer})
+	require.NoError(t, err)
+
+	router.ConsumeCount(context.Background(), newDims("test.count"), 1, 2, false)
+	assert.Equal(t, []metric{newCount(newDims("test.count"), 1, 2)}, consumer.metrics)
+}
+
+func TestNewRouterInvalid(t *testing.T) {
+	_, err := NewRouter(nil)
+	assert.Error(t, err)
+
+	_, err = NewRouter(NoopConsumer{}, RouteRule{Pattern: "container.[", Consumer: NoopConsumer{}})
+	assert.Error(t, err)
+
+	_, err = NewRouter(NoopConsumer{}, RouteRule{Pattern: "container.*"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+// mergedSketch is a sketch merged from those of the same dimensions.
+type mergedSketch struct {
+	dims   *Dimensions
+	ts     uint64
+	sketch *quantile.Sketch
+}
+
+// sketchMerger is a Consumer merging the sketches of the same dimensions
+// until they are flushed, see WithHistogramSketchMerging. Everything else is
+// forwarded to the wrapped Consumer.
+type sketchMerger struct {
+	Consumer
+
+	sketches map[string]*mergedSketch
+	// sketches keys, in the order of their first sketch
+	keys []string
+}
+
+func newSketchMerger(consumer Consumer) *sketchMerger {
+	return &sketchMerger{
+		Consumer: consumer,
+		sketches: make(map[string]*mergedSketch),
+	}
+}
+
+// ConsumeSketch implements SketchConsumer.
+func (m *sketchMerger) ConsumeSketch(_ context.Context, dimensions *Dimensions, timestamp uint64, sketch *quantile.Sketch) {
+	key := dimensions.String()
+	merged, found := m.sketches[key]
+	if !found {
+		m.sketches[key] = &mergedSketch{dims: dimensions, ts: timestamp, sketch: sketch}
+		m.keys = append(m.keys, key)
+		return
+	}
+
+	merged.sketch.Merge(quantile.Default(), sketch)
+	if timestamp > merged.ts {
+		merged.ts = timestamp
+	}
+}
+
+// ConsumeCount implements CountConsumer, for the consumer merged into to be
+// annotated with the monotonicity of the counts when it implements it.
+func (m *sketchMerger) ConsumeCount(ctx context.Context, dimensions *Dimensions, timestamp uint64, value float64, monotonic bool) {
+	if countConsumer, ok := m.Consumer.(CountConsumer); ok {
+		countConsumer.ConsumeCount(ctx, dimensions, timestamp, value, monotonic)
+		return
+	}
+	m.Consumer.ConsumeTimeSeries(ctx, dimensions, Count, timestamp, value)
+}
+
+// flush consumes the merged sketches, with the latest timestamp of the
+// sketches of their dimensions.
+func (m *sketchMerger) flush(ctx context.Context) {
+	for _, key := range m.keys {
+		merged := m.sketches[key]
+		m.Consumer.ConsumeSketch(ctx, merged.dims, merged.ts, merged.sketch)
+	}
+	m.sketches = make(map[string]*mergedSketch)
+	m.keys = nil
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+)
+
+// statsResourceAttributes maps the resource attributes of the semantic
+// conventions to the ones of the resources of APM stats, which carry the
+// fields of the stats payload instead, see StatsPayloadToMetrics.
+var statsResourceAttributes = map[string]string{
+	conventions.AttributeDeploymentEnvironment: statsKeyEnv,
+	conventions.AttributeServiceName:           statsKeyService,
+	conventions.AttributeServiceVersion:        statsKeyVersion,
+	conventions.AttributeHostName:              statsKeyHostname,
+	conventions.AttributeContainerID:           statsKeyContainerID,
+}
+
+// resourceAttributeFilter matches the resources having an attribute with a
+// given value. The attributes of the semantic conventions also match the
+// corresponding attributes of the APM stats resources, e.g.
+// `deployment.environment` matches `dd.env`.
+type resourceAttributeFilter struct {
+	key   string
+	value string
+}
+
+// parseResourceAttributeFilter parses a filter expression of the form
+// `<attribute> == <value>`, e.g. `deployment.environment == dev`.
+func parseResourceAttributeFilter(expr string) (resourceAttributeFilter, error) {
+	key, value, found := strings.Cut(expr, "==")
+	if !found {
+		return resourceAttributeFilter{}, fmt.Errorf("invalid resource attribute filter %q: expected <attribute> == <value>", expr)
+	}
+
+	key = strings.TrimSpace(key)
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if key == "" {
+		return resourceAttributeFilter{}, fmt.Errorf("invalid resource attribute filter %q: empty attribute", expr)
+	}
+	if strings.Contains(value, "==") {
+		return resourceAttributeFilter{}, fmt.Errorf("invalid resource attribute filter %q: more than one comparison", expr)
+	}
+
+	return resourceAttributeFilter{key: key, value: value}, nil
+}
+
+func (f resourceAttributeFilter) matches(attrs pcommon.Map) bool {
+	v, ok := attrs.Get(f.key)
+	if !ok {
+		statsKey, found := statsResourceAttributes[f.key]
+		if !found {
+			return false
+		}
+		v, ok = attrs.Get(statsKey)
+	}
+	return ok && v.AsString() == f.value
+}
+
+// isAPMStatsFiltered returns whether the APM stats of the resource with the
+// given attributes must be dropped.
+func (t *Translator) isAPMStatsFiltered(attrs pcommon.Map) bool {
+	for _, f := range t.cfg.apmStatsFilters {
+		if f.matches(attrs) {
+			return true
+		}
+	}
+	return false
+}
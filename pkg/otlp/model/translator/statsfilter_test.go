@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+	"go.uber.org/zap"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+func TestParseResourceAttributeFilter(t *testing.T) {
+	for expr, expected := range map[string]resourceAttributeFilter{
+		`deployment.environment == "dev"`: {key: "deployment.environment", value: "dev"},
+		`deployment.environment==dev`:     {key: "deployment.environment", value: "dev"},
+		`service.name == `:                {key: "service.name", value: ""},
+	} {
+		filter, err := parseResourceAttributeFilter(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, expected, filter, expr)
+	}
+
+	for _, expr := range []string{
+		"deployment.environment",
+		"deployment.environment = dev",
+		` == "dev"`,
+		"a == b == c",
+	} {
+		_, err := parseResourceAttributeFilter(expr)
+		assert.Error(t, err, expr)
+	}
+
+	_, err := New(zap.NewNop(), WithAPMStatsResourceFilters("deployment.environment"))
+	assert.Error(t, err)
+}
+
+func TestMapAPMStatsResourceFilters(t *testing.T) {
+	dev := statsPayloads[0]
+	dev.Env = "dev"
+
+	for _, expr := range []string{
+		`deployment.environment == "dev"`,
+		`dd.env == "dev"`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			tr := newTranslator(t, zap.NewNop(), WithAPMStatsResourceFilters(expr))
+			md := tr.StatsPayloadToMetrics(pb.StatsPayload{
+				Stats: []pb.ClientStatsPayload{dev, statsPayloads[1]},
+			})
+
+			consumer := &mockFullConsumer{}
+			require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+			assert.Equal(t, []pb.ClientStatsPayload{statsPayloads[1]}, consumer.apmstats)
+		})
+	}
+
+	// The attributes set on the resources take precedence
+	tr := newTranslator(t, zap.NewNop(), WithAPMStatsResourceFilters(`deployment.environment == "dev"`))
+	md := tr.StatsPayloadToMetrics(pb.StatsPayload{
+		Stats: []pb.ClientStatsPayload{statsPayloads[0], dev},
+	})
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr(conventions.AttributeDeploymentEnvironment, "dev")
+	md.ResourceMetrics().At(1).Resource().Attributes().PutStr(conventions.AttributeDeploymentEnvironment, "prod")
+
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+	assert.Equal(t, []pb.ClientStatsPayload{dev}, consumer.apmstats)
+}
@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the maximum length, in characters, of the tags accepted by
+// the backend. Longer tags are truncated by the Translator.
+const MaxTagLength = 200
+
+// truncateTag truncates the tag to MaxTagLength characters, without
+// splitting multi-byte characters. It returns whether the tag was truncated.
+func truncateTag(tag string) (string, bool) {
+	// a string of at most MaxTagLength bytes has at most as many characters
+	if len(tag) <= MaxTagLength || utf8.RuneCountInString(tag) <= MaxTagLength {
+		return tag, false
+	}
+
+	chars := 0
+	for i := range tag {
+		if chars == MaxTagLength {
+			return tag[:i], true
+		}
+		chars++
+	}
+	return tag, false
+}
+
+// truncateTags truncates the tags longer than MaxTagLength in place, and
+// counts them. The tags must not be shared with other dimensions.
+func (t *Translator) truncateTags(tags []string) {
+	for i, tag := range tags {
+		if truncated, ok := truncateTag(tag); ok {
+			tags[i] = truncated
+			atomic.AddUint64(&t.truncatedTags, 1)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestTruncateTag(t *testing.T) {
+	atLimit := "key:" + strings.Repeat("v", MaxTagLength-4)
+	tag, truncated := truncateTag(atLimit)
+	assert.Equal(t, atLimit, tag)
+	assert.False(t, truncated)
+
+	tag, truncated = truncateTag(atLimit + "w")
+	assert.Equal(t, atLimit, tag)
+	assert.True(t, truncated)
+
+	// The length is in characters, and multi-byte characters are not split
+	atLimit = "key:" + strings.Repeat("é", MaxTagLength-4)
+	tag, truncated = truncateTag(atLimit)
+	assert.Equal(t, atLimit, tag)
+	assert.False(t, truncated)
+
+	tag, truncated = truncateTag(atLimit + "é")
+	assert.Equal(t, atLimit, tag)
+	assert.True(t, truncated)
+}
+
+func TestMapMetricsTruncatedTags(t *testing.T) {
+	atLimit := strings.Repeat("a", MaxTagLength-len("point.attr:"))
+	overLimit := strings.Repeat("b", 2*MaxTagLength)
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("deployment.environment", overLimit)
+	met := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	met.SetName("test.gauge")
+	dps := met.SetEmptyGauge().DataPoints()
+	for i := 0; i < 2; i++ {
+		dp := dps.AppendEmpty()
+		dp.SetIntValue(int64(i))
+		dp.SetTimestamp(seconds(i))
+		dp.Attributes().PutStr("point.attr", atLimit)
+		dp.Attributes().PutStr("other.attr", overLimit)
+	}
+
+	tr := newTranslator(t, zap.NewNop())
+	consumer := &mockFullConsumer{}
+	require.NoError(t, tr.MapMetrics(context.Background(), md, consumer))
+
+	// The same source tags are truncated the same way on every point
+	truncatedEnv := ("env:" + overLimit)[:MaxTagLength]
+	truncatedAttr := ("other.attr:" + overLimit)[:MaxTagLength]
+	require.Len(t, consumer.metrics, 2)
+	for _, m := range consumer.metrics {
+		assert.ElementsMatch(t, []string{"point.attr:" + atLimit, truncatedAttr, truncatedEnv}, m.tags)
+	}
+
+	// The resource tag is truncated once, the point tag once per point
+	assert.Equal(t, uint64(3), tr.Stats().TruncatedTags)
+}
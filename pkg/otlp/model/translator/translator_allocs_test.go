@@ -0,0 +1,195 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/DataDog/datadog-agent/pkg/otlp/model/attributes"
+)
+
+// Number of metrics of every kind in the payloads of the benchmarks
+const allocsPayloadSize = 100
+
+// Allocation budgets, in allocations per metric of the payloads, checked by
+// TestAllocationBudgets. They leave about 25% of headroom over the current
+// allocations: lower them when the mapping allocates less, and only raise them
+// for a good reason, as they're the guardrail against regressions.
+const (
+	gaugesAllocsBudget     = 10
+	sumsAllocsBudget       = 24
+	histogramsAllocsBudget = 130
+	summariesAllocsBudget  = 72
+)
+
+// newAllocsPayload creates a payload with one resource having n metrics
+// created by addMetric, with two attributes on every point.
+func newAllocsPayload(n int, addMetric func(metricsArray pmetric.MetricSlice, name string) pmetric.Metric) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(attributes.AttributeDatadogHostname, testHostname)
+	rm.Resource().Attributes().PutStr("deployment.environment", "prod")
+	rm.Resource().Attributes().PutStr("service.name", "service")
+
+	metricsArray := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for i := 0; i < n; i++ {
+		addMetric(metricsArray, fmt.Sprintf("metric.%d", i))
+	}
+	return md
+}
+
+func putPointAttributes(attrs interface{ PutStr(string, string) }) {
+	attrs.PutStr("endpoint", "/api/v1/resource")
+	attrs.PutStr("status_code", "200")
+}
+
+func newAllocsGauges(n int) pmetric.Metrics {
+	return newAllocsPayload(n, func(metricsArray pmetric.MetricSlice, name string) pmetric.Metric {
+		met := metricsArray.AppendEmpty()
+		met.SetName(name)
+		dp := met.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetDoubleValue(42)
+		dp.SetTimestamp(seconds(1))
+		putPointAttributes(dp.Attributes())
+		return met
+	})
+}
+
+func newAllocsSums(n int) pmetric.Metrics {
+	return newAllocsPayload(n, func(metricsArray pmetric.MetricSlice, name string) pmetric.Metric {
+		met := metricsArray.AppendEmpty()
+		met.SetName(name)
+		met.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		met.Sum().SetIsMonotonic(true)
+		dp := met.Sum().DataPoints().AppendEmpty()
+		dp.SetIntValue(42)
+		dp.SetStartTimestamp(seconds(1))
+		dp.SetTimestamp(seconds(2))
+		putPointAttributes(dp.Attributes())
+		return met
+	})
+}
+
+func newAllocsHistograms(n int) pmetric.Metrics {
+	return newAllocsPayload(n, func(metricsArray pmetric.MetricSlice, name string) pmetric.Metric {
+		met := metricsArray.AppendEmpty()
+		met.SetName(name)
+		met.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		dp := met.Histogram().DataPoints().AppendEmpty()
+		dp.ExplicitBounds().FromRaw([]float64{0.01, 0.1, 1, 10})
+		dp.BucketCounts().FromRaw([]uint64{1, 5, 20, 3, 1})
+		dp.SetCount(30)
+		dp.SetSum(42)
+		dp.SetTimestamp(seconds(1))
+		putPointAttributes(dp.Attributes())
+		return met
+	})
+}
+
+func newAllocsSummaries(n int) pmetric.Metrics {
+	return newAllocsPayload(n, func(metricsArray pmetric.MetricSlice, name string) pmetric.Metric {
+		met := metricsArray.AppendEmpty()
+		met.SetName(name)
+		dp := met.SetEmptySummary().DataPoints().AppendEmpty()
+		dp.SetCount(30)
+		dp.SetSum(42)
+		dp.SetStartTimestamp(seconds(1))
+		dp.SetTimestamp(seconds(2))
+		for _, q := range []float64{0, 0.5, 0.99, 1} {
+			qv := dp.QuantileValues().AppendEmpty()
+			qv.SetQuantile(q)
+			qv.SetValue(q * 10)
+		}
+		putPointAttributes(dp.Attributes())
+		return met
+	})
+}
+
+var allocsPayloads = []struct {
+	name   string
+	create func(n int) pmetric.Metrics
+	budget float64
+}{
+	{name: "gauges", create: newAllocsGauges, budget: gaugesAllocsBudget},
+	{name: "sums", create: newAllocsSums, budget: sumsAllocsBudget},
+	{name: "histograms", create: newAllocsHistograms, budget: histogramsAllocsBudget},
+	{name: "summaries", create: newAllocsSummaries, budget: summariesAllocsBudget},
+}
+
+// newAllocsTranslator returns a translator with the options of the Agent,
+// which already mapped the metrics once, to measure the steady state, where
+// the previous points of the cumulative metrics are known.
+func newAllocsTranslator(t testing.TB, md pmetric.Metrics) *Translator {
+	tr, err := New(zap.NewNop(),
+		WithFallbackSourceProvider(testProvider(fallbackHostname)),
+		WithHistogramMode(HistogramModeDistributions),
+		WithNumberMode(NumberModeCumulativeToDelta),
+		WithQuantiles(),
+		WithCountSumMetrics(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, tr.MapMetrics(context.Background(), md, NoopConsumer{}))
+	return tr
+}
+
+func BenchmarkMapMetricsPayloads(b *testing.B) {
+	for _, payload := range allocsPayloads {
+		b.Run(payload.name, func(b *testing.B) {
+			md := payload.create(allocsPayloadSize)
+			tr := newAllocsTranslator(b, md)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := tr.MapMetrics(ctx, md, NoopConsumer{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocationBudgets(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates more than the budgets")
+	}
+
+	for _, payload := range allocsPayloads {
+		t.Run(payload.name, func(t *testing.T) {
+			md := payload.create(allocsPayloadSize)
+			tr := newAllocsTranslator(t, md)
+			ctx := context.Background()
+
+			allocs := testing.AllocsPerRun(10, func() {
+				if err := tr.MapMetrics(ctx, md, NoopConsumer{}); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			perMetric := allocs / allocsPayloadSize
+			t.Logf("%.1f allocations per metric", perMetric)
+			if perMetric > payload.budget {
+				t.Errorf("mapping %s allocated %.1f objects per metric, over the budget of %g", payload.name, perMetric, payload.budget)
+			}
+		})
+	}
+}
@@ -40,30 +40,56 @@ func newTTLCache(sweepInterval int64, deltaTTL int64) *ttlCache {
 // Diff submits a new value for a given non-monotonic metric and returns the difference with the
 // last submitted value (ordered by timestamp). The diff value is only valid if `ok` is true.
 func (t *ttlCache) Diff(dimensions *Dimensions, startTs, ts uint64, val float64) (float64, bool) {
-	return t.putAndGetDiff(dimensions, false, startTs, ts, val)
+	dx, _, _, ok := t.putAndGetDiff(dimensions, false, startTs, ts, val)
+	return dx, ok
+}
+
+// DiffOrDuplicate is like Diff, but also returns whether the value has the same
+// timestamp as the one in memory.
+func (t *ttlCache) DiffOrDuplicate(dimensions *Dimensions, startTs, ts uint64, val float64) (dx float64, duplicate bool, ok bool) {
+	dx, _, duplicate, ok = t.putAndGetDiff(dimensions, false, startTs, ts, val)
+	return
 }
 
 // MonotonicDiff submits a new value for a given monotonic metric and returns the difference with the
 // last submitted value (ordered by timestamp). The diff value is only valid if `ok` is true.
 func (t *ttlCache) MonotonicDiff(dimensions *Dimensions, startTs, ts uint64, val float64) (float64, bool) {
+	dx, _, _, ok := t.putAndGetDiff(dimensions, true, startTs, ts, val)
+	return dx, ok
+}
+
+// MonotonicDiffOrFirst is like MonotonicDiff, but also returns whether the value
+// is the first one submitted for the metric, out of those still in memory, and
+// whether it has the same timestamp as the one in memory.
+func (t *ttlCache) MonotonicDiffOrFirst(dimensions *Dimensions, startTs, ts uint64, val float64) (dx float64, first bool, duplicate bool, ok bool) {
 	return t.putAndGetDiff(dimensions, true, startTs, ts, val)
 }
 
 // putAndGetDiff submits a new value for a given metric and returns the difference with the
 // last submitted value (ordered by timestamp). The diff value is only valid if `ok` is true.
+// `first` is true if there was no value in memory for the metric.
+// `duplicate` is true if the value in memory has the same timestamp, e.g. when an
+// exporter sends the same point twice: the value is ignored, and the first one is
+// kept in memory, so that no zero or negative diff is computed between the two.
+// Points without a timestamp are never duplicates.
 func (t *ttlCache) putAndGetDiff(
 	dimensions *Dimensions,
 	monotonic bool,
 	startTs, ts uint64,
 	val float64,
-) (dx float64, ok bool) {
+) (dx float64, first bool, duplicate bool, ok bool) {
 	key := dimensions.String()
-	if c, found := t.cache.Get(key); found {
+	c, found := t.cache.Get(key)
+	first = !found
+	if found {
 		cnt := c.(numberCounter)
 		if cnt.ts > ts {
 			// We were given a point older than the one in memory so we drop it
 			// We keep the existing point in memory since it is the most recent
-			return 0, false
+			return 0, false, false, false
+		}
+		if ts != 0 && cnt.ts == ts {
+			return 0, false, true, false
 		}
 		dx = val - cnt.value
 
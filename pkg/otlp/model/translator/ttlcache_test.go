@@ -112,3 +112,37 @@ func TestDiffKnownStart(t *testing.T) {
 	assert.True(t, ok, "expected diff: same startTs, not monotonic")
 	assert.Equal(t, 9.0, dx, "expected diff 9.0 with (6,7,1) value")
 }
+
+func TestMonotonicDiffDuplicateTimestamp(t *testing.T) {
+	startTs := uint64(1)
+	prevPts := newTestCache()
+	_, first, duplicate, ok := prevPts.MonotonicDiffOrFirst(dims, startTs, 2, 5)
+	assert.False(t, ok, "expected no diff: first point")
+	assert.True(t, first, "expected first point")
+	assert.False(t, duplicate, "expected no duplicate: first point")
+	_, _, duplicate, ok = prevPts.MonotonicDiffOrFirst(dims, startTs, 2, 4)
+	assert.False(t, ok, "expected no diff: duplicate point")
+	assert.True(t, duplicate, "expected duplicate: same timestamp")
+	_, _, duplicate, ok = prevPts.MonotonicDiffOrFirst(dims, startTs, 2, 8)
+	assert.False(t, ok, "expected no diff: duplicate point")
+	assert.True(t, duplicate, "expected duplicate: same timestamp")
+	dx, _, duplicate, ok := prevPts.MonotonicDiffOrFirst(dims, startTs, 3, 7)
+	assert.True(t, ok, "expected diff: same startTs, old >= new")
+	assert.False(t, duplicate, "expected no duplicate: new timestamp")
+	assert.Equal(t, 2.0, dx, "expected diff 2.0 with (1,2,5) value, the first at its timestamp")
+}
+
+func TestDiffDuplicateTimestamp(t *testing.T) {
+	startTs := uint64(1)
+	prevPts := newTestCache()
+	_, duplicate, ok := prevPts.DiffOrDuplicate(dims, startTs, 2, 5)
+	assert.False(t, ok, "expected no diff: first point")
+	assert.False(t, duplicate, "expected no duplicate: first point")
+	_, duplicate, ok = prevPts.DiffOrDuplicate(dims, startTs, 2, 4)
+	assert.False(t, ok, "expected no diff: duplicate point")
+	assert.True(t, duplicate, "expected duplicate: same timestamp")
+	dx, duplicate, ok := prevPts.DiffOrDuplicate(dims, startTs, 3, 7)
+	assert.True(t, ok, "expected diff: same startTs, not monotonic")
+	assert.False(t, duplicate, "expected no duplicate: new timestamp")
+	assert.Equal(t, 2.0, dx, "expected diff 2.0 with (1,2,5) value, the first at its timestamp")
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package quantile
+
+import (
+	"encoding/json"
+
+	"github.com/DataDog/datadog-agent/pkg/quantile/summary"
+)
+
+// jsonSketch is the JSON representation of a Sketch, to debug its bins.
+type jsonSketch struct {
+	Mapping jsonMapping     `json:"mapping"`
+	Bins    []jsonBin       `json:"bins"`
+	Count   int             `json:"count"`
+	Sum     float64         `json:"sum"`
+	Basic   summary.Summary `json:"summary"`
+}
+
+// jsonMapping holds the parameters mapping the values to the bin indexes.
+type jsonMapping struct {
+	Gamma    float64 `json:"gamma"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Bias     int     `json:"bias"`
+	BinLimit int     `json:"bin_limit"`
+}
+
+type jsonBin struct {
+	Index Key    `json:"index"`
+	Count uint16 `json:"count"`
+}
+
+// MarshalJSON implements json.Marshaler, for debugging. The bins are those of
+// the sketch, in order, and the mapping is the one of the default config, used
+// by all the sketches of the agent. The summary is kept under "summary", as
+// before the bins were included. The JSON can't be decoded back into a
+// sketch, the protobuf encoding is used for transport.
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	c := Default()
+
+	bins := make([]jsonBin, 0, len(s.bins))
+	for _, b := range s.bins {
+		bins = append(bins, jsonBin{Index: b.k, Count: b.n})
+	}
+
+	return json.Marshal(jsonSketch{
+		Mapping: jsonMapping{
+			Gamma:    c.gamma.v,
+			Min:      c.norm.min,
+			Max:      c.norm.max,
+			Bias:     c.norm.bias,
+			BinLimit: c.binLimit,
+		},
+		Bins:  bins,
+		Count: s.count,
+		Sum:   s.Basic.Sum,
+		Basic: s.Basic,
+	})
+}
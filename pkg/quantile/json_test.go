@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package quantile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchMarshalJSON(t *testing.T) {
+	c := Default()
+	s := &Sketch{}
+	s.Insert(c, 0, 1, 1, 10)
+
+	content, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &decoded))
+
+	assert.Equal(t, map[string]interface{}{
+		"gamma":     c.gamma.v,
+		"min":       c.norm.min,
+		"max":       c.norm.max,
+		"bias":      float64(c.norm.bias),
+		"bin_limit": float64(defaultBinLimit),
+	}, decoded["mapping"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"index": float64(0), "count": float64(1)},
+		map[string]interface{}{"index": float64(c.key(1)), "count": float64(2)},
+		map[string]interface{}{"index": float64(c.key(10)), "count": float64(1)},
+	}, decoded["bins"])
+	assert.Equal(t, float64(4), decoded["count"])
+	assert.Equal(t, float64(12), decoded["sum"])
+	assert.Contains(t, decoded, "summary")
+
+	// The encoding is stable
+	again, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+}
+
+func TestEmptySketchMarshalJSON(t *testing.T) {
+	content, err := json.Marshal(&Sketch{})
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, []interface{}{}, decoded["bins"])
+	assert.Equal(t, float64(0), decoded["count"])
+	assert.Equal(t, float64(0), decoded["sum"])
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package quantile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// QuantileValue is the value of a quantile of a distribution, e.g. the 0.99
+// quantile (p99) is 120.
+type QuantileValue struct {
+	Quantile float64
+	Value    float64
+}
+
+// SketchFromQuantiles builds a sketch of count values having the given
+// quantiles, for sources reporting only some percentiles of a distribution
+// instead of its values.
+//
+// This is an approximation: the values between two known quantiles are spread
+// linearly between their values, and the values below the lowest and above the
+// highest quantiles are assumed to be equal to them. The quantiles of the
+// sketch match the given ones within the accuracy of the sketch, but the other
+// quantiles, the sum and the average may be far from the actual ones.
+func SketchFromQuantiles(count uint, quantiles []QuantileValue) (*Sketch, error) {
+	if count == 0 {
+		return nil, fmt.Errorf("count must be positive")
+	}
+	if len(quantiles) == 0 {
+		return nil, fmt.Errorf("no quantiles")
+	}
+
+	sorted := make([]QuantileValue, len(quantiles))
+	copy(sorted, quantiles)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Quantile < sorted[j].Quantile
+	})
+
+	for i, qv := range sorted {
+		if qv.Quantile < 0 || qv.Quantile > 1 || math.IsNaN(qv.Quantile) {
+			return nil, fmt.Errorf("quantile must be between 0 and 1: %g", qv.Quantile)
+		}
+		if math.IsNaN(qv.Value) || math.IsInf(qv.Value, 0) {
+			return nil, fmt.Errorf("value of quantile %g must be finite: %g", qv.Quantile, qv.Value)
+		}
+		if i > 0 && qv.Value < sorted[i-1].Value {
+			return nil, fmt.Errorf("value of quantile %g is lower than the one of quantile %g: %g < %g",
+				qv.Quantile, sorted[i-1].Quantile, qv.Value, sorted[i-1].Value)
+		}
+	}
+
+	// The value of the quantile q is the one of rank(count, q) in the values
+	// sorted in ascending order, see Sketch.Quantile.
+	a := &Agent{}
+	first, last := sorted[0], sorted[len(sorted)-1]
+	prevRank := rank(int(count), first.Quantile)
+	a.InsertInterpolate(first.Value, first.Value, uint(prevRank)+1)
+
+	for i := 1; i < len(sorted); i++ {
+		r := rank(int(count), sorted[i].Quantile)
+		if n := uint(r - prevRank); n > 0 {
+			// the value of rank r is exactly the one of the quantile
+			if n > 1 {
+				a.InsertInterpolate(sorted[i-1].Value, sorted[i].Value, n-1)
+			}
+			a.InsertInterpolate(sorted[i].Value, sorted[i].Value, 1)
+		}
+		prevRank = r
+	}
+
+	if n := uint(float64(count-1) - prevRank); n > 0 {
+		a.InsertInterpolate(last.Value, last.Value, n)
+	}
+
+	sketch := a.Finish()
+	// InsertInterpolate approximates the extremes with the bounds of their
+	// bins, while they're known here.
+	sketch.Basic.Min = first.Value
+	sketch.Basic.Max = last.Value
+	return sketch, nil
+}
@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package quantile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchFromQuantiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     uint
+		quantiles []QuantileValue
+	}{
+		{
+			name:  "percentiles",
+			count: 1000,
+			quantiles: []QuantileValue{
+				{Quantile: 0.5, Value: 12},
+				{Quantile: 0.9, Value: 48},
+				{Quantile: 0.99, Value: 230},
+			},
+		},
+		{
+			name:  "unsorted with extremes",
+			count: 10000,
+			quantiles: []QuantileValue{
+				{Quantile: 1, Value: 5000},
+				{Quantile: 0.5, Value: 100},
+				{Quantile: 0, Value: 0.5},
+				{Quantile: 0.95, Value: 1200},
+			},
+		},
+		{
+			name:  "negative values",
+			count: 500,
+			quantiles: []QuantileValue{
+				{Quantile: 0.25, Value: -40},
+				{Quantile: 0.5, Value: -2},
+				{Quantile: 0.75, Value: 35},
+			},
+		},
+		{
+			name:  "equal values",
+			count: 200,
+			quantiles: []QuantileValue{
+				{Quantile: 0.5, Value: 7},
+				{Quantile: 0.9, Value: 7},
+				{Quantile: 0.99, Value: 9},
+			},
+		},
+		{
+			name:  "few values",
+			count: 3,
+			quantiles: []QuantileValue{
+				{Quantile: 0.5, Value: 10},
+				{Quantile: 0.99, Value: 20},
+			},
+		},
+	}
+
+	c := Default()
+	// A quantile is interpolated in its bin, which spans a factor of gamma
+	// from its lower bound, and so does the bin of the value itself
+	relativeAccuracy := 2 * (c.gamma.v - 1)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sketch, err := SketchFromQuantiles(test.count, test.quantiles)
+			require.NoError(t, err)
+
+			assert.EqualValues(t, test.count, sketch.Basic.Cnt)
+			assert.Equal(t, int(test.count), sketch.count)
+			for _, qv := range test.quantiles {
+				got := sketch.Quantile(c, qv.Quantile)
+				assert.InDelta(t, qv.Value, got, relativeAccuracy*math.Abs(qv.Value), "quantile %g", qv.Quantile)
+			}
+
+			lowest, highest := test.quantiles[0].Value, test.quantiles[0].Value
+			for _, qv := range test.quantiles {
+				lowest = math.Min(lowest, qv.Value)
+				highest = math.Max(highest, qv.Value)
+			}
+			assert.Equal(t, lowest, sketch.Min())
+			assert.Equal(t, highest, sketch.Max())
+		})
+	}
+}
+
+func TestSketchFromQuantilesInvalid(t *testing.T) {
+	for name, test := range map[string]struct {
+		count     uint
+		quantiles []QuantileValue
+	}{
+		"no count":             {count: 0, quantiles: []QuantileValue{{Quantile: 0.5, Value: 1}}},
+		"no quantiles":         {count: 10},
+		"quantile above 1":     {count: 10, quantiles: []QuantileValue{{Quantile: 1.5, Value: 1}}},
+		"negative quantile":    {count: 10, quantiles: []QuantileValue{{Quantile: -0.5, Value: 1}}},
+		"NaN value":            {count: 10, quantiles: []QuantileValue{{Quantile: 0.5, Value: math.NaN()}}},
+		"infinite value":       {count: 10, quantiles: []QuantileValue{{Quantile: 0.5, Value: math.Inf(1)}}},
+		"decreasing quantiles": {count: 10, quantiles: []QuantileValue{{Quantile: 0.5, Value: 10}, {Quantile: 0.9, Value: 5}}},
+	} {
+		_, err := SketchFromQuantiles(test.count, test.quantiles)
+		assert.Error(t, err, name)
+	}
+}
@@ -16,8 +16,8 @@ import (
 var _ memSized = (*Sketch)(nil)
 
 // A Sketch for tracking quantiles
-// The serialized JSON of Sketch contains the summary only
-// Bins are not included.
+// The serialized JSON of Sketch contains its bins and summary, for debugging,
+// see MarshalJSON.
 type Sketch struct {
 	sparseStore
 
@@ -134,6 +134,18 @@ func (s *Sketch) Quantile(c *Config, q float64) float64 {
 	return math.NaN()
 }
 
+// Min returns the smallest value inserted into the sketch. Unlike the
+// quantiles, it's exact: it's tracked by the summary of the sketch, which is
+// combined exactly by Merge and kept by serialization.
+func (s *Sketch) Min() float64 {
+	return s.Basic.Min
+}
+
+// Max returns the largest value inserted into the sketch, exactly, see Min.
+func (s *Sketch) Max() float64 {
+	return s.Basic.Max
+}
+
 func rank(count int, q float64) float64 {
 	return math.RoundToEven(q * float64(count-1))
 }
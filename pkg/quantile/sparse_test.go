@@ -6,6 +6,7 @@
 package quantile
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"testing"
@@ -58,6 +59,51 @@ func TestMerge(t *testing.T) {
 
 }
 
+func TestMergeMinMax(t *testing.T) {
+	var (
+		c  = Default()
+		s1 = &Sketch{}
+		s2 = &Sketch{}
+		s3 = &Sketch{}
+	)
+
+	// Extremes that don't fall on the bounds of their bins
+	s1.InsertMany(c, []float64{-13.37, 1, 2})
+	s2.InsertMany(c, []float64{3, 42.4242})
+	s3.InsertMany(c, []float64{0.5})
+
+	merged := &Sketch{}
+	for _, s := range []*Sketch{s1, s2, s3, {}} {
+		merged.Merge(c, s)
+	}
+
+	require.Equal(t, -13.37, merged.Min())
+	require.Equal(t, 42.4242, merged.Max())
+	require.Equal(t, merged.Min(), merged.Quantile(c, 0))
+	require.Equal(t, merged.Max(), merged.Quantile(c, 1))
+
+	// The extremes survive a serialization round-trip
+	b, err := json.Marshal(merged)
+	require.NoError(t, err)
+	var decoded Sketch
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, -13.37, decoded.Min())
+	require.Equal(t, 42.4242, decoded.Max())
+
+	// And further merges
+	s4 := &Sketch{}
+	s4.InsertMany(c, []float64{-0.001, 10})
+	decoded.Merge(c, s4)
+	require.Equal(t, -13.37, decoded.Min())
+	require.Equal(t, 42.4242, decoded.Max())
+
+	// Merging into an empty sketch keeps the extremes of the other one
+	empty := &Sketch{}
+	empty.Merge(c, s2)
+	require.Equal(t, 3.0, empty.Min())
+	require.Equal(t, 42.4242, empty.Max())
+}
+
 func TestString(t *testing.T) {
 	var (
 		s, c    = &Sketch{}, Default()
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package format
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+const (
+	// flareDir is the directory of the flare where the SBOMs are added
+	flareDir = "sbom"
+
+	// flareProviderName is the name the flare provider is registered under
+	flareProviderName = "sbom"
+)
+
+// fileExtensions are the extensions of the SBOM files of the flare, by format
+var fileExtensions = map[Format]string{
+	CycloneDXJSON: ".cdx.json",
+	SPDXJSON:      ".spdx.json",
+}
+
+// NewFlareProvider returns a flare provider adding the SBOMs of the images of
+// the global workloadmeta store to the flare, in the format set in
+// container_image_collection.sbom.export_format.
+func NewFlareProvider() helpers.Provider {
+	return helpers.NewNamedProvider(flareProviderName, func(fb helpers.FlareBuilder) error {
+		// Nothing to add when this process has no store
+		store := workloadmeta.GetGlobalStore()
+		if store == nil {
+			return nil
+		}
+
+		return addImageSBOMs(fb, store)
+	})
+}
+
+// addImageSBOMs adds a file to the flare for each image of the store with an
+// SBOM, named after the ID of the image.
+func addImageSBOMs(fb helpers.FlareBuilder, store workloadmeta.Store) error {
+	for _, img := range store.ListImages() {
+		data, format, err := ImageSBOM(img)
+		if err != nil {
+			return fmt.Errorf("error serializing the SBOM of image %s: %w", img.ID, err)
+		}
+
+		if data == nil {
+			continue
+		}
+
+		name := strings.ReplaceAll(img.ID, ":", "_") + fileExtensions[format]
+		if err := fb.AddFile(filepath.Join(flareDir, name), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package format serializes the SBOMs collected by the agent, which are
+// stored as CycloneDX BOMs, into the formats consumed outside of the agent.
+package format
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// Format is a serialization format for SBOMs.
+type Format string
+
+const (
+	// CycloneDXJSON is the JSON format of CycloneDX.
+	CycloneDXJSON Format = "cyclonedx-json"
+
+	// SPDXJSON is the JSON format of SPDX 2.2.
+	SPDXJSON Format = "spdx-json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case CycloneDXJSON, SPDXJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown SBOM format %q, expected %q or %q", name, CycloneDXJSON, SPDXJSON)
+	}
+}
+
+// ConfiguredFormat returns the format set in container_image_collection.sbom.export_format.
+func ConfiguredFormat() (Format, error) {
+	return ParseFormat(config.Datadog.GetString("container_image_collection.sbom.export_format"))
+}
+
+// Marshal serializes the SBOM in the given format.
+func Marshal(bom *cyclonedx.BOM, format Format) ([]byte, error) {
+	if bom == nil {
+		return nil, fmt.Errorf("no SBOM to serialize")
+	}
+
+	switch format {
+	case CycloneDXJSON:
+		var buf bytes.Buffer
+		if err := cyclonedx.NewBOMEncoder(&buf, cyclonedx.BOMFileFormatJSON).Encode(bom); err != nil {
+			return nil, fmt.Errorf("error serializing SBOM to CycloneDX: %w", err)
+		}
+		return buf.Bytes(), nil
+	case SPDXJSON:
+		return marshalSPDX(bom)
+	default:
+		return nil, fmt.Errorf("unknown SBOM format %q", format)
+	}
+}
+
+// Unmarshal parses an SBOM serialized in the given format. SPDX documents
+// are converted back to CycloneDX, keeping the name, version and PURL of
+// their packages.
+func Unmarshal(data []byte, format Format) (*cyclonedx.BOM, error) {
+	switch format {
+	case CycloneDXJSON:
+		bom := cyclonedx.NewBOM()
+		if err := cyclonedx.NewBOMDecoder(bytes.NewReader(data), cyclonedx.BOMFileFormatJSON).Decode(bom); err != nil {
+			return nil, fmt.Errorf("error parsing CycloneDX SBOM: %w", err)
+		}
+		return bom, nil
+	case SPDXJSON:
+		return unmarshalSPDX(data)
+	default:
+		return nil, fmt.Errorf("unknown SBOM format %q", format)
+	}
+}
+
+// ImageSBOM returns the SBOM of the image serialized in the configured
+// format, or nil if the SBOM of the image has not been collected.
+func ImageSBOM(img *workloadmeta.ContainerImageMetadata) ([]byte, Format, error) {
+	format, err := ConfiguredFormat()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if img.CycloneDXBOM == nil {
+		return nil, format, nil
+	}
+
+	data, err := Marshal(img.CycloneDXBOM, format)
+	return data, format, err
+}
@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package format
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+	workloadmetatesting "github.com/DataDog/datadog-agent/pkg/workloadmeta/testing"
+)
+
+func newTestBOM() *cyclonedx.BOM {
+	bom := cyclonedx.NewBOM()
+	bom.SerialNumber = "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
+	bom.Metadata = &cyclonedx.Metadata{
+		Timestamp: "2023-01-02T15:04:05Z",
+		Tools: &[]cyclonedx.Tool{
+			{Vendor: "aquasecurity", Name: "trivy", Version: "0.35.0"},
+		},
+		Component: &cyclonedx.Component{
+			Type: cyclonedx.ComponentTypeContainer,
+			Name: "docker.io/datadog/agent:7",
+		},
+	}
+	bom.Components = &[]cyclonedx.Component{
+		{
+			Type:    cyclonedx.ComponentTypeOS,
+			Name:    "debian",
+			Version: "11.6",
+			Components: &[]cyclonedx.Component{
+				{
+					BOMRef:     "pkg:deb/debian/libc6@2.31-13?distro=debian-11.6",
+					Type:       cyclonedx.ComponentTypeLibrary,
+					Name:       "libc6",
+					Version:    "2.31-13",
+					PackageURL: "pkg:deb/debian/libc6@2.31-13?distro=debian-11.6",
+				},
+			},
+		},
+		{
+			BOMRef:     "pkg:golang/github.com/gogo/protobuf@v1.3.2",
+			Type:       cyclonedx.ComponentTypeLibrary,
+			Name:       "github.com/gogo/protobuf",
+			Version:    "v1.3.2",
+			PackageURL: "pkg:golang/github.com/gogo/protobuf@v1.3.2",
+		},
+	}
+
+	return bom
+}
+
+type testPackage struct {
+	name    string
+	version string
+	purl    string
+}
+
+func packagesOf(components []cyclonedx.Component) []testPackage {
+	var packages []testPackage
+	for _, component := range flattenComponents(components) {
+		packages = append(packages, testPackage{
+			name:    component.Name,
+			version: component.Version,
+			purl:    component.PackageURL,
+		})
+	}
+	return packages
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"cyclonedx-json", "spdx-json"} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, Format(name), format)
+	}
+
+	_, err := ParseFormat("spdx-tag-value")
+	assert.Error(t, err)
+}
+
+func TestCycloneDXRoundTrip(t *testing.T) {
+	bom := newTestBOM()
+
+	data, err := Marshal(bom, CycloneDXJSON)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "CycloneDX", raw["bomFormat"])
+
+	parsed, err := Unmarshal(data, CycloneDXJSON)
+	require.NoError(t, err)
+
+	assert.Equal(t, bom.SerialNumber, parsed.SerialNumber)
+	assert.Equal(t, bom.Metadata, parsed.Metadata)
+	assert.Equal(t, bom.Components, parsed.Components)
+}
+
+func TestSPDXRoundTrip(t *testing.T) {
+	bom := newTestBOM()
+
+	data, err := Marshal(bom, SPDXJSON)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "SPDX-2.2", raw["spdxVersion"])
+	assert.Equal(t, "docker.io/datadog/agent:7", raw["name"])
+	assert.Equal(t, "https://datadoghq.com/spdx/3e671687-395b-41f5-a30f-a58921a69b79", raw["documentNamespace"])
+	assert.Len(t, raw["packages"], 3)
+	assert.Len(t, raw["documentDescribes"], 3)
+
+	parsed, err := Unmarshal(data, SPDXJSON)
+	require.NoError(t, err)
+
+	assert.Equal(t, bom.SerialNumber, parsed.SerialNumber)
+	assert.Equal(t, bom.Metadata.Timestamp, parsed.Metadata.Timestamp)
+	require.NotNil(t, parsed.Components)
+	assert.ElementsMatch(t, packagesOf(*bom.Components), packagesOf(*parsed.Components))
+
+	// Converting it again gives the same document
+	again, err := Marshal(parsed, SPDXJSON)
+	require.NoError(t, err)
+	reparsed, err := Unmarshal(again, SPDXJSON)
+	require.NoError(t, err)
+	assert.Equal(t, parsed, reparsed)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(nil, CycloneDXJSON)
+	assert.Error(t, err)
+
+	_, err = Marshal(newTestBOM(), Format("xml"))
+	assert.Error(t, err)
+
+	_, err = Unmarshal([]byte("not json"), SPDXJSON)
+	assert.Error(t, err)
+}
+
+func TestImageSBOM(t *testing.T) {
+	cfg := config.Mock(t)
+
+	img := &workloadmeta.ContainerImageMetadata{}
+
+	data, format, err := ImageSBOM(img)
+	require.NoError(t, err)
+	assert.Equal(t, CycloneDXJSON, format)
+	assert.Nil(t, data)
+
+	img.CycloneDXBOM = newTestBOM()
+	cfg.Set("container_image_collection.sbom.export_format", "spdx-json")
+
+	data, format, err = ImageSBOM(img)
+	require.NoError(t, err)
+	assert.Equal(t, SPDXJSON, format)
+
+	parsed, err := Unmarshal(data, SPDXJSON)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, packagesOf(*img.CycloneDXBOM.Components), packagesOf(*parsed.Components))
+
+	cfg.Set("container_image_collection.sbom.export_format", "yaml")
+	_, _, err = ImageSBOM(img)
+	assert.Error(t, err)
+}
+
+func TestFlareProvider(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.sbom.export_format", "spdx-json")
+
+	store := workloadmetatesting.NewStore()
+	store.Set(&workloadmeta.ContainerImageMetadata{
+		EntityID:     workloadmeta.EntityID{Kind: workloadmeta.KindContainerImageMetadata, ID: "sha256:1"},
+		CycloneDXBOM: newTestBOM(),
+	})
+	// Not scanned yet
+	store.Set(&workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{Kind: workloadmeta.KindContainerImageMetadata, ID: "sha256:2"},
+	})
+
+	flareBuilder := helpers.NewFlareBuilderMock(t)
+	require.NoError(t, addImageSBOMs(flareBuilder.Fb, store))
+
+	flareBuilder.AssertFileExists("sbom", "sha256_1.spdx.json")
+	flareBuilder.AssertNoFileExists("sbom", "sha256_2.spdx.json")
+
+	// The configured format is wrong: reported as the error of the provider
+	cfg.Set("container_image_collection.sbom.export_format", "yaml")
+	assert.Error(t, addImageSBOMs(flareBuilder.Fb, store))
+}
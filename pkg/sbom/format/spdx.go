@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/spdx/tools-golang/jsonloader"
+	"github.com/spdx/tools-golang/jsonsaver"
+	"github.com/spdx/tools-golang/spdx"
+)
+
+const (
+	spdxVersion     = "SPDX-2.2"
+	spdxDataLicense = "CC0-1.0"
+	spdxDocumentID  = "DOCUMENT"
+	spdxNoAssertion = "NOASSERTION"
+	spdxCreatorTool = "datadog-agent"
+
+	// The namespace of the documents is built from the serial number of the
+	// BOMs, so that they can be converted back.
+	spdxNamespacePrefix = "https://datadoghq.com/spdx/"
+	uuidURNPrefix       = "urn:uuid:"
+
+	purlCategory = "PACKAGE-MANAGER"
+	purlType     = "purl"
+)
+
+var uuidPattern = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// marshalSPDX converts the components of the BOM to the packages of an SPDX
+// document, described by the document, and serializes it.
+func marshalSPDX(bom *cyclonedx.BOM) ([]byte, error) {
+	doc := &spdx.Document2_2{
+		CreationInfo: spdxCreationInfo(bom),
+		Packages:     make(map[spdx.ElementID]*spdx.Package2_2),
+	}
+
+	var components []cyclonedx.Component
+	if bom.Components != nil {
+		components = flattenComponents(*bom.Components)
+	}
+
+	for i, component := range components {
+		pkg := spdxPackage(component, spdx.ElementID(fmt.Sprintf("Package-%d", i)))
+		doc.Packages[pkg.PackageSPDXIdentifier] = pkg
+		doc.Relationships = append(doc.Relationships, &spdx.Relationship2_2{
+			RefA:         spdx.MakeDocElementID("", spdxDocumentID),
+			RefB:         spdx.MakeDocElementID("", string(pkg.PackageSPDXIdentifier)),
+			Relationship: "DESCRIBES",
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := jsonsaver.Save2_2(doc, &buf); err != nil {
+		return nil, fmt.Errorf("error serializing SBOM to SPDX: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func spdxCreationInfo(bom *cyclonedx.BOM) *spdx.CreationInfo2_2 {
+	name := "sbom"
+	created := time.Now().UTC().Format(time.RFC3339)
+	tools := []string{spdxCreatorTool}
+
+	if bom.Metadata != nil {
+		if bom.Metadata.Component != nil && bom.Metadata.Component.Name != "" {
+			name = bom.Metadata.Component.Name
+		}
+		if bom.Metadata.Timestamp != "" {
+			created = bom.Metadata.Timestamp
+		}
+		if bom.Metadata.Tools != nil {
+			for _, tool := range *bom.Metadata.Tools {
+				if tool.Version != "" {
+					tools = append(tools, tool.Name+"-"+tool.Version)
+				} else if tool.Name != "" {
+					tools = append(tools, tool.Name)
+				}
+			}
+		}
+	}
+
+	namespaceID := strings.TrimPrefix(bom.SerialNumber, uuidURNPrefix)
+	if !uuidPattern.MatchString(namespaceID) {
+		namespaceID = name + "-" + created
+	}
+
+	return &spdx.CreationInfo2_2{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       spdxDataLicense,
+		SPDXIdentifier:    spdxDocumentID,
+		DocumentName:      name,
+		DocumentNamespace: spdxNamespacePrefix + namespaceID,
+		CreatorTools:      tools,
+		Created:           created,
+	}
+}
+
+func spdxPackage(component cyclonedx.Component, id spdx.ElementID) *spdx.Package2_2 {
+	pkg := &spdx.Package2_2{
+		PackageName:               component.Name,
+		PackageSPDXIdentifier:     id,
+		PackageVersion:            component.Version,
+		PackageDownloadLocation:   spdxNoAssertion,
+		FilesAnalyzed:             false,
+		IsFilesAnalyzedTagPresent: true,
+		PackageLicenseConcluded:   spdxNoAssertion,
+		PackageLicenseDeclared:    spdxNoAssertion,
+		PackageCopyrightText:      spdxNoAssertion,
+		PackageDescription:        component.Description,
+	}
+
+	if component.PackageURL != "" {
+		pkg.PackageExternalReferences = []*spdx.PackageExternalReference2_2{
+			{
+				Category: purlCategory,
+				RefType:  purlType,
+				Locator:  component.PackageURL,
+			},
+		}
+	}
+
+	return pkg
+}
+
+// flattenComponents returns the components and their subcomponents, as SPDX
+// packages are not nested.
+func flattenComponents(components []cyclonedx.Component) []cyclonedx.Component {
+	var flattened []cyclonedx.Component
+	for _, component := range components {
+		flattened = append(flattened, component)
+		if component.Components != nil {
+			flattened = append(flattened, flattenComponents(*component.Components)...)
+		}
+	}
+	return flattened
+}
+
+// unmarshalSPDX parses an SPDX document, and converts its packages to
+// library components.
+func unmarshalSPDX(data []byte) (*cyclonedx.BOM, error) {
+	doc, err := jsonloader.Load2_2(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SPDX SBOM: %w", err)
+	}
+
+	bom := cyclonedx.NewBOM()
+
+	if doc.CreationInfo != nil {
+		if namespaceID := strings.TrimPrefix(doc.CreationInfo.DocumentNamespace, spdxNamespacePrefix); uuidPattern.MatchString(namespaceID) {
+			bom.SerialNumber = uuidURNPrefix + namespaceID
+		}
+		bom.Metadata = &cyclonedx.Metadata{
+			Timestamp: doc.CreationInfo.Created,
+		}
+	}
+
+	// The packages are stored in a map, sort them to get the same BOM every
+	// time
+	ids := make([]spdx.ElementID, 0, len(doc.Packages))
+	for id := range doc.Packages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	components := make([]cyclonedx.Component, 0, len(ids))
+	for _, id := range ids {
+		pkg := doc.Packages[id]
+
+		component := cyclonedx.Component{
+			Type:        cyclonedx.ComponentTypeLibrary,
+			Name:        pkg.PackageName,
+			Version:     pkg.PackageVersion,
+			Description: pkg.PackageDescription,
+		}
+
+		for _, ref := range pkg.PackageExternalReferences {
+			if ref.Category == purlCategory && ref.RefType == purlType {
+				component.PackageURL = ref.Locator
+				component.BOMRef = ref.Locator
+				break
+			}
+		}
+
+		components = append(components, component)
+	}
+	bom.Components = &components
+
+	return bom, nil
+}
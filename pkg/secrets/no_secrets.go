@@ -25,6 +25,11 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 	return data, nil
 }
 
+// FetchSecrets placeholder when compiled without the 'secrets' build tag
+func FetchSecrets(handles []string, origin string) (map[string]string, error) {
+	return nil, fmt.Errorf("Secret feature is not available in this version of the agent")
+}
+
 // GetDebugInfo exposes debug informations about secrets to be included in a flare
 func GetDebugInfo() (*SecretInfo, error) {
 	return nil, fmt.Errorf("Secret feature is not available in this version of the agent")
@@ -198,6 +198,16 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 	return finalConfig, nil
 }
 
+// FetchSecrets fetches the given secret handles by executing
+// "secret_backend_command" once.  Origin should be the name of the component
+// or configuration requesting the secrets.
+func FetchSecrets(handles []string, origin string) (map[string]string, error) {
+	if secretBackendCommand == "" {
+		return nil, fmt.Errorf("No secret_backend_command set: secrets feature is not enabled")
+	}
+	return secretFetcher(handles, origin)
+}
+
 // GetDebugInfo exposes debug informations about secrets to be included in a flare
 func GetDebugInfo() (*SecretInfo, error) {
 	if secretBackendCommand == "" {
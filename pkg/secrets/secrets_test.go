@@ -342,3 +342,24 @@ func TestDebugInfo(t *testing.T) {
 		"pass3": {"test2"},
 	}, handles)
 }
+
+func TestFetchSecrets(t *testing.T) {
+	_, err := FetchSecrets([]string{"pass1"}, "test")
+	require.NotNil(t, err)
+
+	secretBackendCommand = "some_command"
+	defer func() {
+		secretBackendCommand = ""
+		secretFetcher = fetchSecret
+	}()
+
+	secretFetcher = func(secrets []string, origin string) (map[string]string, error) {
+		assert.Equal(t, []string{"pass1"}, secrets)
+		assert.Equal(t, "test", origin)
+		return map[string]string{"pass1": "password1"}, nil
+	}
+
+	secrets, err := FetchSecrets([]string{"pass1"}, "test")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"pass1": "password1"}, secrets)
+}
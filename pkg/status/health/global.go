@@ -23,6 +23,21 @@ func RegisterLiveness(name string) *Handle {
 	return readinessAndLivenessCatalog.register(name)
 }
 
+// RegisterOptional registers an optional component for readiness check with the default 30 seconds
+// timeout, returns a token. When it's unhealthy, it's reported as degraded, without making the agent
+// unready.
+func RegisterOptional(name string) *Handle {
+	return readinessOnlyCatalog.registerOptional(name)
+}
+
+// SetUnhealthy reports a component as unhealthy for the given reason, until it's deregistered
+func SetUnhealthy(handle *Handle, reason string) error {
+	if readinessAndLivenessCatalog.setUnhealthy(handle, reason) == nil {
+		return nil
+	}
+	return readinessOnlyCatalog.setUnhealthy(handle, reason)
+}
+
 // Deregister a component from the healthcheck
 func Deregister(handle *Handle) error {
 	if readinessAndLivenessCatalog.deregister(handle) == nil {
@@ -42,6 +57,15 @@ func GetReady() (ret Status) {
 	readyStatus := readinessOnlyCatalog.getStatus()
 	ret.Healthy = append(liveStatus.Healthy, readyStatus.Healthy...)
 	ret.Unhealthy = append(liveStatus.Unhealthy, readyStatus.Unhealthy...)
+	ret.Degraded = append(liveStatus.Degraded, readyStatus.Degraded...)
+	for _, reasons := range []map[string]string{liveStatus.Reasons, readyStatus.Reasons} {
+		for name, reason := range reasons {
+			if ret.Reasons == nil {
+				ret.Reasons = make(map[string]string)
+			}
+			ret.Reasons[name] = reason
+		}
+	}
 	return
 }
 
@@ -24,10 +24,21 @@ func (h *Handle) Deregister() error {
 	return Deregister(h)
 }
 
+// SetUnhealthy allows a component to easily report itself as unhealthy, see
+// SetUnhealthy
+func (h *Handle) SetUnhealthy(reason string) error {
+	return SetUnhealthy(h, reason)
+}
+
 type component struct {
 	name       string
 	healthChan chan time.Time
 	healthy    bool
+	// optional components don't make the agent unhealthy, they're reported
+	// as degraded instead
+	optional bool
+	// reason why the component is unhealthy, given to setUnhealthy
+	reason string
 }
 
 type catalog struct {
@@ -45,6 +56,15 @@ func newCatalog() *catalog {
 
 // register a component with the default 30 seconds timeout, returns a token
 func (c *catalog) register(name string) *Handle {
+	return c.registerComponent(name, false)
+}
+
+// registerOptional registers an optional component, see RegisterOptional
+func (c *catalog) registerOptional(name string) *Handle {
+	return c.registerComponent(name, true)
+}
+
+func (c *catalog) registerComponent(name string, optional bool) *Handle {
 	c.Lock()
 	defer c.Unlock()
 
@@ -56,6 +76,7 @@ func (c *catalog) register(name string) *Handle {
 		name:       name,
 		healthChan: make(chan time.Time, bufferSize),
 		healthy:    false,
+		optional:   optional,
 	}
 	h := &Handle{
 		C: component.healthChan,
@@ -96,6 +117,10 @@ func (c *catalog) pingComponents(healthDeadline time.Time) bool {
 	c.Lock()
 	defer c.Unlock()
 	for _, component := range c.components {
+		if component.reason != "" {
+			// Unhealthy until deregistered
+			continue
+		}
 		select {
 		case component.healthChan <- healthDeadline:
 			component.healthy = true
@@ -107,6 +132,20 @@ func (c *catalog) pingComponents(healthDeadline time.Time) bool {
 	return len(c.components) == 0
 }
 
+// setUnhealthy marks a component as unhealthy for the given reason, until
+// it's deregistered
+func (c *catalog) setUnhealthy(handle *Handle, reason string) error {
+	c.Lock()
+	defer c.Unlock()
+	component, found := c.components[handle]
+	if !found {
+		return errors.New("component not registered")
+	}
+	component.healthy = false
+	component.reason = reason
+	return nil
+}
+
 // deregister a component from the healthcheck
 func (c *catalog) deregister(handle *Handle) error {
 	c.Lock()
@@ -124,6 +163,12 @@ func (c *catalog) deregister(handle *Handle) error {
 type Status struct {
 	Healthy   []string
 	Unhealthy []string
+	// Degraded are the unhealthy optional components, which don't make the
+	// agent unhealthy
+	Degraded []string `json:",omitempty" yaml:",omitempty"`
+	// Reasons are the reasons why components are unhealthy, by name, for
+	// the ones that gave one
+	Reasons map[string]string `json:",omitempty" yaml:",omitempty"`
 }
 
 // getStatus allows to query the health status of the agent
@@ -147,11 +192,21 @@ func (c *catalog) getStatus() Status {
 
 	// Check components
 	for _, component := range c.components {
-		if component.healthy {
+		switch {
+		case component.healthy:
 			status.Healthy = append(status.Healthy, component.name)
-		} else {
+		case component.optional:
+			status.Degraded = append(status.Degraded, component.name)
+		default:
 			status.Unhealthy = append(status.Unhealthy, component.name)
 		}
+
+		if !component.healthy && component.reason != "" {
+			if status.Reasons == nil {
+				status.Reasons = make(map[string]string)
+			}
+			status.Reasons[component.name] = component.reason
+		}
 	}
 	return status
 }
@@ -123,3 +123,44 @@ func TestGetHealthy(t *testing.T) {
 	assert.Len(t, status.Healthy, 2)
 	assert.Len(t, status.Unhealthy, 0)
 }
+
+func TestOptionalComponentIsDegraded(t *testing.T) {
+	cat := newCatalog()
+	token := cat.registerOptional("test1")
+
+	// Start degraded instead of unhealthy
+	status := cat.getStatus()
+	assert.Equal(t, []string{"healthcheck"}, status.Healthy)
+	assert.Empty(t, status.Unhealthy)
+	assert.Equal(t, []string{"test1"}, status.Degraded)
+
+	<-token.C
+	cat.pingComponents(time.Time{})
+	status = cat.getStatus()
+	assert.Len(t, status.Healthy, 2)
+	assert.Empty(t, status.Degraded)
+}
+
+func TestSetUnhealthy(t *testing.T) {
+	cat := newCatalog()
+	token := cat.register("test1")
+	optionalToken := cat.registerOptional("test2")
+
+	<-token.C
+	<-optionalToken.C
+	cat.pingComponents(time.Time{})
+	require.Len(t, cat.getStatus().Healthy, 3)
+
+	require.NoError(t, cat.setUnhealthy(token, "stuck"))
+	require.NoError(t, cat.setUnhealthy(optionalToken, "self-test failed"))
+	assert.Error(t, cat.setUnhealthy(nil, "unknown"))
+
+	// Stays unhealthy even when reading its channel
+	<-token.C
+	cat.pingComponents(time.Time{})
+	status := cat.getStatus()
+	assert.Equal(t, []string{"healthcheck"}, status.Healthy)
+	assert.Equal(t, []string{"test1"}, status.Unhealthy)
+	assert.Equal(t, []string{"test2"}, status.Degraded)
+	assert.Equal(t, map[string]string{"test1": "stuck", "test2": "self-test failed"}, status.Reasons)
+}
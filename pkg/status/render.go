@@ -55,6 +55,7 @@ func FormatStatus(data []byte) (string, error) {
 	systemProbeStats := stats["systemProbeStats"]
 	processAgentStatus := stats["processAgentStatus"]
 	snmpTrapsStats := stats["snmpTrapsStats"]
+	sbomStats := stats["sbomStats"]
 	title := fmt.Sprintf("Agent (v%s)", stats["version"])
 	stats["title"] = title
 
@@ -86,6 +87,11 @@ func FormatStatus(data []byte) (string, error) {
 			renderStatusTemplate(b, "/snmp-traps.tmpl", snmpTrapsStats)
 		}
 	}
+	sbomFunc := func() {
+		if sbomStats != nil {
+			renderStatusTemplate(b, "/sbom.tmpl", sbomStats)
+		}
+	}
 	autodiscoveryFunc := func() {
 		if config.IsContainerized() {
 			renderAutodiscoveryStats(b, stats["adEnabledFeatures"], stats["adConfigErrors"],
@@ -107,7 +113,7 @@ func FormatStatus(data []byte) (string, error) {
 	} else {
 		renderFuncs = []func(){headerFunc, checkStatsFunc, jmxFetchFunc, forwarderFunc, endpointsFunc,
 			logsAgentFunc, systemProbeFunc, processAgentFunc, traceAgentFunc, aggregatorFunc, dogstatsdFunc,
-			clusterAgentFunc, snmpTrapFunc, autodiscoveryFunc, otlpFunc}
+			clusterAgentFunc, snmpTrapFunc, sbomFunc, autodiscoveryFunc, otlpFunc}
 	}
 
 	renderAgentSections(renderFuncs)
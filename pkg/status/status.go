@@ -398,6 +398,16 @@ func expvarStats(stats map[string]interface{}) (map[string]interface{}, error) {
 		stats["pythonInit"] = nil
 	}
 
+	sbomData := expvar.Get("sbom")
+	if sbomData != nil {
+		sbomStatsJSON := []byte(sbomData.String())
+		sbomStats := make(map[string]interface{})
+		json.Unmarshal(sbomStatsJSON, &sbomStats) //nolint:errcheck
+		stats["sbomStats"] = sbomStats
+	} else {
+		stats["sbomStats"] = nil
+	}
+
 	hostnameStatsJSON := []byte(expvar.Get("hostname").String())
 	hostnameStats := make(map[string]interface{})
 	json.Unmarshal(hostnameStatsJSON, &hostnameStats) //nolint:errcheck
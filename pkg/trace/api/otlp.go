@@ -49,11 +49,21 @@ type OTLPReceiver struct {
 	out         chan<- *Payload     // the outgoing payload channel
 	conf        *config.AgentConfig // receiver config
 	cidProvider IDProvider          // container ID provider
+	spanEvents  SpanEventConsumer   // consumer of the span events, if any
 }
 
-// NewOTLPReceiver returns a new OTLPReceiver which sends any incoming traces down the out channel.
-func NewOTLPReceiver(out chan<- *Payload, cfg *config.AgentConfig) *OTLPReceiver {
-	return &OTLPReceiver{out: out, conf: cfg, cidProvider: NewIDProvider(cfg.ContainerProcRoot)}
+// SpanEventConsumer consumes the events of the received OTLP spans, e.g. to
+// report them as logs. The IDs are hex-encoded, and the timestamp is in
+// nanoseconds since the epoch.
+type SpanEventConsumer interface {
+	ConsumeSpanEvent(traceID, spanID string, name string, ts uint64, attrs map[string]string)
+}
+
+// NewOTLPReceiver returns a new OTLPReceiver which sends any incoming traces down the out channel,
+// and the events of their spans to spanEvents. When spanEvents is nil, the events are only kept
+// in the "events" tag of their span.
+func NewOTLPReceiver(out chan<- *Payload, cfg *config.AgentConfig, spanEvents SpanEventConsumer) *OTLPReceiver {
+	return &OTLPReceiver{out: out, conf: cfg, cidProvider: NewIDProvider(cfg.ContainerProcRoot), spanEvents: spanEvents}
 }
 
 // Start starts the OTLPReceiver, if any of the servers were configured as active.
@@ -285,6 +295,21 @@ func (o *OTLPReceiver) ReceiveResourceSpans(ctx context.Context, rspans ptrace.R
 	return src
 }
 
+// consumeSpanEvents sends the events of the span to the SpanEventConsumer.
+func (o *OTLPReceiver) consumeSpanEvents(traceID [16]byte, in ptrace.Span) {
+	spanID := [8]byte(in.SpanID())
+	traceIDHex, spanIDHex := hex.EncodeToString(traceID[:]), hex.EncodeToString(spanID[:])
+	for i := 0; i < in.Events().Len(); i++ {
+		e := in.Events().At(i)
+		attrs := make(map[string]string, e.Attributes().Len())
+		e.Attributes().Range(func(k string, v pcommon.Value) bool {
+			attrs[k] = v.AsString()
+			return true
+		})
+		o.spanEvents.ConsumeSpanEvent(traceIDHex, spanIDHex, e.Name(), uint64(e.Timestamp()), attrs)
+	}
+}
+
 // marshalEvents marshals events into JSON.
 func marshalEvents(events ptrace.SpanEventSlice) string {
 	var str strings.Builder
@@ -402,6 +427,9 @@ func (o *OTLPReceiver) convertSpan(rattr map[string]string, lib pcommon.Instrume
 	}
 	if in.Events().Len() > 0 {
 		setMetaOTLP(span, "events", marshalEvents(in.Events()))
+		if o.spanEvents != nil {
+			o.consumeSpanEvents(traceID, in)
+		}
 	}
 	if svc, ok := in.Attributes().Get(semconv.AttributePeerService); ok {
 		// the span attribute "peer.service" takes precedence over any resource attributes,
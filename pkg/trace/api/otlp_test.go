@@ -95,7 +95,7 @@ func TestOTLPMetrics(t *testing.T) {
 	defer testutil.WithStatsClient(stats)()
 
 	out := make(chan *Payload, 1)
-	rcv := NewOTLPReceiver(out, cfg)
+	rcv := NewOTLPReceiver(out, cfg, nil)
 	rspans := testutil.NewOTLPTracesRequest([]testutil.OTLPResourceSpan{
 		{
 			LibName:    "libname",
@@ -133,7 +133,7 @@ func TestOTLPNameRemapping(t *testing.T) {
 	cfg := config.New()
 	cfg.OTLPReceiver.SpanNameRemappings = map[string]string{"libname.unspecified": "new"}
 	out := make(chan *Payload, 1)
-	rcv := NewOTLPReceiver(out, cfg)
+	rcv := NewOTLPReceiver(out, cfg, nil)
 	rcv.ReceiveResourceSpans(context.Background(), testutil.NewOTLPTracesRequest([]testutil.OTLPResourceSpan{
 		{
 			LibName:    "libname",
@@ -156,7 +156,7 @@ func TestOTLPNameRemapping(t *testing.T) {
 func TestOTLPReceiveResourceSpans(t *testing.T) {
 	cfg := config.New()
 	out := make(chan *Payload, 1)
-	rcv := NewOTLPReceiver(out, cfg)
+	rcv := NewOTLPReceiver(out, cfg, nil)
 	require := require.New(t)
 	for _, tt := range []struct {
 		in []testutil.OTLPResourceSpan
@@ -437,7 +437,7 @@ func TestOTLPHostname(t *testing.T) {
 		cfg := config.New()
 		cfg.Hostname = tt.config
 		out := make(chan *Payload, 1)
-		rcv := NewOTLPReceiver(out, cfg)
+		rcv := NewOTLPReceiver(out, cfg, nil)
 		rattr := map[string]interface{}{}
 		if tt.resource != "" {
 			rattr["datadog.host.name"] = tt.resource
@@ -469,11 +469,11 @@ func TestOTLPHostname(t *testing.T) {
 func TestOTLPReceiver(t *testing.T) {
 	t.Run("New", func(t *testing.T) {
 		cfg := config.New()
-		assert.NotNil(t, NewOTLPReceiver(nil, cfg).conf)
+		assert.NotNil(t, NewOTLPReceiver(nil, cfg, nil).conf)
 	})
 
 	t.Run("Start/nil", func(t *testing.T) {
-		o := NewOTLPReceiver(nil, config.New())
+		o := NewOTLPReceiver(nil, config.New(), nil)
 		o.Start()
 		defer o.Stop()
 		assert.Nil(t, o.grpcsrv)
@@ -486,7 +486,7 @@ func TestOTLPReceiver(t *testing.T) {
 			BindHost: "localhost",
 			GRPCPort: port,
 		}
-		o := NewOTLPReceiver(nil, cfg)
+		o := NewOTLPReceiver(nil, cfg, nil)
 		o.Start()
 		defer o.Stop()
 		assert := assert.New(t)
@@ -499,7 +499,7 @@ func TestOTLPReceiver(t *testing.T) {
 
 	t.Run("processRequest", func(t *testing.T) {
 		out := make(chan *Payload, 5)
-		o := NewOTLPReceiver(out, config.New())
+		o := NewOTLPReceiver(out, config.New(), nil)
 		o.processRequest(context.Background(), http.Header(map[string][]string{
 			header.Lang:        {"go"},
 			header.ContainerID: {"containerdID"},
@@ -742,10 +742,72 @@ func TestOTLPHelpers(t *testing.T) {
 	})
 }
 
+type spanEvent struct {
+	traceID, spanID, name string
+	ts                    uint64
+	attrs                 map[string]string
+}
+
+type mockSpanEventConsumer struct {
+	events []spanEvent
+}
+
+func (c *mockSpanEventConsumer) ConsumeSpanEvent(traceID, spanID string, name string, ts uint64, attrs map[string]string) {
+	c.events = append(c.events, spanEvent{traceID: traceID, spanID: spanID, name: name, ts: ts, attrs: attrs})
+}
+
+func TestOTLPSpanEventConsumer(t *testing.T) {
+	consumer := &mockSpanEventConsumer{}
+	o := NewOTLPReceiver(nil, config.New(), consumer)
+
+	span := testutil.NewOTLPSpan(&testutil.OTLPSpan{
+		TraceID: otlpTestTraceID,
+		SpanID:  otlpTestSpanID,
+		Name:    "/path",
+		Events: []testutil.OTLPSpanEvent{
+			{
+				Timestamp:  123,
+				Name:       "boom",
+				Attributes: map[string]interface{}{"message": "Out of memory", "accuracy": 2.4},
+			},
+			{
+				Timestamp: 456,
+				Name:      "retry",
+			},
+		},
+	})
+	out := o.convertSpan(nil, pcommon.NewInstrumentationScope(), span)
+
+	assert.Equal(t, []spanEvent{
+		{
+			traceID: "72df520af2bde7a5240031ead750e5f3",
+			spanID:  "240031ead750e5f3",
+			name:    "boom",
+			ts:      123,
+			attrs:   map[string]string{"message": "Out of memory", "accuracy": "2.4"},
+		},
+		{
+			traceID: "72df520af2bde7a5240031ead750e5f3",
+			spanID:  "240031ead750e5f3",
+			name:    "retry",
+			ts:      456,
+			attrs:   map[string]string{},
+		},
+	}, consumer.events)
+
+	// the events are still in the span
+	assert.Contains(t, out.Meta, "events")
+
+	// spans without events are not consumed
+	consumer.events = nil
+	o.convertSpan(nil, pcommon.NewInstrumentationScope(), testutil.NewOTLPSpan(&testutil.OTLPSpan{Name: "/path"}))
+	assert.Empty(t, consumer.events)
+}
+
 func TestOTLPConvertSpan(t *testing.T) {
 	now := uint64(otlpTestSpan.StartTimestamp())
 	cfg := config.New()
-	o := NewOTLPReceiver(nil, cfg)
+	o := NewOTLPReceiver(nil, cfg, nil)
 	for i, tt := range []struct {
 		rattr   map[string]string
 		libname string
@@ -1075,7 +1137,7 @@ func TestResourceAttributesMap(t *testing.T) {
 	rattr := map[string]string{"key": "val"}
 	lib := pcommon.NewInstrumentationScope()
 	span := testutil.NewOTLPSpan(&testutil.OTLPSpan{})
-	NewOTLPReceiver(nil, config.New()).convertSpan(rattr, lib, span)
+	NewOTLPReceiver(nil, config.New(), nil).convertSpan(rattr, lib, span)
 	assert.Len(t, rattr, 1) // ensure "rattr" has no new entries
 	assert.Equal(t, "val", rattr["key"])
 }
@@ -1252,7 +1314,7 @@ func BenchmarkProcessRequest(b *testing.B) {
 		}
 	}()
 
-	r := NewOTLPReceiver(out, nil)
+	r := NewOTLPReceiver(out, nil, nil)
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -65,6 +65,7 @@ type ContainerdItf interface {
 	Annotations(namespace string, ctn containerd.Container) (map[string]string, error)
 	IsSandbox(namespace string, ctn containerd.Container) (bool, error)
 	MountImage(ctx context.Context, expiration time.Duration, namespace string, img containerd.Image, targetDir string) (func(context.Context) error, error)
+	Snapshotter(namespace string) (string, error)
 }
 
 // ContainerdUtil is the util used to interact with the Containerd api.
@@ -141,6 +142,30 @@ func (c *ContainerdUtil) Metadata() (containerd.Version, error) {
 	return c.cl.Version(ctx)
 }
 
+// Snapshotter returns the name of the snapshotter of the containers of the
+// namespace, which is the one the images are unpacked with, for instance the
+// one configured in the CRI plugin for the "k8s.io" namespace. It's empty
+// when the namespace has no container.
+func (c *ContainerdUtil) Snapshotter(namespace string) (string, error) {
+	ctns, err := c.Containers(namespace)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ctn := range ctns {
+		info, err := c.Info(namespace, ctn)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Snapshotter != "" {
+			return info.Snapshotter, nil
+		}
+	}
+
+	return "", nil
+}
+
 // Close is used when done with a ContainerdUtil
 func (c *ContainerdUtil) Close() error {
 	if c.cl == nil {
@@ -397,7 +422,13 @@ func (c *ContainerdUtil) IsSandbox(namespace string, ctn containerd.Container) (
 }
 
 func (c *ContainerdUtil) MountImage(ctx context.Context, expiration time.Duration, namespace string, img containerd.Image, targetDir string) (func(context.Context) error, error) {
-	snapshotter := containerd.DefaultSnapshotter
+	snapshotter, err := c.Snapshotter(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the snapshotter of namespace %s, err: %w", namespace, err)
+	}
+	if snapshotter == "" {
+		snapshotter = containerd.DefaultSnapshotter
+	}
 	ctx = namespaces.WithNamespace(ctx, namespace)
 
 	// Checking if image is already unpacked
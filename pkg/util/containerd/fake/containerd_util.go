@@ -49,6 +49,7 @@ type MockedContainerdClient struct {
 	MockAnnotations           func(namespace string, ctn containerd.Container) (map[string]string, error)
 	MockIsSandbox             func(namespace string, ctn containerd.Container) (bool, error)
 	MockMountImage            func(ctx context.Context, expiration time.Duration, namespace string, img containerd.Image, targetDir string) (func(context.Context) error, error)
+	MockSnapshotter           func(namespace string) (string, error)
 }
 
 // Close is a mock method
@@ -179,3 +180,8 @@ func (client *MockedContainerdClient) IsSandbox(namespace string, ctn containerd
 func (client *MockedContainerdClient) MountImage(ctx context.Context, expiration time.Duration, namespace string, img containerd.Image, targetDir string) (func(context.Context) error, error) {
 	return client.MockMountImage(ctx, expiration, namespace, img, targetDir)
 }
+
+// Snapshotter is a mock method
+func (client *MockedContainerdClient) Snapshotter(namespace string) (string, error) {
+	return client.MockSnapshotter(namespace)
+}
@@ -39,6 +39,10 @@ func getComponentName() string {
 
 // Bundle is a simple wrapper around fx.Module that automatically determines
 // the bundle name.
+//
+// Fx only reports errors for the whole app, so dependency cycles between the
+// components of a bundle are made readable where the app is built, by Run,
+// OneShot and Test, which list the components involved in the cycle.
 func Bundle(opts ...fx.Option) fx.Option {
 	return fx.Module(getBundleName(), opts...)
 }
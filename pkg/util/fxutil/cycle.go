@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fxutil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// digCycleMarker is the message used by dig when it detects a dependency
+// cycle.  It is followed by one line per constructor in the cycle.
+const digCycleMarker = "cycle detected in dependency graph:"
+
+// digCycleEntryRe matches a line of the cycle path in a dig error, such as
+//
+//	depends on func(log.Params, config.Component) log.Component provided by "github.com/DataDog/datadog-agent/comp/core/log".newLogger (...)
+//
+// capturing the provided type, the package and the function name.
+var digCycleEntryRe = regexp.MustCompile(`(\S+) provided by "([^"]+)"\.(\S+)`)
+
+// cycleError is a dependency cycle error, with a readable description of the
+// cycle in terms of components.
+type cycleError struct {
+	// path is the list of constructors in the cycle, the first one being
+	// repeated at the end.
+	path []string
+	err  error
+}
+
+// Error implements error#Error.
+func (e *cycleError) Error() string {
+	var b strings.Builder
+	b.WriteString("dependency cycle detected between components:\n")
+	for i, entry := range e.path {
+		if i == 0 {
+			fmt.Fprintf(&b, "\t%s\n", entry)
+		} else {
+			fmt.Fprintf(&b, "\t-> %s\n", entry)
+		}
+	}
+	fmt.Fprintf(&b, "(fx error: %s)", e.err)
+	return b.String()
+}
+
+// Unwrap returns the original fx error.
+func (e *cycleError) Unwrap() error {
+	return e.err
+}
+
+// explainCycle returns an error describing the cycle if err is caused by a
+// dependency cycle, or err otherwise.
+//
+// Fx reports cycles with the full signature and source location of every
+// constructor, which is hard to read when the constructors are spread across
+// components.  The returned error lists the components involved instead.
+func explainCycle(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	idx := strings.Index(msg, digCycleMarker)
+	if idx < 0 {
+		return err
+	}
+
+	var path []string
+	for _, line := range strings.Split(msg[idx+len(digCycleMarker):], "\n") {
+		match := digCycleEntryRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		path = append(path, fmt.Sprintf("%s (provides %s)", ownerName(match[2], match[3]), match[1]))
+	}
+	if len(path) == 0 {
+		return err
+	}
+
+	return &cycleError{path: path, err: err}
+}
+
+// ownerName returns the name of the component owning the given constructor,
+// such as `comp/core/log`, or the fully-qualified function name when it is not
+// part of a component.
+func ownerName(pkg, fn string) string {
+	if idx := strings.LastIndex(pkg, "/comp/"); idx >= 0 {
+		return pkg[idx+1:]
+	}
+	return pkg + "." + fn
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fxutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+type cycleA interface{}
+type cycleB interface{}
+
+// cyclicBundle returns a bundle whose two components depend on each other.
+func cyclicBundle() fx.Option {
+	return fx.Module("comp/cyclic",
+		fx.Module("comp/cyclic/a", fx.Provide(newCycleA)),
+		fx.Module("comp/cyclic/b", fx.Provide(newCycleB)),
+	)
+}
+
+func newCycleA(cycleB) cycleA { return nil }
+
+func newCycleB(cycleA) cycleB { return nil }
+
+func TestExplainCycle(t *testing.T) {
+	app := fx.New(fx.NopLogger, cyclicBundle(), fx.Invoke(func(cycleA) {}))
+	require.Error(t, app.Err())
+
+	err := explainCycle(app.Err())
+	require.Equal(t, app.Err(), errors.Unwrap(err))
+	// the path is relative to the test package, since the constructors are
+	// not in a real component
+	assert.Contains(t, err.Error(), `dependency cycle detected between components:
+	github.com/DataDog/datadog-agent/pkg/util/fxutil.newCycleA (provides fxutil.cycleA)
+	-> github.com/DataDog/datadog-agent/pkg/util/fxutil.newCycleB (provides fxutil.cycleB)
+	-> github.com/DataDog/datadog-agent/pkg/util/fxutil.newCycleA (provides fxutil.cycleA)
+(fx error: `)
+}
+
+func TestExplainCycleOtherErrors(t *testing.T) {
+	assert.Nil(t, explainCycle(nil))
+
+	err := errors.New("missing type: fxutil.cycleA")
+	assert.Equal(t, err, explainCycle(err))
+}
+
+func TestOneShotCycle(t *testing.T) {
+	err := OneShot(func(cycleA) {}, cyclicBundle())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency cycle detected between components:\n")
+}
+
+func TestOwnerName(t *testing.T) {
+	assert.Equal(t, "comp/core/log", ownerName("github.com/DataDog/datadog-agent/comp/core/log", "newLogger"))
+	assert.Equal(t, "github.com/DataDog/datadog-agent/pkg/util/fxutil.newCycleA", ownerName("github.com/DataDog/datadog-agent/pkg/util/fxutil", "newCycleA"))
+}
@@ -0,0 +1,19 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fxutil
+
+// DisabledComponents is the set of components disabled at runtime, by
+// component name (such as `comp/core/flare`).
+//
+// Bundles supporting this provide it from their params.  Components that can be
+// disabled take it as an optional dependency, and build a no-op implementation
+// when they are disabled.
+type DisabledComponents map[string]bool
+
+// IsDisabled returns true if the given component is disabled.
+func (d DisabledComponents) IsDisabled(componentName string) bool {
+	return d[componentName]
+}
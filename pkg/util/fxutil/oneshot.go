@@ -44,7 +44,7 @@ func OneShot(oneShotFunc interface{}, opts ...fx.Option) error {
 	startCtx, cancel := context.WithTimeout(context.Background(), app.StartTimeout())
 	defer cancel()
 	if err := app.Start(startCtx); err != nil {
-		return err
+		return explainCycle(err)
 	}
 
 	// call the original oneShotFunc with the args captured during app startup
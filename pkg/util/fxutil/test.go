@@ -6,12 +6,14 @@
 package fxutil
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
 	"go.uber.org/fx/fxtest"
 )
 
@@ -24,13 +26,25 @@ import (
 // Use `fx.Options(..)` to bundle multiple fx.Option values into one.
 func Test(t testing.TB, opts fx.Option, fn interface{}) {
 	delayed := newDelayedFxInvocation(fn)
-	app := fxtest.New(
-		t,
+	app := fx.New(
 		fx.Supply(fx.Annotate(t, fx.As(new(testing.TB)))),
 		delayed.option(),
 		opts,
+		fx.WithLogger(func() fxevent.Logger { return fxtest.NewTestLogger(t) }),
 	)
-	defer app.RequireStart().RequireStop()
+	// this is equivalent to fxtest.New, but with readable dependency cycles
+	if err := app.Err(); err != nil {
+		t.Fatalf("fx.New failed: %v", explainCycle(err))
+	}
+
+	startCtx, cancel := context.WithTimeout(context.Background(), app.StartTimeout())
+	defer cancel()
+	require.NoError(t, app.Start(startCtx))
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), app.StopTimeout())
+		defer cancel()
+		require.NoError(t, app.Stop(stopCtx))
+	}()
 
 	if err := delayed.call(); err != nil {
 		t.Fatal(err.Error())
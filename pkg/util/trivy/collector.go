@@ -7,15 +7,26 @@ package trivy
 
 import (
 	"context"
+	"errors"
 
 	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 
 	cyclonedxgo "github.com/CycloneDX/cyclonedx-go"
 	"github.com/containerd/containerd"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+// ErrLayerNeedsLowerLayers is returned by ScanContainerdImageLayer when the
+// SBOM of a layer can't be generated without the layers below it, because
+// the layer deletes some of their files, or because it has OS packages while
+// the OS is only defined by those layers.
+var ErrLayerNeedsLowerLayers = errors.New("the layer can't be scanned without the layers below it")
+
 // Collector interface
 type Collector interface {
 	ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedxgo.BOM, error)
 	ScanContainerdImageFromFilesystem(ctx context.Context, imgMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedxgo.BOM, error)
+	ScanFilesystem(ctx context.Context, path string) (*cyclonedxgo.BOM, error)
+	ScanContainerdImageLayer(ctx context.Context, imgMeta *workloadmeta.ContainerImageMetadata, img containerd.Image, layer ocispec.Descriptor) (*cyclonedxgo.BOM, error)
+	ScanOCILayoutImage(ctx context.Context, path string, manifestDigest string) (*cyclonedxgo.BOM, error)
 }
@@ -9,16 +9,20 @@
 package trivy
 
 import (
+	"archive/tar"
 	"context"
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 	"github.com/aquasecurity/trivy/pkg/fanal/types"
 	"github.com/containerd/containerd"
+	containerdarchive "github.com/containerd/containerd/archive"
+	"github.com/containerd/containerd/archive/compression"
 	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/images/archive"
 	"github.com/containerd/containerd/namespaces"
@@ -175,3 +179,58 @@ func inspect(ctx context.Context, imgMeta *workloadmeta.ContainerImageMetadata,
 		},
 	}, history, ref, nil
 }
+
+// extractLayer extracts the files of a single layer to targetDir, and returns
+// whether the layer has whiteouts, deleting files of the layers below it. The
+// whiteouts are not extracted.
+func extractLayer(ctx context.Context, store content.Store, layer ocispec.Descriptor, targetDir string) (bool, error) {
+	readerAt, err := store.ReaderAt(ctx, layer)
+	if err != nil {
+		return false, err
+	}
+	defer readerAt.Close()
+
+	decompressed, err := compression.DecompressStream(content.NewReader(readerAt))
+	if err != nil {
+		return false, err
+	}
+	defer decompressed.Close()
+
+	// Called for all the files of the layer. The whiteouts, deleting a file
+	// or the content of a directory, are named after the file with a prefix.
+	hasWhiteouts := false
+	_, err = containerdarchive.Apply(ctx, targetDir, decompressed, containerdarchive.WithConvertWhiteout(func(_ *tar.Header, path string) (bool, error) {
+		if strings.HasPrefix(filepath.Base(path), whiteoutPrefix) {
+			hasWhiteouts = true
+			return false, nil
+		}
+		return true, nil
+	}))
+	return hasWhiteouts, err
+}
+
+// whiteoutPrefix is the prefix of the whiteouts in the layers, see
+// https://github.com/opencontainers/image-spec/blob/main/layer.md#whiteouts
+const whiteoutPrefix = ".wh."
+
+// osPackageDatabases are the files, relative to the root of a filesystem,
+// where the OS package managers record the installed packages.
+var osPackageDatabases = []string{
+	"lib/apk/db/installed",
+	"var/lib/dpkg/status",
+	"var/lib/dpkg/status.d",
+	"var/lib/rpm",
+	"usr/lib/sysimage/rpm",
+}
+
+// hasOSPackageDatabase returns whether the filesystem in root has a database
+// of OS packages.
+func hasOSPackageDatabase(root string) bool {
+	for _, database := range osPackageDatabases {
+		if _, err := os.Stat(filepath.Join(root, database)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
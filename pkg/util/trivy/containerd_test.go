@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLayer writes an uncompressed layer made of the given files to store.
+func writeLayer(t *testing.T, store content.Store, files map[string]string) ocispec.Descriptor {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(data)),
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	layer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(buf.Bytes()),
+		Size:      int64(buf.Len()),
+	}
+	require.NoError(t, content.WriteBlob(context.Background(), store, layer.Digest.String(), bytes.NewReader(buf.Bytes()), layer))
+
+	return layer
+}
+
+func TestExtractLayer(t *testing.T) {
+	tests := []struct {
+		name                 string
+		files                map[string]string
+		expectedWhiteouts    bool
+		expectedPackageFiles bool
+	}{
+		{
+			name: "base layer",
+			files: map[string]string{
+				"etc/os-release":      "ID=debian\n",
+				"var/lib/dpkg/status": "Package: bash\n",
+			},
+			expectedPackageFiles: true,
+		},
+		{
+			name: "layer deleting files",
+			files: map[string]string{
+				"usr/bin/.wh.curl": "",
+			},
+			expectedWhiteouts: true,
+		},
+		{
+			name: "application layer",
+			files: map[string]string{
+				"app/requirements.txt": "requests==2.28.1\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := local.NewStore(t.TempDir())
+			require.NoError(t, err)
+			layer := writeLayer(t, store, test.files)
+
+			targetDir := t.TempDir()
+			hasWhiteouts, err := extractLayer(context.Background(), store, layer, targetDir)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedWhiteouts, hasWhiteouts)
+			assert.Equal(t, test.expectedPackageFiles, hasOSPackageDatabase(targetDir))
+
+			// The whiteouts are not extracted
+			_, err = os.Stat(filepath.Join(targetDir, "usr/bin/.wh.curl"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
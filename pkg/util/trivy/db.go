@@ -0,0 +1,197 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	trivydb "github.com/aquasecurity/trivy-db/pkg/db"
+	"github.com/aquasecurity/trivy/pkg/db"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Version reported to the DB client of trivy, only used in its log messages
+const dbClientVersion = "datadog-agent"
+
+// ErrDBUnavailable is returned when there's no usable vulnerability DB in
+// offline mode, where it can't be downloaded.
+var ErrDBUnavailable = errors.New("the vulnerability DB is not available")
+
+// DBConfig configures how the vulnerability DB of trivy is kept up to date
+type DBConfig struct {
+	// Directory where the DB is stored
+	CacheDir string
+	// Repository the DB is downloaded from. Empty means the default
+	// repository of trivy.
+	Repository string
+	// UpdateInterval is how often the DB is updated. Zero means it's only
+	// downloaded when it's missing.
+	UpdateInterval time.Duration
+	// Offline disables the downloads, for the nodes without access to the
+	// repository. The DB must already be in CacheDir.
+	Offline bool
+}
+
+// The vulnerability DB is a global of trivy, opened by DBUpdater.
+// DBUpdater.Vulnerabilities holds dbLock for reading, and only finds the
+// vulnerabilities when the DB is loaded. The DB is replaced with dbLock held
+// for writing, once its update is downloaded.
+var (
+	dbLock   sync.RWMutex
+	dbLoaded bool
+)
+
+// newDBClient creates the client downloading the DB. Overridden in tests.
+var newDBClient = func(cacheDir string, repository string) db.Operation {
+	var opts []db.Option
+	if repository != "" {
+		opts = append(opts, db.WithDBRepository(repository))
+	}
+
+	return db.NewClient(cacheDir, true, false, opts...)
+}
+
+// DBUpdater downloads the vulnerability DB and keeps it up to date
+type DBUpdater struct {
+	config DBConfig
+	client db.Operation
+}
+
+// NewDBUpdater creates a DBUpdater with the given configuration
+func NewDBUpdater(config DBConfig) *DBUpdater {
+	return &DBUpdater{
+		config: config,
+		client: newDBClient(config.CacheDir, config.Repository),
+	}
+}
+
+// Update downloads the DB when it's missing or outdated, and loads it. In offline mode, it only checks that a usable DB is present, and
+// returns ErrDBUnavailable otherwise. The DB in use is kept when the download
+// fails.
+func (u *DBUpdater) Update(ctx context.Context) error {
+	needsUpdate, err := u.client.NeedsUpdate(dbClientVersion, u.config.Offline)
+	if err != nil {
+		if u.config.Offline {
+			return fmt.Errorf("%w in %s in offline mode: %s", ErrDBUnavailable, u.config.CacheDir, err)
+		}
+		return fmt.Errorf("error checking the vulnerability DB: %w", err)
+	}
+
+	if !needsUpdate {
+		log.Debugf("The vulnerability DB in %s is up to date", u.config.CacheDir)
+
+		dbLock.Lock()
+		defer dbLock.Unlock()
+
+		if dbLoaded {
+			return nil
+		}
+		return loadDB(u.config.CacheDir)
+	}
+
+	// The update is downloaded next to the DB in use, so that it's still
+	// used in the meantime
+	if err = os.MkdirAll(u.config.CacheDir, 0700); err != nil {
+		return fmt.Errorf("error creating the vulnerability DB directory: %w", err)
+	}
+
+	downloadDir, err := os.MkdirTemp(u.config.CacheDir, "download-*")
+	if err != nil {
+		return fmt.Errorf("error creating the vulnerability DB download directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(downloadDir); err != nil {
+			log.Warnf("Error removing the vulnerability DB download directory %s: %s", downloadDir, err)
+		}
+	}()
+
+	log.Infof("Downloading the vulnerability DB to %s", u.config.CacheDir)
+	if err = u.client.Download(ctx, downloadDir); err != nil {
+		return fmt.Errorf("error downloading the vulnerability DB: %w", err)
+	}
+
+	dbLock.Lock()
+	defer dbLock.Unlock()
+
+	if err = unloadDB(); err != nil {
+		return err
+	}
+
+	if err = os.RemoveAll(trivydb.Dir(u.config.CacheDir)); err != nil {
+		return fmt.Errorf("error removing the previous vulnerability DB: %w", err)
+	}
+
+	if err = os.Rename(trivydb.Dir(downloadDir), trivydb.Dir(u.config.CacheDir)); err != nil {
+		return fmt.Errorf("error moving the downloaded vulnerability DB: %w", err)
+	}
+
+	return loadDB(u.config.CacheDir)
+}
+
+// Close unloads the DB, no vulnerabilities are found after it.
+func (u *DBUpdater) Close() error {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+
+	return unloadDB()
+}
+
+// loadDB opens the DB of cacheDir. dbLock must be held for
+// writing.
+func loadDB(cacheDir string) error {
+	if err := trivydb.Init(cacheDir); err != nil {
+		return fmt.Errorf("error opening the vulnerability DB: %w", err)
+	}
+
+	dbLoaded = true
+	return nil
+}
+
+// unloadDB closes the DB, if it's open. dbLock must be held for writing.
+func unloadDB() error {
+	if !dbLoaded {
+		return nil
+	}
+
+	if err := trivydb.Close(); err != nil {
+		return fmt.Errorf("error closing the vulnerability DB: %w", err)
+	}
+
+	dbLoaded = false
+	return nil
+}
+
+// Run updates the DB every UpdateInterval, until the context is cancelled.
+// It returns right away in offline mode or when there's no update interval.
+// The DB in use is kept when an update fails.
+func (u *DBUpdater) Run(ctx context.Context) {
+	if u.config.Offline || u.config.UpdateInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(u.config.UpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := u.Update(ctx); err != nil {
+				log.Warnf("Vulnerability DB update failed, keeping the current one: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
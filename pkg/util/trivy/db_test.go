@@ -0,0 +1,252 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	trivydb "github.com/aquasecurity/trivy-db/pkg/db"
+	dbtypes "github.com/aquasecurity/trivy-db/pkg/types"
+	"github.com/aquasecurity/trivy-db/pkg/vulnsrc/vulnerability"
+	"github.com/aquasecurity/trivy/pkg/db"
+	"github.com/aquasecurity/trivy/pkg/fanal/artifact"
+	"github.com/aquasecurity/trivy/pkg/fanal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// fakeDBClient is a DB client whose DB is present or not, counting the
+// downloads.
+type fakeDBClient struct {
+	mut         sync.Mutex
+	present     bool
+	outdated    bool
+	downloads   int
+	downloadErr error
+	repository  string
+}
+
+func (c *fakeDBClient) NeedsUpdate(_ string, skip bool) (bool, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.present {
+		if skip {
+			return false, errors.New("the first run cannot skip downloading DB")
+		}
+		return true, nil
+	}
+
+	return !skip && c.outdated, nil
+}
+
+// Download writes an empty DB to dst
+func (c *fakeDBClient) Download(_ context.Context, dst string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.downloads++
+	if c.downloadErr != nil {
+		return c.downloadErr
+	}
+
+	if err := os.MkdirAll(trivydb.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(trivydb.Path(dst), nil, 0600); err != nil {
+		return err
+	}
+
+	c.present = true
+	return nil
+}
+
+func (c *fakeDBClient) downloadCount() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.downloads
+}
+
+func withFakeDBClient(t *testing.T, client *fakeDBClient) {
+	previous := newDBClient
+	newDBClient = func(_ string, repository string) db.Operation {
+		client.repository = repository
+		return client
+	}
+	t.Cleanup(func() {
+		newDBClient = previous
+		assert.NoError(t, closeTestDB())
+	})
+}
+
+func closeTestDB() error {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+
+	return unloadDB()
+}
+
+func isDBLoaded() bool {
+	dbLock.RLock()
+	defer dbLock.RUnlock()
+
+	return dbLoaded
+}
+
+func TestDBUpdaterOffline(t *testing.T) {
+	// No DB present: fails without trying to download it
+	client := &fakeDBClient{}
+	withFakeDBClient(t, client)
+
+	updater := NewDBUpdater(DBConfig{CacheDir: t.TempDir(), Offline: true, UpdateInterval: time.Millisecond})
+	err := updater.Update(context.Background())
+	assert.ErrorIs(t, err, ErrDBUnavailable)
+	assert.Equal(t, 0, client.downloadCount())
+	assert.False(t, isDBLoaded())
+
+	// The DB is present: used as is, even when outdated
+	client.present = true
+	client.outdated = true
+	assert.NoError(t, updater.Update(context.Background()))
+	assert.Equal(t, 0, client.downloadCount())
+	assert.True(t, isDBLoaded())
+
+	// Never updated in the background
+	done := make(chan struct{})
+	go func() {
+		updater.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Run didn't return in offline mode")
+	}
+	assert.Equal(t, 0, client.downloadCount())
+}
+
+func TestDBUpdaterMirror(t *testing.T) {
+	client := &fakeDBClient{}
+	withFakeDBClient(t, client)
+
+	updater := NewDBUpdater(DBConfig{CacheDir: t.TempDir(), Repository: "registry.example.com/trivy-db"})
+	assert.Equal(t, "registry.example.com/trivy-db", client.repository)
+
+	// Downloaded when missing, not when up to date
+	require.NoError(t, updater.Update(context.Background()))
+	assert.Equal(t, 1, client.downloadCount())
+
+	require.NoError(t, updater.Update(context.Background()))
+	assert.Equal(t, 1, client.downloadCount())
+
+	client.outdated = true
+	require.NoError(t, updater.Update(context.Background()))
+	assert.Equal(t, 2, client.downloadCount())
+	assert.True(t, isDBLoaded())
+
+	// The DB in use is kept when the download fails
+	client.downloadErr = errors.New("registry unreachable")
+	assert.Error(t, updater.Update(context.Background()))
+	assert.True(t, isDBLoaded())
+
+	require.NoError(t, updater.Close())
+	assert.False(t, isDBLoaded())
+}
+
+func TestDBUpdaterRun(t *testing.T) {
+	client := &fakeDBClient{present: true, outdated: true}
+	withFakeDBClient(t, client)
+
+	updater := NewDBUpdater(DBConfig{CacheDir: t.TempDir(), UpdateInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		updater.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return client.downloadCount() >= 2 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Run didn't stop when the context was cancelled")
+	}
+}
+
+func TestDBUpdaterVulnerabilities(t *testing.T) {
+	// A DB with a vulnerability of the musl package of Alpine 3.17
+	cacheDir := t.TempDir()
+	require.NoError(t, trivydb.Init(cacheDir))
+	dbc := trivydb.Config{}
+	require.NoError(t, dbc.BatchUpdate(func(tx *bolt.Tx) error {
+		if err := dbc.PutDataSource(tx, "alpine 3.17", dbtypes.DataSource{ID: vulnerability.Alpine}); err != nil {
+			return err
+		}
+		if err := dbc.PutVulnerability(tx, "CVE-2023-0001", dbtypes.Vulnerability{Severity: "HIGH"}); err != nil {
+			return err
+		}
+		return dbc.PutAdvisory(tx, []string{"alpine 3.17", "musl"}, "CVE-2023-0001", dbtypes.Advisory{FixedVersion: "1.2.3-r5"})
+	}))
+	require.NoError(t, trivydb.Close())
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte("ID=alpine\nVERSION_ID=3.17.0\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "lib", "apk", "db"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lib", "apk", "db", "installed"), []byte("P:musl\nV:1.2.3-r4\nA:x86_64\no:musl\n\n"), 0644))
+
+	fsCache, err := cache.NewFSCache(t.TempDir())
+	require.NoError(t, err)
+	scanner, err := NewCollector(CollectorConfig{
+		ArtifactCache:      fsCache,
+		LocalArtifactCache: fsCache,
+		ArtifactOption: artifact.Option{
+			Offline:          true,
+			NoProgress:       true,
+			SBOMSources:      []string{},
+			DisabledHandlers: DefaultDisabledHandlers(),
+		},
+	})
+	require.NoError(t, err)
+
+	// The scans only report the packages
+	bom, err := scanner.ScanFilesystem(context.Background(), root)
+	require.NoError(t, err)
+	require.NotNil(t, bom.Components)
+	assert.True(t, bom.Vulnerabilities == nil || len(*bom.Vulnerabilities) == 0)
+
+	withFakeDBClient(t, &fakeDBClient{present: true})
+	updater := NewDBUpdater(DBConfig{CacheDir: cacheDir, Offline: true})
+
+	// Not found before the DB is loaded
+	_, err = updater.Vulnerabilities(context.Background(), *bom.Components)
+	assert.ErrorIs(t, err, ErrDBUnavailable)
+
+	require.NoError(t, updater.Update(context.Background()))
+
+	vulnerabilities, err := updater.Vulnerabilities(context.Background(), *bom.Components)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	assert.Equal(t, "CVE-2023-0001", vulnerabilities[0].ID)
+	assert.Equal(t, "HIGH", vulnerabilities[0].Severity)
+	assert.Equal(t, "1.2.3-r5", vulnerabilities[0].FixedVersion)
+	assert.Contains(t, vulnerabilities[0].PackageURL, "pkg:apk/alpine/musl@1.2.3-r4")
+}
@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"context"
+	"fmt"
+
+	cyclonedxgo "github.com/CycloneDX/cyclonedx-go"
+	image2 "github.com/aquasecurity/trivy/pkg/fanal/artifact/image"
+	fimage "github.com/aquasecurity/trivy/pkg/fanal/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+// ociLayoutImage is an image of an OCI layout directory, as an image of
+// fanal. Like the archives, it has no tags nor digests of a registry.
+type ociLayoutImage struct {
+	v1.Image
+	name string
+}
+
+func (img ociLayoutImage) Name() string {
+	return img.name
+}
+
+func (img ociLayoutImage) ID() (string, error) {
+	return fimage.ID(img)
+}
+
+func (ociLayoutImage) RepoTags() []string {
+	return nil
+}
+
+func (ociLayoutImage) RepoDigests() []string {
+	return nil
+}
+
+// ScanOCILayoutImage generates the SBOM of the image with the given manifest
+// digest in the OCI layout directory at path.
+func (c *collector) ScanOCILayoutImage(ctx context.Context, path string, manifestDigest string) (*cyclonedxgo.BOM, error) {
+	hash, err := v1.NewHash(manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest digest %q, err: %w", manifestDigest, err)
+	}
+
+	layoutPath, err := layout.FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open OCI layout %s, err: %w", path, err)
+	}
+
+	img, err := layoutPath.Image(hash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image %s of OCI layout %s, err: %w", manifestDigest, path, err)
+	}
+
+	imageArtifact, err := image2.NewArtifact(ociLayoutImage{Image: img, name: path + "@" + manifestDigest}, c.config.ArtifactCache, c.config.ArtifactOption)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create artifact from image, err: %w", err)
+	}
+
+	bom, err := c.scan(ctx, imageArtifact)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal report to sbom format, err: %w", err)
+	}
+
+	return bom, nil
+}
@@ -12,7 +12,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"sort"
 	"time"
 
 	containerdUtil "github.com/DataDog/datadog-agent/pkg/util/containerd"
@@ -37,6 +36,8 @@ import (
 	"github.com/aquasecurity/trivy/pkg/types"
 	"github.com/aquasecurity/trivy/pkg/vulnerability"
 	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const (
@@ -46,8 +47,36 @@ const (
 	SecretAnalyzers     = "secret"
 	ConfigFileAnalyzers = "config"
 	LicenseAnalyzers    = "license"
+
+	// Subsets of the language analyzers
+	PythonAnalyzers = "python"
+	NodeAnalyzers   = "node"
+	GoAnalyzers     = "go"
+	JavaAnalyzers   = "java"
+	RubyAnalyzers   = "ruby"
+	PHPAnalyzers    = "php"
+	RustAnalyzers   = "rust"
+	DotNetAnalyzers = "dotnet"
 )
 
+// analyzerGroups maps the names of the analyzers that can be enabled to the
+// trivy analyzers they include
+var analyzerGroups = map[string][]analyzer.Type{
+	OSAnalyzers:         analyzer.TypeOSes,
+	LanguagesAnalyzers:  analyzer.TypeLanguages,
+	SecretAnalyzers:     {analyzer.TypeSecret},
+	ConfigFileAnalyzers: analyzer.TypeConfigFiles,
+	LicenseAnalyzers:    {analyzer.TypeLicenseFile},
+	PythonAnalyzers:     {analyzer.TypePythonPkg, analyzer.TypePip, analyzer.TypePipenv, analyzer.TypePoetry, analyzer.TypeCondaPkg},
+	NodeAnalyzers:       {analyzer.TypeNodePkg, analyzer.TypeNpmPkgLock, analyzer.TypeYarn, analyzer.TypePnpm},
+	GoAnalyzers:         {analyzer.TypeGoBinary, analyzer.TypeGoMod},
+	JavaAnalyzers:       {analyzer.TypeJar, analyzer.TypePom, analyzer.TypeGradleLock},
+	RubyAnalyzers:       {analyzer.TypeGemSpec, analyzer.TypeBundler},
+	PHPAnalyzers:        {analyzer.TypeComposer},
+	RustAnalyzers:       {analyzer.TypeRustBinary, analyzer.TypeCargo},
+	DotNetAnalyzers:     {analyzer.TypeNuget, analyzer.TypeDotNetCore},
+}
+
 // CollectorConfig allows to pass configuration
 type CollectorConfig struct {
 	ArtifactCache      cache.ArtifactCache
@@ -95,28 +124,39 @@ func DefaultCollectorConfig(enabledAnalyzers []string) (CollectorConfig, error)
 	return collectorConfig, nil
 }
 
+// DefaultDisabledCollectors returns the trivy analyzers that are not part of
+// the enabled ones. All the analyzers are enabled when the list is empty.
 func DefaultDisabledCollectors(enabledAnalyzers []string) []analyzer.Type {
-	sort.Strings(enabledAnalyzers)
-	analyzersDisabled := func(analyzers string) bool {
-		index := sort.SearchStrings(enabledAnalyzers, analyzers)
-		return index >= len(enabledAnalyzers) || enabledAnalyzers[index] != analyzers
+	if len(enabledAnalyzers) == 0 {
+		return nil
 	}
 
-	var disabledAnalyzers []analyzer.Type
-	if analyzersDisabled(OSAnalyzers) {
-		disabledAnalyzers = append(disabledAnalyzers, analyzer.TypeOSes...)
-	}
-	if analyzersDisabled(LanguagesAnalyzers) {
-		disabledAnalyzers = append(disabledAnalyzers, analyzer.TypeLanguages...)
-	}
-	if analyzersDisabled(SecretAnalyzers) {
-		disabledAnalyzers = append(disabledAnalyzers, analyzer.TypeSecret)
-	}
-	if analyzersDisabled(ConfigFileAnalyzers) {
-		disabledAnalyzers = append(disabledAnalyzers, analyzer.TypeConfigFiles...)
+	enabled := make(map[analyzer.Type]struct{})
+	for _, name := range enabledAnalyzers {
+		analyzers, found := analyzerGroups[name]
+		if !found {
+			log.Warnf("Unknown SBOM analyzer %q, ignoring it", name)
+			continue
+		}
+
+		for _, analyzerType := range analyzers {
+			enabled[analyzerType] = struct{}{}
+		}
 	}
-	if analyzersDisabled(LicenseAnalyzers) {
-		disabledAnalyzers = append(disabledAnalyzers, analyzer.TypeLicenseFile)
+
+	var disabledAnalyzers []analyzer.Type
+	for _, analyzers := range [][]analyzer.Type{
+		analyzer.TypeOSes,
+		analyzer.TypeLanguages,
+		{analyzer.TypeSecret},
+		analyzer.TypeConfigFiles,
+		{analyzer.TypeLicenseFile},
+	} {
+		for _, analyzerType := range analyzers {
+			if _, found := enabled[analyzerType]; !found {
+				disabledAnalyzers = append(disabledAnalyzers, analyzerType)
+			}
+		}
 	}
 
 	return disabledAnalyzers
@@ -201,7 +241,13 @@ func (c *collector) ScanContainerdImageFromFilesystem(ctx context.Context, imgMe
 		}
 	}()
 
-	fsArtifact, err := local2.NewArtifact(imagePath, c.config.ArtifactCache, c.config.ArtifactOption)
+	return c.ScanFilesystem(ctx, imagePath)
+}
+
+// ScanFilesystem generates the SBOM of the files in path, for instance the
+// root filesystem of a running container.
+func (c *collector) ScanFilesystem(ctx context.Context, path string) (*cyclonedxgo.BOM, error) {
+	fsArtifact, err := local2.NewArtifact(path, c.config.ArtifactCache, c.config.ArtifactOption)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create artifact from fs, err: %w", err)
 	}
@@ -214,18 +260,72 @@ func (c *collector) ScanContainerdImageFromFilesystem(ctx context.Context, imgMe
 	return bom, nil
 }
 
+// ScanContainerdImageLayer generates the SBOM of the files of a single layer
+// of an image, without the files of the layers below it. It returns
+// ErrLayerNeedsLowerLayers when the SBOM would be wrong without them.
+func (c *collector) ScanContainerdImageLayer(ctx context.Context, imgMeta *workloadmeta.ContainerImageMetadata, img containerd.Image, layer ocispec.Descriptor) (*cyclonedxgo.BOM, error) {
+	layerPath, err := os.MkdirTemp(os.TempDir(), "containerd-layer-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temp dir, err: %w", err)
+	}
+	defer func() {
+		err := os.RemoveAll(layerPath)
+		if err != nil {
+			log.Errorf("Unable to remove temp dir: %s, err: %v", layerPath, err)
+		}
+	}()
+
+	ctxWithNamespace := namespaces.WithNamespace(ctx, imgMeta.Namespace)
+	hasWhiteouts, err := extractLayer(ctxWithNamespace, img.ContentStore(), layer, layerPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract layer %s, err: %w", layer.Digest, err)
+	}
+	if hasWhiteouts {
+		return nil, fmt.Errorf("layer %s deletes files: %w", layer.Digest, ErrLayerNeedsLowerLayers)
+	}
+
+	fsArtifact, err := local2.NewArtifact(layerPath, c.config.ArtifactCache, c.config.ArtifactOption)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create artifact from fs, err: %w", err)
+	}
+
+	report, err := c.scanArtifact(ctx, fsArtifact)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan layer %s, err: %w", layer.Digest, err)
+	}
+
+	// The OS is usually defined by a lower layer than the one updating the OS
+	// packages. Without it, the packages are either missing or reported for
+	// an unknown OS.
+	if (report.Metadata.OS == nil || report.Metadata.OS.Family == "none") && hasOSPackageDatabase(layerPath) {
+		return nil, fmt.Errorf("the OS of the packages of layer %s is unknown: %w", layer.Digest, ErrLayerNeedsLowerLayers)
+	}
+
+	return c.marshalReport(report)
+}
+
 func (c *collector) scan(ctx context.Context, artifact artifact.Artifact) (*cyclonedxgo.BOM, error) {
-	s := scanner.NewScanner(local.NewScanner(c.applier, c.detector, c.vulnClient), artifact)
-	report, err := s.ScanArtifact(ctx, types.ScanOptions{
+	report, err := c.scanArtifact(ctx, artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.marshalReport(report)
+}
+
+func (c *collector) scanArtifact(ctx context.Context, artifact artifact.Artifact) (types.Report, error) {
+	scanOptions := types.ScanOptions{
 		VulnType:            []string{},
 		SecurityChecks:      []string{},
 		ScanRemovedPackages: false,
 		ListAllPackages:     true,
-	})
-	if err != nil {
-		return nil, err
 	}
 
+	s := scanner.NewScanner(local.NewScanner(c.applier, c.detector, c.vulnClient), artifact)
+	return s.ScanArtifact(ctx, scanOptions)
+}
+
+func (c *collector) marshalReport(report types.Report) (*cyclonedxgo.BOM, error) {
 	bom, err := c.marshaler.Marshal(report)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/trivy/pkg/fanal/analyzer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultDisabledCollectors(t *testing.T) {
+	// All the analyzers are enabled by an empty list
+	assert.Empty(t, DefaultDisabledCollectors(nil))
+
+	disabled := DefaultDisabledCollectors([]string{OSAnalyzers, PythonAnalyzers, "unknown"})
+	for _, enabled := range append([]analyzer.Type{analyzer.TypeDpkg, analyzer.TypePip, analyzer.TypePythonPkg}, analyzer.TypeOSes...) {
+		assert.NotContains(t, disabled, enabled)
+	}
+	for _, expectedDisabled := range []analyzer.Type{analyzer.TypeGoBinary, analyzer.TypeNpmPkgLock, analyzer.TypeSecret, analyzer.TypeLicenseFile, analyzer.TypeYaml} {
+		assert.Contains(t, disabled, expectedDisabled)
+	}
+
+	// The language subsets are part of the languages
+	assert.NotContains(t, DefaultDisabledCollectors([]string{LanguagesAnalyzers}), analyzer.TypeGoMod)
+	assert.Contains(t, DefaultDisabledCollectors([]string{GoAnalyzers}), analyzer.TypeDpkg)
+	assert.NotContains(t, DefaultDisabledCollectors([]string{GoAnalyzers}), analyzer.TypeGoMod)
+}
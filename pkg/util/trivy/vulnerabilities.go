@@ -0,0 +1,172 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build trivy
+// +build trivy
+
+package trivy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	cyclonedxgo "github.com/CycloneDX/cyclonedx-go"
+	trivydb "github.com/aquasecurity/trivy-db/pkg/db"
+	"github.com/aquasecurity/trivy/pkg/detector/library"
+	"github.com/aquasecurity/trivy/pkg/detector/ospkg"
+	ftypes "github.com/aquasecurity/trivy/pkg/fanal/types"
+	"github.com/aquasecurity/trivy/pkg/purl"
+	"github.com/aquasecurity/trivy/pkg/sbom/cyclonedx"
+	"github.com/aquasecurity/trivy/pkg/types"
+	"github.com/aquasecurity/trivy/pkg/vulnerability"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Vulnerability is a vulnerability of a package of an SBOM, found in the
+// vulnerability DB
+type Vulnerability struct {
+	// ID of the vulnerability, like a CVE ID
+	ID string
+	// Severity is the name of the severity in trivy, like "CRITICAL"
+	Severity string
+	// PackageURL is the PURL of the affected component
+	PackageURL string
+	// FixedVersion is the first version of the package that is not
+	// affected. Empty when there's no fix.
+	FixedVersion string
+}
+
+// Vulnerabilities finds the vulnerabilities of the packages of an SBOM, the
+// components generated by the scans, in the vulnerability DB. The OS
+// packages are only checked when the SBOM has an operating system component.
+// It returns ErrDBUnavailable when the DB isn't loaded yet.
+func (u *DBUpdater) Vulnerabilities(ctx context.Context, components []cyclonedxgo.Component) ([]Vulnerability, error) {
+	dbLock.RLock()
+	defer dbLock.RUnlock()
+
+	if !dbLoaded {
+		return nil, ErrDBUnavailable
+	}
+
+	var osFamily, osName string
+	for _, component := range components {
+		if component.Type == cyclonedxgo.ComponentTypeOS {
+			osFamily, osName = component.Name, component.Version
+			break
+		}
+	}
+
+	vulnClient := vulnerability.NewClient(trivydb.Config{})
+
+	var vulnerabilities []Vulnerability
+	for _, component := range components {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if component.PackageURL == "" {
+			continue
+		}
+
+		detected, err := detectVulnerabilities(component, osFamily, osName)
+		if err != nil {
+			return nil, fmt.Errorf("error detecting the vulnerabilities of package %s: %w", component.PackageURL, err)
+		}
+
+		// Fills the severities
+		vulnClient.FillInfo(detected)
+
+		for _, found := range detected {
+			vulnerabilities = append(vulnerabilities, Vulnerability{
+				ID:           found.VulnerabilityID,
+				Severity:     found.Severity,
+				PackageURL:   component.PackageURL,
+				FixedVersion: found.FixedVersion,
+			})
+		}
+	}
+
+	return vulnerabilities, nil
+}
+
+// detectVulnerabilities returns the vulnerabilities of the package of a
+// component. dbLock must be held.
+func detectVulnerabilities(component cyclonedxgo.Component, osFamily, osName string) ([]types.DetectedVulnerability, error) {
+	packageURL, err := purl.FromString(component.PackageURL)
+	if err != nil {
+		// Not a package known by trivy
+		log.Debugf("Not checking the vulnerabilities of package %s: %s", component.PackageURL, err)
+		return nil, nil
+	}
+
+	pkg, err := toPackage(packageURL, component)
+	if err != nil {
+		return nil, err
+	}
+
+	if !packageURL.IsOSPkg() {
+		if _, err = library.NewDriver(packageURL.PackageType()); err != nil {
+			log.Debugf("Not checking the vulnerabilities of package %s: %s", component.PackageURL, err)
+			return nil, nil
+		}
+		return library.Detect(packageURL.PackageType(), []ftypes.Package{*pkg})
+	}
+
+	if osFamily == "" {
+		return nil, nil
+	}
+
+	vulnerabilities, _, err := ospkg.Detector{}.Detect("", osFamily, osName, nil, time.Time{}, []ftypes.Package{*pkg})
+	if err == ospkg.ErrUnsupportedOS {
+		return nil, nil
+	}
+	return vulnerabilities, err
+}
+
+// toPackage returns the package of a component, with the source package
+// stored in its trivy properties, which the OS advisories refer to
+func toPackage(packageURL *purl.PackageURL, component cyclonedxgo.Component) (*ftypes.Package, error) {
+	pkg := packageURL.Package()
+
+	if component.Properties != nil {
+		for _, property := range *component.Properties {
+			switch strings.TrimPrefix(property.Name, cyclonedx.Namespace) {
+			case cyclonedx.PropertySrcName:
+				pkg.SrcName = property.Value
+			case cyclonedx.PropertySrcVersion:
+				pkg.SrcVersion = property.Value
+			case cyclonedx.PropertySrcRelease:
+				pkg.SrcRelease = property.Value
+			case cyclonedx.PropertySrcEpoch:
+				epoch, err := strconv.Atoi(property.Value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid source epoch %q: %w", property.Value, err)
+				}
+				pkg.SrcEpoch = epoch
+			}
+		}
+	}
+
+	if packageURL.IsOSPkg() {
+		if pkg.SrcName == "" {
+			pkg.SrcName = pkg.Name
+		}
+		if pkg.SrcVersion == "" {
+			pkg.SrcVersion = pkg.Version
+		}
+		if pkg.SrcRelease == "" {
+			pkg.SrcRelease = pkg.Release
+		}
+		if pkg.SrcEpoch == 0 {
+			pkg.SrcEpoch = pkg.Epoch
+		}
+	}
+
+	return pkg, nil
+}
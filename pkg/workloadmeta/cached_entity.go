@@ -8,6 +8,9 @@ package workloadmeta
 import (
 	"reflect"
 	"sort"
+	"time"
+
+	"github.com/cihub/seelog"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -20,17 +23,40 @@ type cachedEntity struct {
 	cached        Entity
 	sources       map[Source]Entity
 	sortedSources []string
+
+	// versions has the version of the last update of each source that
+	// changed a value that can conflict with the other sources, see
+	// conflictingFields. They come from lastVersion, incremented on every
+	// such update, so they order the sources from the least to the most
+	// recently updated.
+	versions    map[Source]uint64
+	lastVersion uint64
+
+	// priorities has the rank of the sources whose values win the
+	// conflicts over the other ones, the lowest rank first, see
+	// sourcePriorities
+	priorities map[Source]int
+
+	// refreshed has the last time each source set the entity, changed or
+	// not, to expire the sources that stopped refreshing it, see
+	// store.expiredEvents
+	refreshed map[Source]time.Time
 }
 
-func newCachedEntity() *cachedEntity {
+func newCachedEntity(priorities map[Source]int) *cachedEntity {
 	return &cachedEntity{
-		sources: make(map[Source]Entity),
+		sources:    make(map[Source]Entity),
+		versions:   make(map[Source]uint64),
+		priorities: priorities,
+		refreshed:  make(map[Source]time.Time),
 	}
 }
 
 func (e *cachedEntity) unset(source Source) bool {
 	if _, found := e.sources[source]; found {
 		delete(e.sources, source)
+		delete(e.versions, source)
+		delete(e.refreshed, source)
 		e.computeCache()
 		return true
 	}
@@ -45,12 +71,24 @@ func (e *cachedEntity) set(source Source, entity Entity) (found, changed bool) {
 		return true, false
 	}
 
+	// A refresh only changing the slices or maps, for instance their order,
+	// doesn't make the values of the source more recent
+	if !found || !sameConflictingValues(old, entity) {
+		e.lastVersion++
+		e.versions[source] = e.lastVersion
+	}
+
 	e.sources[source] = entity
 	e.computeCache()
 
 	return found, true
 }
 
+// refresh records that the source set the entity at the given time.
+func (e *cachedEntity) refresh(source Source, now time.Time) {
+	e.refreshed[source] = now
+}
+
 func (e *cachedEntity) get(source Source) Entity {
 	if source == SourceAll {
 		return e.cached
@@ -60,10 +98,12 @@ func (e *cachedEntity) get(source Source) Entity {
 }
 
 // computeCache merges the entities in e.sources into one and caches the result
-// in e.cached. Priority is established by the string representation of the
-// source in alphabetical order, and data is considered missing if it's a zero
-// value. Conflicts are not expected (entities should represent the same data),
-// so the sorting is to ensure deterministic behavior more than anything.
+// in e.cached. Each source only sets the fields it knows about, and data is
+// considered missing if it's a zero value, so the fields set by a source are
+// never cleared by the updates of another one. When several sources set the
+// same field to different values, the value of the source with the highest
+// priority is kept, or of the most recently updated one when they have the
+// same priority, and the conflict is logged at debug level.
 func (e *cachedEntity) computeCache() {
 	sources := make([]string, 0, len(e.sources))
 	for source := range e.sources {
@@ -74,25 +114,66 @@ func (e *cachedEntity) computeCache() {
 
 	e.sortedSources = sources
 
+	// Merging never overwrites the fields already set, so the sources are
+	// merged from the highest to the lowest priority, and from the most to
+	// the least recently updated between the sources of the same priority
+	mergeOrder := make([]Source, 0, len(sources))
+	for _, source := range sources {
+		mergeOrder = append(mergeOrder, Source(source))
+	}
+	sort.SliceStable(mergeOrder, func(i, j int) bool {
+		rankI, rankJ := e.rank(mergeOrder[i]), e.rank(mergeOrder[j])
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		return e.versions[mergeOrder[i]] > e.versions[mergeOrder[j]]
+	})
+
+	winners := "most recently updated"
+	if len(e.priorities) > 0 {
+		winners = "highest priority or most recently updated"
+	}
+
+	logConflicts := log.ShouldLog(seelog.DebugLvl)
+
 	var merged Entity
-	for _, source := range e.sortedSources {
-		if e, ok := e.sources[Source(source)]; ok {
-			if merged == nil {
-				merged = e.DeepCopy()
-			} else {
-				err := merged.Merge(e)
-				if err != nil {
-					log.Errorf("Cannot merge %+v into %+v: %s", merged, e, err)
-				}
+	var mergedSources []Source
+	for _, source := range mergeOrder {
+		entity := e.sources[source]
+
+		if merged == nil {
+			merged = entity.DeepCopy()
+			mergedSources = append(mergedSources, source)
+			continue
+		}
+
+		if logConflicts {
+			for _, field := range conflictingFields(merged, entity) {
+				log.Debugf("Conflicting values for field %s of %s %s, keeping the value of the %s of sources %v over the one of source %s", field, entity.GetID().Kind, entity.GetID().ID, winners, mergedSources, source)
 			}
 		}
+
+		err := merged.Merge(entity)
+		if err != nil {
+			log.Errorf("Cannot merge %+v into %+v: %s", merged, entity, err)
+		}
+		mergedSources = append(mergedSources, source)
 	}
 
 	e.cached = merged
 }
 
+// rank returns the rank of the source in the priorities, the sources without
+// a priority all come after the other ones.
+func (e *cachedEntity) rank(source Source) int {
+	if rank, found := e.priorities[source]; found {
+		return rank
+	}
+	return len(e.priorities)
+}
+
 func (e *cachedEntity) copy() *cachedEntity {
-	newEntity := newCachedEntity()
+	newEntity := newCachedEntity(e.priorities)
 
 	newEntity.cached = e.cached.DeepCopy()
 
@@ -102,5 +183,89 @@ func (e *cachedEntity) copy() *cachedEntity {
 		newEntity.sources[source] = entity
 	}
 
+	for source, version := range e.versions {
+		newEntity.versions[source] = version
+	}
+	newEntity.lastVersion = e.lastVersion
+
+	for source, refreshed := range e.refreshed {
+		newEntity.refreshed[source] = refreshed
+	}
+
 	return newEntity
 }
+
+// conflictingFields returns the names of the fields set in both entities, to
+// different values. The slices and maps are not compared, as merging combines
+// their elements instead of keeping the ones of a single entity.
+func conflictingFields(dst, src Entity) []string {
+	dstValue := reflect.Indirect(reflect.ValueOf(dst))
+	srcValue := reflect.Indirect(reflect.ValueOf(src))
+
+	if dstValue.Kind() != reflect.Struct || dstValue.Type() != srcValue.Type() {
+		return nil
+	}
+
+	return appendConflictingFields(nil, "", dstValue, srcValue)
+}
+
+// sameConflictingValues returns true if the fields of the entities that can
+// conflict when merging, all of them but the slices and maps, are equal.
+func sameConflictingValues(a, b Entity) bool {
+	aValue := reflect.Indirect(reflect.ValueOf(a))
+	bValue := reflect.Indirect(reflect.ValueOf(b))
+
+	if aValue.Kind() != reflect.Struct || aValue.Type() != bValue.Type() {
+		return reflect.DeepEqual(a, b)
+	}
+
+	return sameFields(aValue, bValue)
+}
+
+func sameFields(a, b reflect.Value) bool {
+	for i := 0; i < a.NumField(); i++ {
+		if !a.Type().Field(i).IsExported() {
+			continue
+		}
+
+		aField, bField := a.Field(i), b.Field(i)
+
+		switch {
+		case aField.Type() == timeType:
+			if !aField.Interface().(time.Time).Equal(bField.Interface().(time.Time)) {
+				return false
+			}
+		case aField.Kind() == reflect.Struct:
+			if !sameFields(aField, bField) {
+				return false
+			}
+		case aField.Kind() == reflect.Slice || aField.Kind() == reflect.Map:
+		case !reflect.DeepEqual(aField.Interface(), bField.Interface()):
+			return false
+		}
+	}
+
+	return true
+}
+
+func appendConflictingFields(conflicts []string, prefix string, dst, src reflect.Value) []string {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		dstField, srcField := dst.Field(i), src.Field(i)
+
+		switch {
+		case dstField.Kind() == reflect.Struct && dstField.Type() != timeType:
+			conflicts = appendConflictingFields(conflicts, prefix+field.Name+".", dstField, srcField)
+		case dstField.Kind() == reflect.Slice || dstField.Kind() == reflect.Map:
+		case dstField.IsZero() || srcField.IsZero():
+		case !reflect.DeepEqual(dstField.Interface(), srcField.Interface()):
+			conflicts = append(conflicts, prefix+field.Name)
+		}
+	}
+
+	return conflicts
+}
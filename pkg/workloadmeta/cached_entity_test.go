@@ -0,0 +1,248 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package workloadmeta
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	runtimeSource      Source = "runtime"
+	orchestratorSource Source = "orchestrator"
+)
+
+func TestCachedEntityMergesDisjointFields(t *testing.T) {
+	createdAt := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	entity := newCachedEntity(nil)
+	entity.set(orchestratorSource, &Container{
+		EntityID:   EntityID{Kind: KindContainer, ID: "ctr-id"},
+		EntityMeta: EntityMeta{Name: "ctr-name", Labels: map[string]string{"team": "containers"}},
+		Hostname:   "ctr-host",
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID:   EntityID{Kind: KindContainer, ID: "ctr-id"},
+		EntityMeta: EntityMeta{Labels: map[string]string{"version": "7"}},
+		Runtime:    ContainerRuntimeContainerd,
+		PID:        42,
+		State:      ContainerState{Running: true, CreatedAt: createdAt},
+	})
+
+	// A new update of the runtime doesn't clear the fields of the
+	// orchestrator
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Runtime:  ContainerRuntimeContainerd,
+		PID:      43,
+		State:    ContainerState{Running: true, CreatedAt: createdAt},
+	})
+
+	merged := entity.get(SourceAll).(*Container)
+	assert.Equal(t, "ctr-name", merged.Name)
+	assert.Equal(t, map[string]string{"team": "containers"}, merged.Labels)
+	assert.Equal(t, "ctr-host", merged.Hostname)
+	assert.Equal(t, ContainerRuntimeContainerd, merged.Runtime)
+	assert.Equal(t, 43, merged.PID)
+	assert.True(t, merged.State.Running)
+	assert.Equal(t, createdAt, merged.State.CreatedAt)
+}
+
+func TestCachedEntityMergesOverlappingFields(t *testing.T) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	l, err := seelog.LoggerFromWriterWithMinLevelAndFormat(w, seelog.DebugLvl, "[%LEVEL] %FuncShort: %Msg")
+	require.NoError(t, err)
+	previousLogger := log.Logger
+	log.SetupLogger(l, "debug")
+	t.Cleanup(func() { log.Logger = previousLogger })
+
+	earlier := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	later := earlier.Add(time.Minute)
+
+	entity := newCachedEntity(nil)
+	entity.set(orchestratorSource, &Container{
+		EntityID:   EntityID{Kind: KindContainer, ID: "ctr-id"},
+		EntityMeta: EntityMeta{Name: "ctr-name"},
+		Image:      ContainerImage{Name: "agent", Tag: "7.42"},
+		State:      ContainerState{StartedAt: earlier},
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Name: "agent", Tag: "7.43"},
+		State:    ContainerState{StartedAt: later},
+	})
+
+	// The most recently updated source wins
+	merged := entity.get(SourceAll).(*Container)
+	assert.Equal(t, "ctr-name", merged.Name)
+	assert.Equal(t, "7.43", merged.Image.Tag)
+	assert.Equal(t, later, merged.State.StartedAt)
+
+	// Until the other one is updated
+	entity.set(orchestratorSource, &Container{
+		EntityID:   EntityID{Kind: KindContainer, ID: "ctr-id"},
+		EntityMeta: EntityMeta{Name: "ctr-name"},
+		Image:      ContainerImage{Name: "agent", Tag: "7.44"},
+	})
+
+	merged = entity.get(SourceAll).(*Container)
+	assert.Equal(t, "7.44", merged.Image.Tag)
+	assert.Equal(t, later, merged.State.StartedAt)
+
+	// The sources keep their own version of the entity
+	assert.Equal(t, "7.43", entity.get(runtimeSource).(*Container).Image.Tag)
+
+	w.Flush()
+	logs := b.String()
+	assert.Contains(t, logs, "[DEBUG] computeCache: Conflicting values for field Image.Tag of container ctr-id, keeping the value of the most recently updated of sources [runtime] over the one of source orchestrator")
+	assert.Contains(t, logs, "[DEBUG] computeCache: Conflicting values for field State.StartedAt of container ctr-id")
+	assert.Contains(t, logs, "[DEBUG] computeCache: Conflicting values for field Image.Tag of container ctr-id, keeping the value of the most recently updated of sources [orchestrator] over the one of source runtime")
+	assert.NotContains(t, logs, "field Image.Name")
+	assert.NotContains(t, logs, "field Name")
+}
+
+func TestCachedEntityUnsetSource(t *testing.T) {
+	entity := newCachedEntity(nil)
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.42"},
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+	})
+
+	assert.True(t, entity.unset(runtimeSource))
+	assert.Equal(t, "7.42", entity.get(SourceAll).(*Container).Image.Tag)
+
+	// Setting the same entity again is not an update, it doesn't make the
+	// source more recent
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+	})
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.42"},
+	})
+	assert.Equal(t, "7.43", entity.get(SourceAll).(*Container).Image.Tag)
+}
+
+func TestCachedEntityRefreshOfCollections(t *testing.T) {
+	entity := newCachedEntity(nil)
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+		Ports:    []ContainerPort{{Port: 80}, {Port: 443}},
+	})
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.42"},
+	})
+
+	// The runtime lists its ports in another order: it's not an update of
+	// its values, the orchestrator stays the most recently updated
+	_, changed := entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+		Ports:    []ContainerPort{{Port: 443}, {Port: 80}},
+	})
+	assert.True(t, changed)
+	assert.Equal(t, "7.42", entity.get(SourceAll).(*Container).Image.Tag)
+	assert.Equal(t, []ContainerPort{{Port: 443}, {Port: 80}}, entity.get(runtimeSource).(*Container).Ports)
+
+	// Until it updates one
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+		Ports:    []ContainerPort{{Port: 443}, {Port: 80}},
+		PID:      42,
+	})
+	assert.Equal(t, "7.43", entity.get(SourceAll).(*Container).Image.Tag)
+}
+
+func TestCachedEntityMergesBySourcePriority(t *testing.T) {
+	// The orchestrator wins the conflicts, even when the runtime is more
+	// recently updated
+	entity := newCachedEntity(sourcePriorities([]string{string(orchestratorSource), string(runtimeSource)}))
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Name: "agent", Tag: "7.42"},
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Name: "agent", Tag: "7.43"},
+		PID:      42,
+	})
+
+	merged := entity.get(SourceAll).(*Container)
+	assert.Equal(t, "7.42", merged.Image.Tag)
+	assert.Equal(t, 42, merged.PID)
+
+	// Without priorities, the most recently updated source wins
+	entity = newCachedEntity(sourcePriorities(nil))
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.42"},
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+	})
+	assert.Equal(t, "7.43", entity.get(SourceAll).(*Container).Image.Tag)
+}
+
+func TestCachedEntityMergesUnlistedSourcesByRecency(t *testing.T) {
+	const clusterOrchestratorSource Source = "cluster_orchestrator"
+
+	entity := newCachedEntity(sourcePriorities([]string{string(clusterOrchestratorSource)}))
+	entity.set(clusterOrchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.41"},
+	})
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.42"},
+		Hostname: "orchestrator-host",
+	})
+	entity.set(runtimeSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.43"},
+		Hostname: "runtime-host",
+	})
+
+	// The listed source wins over the other ones, which tie and fall back to
+	// the most recently updated
+	merged := entity.get(SourceAll).(*Container)
+	assert.Equal(t, "7.41", merged.Image.Tag)
+	assert.Equal(t, "runtime-host", merged.Hostname)
+
+	entity.set(orchestratorSource, &Container{
+		EntityID: EntityID{Kind: KindContainer, ID: "ctr-id"},
+		Image:    ContainerImage{Tag: "7.44"},
+		Hostname: "orchestrator-host-2",
+	})
+	merged = entity.get(SourceAll).(*Container)
+	assert.Equal(t, "7.41", merged.Image.Tag)
+	assert.Equal(t, "orchestrator-host-2", merged.Hostname)
+}
+
+func TestSourcePriorities(t *testing.T) {
+	assert.Nil(t, sourcePriorities(nil))
+	assert.Equal(t, map[Source]int{
+		SourceNodeOrchestrator: 0,
+		SourceRuntime:          1,
+	}, sourcePriorities([]string{"node_orchestrator", "runtime", "node_orchestrator"}))
+}
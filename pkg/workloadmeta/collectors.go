@@ -35,6 +35,10 @@ var (
 
 	// RemoteCatalog collectors to run when workloadmeta is configured as remote
 	RemoteCatalog = make(CollectorCatalog)
+
+	// expiringEntities are the kinds of entities expired for each source,
+	// see RegisterExpiringEntities
+	expiringEntities = make(map[Source]map[Kind]struct{})
 )
 
 // RegisterCollector registers a new collector in the NodeAgentCatalog,
@@ -57,3 +61,18 @@ func RegisterClusterCollector(id string, c collectorFactory) {
 func RegisterRemoteCollector(id string, c collectorFactory) {
 	RemoteCatalog[id] = c
 }
+
+// RegisterExpiringEntities opts the entities of the given kinds set by the
+// source in to the expiry of the entities not refreshed within
+// `workloadmeta.entity_ttl`. It's meant for the collectors setting all their
+// entities again on every pull, and only for the kinds that no collector
+// updating them on events sets with the same source. Like the collectors, it's
+// meant to be registered in an init function.
+func RegisterExpiringEntities(source Source, kinds ...Kind) {
+	if expiringEntities[source] == nil {
+		expiringEntities[source] = make(map[Kind]struct{})
+	}
+	for _, kind := range kinds {
+		expiringEntities[source][kind] = struct{}{}
+	}
+}
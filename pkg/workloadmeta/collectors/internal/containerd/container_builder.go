@@ -14,6 +14,7 @@ import (
 
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/oci"
 
 	cutil "github.com/DataDog/datadog-agent/pkg/util/containerd"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -28,17 +29,17 @@ func buildWorkloadMetaContainer(namespace string, container containerd.Container
 
 	info, err := containerdClient.Info(namespace, container)
 	if err != nil {
-		return workloadmeta.Container{}, err
+		return workloadmeta.Container{}, classifyContainerdError(err, nil)
 	}
 
 	spec, err := containerdClient.Spec(namespace, container)
 	if err != nil {
-		return workloadmeta.Container{}, err
+		return workloadmeta.Container{}, classifyContainerdError(err, ErrSpecParse)
 	}
 
 	envs, err := cutil.EnvVarsFromSpec(spec)
 	if err != nil {
-		return workloadmeta.Container{}, err
+		return workloadmeta.Container{}, classifyContainerdError(err, ErrSpecParse)
 	}
 
 	image, err := workloadmeta.NewContainerImage(info.Image)
@@ -49,7 +50,7 @@ func buildWorkloadMetaContainer(namespace string, container containerd.Container
 	status, err := containerdClient.Status(namespace, container)
 	if err != nil {
 		if !errdefs.IsNotFound(err) {
-			return workloadmeta.Container{}, err
+			return workloadmeta.Container{}, classifyContainerdError(err, nil)
 		}
 
 		// The container exists, but there isn't a task associated to it. That
@@ -82,6 +83,7 @@ func buildWorkloadMetaContainer(namespace string, container containerd.Container
 		Image:   image,
 		EnvVars: envs,
 		Ports:   nil, // Not available
+		Mounts:  extractMounts(spec),
 		Runtime: workloadmeta.ContainerRuntimeContainerd,
 		State: workloadmeta.ContainerState{
 			Running:    status == containerd.Running,
@@ -96,6 +98,39 @@ func buildWorkloadMetaContainer(namespace string, container containerd.Container
 	}, nil
 }
 
+// extractMounts returns the mounts defined in the spec of the container, or
+// nil if there are none.
+func extractMounts(spec *oci.Spec) []workloadmeta.ContainerMount {
+	if spec == nil || len(spec.Mounts) == 0 {
+		return nil
+	}
+
+	mounts := make([]workloadmeta.ContainerMount, 0, len(spec.Mounts))
+	for _, mount := range spec.Mounts {
+		mounts = append(mounts, workloadmeta.ContainerMount{
+			Source:      mount.Source,
+			Destination: mount.Destination,
+			ReadOnly:    isReadOnlyMount(mount.Options),
+		})
+	}
+
+	return mounts
+}
+
+func isReadOnlyMount(options []string) bool {
+	// When an option is repeated, the last one wins
+	readOnly := false
+	for _, option := range options {
+		switch option {
+		case "ro":
+			readOnly = true
+		case "rw":
+			readOnly = false
+		}
+	}
+	return readOnly
+}
+
 func extractStatus(status containerd.ProcessStatus) workloadmeta.ContainerStatus {
 	switch status {
 	case containerd.Paused, containerd.Pausing:
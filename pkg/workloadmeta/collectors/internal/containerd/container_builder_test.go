@@ -125,3 +125,86 @@ func TestBuildWorkloadMetaContainer(t *testing.T) {
 	}
 	assert.Equal(t, expected, result)
 }
+
+func TestBuildWorkloadMetaContainerMounts(t *testing.T) {
+	container := mockedContainer{
+		mockID: func() string {
+			return "10"
+		},
+	}
+
+	tests := []struct {
+		name           string
+		mounts         []specs.Mount
+		expectedMounts []workloadmeta.ContainerMount
+	}{
+		{
+			name:           "no mounts",
+			mounts:         nil,
+			expectedMounts: nil,
+		},
+		{
+			name: "mounts",
+			mounts: []specs.Mount{
+				{
+					Destination: "/proc",
+					Type:        "proc",
+					Source:      "proc",
+					Options:     []string{"nosuid", "noexec", "nodev"},
+				},
+				{
+					Destination: "/etc/config",
+					Type:        "bind",
+					Source:      "/var/lib/kubelet/pods/123/volumes/config",
+					Options:     []string{"rbind", "rprivate", "ro"},
+				},
+				{
+					Destination: "/data",
+					Type:        "bind",
+					Source:      "/mnt/data",
+					Options:     []string{"rbind", "ro", "rw"},
+				},
+			},
+			expectedMounts: []workloadmeta.ContainerMount{
+				{
+					Source:      "proc",
+					Destination: "/proc",
+					ReadOnly:    false,
+				},
+				{
+					Source:      "/var/lib/kubelet/pods/123/volumes/config",
+					Destination: "/etc/config",
+					ReadOnly:    true,
+				},
+				{
+					Source:      "/mnt/data",
+					Destination: "/data",
+					ReadOnly:    false,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.MockedContainerdClient{
+				MockInfo: func(namespace string, ctn containerd.Container) (containers.Container, error) {
+					return containers.Container{Image: "datadog/agent:7"}, nil
+				},
+				MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+					return &oci.Spec{Process: &specs.Process{}, Mounts: test.mounts}, nil
+				},
+				MockStatus: func(namespace string, ctn containerd.Container) (containerd.ProcessStatus, error) {
+					return containerd.Running, nil
+				},
+				MockTaskPids: func(namespace string, ctn containerd.Container) ([]containerd.ProcessInfo, error) {
+					return nil, nil
+				},
+			}
+
+			result, err := buildWorkloadMetaContainer("default", &container, &client)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedMounts, result.Mounts)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+type namespacedContainer struct {
+	namespace   string
+	container   containerd.Container
+	containerID string
+
+	// imageName is the name of the image of the container, used to find
+	// the SBOM of the image
+	imageName string
+}
+
+func containerSBOMCollectionIsEnabled() bool {
+	return config.Datadog.GetBool("container_image_collection.sbom.container_scan.enabled")
+}
+
+// Sandbox containers are only scanned when requested, because they only run
+// the pause binary of their image
+func sandboxContainersAreScanned() bool {
+	return !config.Datadog.GetBool("container_image_collection.sbom.container_scan.exclude_sandbox_containers")
+}
+
+// handleContainerSBOM keeps the SBOM of a container that is updated, and
+// sends the running containers that have not been scanned to the container
+// scan worker. Containers are scanned only once, as scanning their root
+// filesystem is expensive.
+func (c *collector) handleContainerSBOM(namespace string, container containerd.Container, entity *workloadmeta.Container) {
+	if c.containersToScan == nil {
+		return
+	}
+
+	// The entities are built from scratch for every containerd event, so the
+	// SBOM of the container would be lost without this.
+	if stored, err := c.store.GetContainer(entity.ID); err == nil && stored.CycloneDXBOM != nil {
+		entity.CycloneDXBOM = stored.CycloneDXBOM
+		return
+	}
+
+	if entity.IsSandbox && !sandboxContainersAreScanned() {
+		return
+	}
+
+	if !entity.State.Running || container == nil || !c.scannedContainers.markAsScanned(entity.ID) {
+		return
+	}
+
+	containerToScan := namespacedContainer{
+		namespace:   namespace,
+		container:   container,
+		containerID: entity.ID,
+		imageName:   entity.Image.RawName,
+	}
+
+	c.afterNotify(func() {
+		select {
+		case c.containersToScan <- containerToScan:
+		default:
+			log.Warnf("Container SBOM scan queue is full, skipping scan of container: %s/%s", namespace, entity.ID)
+			c.scannedContainers.forget(entity.ID)
+		}
+	})
+}
+
+// forgetContainerSBOM forgets that a deleted container was scanned.
+func (c *collector) forgetContainerSBOM(containerID string) {
+	if c.scannedContainers != nil {
+		c.scannedContainers.forget(containerID)
+	}
+}
+
+// imageSBOMOfContainer returns the SBOM of the image of the container, if
+// it has been collected.
+func (c *collector) imageSBOMOfContainer(containerToScan namespacedContainer) *cyclonedx.BOM {
+	imageID, found := c.knownImages.getImageID(containerToScan.namespace, containerToScan.imageName)
+	if !found {
+		return nil
+	}
+
+	image, err := c.store.GetImage(imageID)
+	if err != nil {
+		return nil
+	}
+
+	return image.CycloneDXBOM
+}
+
+// mergeContainerSBOM returns the SBOM of the image, with the components
+// found in the root filesystem of the container that are not part of the
+// image, like the packages installed at runtime.
+func mergeContainerSBOM(imageBOM *cyclonedx.BOM, rootfsBOM *cyclonedx.BOM) *cyclonedx.BOM {
+	if imageBOM == nil {
+		return rootfsBOM
+	}
+
+	seen := make(map[string]struct{})
+	var components []cyclonedx.Component
+
+	for _, bom := range []*cyclonedx.BOM{imageBOM, rootfsBOM} {
+		if bom == nil || bom.Components == nil {
+			continue
+		}
+
+		for _, component := range *bom.Components {
+			key := componentKey(component)
+			if _, found := seen[key]; found {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			components = append(components, component)
+		}
+	}
+
+	merged := *imageBOM
+	if rootfsBOM != nil {
+		merged.SerialNumber = rootfsBOM.SerialNumber
+	}
+	merged.Components = &components
+
+	return &merged
+}
+
+// componentKey identifies a component across SBOMs. The PURL is used when
+// available, because it includes the version and the distribution of the
+// package.
+func componentKey(component cyclonedx.Component) string {
+	if component.PackageURL != "" {
+		return component.PackageURL
+	}
+
+	return string(component.Type) + "/" + component.Name + "@" + component.Version
+}
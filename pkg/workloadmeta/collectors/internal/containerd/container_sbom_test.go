@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestMergeContainerSBOM(t *testing.T) {
+	libc := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "libc6", Version: "2.31-13", PackageURL: "pkg:deb/debian/libc6@2.31-13"}
+	curl := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "curl", Version: "7.74.0", PackageURL: "pkg:deb/debian/curl@7.74.0"}
+	os := cyclonedx.Component{Type: cyclonedx.ComponentTypeOS, Name: "debian", Version: "11.6"}
+
+	imageBOM := newTestBOM("urn:uuid:image")
+	imageBOM.Metadata = &cyclonedx.Metadata{Component: &cyclonedx.Component{Name: "debian:11"}}
+	imageBOM.Components = &[]cyclonedx.Component{os, libc}
+
+	rootfsBOM := newTestBOM("urn:uuid:rootfs")
+	rootfsBOM.Components = &[]cyclonedx.Component{os, libc, curl}
+
+	merged := mergeContainerSBOM(imageBOM, rootfsBOM)
+	assert.Equal(t, "urn:uuid:rootfs", merged.SerialNumber)
+	assert.Equal(t, imageBOM.Metadata, merged.Metadata)
+	assert.Equal(t, []cyclonedx.Component{os, libc, curl}, *merged.Components)
+
+	// The image SBOM is not modified
+	assert.Equal(t, []cyclonedx.Component{os, libc}, *imageBOM.Components)
+
+	// Without an image SBOM, the SBOM of the root filesystem is used as is
+	assert.Equal(t, rootfsBOM, mergeContainerSBOM(nil, rootfsBOM))
+}
+
+func TestHandleContainerSBOMDisabled(t *testing.T) {
+	c := collector{}
+
+	entity := &workloadmeta.Container{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainer,
+			ID:   "ctn",
+		},
+		State: workloadmeta.ContainerState{
+			Running: true,
+		},
+	}
+
+	// Nothing to do when container SBOM collection is disabled
+	c.handleContainerSBOM("default", &mockedContainer{mockID: func() string { return "ctn" }}, entity)
+	c.forgetContainerSBOM("ctn")
+	assert.Nil(t, entity.CycloneDXBOM)
+}
+
+func TestHandleContainerSBOMSandbox(t *testing.T) {
+	cfg := config.Mock(t)
+
+	c := collector{
+		store:             newFakeImageStore(),
+		containersToScan:  make(chan namespacedContainer, 1),
+		scannedContainers: newScannedImages(),
+	}
+
+	newEntity := func(id string, isSandbox bool) *workloadmeta.Container {
+		return &workloadmeta.Container{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainer,
+				ID:   id,
+			},
+			State: workloadmeta.ContainerState{
+				Running: true,
+			},
+			IsSandbox: isSandbox,
+		}
+	}
+
+	// Sandboxes are not scanned by default
+	c.handleContainerSBOM("k8s.io", &mockedContainer{mockID: func() string { return "pause" }}, newEntity("pause", true))
+	assert.Empty(t, c.containersToScan)
+	assert.False(t, c.scannedContainers.isScanned("pause"))
+
+	// Other containers are
+	c.handleContainerSBOM("k8s.io", &mockedContainer{mockID: func() string { return "app" }}, newEntity("app", false))
+	require.Len(t, c.containersToScan, 1)
+	assert.Equal(t, "app", (<-c.containersToScan).containerID)
+
+	cfg.Set("container_image_collection.sbom.container_scan.exclude_sandbox_containers", false)
+	c.handleContainerSBOM("k8s.io", &mockedContainer{mockID: func() string { return "pause" }}, newEntity("pause", true))
+	require.Len(t, c.containersToScan, 1)
+	assert.Equal(t, "pause", (<-c.containersToScan).containerID)
+}
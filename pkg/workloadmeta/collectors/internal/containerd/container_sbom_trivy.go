@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// Containers are scanned much less often than images, they are only scanned
+// once when they start
+const containersToScanBufferSize = 500
+
+// startContainerSBOMScanWorker starts the worker scanning the root
+// filesystems of the containers sent to containersToScan. Only one container
+// is scanned at a time, and the scans are rate limited, because they are
+// more expensive than image scans: nothing can be cached between them. The
+// worker stops when containersToScan is closed, see stopSBOMScans.
+func (c *collector) startContainerSBOMScanWorker(maxScansPerMinute int) {
+	c.containersToScan = make(chan namespacedContainer, containersToScanBufferSize)
+	c.scannedContainers = newScannedImages()
+	c.containerScanRateLimiter = newScanRateLimiter(maxScansPerMinute)
+	ctx := c.sbomScanContext()
+
+	c.sbomScanWorkers.Add(1)
+	go func() {
+		defer c.sbomScanWorkers.Done()
+
+		for containerToScan := range c.containersToScan {
+			// The scans are stopped, drop the containers still queued
+			if ctx.Err() != nil {
+				continue
+			}
+
+			if err := c.extractContainerSBOM(ctx, containerToScan); err != nil {
+				log.Warnf("error extracting SBOM for container: namespace=%s id=%s, err: %s", containerToScan.namespace, containerToScan.containerID, err)
+
+				// Scan the container again the next time it's seen
+				c.scannedContainers.forget(containerToScan.containerID)
+			}
+		}
+	}()
+}
+
+func (c *collector) extractContainerSBOM(ctx context.Context, containerToScan namespacedContainer) error {
+	storedContainer, err := c.store.GetContainer(containerToScan.containerID)
+	if err != nil || !storedContainer.State.Running {
+		log.Debugf("Container: %s/%s not running, skipping scan", containerToScan.namespace, containerToScan.containerID)
+		c.scannedContainers.forget(containerToScan.containerID)
+		return nil
+	}
+
+	if c.containerScanRateLimiter != nil {
+		if err := c.containerScanRateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	rootfs, err := c.containerRootfs(containerToScan)
+	if err != nil {
+		return err
+	}
+
+	rootfsBOM, err := scanWithTimeout(ctx, scanningTimeout(), func(scanContext context.Context) (*cyclonedx.BOM, error) {
+		return c.trivyClient.ScanFilesystem(scanContext, rootfs)
+	})
+	if err != nil {
+		return err
+	}
+
+	bom := mergeContainerSBOM(c.imageSBOMOfContainer(containerToScan), rootfsBOM)
+	c.notifyContainerWithBOM(storedContainer, c.processSBOM(ctx, bom, fmt.Sprintf("container %s/%s", containerToScan.namespace, containerToScan.containerID)))
+
+	return nil
+}
+
+// containerRootfs returns the path of the root filesystem of a running
+// container as seen by its processes, which includes its writable layer.
+func (c *collector) containerRootfs(containerToScan namespacedContainer) (string, error) {
+	pids, err := c.containerdClient.TaskPids(containerToScan.namespace, containerToScan.container)
+	if err != nil {
+		return "", fmt.Errorf("error getting the PIDs of the container: %w", err)
+	}
+
+	if len(pids) == 0 {
+		return "", fmt.Errorf("no PIDs found for the container")
+	}
+
+	return filepath.Join(config.Datadog.GetString("container_proc_root"), strconv.Itoa(int(pids[0].Pid)), "root"), nil
+}
+
+// notifyContainerWithBOM generates an update event for the stored container
+// with its SBOM.
+func (c *collector) notifyContainerWithBOM(storedContainer *workloadmeta.Container, bom *cyclonedx.BOM) {
+	// The event is built from a copy of the stored container for the same
+	// reasons as the images, see notifyImageWithBOM
+	scannedContainer := *storedContainer
+	scannedContainer.CycloneDXBOM = bom
+
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeSet,
+			Source: workloadmeta.SourceRuntime,
+			Entity: &scannedContainer,
+		},
+	})
+}
@@ -0,0 +1,188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// rootfsScanner finds the packages of a root filesystem in its "packages"
+// directory, which contains one file named "<name>@<version>" per package.
+type rootfsScanner struct {
+	fakeScanner
+}
+
+func (s *rootfsScanner) ScanFilesystem(ctx context.Context, path string) (*cyclonedx.BOM, error) {
+	bom, _ := s.fakeScanner.ScanFilesystem(ctx, path)
+
+	entries, err := os.ReadDir(filepath.Join(path, "packages"))
+	if err != nil {
+		return nil, err
+	}
+
+	var components []cyclonedx.Component
+	for _, entry := range entries {
+		name, version, _ := strings.Cut(entry.Name(), "@")
+		components = append(components, newTestDebComponent(name, version))
+	}
+	bom.Components = &components
+
+	return bom, nil
+}
+
+func newTestDebComponent(name string, version string) cyclonedx.Component {
+	return cyclonedx.Component{
+		Type:       cyclonedx.ComponentTypeLibrary,
+		Name:       name,
+		Version:    version,
+		PackageURL: "pkg:deb/debian/" + name + "@" + version,
+	}
+}
+
+func newTestRootfs(t *testing.T, procRoot string, pid string, packages ...string) string {
+	rootfs := filepath.Join(procRoot, pid, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "packages"), 0700))
+
+	for _, pkg := range packages {
+		require.NoError(t, os.WriteFile(filepath.Join(rootfs, "packages", pkg), nil, 0600))
+	}
+
+	return rootfs
+}
+
+func TestContainerSBOMIncludesPackagesAddedAtRuntime(t *testing.T) {
+	procRoot := t.TempDir()
+	cfg := config.Mock(t)
+	cfg.Set("container_proc_root", procRoot)
+
+	// The image contains libc6, curl is installed in the running container
+	rootfs := newTestRootfs(t, procRoot, "1234", "libc6@2.31-13", "curl@7.74.0")
+
+	imageBOM := newTestBOM("urn:uuid:image")
+	imageBOM.Components = &[]cyclonedx.Component{newTestDebComponent("libc6", "2.31-13")}
+
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+		CycloneDXBOM: imageBOM,
+	}
+	container := &workloadmeta.Container{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainer,
+			ID:   "ctn",
+		},
+		EntityMeta: workloadmeta.EntityMeta{
+			Namespace: "default",
+		},
+		Image: workloadmeta.ContainerImage{
+			RawName: "debian:11",
+		},
+		State: workloadmeta.ContainerState{
+			Running: true,
+		},
+	}
+
+	store := newFakeImageStore(image)
+	store.Set(container)
+
+	scanner := &rootfsScanner{fakeScanner{scans: make(map[string]int)}}
+	c := collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockTaskPids: func(namespace string, ctn containerd.Container) ([]containerd.ProcessInfo, error) {
+				return []containerd.ProcessInfo{{Pid: 1234}}, nil
+			},
+		},
+		knownImages: newKnownImages(),
+		trivyClient: scanner,
+	}
+	c.knownImages.addAssociation("default", "debian:11", "sha256:1")
+	c.startContainerSBOMScanWorker(0)
+	defer close(c.containersToScan)
+
+	ctn := &mockedContainer{mockID: func() string { return "ctn" }}
+
+	// Containers are scanned only once
+	for i := 0; i < 2; i++ {
+		entity := *container
+		c.handleContainerSBOM("default", ctn, &entity)
+	}
+
+	var event workloadmeta.CollectorEvent
+	select {
+	case event = <-store.events:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for container SBOM event")
+	}
+
+	assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+	scannedContainer := event.Entity.(*workloadmeta.Container)
+	assert.Equal(t, "ctn", scannedContainer.ID)
+	require.NotNil(t, scannedContainer.CycloneDXBOM)
+	assert.ElementsMatch(t, []cyclonedx.Component{
+		newTestDebComponent("libc6", "2.31-13"),
+		newTestDebComponent("curl", "7.74.0"),
+	}, *scannedContainer.CycloneDXBOM.Components)
+	assert.Equal(t, 1, scanner.scanCount(rootfs))
+
+	// The SBOM is kept when the container is updated by containerd
+	store.Set(scannedContainer)
+	updated := *container
+	c.handleContainerSBOM("default", ctn, &updated)
+	assert.Equal(t, scannedContainer.CycloneDXBOM, updated.CycloneDXBOM)
+
+	select {
+	case event := <-store.events:
+		assert.Failf(t, "unexpected event", "%+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 1, scanner.scanCount(rootfs))
+}
+
+func TestContainerSBOMSkipsStoppedContainers(t *testing.T) {
+	store := newFakeImageStore()
+	store.Set(&workloadmeta.Container{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainer,
+			ID:   "ctn",
+		},
+	})
+
+	scanner := &rootfsScanner{fakeScanner{scans: make(map[string]int)}}
+	c := collector{
+		store:       store,
+		trivyClient: scanner,
+	}
+	c.scannedContainers = newScannedImages()
+	c.scannedContainers.markAsScanned("ctn")
+
+	require.NoError(t, c.extractContainerSBOM(context.Background(), namespacedContainer{
+		namespace:   "default",
+		containerID: "ctn",
+	}))
+
+	assert.Empty(t, store.events)
+	assert.False(t, c.scannedContainers.isScanned("ctn"))
+}
@@ -12,11 +12,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util/trivy"
 	"github.com/containerd/containerd"
 	containerdevents "github.com/containerd/containerd/events"
+	"golang.org/x/time/rate"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	agentErrors "github.com/DataDog/datadog-agent/pkg/errors"
@@ -81,8 +83,25 @@ type collector struct {
 	store                  workloadmeta.Store
 	containerdClient       cutil.ContainerdItf
 	filterPausedContainers *containers.Filter
-	eventsChan             <-chan *containerdevents.Envelope
-	errorsChan             <-chan error
+	// Matches the images of the sandbox containers configured in
+	// containerd_sandbox_image_patterns. Nil when there are none.
+	filterSandboxImages *containers.Filter
+	// Whether sandbox containers are reported instead of being ignored
+	collectSandboxContainers bool
+	// Whether the images are reported with a summary of their signatures
+	// and attestations
+	collectImageSignatures bool
+	// Buffers the events of the containerd subscription until they are
+	// handled
+	eventBuffer *eventBuffer
+	errorsChan  <-chan error
+
+	// Batches the events of the initial inventory. Nil once it has been
+	// notified.
+	eventBatch *eventBatch
+
+	// Counts the events that couldn't be handled, by reason
+	eventErrorTelemetry *eventErrorTelemetry
 
 	// Container exit info (mainly exit code and exit timestamp) are attached to the corresponding task events.
 	// contToExitInfo caches the exit info of a task to enrich the container deletion event when it's received later.
@@ -95,12 +114,90 @@ type collector struct {
 
 	trivyClient  trivy.Collector // nolint: unused
 	imagesToScan chan namespacedImage
+
+	// Images whose SBOM has already been extracted
+	scannedImages *scannedImages
+
+	// On-disk cache of the extracted SBOMs. Nil when disabled.
+	sbomCache *sbomCache // nolint: unused
+
+	// In-memory cache of the SBOMs of image layers. When enabled, images
+	// are scanned layer by layer. Nil when disabled.
+	layerSBOMCache *layerSBOMCache // nolint: unused
+
+	// Limits the number of scans started per minute. Nil when there's no
+	// limit.
+	scanRateLimiter *rate.Limiter // nolint: unused
+
+	// Periodically rescans the images whose SBOM was extracted. Nil when
+	// images are never rescanned.
+	sbomRescanner *sbomRescanner
+
+	// Checks that the scanner works, and reports it in the readiness checks
+	sbomSelfTest *sbomSelfTest // nolint: unused
+
+	// Retries the scans that failed. Nil when failed scans are not retried.
+	sbomScanRetrier *sbomScanRetrier
+
+	// Whether the images are updated with the status of their SBOM scan
+	// every time it changes
+	sbomStatusEvents bool
+
+	// Metrics about the SBOM scans
+	sbomTelemetry *sbomTelemetry // nolint: unused
+
+	// Decides which images are scanned. Nil when all the images are scanned.
+	sbomFilter *sbomFilter // nolint: unused
+
+	// Limits of the SBOMs notified to the store
+	sbomLimits sbomLimits // nolint: unused
+
+	// Drops packages from the SBOMs based on their type. Nil when all the
+	// packages are kept.
+	sbomPackageFilter *packageTypeFilter // nolint: unused
+
+	// Images used by the running containers, which are the only ones scanned
+	// when this is not nil
+	usedImages *usedImages
+
+	// Attaches vulnerabilities to the SBOMs. Nil when they are not enriched.
+	vulnerabilitySource VulnerabilitySource
+
+	// Running containers whose root filesystem is scanned, to find the
+	// packages that are not part of their image. Nil when container SBOM
+	// collection is disabled.
+	containersToScan chan namespacedContainer
+
+	// Containers whose SBOM has already been extracted, keyed by container ID
+	scannedContainers *scannedImages
+
+	// Limits the number of container scans started per minute
+	containerScanRateLimiter *rate.Limiter // nolint: unused
+
+	// Context of the image and container scans, cancelled when they are
+	// stopped, and the workers running them. Nil when there are no workers.
+	sbomScansCtx    context.Context
+	cancelSBOMScans context.CancelFunc
+	sbomScanWorkers sync.WaitGroup
+
+	// Protects the scan queues from being closed while a scan is triggered
+	// from another goroutine
+	scanQueuesMut    sync.Mutex
+	scanQueuesClosed bool
 }
 
 type namespacedImage struct {
 	namespace string
 	image     containerd.Image
 	imageID   string
+
+	// rescan is true when the image was already scanned, and is scanned
+	// again because its rescan period elapsed.
+	rescan bool
+
+	// failedAttempts is the number of scans of the image that failed before
+	// this one, which is a retry when it's not zero.
+	failedAttempts int
 }
 
 func init() {
@@ -109,6 +206,7 @@ func init() {
 			contToExitInfo: make(map[string]*exitInfo),
 			knownImages:    newKnownImages(),
 			repoTags:       make(map[string][]string),
+			scannedImages:  newScannedImages(),
 		}
 	})
 }
@@ -126,7 +224,9 @@ func (c *collector) Start(ctx context.Context, store workloadmeta.Store) error {
 		return err
 	}
 
-	if err = c.startSBOMCollection(); err != nil {
+	// Images are still collected, without their SBOM, when SBOM collection is
+	// not available in this build.
+	if err = c.startSBOMCollection(); err != nil && !errors.Is(err, errSBOMCollectionUnavailable) {
 		return err
 	}
 
@@ -135,8 +235,27 @@ func (c *collector) Start(ctx context.Context, store workloadmeta.Store) error {
 		return err
 	}
 
+	c.filterSandboxImages, err = newSandboxImageFilter()
+	if err != nil {
+		return err
+	}
+
+	c.collectSandboxContainers = config.Datadog.GetBool("containerd_collect_sandbox_containers")
+	c.collectImageSignatures = config.Datadog.GetBool("container_image_collection.signatures.enabled")
+
+	c.eventErrorTelemetry = getDefaultEventErrorTelemetry()
+
+	c.eventBuffer, err = newEventBufferFromConfig()
+	if err != nil {
+		return err
+	}
+
 	eventsCtx, cancelEvents := context.WithCancel(ctx)
-	c.eventsChan, c.errorsChan = c.containerdClient.GetEvents().Subscribe(eventsCtx, subscribeFilters()...)
+	var subscription <-chan *containerdevents.Envelope
+	subscription, c.errorsChan = c.containerdClient.GetEvents().Subscribe(eventsCtx, subscribeFilters()...)
+	go c.eventBuffer.run(eventsCtx, subscription)
+
+	c.notifyRuntimeEvent(ctx)
 
 	err = c.notifyInitialEvents(ctx)
 	if err != nil {
@@ -151,9 +270,17 @@ func (c *collector) Start(ctx context.Context, store workloadmeta.Store) error {
 			}
 		}()
 		defer cancelEvents()
+		defer c.notifyRuntimeUnsetEvent()
+
+		defer c.stopSBOMScans(sbomShutdownTimeout())
 
-		if c.imagesToScan != nil {
-			defer close(c.imagesToScan)
+		// Deferred after stopping the scans, so that they run first and the
+		// rescanner and the retrier don't send to a closed channel.
+		if c.sbomRescanner != nil {
+			defer c.sbomRescanner.stop()
+		}
+		if c.sbomScanRetrier != nil {
+			defer c.sbomScanRetrier.stop()
 		}
 
 		c.stream(ctx)
@@ -174,9 +301,9 @@ func (c *collector) stream(ctx context.Context) {
 		select {
 		case <-healthHandle.C:
 
-		case ev := <-c.eventsChan:
+		case ev := <-c.eventBuffer.events:
 			if err := c.handleEvent(ctx, ev); err != nil {
-				log.Warnf(err.Error())
+				c.reportEventError(fmt.Sprintf("containerd event %s in namespace %s", ev.Topic, ev.Namespace), err)
 			}
 
 		case err := <-c.errorsChan:
@@ -197,7 +324,12 @@ func (c *collector) stream(ctx context.Context) {
 	}
 }
 
+// notifyInitialEvents notifies the images and containers that exist when the
+// collector starts. They are notified in batches, see eventBatch.
 func (c *collector) notifyInitialEvents(ctx context.Context) error {
+	c.startEventBatch(maxEventBatchSize)
+	defer c.stopEventBatch()
+
 	var containerEvents []workloadmeta.CollectorEvent
 
 	namespaces, err := cutil.NamespacesToWatch(ctx, c.containerdClient)
@@ -206,21 +338,23 @@ func (c *collector) notifyInitialEvents(ctx context.Context) error {
 	}
 
 	for _, namespace := range namespaces {
-		nsContainerEvents, err := c.generateInitialContainerEvents(namespace)
-		if err != nil {
-			return err
-		}
-		containerEvents = append(containerEvents, nsContainerEvents...)
-
+		// The images are handled first, so that the images of the
+		// containers are known when the containers are handled
 		if imageMetadataCollectionIsEnabled() {
 			if err := c.notifyInitialImageEvents(ctx, namespace); err != nil {
 				return err
 			}
 		}
+
+		nsContainerEvents, err := c.generateInitialContainerEvents(namespace)
+		if err != nil {
+			return err
+		}
+		containerEvents = append(containerEvents, nsContainerEvents...)
 	}
 
 	if len(containerEvents) > 0 {
-		c.store.Notify(containerEvents)
+		c.notify(containerEvents)
 	}
 
 	return nil
@@ -235,23 +369,29 @@ func (c *collector) generateInitialContainerEvents(namespace string) ([]workload
 	}
 
 	for _, container := range existingContainers {
-		// if ignoreContainer returns an error, keep the container
+		// if isSandboxContainer returns an error, keep the container
 		// regardless.  it might've been because of network errors, so
 		// it's better to keep a container we should've ignored than
 		// ignoring a container we should've kept
-		ignore, err := c.ignoreContainer(namespace, container)
+		isSandbox, err := c.isSandboxContainer(namespace, container)
 		if err != nil {
 			log.Debugf("Error while deciding to ignore event %s, keeping it: %s", container.ID(), err)
-		} else if ignore {
+		} else if c.ignoreContainer(isSandbox) {
 			continue
 		}
 
 		ev, err := createSetEvent(container, namespace, c.containerdClient)
 		if err != nil {
-			log.Warnf(err.Error())
+			c.reportEventError(fmt.Sprintf("existing container %s/%s", namespace, container.ID()), err)
 			continue
 		}
 
+		entity := ev.Entity.(*workloadmeta.Container)
+		entity.IsSandbox = isSandbox
+
+		c.handleContainerSBOM(namespace, container, entity)
+		c.handleImageUsage(namespace, container, entity)
+
 		events = append(events, ev)
 	}
 
@@ -287,11 +427,12 @@ func (c *collector) handleContainerEvent(ctx context.Context, containerdEvent *c
 		return fmt.Errorf("cannot extract container from event: %w", err)
 	}
 
+	isSandbox := false
 	if container != nil {
-		ignore, err := c.ignoreContainer(containerdEvent.Namespace, container)
+		isSandbox, err = c.isSandboxContainer(containerdEvent.Namespace, container)
 		if err != nil {
 			log.Debugf("Error while deciding to ignore event %s, keeping it: %s", container.ID(), err)
-		} else if ignore {
+		} else if c.ignoreContainer(isSandbox) {
 			return nil
 		}
 	}
@@ -306,6 +447,17 @@ func (c *collector) handleContainerEvent(ctx context.Context, containerdEvent *c
 		return fmt.Errorf("cannot build collector event: %w", err)
 	}
 
+	if workloadmetaEvent.Type == workloadmeta.EventTypeSet {
+		entity := workloadmetaEvent.Entity.(*workloadmeta.Container)
+		entity.IsSandbox = isSandbox
+
+		c.handleContainerSBOM(containerdEvent.Namespace, container, entity)
+		c.handleImageUsage(containerdEvent.Namespace, container, entity)
+	} else {
+		c.forgetContainerSBOM(containerID)
+		c.releaseImage(containerID)
+	}
+
 	c.store.Notify([]workloadmeta.CollectorEvent{workloadmetaEvent})
 
 	return nil
@@ -342,15 +494,16 @@ func (c *collector) extractContainerFromEvent(ctx context.Context, containerdEve
 	// container, but these events still need to be handled
 	container, err := c.containerdClient.ContainerWithContext(ctx, containerdEvent.Namespace, containerID)
 	if err != nil && !agentErrors.IsNotFound(err) {
-		return "", nil, err
+		return "", nil, classifyContainerdError(err, nil)
 	}
 
 	return containerID, container, nil
 }
 
-// ignoreContainer returns whether a containerd event should be ignored.
-// The ignored events are the ones that refer to a "pause" container.
-func (c *collector) ignoreContainer(namespace string, container containerd.Container) (bool, error) {
+// isSandboxContainer returns whether a container is a sandbox (a.k.a.
+// "pause") container. Sandboxes are detected by their label or annotation, or
+// by their image name.
+func (c *collector) isSandboxContainer(namespace string, container containerd.Container) (bool, error) {
 	isSandbox, err := c.containerdClient.IsSandbox(namespace, container)
 	if err != nil {
 		return false, err
@@ -365,8 +518,40 @@ func (c *collector) ignoreContainer(namespace string, container containerd.Conta
 		return false, err
 	}
 
-	// Only the image name is relevant to exclude paused containers
-	return c.filterPausedContainers.IsExcluded("", info.Image, ""), nil
+	// Only the image name is relevant to detect paused containers
+	if c.filterPausedContainers.IsExcluded("", info.Image, "") {
+		return true, nil
+	}
+
+	return c.filterSandboxImages != nil && c.filterSandboxImages.IsExcluded("", info.Image, ""), nil
+}
+
+// ignoreContainer returns whether the events of a container should be
+// ignored. The ignored events are the ones that refer to a sandbox container,
+// unless sandboxes are collected.
+func (c *collector) ignoreContainer(isSandbox bool) bool {
+	return isSandbox && !c.collectSandboxContainers
+}
+
+// newSandboxImageFilter returns a filter matching the images configured in
+// containerd_sandbox_image_patterns, or nil if there are none.
+func newSandboxImageFilter() (*containers.Filter, error) {
+	patterns := config.Datadog.GetStringSlice("containerd_sandbox_image_patterns")
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	excludeList := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		excludeList = append(excludeList, "image:"+pattern)
+	}
+
+	filter, err := containers.NewFilter(nil, excludeList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid containerd_sandbox_image_patterns: %w", err)
+	}
+
+	return filter, nil
 }
 
 func subscribeFilters() []string {
@@ -9,20 +9,37 @@
 package containerd
 
 import (
+	"context"
 	"testing"
 
 	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/events"
 	containerdcontainers "github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content/local"
+	containerdevents "github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/typeurl"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
 	"github.com/DataDog/datadog-agent/pkg/util/containers"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 )
 
-func TestIgnoreContainer(t *testing.T) {
+func TestIsSandboxContainer(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("containerd_sandbox_image_patterns", []string{`registry\.example\.com/infra/sandbox.*`})
+
 	pauseFilter, err := containers.GetPauseContainerFilter()
 	assert.NoError(t, err)
 
+	sandboxImageFilter, err := newSandboxImageFilter()
+	assert.NoError(t, err)
+
 	containerID := "123"
 
 	container := mockedContainer{
@@ -36,28 +53,35 @@ func TestIgnoreContainer(t *testing.T) {
 		imgName        string
 		isSandbox      bool
 		container      containerd.Container
-		expectsIgnored bool
+		expectsSandbox bool
 	}{
 		{
 			name:           "pause image",
 			imgName:        "k8s.gcr.io/pause",
 			container:      &container,
 			isSandbox:      false,
-			expectsIgnored: true,
+			expectsSandbox: true,
 		},
 		{
 			name:           "is sandbox",
 			imgName:        "k8s.gcr.io/pause",
 			container:      &container,
 			isSandbox:      true,
-			expectsIgnored: true,
+			expectsSandbox: true,
+		},
+		{
+			name:           "configured sandbox image",
+			imgName:        "registry.example.com/infra/sandbox:1.0",
+			container:      &container,
+			isSandbox:      false,
+			expectsSandbox: true,
 		},
 		{
 			name:           "non-pause container that exists",
 			imgName:        "datadog/agent",
 			container:      &container,
 			isSandbox:      false,
-			expectsIgnored: false,
+			expectsSandbox: false,
 		},
 	}
 
@@ -77,16 +101,200 @@ func TestIgnoreContainer(t *testing.T) {
 			containerdCollector := collector{
 				containerdClient:       &client,
 				filterPausedContainers: pauseFilter,
+				filterSandboxImages:    sandboxImageFilter,
 			}
 
-			ignored, err := containerdCollector.ignoreContainer("default", test.container)
+			isSandbox, err := containerdCollector.isSandboxContainer("default", test.container)
 			assert.NoError(t, err)
+			assert.Equal(t, test.expectsSandbox, isSandbox)
 
-			if test.expectsIgnored {
-				assert.True(t, ignored)
-			} else {
-				assert.False(t, ignored)
-			}
+			// Sandboxes are ignored unless they're collected
+			assert.Equal(t, test.expectsSandbox, containerdCollector.ignoreContainer(isSandbox))
+			containerdCollector.collectSandboxContainers = true
+			assert.False(t, containerdCollector.ignoreContainer(isSandbox))
 		})
 	}
 }
+
+func TestNewSandboxImageFilter(t *testing.T) {
+	cfg := config.Mock(t)
+
+	filter, err := newSandboxImageFilter()
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+
+	cfg.Set("containerd_sandbox_image_patterns", []string{"(invalid"})
+	_, err = newSandboxImageFilter()
+	assert.Error(t, err)
+}
+
+func TestHandleSandboxContainerEvent(t *testing.T) {
+	pauseFilter, err := containers.GetPauseContainerFilter()
+	require.NoError(t, err)
+
+	imagesByContainer := map[string]string{
+		"pause-container": "k8s.gcr.io/pause:3.9",
+		"app-container":   "docker.io/datadog/agent:7",
+	}
+
+	client := &fake.MockedContainerdClient{
+		MockContainerWithCtx: func(ctx context.Context, namespace string, id string) (containerd.Container, error) {
+			return &mockedContainer{mockID: func() string { return id }}, nil
+		},
+		MockIsSandbox: func(namespace string, ctn containerd.Container) (bool, error) {
+			return false, nil
+		},
+		MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+			return containerdcontainers.Container{Image: imagesByContainer[ctn.ID()]}, nil
+		},
+		MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+			return &oci.Spec{Process: &specs.Process{}}, nil
+		},
+		MockStatus: func(namespace string, ctn containerd.Container) (containerd.ProcessStatus, error) {
+			return containerd.Running, nil
+		},
+		MockTaskPids: func(namespace string, ctn containerd.Container) ([]containerd.ProcessInfo, error) {
+			return nil, nil
+		},
+	}
+
+	store := newFakeImageStore()
+	c := collector{
+		store:                  store,
+		containerdClient:       client,
+		filterPausedContainers: pauseFilter,
+	}
+
+	createContainer := func(containerID string) {
+		event, err := typeurl.MarshalAny(&events.ContainerCreate{ID: containerID})
+		require.NoError(t, err)
+
+		require.NoError(t, c.handleEvent(context.Background(), &containerdevents.Envelope{
+			Namespace: "k8s.io",
+			Topic:     containerCreationTopic,
+			Event:     event,
+		}))
+	}
+
+	// Sandboxes are ignored by default
+	createContainer("pause-container")
+	assert.Empty(t, store.events)
+
+	c.collectSandboxContainers = true
+	for containerID, expectsSandbox := range map[string]bool{
+		"pause-container": true,
+		"app-container":   false,
+	} {
+		createContainer(containerID)
+		require.Len(t, store.events, 1)
+
+		container := (<-store.events).Entity.(*workloadmeta.Container)
+		assert.Equal(t, containerID, container.ID)
+		assert.Equal(t, expectsSandbox, container.IsSandbox)
+	}
+}
+
+func TestNotifyInitialEventsMultipleNamespaces(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("containerd_namespaces", []string{"ns1", "ns2"})
+	cfg.Set("container_image_collection.metadata.enabled", true)
+
+	pauseFilter, err := containers.GetPauseContainerFilter()
+	require.NoError(t, err)
+
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	// Each namespace has its own container and image. ns3 is not watched.
+	imagesByNamespace := make(map[string]*fakeImage)
+	imageIDsByNamespace := make(map[string]string)
+	containersByNamespace := make(map[string]containerd.Container)
+	for _, namespace := range []string{"ns1", "ns2", "ns3"} {
+		imagesByNamespace[namespace], imageIDsByNamespace[namespace] = newFakeImage(t, contentStore, "docker.io/datadog/"+namespace+":latest", ocispec.Image{Author: namespace})
+
+		containerID := namespace + "-container"
+		containersByNamespace[namespace] = &mockedContainer{
+			mockID: func() string { return containerID },
+		}
+	}
+
+	client := &fake.MockedContainerdClient{
+		MockNamespaces: func(ctx context.Context) ([]string, error) {
+			return []string{"ns1", "ns2", "ns3"}, nil
+		},
+		MockContainers: func(namespace string) ([]containerd.Container, error) {
+			return []containerd.Container{containersByNamespace[namespace]}, nil
+		},
+		MockContainerWithCtx: func(ctx context.Context, namespace string, id string) (containerd.Container, error) {
+			return containersByNamespace[namespace], nil
+		},
+		MockListImages: func(namespace string) ([]containerd.Image, error) {
+			return []containerd.Image{imagesByNamespace[namespace]}, nil
+		},
+		MockIsSandbox: func(namespace string, ctn containerd.Container) (bool, error) {
+			return false, nil
+		},
+		MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+			return containerdcontainers.Container{Image: imagesByNamespace[namespace].Name()}, nil
+		},
+		MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+			return &oci.Spec{Process: &specs.Process{}}, nil
+		},
+		MockStatus: func(namespace string, ctn containerd.Container) (containerd.ProcessStatus, error) {
+			return containerd.Running, nil
+		},
+		MockTaskPids: func(namespace string, ctn containerd.Container) ([]containerd.ProcessInfo, error) {
+			return nil, nil
+		},
+	}
+
+	store := newFakeImageStore()
+	c := collector{
+		store:                  store,
+		containerdClient:       client,
+		filterPausedContainers: pauseFilter,
+		knownImages:            newKnownImages(),
+		repoTags:               make(map[string][]string),
+		scannedImages:          newScannedImages(),
+	}
+
+	require.NoError(t, c.notifyInitialEvents(context.Background()))
+
+	namespacesByEntity := make(map[workloadmeta.EntityID]string)
+	for len(store.events) > 0 {
+		event := <-store.events
+		assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+
+		switch entity := event.Entity.(type) {
+		case *workloadmeta.Container:
+			namespacesByEntity[entity.EntityID] = entity.Namespace
+		case *workloadmeta.ContainerImageMetadata:
+			namespacesByEntity[entity.EntityID] = entity.Namespace
+		}
+	}
+
+	expected := make(map[workloadmeta.EntityID]string)
+	for _, namespace := range []string{"ns1", "ns2"} {
+		expected[workloadmeta.EntityID{Kind: workloadmeta.KindContainer, ID: namespace + "-container"}] = namespace
+		expected[workloadmeta.EntityID{Kind: workloadmeta.KindContainerImageMetadata, ID: imageIDsByNamespace[namespace]}] = namespace
+	}
+	assert.Equal(t, expected, namespacesByEntity)
+
+	// Events are handled in the namespace they come from
+	for _, namespace := range []string{"ns2", "ns1"} {
+		event, err := typeurl.MarshalAny(&events.ContainerCreate{ID: namespace + "-container"})
+		require.NoError(t, err)
+
+		require.NoError(t, c.handleEvent(context.Background(), &containerdevents.Envelope{
+			Namespace: namespace,
+			Topic:     containerCreationTopic,
+			Event:     event,
+		}))
+
+		collectorEvent := <-store.events
+		container := collectorEvent.Entity.(*workloadmeta.Container)
+		assert.Equal(t, namespace+"-container", container.ID)
+		assert.Equal(t, namespace, container.Namespace)
+		assert.Equal(t, imagesByNamespace[namespace].Name(), container.Image.RawName)
+	}
+}
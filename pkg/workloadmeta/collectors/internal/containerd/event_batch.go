@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// Maximum number of events notified to the store at once. The initial
+// inventory is notified in batches of at most this size.
+const maxEventBatchSize = 1000
+
+// eventBatch collects the events of the initial inventory, which are notified
+// to the store in bulk instead of one by one, so that the subscribers of the
+// store are not notified of every image and container separately at startup.
+type eventBatch struct {
+	maxSize int
+	events  []workloadmeta.CollectorEvent
+
+	// Position in events of the set event of each image, to replace it when
+	// the image is notified again, for instance under another name
+	imageEvents map[string]int
+
+	// Images of the whole inventory, by ID. The store is updated
+	// asynchronously, so the images notified are looked up here first.
+	images map[string]*workloadmeta.ContainerImageMetadata
+
+	// Functions run once the pending events are notified, like sending
+	// images to the scan workers, which must not notify the result of a
+	// scan before the image itself
+	afterNotify []func()
+}
+
+func newEventBatch(maxSize int) *eventBatch {
+	return &eventBatch{
+		maxSize:     maxSize,
+		imageEvents: make(map[string]int),
+		images:      make(map[string]*workloadmeta.ContainerImageMetadata),
+	}
+}
+
+// add adds the events to the batch. It returns whether the batch is full and
+// must be flushed.
+func (b *eventBatch) add(events []workloadmeta.CollectorEvent) bool {
+	for _, event := range events {
+		image, isImage := event.Entity.(*workloadmeta.ContainerImageMetadata)
+		if !isImage || event.Type != workloadmeta.EventTypeSet {
+			b.events = append(b.events, event)
+			continue
+		}
+
+		b.images[image.ID] = image
+
+		if i, found := b.imageEvents[image.ID]; found {
+			b.events[i] = event
+			continue
+		}
+
+		b.imageEvents[image.ID] = len(b.events)
+		b.events = append(b.events, event)
+	}
+
+	return len(b.events) >= b.maxSize
+}
+
+// flush notifies the pending events to the store, then runs the functions
+// waiting for them.
+func (b *eventBatch) flush(store workloadmeta.Store) {
+	if len(b.events) > 0 {
+		store.Notify(b.events)
+	}
+
+	for _, f := range b.afterNotify {
+		f()
+	}
+
+	b.events = nil
+	b.imageEvents = make(map[string]int)
+	b.afterNotify = nil
+}
+
+// startEventBatch makes the collector batch the events it notifies, until
+// stopEventBatch is called. Only for the goroutine starting the collector,
+// as the event batch is not thread-safe.
+func (c *collector) startEventBatch(maxSize int) {
+	c.eventBatch = newEventBatch(maxSize)
+}
+
+// stopEventBatch notifies the events still in the batch and stops batching.
+func (c *collector) stopEventBatch() {
+	if c.eventBatch == nil {
+		return
+	}
+
+	c.eventBatch.flush(c.store)
+	c.eventBatch = nil
+}
+
+// notify notifies the events to the store, or adds them to the batch while
+// the initial inventory is generated.
+func (c *collector) notify(events []workloadmeta.CollectorEvent) {
+	if c.eventBatch == nil {
+		c.store.Notify(events)
+		return
+	}
+
+	if c.eventBatch.add(events) {
+		c.eventBatch.flush(c.store)
+	}
+}
+
+// afterNotify runs f once the events notified so far have been sent to the
+// store.
+func (c *collector) afterNotify(f func()) {
+	if c.eventBatch == nil {
+		f()
+		return
+	}
+
+	c.eventBatch.afterNotify = append(c.eventBatch.afterNotify, f)
+}
+
+// getImage returns the image with the given ID, including the images of the
+// batch that the store might not know yet.
+func (c *collector) getImage(imageID string) (*workloadmeta.ContainerImageMetadata, error) {
+	if c.eventBatch != nil {
+		if image, found := c.eventBatch.images[imageID]; found {
+			return image, nil
+		}
+	}
+
+	return c.store.GetImage(imageID)
+}
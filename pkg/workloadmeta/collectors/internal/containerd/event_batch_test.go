@@ -0,0 +1,223 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/events"
+	containerdcontainers "github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content/local"
+	containerdevents "github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/typeurl"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/util/containers"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+	workloadmetaTesting "github.com/DataDog/datadog-agent/pkg/workloadmeta/testing"
+)
+
+// batchRecordingStore is a workloadmeta store that records the events of
+// every call to Notify separately.
+type batchRecordingStore struct {
+	*workloadmetaTesting.Store
+
+	mut     sync.Mutex
+	batches [][]workloadmeta.CollectorEvent
+}
+
+func (s *batchRecordingStore) Notify(events []workloadmeta.CollectorEvent) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.batches = append(s.batches, events)
+}
+
+func (s *batchRecordingStore) notifiedBatches() [][]workloadmeta.CollectorEvent {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.batches
+}
+
+func newTestImageEvent(imageID string, name string) workloadmeta.CollectorEvent {
+	return workloadmeta.CollectorEvent{
+		Type:   workloadmeta.EventTypeSet,
+		Source: workloadmeta.SourceRuntime,
+		Entity: &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   imageID,
+			},
+			EntityMeta: workloadmeta.EntityMeta{
+				Name: name,
+			},
+		},
+	}
+}
+
+func TestEventBatch(t *testing.T) {
+	store := &batchRecordingStore{Store: workloadmetaTesting.NewStore()}
+	c := collector{store: store}
+
+	c.startEventBatch(3)
+
+	// Notifying the same image again replaces its pending event
+	c.notify([]workloadmeta.CollectorEvent{newTestImageEvent("sha256:1", "agent@sha256:1")})
+	c.notify([]workloadmeta.CollectorEvent{newTestImageEvent("sha256:1", "agent:7")})
+
+	image, err := c.getImage("sha256:1")
+	require.NoError(t, err)
+	assert.Equal(t, "agent:7", image.Name)
+
+	var notifiedBeforeScan int
+	c.afterNotify(func() { notifiedBeforeScan = len(store.notifiedBatches()) })
+	assert.Empty(t, store.notifiedBatches())
+
+	// The batch is flushed when it's full
+	c.notify([]workloadmeta.CollectorEvent{
+		newTestImageEvent("sha256:2", "agent:6"),
+		newTestImageEvent("sha256:3", "cluster-agent:1"),
+	})
+	require.Len(t, store.notifiedBatches(), 1)
+	assert.Len(t, store.notifiedBatches()[0], 3)
+	assert.Equal(t, "agent:7", store.notifiedBatches()[0][0].Entity.(*workloadmeta.ContainerImageMetadata).Name)
+	assert.Equal(t, 1, notifiedBeforeScan)
+
+	// The images of the batches already flushed are still found
+	image, err = c.getImage("sha256:3")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster-agent:1", image.Name)
+
+	c.notify([]workloadmeta.CollectorEvent{newTestImageEvent("sha256:4", "agent:5")})
+	c.stopEventBatch()
+	require.Len(t, store.notifiedBatches(), 2)
+	assert.Len(t, store.notifiedBatches()[1], 1)
+
+	// Without a batch, the events are notified right away
+	c.notify([]workloadmeta.CollectorEvent{newTestImageEvent("sha256:5", "agent:4")})
+	require.Len(t, store.notifiedBatches(), 3)
+
+	ran := false
+	c.afterNotify(func() { ran = true })
+	assert.True(t, ran)
+
+	_, err = c.getImage("sha256:1")
+	assert.Error(t, err)
+}
+
+func TestNotifyInitialEventsInBatch(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("containerd_namespaces", []string{"default"})
+	cfg.Set("container_image_collection.metadata.enabled", true)
+
+	pauseFilter, err := containers.GetPauseContainerFilter()
+	require.NoError(t, err)
+
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	var existingImages []containerd.Image
+	var existingContainers []containerd.Container
+	containersByID := make(map[string]containerd.Container)
+	for i := 0; i < 3; i++ {
+		image, _ := newFakeImage(t, contentStore, fmt.Sprintf("docker.io/datadog/app%d:latest", i), ocispec.Image{Author: fmt.Sprint(i)})
+		existingImages = append(existingImages, image)
+
+		containerID := fmt.Sprintf("container%d", i)
+		container := &mockedContainer{
+			mockID: func() string { return containerID },
+		}
+		existingContainers = append(existingContainers, container)
+		containersByID[containerID] = container
+	}
+
+	client := &fake.MockedContainerdClient{
+		MockNamespaces: func(ctx context.Context) ([]string, error) {
+			return []string{"default"}, nil
+		},
+		MockContainers: func(namespace string) ([]containerd.Container, error) {
+			return existingContainers, nil
+		},
+		MockContainerWithCtx: func(ctx context.Context, namespace string, id string) (containerd.Container, error) {
+			return containersByID[id], nil
+		},
+		MockListImages: func(namespace string) ([]containerd.Image, error) {
+			return existingImages, nil
+		},
+		MockIsSandbox: func(namespace string, ctn containerd.Container) (bool, error) {
+			return false, nil
+		},
+		MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+			return containerdcontainers.Container{Image: existingImages[0].Name()}, nil
+		},
+		MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+			return &oci.Spec{Process: &specs.Process{}}, nil
+		},
+		MockStatus: func(namespace string, ctn containerd.Container) (containerd.ProcessStatus, error) {
+			return containerd.Running, nil
+		},
+		MockTaskPids: func(namespace string, ctn containerd.Container) ([]containerd.ProcessInfo, error) {
+			return nil, nil
+		},
+	}
+
+	store := &batchRecordingStore{Store: workloadmetaTesting.NewStore()}
+	c := collector{
+		store:                  store,
+		containerdClient:       client,
+		filterPausedContainers: pauseFilter,
+		knownImages:            newKnownImages(),
+		repoTags:               make(map[string][]string),
+		scannedImages:          newScannedImages(),
+	}
+
+	// The whole inventory is notified at once, the images first
+	require.NoError(t, c.notifyInitialEvents(context.Background()))
+
+	batches := store.notifiedBatches()
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 6)
+	for i, event := range batches[0] {
+		if i < 3 {
+			assert.IsType(t, &workloadmeta.ContainerImageMetadata{}, event.Entity)
+		} else {
+			assert.IsType(t, &workloadmeta.Container{}, event.Entity)
+		}
+	}
+
+	// The later changes are notified one by one
+	for _, containerID := range []string{"container0", "container1"} {
+		event, err := typeurl.MarshalAny(&events.ContainerUpdate{ID: containerID})
+		require.NoError(t, err)
+
+		require.NoError(t, c.handleEvent(context.Background(), &containerdevents.Envelope{
+			Namespace: "default",
+			Topic:     containerUpdateTopic,
+			Event:     event,
+		}))
+	}
+
+	batches = store.notifiedBatches()
+	require.Len(t, batches, 3)
+	for _, batch := range batches[1:] {
+		require.Len(t, batch, 1)
+		assert.IsType(t, &workloadmeta.Container{}, batch[0].Entity)
+	}
+}
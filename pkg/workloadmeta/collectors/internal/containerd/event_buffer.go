@@ -0,0 +1,183 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	containerdevents "github.com/containerd/containerd/events"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Policies applied when the event buffer is full
+const (
+	// The new event waits for room in the buffer, for a bounded time, and is
+	// dropped if there's still none
+	overflowPolicyBlock = "block"
+	// The oldest buffered event is dropped to make room for the new one
+	overflowPolicyDropOldest = "drop-oldest"
+)
+
+// eventBuffer sits between the containerd event subscription and the
+// goroutine handling the events. It keeps reading the subscription while the
+// events are handled, so that a slow workloadmeta store doesn't block it, and
+// applies its overflow policy when the buffer is full.
+type eventBuffer struct {
+	events       chan *containerdevents.Envelope
+	policy       string
+	blockTimeout time.Duration
+	telemetry    *eventBufferTelemetry
+}
+
+func newEventBuffer(size int, policy string, blockTimeout time.Duration, telemetry *eventBufferTelemetry) (*eventBuffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid containerd event buffer size %d, it must be positive", size)
+	}
+
+	switch policy {
+	case overflowPolicyBlock, overflowPolicyDropOldest:
+	default:
+		return nil, fmt.Errorf("unknown containerd event buffer overflow policy %q, expected %q or %q", policy, overflowPolicyBlock, overflowPolicyDropOldest)
+	}
+
+	return &eventBuffer{
+		events:       make(chan *containerdevents.Envelope, size),
+		policy:       policy,
+		blockTimeout: blockTimeout,
+		telemetry:    telemetry,
+	}, nil
+}
+
+// newEventBufferFromConfig creates the event buffer configured with the
+// containerd_event_buffer_* options.
+func newEventBufferFromConfig() (*eventBuffer, error) {
+	return newEventBuffer(
+		config.Datadog.GetInt("containerd_event_buffer_size"),
+		config.Datadog.GetString("containerd_event_buffer_overflow_policy"),
+		time.Duration(config.Datadog.GetInt("containerd_event_buffer_block_timeout"))*time.Second,
+		getDefaultEventBufferTelemetry(),
+	)
+}
+
+// run moves the events received from the subscription to the buffer, until
+// the context is cancelled or the subscription is closed. It must be the only
+// goroutine sending to the buffer.
+func (b *eventBuffer) run(ctx context.Context, subscription <-chan *containerdevents.Envelope) {
+	for {
+		select {
+		case ev, ok := <-subscription:
+			if !ok {
+				return
+			}
+			b.push(ctx, ev)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *eventBuffer) push(ctx context.Context, ev *containerdevents.Envelope) {
+	defer func() {
+		b.telemetry.setBufferedEvents(len(b.events))
+	}()
+
+	select {
+	case b.events <- ev:
+		return
+	default:
+	}
+
+	switch b.policy {
+	case overflowPolicyDropOldest:
+		select {
+		case oldest := <-b.events:
+			b.drop(oldest)
+		default:
+			// The consumer made room in the meantime
+		}
+
+		// This is the only sender, so there's room for the event now
+		b.events <- ev
+	case overflowPolicyBlock:
+		timer := time.NewTimer(b.blockTimeout)
+		defer timer.Stop()
+
+		select {
+		case b.events <- ev:
+		case <-timer.C:
+			b.drop(ev)
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (b *eventBuffer) drop(ev *containerdevents.Envelope) {
+	log.Warnf("containerd event buffer is full, dropping event %s in namespace %s (policy %s)", ev.Topic, ev.Namespace, b.policy)
+	b.telemetry.incDroppedEvents(b.policy)
+}
+
+// eventBufferTelemetry holds the metrics about the event buffer. Its methods
+// do nothing on a nil eventBufferTelemetry.
+type eventBufferTelemetry struct {
+	droppedEvents  telemetry.Counter
+	bufferedEvents telemetry.Gauge
+}
+
+func newEventBufferTelemetry(provider telemetryProvider) *eventBufferTelemetry {
+	return &eventBufferTelemetry{
+		droppedEvents: provider.NewCounter(
+			telemetrySubsystem,
+			"containerd_dropped_events",
+			[]string{"policy"},
+			"Number of containerd events dropped because the event buffer was full, by overflow policy.",
+		),
+		bufferedEvents: provider.NewGauge(
+			telemetrySubsystem,
+			"containerd_buffered_events",
+			[]string{},
+			"Number of containerd events waiting to be handled.",
+		),
+	}
+}
+
+var (
+	defaultEventBufferTelemetry     *eventBufferTelemetry
+	defaultEventBufferTelemetryOnce sync.Once
+)
+
+// getDefaultEventBufferTelemetry returns the metrics registered with
+// pkg/telemetry, created only once like the SBOM metrics.
+func getDefaultEventBufferTelemetry() *eventBufferTelemetry {
+	defaultEventBufferTelemetryOnce.Do(func() {
+		defaultEventBufferTelemetry = newEventBufferTelemetry(pkgTelemetryProvider{})
+	})
+	return defaultEventBufferTelemetry
+}
+
+func (t *eventBufferTelemetry) incDroppedEvents(policy string) {
+	if t == nil {
+		return
+	}
+
+	t.droppedEvents.Inc(policy)
+}
+
+func (t *eventBufferTelemetry) setBufferedEvents(buffered int) {
+	if t == nil {
+		return
+	}
+
+	t.bufferedEvents.Set(float64(buffered))
+}
@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	containerdevents "github.com/containerd/containerd/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func newTestEvents(n int) []*containerdevents.Envelope {
+	var events []*containerdevents.Envelope
+	for i := 0; i < n; i++ {
+		events = append(events, &containerdevents.Envelope{
+			Namespace: "default",
+			Topic:     fmt.Sprintf("/test/%d", i),
+		})
+	}
+	return events
+}
+
+func TestNewEventBuffer(t *testing.T) {
+	_, err := newEventBuffer(10, overflowPolicyBlock, time.Second, nil)
+	assert.NoError(t, err)
+
+	_, err = newEventBuffer(10, overflowPolicyDropOldest, time.Second, nil)
+	assert.NoError(t, err)
+
+	_, err = newEventBuffer(0, overflowPolicyBlock, time.Second, nil)
+	assert.Error(t, err)
+
+	_, err = newEventBuffer(10, "drop-newest", time.Second, nil)
+	assert.Error(t, err)
+}
+
+func TestEventBufferDropOldest(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		buffer, err := newEventBuffer(2, overflowPolicyDropOldest, time.Hour, newEventBufferTelemetry(tel))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Nothing is consumed while the events are received, they are never
+		// blocked
+		subscription := make(chan *containerdevents.Envelope)
+		go buffer.run(ctx, subscription)
+
+		events := newTestEvents(5)
+		for _, ev := range events {
+			select {
+			case subscription <- ev:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "timed out sending to the subscription")
+			}
+		}
+
+		// The last event may not have been pushed yet
+		require.Eventually(t, func() bool {
+			return mock.Value(telemetrySubsystem, "containerd_dropped_events", overflowPolicyDropOldest) == 3
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// Only the newest events are kept
+		assert.Equal(t, events[3], <-buffer.events)
+		assert.Equal(t, events[4], <-buffer.events)
+		assert.Equal(t, 2.0, mock.Value(telemetrySubsystem, "containerd_buffered_events"))
+	})
+}
+
+func TestEventBufferBlock(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		buffer, err := newEventBuffer(1, overflowPolicyBlock, 50*time.Millisecond, newEventBufferTelemetry(tel))
+		require.NoError(t, err)
+
+		events := newTestEvents(4)
+
+		// A consumer slower than the block timeout makes the new event drop
+		buffer.push(context.Background(), events[0])
+		start := time.Now()
+		buffer.push(context.Background(), events[1])
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_dropped_events", overflowPolicyBlock))
+
+		// A consumer faster than the block timeout doesn't lose any event
+		consumed := make(chan *containerdevents.Envelope, len(events))
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			for i := 0; i < 2; i++ {
+				consumed <- <-buffer.events
+			}
+		}()
+		buffer.push(context.Background(), events[2])
+		assert.Equal(t, events[0], <-consumed)
+		assert.Equal(t, events[2], <-consumed)
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_dropped_events", overflowPolicyBlock))
+
+		// Cancelling stops waiting, without counting the event as dropped
+		buffer.push(context.Background(), events[3])
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		buffer.push(ctx, events[0])
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_dropped_events", overflowPolicyBlock))
+	})
+}
+
+func TestEventBufferRunStops(t *testing.T) {
+	buffer, err := newEventBuffer(1, overflowPolicyBlock, time.Hour, nil)
+	require.NoError(t, err)
+
+	// Stops when the subscription is closed
+	subscription := make(chan *containerdevents.Envelope)
+	done := make(chan struct{})
+	go func() {
+		buffer.run(context.Background(), subscription)
+		close(done)
+	}()
+	close(subscription)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "run didn't stop when the subscription was closed")
+	}
+
+	// Stops when the context is cancelled, even while blocked on a full
+	// buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription = make(chan *containerdevents.Envelope, 2)
+	for _, ev := range newTestEvents(2) {
+		subscription <- ev
+	}
+	done = make(chan struct{})
+	go func() {
+		buffer.run(ctx, subscription)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return len(subscription) == 0 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "run didn't stop when the context was cancelled")
+	}
+}
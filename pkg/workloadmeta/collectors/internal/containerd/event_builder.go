@@ -72,7 +72,7 @@ func createSetEvent(container containerd.Container, namespace string, containerd
 
 	entity, err := buildWorkloadMetaContainer(namespace, container, containerdClient)
 	if err != nil {
-		return workloadmeta.CollectorEvent{}, fmt.Errorf("could not fetch info for container %s: %s", container.ID(), err)
+		return workloadmeta.CollectorEvent{}, fmt.Errorf("could not fetch info for container %s: %w", container.ID(), err)
 	}
 
 	// The namespace cannot be obtained from a container instance. That's why we
@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/containerd/containerd/errdefs"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Errors of the collector failing to handle an event, to be checked with
+// errors.Is.
+var (
+	// ErrImageNotFound is returned when an image, or its manifest or config,
+	// is not found in containerd.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrSpecParse is returned when the OCI spec of a container can't be
+	// read or parsed.
+	ErrSpecParse = errors.New("cannot parse container spec")
+	// ErrStoreUnavailable is returned when containerd, and so the stores of
+	// its containers and images, can't be reached.
+	ErrStoreUnavailable = errors.New("containerd store unavailable")
+)
+
+// Reasons of the failures, used as a tag of the telemetry and in the logs
+const (
+	errorReasonImageNotFound    = "image_not_found"
+	errorReasonSpecParse        = "spec_parse"
+	errorReasonStoreUnavailable = "store_unavailable"
+	errorReasonUnknown          = "unknown"
+)
+
+// classifiedError is an error that is one of the typed errors of the
+// collector, while keeping the error that caused it.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// classifyContainerdError classifies an error returned by containerd. It's an
+// ErrStoreUnavailable when containerd can't be reached, and otherwise the
+// given kind of error, unless kind is nil.
+func classifyContainerdError(err error, kind error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errdefs.IsUnavailable(err) || errdefs.IsDeadlineExceeded(err) || errors.Is(err, context.DeadlineExceeded) {
+		return &classifiedError{kind: ErrStoreUnavailable, err: err}
+	}
+
+	if kind == nil {
+		return err
+	}
+
+	return &classifiedError{kind: kind, err: err}
+}
+
+// classifyImageError classifies an error getting an image or its content from
+// containerd, which is an ErrImageNotFound when it doesn't exist.
+func classifyImageError(err error) error {
+	if errdefs.IsNotFound(err) {
+		return &classifiedError{kind: ErrImageNotFound, err: err}
+	}
+
+	return classifyContainerdError(err, nil)
+}
+
+// errorReason returns the reason of a failure to handle an event.
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrImageNotFound):
+		return errorReasonImageNotFound
+	case errors.Is(err, ErrSpecParse):
+		return errorReasonSpecParse
+	case errors.Is(err, ErrStoreUnavailable):
+		return errorReasonStoreUnavailable
+	default:
+		return errorReasonUnknown
+	}
+}
+
+// reportEventError logs and counts a failure to handle an event, described
+// by what, with its reason.
+func (c *collector) reportEventError(what string, err error) {
+	reason := errorReason(err)
+	c.eventErrorTelemetry.incErrors(reason)
+	log.Warnf("error handling %s (reason: %s): %s", what, reason, err)
+}
+
+// eventErrorTelemetry holds the metrics about the events that couldn't be
+// handled. Its methods do nothing on a nil eventErrorTelemetry.
+type eventErrorTelemetry struct {
+	errors telemetry.Counter
+}
+
+func newEventErrorTelemetry(provider telemetryProvider) *eventErrorTelemetry {
+	return &eventErrorTelemetry{
+		errors: provider.NewCounter(
+			telemetrySubsystem,
+			"containerd_event_errors",
+			[]string{"reason"},
+			"Number of containerd events that couldn't be handled, by reason.",
+		),
+	}
+}
+
+var (
+	defaultEventErrorTelemetry     *eventErrorTelemetry
+	defaultEventErrorTelemetryOnce sync.Once
+)
+
+// getDefaultEventErrorTelemetry returns the metrics registered with
+// pkg/telemetry, created only once like the other metrics of the collector.
+func getDefaultEventErrorTelemetry() *eventErrorTelemetry {
+	defaultEventErrorTelemetryOnce.Do(func() {
+		defaultEventErrorTelemetry = newEventErrorTelemetry(pkgTelemetryProvider{})
+	})
+	return defaultEventErrorTelemetry
+}
+
+func (t *eventErrorTelemetry) incErrors(reason string) {
+	if t == nil {
+		return
+	}
+
+	t.errors.Inc(reason)
+}
@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/containerd/containerd"
+	containerdcontainers "github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/oci"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		err            error
+		expectedReason string
+	}{
+		{
+			err:            classifyImageError(errdefs.ErrNotFound),
+			expectedReason: "image_not_found",
+		},
+		{
+			err:            fmt.Errorf("error getting image: %w", classifyImageError(errdefs.ErrNotFound)),
+			expectedReason: "image_not_found",
+		},
+		{
+			err:            classifyContainerdError(errors.New("invalid spec"), ErrSpecParse),
+			expectedReason: "spec_parse",
+		},
+		{
+			err:            classifyContainerdError(errdefs.ErrUnavailable, ErrSpecParse),
+			expectedReason: "store_unavailable",
+		},
+		{
+			err:            classifyImageError(context.DeadlineExceeded),
+			expectedReason: "store_unavailable",
+		},
+		{
+			err:            classifyContainerdError(errors.New("unexpected"), nil),
+			expectedReason: "unknown",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			assert.Equal(t, test.expectedReason, errorReason(test.err))
+		})
+	}
+}
+
+func TestEventErrorClassification(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	// An image whose config is missing from the content store
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageConfig,
+			Digest:    digest.FromString("missing config"),
+			Size:      14,
+		},
+	}
+	manifest.SchemaVersion = 2
+	imageWithoutConfig := &fakeImage{
+		name:   "datadog/agent:7",
+		store:  contentStore,
+		target: writeBlob(t, contentStore, ocispec.MediaTypeImageManifest, manifest),
+	}
+
+	container := &mockedContainer{
+		mockID: func() string { return "container1" },
+	}
+
+	tests := []struct {
+		name          string
+		client        *fake.MockedContainerdClient
+		handle        func(c *collector) error
+		expectedError error
+	}{
+		{
+			name: "image not found",
+			client: &fake.MockedContainerdClient{
+				MockImage: func(namespace string, name string) (containerd.Image, error) {
+					return nil, fmt.Errorf("image %q: %w", name, errdefs.ErrNotFound)
+				},
+			},
+			handle: func(c *collector) error {
+				return c.handleImageCreateOrUpdate(context.Background(), "default", "datadog/agent:7", nil)
+			},
+			expectedError: ErrImageNotFound,
+		},
+		{
+			name: "image config not found",
+			client: &fake.MockedContainerdClient{
+				MockImage: func(namespace string, name string) (containerd.Image, error) {
+					return imageWithoutConfig, nil
+				},
+			},
+			handle: func(c *collector) error {
+				return c.handleImageCreateOrUpdate(context.Background(), "default", "datadog/agent:7", nil)
+			},
+			expectedError: ErrImageNotFound,
+		},
+		{
+			name: "image store unavailable",
+			client: &fake.MockedContainerdClient{
+				MockImage: func(namespace string, name string) (containerd.Image, error) {
+					return nil, fmt.Errorf("connection refused: %w", errdefs.ErrUnavailable)
+				},
+			},
+			handle: func(c *collector) error {
+				return c.handleImageCreateOrUpdate(context.Background(), "default", "datadog/agent:7", nil)
+			},
+			expectedError: ErrStoreUnavailable,
+		},
+		{
+			name: "spec parse error",
+			client: &fake.MockedContainerdClient{
+				MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+					return containerdcontainers.Container{}, nil
+				},
+				MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+					return nil, errors.New("json: cannot unmarshal string into Go value of type specs.Spec")
+				},
+			},
+			handle: func(c *collector) error {
+				_, err := createSetEvent(container, "default", c.containerdClient)
+				return err
+			},
+			expectedError: ErrSpecParse,
+		},
+		{
+			name: "container store unavailable",
+			client: &fake.MockedContainerdClient{
+				MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+					return containerdcontainers.Container{}, errdefs.ErrUnavailable
+				},
+			},
+			handle: func(c *collector) error {
+				_, err := createSetEvent(container, "default", c.containerdClient)
+				return err
+			},
+			expectedError: ErrStoreUnavailable,
+		},
+		{
+			name: "task store unavailable",
+			client: &fake.MockedContainerdClient{
+				MockInfo: func(namespace string, ctn containerd.Container) (containerdcontainers.Container, error) {
+					return containerdcontainers.Container{}, nil
+				},
+				MockSpec: func(namespace string, ctn containerd.Container) (*oci.Spec, error) {
+					return &oci.Spec{Process: &specs.Process{}}, nil
+				},
+				MockStatus: func(namespace string, ctn containerd.Container) (containerd.ProcessStatus, error) {
+					return containerd.Unknown, context.DeadlineExceeded
+				},
+			},
+			handle: func(c *collector) error {
+				_, err := createSetEvent(container, "default", c.containerdClient)
+				return err
+			},
+			expectedError: ErrStoreUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &collector{
+				store:            newFakeImageStore(),
+				containerdClient: test.client,
+				knownImages:      newKnownImages(),
+				repoTags:         make(map[string][]string),
+				scannedImages:    newScannedImages(),
+			}
+
+			err := test.handle(c)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, test.expectedError)
+
+			for _, otherError := range []error{ErrImageNotFound, ErrSpecParse, ErrStoreUnavailable} {
+				if otherError != test.expectedError {
+					assert.NotErrorIs(t, err, otherError)
+				}
+			}
+		})
+	}
+}
+
+func TestReportEventError(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		c := collector{
+			eventErrorTelemetry: newEventErrorTelemetry(tel),
+		}
+
+		c.reportEventError("containerd event /containers/create in namespace default", classifyContainerdError(errors.New("invalid spec"), ErrSpecParse))
+		c.reportEventError("containerd event /containers/update in namespace default", classifyContainerdError(errors.New("invalid spec"), ErrSpecParse))
+		c.reportEventError("containerd event /images/create in namespace default", errors.New("unexpected"))
+
+		assert.Equal(t, 2.0, mock.Value(telemetrySubsystem, "containerd_event_errors", "spec_parse"))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_event_errors", "unknown"))
+		assert.Equal(t, 0.0, mock.Value(telemetrySubsystem, "containerd_event_errors", "image_not_found"))
+	})
+}
@@ -11,7 +11,9 @@ package containerd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -26,7 +28,9 @@ import (
 	containerdevents "github.com/containerd/containerd/events"
 	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/platforms"
 	"github.com/gogo/protobuf/proto"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -36,58 +40,74 @@ const imageTopicPrefix = "/images/"
 // event arrives it'll contain a name, but we won't be able to access the ID
 // because the image is already gone, that's why we need to keep the IDs =>
 // names relationships.
+//
+// Names are scoped by namespace, because the same name can refer to
+// different images in different namespaces, and the same image can be
+// referenced in several namespaces.
 type knownImages struct {
 	// Needed because this is accessed by the goroutine handling events and also the one that extracts SBOMs
 	mut sync.Mutex
 
 	// Store IDs and names in both directions for efficient access
-	idsByName map[string]string              // map name => ID
-	namesByID map[string]map[string]struct{} // map ID => set of names
+	idsByName map[namespacedName]string              // map name => ID
+	namesByID map[string]map[namespacedName]struct{} // map ID => set of names
+}
+
+// namespacedName is the name of an image in a containerd namespace.
+type namespacedName struct {
+	namespace string
+	name      string
 }
 
 func newKnownImages() *knownImages {
 	return &knownImages{
-		idsByName: make(map[string]string),
-		namesByID: make(map[string]map[string]struct{}),
+		idsByName: make(map[namespacedName]string),
+		namesByID: make(map[string]map[namespacedName]struct{}),
 	}
 }
 
-func (images *knownImages) addAssociation(imageName string, imageID string) {
+func (images *knownImages) addAssociation(namespace string, imageName string, imageID string) {
 	images.mut.Lock()
 	defer images.mut.Unlock()
 
-	images.idsByName[imageName] = imageID
+	name := namespacedName{namespace: namespace, name: imageName}
+
+	images.idsByName[name] = imageID
 
 	if images.namesByID[imageID] == nil {
-		images.namesByID[imageID] = make(map[string]struct{})
+		images.namesByID[imageID] = make(map[namespacedName]struct{})
 	}
-	images.namesByID[imageID][imageName] = struct{}{}
+	images.namesByID[imageID][name] = struct{}{}
 }
 
-func (images *knownImages) deleteAssociation(imageName string, imageID string) {
+func (images *knownImages) deleteAssociation(namespace string, imageName string, imageID string) {
 	images.mut.Lock()
 	defer images.mut.Unlock()
 
-	delete(images.idsByName, imageName)
+	name := namespacedName{namespace: namespace, name: imageName}
+
+	delete(images.idsByName, name)
 
 	if images.namesByID[imageID] == nil {
 		return
 	}
 
-	delete(images.namesByID[imageID], imageName)
+	delete(images.namesByID[imageID], name)
 	if len(images.namesByID[imageID]) == 0 {
 		delete(images.namesByID, imageID)
 	}
 }
 
-func (images *knownImages) getImageID(imageName string) (string, bool) {
+func (images *knownImages) getImageID(namespace string, imageName string) (string, bool) {
 	images.mut.Lock()
 	defer images.mut.Unlock()
 
-	id, found := images.idsByName[imageName]
+	id, found := images.idsByName[namespacedName{namespace: namespace, name: imageName}]
 	return id, found
 }
 
+// isReferenced returns whether the image is referenced by any name in any
+// namespace.
 func (images *knownImages) isReferenced(imageID string) bool {
 	images.mut.Lock()
 	defer images.mut.Unlock()
@@ -95,6 +115,97 @@ func (images *knownImages) isReferenced(imageID string) bool {
 	return len(images.namesByID[imageID]) > 0
 }
 
+// isReferencedWithName returns whether the image is referenced by the given
+// name in any namespace.
+func (images *knownImages) isReferencedWithName(imageID string, imageName string) bool {
+	images.mut.Lock()
+	defer images.mut.Unlock()
+
+	for name := range images.namesByID[imageID] {
+		if name.name == imageName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getReference returns one of the names referencing the image, preferring
+// the given one. The other names are sorted to return the same one every
+// time.
+func (images *knownImages) getReference(imageID string, preferredName string) (namespacedName, bool) {
+	images.mut.Lock()
+	defer images.mut.Unlock()
+
+	names := make([]namespacedName, 0, len(images.namesByID[imageID]))
+	for name := range images.namesByID[imageID] {
+		if name.name == preferredName {
+			return name, true
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return namespacedName{}, false
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].namespace != names[j].namespace {
+			return names[i].namespace < names[j].namespace
+		}
+		return names[i].name < names[j].name
+	})
+
+	return names[0], true
+}
+
+// scannedImages keeps the IDs (config digests) of the images whose SBOM has
+// been extracted or is being extracted, so that each image is scanned only
+// once even when it's referenced with several names.
+type scannedImages struct {
+	// Needed because this is accessed by the goroutine handling events and
+	// also by the ones that extract SBOMs
+	mut sync.Mutex
+
+	ids map[string]struct{}
+}
+
+func newScannedImages() *scannedImages {
+	return &scannedImages{
+		ids: make(map[string]struct{}),
+	}
+}
+
+// markAsScanned marks the image as scanned and returns whether it was not
+// scanned before.
+func (images *scannedImages) markAsScanned(imageID string) bool {
+	images.mut.Lock()
+	defer images.mut.Unlock()
+
+	if _, found := images.ids[imageID]; found {
+		return false
+	}
+
+	images.ids[imageID] = struct{}{}
+	return true
+}
+
+func (images *scannedImages) isScanned(imageID string) bool {
+	images.mut.Lock()
+	defer images.mut.Unlock()
+
+	_, found := images.ids[imageID]
+	return found
+}
+
+// forget removes the image, so that it's scanned again the next time it's seen.
+func (images *scannedImages) forget(imageID string) {
+	images.mut.Lock()
+	defer images.mut.Unlock()
+
+	delete(images.ids, imageID)
+}
+
 func isImageTopic(topic string) bool {
 	return strings.HasPrefix(topic, imageTopicPrefix)
 }
@@ -107,7 +218,9 @@ func (c *collector) handleImageEvent(ctx context.Context, containerdEvent *conta
 			return fmt.Errorf("error unmarshaling containerd event: %w", err)
 		}
 
-		return c.handleImageCreateOrUpdate(ctx, containerdEvent.Namespace, event.Name, nil)
+		err := c.handleImageCreateOrUpdate(ctx, containerdEvent.Namespace, event.Name, nil)
+		c.refreshSignedImage(ctx, event.Name)
+		return err
 
 	case imageUpdateTopic:
 		event := &events.ImageUpdate{}
@@ -115,7 +228,9 @@ func (c *collector) handleImageEvent(ctx context.Context, containerdEvent *conta
 			return fmt.Errorf("error unmarshaling containerd event: %w", err)
 		}
 
-		return c.handleImageCreateOrUpdate(ctx, containerdEvent.Namespace, event.Name, nil)
+		err := c.handleImageCreateOrUpdate(ctx, containerdEvent.Namespace, event.Name, nil)
+		c.refreshSignedImage(ctx, event.Name)
+		return err
 
 	case imageDeletionTopic:
 		event := &events.ImageDelete{}
@@ -123,17 +238,34 @@ func (c *collector) handleImageEvent(ctx context.Context, containerdEvent *conta
 			return fmt.Errorf("error unmarshaling containerd event: %w", err)
 		}
 
-		imageID, found := c.knownImages.getImageID(event.Name)
+		defer c.refreshSignedImage(ctx, event.Name)
+
+		imageID, found := c.knownImages.getImageID(containerdEvent.Namespace, event.Name)
 		if !found {
 			return nil
 		}
 
-		c.knownImages.deleteAssociation(event.Name, imageID)
+		c.knownImages.deleteAssociation(containerdEvent.Namespace, event.Name, imageID)
 
 		if c.knownImages.isReferenced(imageID) {
-			// Image is still referenced by a different name. Don't delete the
-			// image, but update its repo tags.
-			return c.deleteRepoTagOfImage(ctx, containerdEvent.Namespace, imageID, event.Name)
+			// Image is still referenced by a different name or in a
+			// different namespace. Don't delete the image, but update its
+			// repo tags.
+			return c.deleteRepoTagOfImage(ctx, imageID, event.Name)
+		}
+
+		// The image is not referenced by any name anymore: forget everything
+		// about it, so that it's collected from scratch if it's pulled again.
+		delete(c.repoTags, imageID)
+		c.scannedImages.forget(imageID)
+		if c.sbomRescanner != nil {
+			c.sbomRescanner.forget(imageID)
+		}
+		if c.sbomScanRetrier != nil {
+			c.sbomScanRetrier.forget(imageID)
+		}
+		if c.sbomCache != nil {
+			c.sbomCache.delete(imageID)
 		}
 
 		c.store.Notify([]workloadmeta.CollectorEvent{
@@ -158,7 +290,7 @@ func (c *collector) handleImageEvent(ctx context.Context, containerdEvent *conta
 func (c *collector) handleImageCreateOrUpdate(ctx context.Context, namespace string, imageName string, bom *cyclonedx.BOM) error {
 	img, err := c.containerdClient.Image(namespace, imageName)
 	if err != nil {
-		return fmt.Errorf("error getting image: %w", err)
+		return fmt.Errorf("error getting image: %w", classifyImageError(err))
 	}
 
 	return c.notifyEventForImage(ctx, namespace, img, bom)
@@ -169,7 +301,7 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 
 	manifest, err := images.Manifest(ctxWithNamespace, img.ContentStore(), img.Target(), img.Platform())
 	if err != nil {
-		return fmt.Errorf("error getting image manifest: %w", err)
+		return fmt.Errorf("error getting image manifest: %w", classifyImageError(err))
 	}
 
 	layers, err := getLayersWithHistory(ctxWithNamespace, img.ContentStore(), manifest)
@@ -180,9 +312,17 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 		// returning an error, collect the image without this information.
 	}
 
-	platforms, err := images.Platforms(ctxWithNamespace, img.ContentStore(), manifest.Config)
+	platform, err := getImagePlatform(ctxWithNamespace, img, manifest)
 	if err != nil {
-		return fmt.Errorf("error getting image platforms: %w", err)
+		return fmt.Errorf("error getting image platform: %w", classifyImageError(err))
+	}
+
+	runtimeConfig, err := getImageRuntimeConfig(ctxWithNamespace, img.ContentStore(), manifest)
+	if err != nil {
+		log.Warnf("error while getting the env and entrypoint of image %s: %s", img.Name(), err)
+
+		// Like the layers, these are not essential, collect the image
+		// without them.
 	}
 
 	imageName := img.Name()
@@ -213,7 +353,14 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 	// of the name that includes a digest. This is just to show names that are
 	// more user-friendly (the digests are already present in other attributes
 	// like ID, and repo digest).
-	existingImg, err := c.store.GetImage(imageID)
+	//
+	// Each name of the image has its own creation time. The image was pulled
+	// when its first name was created.
+	pulledAt := img.Metadata().CreatedAt
+
+	var sbomStatus workloadmeta.SBOMStatus
+
+	existingImg, err := c.getImage(imageID)
 	if err == nil {
 		if strings.Contains(imageName, "sha256:") && !strings.Contains(existingImg.Name, "sha256:") {
 			imageName = existingImg.Name
@@ -223,6 +370,12 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 		if existingBOM == nil && existingImg.CycloneDXBOM != nil {
 			existingBOM = existingImg.CycloneDXBOM
 		}
+
+		if !existingImg.PulledAt.IsZero() && (pulledAt.IsZero() || existingImg.PulledAt.Before(pulledAt)) {
+			pulledAt = existingImg.PulledAt
+		}
+
+		sbomStatus = existingImg.SBOMStatus
 	}
 
 	var repoDigests []string
@@ -246,16 +399,15 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 
 	var totalSizeBytes int64 = 0
 	for _, layer := range manifest.Layers {
-		totalSizeBytes += layer.Size
-	}
+		// A partial sum would be misleading, so the size is left unset when
+		// the size of a layer is unknown
+		if layer.Size <= 0 {
+			log.Debugf("Size of layer %s of image %s is unavailable, not reporting the size of the image", layer.Digest, imageName)
+			totalSizeBytes = 0
+			break
+		}
 
-	var os, osVersion, architecture, variant string
-	// If there are multiple platforms, return the info about the first one
-	if len(platforms) >= 1 {
-		os = platforms[0].OS
-		osVersion = platforms[0].OSVersion
-		architecture = platforms[0].Architecture
-		variant = platforms[0].Variant
+		totalSizeBytes += layer.Size
 	}
 
 	workloadmetaImg := workloadmeta.ContainerImageMetadata{
@@ -274,15 +426,35 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 		RepoDigests:  repoDigests,
 		MediaType:    manifest.MediaType,
 		SizeBytes:    totalSizeBytes,
-		OS:           os,
-		OSVersion:    osVersion,
-		Architecture: architecture,
-		Variant:      variant,
+		OS:           platform.OS,
+		OSVersion:    platform.OSVersion,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
 		Layers:       layers,
+		PulledAt:     pulledAt,
+		EnvVars:      runtimeConfig.envVars,
+		Entrypoint:   runtimeConfig.entrypoint,
+		Cmd:          runtimeConfig.cmd,
 		CycloneDXBOM: existingBOM,
 	}
 
-	c.store.Notify([]workloadmeta.CollectorEvent{
+	if c.collectImageSignatures {
+		workloadmetaImg.Signatures = c.getImageSignatures(ctxWithNamespace, namespace, img.Name(), img.Target().Digest)
+	}
+
+	shouldScan := existingBOM == nil && c.imagesToScan != nil && !c.scannedImages.isScanned(imageID) && c.isImageUsed(imageID) && c.shouldScanImage(&workloadmetaImg)
+
+	if c.sbomStatusEvents {
+		switch {
+		case shouldScan:
+			sbomStatus = workloadmeta.SBOMStatusPending
+		case existingBOM != nil:
+			sbomStatus = workloadmeta.SBOMStatusSuccess
+		}
+		workloadmetaImg.SBOMStatus = sbomStatus
+	}
+
+	c.notify([]workloadmeta.CollectorEvent{
 		{
 			Type:   workloadmeta.EventTypeSet,
 			Source: workloadmeta.SourceRuntime,
@@ -290,54 +462,89 @@ func (c *collector) notifyEventForImage(ctx context.Context, namespace string, i
 		},
 	})
 
-	if existingBOM == nil && sbomCollectionIsEnabled() {
+	if shouldScan {
 		// Notify image scanner
-		c.imagesToScan <- namespacedImage{
+		imageToScan := namespacedImage{
 			namespace: namespace,
 			image:     img,
 			imageID:   imageID,
 		}
+		c.afterNotify(func() { c.enqueueImageToScan(imageToScan) })
 	}
 
 	return c.updateKnownImages(ctx, namespace, imageName, imageID)
 }
 
+// shouldScanImage returns whether the SBOM of the image should be extracted,
+// according to the repository and label filters of SBOM collection.
+func (c *collector) shouldScanImage(img *workloadmeta.ContainerImageMetadata) bool {
+	names := append([]string{img.Name}, img.RepoTags...)
+	if c.sbomFilter.isIncluded(names, img.Labels) {
+		return true
+	}
+
+	log.Debugf("Image: %s/%s (id %s) excluded from SBOM collection", img.Namespace, img.Name, img.ID)
+	return false
+}
+
+// enqueueImageToScan sends the image to the SBOM scan workers without blocking
+// the goroutine handling the containerd events. When the queue is full, the
+// image is dropped, see dropScan.
+func (c *collector) enqueueImageToScan(imageToScan namespacedImage) {
+	select {
+	case c.imagesToScan <- imageToScan:
+		c.sbomTelemetry.setQueuedScans(len(c.imagesToScan))
+	default:
+		log.Warnf("SBOM scan queue is full, skipping scan of image: %s/%s (id %s)", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+		c.dropScan(imageToScan)
+	}
+}
+
 // Updates the map with the image name => image ID relationships and also the repo tags
 func (c *collector) updateKnownImages(ctx context.Context, namespace string, imageName string, newImageID string) error {
-	oldImageID, found := c.knownImages.getImageID(imageName)
-	c.knownImages.addAssociation(imageName, newImageID)
+	oldImageID, found := c.knownImages.getImageID(namespace, imageName)
+	c.knownImages.addAssociation(namespace, imageName, newImageID)
 
 	// If the image name is already pointing to an ID, we need to delete the name from
 	// the repo tags of the image with that ID.
 	if found && newImageID != oldImageID {
-		c.knownImages.deleteAssociation(imageName, oldImageID)
-		return c.deleteRepoTagOfImage(ctx, namespace, oldImageID, imageName)
+		c.knownImages.deleteAssociation(namespace, imageName, oldImageID)
+		return c.deleteRepoTagOfImage(ctx, oldImageID, imageName)
 	}
 
 	return nil
 }
 
-func (c *collector) deleteRepoTagOfImage(ctx context.Context, namespace string, imageID string, repoTagToDelete string) error {
-	repoTagDeleted := false
-
-	for i, repoTag := range c.repoTags[imageID] {
-		if repoTag == repoTagToDelete {
-			c.repoTags[imageID] = append(c.repoTags[imageID][:i], c.repoTags[imageID][i+1:]...)
-			repoTagDeleted = true
-			break
+// deleteRepoTagOfImage deletes a name that doesn't reference the image
+// anymore from its repo tags, unless the name still references it in a
+// different namespace, and notifies the updated image.
+func (c *collector) deleteRepoTagOfImage(ctx context.Context, imageID string, repoTagToDelete string) error {
+	if !c.knownImages.isReferencedWithName(imageID, repoTagToDelete) {
+		for i, repoTag := range c.repoTags[imageID] {
+			if repoTag == repoTagToDelete {
+				c.repoTags[imageID] = append(c.repoTags[imageID][:i], c.repoTags[imageID][i+1:]...)
+				break
+			}
 		}
 	}
 
-	if repoTagDeleted && len(c.repoTags[imageID]) > 0 {
-		// We need to notify to workloadmeta that the image has changed.
-		// Updating workloadmeta entities directly is not thread-safe, that's
-		// why we generate an update event here instead.
-		if err := c.handleImageCreateOrUpdate(ctx, namespace, c.repoTags[imageID][len(c.repoTags[imageID])-1], nil); err != nil {
-			return err
-		}
+	// The remaining reference is looked up in the known images instead of
+	// the repo tags, because it might be in a different namespace than the
+	// deleted name.
+	preferredName := ""
+	if len(c.repoTags[imageID]) > 0 {
+		preferredName = c.repoTags[imageID][len(c.repoTags[imageID])-1]
 	}
 
-	return nil
+	reference, found := c.knownImages.getReference(imageID, preferredName)
+	if !found {
+		return nil
+	}
+
+	// We need to notify to workloadmeta that the image has changed.
+	// Updating workloadmeta entities directly is not thread-safe, that's
+	// why we generate an update event here instead.
+	return c.handleImageCreateOrUpdate(ctx, reference.namespace, reference.name, nil)
 }
 
 func getLayersWithHistory(ctx context.Context, store content.Store, manifest ocispec.Manifest) ([]workloadmeta.ContainerImageLayer, error) {
@@ -394,6 +601,112 @@ func getLayersWithHistory(ctx context.Context, store content.Store, manifest oci
 	return layers, nil
 }
 
+// getImagePlatform returns the platform of the image, read from its config.
+// For multi-platform images, the fields missing from the config, usually the
+// variant, are taken from the entry of the index that was resolved for the
+// platform of the image.
+func getImagePlatform(ctx context.Context, img containerd.Image, manifest ocispec.Manifest) (ocispec.Platform, error) {
+	blob, err := content.ReadBlob(ctx, img.ContentStore(), manifest.Config)
+	if err != nil {
+		return ocispec.Platform{}, fmt.Errorf("error while getting image config: %w", err)
+	}
+
+	var ocispecImage ocispec.Image
+	if err = json.Unmarshal(blob, &ocispecImage); err != nil {
+		return ocispec.Platform{}, fmt.Errorf("error while unmarshaling image config: %w", err)
+	}
+
+	platform := ocispec.Platform{
+		OS:           ocispecImage.OS,
+		OSVersion:    ocispecImage.OSVersion,
+		Architecture: ocispecImage.Architecture,
+		Variant:      ocispecImage.Variant,
+	}
+
+	indexPlatform, err := getResolvedIndexPlatform(ctx, img.ContentStore(), img.Target(), img.Platform(), manifest.Config.Digest)
+	if err != nil {
+		// Not fatal, the platform in the config is enough in most cases
+		log.Debugf("error while getting the platform of image %s in its index: %s", img.Name(), err)
+	}
+
+	if indexPlatform != nil {
+		if platform.OS == "" {
+			platform.OS = indexPlatform.OS
+		}
+		if platform.OSVersion == "" {
+			platform.OSVersion = indexPlatform.OSVersion
+		}
+		if platform.Architecture == "" {
+			platform.Architecture = indexPlatform.Architecture
+		}
+		if platform.Variant == "" {
+			platform.Variant = indexPlatform.Variant
+		}
+	}
+
+	return platforms.Normalize(platform), nil
+}
+
+// getResolvedIndexPlatform returns the platform of the entry of the index
+// (possibly nested) whose manifest references the given config, among the
+// entries matching the platform of the image. It returns nil when desc is not
+// an index, or when no entry with a platform matches.
+func getResolvedIndexPlatform(ctx context.Context, store content.Store, desc ocispec.Descriptor, matcher platforms.MatchComparer, configDigest digest.Digest) (*ocispec.Platform, error) {
+	if !images.IsIndexType(desc.MediaType) {
+		return nil, nil
+	}
+
+	blob, err := content.ReadBlob(ctx, store, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	var index ocispec.Index
+	if err = json.Unmarshal(blob, &index); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range index.Manifests {
+		if entry.Platform != nil && !matcher.Match(*entry.Platform) {
+			continue
+		}
+
+		if images.IsIndexType(entry.MediaType) {
+			platform, err := getResolvedIndexPlatform(ctx, store, entry, matcher, configDigest)
+			if err != nil || platform != nil {
+				return platform, err
+			}
+			continue
+		}
+
+		if entry.Platform == nil || !images.IsManifestType(entry.MediaType) {
+			continue
+		}
+
+		blob, err := content.ReadBlob(ctx, store, entry)
+		if err != nil {
+			// The content of the manifests of other platforms is usually not
+			// pulled
+			continue
+		}
+
+		var manifest ocispec.Manifest
+		if err = json.Unmarshal(blob, &manifest); err != nil {
+			return nil, err
+		}
+
+		if manifest.Config.Digest == configDigest {
+			return entry.Platform, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// errSBOMCollectionUnavailable is returned when SBOM collection is enabled
+// in the configuration, but the agent was built without trivy.
+var errSBOMCollectionUnavailable = errors.New("SBOM collection is enabled but is not available in this build of the agent")
+
 func sbomCollectionIsEnabled() bool {
 	return imageMetadataCollectionIsEnabled() && config.Datadog.GetBool("container_image_collection.sbom.enabled")
 }
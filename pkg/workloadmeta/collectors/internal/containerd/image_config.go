@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/process/procutil"
+)
+
+// redactedEnvValue replaces the values of the env vars with secret-like
+// names, like the process agent does in the command lines it scrubs.
+const redactedEnvValue = "********"
+
+// envVarsScrubber matches the env var names with the sensitive words of the
+// process agent.
+var envVarsScrubber = procutil.NewDefaultDataScrubber()
+
+// imageRuntimeConfig is the part of the config of an image that sets the
+// defaults of the containers created from it.
+type imageRuntimeConfig struct {
+	envVars    map[string]string
+	entrypoint []string
+	cmd        []string
+}
+
+// getImageRuntimeConfig returns the env vars, entrypoint and cmd of the
+// image, read from its config. They are nil when the image doesn't set them.
+func getImageRuntimeConfig(ctx context.Context, store content.Store, manifest ocispec.Manifest) (imageRuntimeConfig, error) {
+	blob, err := content.ReadBlob(ctx, store, manifest.Config)
+	if err != nil {
+		return imageRuntimeConfig{}, fmt.Errorf("error while getting image config: %w", err)
+	}
+
+	var ocispecImage ocispec.Image
+	if err = json.Unmarshal(blob, &ocispecImage); err != nil {
+		return imageRuntimeConfig{}, fmt.Errorf("error while unmarshaling image config: %w", err)
+	}
+
+	return imageRuntimeConfig{
+		envVars:    redactedEnvVars(ocispecImage.Config.Env),
+		entrypoint: ocispecImage.Config.Entrypoint,
+		cmd:        ocispecImage.Config.Cmd,
+	}, nil
+}
+
+// redactedEnvVars returns the env vars, given in the NAME=value form of the
+// image config, by name, with the values of the secret-like ones redacted.
+func redactedEnvVars(env []string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+
+	envVars := make(map[string]string, len(env))
+	for _, envVar := range env {
+		name, value, _ := strings.Cut(envVar, "=")
+		if name == "" {
+			continue
+		}
+
+		if isSensitiveEnvVar(name) {
+			value = redactedEnvValue
+		}
+
+		envVars[name] = value
+	}
+
+	return envVars
+}
+
+func isSensitiveEnvVar(name string) bool {
+	// The patterns match the arguments of command lines, so the name is
+	// matched as the key of a " NAME=value" argument.
+	arg := " " + name + "="
+
+	for _, pattern := range envVarsScrubber.SensitivePatterns {
+		if pattern.MatchString(arg) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containerd/containerd/content/local"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestRedactedEnvVars(t *testing.T) {
+	assert.Nil(t, redactedEnvVars(nil))
+
+	envVars := redactedEnvVars([]string{
+		"PATH=/usr/local/bin:/usr/bin",
+		"DB_PASSWORD=hunter2",
+		"Github_Auth_Token=ghp_123",
+		"DD_API_KEY=abcdef",
+		"AWS_SECRET_ACCESS_KEY=xyz",
+		"EMPTY=",
+		"NO_VALUE",
+		"URL=http://example.com/?a=b",
+		"=ignored",
+	})
+
+	assert.Equal(t, map[string]string{
+		"PATH":                  "/usr/local/bin:/usr/bin",
+		"DB_PASSWORD":           redactedEnvValue,
+		"Github_Auth_Token":     redactedEnvValue,
+		"DD_API_KEY":            redactedEnvValue,
+		"AWS_SECRET_ACCESS_KEY": redactedEnvValue,
+		"EMPTY":                 "",
+		"NO_VALUE":              "",
+		"URL":                   "http://example.com/?a=b",
+	}, envVars)
+}
+
+func TestImageRuntimeConfig(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	store := newFakeImageStore()
+	c := collector{
+		store:         store,
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+	}
+
+	image, _ := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{
+		Config: ocispec.ImageConfig{
+			Env:        []string{"PATH=/usr/bin", "DD_API_KEY=abcdef"},
+			Entrypoint: []string{"/bin/entrypoint.sh"},
+			Cmd:        []string{"agent", "run"},
+		},
+	})
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", image, nil))
+
+	reported := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, map[string]string{"PATH": "/usr/bin", "DD_API_KEY": redactedEnvValue}, reported.EnvVars)
+	assert.Equal(t, []string{"/bin/entrypoint.sh"}, reported.Entrypoint)
+	assert.Equal(t, []string{"agent", "run"}, reported.Cmd)
+
+	// Images don't need to have an entrypoint, or any of these
+	image, _ = newFakeImage(t, contentStore, "docker.io/datadog/cluster-agent:7", ocispec.Image{
+		Config: ocispec.ImageConfig{
+			Cmd: []string{"/bin/sh"},
+		},
+	})
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", image, nil))
+
+	reported = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Nil(t, reported.EnvVars)
+	assert.Nil(t, reported.Entrypoint)
+	assert.Equal(t, []string{"/bin/sh"}, reported.Cmd)
+
+	image, _ = newFakeImage(t, contentStore, "docker.io/library/scratch:latest", ocispec.Image{})
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", image, nil))
+
+	reported = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Nil(t, reported.EnvVars)
+	assert.Nil(t, reported.Entrypoint)
+	assert.Nil(t, reported.Cmd)
+}
@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const sbomCacheFileSuffix = ".json"
+
+// Characters that can't be used in the file names of the cache entries
+var invalidSBOMCacheFileChars = regexp.MustCompile("[^a-zA-Z0-9_-]")
+
+// sbomCacheEntry is the content of a file of the SBOM cache.
+type sbomCacheEntry struct {
+	ImageID   string         `json:"image_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	BOM       *cyclonedx.BOM `json:"bom"`
+}
+
+// sbomCache is an on-disk cache of the SBOMs extracted from images, keyed by
+// image ID (the digest of the image config), so that the images that were
+// already scanned are not scanned again when the agent restarts.
+//
+// Each entry is stored in its own file. Entries older than the TTL are
+// ignored and removed, and the oldest entries are removed when the total
+// size of the cache exceeds maxDiskSize.
+type sbomCache struct {
+	// Needed because the cache is accessed by all the scan workers
+	mut sync.Mutex
+
+	dir         string
+	ttl         time.Duration
+	maxDiskSize int64
+
+	// now returns the current time. It can be overridden in tests.
+	now func() time.Time
+}
+
+// newSBOMCache returns a cache storing its entries in dir. A ttl or a
+// maxDiskSize of zero means no limit.
+func newSBOMCache(dir string, ttl time.Duration, maxDiskSize int64) (*sbomCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating SBOM cache directory %s: %w", dir, err)
+	}
+
+	return &sbomCache{
+		dir:         dir,
+		ttl:         ttl,
+		maxDiskSize: maxDiskSize,
+		now:         time.Now,
+	}, nil
+}
+
+// get returns the SBOM stored for the image, if there's one and it has not
+// expired.
+func (cache *sbomCache) get(imageID string) (*cyclonedx.BOM, bool) {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	path := cache.pathForImage(imageID)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debugf("error reading SBOM cache entry for image %s: %s", imageID, err)
+		}
+		return nil, false
+	}
+
+	var entry sbomCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.ImageID != imageID || entry.BOM == nil {
+		log.Debugf("invalid SBOM cache entry for image %s, removing it", imageID)
+		cache.remove(path)
+		return nil, false
+	}
+
+	if cache.ttl > 0 && cache.now().Sub(entry.CreatedAt) > cache.ttl {
+		cache.remove(path)
+		return nil, false
+	}
+
+	return entry.BOM, true
+}
+
+// set stores the SBOM of the image.
+func (cache *sbomCache) set(imageID string, bom *cyclonedx.BOM) error {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	content, err := json.Marshal(sbomCacheEntry{
+		ImageID:   imageID,
+		CreatedAt: cache.now(),
+		BOM:       bom,
+	})
+	if err != nil {
+		return fmt.Errorf("error serializing SBOM of image %s: %w", imageID, err)
+	}
+
+	if cache.maxDiskSize > 0 && int64(len(content)) > cache.maxDiskSize {
+		return fmt.Errorf("SBOM of image %s is larger than the maximum size of the cache", imageID)
+	}
+
+	// Write to a temporary file first, so that a crash never leaves a
+	// truncated entry behind.
+	path := cache.pathForImage(imageID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return fmt.Errorf("error writing SBOM cache entry for image %s: %w", imageID, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		cache.remove(tmpPath)
+		return fmt.Errorf("error writing SBOM cache entry for image %s: %w", imageID, err)
+	}
+
+	cache.evict()
+
+	return nil
+}
+
+// delete removes the SBOM stored for the image, if there's one.
+func (cache *sbomCache) delete(imageID string) {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	cache.remove(cache.pathForImage(imageID))
+}
+
+// evict removes the oldest entries until the cache fits in maxDiskSize.
+func (cache *sbomCache) evict() {
+	if cache.maxDiskSize <= 0 {
+		return
+	}
+
+	dirEntries, err := os.ReadDir(cache.dir)
+	if err != nil {
+		log.Debugf("error listing SBOM cache entries: %s", err)
+		return
+	}
+
+	var files []os.FileInfo
+	var totalSize int64
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), sbomCacheFileSuffix) {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, info)
+		totalSize += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, file := range files {
+		if totalSize <= cache.maxDiskSize {
+			return
+		}
+
+		cache.remove(filepath.Join(cache.dir, file.Name()))
+		totalSize -= file.Size()
+	}
+}
+
+func (cache *sbomCache) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Debugf("error removing SBOM cache entry %s: %s", path, err)
+	}
+}
+
+// pathForImage returns the path of the file storing the SBOM of the image.
+// Image IDs are digests like "sha256:<hex>", so replacing the colon is enough
+// to get a valid file name that doesn't collide with other images.
+func (cache *sbomCache) pathForImage(imageID string) string {
+	return filepath.Join(cache.dir, invalidSBOMCacheFileChars.ReplaceAllString(imageID, "-")+sbomCacheFileSuffix)
+}
@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSBOMCache(t *testing.T, ttl time.Duration, maxDiskSize int64) (*sbomCache, *time.Time) {
+	cache, err := newSBOMCache(filepath.Join(t.TempDir(), "sbom-cache"), ttl, maxDiskSize)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	return cache, &now
+}
+
+func newTestBOM(serialNumber string) *cyclonedx.BOM {
+	bom := cyclonedx.NewBOM()
+	bom.SerialNumber = serialNumber
+	return bom
+}
+
+func TestSBOMCacheHit(t *testing.T) {
+	cache, _ := newTestSBOMCache(t, time.Hour, 0)
+
+	bom := newTestBOM("urn:uuid:1")
+	require.NoError(t, cache.set("sha256:1", bom))
+
+	cachedBOM, found := cache.get("sha256:1")
+	require.True(t, found)
+	assert.Equal(t, bom.SerialNumber, cachedBOM.SerialNumber)
+	assert.Equal(t, bom.SpecVersion, cachedBOM.SpecVersion)
+
+	// The cache is persistent: a new cache on the same directory has the
+	// same entries
+	otherCache, err := newSBOMCache(cache.dir, time.Hour, 0)
+	require.NoError(t, err)
+	otherCache.now = cache.now
+
+	cachedBOM, found = otherCache.get("sha256:1")
+	require.True(t, found)
+	assert.Equal(t, bom.SerialNumber, cachedBOM.SerialNumber)
+	assert.Equal(t, bom.SpecVersion, cachedBOM.SpecVersion)
+}
+
+func TestSBOMCacheMiss(t *testing.T) {
+	cache, _ := newTestSBOMCache(t, time.Hour, 0)
+
+	require.NoError(t, cache.set("sha256:1", newTestBOM("urn:uuid:1")))
+
+	_, found := cache.get("sha256:2")
+	assert.False(t, found)
+
+	// Invalid entries are misses too, and are removed
+	path := cache.pathForImage("sha256:3")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+
+	_, found = cache.get("sha256:3")
+	assert.False(t, found)
+	assert.NoFileExists(t, path)
+}
+
+func TestSBOMCacheTTL(t *testing.T) {
+	cache, now := newTestSBOMCache(t, time.Hour, 0)
+
+	require.NoError(t, cache.set("sha256:1", newTestBOM("urn:uuid:1")))
+
+	*now = now.Add(59 * time.Minute)
+	_, found := cache.get("sha256:1")
+	assert.True(t, found)
+
+	*now = now.Add(2 * time.Minute)
+	_, found = cache.get("sha256:1")
+	assert.False(t, found)
+	assert.NoFileExists(t, cache.pathForImage("sha256:1"))
+}
+
+func TestSBOMCacheMaxDiskSize(t *testing.T) {
+	cache, _ := newTestSBOMCache(t, 0, 0)
+
+	require.NoError(t, cache.set("sha256:1", newTestBOM("urn:uuid:1")))
+	info, err := os.Stat(cache.pathForImage("sha256:1"))
+	require.NoError(t, err)
+
+	// Room for two entries of the same size
+	cache.maxDiskSize = 2 * info.Size()
+
+	// Make sure the first entry is the oldest one
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(cache.pathForImage("sha256:1"), old, old))
+
+	require.NoError(t, cache.set("sha256:2", newTestBOM("urn:uuid:2")))
+	require.NoError(t, cache.set("sha256:3", newTestBOM("urn:uuid:3")))
+
+	_, found := cache.get("sha256:1")
+	assert.False(t, found)
+	_, found = cache.get("sha256:2")
+	assert.True(t, found)
+	_, found = cache.get("sha256:3")
+	assert.True(t, found)
+
+	// Entries larger than the cache are rejected
+	cache.maxDiskSize = 1
+	assert.Error(t, cache.set("sha256:4", newTestBOM("urn:uuid:4")))
+}
+
+func TestSBOMCachePathForImage(t *testing.T) {
+	cache, _ := newTestSBOMCache(t, 0, 0)
+
+	assert.Equal(t, filepath.Join(cache.dir, "sha256-abc.json"), cache.pathForImage("sha256:abc"))
+	assert.Equal(t, filepath.Join(cache.dir, "------etc-passwd.json"), cache.pathForImage("../../etc/passwd"))
+}
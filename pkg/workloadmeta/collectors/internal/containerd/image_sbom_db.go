@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+)
+
+// vulnerabilityDB is the vulnerability DB of trivy, see trivy.DBUpdater
+type vulnerabilityDB interface {
+	Update(ctx context.Context) error
+	Run(ctx context.Context)
+	Close() error
+	Vulnerabilities(ctx context.Context, components []cyclonedx.Component) ([]trivy.Vulnerability, error)
+}
+
+// newVulnerabilityDB creates the DB with the given configuration. Overridden
+// in tests.
+var newVulnerabilityDB = func(dbConfig trivy.DBConfig) vulnerabilityDB {
+	return trivy.NewDBUpdater(dbConfig)
+}
+
+func vulnerabilityDBConfig() trivy.DBConfig {
+	return trivy.DBConfig{
+		CacheDir:       config.Datadog.GetString("container_image_collection.sbom.db.directory"),
+		Repository:     config.Datadog.GetString("container_image_collection.sbom.db.repository"),
+		UpdateInterval: time.Duration(config.Datadog.GetInt("container_image_collection.sbom.db.update_interval")) * time.Second,
+		Offline:        config.Datadog.GetBool("container_image_collection.sbom.db.offline"),
+	}
+}
+
+// vulnerabilityDBFromConfig returns the vulnerability DB, or nil when it's
+// disabled
+func vulnerabilityDBFromConfig() vulnerabilityDB {
+	if !config.Datadog.GetBool("container_image_collection.sbom.db.enabled") {
+		return nil
+	}
+
+	return newVulnerabilityDB(vulnerabilityDBConfig())
+}
+
+// prepareVulnerabilityDB returns the function run before the SBOM self-test
+// to get the vulnerability DB, or nil when the DB is disabled. The SBOMs are
+// reported with their vulnerabilities once the DB is loaded, and with their
+// packages only before. The self-test fails when there's no usable DB, for
+// instance in offline mode when it's not in the DB directory, which reports
+// SBOM collection as degraded in the health of the agent instead of blocking
+// it. Then the DB is updated in the background until
+// the scans stop, and closed.
+func prepareVulnerabilityDB(db vulnerabilityDB) func(context.Context) error {
+	if db == nil {
+		return nil
+	}
+
+	return func(ctx context.Context) error {
+		err := db.Update(ctx)
+
+		go func() {
+			db.Run(ctx)
+			<-ctx.Done()
+
+			if err := db.Close(); err != nil {
+				log.Warnf("Error closing the vulnerability DB: %s", err)
+			}
+		}()
+
+		return err
+	}
+}
+
+// trivyVulnerabilitySource finds the vulnerabilities in the vulnerability DB
+// of trivy. It's the default source, used when the DB is enabled and no
+// other source is registered.
+type trivyVulnerabilitySource struct {
+	db vulnerabilityDB
+}
+
+func newTrivyVulnerabilitySource(db vulnerabilityDB) VulnerabilitySource {
+	if db == nil {
+		return nil
+	}
+
+	return &trivyVulnerabilitySource{db: db}
+}
+
+// Vulnerabilities implements VulnerabilitySource. It returns
+// trivy.ErrDBUnavailable until the DB is loaded.
+func (s *trivyVulnerabilitySource) Vulnerabilities(ctx context.Context, components []cyclonedx.Component) ([]Vulnerability, error) {
+	found, err := s.db.Vulnerabilities(ctx, components)
+	if err != nil {
+		return nil, err
+	}
+
+	vulnerabilities := make([]Vulnerability, 0, len(found))
+	for _, vulnerability := range found {
+		vulnerabilities = append(vulnerabilities, Vulnerability{
+			ID: vulnerability.ID,
+			// The severities of trivy are the ones of CycloneDX, in
+			// upper case
+			Severity:     cyclonedx.Severity(strings.ToLower(vulnerability.Severity)),
+			PackageURL:   vulnerability.PackageURL,
+			FixedVersion: vulnerability.FixedVersion,
+		})
+	}
+
+	return vulnerabilities, nil
+}
@@ -0,0 +1,174 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+)
+
+// fakeVulnerabilityDB is a vulnerability DB that is present or not, as
+// trivy.DBUpdater would find it with its configuration.
+type fakeVulnerabilityDB struct {
+	config  trivy.DBConfig
+	present bool
+	runs    int32
+	closed  int32
+
+	vulnerabilities []trivy.Vulnerability
+}
+
+func (db *fakeVulnerabilityDB) Update(context.Context) error {
+	if !db.present && db.config.Offline {
+		return fmt.Errorf("%w in offline mode", trivy.ErrDBUnavailable)
+	}
+	return nil
+}
+
+func (db *fakeVulnerabilityDB) Run(context.Context) {
+	atomic.AddInt32(&db.runs, 1)
+}
+
+func (db *fakeVulnerabilityDB) Close() error {
+	atomic.AddInt32(&db.closed, 1)
+	return nil
+}
+
+// Vulnerabilities returns the vulnerabilities of the DB once it's present
+func (db *fakeVulnerabilityDB) Vulnerabilities(context.Context, []cyclonedx.Component) ([]trivy.Vulnerability, error) {
+	if !db.present {
+		return nil, trivy.ErrDBUnavailable
+	}
+	return db.vulnerabilities, nil
+}
+
+func withFakeVulnerabilityDB(t *testing.T, present bool) *fakeVulnerabilityDB {
+	db := &fakeVulnerabilityDB{present: present}
+
+	previous := newVulnerabilityDB
+	newVulnerabilityDB = func(dbConfig trivy.DBConfig) vulnerabilityDB {
+		db.config = dbConfig
+		return db
+	}
+	t.Cleanup(func() { newVulnerabilityDB = previous })
+
+	return db
+}
+
+func TestPrepareVulnerabilityDBDisabled(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.sbom.db.enabled", false)
+
+	assert.Nil(t, vulnerabilityDBFromConfig())
+	assert.Nil(t, prepareVulnerabilityDB(nil))
+	assert.Nil(t, newTrivyVulnerabilitySource(nil))
+}
+
+func TestPrepareVulnerabilityDBOffline(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.sbom.db.enabled", true)
+	cfg.Set("container_image_collection.sbom.db.offline", true)
+
+	db := withFakeVulnerabilityDB(t, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := collector{
+		trivyClient: &fakeScanner{scans: make(map[string]int)},
+	}
+
+	// Without a DB, the self-test fails instead of blocking
+	selfTest := c.startSBOMSelfTest(ctx, prepareVulnerabilityDB(vulnerabilityDBFromConfig()))
+	err := waitForSBOMSelfTest(t, selfTest)
+	assert.ErrorIs(t, err, trivy.ErrDBUnavailable)
+	assert.True(t, db.config.Offline)
+
+	// And SBOM collection is reported as degraded in the health of the agent
+	assertSBOMHealthDegraded(t, trivy.ErrDBUnavailable.Error())
+}
+
+func TestPrepareVulnerabilityDBMirror(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.sbom.db.enabled", true)
+	cfg.Set("container_image_collection.sbom.db.repository", "registry.example.com/trivy-db")
+	cfg.Set("container_image_collection.sbom.db.update_interval", 3600)
+	cfg.Set("container_image_collection.sbom.db.directory", "/tmp/trivy-db")
+
+	db := withFakeVulnerabilityDB(t, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := collector{
+		trivyClient: &fakeScanner{scans: make(map[string]int)},
+	}
+
+	selfTest := c.startSBOMSelfTest(ctx, prepareVulnerabilityDB(vulnerabilityDBFromConfig()))
+	assert.NoError(t, waitForSBOMSelfTest(t, selfTest))
+
+	assert.Equal(t, trivy.DBConfig{
+		CacheDir:       "/tmp/trivy-db",
+		Repository:     "registry.example.com/trivy-db",
+		UpdateInterval: time.Hour,
+	}, db.config)
+
+	// Then the DB is kept up to date in the background
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&db.runs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// And closed when the scans stop
+	assert.Equal(t, int32(0), atomic.LoadInt32(&db.closed))
+	cancel()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&db.closed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTrivyVulnerabilitySource(t *testing.T) {
+	db := &fakeVulnerabilityDB{
+		vulnerabilities: []trivy.Vulnerability{
+			{
+				ID:           "CVE-2023-23914",
+				Severity:     "CRITICAL",
+				PackageURL:   "pkg:deb/debian/curl@7.74.0",
+				FixedVersion: "7.88.0",
+			},
+		},
+	}
+
+	c := collector{
+		vulnerabilitySource: newTrivyVulnerabilitySource(db),
+	}
+
+	// Reported without vulnerabilities until the DB is loaded
+	bom := newTestVulnerableBOM()
+	assert.Same(t, bom, c.enrichSBOM(context.Background(), bom))
+
+	db.present = true
+	enriched := c.enrichSBOM(context.Background(), bom)
+	require.NotNil(t, enriched.Vulnerabilities)
+	require.Len(t, *enriched.Vulnerabilities, 1)
+
+	found := (*enriched.Vulnerabilities)[0]
+	assert.Equal(t, "CVE-2023-23914", found.ID)
+	assert.Equal(t, cyclonedx.SeverityCritical, (*found.Ratings)[0].Severity)
+	assert.Equal(t, "pkg:deb/debian/curl@7.74.0", (*found.Affects)[0].Ref)
+}
@@ -0,0 +1,155 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/containers"
+)
+
+// labelSelector matches the images with a label, optionally with a given
+// value.
+type labelSelector struct {
+	key      string
+	value    string
+	anyValue bool
+}
+
+// parseLabelSelector parses selectors with the "key" or "key=value" format.
+func parseLabelSelector(selector string) (labelSelector, error) {
+	key, value, hasValue := strings.Cut(selector, "=")
+	if key == "" {
+		return labelSelector{}, fmt.Errorf("invalid label selector %q: the label key is empty", selector)
+	}
+
+	return labelSelector{
+		key:      key,
+		value:    value,
+		anyValue: !hasValue,
+	}, nil
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	value, found := labels[s.key]
+	return found && (s.anyValue || value == s.value)
+}
+
+// sbomFilter decides which images have their SBOM extracted, based on their
+// repositories and their labels.
+//
+// An image is excluded when one of its repositories or labels matches an
+// exclusion. Otherwise, it's included when there are no inclusions, or when
+// one of its repositories or labels matches an inclusion.
+//
+// Repositories are matched with glob patterns (see path.Match) against the
+// image names without tag nor digest, like "docker.io/datadog/agent".
+type sbomFilter struct {
+	includeRepositories []string
+	excludeRepositories []string
+	includeLabels       []labelSelector
+	excludeLabels       []labelSelector
+}
+
+// newSBOMFilter returns a filter with the given repository patterns and
+// label selectors, or nil if there are none.
+func newSBOMFilter(includeRepositories, excludeRepositories, includeLabels, excludeLabels []string) (*sbomFilter, error) {
+	if len(includeRepositories)+len(excludeRepositories)+len(includeLabels)+len(excludeLabels) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range append(append([]string{}, includeRepositories...), excludeRepositories...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+
+	filter := &sbomFilter{
+		includeRepositories: includeRepositories,
+		excludeRepositories: excludeRepositories,
+	}
+
+	var err error
+	if filter.includeLabels, err = parseLabelSelectors(includeLabels); err != nil {
+		return nil, err
+	}
+	if filter.excludeLabels, err = parseLabelSelectors(excludeLabels); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func parseLabelSelectors(selectors []string) ([]labelSelector, error) {
+	var parsed []labelSelector
+	for _, selector := range selectors {
+		s, err := parseLabelSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, s)
+	}
+	return parsed, nil
+}
+
+// isIncluded returns whether the SBOM of the image with the given names and
+// labels should be extracted. A nil filter includes all the images.
+func (f *sbomFilter) isIncluded(imageNames []string, labels map[string]string) bool {
+	if f == nil {
+		return true
+	}
+
+	repositories := make([]string, 0, len(imageNames))
+	for _, imageName := range imageNames {
+		repositories = append(repositories, imageRepository(imageName))
+	}
+
+	if matchesAnyRepository(f.excludeRepositories, repositories) || matchesAnyLabel(f.excludeLabels, labels) {
+		return false
+	}
+
+	if len(f.includeRepositories) == 0 && len(f.includeLabels) == 0 {
+		return true
+	}
+
+	return matchesAnyRepository(f.includeRepositories, repositories) || matchesAnyLabel(f.includeLabels, labels)
+}
+
+func matchesAnyRepository(patterns []string, repositories []string) bool {
+	for _, pattern := range patterns {
+		for _, repository := range repositories {
+			// The patterns are validated when creating the filter
+			if matched, _ := path.Match(pattern, repository); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAnyLabel(selectors []labelSelector, labels map[string]string) bool {
+	for _, selector := range selectors {
+		if selector.matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageRepository returns the name of the image without its tag nor digest.
+// Names that can't be parsed, like digests, are returned as is.
+func imageRepository(imageName string) string {
+	repository, _, _, _, err := containers.SplitImageName(imageName)
+	if err != nil || repository == "" {
+		return imageName
+	}
+	return repository
+}
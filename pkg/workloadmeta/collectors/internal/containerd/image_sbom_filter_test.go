@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestNewSBOMFilter(t *testing.T) {
+	filter, err := newSBOMFilter(nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+	assert.True(t, filter.isIncluded([]string{"datadog/agent:7"}, nil))
+
+	_, err = newSBOMFilter(nil, []string{"[invalid"}, nil, nil)
+	assert.ErrorContains(t, err, `invalid repository pattern "[invalid"`)
+
+	_, err = newSBOMFilter(nil, nil, []string{"=value"}, nil)
+	assert.ErrorContains(t, err, `invalid label selector "=value"`)
+}
+
+func TestSBOMFilterIsIncluded(t *testing.T) {
+	filter, err := newSBOMFilter(
+		[]string{"docker.io/datadog/*", "gcr.io/*/*"},
+		[]string{"gcr.io/google_containers/*", "*/pause"},
+		[]string{"sbom.datadoghq.com/enabled=true"},
+		[]string{"io.cri-containerd.pinned", "sbom.datadoghq.com/enabled=false"},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		names    []string
+		labels   map[string]string
+		included bool
+	}{
+		{
+			name:     "included by repository",
+			names:    []string{"docker.io/datadog/agent:7"},
+			included: true,
+		},
+		{
+			name:     "included by digest",
+			names:    []string{"docker.io/datadog/agent@sha256:0f77e6e8528e0b4b5a0a8b1e4bd4b66e3d3a4b6a1e6a5c1f39fbb3e7a1e5c6d2"},
+			included: true,
+		},
+		{
+			name:     "included by label",
+			names:    []string{"docker.io/library/redis:7"},
+			labels:   map[string]string{"sbom.datadoghq.com/enabled": "true"},
+			included: true,
+		},
+		{
+			name:     "included by one of its names",
+			names:    []string{"docker.io/library/agent:7", "docker.io/datadog/agent:7"},
+			included: true,
+		},
+		{
+			name:     "not included",
+			names:    []string{"docker.io/library/redis:7"},
+			included: false,
+		},
+		{
+			name:     "excluded by repository",
+			names:    []string{"gcr.io/google_containers/kube-proxy:v1.25"},
+			included: false,
+		},
+		{
+			name:     "excluded by repository, even if included by label",
+			names:    []string{"registry.k8s.io/pause:3.6"},
+			labels:   map[string]string{"sbom.datadoghq.com/enabled": "true"},
+			included: false,
+		},
+		{
+			name:     "excluded by label key",
+			names:    []string{"docker.io/datadog/agent:7"},
+			labels:   map[string]string{"io.cri-containerd.pinned": "pinned"},
+			included: false,
+		},
+		{
+			name:     "excluded by label value",
+			names:    []string{"docker.io/datadog/agent:7"},
+			labels:   map[string]string{"sbom.datadoghq.com/enabled": "false"},
+			included: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.included, filter.isIncluded(test.names, test.labels))
+		})
+	}
+}
+
+func TestShouldScanImage(t *testing.T) {
+	filter, err := newSBOMFilter(nil, []string{"*/pause"}, nil, []string{"io.cri-containerd.pinned"})
+	require.NoError(t, err)
+
+	c := collector{sbomFilter: filter}
+
+	assert.True(t, c.shouldScanImage(&workloadmeta.ContainerImageMetadata{
+		EntityMeta: workloadmeta.EntityMeta{Name: "docker.io/datadog/agent:7"},
+	}))
+	assert.False(t, c.shouldScanImage(&workloadmeta.ContainerImageMetadata{
+		EntityMeta: workloadmeta.EntityMeta{Name: "sha256:1234"},
+		RepoTags:   []string{"registry.k8s.io/pause:3.6"},
+	}))
+	assert.False(t, c.shouldScanImage(&workloadmeta.ContainerImageMetadata{
+		EntityMeta: workloadmeta.EntityMeta{
+			Name:   "docker.io/datadog/agent:7",
+			Labels: map[string]string{"io.cri-containerd.pinned": "pinned"},
+		},
+	}))
+}
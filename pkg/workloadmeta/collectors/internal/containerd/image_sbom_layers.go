@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/google/uuid"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// layerSBOMCache is an in-memory cache of the SBOM fragments of the layers
+// that were scanned, keyed by layer digest. The layers shared by several
+// images, like the layers of a common base image, are scanned only once.
+// The fragment of a layer that can't be scanned without the layers below it
+// is nil.
+type layerSBOMCache struct {
+	fragments *lru.Cache[string, *cyclonedx.BOM]
+}
+
+// newLayerSBOMCache returns a cache of the fragments of at most maxLayers
+// layers, or nil if maxLayers is not positive.
+func newLayerSBOMCache(maxLayers int) (*layerSBOMCache, error) {
+	if maxLayers <= 0 {
+		return nil, nil
+	}
+
+	fragments, err := lru.New[string, *cyclonedx.BOM](maxLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &layerSBOMCache{fragments: fragments}, nil
+}
+
+func (cache *layerSBOMCache) get(layerDigest string) (*cyclonedx.BOM, bool) {
+	return cache.fragments.Get(layerDigest)
+}
+
+func (cache *layerSBOMCache) set(layerDigest string, fragment *cyclonedx.BOM) {
+	cache.fragments.Add(layerDigest, fragment)
+}
+
+// assembleLayeredSBOM returns the SBOM of an image made of the SBOM fragments
+// of its layers, ordered from the bottom layer to the top one. When a
+// package is found in several layers, for instance because it was upgraded,
+// the version of the topmost layer is kept. The layers removing files are
+// not scanned separately, so no package of the fragments was removed.
+func assembleLayeredSBOM(imageName string, fragments []*cyclonedx.BOM) *cyclonedx.BOM {
+	indexByIdentity := make(map[string]int)
+	var components []cyclonedx.Component
+
+	for _, fragment := range fragments {
+		if fragment == nil || fragment.Components == nil {
+			continue
+		}
+
+		for _, component := range *fragment.Components {
+			identity := componentIdentity(component)
+			if index, found := indexByIdentity[identity]; found {
+				components[index] = component
+				continue
+			}
+
+			indexByIdentity[identity] = len(components)
+			components = append(components, component)
+		}
+	}
+
+	bom := cyclonedx.NewBOM()
+	bom.SerialNumber = "urn:uuid:" + uuid.New().String()
+	bom.Metadata = &cyclonedx.Metadata{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Component: &cyclonedx.Component{
+			Type: cyclonedx.ComponentTypeContainer,
+			Name: imageName,
+		},
+	}
+	bom.Components = &components
+
+	// The fragments are generated by the same scanner
+	for i := len(fragments) - 1; i >= 0; i-- {
+		if fragments[i] != nil && fragments[i].Metadata != nil {
+			bom.Metadata.Tools = fragments[i].Metadata.Tools
+			break
+		}
+	}
+
+	return bom
+}
+
+// componentIdentity identifies a package regardless of its version, unlike
+// componentKey.
+func componentIdentity(component cyclonedx.Component) string {
+	if purl := component.PackageURL; purl != "" {
+		if at := strings.LastIndex(purl, "@"); at >= 0 {
+			return purl[:at]
+		}
+		return purl
+	}
+
+	return string(component.Type) + "/" + component.Group + "/" + component.Name
+}
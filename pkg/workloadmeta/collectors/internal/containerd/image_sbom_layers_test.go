@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLayerSBOMCache(t *testing.T) {
+	cache, err := newLayerSBOMCache(0)
+	assert.NoError(t, err)
+	assert.Nil(t, cache)
+
+	cache, err = newLayerSBOMCache(2)
+	require.NoError(t, err)
+
+	for _, layerDigest := range []string{"sha256:1", "sha256:2", "sha256:3"} {
+		cache.set(layerDigest, newTestBOM(layerDigest))
+	}
+
+	// The least recently used layer is evicted
+	_, found := cache.get("sha256:1")
+	assert.False(t, found)
+
+	fragment, found := cache.get("sha256:3")
+	require.True(t, found)
+	assert.Equal(t, "sha256:3", fragment.SerialNumber)
+}
+
+func TestAssembleLayeredSBOM(t *testing.T) {
+	libc := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "libc6", Version: "2.31-13", PackageURL: "pkg:deb/debian/libc6@2.31-13?distro=debian-11"}
+	upgradedLibc := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "libc6", Version: "2.31-13+deb11u5", PackageURL: "pkg:deb/debian/libc6@2.31-13+deb11u5?distro=debian-11"}
+	curl := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "curl", Version: "7.74.0", PackageURL: "pkg:deb/debian/curl@7.74.0?distro=debian-11"}
+	app := cyclonedx.Component{Type: cyclonedx.ComponentTypeApplication, Name: "app"}
+
+	base := newTestBOM("urn:uuid:base")
+	base.Components = &[]cyclonedx.Component{libc, curl}
+
+	top := newTestBOM("urn:uuid:top")
+	top.Metadata = &cyclonedx.Metadata{
+		Tools: &[]cyclonedx.Tool{{Vendor: "aquasecurity", Name: "trivy"}},
+	}
+	top.Components = &[]cyclonedx.Component{upgradedLibc, app}
+
+	bom := assembleLayeredSBOM("datadog/agent:7", []*cyclonedx.BOM{base, nil, top})
+
+	// The version of the topmost layer is kept, in place
+	assert.Equal(t, []cyclonedx.Component{upgradedLibc, curl, app}, *bom.Components)
+	assert.Equal(t, "datadog/agent:7", bom.Metadata.Component.Name)
+	assert.Equal(t, top.Metadata.Tools, bom.Metadata.Tools)
+	assert.Regexp(t, "^urn:uuid:", bom.SerialNumber)
+	assert.NotEqual(t, base.SerialNumber, bom.SerialNumber)
+	assert.NotEqual(t, top.SerialNumber, bom.SerialNumber)
+
+	// The fragments are not modified
+	assert.Equal(t, []cyclonedx.Component{libc, curl}, *base.Components)
+}
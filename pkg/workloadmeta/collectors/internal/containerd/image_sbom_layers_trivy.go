@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/namespaces"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// scanImageLayers generates the SBOM of an image from the SBOM fragments of
+// its layers. Only the layers whose fragment is not cached are scanned, the
+// other ones were scanned as part of another image. On rescans, all the
+// layers are scanned again to get up-to-date results.
+//
+// When a layer can't be scanned without the layers below it, the whole image
+// is scanned with scanImage instead.
+func (c *collector) scanImageLayers(ctx context.Context, storedImage *workloadmeta.ContainerImageMetadata, img containerd.Image, rescan bool, scanImage func(context.Context, *workloadmeta.ContainerImageMetadata, containerd.Image) (*cyclonedx.BOM, error)) (*cyclonedx.BOM, error) {
+	manifest, err := images.Manifest(namespaces.WithNamespace(ctx, storedImage.Namespace), img.ContentStore(), img.Target(), img.Platform())
+	if err != nil {
+		return nil, fmt.Errorf("error getting image manifest: %w", err)
+	}
+
+	fragments := make([]*cyclonedx.BOM, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		layerDigest := layer.Digest.String()
+
+		if !rescan {
+			if fragment, found := c.layerSBOMCache.get(layerDigest); found {
+				if fragment == nil {
+					log.Debugf("Image: %s/%s (id %s) layer %s can't be scanned alone, scanning the whole image", storedImage.Namespace, img.Name(), storedImage.ID, layerDigest)
+					return scanImage(ctx, storedImage, img)
+				}
+
+				log.Debugf("Image: %s/%s (id %s) SBOM of layer %s found in cache", storedImage.Namespace, img.Name(), storedImage.ID, layerDigest)
+				fragments = append(fragments, fragment)
+				continue
+			}
+		}
+
+		fragment, err := c.trivyClient.ScanContainerdImageLayer(ctx, storedImage, img, layer)
+		if errors.Is(err, trivy.ErrLayerNeedsLowerLayers) {
+			log.Debugf("Image: %s/%s (id %s) scanning the whole image: %s", storedImage.Namespace, img.Name(), storedImage.ID, err)
+			c.layerSBOMCache.set(layerDigest, nil)
+			return scanImage(ctx, storedImage, img)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error scanning layer %s: %w", layerDigest, err)
+		}
+
+		c.layerSBOMCache.set(layerDigest, fragment)
+		fragments = append(fragments, fragment)
+	}
+
+	return assembleLayeredSBOM(storedImage.Name, fragments), nil
+}
@@ -0,0 +1,203 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content/local"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestSBOMScanSharedLayers(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	newLayer := func(name string) ocispec.Descriptor {
+		return ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayerGzip,
+			Digest:    digest.FromString(name),
+			Size:      int64(len(name)),
+		}
+	}
+	baseLayer := newLayer("base")
+	appLayer := newLayer("app")
+	otherAppLayer := newLayer("other-app")
+
+	// Both images are derived from the same base image
+	appImage, appImageID := newFakeImageWithLayers(t, contentStore, "docker.io/datadog/app:1", ocispec.Image{Author: "app"}, baseLayer, appLayer)
+	otherAppImage, otherAppImageID := newFakeImageWithLayers(t, contentStore, "docker.io/datadog/other-app:1", ocispec.Image{Author: "other-app"}, baseLayer, otherAppLayer)
+
+	newStoredImage := func(id string, name string) *workloadmeta.ContainerImageMetadata {
+		return &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   id,
+			},
+			EntityMeta: workloadmeta.EntityMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+	}
+
+	store := newFakeImageStore(
+		newStoredImage(appImageID, appImage.Name()),
+		newStoredImage(otherAppImageID, otherAppImage.Name()),
+	)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+	layerSBOMCache, err := newLayerSBOMCache(10)
+	require.NoError(t, err)
+
+	c := collector{
+		store:          store,
+		trivyClient:    scanner,
+		scannedImages:  newScannedImages(),
+		layerSBOMCache: layerSBOMCache,
+	}
+
+	componentNames := func(bom *cyclonedx.BOM) []string {
+		var names []string
+		for _, component := range *bom.Components {
+			names = append(names, component.Name)
+		}
+		return names
+	}
+
+	scan := func(img *fakeImage, imageID string, rescan bool) *workloadmeta.ContainerImageMetadata {
+		require.NoError(t, c.extractBOMWithTrivy(context.Background(), namespacedImage{
+			namespace: "default",
+			image:     img,
+			imageID:   imageID,
+			rescan:    rescan,
+		}))
+
+		image := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+		require.NotNil(t, image.CycloneDXBOM)
+		return image
+	}
+
+	// All the layers of the first image are scanned
+	image := scan(appImage, appImageID, false)
+	assert.Equal(t, []string{baseLayer.Digest.Encoded(), appLayer.Digest.Encoded()}, componentNames(image.CycloneDXBOM))
+	assert.Equal(t, appImage.Name(), image.CycloneDXBOM.Metadata.Component.Name)
+	assert.Equal(t, 1, scanner.scanCount(baseLayer.Digest.String()))
+	assert.Equal(t, 1, scanner.scanCount(appLayer.Digest.String()))
+
+	// Only the top layer of the second image is scanned, the SBOM of the base
+	// layer is reused
+	image = scan(otherAppImage, otherAppImageID, false)
+	assert.Equal(t, []string{baseLayer.Digest.Encoded(), otherAppLayer.Digest.Encoded()}, componentNames(image.CycloneDXBOM))
+	assert.Equal(t, 1, scanner.scanCount(baseLayer.Digest.String()))
+	assert.Equal(t, 1, scanner.scanCount(otherAppLayer.Digest.String()))
+
+	// The whole images are never scanned
+	assert.Equal(t, 0, scanner.scanCount(appImageID))
+	assert.Equal(t, 0, scanner.scanCount(otherAppImageID))
+
+	// Rescans don't use the cached layers
+	scan(otherAppImage, otherAppImageID, true)
+	assert.Equal(t, 2, scanner.scanCount(baseLayer.Digest.String()))
+	assert.Equal(t, 2, scanner.scanCount(otherAppLayer.Digest.String()))
+}
+
+// lowerLayersScanner is a fakeScanner which can't scan some layers without
+// the layers below them.
+type lowerLayersScanner struct {
+	*fakeScanner
+	needLowerLayers map[digest.Digest]bool
+}
+
+func (s *lowerLayersScanner) ScanContainerdImageLayer(ctx context.Context, imgMeta *workloadmeta.ContainerImageMetadata, img containerd.Image, layer ocispec.Descriptor) (*cyclonedx.BOM, error) {
+	if s.needLowerLayers[layer.Digest] {
+		s.mut.Lock()
+		defer s.mut.Unlock()
+
+		s.scans[layer.Digest.String()]++
+		return nil, fmt.Errorf("layer %s deletes files: %w", layer.Digest, trivy.ErrLayerNeedsLowerLayers)
+	}
+
+	return s.fakeScanner.ScanContainerdImageLayer(ctx, imgMeta, img, layer)
+}
+
+func TestSBOMScanLayerNeedingLowerLayers(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	baseLayer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromString("base"),
+		Size:      4,
+	}
+	// For instance, a layer upgrading OS packages, without the OS release
+	// files of the base layer
+	upgradeLayer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromString("upgrade"),
+		Size:      7,
+	}
+
+	img, imageID := newFakeImageWithLayers(t, contentStore, "docker.io/datadog/app:1", ocispec.Image{Author: "app"}, baseLayer, upgradeLayer)
+	otherImg, otherImageID := newFakeImageWithLayers(t, contentStore, "docker.io/datadog/other-app:1", ocispec.Image{Author: "other-app"}, baseLayer, upgradeLayer)
+
+	store := newFakeImageStore()
+	for _, image := range []struct {
+		id   string
+		name string
+	}{{imageID, img.Name()}, {otherImageID, otherImg.Name()}} {
+		store.Set(&workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   image.id,
+			},
+			EntityMeta: workloadmeta.EntityMeta{
+				Name:      image.name,
+				Namespace: "default",
+			},
+		})
+	}
+
+	scanner := &lowerLayersScanner{
+		fakeScanner:     &fakeScanner{scans: make(map[string]int)},
+		needLowerLayers: map[digest.Digest]bool{upgradeLayer.Digest: true},
+	}
+	layerSBOMCache, err := newLayerSBOMCache(10)
+	require.NoError(t, err)
+
+	c := collector{
+		store:          store,
+		trivyClient:    scanner,
+		scannedImages:  newScannedImages(),
+		layerSBOMCache: layerSBOMCache,
+	}
+
+	// The whole image is scanned instead of its layers
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), namespacedImage{namespace: "default", image: img, imageID: imageID}))
+	image := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, imageID, image.CycloneDXBOM.SerialNumber)
+	assert.Equal(t, 1, scanner.scanCount(imageID))
+	assert.Equal(t, 1, scanner.scanCount(upgradeLayer.Digest.String()))
+
+	// The layer isn't scanned again for the other images using it
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), namespacedImage{namespace: "default", image: otherImg, imageID: otherImageID}))
+	image = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, otherImageID, image.CycloneDXBOM.SerialNumber)
+	assert.Equal(t, 1, scanner.scanCount(otherImageID))
+	assert.Equal(t, 1, scanner.scanCount(upgradeLayer.Digest.String()))
+}
@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Properties added to the metadata of the SBOMs whose packages were truncated
+const (
+	sbomTruncatedProperty       = "datadog:sbom:truncated"
+	sbomDroppedPackagesProperty = "datadog:sbom:dropped_packages"
+)
+
+// sbomLimits bounds the SBOMs notified to the store, because the SBOMs of
+// huge images are copied to every consumer of the store. A limit of 0 means
+// no limit.
+type sbomLimits struct {
+	// maxPackages is the maximum number of components of an SBOM
+	maxPackages int
+	// maxSize is the maximum size of an SBOM serialized in JSON, in bytes
+	maxSize int
+}
+
+func sbomLimitsFromConfig() sbomLimits { // nolint: unused
+	return sbomLimits{
+		maxPackages: config.Datadog.GetInt("container_image_collection.sbom.max_packages"),
+		maxSize:     config.Datadog.GetInt("container_image_collection.sbom.max_size"),
+	}
+}
+
+// apply returns the SBOM with as many of its first components as the limits
+// allow, and the number of components that were dropped. The SBOM is
+// returned as is when it's within the limits. Otherwise, a copy is returned,
+// without the vulnerabilities and dependencies of the dropped components, and
+// with properties in its metadata marking it as truncated.
+func (l sbomLimits) apply(bom *cyclonedx.BOM) (*cyclonedx.BOM, int) {
+	if bom == nil || bom.Components == nil {
+		return bom, 0
+	}
+
+	total := len(*bom.Components)
+
+	kept := total
+	if l.maxPackages > 0 && kept > l.maxPackages {
+		kept = l.maxPackages
+	}
+
+	truncated := truncateSBOM(bom, kept)
+
+	if l.maxSize > 0 && serializedSBOMSize(truncated) > l.maxSize {
+		// Finds the largest number of components fitting in the size limit.
+		// Dropping components can't make the SBOM larger, so this is a
+		// binary search.
+		low, high := 0, kept-1
+		for low < high {
+			mid := (low + high + 1) / 2
+			if serializedSBOMSize(truncateSBOM(bom, mid)) <= l.maxSize {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+
+		kept = low
+		truncated = truncateSBOM(bom, kept)
+	}
+
+	return truncated, total - kept
+}
+
+// truncateSBOM returns a copy of the SBOM with only its first kept
+// components, or the SBOM itself when it has no more than kept components.
+func truncateSBOM(bom *cyclonedx.BOM, kept int) *cyclonedx.BOM {
+	components := *bom.Components
+	if kept >= len(components) {
+		return bom
+	}
+
+	truncated := withoutComponents(bom, components[:kept], components[kept:])
+
+	var metadata cyclonedx.Metadata
+	var properties []cyclonedx.Property
+	if bom.Metadata != nil {
+		metadata = *bom.Metadata
+		if bom.Metadata.Properties != nil {
+			properties = append(properties, *bom.Metadata.Properties...)
+		}
+	}
+	properties = append(properties,
+		cyclonedx.Property{Name: sbomTruncatedProperty, Value: "true"},
+		cyclonedx.Property{Name: sbomDroppedPackagesProperty, Value: strconv.Itoa(len(components) - kept)},
+	)
+	metadata.Properties = &properties
+	truncated.Metadata = &metadata
+
+	return truncated
+}
+
+// withoutComponents returns a copy of the SBOM with the kept components
+// only, and without the dependencies and the vulnerabilities of the dropped
+// ones.
+func withoutComponents(bom *cyclonedx.BOM, kept []cyclonedx.Component, dropped []cyclonedx.Component) *cyclonedx.BOM {
+	droppedRefs := make(map[string]struct{})
+	for _, component := range dropped {
+		for _, ref := range []string{component.BOMRef, component.PackageURL} {
+			if ref != "" {
+				droppedRefs[ref] = struct{}{}
+			}
+		}
+	}
+
+	filtered := *bom
+
+	keptComponents := append([]cyclonedx.Component{}, kept...)
+	filtered.Components = &keptComponents
+
+	if bom.Dependencies != nil {
+		var dependencies []cyclonedx.Dependency
+		for _, dependency := range *bom.Dependencies {
+			if _, dropped := droppedRefs[dependency.Ref]; dropped {
+				continue
+			}
+
+			if dependency.Dependencies != nil {
+				dependsOn := withoutDroppedRefs(*dependency.Dependencies, droppedRefs)
+				dependency.Dependencies = &dependsOn
+			}
+			dependencies = append(dependencies, dependency)
+		}
+		filtered.Dependencies = &dependencies
+	}
+
+	if bom.Vulnerabilities != nil {
+		var vulnerabilities []cyclonedx.Vulnerability
+		for _, vulnerability := range *bom.Vulnerabilities {
+			if vulnerability.Affects != nil {
+				var affects []cyclonedx.Affects
+				for _, affected := range *vulnerability.Affects {
+					if _, dropped := droppedRefs[affected.Ref]; !dropped {
+						affects = append(affects, affected)
+					}
+				}
+
+				if len(affects) == 0 {
+					continue
+				}
+				vulnerability.Affects = &affects
+			}
+			vulnerabilities = append(vulnerabilities, vulnerability)
+		}
+		filtered.Vulnerabilities = &vulnerabilities
+	}
+
+	return &filtered
+}
+
+func withoutDroppedRefs(refs []string, droppedRefs map[string]struct{}) []string {
+	var kept []string
+	for _, ref := range refs {
+		if _, dropped := droppedRefs[ref]; !dropped {
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
+
+func serializedSBOMSize(bom *cyclonedx.BOM) int {
+	serialized, err := json.Marshal(bom)
+	if err != nil {
+		// Not expected, the SBOM is not limited by its size in that case
+		return 0
+	}
+	return len(serialized)
+}
+
+// limitSBOM applies the SBOM limits of the collector to the SBOM of the given
+// image or container, and warns when packages are dropped.
+func (c *collector) limitSBOM(bom *cyclonedx.BOM, scanned string) *cyclonedx.BOM { // nolint: unused
+	limited, dropped := c.sbomLimits.apply(bom)
+	if dropped > 0 {
+		log.Warnf("SBOM of %s is over the limits (%d packages, %d bytes), dropping %d of its %d packages", scanned, c.sbomLimits.maxPackages, c.sbomLimits.maxSize, dropped, len(*bom.Components))
+	}
+
+	return limited
+}
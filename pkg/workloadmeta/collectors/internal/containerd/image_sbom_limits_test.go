@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// newTestLargeBOM returns an SBOM with the given number of packages, each with
+// a vulnerability, and depending on each other in order.
+func newTestLargeBOM(packages int) *cyclonedx.BOM {
+	var components []cyclonedx.Component
+	var vulnerabilities []cyclonedx.Vulnerability
+	var dependencies []cyclonedx.Dependency
+
+	for i := 0; i < packages; i++ {
+		ref := fmt.Sprintf("pkg:deb/debian/package%d@1.0", i)
+		components = append(components, cyclonedx.Component{
+			BOMRef:     ref,
+			Type:       cyclonedx.ComponentTypeLibrary,
+			Name:       fmt.Sprintf("package%d", i),
+			Version:    "1.0",
+			PackageURL: ref,
+		})
+		vulnerabilities = append(vulnerabilities, cyclonedx.Vulnerability{
+			ID:      fmt.Sprintf("CVE-2023-%d", i),
+			Affects: &[]cyclonedx.Affects{{Ref: ref}},
+		})
+
+		var dependsOn []string
+		if i+1 < packages {
+			dependsOn = []string{fmt.Sprintf("pkg:deb/debian/package%d@1.0", i+1)}
+		}
+		dependencies = append(dependencies, cyclonedx.Dependency{
+			Ref:          ref,
+			Dependencies: &dependsOn,
+		})
+	}
+
+	return &cyclonedx.BOM{
+		SerialNumber: "urn:uuid:large",
+		Metadata: &cyclonedx.Metadata{
+			Properties: &[]cyclonedx.Property{{Name: "aquasecurity:trivy:SchemaVersion", Value: "2"}},
+		},
+		Components:      &components,
+		Vulnerabilities: &vulnerabilities,
+		Dependencies:    &dependencies,
+	}
+}
+
+func TestSBOMLimits(t *testing.T) {
+	bom := newTestLargeBOM(10)
+
+	fiveComponentsSize := serializedSBOMSize(truncateSBOM(bom, 5))
+
+	tests := []struct {
+		name             string
+		limits           sbomLimits
+		expectedPackages int
+	}{
+		{
+			name:             "no limits",
+			limits:           sbomLimits{},
+			expectedPackages: 10,
+		},
+		{
+			name:             "within limits",
+			limits:           sbomLimits{maxPackages: 10, maxSize: serializedSBOMSize(bom)},
+			expectedPackages: 10,
+		},
+		{
+			name:             "over the package limit",
+			limits:           sbomLimits{maxPackages: 3},
+			expectedPackages: 3,
+		},
+		{
+			name:             "over the size limit",
+			limits:           sbomLimits{maxSize: fiveComponentsSize + 1},
+			expectedPackages: 5,
+		},
+		{
+			name:             "over both limits",
+			limits:           sbomLimits{maxPackages: 7, maxSize: fiveComponentsSize},
+			expectedPackages: 5,
+		},
+		{
+			name:             "size limit smaller than an empty SBOM",
+			limits:           sbomLimits{maxSize: 1},
+			expectedPackages: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limited, dropped := test.limits.apply(bom)
+			assert.Equal(t, 10-test.expectedPackages, dropped)
+			require.Len(t, *limited.Components, test.expectedPackages)
+			assert.Equal(t, (*bom.Components)[:test.expectedPackages], *limited.Components)
+
+			// The original SBOM is left untouched
+			assert.Len(t, *bom.Components, 10)
+			assert.Len(t, *bom.Metadata.Properties, 1)
+
+			if dropped == 0 {
+				assert.Same(t, bom, limited)
+				return
+			}
+
+			if test.limits.maxSize > 1 {
+				assert.LessOrEqual(t, serializedSBOMSize(limited), test.limits.maxSize)
+			}
+
+			assert.Equal(t, []cyclonedx.Property{
+				{Name: "aquasecurity:trivy:SchemaVersion", Value: "2"},
+				{Name: sbomTruncatedProperty, Value: "true"},
+				{Name: sbomDroppedPackagesProperty, Value: fmt.Sprint(dropped)},
+			}, *limited.Metadata.Properties)
+
+			// The vulnerabilities and dependencies of the dropped
+			// packages are dropped too
+			assert.Len(t, *limited.Vulnerabilities, test.expectedPackages)
+			require.Len(t, *limited.Dependencies, test.expectedPackages)
+			if test.expectedPackages > 0 {
+				lastDependency := (*limited.Dependencies)[test.expectedPackages-1]
+				assert.Empty(t, *lastDependency.Dependencies)
+			}
+		})
+	}
+}
+
+func TestLimitSBOMWarns(t *testing.T) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	l, err := seelog.LoggerFromWriterWithMinLevelAndFormat(w, seelog.DebugLvl, "[%LEVEL] %FuncShort: %Msg")
+	require.NoError(t, err)
+	previousLogger := log.Logger
+	log.SetupLogger(l, "debug")
+	t.Cleanup(func() { log.Logger = previousLogger })
+
+	c := collector{
+		sbomLimits: sbomLimits{maxPackages: 4},
+	}
+
+	limited := c.limitSBOM(newTestLargeBOM(10), "image default/large:latest (id sha256:large)")
+	assert.Len(t, *limited.Components, 4)
+
+	limited = c.limitSBOM(newTestLargeBOM(2), "image default/small:latest (id sha256:small)")
+	assert.Len(t, *limited.Components, 2)
+
+	w.Flush()
+	logs := b.String()
+	assert.Contains(t, logs, "[WARN] limitSBOM: SBOM of image default/large:latest (id sha256:large) is over the limits (4 packages, 0 bytes), dropping 6 of its 10 packages")
+	assert.NotContains(t, logs, "small")
+}
@@ -0,0 +1,220 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// ociLayoutIndexFile is the file listing the images of an OCI layout
+const ociLayoutIndexFile = "index.json"
+
+// errInvalidOCILayout is returned for the OCI layout directories that are
+// missing, or whose files or blobs are corrupt.
+var errInvalidOCILayout = errors.New("invalid OCI layout")
+
+// startOCILayoutScans starts scanning the images of the OCI layout
+// directories, one at a time. Each directory is scanned once. The scans stop
+// with the other scans, see stopSBOMScans.
+func (c *collector) startOCILayoutScans(directories []string) {
+	if len(directories) == 0 {
+		return
+	}
+
+	ctx := c.sbomScanContext()
+
+	c.sbomScanWorkers.Add(1)
+	go func() {
+		defer c.sbomScanWorkers.Done()
+
+		for _, directory := range directories {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err := c.scanOCILayout(ctx, directory); err != nil {
+				log.Warnf("error extracting SBOM for OCI layout %s, err: %s", directory, err)
+			}
+		}
+	}()
+}
+
+// scanOCILayout scans the images of the OCI layout directory, and notifies
+// them to the store with their SBOM. The images are identified by the digest
+// of their manifest.
+func (c *collector) scanOCILayout(ctx context.Context, directory string) error {
+	layoutImages, err := readOCILayout(directory)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range layoutImages {
+		if c.scanRateLimiter != nil {
+			if err := c.scanRateLimiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		bom, err := scanWithTimeout(ctx, scanningTimeout(), func(scanContext context.Context) (*cyclonedx.BOM, error) {
+			return c.trivyClient.ScanOCILayoutImage(scanContext, directory, image.ID)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			// The other images of the layout can still be scanned
+			log.Warnf("error extracting SBOM for image %s of OCI layout %s, err: %s", image.ID, directory, err)
+			continue
+		}
+
+		c.notifyImageWithBOM(image, c.processSBOM(ctx, bom, fmt.Sprintf("image %s of OCI layout %s", image.ID, directory)))
+	}
+
+	return nil
+}
+
+// readOCILayout returns the images of the OCI layout directory. The images
+// in nested indexes, like multi-platform images, are not read.
+func readOCILayout(directory string) ([]*workloadmeta.ContainerImageMetadata, error) {
+	layoutPath, err := layout.FromPath(directory)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: corrupt %s: %s", errInvalidOCILayout, directory, ociLayoutIndexFile, err)
+	}
+
+	var layoutImages []*workloadmeta.ContainerImageMetadata
+	for _, descriptor := range indexManifest.Manifests {
+		if !descriptor.MediaType.IsImage() {
+			log.Debugf("Skipping %s of OCI layout %s with media type %s", descriptor.Digest, directory, descriptor.MediaType)
+			continue
+		}
+
+		image, err := readOCILayoutImage(directory, index, descriptor)
+		if err != nil {
+			return nil, err
+		}
+		layoutImages = append(layoutImages, image)
+	}
+
+	if len(layoutImages) == 0 {
+		return nil, fmt.Errorf("%w %s: no image manifest in %s", errInvalidOCILayout, directory, ociLayoutIndexFile)
+	}
+
+	return layoutImages, nil
+}
+
+// readOCILayoutImage builds the image of the manifest from its manifest and
+// config blobs, checking that their content matches their digest.
+func readOCILayoutImage(directory string, index v1.ImageIndex, descriptor v1.Descriptor) (*workloadmeta.ContainerImageMetadata, error) {
+	img, err := index.Image(descriptor.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+	if err = checkOCILayoutBlob(directory, rawManifest, descriptor.Digest); err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: corrupt blob %s: %s", errInvalidOCILayout, directory, descriptor.Digest, err)
+	}
+
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+	if err = checkOCILayoutBlob(directory, rawConfig, manifest.Config.Digest); err != nil {
+		return nil, err
+	}
+
+	imageConfig, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: corrupt blob %s: %s", errInvalidOCILayout, directory, manifest.Config.Digest, err)
+	}
+
+	name := descriptor.Annotations[ocispec.AnnotationRefName]
+	if name == "" {
+		name = directory
+	}
+
+	totalSizeBytes := manifest.Config.Size
+	layers := make([]workloadmeta.ContainerImageLayer, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		totalSizeBytes += layer.Size
+		layers = append(layers, workloadmeta.ContainerImageLayer{
+			MediaType: string(layer.MediaType),
+			Digest:    layer.Digest.String(),
+			SizeBytes: layer.Size,
+			URLs:      layer.URLs,
+		})
+	}
+
+	return &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   descriptor.Digest.String(),
+		},
+		EntityMeta: workloadmeta.EntityMeta{
+			Name:   name,
+			Labels: imageConfig.Config.Labels,
+		},
+		MediaType:    string(descriptor.MediaType),
+		SizeBytes:    totalSizeBytes,
+		OS:           imageConfig.OS,
+		OSVersion:    imageConfig.OSVersion,
+		Architecture: imageConfig.Architecture,
+		Variant:      imageConfig.Variant,
+		Layers:       layers,
+	}, nil
+}
+
+// checkOCILayoutBlob checks that the content of a blob matches its digest.
+func checkOCILayoutBlob(directory string, content []byte, digest v1.Hash) error {
+	hash, _, err := v1.SHA256(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("%w %s: %s", errInvalidOCILayout, directory, err)
+	}
+
+	if hash != digest {
+		return fmt.Errorf("%w %s: the content of blob %s doesn't match its digest", errInvalidOCILayout, directory, digest)
+	}
+
+	return nil
+}
+
+func ociLayoutDirectories() []string {
+	return config.Datadog.GetStringSlice("container_image_collection.sbom.oci_layout.directories")
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+const (
+	testOCILayout           = "testdata/oci-layout"
+	testOCILayoutManifest   = "sha256:48311438b33e53e773f1901b18693e7df1c67b3e7e32e026931cdf74cf0d7f65"
+	testOCILayoutConfigBlob = "blobs/sha256/5d29d9e2ec1c0425ba9e29eadd376689b8913b2d68cb24a23493e9826b6443db"
+)
+
+// copyTestOCILayout copies the OCI layout fixture to a temporary directory,
+// to corrupt it.
+func copyTestOCILayout(t *testing.T) string {
+	dst := t.TempDir()
+
+	err := filepath.WalkDir(testOCILayout, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(testOCILayout, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), content, 0644)
+	})
+	require.NoError(t, err)
+
+	return dst
+}
+
+func TestReadOCILayout(t *testing.T) {
+	layoutImages, err := readOCILayout(testOCILayout)
+	require.NoError(t, err)
+	require.Len(t, layoutImages, 1)
+
+	image := layoutImages[0]
+	assert.Equal(t, workloadmeta.EntityID{Kind: workloadmeta.KindContainerImageMetadata, ID: testOCILayoutManifest}, image.EntityID)
+	assert.Equal(t, "datadog/app:1.0", image.Name)
+	assert.Equal(t, map[string]string{"team": "containers"}, image.Labels)
+	assert.Equal(t, "linux", image.OS)
+	assert.Equal(t, "amd64", image.Architecture)
+	assert.Equal(t, int64(193+139), image.SizeBytes)
+	require.Len(t, image.Layers, 1)
+	assert.Equal(t, "sha256:568abdce8bc28c41c30510ba63a5a41a0c52217e4d38f938419caaa7779c2178", image.Layers[0].Digest)
+}
+
+func TestReadInvalidOCILayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, directory string)
+	}{
+		{
+			name: "missing directory",
+			corrupt: func(t *testing.T, directory string) {
+				require.NoError(t, os.RemoveAll(directory))
+			},
+		},
+		{
+			name: "missing index",
+			corrupt: func(t *testing.T, directory string) {
+				require.NoError(t, os.Remove(filepath.Join(directory, ociLayoutIndexFile)))
+			},
+		},
+		{
+			name: "corrupt index",
+			corrupt: func(t *testing.T, directory string) {
+				require.NoError(t, os.WriteFile(filepath.Join(directory, ociLayoutIndexFile), []byte("{"), 0644))
+			},
+		},
+		{
+			name: "no image",
+			corrupt: func(t *testing.T, directory string) {
+				require.NoError(t, os.WriteFile(filepath.Join(directory, ociLayoutIndexFile), []byte(`{"schemaVersion":2,"manifests":[]}`), 0644))
+			},
+		},
+		{
+			name: "corrupt blob",
+			corrupt: func(t *testing.T, directory string) {
+				require.NoError(t, os.WriteFile(filepath.Join(directory, testOCILayoutConfigBlob), []byte(`{"os":"windows"}`), 0644))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := copyTestOCILayout(t)
+			test.corrupt(t, directory)
+
+			_, err := readOCILayout(directory)
+			assert.ErrorIs(t, err, errInvalidOCILayout)
+			assert.Contains(t, err.Error(), directory)
+		})
+	}
+}
+
+func TestScanOCILayout(t *testing.T) {
+	store := newFakeImageStore()
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:       store,
+		trivyClient: scanner,
+	}
+
+	require.NoError(t, c.scanOCILayout(context.Background(), testOCILayout))
+
+	select {
+	case event := <-store.events:
+		image := event.Entity.(*workloadmeta.ContainerImageMetadata)
+		assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+		assert.Equal(t, testOCILayoutManifest, image.ID)
+		assert.Equal(t, "datadog/app:1.0", image.Name)
+		require.NotNil(t, image.CycloneDXBOM)
+		assert.Equal(t, testOCILayoutManifest, image.CycloneDXBOM.SerialNumber)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for SBOM events")
+	}
+	assert.Equal(t, 1, scanner.scanCount(testOCILayout+"@"+testOCILayoutManifest))
+
+	// A missing layout is reported, without any scan
+	err := c.scanOCILayout(context.Background(), filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, errInvalidOCILayout)
+	assert.Empty(t, store.events)
+}
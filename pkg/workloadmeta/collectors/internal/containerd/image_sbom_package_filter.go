@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"strings"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// packageTypeOS is the type of the packages of the OS package managers
+const packageTypeOS = "os"
+
+// Types of the packages, by type of their package URL. The types of package
+// URLs that are not listed here are used as is.
+var packageTypesByPURLType = map[string]string{
+	"alpm":   packageTypeOS,
+	"apk":    packageTypeOS,
+	"deb":    packageTypeOS,
+	"rpm":    packageTypeOS,
+	"pypi":   "pip",
+	"golang": "go",
+	"maven":  "java",
+}
+
+// packageTypeFilter decides which packages are kept in the SBOMs, based on
+// their type, like "os", "gem", "pip", "npm", "go" or "java". A package is
+// dropped when its type is excluded, or when there are inclusions and its
+// type is not included. The components that are not packages, like the
+// operating system itself or the lock files, are always kept.
+type packageTypeFilter struct {
+	include map[string]struct{}
+	exclude map[string]struct{}
+}
+
+// newPackageTypeFilter returns a filter of the given package types, or nil
+// if there are none.
+func newPackageTypeFilter(include, exclude []string) *packageTypeFilter {
+	if len(include)+len(exclude) == 0 {
+		return nil
+	}
+
+	return &packageTypeFilter{
+		include: toPackageTypeSet(include),
+		exclude: toPackageTypeSet(exclude),
+	}
+}
+
+func packageTypeFilterFromConfig() *packageTypeFilter { // nolint: unused
+	return newPackageTypeFilter(
+		config.Datadog.GetStringSlice("container_image_collection.sbom.include_package_types"),
+		config.Datadog.GetStringSlice("container_image_collection.sbom.exclude_package_types"),
+	)
+}
+
+func toPackageTypeSet(packageTypes []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(packageTypes))
+	for _, packageType := range packageTypes {
+		set[strings.ToLower(packageType)] = struct{}{}
+	}
+	return set
+}
+
+// apply returns the SBOM without the packages that are filtered out, and
+// without their dependencies and vulnerabilities. The SBOM is returned as is
+// when no package is dropped. A nil filter keeps all the packages.
+func (f *packageTypeFilter) apply(bom *cyclonedx.BOM) *cyclonedx.BOM {
+	if f == nil || bom == nil || bom.Components == nil {
+		return bom
+	}
+
+	var kept, dropped []cyclonedx.Component
+	for _, component := range *bom.Components {
+		if f.keeps(component) {
+			kept = append(kept, component)
+		} else {
+			dropped = append(dropped, component)
+		}
+	}
+
+	if len(dropped) == 0 {
+		return bom
+	}
+
+	return withoutComponents(bom, kept, dropped)
+}
+
+func (f *packageTypeFilter) keeps(component cyclonedx.Component) bool {
+	packageType, isPackage := componentPackageType(component)
+	if !isPackage {
+		return true
+	}
+
+	if _, excluded := f.exclude[packageType]; excluded {
+		return false
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+
+	_, included := f.include[packageType]
+	return included
+}
+
+// componentPackageType returns the type of package of a component, found in
+// its package URL, and false if the component is not a package.
+func componentPackageType(component cyclonedx.Component) (string, bool) {
+	if !strings.HasPrefix(component.PackageURL, "pkg:") {
+		return "", false
+	}
+
+	purlType, _, found := strings.Cut(strings.TrimPrefix(component.PackageURL, "pkg:"), "/")
+	if !found || purlType == "" {
+		return "", false
+	}
+
+	purlType = strings.ToLower(purlType)
+	if packageType, found := packageTypesByPURLType[purlType]; found {
+		return packageType, true
+	}
+
+	return purlType, true
+}
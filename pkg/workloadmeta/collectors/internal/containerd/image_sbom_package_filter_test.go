@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPackage(purl string, name string) cyclonedx.Component {
+	return cyclonedx.Component{
+		BOMRef:     purl,
+		Type:       cyclonedx.ComponentTypeLibrary,
+		Name:       name,
+		PackageURL: purl,
+	}
+}
+
+func TestPackageTypeFilter(t *testing.T) {
+	debian := cyclonedx.Component{
+		BOMRef: "debian",
+		Type:   cyclonedx.ComponentTypeOS,
+		Name:   "debian",
+	}
+	curl := newTestPackage("pkg:deb/debian/curl@7.74.0", "curl")
+	openssl := newTestPackage("pkg:rpm/redhat/openssl@1.1.1", "openssl")
+	rails := newTestPackage("pkg:gem/rails@7.0.4", "rails")
+	requests := newTestPackage("pkg:pypi/requests@2.28.1", "requests")
+	lodash := newTestPackage("pkg:npm/lodash@4.17.20", "lodash")
+	cobra := newTestPackage("pkg:golang/github.com/spf13/cobra@v1.6.1", "cobra")
+
+	bom := &cyclonedx.BOM{
+		SerialNumber: "urn:uuid:packages",
+		Components:   &[]cyclonedx.Component{debian, curl, openssl, rails, requests, lodash, cobra},
+		Dependencies: &[]cyclonedx.Dependency{
+			{Ref: "debian", Dependencies: &[]string{curl.BOMRef, openssl.BOMRef}},
+			{Ref: lodash.BOMRef, Dependencies: &[]string{}},
+		},
+		Vulnerabilities: &[]cyclonedx.Vulnerability{
+			{ID: "CVE-2021-23337", Affects: &[]cyclonedx.Affects{{Ref: lodash.BOMRef}}},
+			{ID: "CVE-2022-32221", Affects: &[]cyclonedx.Affects{{Ref: curl.BOMRef}}},
+		},
+	}
+
+	tests := []struct {
+		name                    string
+		include                 []string
+		exclude                 []string
+		expectedComponents      []cyclonedx.Component
+		expectedVulnerabilities []string
+	}{
+		{
+			name:                    "no filter",
+			expectedComponents:      []cyclonedx.Component{debian, curl, openssl, rails, requests, lodash, cobra},
+			expectedVulnerabilities: []string{"CVE-2021-23337", "CVE-2022-32221"},
+		},
+		{
+			name:                    "os packages only",
+			include:                 []string{"os"},
+			expectedComponents:      []cyclonedx.Component{debian, curl, openssl},
+			expectedVulnerabilities: []string{"CVE-2022-32221"},
+		},
+		{
+			name:                    "language packages",
+			include:                 []string{"gem", "PIP", "npm"},
+			expectedComponents:      []cyclonedx.Component{debian, rails, requests, lodash},
+			expectedVulnerabilities: []string{"CVE-2021-23337"},
+		},
+		{
+			name:                    "excluded types",
+			exclude:                 []string{"npm", "go"},
+			expectedComponents:      []cyclonedx.Component{debian, curl, openssl, rails, requests},
+			expectedVulnerabilities: []string{"CVE-2022-32221"},
+		},
+		{
+			name:                    "exclusions take precedence",
+			include:                 []string{"os", "gem"},
+			exclude:                 []string{"gem"},
+			expectedComponents:      []cyclonedx.Component{debian, curl, openssl},
+			expectedVulnerabilities: []string{"CVE-2022-32221"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filtered := newPackageTypeFilter(test.include, test.exclude).apply(bom)
+			assert.Equal(t, test.expectedComponents, *filtered.Components)
+
+			var vulnerabilities []string
+			for _, vulnerability := range *filtered.Vulnerabilities {
+				vulnerabilities = append(vulnerabilities, vulnerability.ID)
+			}
+			assert.Equal(t, test.expectedVulnerabilities, vulnerabilities)
+
+			// The original SBOM is left untouched
+			assert.Len(t, *bom.Components, 7)
+			assert.Len(t, *bom.Vulnerabilities, 2)
+		})
+	}
+}
+
+func TestPackageTypeFilterDependencies(t *testing.T) {
+	curl := newTestPackage("pkg:deb/debian/curl@7.74.0", "curl")
+	lodash := newTestPackage("pkg:npm/lodash@4.17.20", "lodash")
+
+	bom := &cyclonedx.BOM{
+		Components: &[]cyclonedx.Component{curl, lodash},
+		Dependencies: &[]cyclonedx.Dependency{
+			{Ref: "image", Dependencies: &[]string{curl.BOMRef, lodash.BOMRef}},
+			{Ref: lodash.BOMRef, Dependencies: &[]string{}},
+		},
+	}
+
+	filtered := newPackageTypeFilter([]string{"os"}, nil).apply(bom)
+	assert.Equal(t, []cyclonedx.Dependency{
+		{Ref: "image", Dependencies: &[]string{curl.BOMRef}},
+	}, *filtered.Dependencies)
+
+	// Nothing is copied when all the packages are kept
+	assert.Same(t, bom, newPackageTypeFilter([]string{"os", "npm"}, nil).apply(bom))
+	assert.Nil(t, newPackageTypeFilter(nil, nil))
+}
@@ -0,0 +1,208 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+)
+
+const (
+	// rescanJitterDivisor defines the maximum jitter added to the rescan
+	// period, as a fraction of the period (1/10th).
+	rescanJitterDivisor = 10
+
+	// maxRescanCheckInterval is the maximum interval between two checks of
+	// the images due for a rescan.
+	maxRescanCheckInterval = time.Minute
+)
+
+type scheduledRescan struct {
+	image namespacedImage
+	at    time.Time
+}
+
+// sbomRescanner periodically sends the images that were scanned back to the
+// scan workers, because the vulnerabilities found in an image can change even
+// when the image doesn't.
+//
+// A random jitter is added to the rescan period of each image so that the
+// images scanned together at startup are not all rescanned at the same time.
+type sbomRescanner struct {
+	// Needed because this is accessed by the goroutine handling events, by
+	// the scan workers and by the rescan loop
+	mut       sync.Mutex
+	scheduled map[string]scheduledRescan // map image ID => rescan
+
+	period        time.Duration
+	checkInterval time.Duration
+	enqueue       func(namespacedImage)
+
+	// now and jitter can be overridden in tests
+	now    func() time.Time
+	jitter func(period time.Duration) time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newSBOMRescanner returns a rescanner sending the images to rescan to
+// enqueue, every period, or nil if period is not positive.
+func newSBOMRescanner(period time.Duration, enqueue func(namespacedImage)) *sbomRescanner {
+	if period <= 0 {
+		return nil
+	}
+
+	checkInterval := period / rescanJitterDivisor
+	if checkInterval > maxRescanCheckInterval {
+		checkInterval = maxRescanCheckInterval
+	}
+
+	return &sbomRescanner{
+		scheduled:     make(map[string]scheduledRescan),
+		period:        period,
+		checkInterval: checkInterval,
+		enqueue:       enqueue,
+		now:           time.Now,
+		jitter:        randomRescanJitter,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// start starts the loop sending the images due for a rescan to the scan
+// workers.
+func (r *sbomRescanner) start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, image := range r.popDue() {
+					r.enqueue(image)
+				}
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the rescan loop, and waits for it to exit, so that no image is
+// sent to the scan workers once it returns.
+func (r *sbomRescanner) stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// schedule schedules a rescan of the image after the rescan period, plus a
+// jitter, replacing any rescan already scheduled for it.
+func (r *sbomRescanner) schedule(image namespacedImage) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	image.rescan = true
+	r.scheduled[image.imageID] = scheduledRescan{
+		image: image,
+		at:    r.now().Add(r.period + r.jitter(r.period)),
+	}
+}
+
+// forget cancels the rescan of the image, if there's one scheduled.
+func (r *sbomRescanner) forget(imageID string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	delete(r.scheduled, imageID)
+}
+
+// popDue returns the images due for a rescan, and removes them from the
+// scheduled rescans. They are scheduled again once rescanned.
+func (r *sbomRescanner) popDue() []namespacedImage {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	now := r.now()
+
+	var due []namespacedImage
+	for imageID, rescan := range r.scheduled {
+		if !now.Before(rescan.at) {
+			due = append(due, rescan.image)
+			delete(r.scheduled, imageID)
+		}
+	}
+
+	return due
+}
+
+func randomRescanJitter(period time.Duration) time.Duration {
+	maxJitter := int64(period / rescanJitterDivisor)
+	if maxJitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(maxJitter))
+}
+
+// sbomContentHash returns a hash of the packages of the SBOM and of their
+// vulnerabilities, including the versions fixing them, used to find out
+// whether a rescan changed anything. It doesn't depend on the order of the
+// components, nor on the fields that change with every scan, like the serial
+// number and the timestamp.
+func sbomContentHash(bom *cyclonedx.BOM) string { // nolint: unused
+	var keys []string
+
+	if bom.Components != nil {
+		for _, component := range *bom.Components {
+			keys = append(keys, "component:"+componentKey(component))
+		}
+	}
+
+	if bom.Vulnerabilities != nil {
+		for _, vulnerability := range *bom.Vulnerabilities {
+			key := "vulnerability:" + vulnerability.ID
+			if vulnerability.Affects != nil {
+				for _, affects := range *vulnerability.Affects {
+					key += "," + affects.Ref
+					if affects.Range != nil {
+						for _, version := range *affects.Range {
+							key += "," + version.Version + ":" + string(version.Status)
+						}
+					}
+				}
+			}
+			// Where trivy reports the fixed version
+			if vulnerability.Recommendation != "" {
+				key += "," + vulnerability.Recommendation
+			}
+			if vulnerability.Ratings != nil {
+				for _, rating := range *vulnerability.Ratings {
+					key += "," + string(rating.Severity)
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	hash := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSBOMRescannerDisabled(t *testing.T) {
+	assert.Nil(t, newSBOMRescanner(0, func(namespacedImage) {}))
+}
+
+func TestSBOMRescannerSchedule(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rescanner := newSBOMRescanner(time.Hour, func(namespacedImage) {})
+	rescanner.now = func() time.Time { return now }
+	rescanner.jitter = func(period time.Duration) time.Duration { return period / 10 }
+
+	rescanner.schedule(namespacedImage{namespace: "default", imageID: "sha256:1"})
+
+	// Not due before the period plus the jitter
+	now = now.Add(65 * time.Minute)
+	assert.Empty(t, rescanner.popDue())
+
+	now = now.Add(time.Minute)
+	due := rescanner.popDue()
+	require.Len(t, due, 1)
+	assert.Equal(t, "sha256:1", due[0].imageID)
+	assert.True(t, due[0].rescan)
+
+	// Due images are not scheduled anymore until they're rescanned
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, rescanner.popDue())
+}
+
+func TestSBOMRescannerForget(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rescanner := newSBOMRescanner(time.Hour, func(namespacedImage) {})
+	rescanner.now = func() time.Time { return now }
+
+	rescanner.schedule(namespacedImage{namespace: "default", imageID: "sha256:1"})
+	rescanner.schedule(namespacedImage{namespace: "default", imageID: "sha256:2"})
+	rescanner.forget("sha256:1")
+
+	now = now.Add(2 * time.Hour)
+	due := rescanner.popDue()
+	require.Len(t, due, 1)
+	assert.Equal(t, "sha256:2", due[0].imageID)
+}
+
+func TestSBOMRescannerLoop(t *testing.T) {
+	const period = 50 * time.Millisecond
+
+	var mut sync.Mutex
+	var rescans []time.Time
+	rescanner := newSBOMRescanner(period, func(image namespacedImage) {
+		mut.Lock()
+		defer mut.Unlock()
+		rescans = append(rescans, time.Now())
+	})
+
+	scheduledAt := time.Now()
+	rescanner.schedule(namespacedImage{namespace: "default", imageID: "sha256:1"})
+	rescanner.start()
+
+	assert.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(rescans) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	rescanner.stop()
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.GreaterOrEqual(t, rescans[0].Sub(scheduledAt), period)
+}
+
+func TestRandomRescanJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		jitter := randomRescanJitter(time.Hour)
+		assert.GreaterOrEqual(t, jitter, time.Duration(0))
+		assert.Less(t, jitter, 6*time.Minute)
+	}
+
+	assert.Equal(t, time.Duration(0), randomRescanJitter(time.Nanosecond))
+}
+
+func TestSBOMContentHash(t *testing.T) {
+	curl := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "curl", Version: "7.74.0", PackageURL: "pkg:deb/debian/curl@7.74.0"}
+	libc := cyclonedx.Component{Type: cyclonedx.ComponentTypeLibrary, Name: "libc6", Version: "2.31-13", PackageURL: "pkg:deb/debian/libc6@2.31-13"}
+
+	newBOM := func(serialNumber string, components ...cyclonedx.Component) *cyclonedx.BOM {
+		bom := newTestBOM(serialNumber)
+		bom.Metadata = &cyclonedx.Metadata{Timestamp: serialNumber}
+		bom.Components = &components
+		return bom
+	}
+
+	hash := sbomContentHash(newBOM("urn:uuid:1", curl, libc))
+
+	// Stable across scans of the same packages, whatever their order
+	assert.Equal(t, hash, sbomContentHash(newBOM("urn:uuid:1", curl, libc)))
+	assert.Equal(t, hash, sbomContentHash(newBOM("urn:uuid:2", libc, curl)))
+
+	// Changes when a package is added, removed or updated
+	updatedCurl := curl
+	updatedCurl.Version = "7.88.0"
+	updatedCurl.PackageURL = "pkg:deb/debian/curl@7.88.0"
+	assert.NotEqual(t, hash, sbomContentHash(newBOM("urn:uuid:1", curl)))
+	assert.NotEqual(t, hash, sbomContentHash(newBOM("urn:uuid:1", updatedCurl, libc)))
+
+	// Changes when the vulnerabilities change
+	vulnerable := newBOM("urn:uuid:1", curl, libc)
+	vulnerable.Vulnerabilities = &[]cyclonedx.Vulnerability{{ID: "CVE-2023-23914"}}
+	assert.NotEqual(t, hash, sbomContentHash(vulnerable))
+	assert.Equal(t, sbomContentHash(vulnerable), sbomContentHash(vulnerable))
+
+	// Changes when a fix is released for a vulnerability
+	fixed := newBOM("urn:uuid:1", curl, libc)
+	fixed.Vulnerabilities = &[]cyclonedx.Vulnerability{
+		{
+			ID:             "CVE-2023-23914",
+			Recommendation: "Upgrade curl to version 7.88.0",
+		},
+	}
+	assert.NotEqual(t, sbomContentHash(vulnerable), sbomContentHash(fixed))
+
+	fixedRange := newBOM("urn:uuid:1", curl, libc)
+	fixedRange.Vulnerabilities = &[]cyclonedx.Vulnerability{
+		{
+			ID: "CVE-2023-23914",
+			Affects: &[]cyclonedx.Affects{
+				{
+					Ref: curl.PackageURL,
+					Range: &[]cyclonedx.AffectedVersions{
+						{Version: "7.74.0", Status: cyclonedx.VulnerabilityStatusAffected},
+						{Version: "7.88.0", Status: cyclonedx.VulnerabilityStatusNotAffected},
+					},
+				},
+			},
+		},
+	}
+	unfixedRange := newBOM("urn:uuid:1", curl, libc)
+	unfixedRange.Vulnerabilities = &[]cyclonedx.Vulnerability{
+		{
+			ID: "CVE-2023-23914",
+			Affects: &[]cyclonedx.Affects{
+				{
+					Ref: curl.PackageURL,
+					Range: &[]cyclonedx.AffectedVersions{
+						{Version: "7.74.0", Status: cyclonedx.VulnerabilityStatusAffected},
+					},
+				},
+			},
+		},
+	}
+	assert.NotEqual(t, sbomContentHash(unfixedRange), sbomContentHash(fixedRange))
+}
@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+
+	"github.com/DataDog/datadog-agent/pkg/util/backoff"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// retryMinBackoffFactor makes the backoff after n failed attempts a random
+// duration between baseBackoff*2^(n-1) and baseBackoff*2^n, capped to
+// maxBackoff.
+const retryMinBackoffFactor = 2
+
+// sbomScanRetrier sends the images whose scan failed back to the scan
+// workers after an exponential backoff, because some failures are transient,
+// like an image whose layers are not unpacked yet, or a registry hiccup.
+//
+// Images are retried until maxAttempts scans have been attempted. Failures
+// that would happen again on every attempt, like unsupported image formats,
+// are not retried.
+//
+// The images are not retried by sleeping in the scan workers, so that a
+// failing image doesn't delay the scans of the other images.
+type sbomScanRetrier struct {
+	// Needed because this is accessed by the goroutine handling events, by
+	// the scan workers and by the retry timers
+	mut     sync.Mutex
+	pending map[string]*time.Timer // map image ID => pending retry
+	stopped bool
+
+	maxAttempts int
+	enqueue     func(namespacedImage)
+
+	// backoff returns the duration to wait after the given number of failed
+	// attempts. It can be overridden in tests.
+	backoff func(failedAttempts int) time.Duration
+}
+
+// newSBOMScanRetrier returns a retrier sending the images to retry to
+// enqueue, or nil if maxAttempts doesn't allow any retry.
+func newSBOMScanRetrier(maxAttempts int, baseBackoff time.Duration, maxBackoff time.Duration, enqueue func(namespacedImage)) *sbomScanRetrier {
+	if maxAttempts <= 1 {
+		return nil
+	}
+
+	policy := backoff.NewPolicy(retryMinBackoffFactor, baseBackoff.Seconds(), maxBackoff.Seconds(), 0, false)
+
+	return &sbomScanRetrier{
+		pending:     make(map[string]*time.Timer),
+		maxAttempts: maxAttempts,
+		enqueue:     enqueue,
+		backoff:     policy.GetBackoffDuration,
+	}
+}
+
+// retry schedules a new scan of an image whose scan failed with err. It
+// returns false, and logs the failure, when the image won't be retried
+// because the failure is not transient or because it was already attempted
+// maxAttempts times.
+func (r *sbomScanRetrier) retry(image namespacedImage, err error) bool {
+	image.failedAttempts++
+
+	if !isRetryableScanError(err) {
+		log.Warnf("SBOM scan of image: %s/%s (id %s) failed with a non-retryable error, giving up: %s", image.namespace, image.image.Name(), image.imageID, err)
+		return false
+	}
+
+	if image.failedAttempts >= r.maxAttempts {
+		log.Errorf("SBOM scan of image: %s/%s (id %s) failed %d times, giving up: %s", image.namespace, image.image.Name(), image.imageID, image.failedAttempts, err)
+		return false
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.stopped {
+		return false
+	}
+
+	delay := r.backoff(image.failedAttempts)
+	log.Debugf("SBOM scan of image: %s/%s (id %s) failed (attempt %d/%d), retrying in %s: %s", image.namespace, image.image.Name(), image.imageID, image.failedAttempts, r.maxAttempts, delay, err)
+
+	if timer, found := r.pending[image.imageID]; found {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+
+		// The retry might have been cancelled, or replaced, after the timer
+		// fired.
+		if r.stopped || r.pending[image.imageID] != timer {
+			return
+		}
+
+		delete(r.pending, image.imageID)
+		r.enqueue(image)
+	})
+	r.pending[image.imageID] = timer
+
+	return true
+}
+
+// forget cancels the retry of the image, if there's one pending.
+func (r *sbomScanRetrier) forget(imageID string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if timer, found := r.pending[imageID]; found {
+		timer.Stop()
+		delete(r.pending, imageID)
+	}
+}
+
+// stop cancels all the pending retries. No image is sent to the scan workers
+// once it returns.
+func (r *sbomScanRetrier) stop() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.stopped = true
+	for imageID, timer := range r.pending {
+		timer.Stop()
+		delete(r.pending, imageID)
+	}
+}
+
+// isRetryableScanError returns whether a scan that failed with err might
+// succeed if attempted again. Parsing errors and unsupported images fail the
+// same way on every attempt.
+func isRetryableScanError(err error) bool {
+	if errdefs.IsInvalidArgument(err) || errdefs.IsNotImplemented(err) {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(err.Error()), "unsupported") {
+		return false
+	}
+
+	return scanFailureReason(err) != scanFailureParseError
+}
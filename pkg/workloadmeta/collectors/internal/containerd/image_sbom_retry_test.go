@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSBOMScanRetrier(maxAttempts int) (*sbomScanRetrier, chan namespacedImage) {
+	retried := make(chan namespacedImage, 10)
+	retrier := newSBOMScanRetrier(maxAttempts, time.Second, time.Minute, func(image namespacedImage) {
+		retried <- image
+	})
+	retrier.backoff = func(int) time.Duration { return time.Millisecond }
+
+	return retrier, retried
+}
+
+func newTestImageToScan(imageID string) namespacedImage {
+	return namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: imageID,
+	}
+}
+
+func TestNewSBOMScanRetrier(t *testing.T) {
+	assert.Nil(t, newSBOMScanRetrier(0, time.Second, time.Minute, nil))
+	assert.Nil(t, newSBOMScanRetrier(1, time.Second, time.Minute, nil))
+
+	retrier := newSBOMScanRetrier(5, 10*time.Second, time.Minute, nil)
+	require.NotNil(t, retrier)
+
+	// The backoff grows exponentially, up to the maximum
+	for failedAttempts, bounds := range map[int][2]time.Duration{
+		1: {10 * time.Second, 20 * time.Second},
+		2: {20 * time.Second, 40 * time.Second},
+		3: {time.Minute, time.Minute},
+		4: {time.Minute, time.Minute},
+	} {
+		backoff := retrier.backoff(failedAttempts)
+		assert.GreaterOrEqual(t, backoff, bounds[0], "failed attempts: %d", failedAttempts)
+		assert.LessOrEqual(t, backoff, bounds[1], "failed attempts: %d", failedAttempts)
+	}
+}
+
+func TestSBOMScanRetrierRetriesUntilMaxAttempts(t *testing.T) {
+	retrier, retried := newTestSBOMScanRetrier(3)
+	err := fmt.Errorf("registry unavailable: %w", context.DeadlineExceeded)
+
+	image := newTestImageToScan("sha256:1")
+	for attempt := 1; attempt < 3; attempt++ {
+		require.True(t, retrier.retry(image, err))
+
+		select {
+		case image = <-retried:
+			assert.Equal(t, "sha256:1", image.imageID)
+			assert.Equal(t, attempt, image.failedAttempts)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for retry")
+		}
+	}
+
+	// The third failed attempt is the last one
+	assert.False(t, retrier.retry(image, err))
+	assert.Empty(t, retried)
+}
+
+func TestSBOMScanRetrierDoesNotRetryDeterministicFailures(t *testing.T) {
+	retrier, retried := newTestSBOMScanRetrier(3)
+
+	for _, err := range []error{
+		errors.New("unsupported image format"),
+		fmt.Errorf("invalid media type: %w", errdefs.ErrInvalidArgument),
+		fmt.Errorf("error decoding image config: %w", &json.SyntaxError{}),
+	} {
+		assert.False(t, retrier.retry(newTestImageToScan("sha256:1"), err), "error: %s", err)
+	}
+
+	assert.Empty(t, retried)
+}
+
+func TestSBOMScanRetrierForgetAndStop(t *testing.T) {
+	retrier, retried := newTestSBOMScanRetrier(3)
+	retrier.backoff = func(int) time.Duration { return 50 * time.Millisecond }
+	err := errors.New("layer not unpacked yet")
+
+	require.True(t, retrier.retry(newTestImageToScan("sha256:1"), err))
+	require.True(t, retrier.retry(newTestImageToScan("sha256:2"), err))
+
+	// Deleted images are not retried
+	retrier.forget("sha256:1")
+
+	select {
+	case image := <-retried:
+		assert.Equal(t, "sha256:2", image.imageID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for retry")
+	}
+
+	// Nothing is retried once stopped
+	require.True(t, retrier.retry(newTestImageToScan("sha256:3"), err))
+	retrier.stop()
+	assert.False(t, retrier.retry(newTestImageToScan("sha256:4"), err))
+
+	select {
+	case image := <-retried:
+		assert.Failf(t, "unexpected retry", "%+v", image)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/status/health"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+)
+
+const (
+	// Name of SBOM collection in the health checks of the agent
+	sbomHealthName = "workloadmeta-containerd-sbom"
+
+	// Maximum time given to the scan of the self-test
+	sbomSelfTestTimeout = time.Minute
+)
+
+// Content of the filesystem scanned by the self-test. It's enough for trivy
+// to detect an OS, without packages.
+const sbomSelfTestOSRelease = "ID=alpine\nVERSION_ID=3.17.0\n"
+
+// Statuses of the self-test reported in the status of the agent
+const (
+	sbomSelfTestRunning = "Running"
+	sbomSelfTestPassed  = "Passed"
+	sbomSelfTestFailed  = "Failed"
+)
+
+// sbomExpvars are the stats of SBOM collection shown in the status of the
+// agent. They're published when SBOM collection starts, so that the status
+// has no SBOM section when it's disabled.
+var (
+	sbomExpvars        = new(expvar.Map)
+	sbomExpvarsPublish sync.Once
+	sbomSelfTestStatus = new(expvar.String)
+	sbomSelfTestError  = new(expvar.String)
+)
+
+// sbomSelfTest checks that the scanner works by scanning a tiny filesystem
+// when SBOM collection starts. Its result is reported in the status of the
+// agent and in its health, with the reason why it failed, if it did. SBOM
+// collection is an optional component of the health checks: a failed
+// self-test reports it as degraded, and doesn't keep the agent from becoming
+// ready.
+type sbomSelfTest struct {
+	health *health.Handle
+
+	mut  sync.Mutex
+	done bool
+	err  error
+}
+
+// startSBOMSelfTest registers the health check and runs the self-test in the
+// background, so that the collector doesn't wait for it. When prepare is not
+// nil, it runs first, and the self-test fails if it fails. The check is
+// deregistered when the context is cancelled.
+func (c *collector) startSBOMSelfTest(ctx context.Context, prepare func(context.Context) error) *sbomSelfTest { // nolint: unused
+	sbomExpvarsPublish.Do(func() {
+		sbomExpvars.Set("SelfTestStatus", sbomSelfTestStatus)
+		sbomExpvars.Set("SelfTestError", sbomSelfTestError)
+		expvar.Publish("sbom", sbomExpvars)
+	})
+
+	sbomSelfTestStatus.Set(sbomSelfTestRunning)
+	sbomSelfTestError.Set("")
+
+	selfTest := &sbomSelfTest{
+		health: health.RegisterOptional(sbomHealthName),
+	}
+
+	go selfTest.run(ctx, prepare, c.trivyClient)
+
+	return selfTest
+}
+
+func (t *sbomSelfTest) run(ctx context.Context, prepare func(context.Context) error, scanner trivy.Collector) { // nolint: unused
+	defer func() {
+		_ = t.health.Deregister()
+	}()
+
+	var err error
+	if prepare != nil {
+		err = prepare(ctx)
+	}
+	if err == nil {
+		err = runSBOMSelfTest(ctx, scanner)
+	}
+	if ctx.Err() != nil {
+		// Stopped before the end of the self-test
+		return
+	}
+
+	if err != nil {
+		log.Errorf("SBOM collection self-test failed, reporting it as unhealthy: %s", err)
+		_ = t.health.SetUnhealthy(fmt.Sprintf("self-test failed: %s", err))
+	} else {
+		log.Debugf("SBOM collection self-test passed")
+	}
+
+	t.setResult(err)
+
+	for {
+		select {
+		case <-t.health.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSBOMSelfTest scans a tiny filesystem and checks that it gets an SBOM.
+func runSBOMSelfTest(ctx context.Context, scanner trivy.Collector) error { // nolint: unused
+	if scanner == nil {
+		return errors.New("the scanner is not initialized")
+	}
+
+	root, err := os.MkdirTemp("", "sbom-self-test-*")
+	if err != nil {
+		return fmt.Errorf("error creating the filesystem to scan: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(root); err != nil {
+			log.Warnf("error removing the filesystem scanned by the SBOM self-test %s: %s", root, err)
+		}
+	}()
+
+	if err = os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		return fmt.Errorf("error creating the filesystem to scan: %w", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte(sbomSelfTestOSRelease), 0644); err != nil {
+		return fmt.Errorf("error creating the filesystem to scan: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sbomSelfTestTimeout)
+	defer cancel()
+
+	bom, err := scanner.ScanFilesystem(ctx, root)
+	if err != nil {
+		return fmt.Errorf("error scanning a test filesystem: %w", err)
+	}
+
+	if bom == nil {
+		return errors.New("the scan of a test filesystem returned no SBOM")
+	}
+
+	return nil
+}
+
+func (t *sbomSelfTest) setResult(err error) { // nolint: unused
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.done = true
+	t.err = err
+
+	if err != nil {
+		sbomSelfTestStatus.Set(sbomSelfTestFailed)
+		sbomSelfTestError.Set(err.Error())
+	} else {
+		sbomSelfTestStatus.Set(sbomSelfTestPassed)
+	}
+}
+
+// result returns whether the self-test has finished and, if it has, the
+// reason why it failed.
+func (t *sbomSelfTest) result() (bool, error) { // nolint: unused
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return t.done, t.err
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/status/health"
+)
+
+func waitForSBOMSelfTest(t *testing.T, selfTest *sbomSelfTest) error {
+	var err error
+	require.Eventually(t, func() bool {
+		var done bool
+		done, err = selfTest.result()
+		return done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return err
+}
+
+func TestSBOMSelfTestPasses(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := collector{
+		trivyClient: &fakeScanner{scans: make(map[string]int)},
+	}
+
+	selfTest := c.startSBOMSelfTest(ctx, nil)
+	assert.NoError(t, waitForSBOMSelfTest(t, selfTest))
+
+	assert.Equal(t, sbomSelfTestPassed, sbomSelfTestStatus.Value())
+	assert.Empty(t, sbomSelfTestError.Value())
+	assert.Equal(t, sbomExpvars, expvar.Get("sbom"))
+
+	// The health check is read, so it turns healthy
+	require.Eventually(t, func() bool {
+		return len(selfTest.health.C) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSBOMSelfTestFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := collector{
+		trivyClient: &failingScanner{err: errors.New("trivy DB not loaded")},
+	}
+
+	selfTest := c.startSBOMSelfTest(ctx, nil)
+	err := waitForSBOMSelfTest(t, selfTest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "trivy DB not loaded")
+
+	// The reason is reported in the status of the agent
+	assert.Equal(t, sbomSelfTestFailed, sbomSelfTestStatus.Value())
+	assert.Contains(t, sbomSelfTestError.Value(), "trivy DB not loaded")
+
+	// And in its health, where SBOM collection is degraded without making
+	// the agent unready
+	assertSBOMHealthDegraded(t, "trivy DB not loaded")
+}
+
+// assertSBOMHealthDegraded checks that SBOM collection is reported as
+// degraded in the health of the agent, for a reason containing reason.
+func assertSBOMHealthDegraded(t *testing.T, reason string) {
+	status := health.GetReady()
+	assert.Contains(t, status.Degraded, sbomHealthName)
+	assert.NotContains(t, status.Unhealthy, sbomHealthName)
+	assert.Contains(t, status.Reasons[sbomHealthName], reason)
+}
+
+func TestSBOMSelfTestWithoutScanner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := collector{}
+
+	selfTest := c.startSBOMSelfTest(ctx, nil)
+	assert.Error(t, waitForSBOMSelfTest(t, selfTest))
+}
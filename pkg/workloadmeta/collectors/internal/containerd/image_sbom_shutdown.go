@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// sbomScanContext returns the context of the image and container scans,
+// creating it when the first workers start. It's cancelled by stopSBOMScans.
+// Not thread-safe, the workers are started by the goroutine starting the
+// collector.
+func (c *collector) sbomScanContext() context.Context { // nolint: unused
+	if c.sbomScansCtx == nil {
+		c.sbomScansCtx, c.cancelSBOMScans = context.WithCancel(context.Background())
+	}
+
+	return c.sbomScansCtx
+}
+
+// stopSBOMScans stops the image and container scan workers. Nothing can be
+// sent to the scan queues once it's called, so the goroutines sending to them
+// must have stopped, apart from the ones triggering scans, which check that
+// the queues are still open. The workers keep scanning what was already
+// queued for at most timeout, so that the scans in progress can finish and
+// notify the store. Then the scans still running are cancelled and the
+// queued ones are dropped.
+func (c *collector) stopSBOMScans(timeout time.Duration) {
+	c.closeScanQueues()
+
+	if c.cancelSBOMScans == nil {
+		return
+	}
+	defer c.cancelSBOMScans()
+
+	done := make(chan struct{})
+	go func() {
+		c.sbomScanWorkers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Debugf("All the SBOM scans finished before shutdown")
+	case <-time.After(timeout):
+		log.Infof("SBOM scans still in progress after %s, cancelling them", timeout)
+	}
+}
+
+// closeScanQueues closes the scan queues, waiting for the scans that are
+// being triggered, see TriggerScan.
+func (c *collector) closeScanQueues() {
+	c.scanQueuesMut.Lock()
+	defer c.scanQueuesMut.Unlock()
+
+	if c.imagesToScan != nil {
+		close(c.imagesToScan)
+	}
+
+	if c.containersToScan != nil {
+		close(c.containersToScan)
+	}
+
+	c.scanQueuesClosed = true
+}
+
+func sbomShutdownTimeout() time.Duration {
+	return time.Duration(config.Datadog.GetInt("container_image_collection.sbom.shutdown_timeout")) * time.Second
+}
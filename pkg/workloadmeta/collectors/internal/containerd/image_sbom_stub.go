@@ -8,6 +8,33 @@
 
 package containerd
 
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// sbomUnavailableWarning makes sure that the warning about SBOM collection
+// being unavailable is logged only once, even if the collector is restarted.
+var sbomUnavailableWarning sync.Once
+
 func (c *collector) startSBOMCollection() error {
-	return nil
+	if !sbomCollectionIsEnabled() {
+		return nil
+	}
+
+	sbomUnavailableWarning.Do(func() {
+		log.Warnf("%s: no SBOM will be collected for containerd images", errSBOMCollectionUnavailable)
+	})
+
+	return errSBOMCollectionUnavailable
+}
+
+// TriggerScan can't scan the image, SBOM collection is not available in this
+// build.
+func (c *collector) TriggerScan(imageID string) error {
+	return errSBOMCollectionUnavailable
 }
+
+// dropScan has nothing to do, there are no scans in this build.
+func (c *collector) dropScan(namespacedImage) {}
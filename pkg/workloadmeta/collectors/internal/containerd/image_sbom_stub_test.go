@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && !trivy
+// +build containerd,!trivy
+
+package containerd
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+func TestStartSBOMCollectionUnavailable(t *testing.T) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	l, err := seelog.LoggerFromWriterWithMinLevelAndFormat(w, seelog.WarnLvl, "[%LEVEL] %Msg\n")
+	require.NoError(t, err)
+	previousLogger := log.Logger
+	log.SetupLogger(l, "warn")
+	t.Cleanup(func() { log.Logger = previousLogger })
+
+	// Discard the logs of the other tests, which are buffered until the
+	// logger is set up
+	w.Flush()
+	b.Reset()
+
+	c := collector{}
+
+	t.Run("SBOM not configured", func(t *testing.T) {
+		config.Mock(t)
+
+		assert.NoError(t, c.startSBOMCollection())
+		w.Flush()
+		assert.NotContains(t, b.String(), "SBOM")
+	})
+
+	t.Run("SBOM configured", func(t *testing.T) {
+		cfg := config.Mock(t)
+		cfg.Set("container_image_collection.metadata.enabled", true)
+		cfg.Set("container_image_collection.sbom.enabled", true)
+
+		// The warning is logged only the first time, whether or not other
+		// tests made the collector start before
+		sbomUnavailableWarning = sync.Once{}
+		for i := 0; i < 2; i++ {
+			assert.ErrorIs(t, c.startSBOMCollection(), errSBOMCollectionUnavailable)
+		}
+		w.Flush()
+
+		assert.Equal(t, 1, strings.Count(b.String(), "[WARN] SBOM collection is enabled but is not available in this build of the agent"))
+	})
+}
@@ -0,0 +1,191 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+const telemetrySubsystem = "workloadmeta"
+
+// Coarse categories of SBOM scan failures, used as the "reason" tag of the
+// failure counter
+const (
+	scanFailureTimeout    = "timeout"
+	scanFailurePullError  = "pull-error"
+	scanFailureParseError = "parse-error"
+	scanFailureOther      = "other"
+)
+
+// Sources of the SBOMs, used as the "source" tag of the success counter
+const (
+	sbomSourceScan  = "scan"
+	sbomSourceCache = "cache"
+)
+
+// telemetryProvider creates metrics. It's implemented by the telemetry
+// component (comp/core/telemetry).
+type telemetryProvider interface {
+	NewCounter(subsystem, name string, tags []string, help string) telemetry.Counter
+	NewGauge(subsystem, name string, tags []string, help string) telemetry.Gauge
+	NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) telemetry.Histogram
+}
+
+// pkgTelemetryProvider creates the metrics in the registry of pkg/telemetry,
+// which the telemetry component wraps. It's used because the workloadmeta
+// collectors are not components and can't depend on the telemetry component.
+type pkgTelemetryProvider struct{}
+
+var pkgTelemetryOpts = telemetry.Options{NoDoubleUnderscoreSep: true}
+
+func (pkgTelemetryProvider) NewCounter(subsystem, name string, tags []string, help string) telemetry.Counter {
+	return telemetry.NewCounterWithOpts(subsystem, name, tags, help, pkgTelemetryOpts)
+}
+
+func (pkgTelemetryProvider) NewGauge(subsystem, name string, tags []string, help string) telemetry.Gauge {
+	return telemetry.NewGaugeWithOpts(subsystem, name, tags, help, pkgTelemetryOpts)
+}
+
+func (pkgTelemetryProvider) NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) telemetry.Histogram {
+	return telemetry.NewHistogramWithOpts(subsystem, name, tags, help, buckets, pkgTelemetryOpts)
+}
+
+// sbomTelemetry holds the metrics about the SBOM scans of containerd images.
+// Its methods do nothing on a nil sbomTelemetry.
+type sbomTelemetry struct {
+	scanDuration telemetry.Histogram
+	scanSuccess  telemetry.Counter
+	scanFailures telemetry.Counter
+	queuedScans  telemetry.Gauge
+
+	// Heartbeat of the rescans that didn't change the SBOM, which are not
+	// notified to workloadmeta
+	unchangedRescans telemetry.Counter
+}
+
+func newSBOMTelemetry(provider telemetryProvider) *sbomTelemetry {
+	return &sbomTelemetry{
+		scanDuration: provider.NewHistogram(
+			telemetrySubsystem,
+			"containerd_sbom_scan_duration",
+			[]string{},
+			"Duration of the SBOM scans of containerd images, in seconds.",
+			[]float64{1, 5, 10, 30, 60, 120, 300, 600},
+		),
+		scanSuccess: provider.NewCounter(
+			telemetrySubsystem,
+			"containerd_sbom_scan_success",
+			[]string{"source"},
+			"Number of SBOMs of containerd images successfully extracted, by source (scan or cache).",
+		),
+		scanFailures: provider.NewCounter(
+			telemetrySubsystem,
+			"containerd_sbom_scan_failures",
+			[]string{"reason"},
+			"Number of failed SBOM scans of containerd images, by reason.",
+		),
+		queuedScans: provider.NewGauge(
+			telemetrySubsystem,
+			"containerd_sbom_queued_scans",
+			[]string{},
+			"Number of containerd images waiting for an SBOM scan.",
+		),
+		unchangedRescans: provider.NewCounter(
+			telemetrySubsystem,
+			"containerd_sbom_unchanged_rescans",
+			[]string{},
+			"Number of rescans of containerd images that didn't change their SBOM.",
+		),
+	}
+}
+
+var (
+	defaultSBOMTelemetry     *sbomTelemetry
+	defaultSBOMTelemetryOnce sync.Once
+)
+
+// getDefaultSBOMTelemetry returns the metrics registered with pkg/telemetry.
+// They are created only once, as metrics can't be registered twice, even if
+// the collector is restarted.
+func getDefaultSBOMTelemetry() *sbomTelemetry {
+	defaultSBOMTelemetryOnce.Do(func() {
+		defaultSBOMTelemetry = newSBOMTelemetry(pkgTelemetryProvider{})
+	})
+	return defaultSBOMTelemetry
+}
+
+// observeScan records the result of a scan that took the given duration.
+func (t *sbomTelemetry) observeScan(duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.scanDuration.Observe(duration.Seconds())
+	if err != nil {
+		t.scanFailures.Inc(scanFailureReason(err))
+	} else {
+		t.scanSuccess.Inc(sbomSourceScan)
+	}
+}
+
+// observeCacheHit records an SBOM served from the cache.
+func (t *sbomTelemetry) observeCacheHit() {
+	if t == nil {
+		return
+	}
+
+	t.scanSuccess.Inc(sbomSourceCache)
+}
+
+// setQueuedScans records the number of images waiting for a scan.
+func (t *sbomTelemetry) setQueuedScans(queued int) {
+	if t == nil {
+		return
+	}
+
+	t.queuedScans.Set(float64(queued))
+}
+
+// observeUnchangedRescan records a rescan that didn't change the SBOM.
+func (t *sbomTelemetry) observeUnchangedRescan() {
+	if t == nil {
+		return
+	}
+
+	t.unchangedRescans.Inc()
+}
+
+// scanFailureReason returns the category of a scan failure. Trivy doesn't
+// always wrap the underlying errors, that's why the message is also checked.
+func scanFailureReason(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || strings.Contains(msg, "deadline exceeded") || strings.Contains(msg, "timeout"):
+		return scanFailureTimeout
+	case errdefs.IsNotFound(err) || errdefs.IsUnavailable(err) || strings.Contains(msg, "pull") || strings.Contains(msg, "content digest") || strings.Contains(msg, "not found"):
+		return scanFailurePullError
+	case errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || strings.Contains(msg, "parse") || strings.Contains(msg, "unmarshal") || strings.Contains(msg, "decode"):
+		return scanFailureParseError
+	default:
+		return scanFailureOther
+	}
+}
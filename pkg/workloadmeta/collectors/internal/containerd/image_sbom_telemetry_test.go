@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestScanFailureReason(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+
+	tests := []struct {
+		err    error
+		reason string
+	}{
+		{err: fmt.Errorf("scan: %w", context.DeadlineExceeded), reason: scanFailureTimeout},
+		{err: errors.New("unable to inspect the image: context deadline exceeded"), reason: scanFailureTimeout},
+		{err: fmt.Errorf("blob: %w", errdefs.ErrNotFound), reason: scanFailurePullError},
+		{err: errors.New("failed to pull layer"), reason: scanFailurePullError},
+		{err: fmt.Errorf("config: %w", syntaxErr), reason: scanFailureParseError},
+		{err: errors.New("unable to parse the image config"), reason: scanFailureParseError},
+		{err: errors.New("boom"), reason: scanFailureOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			assert.Equal(t, test.reason, scanFailureReason(test.err))
+		})
+	}
+}
+
+func TestSBOMTelemetry(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+		sbomTelemetry := newSBOMTelemetry(tel)
+
+		sbomTelemetry.observeScan(2*time.Second, nil)
+		sbomTelemetry.observeScan(time.Second, context.DeadlineExceeded)
+		sbomTelemetry.observeCacheHit()
+		sbomTelemetry.setQueuedScans(3)
+		sbomTelemetry.observeUnchangedRescan()
+
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_success", sbomSourceScan))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_success", sbomSourceCache))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_failures", scanFailureTimeout))
+		assert.Equal(t, 3.0, mock.Value(telemetrySubsystem, "containerd_sbom_queued_scans"))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_unchanged_rescans"))
+		assert.ElementsMatch(t, []float64{2, 1}, mock.Observations(telemetrySubsystem, "containerd_sbom_scan_duration"))
+	})
+
+	// A nil sbomTelemetry does nothing
+	var nilTelemetry *sbomTelemetry
+	nilTelemetry.observeScan(time.Second, nil)
+	nilTelemetry.observeCacheHit()
+	nilTelemetry.setQueuedScans(1)
+	nilTelemetry.observeUnchangedRescan()
+}
@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"errors"
+	"fmt"
+
+	agentErrors "github.com/DataDog/datadog-agent/pkg/errors"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// TriggerScan sends the image with the given ID to the scan workers right
+// away, without waiting for its next rescan, like after the disclosure of a
+// vulnerability. The scan bypasses the SBOM cache and the deduplication of the
+// scans, so the image is scanned even if it already has an SBOM. It returns a
+// not found error when the image is unknown, and an error when the scan
+// can't be queued. It's safe to call from any goroutine.
+func (c *collector) TriggerScan(imageID string) error {
+	if c.imagesToScan == nil {
+		return errors.New("SBOM collection is not enabled")
+	}
+
+	reference, found := c.knownImages.getReference(imageID, "")
+	if !found {
+		return agentErrors.NewNotFound(fmt.Sprintf("image %s", imageID))
+	}
+
+	img, err := c.containerdClient.Image(reference.namespace, reference.name)
+	if err != nil {
+		return fmt.Errorf("error getting image %s/%s: %w", reference.namespace, reference.name, err)
+	}
+
+	// The scan is a rescan, so that it's not skipped as a duplicate, nor
+	// served from the cache
+	imageToScan := namespacedImage{
+		namespace: reference.namespace,
+		image:     img,
+		imageID:   imageID,
+		rescan:    true,
+	}
+
+	c.scanQueuesMut.Lock()
+	defer c.scanQueuesMut.Unlock()
+
+	if c.scanQueuesClosed {
+		return errors.New("SBOM scans are stopped")
+	}
+
+	select {
+	case c.imagesToScan <- imageToScan:
+		c.sbomTelemetry.setQueuedScans(len(c.imagesToScan))
+	default:
+		return fmt.Errorf("SBOM scan queue is full, can't scan image %s/%s (id %s)", reference.namespace, reference.name, imageID)
+	}
+
+	// Scans the image only once, even if it's seen again before the scan
+	c.scannedImages.markAsScanned(imageID)
+	c.notifySBOMStatus(imageID, workloadmeta.SBOMStatusPending)
+
+	log.Infof("SBOM scan of image %s/%s (id %s) triggered", reference.namespace, reference.name, imageID)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agentErrors "github.com/DataDog/datadog-agent/pkg/errors"
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func newTriggerScanTestCollector(store *fakeImageStore, scanner *fakeScanner) *collector {
+	img := &mockedImage{
+		mockName: func() string { return "datadog/agent:7" },
+	}
+
+	c := &collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockImage: func(namespace string, name string) (containerd.Image, error) {
+				return img, nil
+			},
+		},
+		knownImages:      newKnownImages(),
+		scannedImages:    newScannedImages(),
+		trivyClient:      scanner,
+		imagesToScan:     make(chan namespacedImage, 1),
+		sbomStatusEvents: true,
+	}
+	c.knownImages.addAssociation("default", "datadog/agent:7", "sha256:1")
+
+	return c
+}
+
+func TestTriggerScan(t *testing.T) {
+	// The image was already scanned, it has an SBOM
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+		CycloneDXBOM: newTestBOM("sha256:1"),
+	}
+	store := newFakeImageStore(image)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+	c := newTriggerScanTestCollector(store, scanner)
+	c.scannedImages.markAsScanned("sha256:1")
+
+	require.NoError(t, c.TriggerScan("sha256:1"))
+
+	require.Len(t, c.imagesToScan, 1)
+	imageToScan := <-c.imagesToScan
+	assert.Equal(t, "default", imageToScan.namespace)
+	assert.Equal(t, "datadog/agent:7", imageToScan.image.Name())
+	assert.Equal(t, "sha256:1", imageToScan.imageID)
+	assert.True(t, imageToScan.rescan)
+
+	event := <-store.events
+	assert.Equal(t, workloadmeta.SBOMStatusPending, event.Entity.(*workloadmeta.ContainerImageMetadata).SBOMStatus)
+
+	// The image is scanned again, even if it has an SBOM and was already
+	// scanned
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	assert.Equal(t, 1, scanner.scanCount("sha256:1"))
+
+	// No more scans are triggered when the queue is full
+	require.NoError(t, c.TriggerScan("sha256:1"))
+	assert.Error(t, c.TriggerScan("sha256:1"))
+}
+
+func TestTriggerScanUnknownImage(t *testing.T) {
+	c := newTriggerScanTestCollector(newFakeImageStore(), &fakeScanner{scans: make(map[string]int)})
+
+	err := c.TriggerScan("sha256:2")
+	assert.Error(t, err)
+	assert.True(t, agentErrors.IsNotFound(err))
+	assert.Empty(t, c.imagesToScan)
+}
+
+func TestTriggerScanAfterStop(t *testing.T) {
+	c := newTriggerScanTestCollector(newFakeImageStore(), &fakeScanner{scans: make(map[string]int)})
+	c.stopSBOMScans(0)
+
+	assert.Error(t, c.TriggerScan("sha256:1"))
+}
+
+func TestTriggerScanSBOMCollectionDisabled(t *testing.T) {
+	c := newTriggerScanTestCollector(newFakeImageStore(), &fakeScanner{scans: make(map[string]int)})
+	c.imagesToScan = nil
+
+	assert.Error(t, c.TriggerScan("sha256:1"))
+}
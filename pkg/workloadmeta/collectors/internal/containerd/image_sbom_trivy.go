@@ -10,13 +10,19 @@ package containerd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+	"golang.org/x/time/rate"
+
 	"github.com/DataDog/datadog-agent/pkg/config"
 	cutil "github.com/DataDog/datadog-agent/pkg/util/containerd"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 )
 
 // scan buffer needs to be very large as we cannot block containerd collector
@@ -24,70 +30,435 @@ const (
 	imagesToScanBufferSize = 5000
 )
 
+// newTrivyCollector creates the scanner running the enabled analyzers. All
+// the analyzers run when none is enabled. Overridden in tests.
+var newTrivyCollector = func(enabledAnalyzers []string, containerdAccessor func() (cutil.ContainerdItf, error)) (trivy.Collector, error) {
+	trivyConfiguration, err := trivy.DefaultCollectorConfig(enabledAnalyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	trivyConfiguration.ContainerdAccessor = containerdAccessor
+	return trivy.NewCollector(trivyConfiguration)
+}
+
 func (c *collector) startSBOMCollection() error {
 	if !sbomCollectionIsEnabled() {
 		return nil
 	}
 
-	var err error
-	enabledAnalyzers := config.Datadog.GetStringSlice("container_image_collection.sbom.analyzers")
-	trivyConfiguration, err := trivy.DefaultCollectorConfig(enabledAnalyzers)
+	// Invalid filters disable the SBOM collection only, the images are
+	// still collected
+	sbomFilter, err := newSBOMFilter(
+		config.Datadog.GetStringSlice("container_image_collection.sbom.include_repositories"),
+		config.Datadog.GetStringSlice("container_image_collection.sbom.exclude_repositories"),
+		config.Datadog.GetStringSlice("container_image_collection.sbom.include_labels"),
+		config.Datadog.GetStringSlice("container_image_collection.sbom.exclude_labels"),
+	)
 	if err != nil {
-		return fmt.Errorf("error initializing trivy client: %w", err)
+		log.Errorf("Invalid SBOM collection filters, SBOM collection is disabled: %s", err)
+		return nil
 	}
+	c.sbomFilter = sbomFilter
 
-	trivyConfiguration.ContainerdAccessor = func() (cutil.ContainerdItf, error) {
+	enabledAnalyzers := config.Datadog.GetStringSlice("container_image_collection.sbom.analyzers")
+	c.trivyClient, err = newTrivyCollector(enabledAnalyzers, func() (cutil.ContainerdItf, error) {
 		return c.containerdClient, nil
-	}
-	c.trivyClient, err = trivy.NewCollector(trivyConfiguration)
+	})
 	if err != nil {
 		return fmt.Errorf("error initializing trivy client: %w", err)
 	}
 
-	c.imagesToScan = make(chan namespacedImage, imagesToScanBufferSize)
+	vulnerabilityDB := vulnerabilityDBFromConfig()
+	c.sbomSelfTest = c.startSBOMSelfTest(c.sbomScanContext(), prepareVulnerabilityDB(vulnerabilityDB))
 
-	go func() {
-		for imageToScan := range c.imagesToScan {
-			scanContext, cancel := context.WithTimeout(context.Background(), scanningTimeout())
-			if err := c.extractBOMWithTrivy(scanContext, imageToScan); err != nil {
-				log.Warnf("error extracting SBOM for image: namespace=%s name=%s, err: %s", imageToScan.namespace, imageToScan.image.Name(), err)
-			}
-			cancel()
+	if config.Datadog.GetBool("container_image_collection.sbom.cache.enabled") {
+		c.sbomCache, err = newSBOMCache(
+			config.Datadog.GetString("container_image_collection.sbom.cache.directory"),
+			time.Duration(config.Datadog.GetInt("container_image_collection.sbom.cache.ttl"))*time.Second,
+			config.Datadog.GetInt64("container_image_collection.sbom.cache.max_disk_size"),
+		)
+		if err != nil {
+			// Not fatal, images are scanned again after a restart
+			log.Warnf("SBOM cache disabled: %s", err)
 		}
-	}()
+	}
+
+	if config.Datadog.GetBool("container_image_collection.sbom.layer_cache.enabled") {
+		c.layerSBOMCache, err = newLayerSBOMCache(config.Datadog.GetInt("container_image_collection.sbom.layer_cache.max_layers"))
+		if err != nil {
+			return fmt.Errorf("error initializing the SBOM layer cache: %w", err)
+		}
+	}
+
+	if usedImagesOnlyAreScanned() {
+		c.usedImages = newUsedImages()
+	}
+
+	c.vulnerabilitySource = getVulnerabilitySource()
+	if c.vulnerabilitySource == nil {
+		c.vulnerabilitySource = newTrivyVulnerabilitySource(vulnerabilityDB)
+	}
+	c.sbomLimits = sbomLimitsFromConfig()
+	c.sbomPackageFilter = packageTypeFilterFromConfig()
+
+	if c.sbomTelemetry == nil {
+		c.sbomTelemetry = getDefaultSBOMTelemetry()
+	}
+
+	c.sbomStatusEvents = config.Datadog.GetBool("container_image_collection.sbom.status_events.enabled")
+
+	c.scanRateLimiter = newScanRateLimiter(config.Datadog.GetInt("container_image_collection.sbom.max_scans_per_minute"))
+	c.startSBOMScanWorkers(scanWorkers())
+
+	c.sbomRescanner = newSBOMRescanner(rescanPeriod(), c.enqueueImageToRescan)
+	if c.sbomRescanner != nil {
+		c.sbomRescanner.start()
+	}
+
+	c.sbomScanRetrier = newSBOMScanRetrier(
+		config.Datadog.GetInt("container_image_collection.sbom.retry.max_attempts"),
+		time.Duration(config.Datadog.GetInt("container_image_collection.sbom.retry.base_backoff"))*time.Second,
+		time.Duration(config.Datadog.GetInt("container_image_collection.sbom.retry.max_backoff"))*time.Second,
+		c.enqueueImageToScan,
+	)
+
+	// Scanning the root filesystem of the containers is more expensive than
+	// scanning their image, that's why it's enabled separately
+	if containerSBOMCollectionIsEnabled() {
+		c.startContainerSBOMScanWorker(config.Datadog.GetInt("container_image_collection.sbom.container_scan.max_scans_per_minute"))
+	}
+
+	// Started last, as the scans of the OCI layouts use the state set up above
+	c.startOCILayoutScans(ociLayoutDirectories())
 
 	return nil
 }
 
+// startSBOMScanWorkers starts a pool of workers extracting the SBOMs of the
+// images sent to imagesToScan. The workers stop when imagesToScan is closed,
+// see stopSBOMScans.
+func (c *collector) startSBOMScanWorkers(workers int) {
+	c.imagesToScan = make(chan namespacedImage, imagesToScanBufferSize)
+	ctx := c.sbomScanContext()
+
+	c.sbomScanWorkers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer c.sbomScanWorkers.Done()
+			c.runSBOMScanWorker(ctx)
+		}()
+	}
+}
+
+func (c *collector) runSBOMScanWorker(ctx context.Context) {
+	for imageToScan := range c.imagesToScan {
+		c.sbomTelemetry.setQueuedScans(len(c.imagesToScan))
+
+		// The scans are stopped, drop the images still queued
+		if ctx.Err() != nil {
+			continue
+		}
+
+		// Rescans and retries are for images already marked as scanned
+		if !imageToScan.rescan && imageToScan.failedAttempts == 0 && !c.scannedImages.markAsScanned(imageToScan.imageID) {
+			// Can happen when the same image ID is referenced with different
+			// names.
+			log.Debugf("Image: %s/%s (id %s) already scanned, skipping scan", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+			continue
+		}
+
+		if err := c.extractBOMWithTrivy(ctx, imageToScan); err != nil {
+			if ctx.Err() != nil {
+				log.Debugf("SBOM scan of image: %s/%s (id %s) cancelled on shutdown", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+				continue
+			}
+
+			if c.sbomScanRetrier != nil && c.sbomScanRetrier.retry(imageToScan, err) {
+				c.notifySBOMStatus(imageToScan.imageID, workloadmeta.SBOMStatusPending)
+				continue
+			}
+
+			log.Warnf("error extracting SBOM for image: namespace=%s name=%s, err: %s", imageToScan.namespace, imageToScan.image.Name(), err)
+			c.notifySBOMStatus(imageToScan.imageID, workloadmeta.SBOMStatusFailed)
+
+			if imageToScan.rescan {
+				// Try again at the next rescan
+				c.scheduleRescan(imageToScan)
+			} else {
+				// Scan the image again the next time it's seen
+				c.scannedImages.forget(imageToScan.imageID)
+			}
+		}
+	}
+}
+
 func (c *collector) extractBOMWithTrivy(ctx context.Context, imageToScan namespacedImage) error {
 	storedImage, err := c.store.GetImage(imageToScan.imageID)
 	if err != nil {
 		log.Infof("Image: %s/%s (id %s) not found in Workloadmeta, skipping scan", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+		c.scannedImages.forget(imageToScan.imageID)
 		return nil
 	}
 
-	if storedImage.CycloneDXBOM != nil {
-		// BOM already stored. Can happen when the same image ID is referenced
-		// with different names.
+	if storedImage.CycloneDXBOM != nil && !imageToScan.rescan {
+		// BOM already stored.
 		log.Debugf("Image: %s/%s (id %s) SBOM already available", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
 		return nil
 	}
 
+	// Rescans are not served from the cache, as the point of rescanning is to
+	// get up-to-date results
+	if c.sbomCache != nil && !imageToScan.rescan {
+		if bom, found := c.sbomCache.get(imageToScan.imageID); found {
+			log.Debugf("Image: %s/%s (id %s) SBOM found in cache", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+			c.sbomTelemetry.observeCacheHit()
+			c.notifyImageWithBOM(storedImage, c.processSBOM(ctx, bom, imageDescription(imageToScan)))
+			c.scheduleRescan(imageToScan)
+			return nil
+		}
+	}
+
 	scanFunc := c.trivyClient.ScanContainerdImage
 	if config.Datadog.GetBool("container_image_collection.sbom.use_mount") {
 		scanFunc = c.trivyClient.ScanContainerdImageFromFilesystem
 	}
+	if c.layerSBOMCache != nil {
+		scanImage := scanFunc
+		scanFunc = func(ctx context.Context, storedImage *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+			return c.scanImageLayers(ctx, storedImage, img, imageToScan.rescan, scanImage)
+		}
+	}
+
+	// Wait before creating the scan context, so that the time spent waiting
+	// doesn't count in the scan timeout
+	if c.scanRateLimiter != nil {
+		if err := c.scanRateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.notifySBOMStatus(imageToScan.imageID, workloadmeta.SBOMStatusRunning)
 
-	bom, err := scanFunc(ctx, storedImage, imageToScan.image)
+	scanStart := time.Now()
+	bom, err := scanWithTimeout(ctx, scanningTimeout(), func(scanContext context.Context) (*cyclonedx.BOM, error) {
+		return scanFunc(scanContext, storedImage, imageToScan.image)
+	})
+	c.sbomTelemetry.observeScan(time.Since(scanStart), err)
 	if err != nil {
 		return err
 	}
 
-	time.Sleep(timeBetweenScans())
+	if c.sbomCache != nil {
+		if err := c.sbomCache.set(imageToScan.imageID, bom); err != nil {
+			log.Debugf("error caching SBOM of image %s: %s", imageToScan.imageID, err)
+		}
+	}
+
+	// The vulnerabilities are not cached, they change more often than the
+	// packages of the image
+	bom = c.processSBOM(ctx, bom, imageDescription(imageToScan))
+
+	// Unchanged SBOMs are not notified again after rescans, only a heartbeat
+	// keeping the stored SBOM is
+	if imageToScan.rescan && storedImage.CycloneDXBOM != nil && sbomContentHash(bom) == sbomContentHash(storedImage.CycloneDXBOM) {
+		log.Debugf("Image: %s/%s (id %s) rescanned, SBOM unchanged", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+		c.sbomTelemetry.observeUnchangedRescan()
+		c.notifyUnchangedSBOM(storedImage)
+	} else {
+		c.notifyImageWithBOM(storedImage, bom)
+	}
+	c.scheduleRescan(imageToScan)
+
+	select {
+	case <-time.After(timeBetweenScans()):
+	case <-ctx.Done():
+	}
+
+	return nil
+}
+
+// processSBOM prepares the SBOM of a scan, described by scanned, to be
+// notified to the store: the packages are filtered by type, then the
+// vulnerabilities of the remaining ones are attached, and finally the limits
+// are applied.
+func (c *collector) processSBOM(ctx context.Context, bom *cyclonedx.BOM, scanned string) *cyclonedx.BOM {
+	bom = c.sbomPackageFilter.apply(bom)
+	bom = c.enrichSBOM(ctx, bom)
+	return c.limitSBOM(bom, scanned)
+}
+
+func imageDescription(imageToScan namespacedImage) string {
+	return fmt.Sprintf("image %s/%s (id %s)", imageToScan.namespace, imageToScan.image.Name(), imageToScan.imageID)
+}
+
+// scheduleRescan schedules a rescan of the image, if rescans are enabled.
+func (c *collector) scheduleRescan(imageToScan namespacedImage) {
+	if c.sbomRescanner != nil {
+		c.sbomRescanner.schedule(imageToScan)
+	}
+}
 
+// notifyImageWithBOM generates an update event for the stored image with its
+// SBOM.
+func (c *collector) notifyImageWithBOM(storedImage *workloadmeta.ContainerImageMetadata, bom *cyclonedx.BOM) {
 	// Updating workloadmeta entities directly is not thread-safe, that's why we
-	// generate an update event here instead.
-	return c.handleImageCreateOrUpdate(ctx, imageToScan.namespace, storedImage.Name, bom)
+	// generate an update event here instead. The event is built from a copy
+	// of the stored image rather than from containerd, because the state of
+	// the collector (like the repo tags) is owned by the goroutine handling
+	// the containerd events.
+	scannedImage := *storedImage
+	scannedImage.CycloneDXBOM = bom
+	scannedImage.SBOMScannedAt = time.Now()
+	if c.sbomStatusEvents {
+		scannedImage.SBOMStatus = workloadmeta.SBOMStatusSuccess
+	}
+
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeSet,
+			Source: workloadmeta.SourceRuntime,
+			Entity: &scannedImage,
+		},
+	})
+}
+
+// notifyUnchangedSBOM generates the heartbeat of an unchanged rescan: an
+// update event for the stored image, with the same SBOM, where only the scan
+// time and, if the status events are enabled, the status change. The
+// consumers don't send the SBOM again.
+func (c *collector) notifyUnchangedSBOM(storedImage *workloadmeta.ContainerImageMetadata) {
+	image := *storedImage
+	image.SBOMScannedAt = time.Now()
+	if c.sbomStatusEvents {
+		image.SBOMStatus = workloadmeta.SBOMStatusSuccess
+	}
+
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeSet,
+			Source: workloadmeta.SourceRuntime,
+			Entity: &image,
+		},
+	})
+}
+
+// notifySBOMStatus generates an update event for the stored image with the
+// new status of its SBOM scan, if the status events are enabled. The SBOM,
+// if any, is kept in the event. The final event of a successful scan, which
+// carries the SBOM, is generated by notifyImageWithBOM.
+func (c *collector) notifySBOMStatus(imageID string, status workloadmeta.SBOMStatus) {
+	if !c.sbomStatusEvents {
+		return
+	}
+
+	storedImage, err := c.store.GetImage(imageID)
+	if err != nil {
+		return
+	}
+
+	image := *storedImage
+	image.SBOMStatus = status
+
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeSet,
+			Source: workloadmeta.SourceRuntime,
+			Entity: &image,
+		},
+	})
+}
+
+// enqueueImageToRescan sends an image that was already scanned to the scan
+// workers, marking its scan as pending.
+func (c *collector) enqueueImageToRescan(imageToScan namespacedImage) {
+	c.notifySBOMStatus(imageToScan.imageID, workloadmeta.SBOMStatusPending)
+	c.enqueueImageToScan(imageToScan)
+}
+
+// dropScan handles an image that couldn't be sent to the scan workers. Its
+// scan was already notified as pending, so its status is reverted:
+//   - first scans have no status, the image is scanned the next time it's seen
+//   - retries have failed, the image is scanned again the next time it's seen
+//   - rescans keep the status of the previous scan, and are scheduled again
+func (c *collector) dropScan(imageToScan namespacedImage) {
+	switch {
+	case imageToScan.rescan:
+		status := workloadmeta.SBOMStatusFailed
+		if storedImage, err := c.store.GetImage(imageToScan.imageID); err == nil && storedImage.CycloneDXBOM != nil {
+			status = workloadmeta.SBOMStatusSuccess
+		}
+		c.notifySBOMStatus(imageToScan.imageID, status)
+		c.scheduleRescan(imageToScan)
+	case imageToScan.failedAttempts > 0:
+		c.scannedImages.forget(imageToScan.imageID)
+		c.notifySBOMStatus(imageToScan.imageID, workloadmeta.SBOMStatusFailed)
+	default:
+		c.notifySBOMStatus(imageToScan.imageID, "")
+	}
+}
+
+// errScanTimeout is returned when a scan doesn't finish within the scan
+// timeout. It wraps context.DeadlineExceeded.
+var errScanTimeout = fmt.Errorf("SBOM scan timed out: %w", context.DeadlineExceeded)
+
+// maxAbandonedScans is the maximum number of scans still running in the
+// background after their timeout expired, see scanWithTimeout.
+const maxAbandonedScans = 4
+
+// abandonedScans holds a slot for each scan still running after its timeout.
+// It's shared by all the scans.
+var abandonedScans = make(chan struct{}, maxAbandonedScans)
+
+// scanWithTimeout runs the scan with a context cancelled after the timeout,
+// and returns errScanTimeout as soon as the timeout expires, even if the scan
+// hasn't returned yet. Not all the analyzers of trivy check their context, so
+// a pathological image could otherwise hold a scan worker indefinitely. The
+// scan keeps running in the background until it notices the cancellation.
+//
+// At most maxAbandonedScans scans run in the background. Beyond that,
+// scanWithTimeout waits for the scan to return, so that the scans ignoring
+// their cancellation don't pile up.
+func scanWithTimeout(ctx context.Context, timeout time.Duration, scan func(ctx context.Context) (*cyclonedx.BOM, error)) (*cyclonedx.BOM, error) {
+	scanContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type scanResult struct {
+		bom *cyclonedx.BOM
+		err error
+	}
+
+	// Buffered so that a scan finishing after the timeout doesn't leak
+	results := make(chan scanResult, 1)
+	go func() {
+		bom, err := scan(scanContext)
+		results <- scanResult{bom: bom, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		if result.err != nil && errors.Is(scanContext.Err(), context.DeadlineExceeded) {
+			return nil, errScanTimeout
+		}
+		return result.bom, result.err
+	case <-scanContext.Done():
+	}
+
+	select {
+	case abandonedScans <- struct{}{}:
+		go func() {
+			<-results
+			<-abandonedScans
+		}()
+	default:
+		log.Warnf("Too many SBOM scans still running after their timeout, waiting for the scan to return")
+		<-results
+	}
+
+	if errors.Is(scanContext.Err(), context.DeadlineExceeded) {
+		return nil, errScanTimeout
+	}
+	return nil, scanContext.Err()
 }
 
 func scanningTimeout() time.Duration {
@@ -97,3 +468,24 @@ func scanningTimeout() time.Duration {
 func timeBetweenScans() time.Duration {
 	return time.Duration(config.Datadog.GetInt("container_image_collection.sbom.scan_interval")) * time.Second
 }
+
+// newScanRateLimiter returns a limiter allowing to start at most maxPerMinute
+// scans per minute, or nil if maxPerMinute is not positive.
+func newScanRateLimiter(maxPerMinute int) *rate.Limiter {
+	if maxPerMinute <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(float64(maxPerMinute)/60), 1)
+}
+
+func rescanPeriod() time.Duration {
+	return time.Duration(config.Datadog.GetInt("container_image_collection.sbom.rescan_period")) * time.Second
+}
+
+func scanWorkers() int {
+	if workers := config.Datadog.GetInt("container_image_collection.sbom.scan_workers"); workers > 0 {
+		return workers
+	}
+	return 1
+}
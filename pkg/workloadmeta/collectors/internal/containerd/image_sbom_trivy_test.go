@@ -0,0 +1,1329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content/local"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	cutil "github.com/DataDog/datadog-agent/pkg/util/containerd"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// fakeScanner returns an empty SBOM for every image, counting the scans by
+// image ID.
+type fakeScanner struct {
+	mut   sync.Mutex
+	scans map[string]int
+}
+
+func (s *fakeScanner) ScanContainerdImage(_ context.Context, imageMeta *workloadmeta.ContainerImageMetadata, _ containerd.Image) (*cyclonedx.BOM, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.scans[imageMeta.ID]++
+	return newTestBOM(imageMeta.ID), nil
+}
+
+func (s *fakeScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func (s *fakeScanner) ScanFilesystem(_ context.Context, path string) (*cyclonedx.BOM, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.scans[path]++
+	return newTestBOM(path), nil
+}
+
+// ScanContainerdImageLayer returns an SBOM with a single package named after
+// the layer, counting the scans by layer digest.
+func (s *fakeScanner) ScanContainerdImageLayer(_ context.Context, _ *workloadmeta.ContainerImageMetadata, _ containerd.Image, layer ocispec.Descriptor) (*cyclonedx.BOM, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.scans[layer.Digest.String()]++
+
+	bom := newTestBOM(layer.Digest.String())
+	bom.Components = &[]cyclonedx.Component{
+		{
+			Type: cyclonedx.ComponentTypeLibrary,
+			Name: layer.Digest.Encoded(),
+		},
+	}
+	return bom, nil
+}
+
+// ScanOCILayoutImage counts the scans by path and manifest digest, as
+// "<path>@<digest>".
+func (s *fakeScanner) ScanOCILayoutImage(_ context.Context, path string, manifestDigest string) (*cyclonedx.BOM, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.scans[path+"@"+manifestDigest]++
+	return newTestBOM(manifestDigest), nil
+}
+
+func (s *fakeScanner) scanCount(imageID string) int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.scans[imageID]
+}
+
+func TestSBOMScanWorkers(t *testing.T) {
+	imageIDs := []string{"sha256:1", "sha256:2", "sha256:3"}
+
+	var images []*workloadmeta.ContainerImageMetadata
+	for _, id := range imageIDs {
+		images = append(images, &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   id,
+			},
+			EntityMeta: workloadmeta.EntityMeta{
+				Name:      "agent-" + id,
+				Namespace: "default",
+			},
+		})
+	}
+
+	store := newFakeImageStore(images...)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+	c.startSBOMScanWorkers(2)
+	defer close(c.imagesToScan)
+
+	enqueue := func(id string) {
+		c.imagesToScan <- namespacedImage{
+			namespace: "default",
+			image: &mockedImage{
+				mockName: func() string { return "agent-" + id },
+			},
+			imageID: id,
+		}
+	}
+
+	// The first image is referenced twice, but should be scanned only once
+	for _, id := range imageIDs {
+		enqueue(id)
+	}
+	enqueue(imageIDs[0])
+
+	eventsByID := make(map[string]workloadmeta.CollectorEvent)
+	for range imageIDs {
+		select {
+		case event := <-store.events:
+			image := event.Entity.(*workloadmeta.ContainerImageMetadata)
+			eventsByID[image.ID] = event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for SBOM events")
+		}
+	}
+
+	for _, id := range imageIDs {
+		event, found := eventsByID[id]
+		require.True(t, found, "no event for image %s", id)
+
+		image := event.Entity.(*workloadmeta.ContainerImageMetadata)
+		assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+		assert.Equal(t, workloadmeta.SourceRuntime, event.Source)
+		assert.Equal(t, "agent-"+id, image.Name)
+		require.NotNil(t, image.CycloneDXBOM)
+		assert.Equal(t, id, image.CycloneDXBOM.SerialNumber)
+		assert.Equal(t, 1, scanner.scanCount(id))
+	}
+
+	select {
+	case event := <-store.events:
+		assert.Failf(t, "unexpected event", "%+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSBOMScanSkipsImagesNotInStore(t *testing.T) {
+	store := newFakeImageStore()
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+
+	err := c.extractBOMWithTrivy(context.Background(), namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, scanner.scanCount("sha256:1"))
+	assert.False(t, c.scannedImages.isScanned("sha256:1"))
+	assert.Empty(t, store.events)
+}
+
+func TestSBOMScanUsesCache(t *testing.T) {
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+		EntityMeta: workloadmeta.EntityMeta{
+			Name:      "agent",
+			Namespace: "default",
+		},
+	}
+	imageToScan := namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	}
+
+	cache, _ := newTestSBOMCache(t, time.Hour, 0)
+	store := newFakeImageStore(image)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+		sbomCache:     cache,
+	}
+
+	// Cache miss: the image is scanned and its SBOM is cached
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	assert.Equal(t, 1, scanner.scanCount("sha256:1"))
+	event := <-store.events
+	assert.Equal(t, "sha256:1", event.Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM.SerialNumber)
+
+	_, found := cache.get("sha256:1")
+	assert.True(t, found)
+
+	// Cache hit, for instance after a restart: the cached SBOM is emitted
+	// without scanning the image
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	assert.Equal(t, 1, scanner.scanCount("sha256:1"))
+	event = <-store.events
+	assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+	assert.Equal(t, "sha256:1", event.Entity.(*workloadmeta.ContainerImageMetadata).ID)
+	assert.Equal(t, "sha256:1", event.Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM.SerialNumber)
+}
+
+// concurrencyTrackingScanner records the maximum number of scans running at
+// the same time.
+type concurrencyTrackingScanner struct {
+	fakeScanner
+	scanDuration time.Duration
+
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (s *concurrencyTrackingScanner) ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	inFlight := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if inFlight <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, inFlight) {
+			break
+		}
+	}
+
+	time.Sleep(s.scanDuration)
+
+	return s.fakeScanner.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func (s *concurrencyTrackingScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func TestSBOMScanConcurrencyLimit(t *testing.T) {
+	const (
+		workers  = 3
+		numScans = 12
+	)
+
+	var images []*workloadmeta.ContainerImageMetadata
+	for i := 0; i < numScans; i++ {
+		images = append(images, &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   fmt.Sprintf("sha256:%d", i),
+			},
+		})
+	}
+
+	store := newFakeImageStore(images...)
+	scanner := &concurrencyTrackingScanner{
+		fakeScanner:  fakeScanner{scans: make(map[string]int)},
+		scanDuration: 20 * time.Millisecond,
+	}
+
+	c := collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+	c.startSBOMScanWorkers(workers)
+	defer close(c.imagesToScan)
+
+	for _, image := range images {
+		c.enqueueImageToScan(namespacedImage{
+			namespace: "default",
+			image: &mockedImage{
+				mockName: func() string { return "agent" },
+			},
+			imageID: image.ID,
+		})
+	}
+
+	for i := 0; i < numScans; i++ {
+		select {
+		case <-store.events:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for SBOM events")
+		}
+	}
+
+	maxInFlight := atomic.LoadInt32(&scanner.maxInFlight)
+	assert.LessOrEqual(t, maxInFlight, int32(workers))
+	assert.Greater(t, maxInFlight, int32(1), "scans should run concurrently")
+}
+
+func TestSBOMScanRateLimit(t *testing.T) {
+	assert.Nil(t, newScanRateLimiter(0))
+
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+	}
+
+	store := newFakeImageStore(image)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:       store,
+		trivyClient: scanner,
+		// One scan per minute: the first scan starts immediately, the
+		// second one has to wait for a minute
+		scanRateLimiter: newScanRateLimiter(1),
+	}
+
+	imageToScan := namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	}
+
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	assert.Equal(t, 1, scanner.scanCount("sha256:1"))
+	<-store.events
+
+	// Clear the BOM so that the image is scanned again
+	store.Set(image)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, c.extractBOMWithTrivy(ctx, imageToScan))
+	assert.Equal(t, 1, scanner.scanCount("sha256:1"))
+}
+
+func TestSBOMPeriodicRescan(t *testing.T) {
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+	}
+
+	store := newFakeImageStore(image)
+	scanner := &fakeScanner{scans: make(map[string]int)}
+
+	c := collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+	c.startSBOMScanWorkers(1)
+	c.sbomRescanner = newSBOMRescanner(50*time.Millisecond, c.enqueueImageToScan)
+	c.sbomRescanner.start()
+	defer close(c.imagesToScan)
+	defer c.sbomRescanner.stop()
+
+	c.enqueueImageToScan(namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	})
+
+	// The initial scan, then rescans on schedule
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		select {
+		case event := <-store.events:
+			assert.Equal(t, "sha256:1", event.Entity.(*workloadmeta.ContainerImageMetadata).ID)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for SBOM events")
+		}
+		assert.Equal(t, i, scanner.scanCount("sha256:1"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// Once the image is removed, it's not rescanned anymore
+	store.Unset(image)
+
+	select {
+	case event := <-store.events:
+		assert.Failf(t, "unexpected event", "%+v", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.Equal(t, 3, scanner.scanCount("sha256:1"))
+}
+
+// failingScanner fails every scan with the given error.
+type failingScanner struct {
+	err error
+}
+
+func (s *failingScanner) ScanContainerdImage(context.Context, *workloadmeta.ContainerImageMetadata, containerd.Image) (*cyclonedx.BOM, error) {
+	return nil, s.err
+}
+
+func (s *failingScanner) ScanContainerdImageFromFilesystem(context.Context, *workloadmeta.ContainerImageMetadata, containerd.Image) (*cyclonedx.BOM, error) {
+	return nil, s.err
+}
+
+func (s *failingScanner) ScanFilesystem(context.Context, string) (*cyclonedx.BOM, error) {
+	return nil, s.err
+}
+
+func (s *failingScanner) ScanContainerdImageLayer(context.Context, *workloadmeta.ContainerImageMetadata, containerd.Image, ocispec.Descriptor) (*cyclonedx.BOM, error) {
+	return nil, s.err
+}
+
+func (s *failingScanner) ScanOCILayoutImage(context.Context, string, string) (*cyclonedx.BOM, error) {
+	return nil, s.err
+}
+
+func TestSBOMScanFailureTelemetry(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		image := &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   "sha256:1",
+			},
+		}
+
+		c := collector{
+			store:         newFakeImageStore(image),
+			trivyClient:   &failingScanner{err: fmt.Errorf("scan failed: %w", context.DeadlineExceeded)},
+			scannedImages: newScannedImages(),
+			sbomTelemetry: newSBOMTelemetry(tel),
+		}
+
+		imageToScan := namespacedImage{
+			namespace: "default",
+			image: &mockedImage{
+				mockName: func() string { return "agent" },
+			},
+			imageID: "sha256:1",
+		}
+
+		for i := 0; i < 2; i++ {
+			assert.Error(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+		}
+
+		assert.Equal(t, 2.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_failures", scanFailureTimeout))
+		assert.Equal(t, 0.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_success", sbomSourceScan))
+		assert.Len(t, mock.Observations(telemetrySubsystem, "containerd_sbom_scan_duration"), 2)
+	})
+}
+
+// flakyScanner fails the first scans of each image with the given error, then
+// succeeds.
+type flakyScanner struct {
+	fakeScanner
+	failures int
+	err      error
+}
+
+func (s *flakyScanner) ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	bom, _ := s.fakeScanner.ScanContainerdImage(ctx, imageMeta, img)
+	if s.scanCount(imageMeta.ID) <= s.failures {
+		return nil, s.err
+	}
+	return bom, nil
+}
+
+func (s *flakyScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func newRetryTestCollector(scanner *flakyScanner, maxAttempts int) (*collector, *fakeImageStore) {
+	store := newFakeImageStore(&workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+	})
+
+	c := &collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+	c.startSBOMScanWorkers(1)
+	c.sbomScanRetrier = newSBOMScanRetrier(maxAttempts, time.Millisecond, 10*time.Millisecond, c.enqueueImageToScan)
+
+	return c, store
+}
+
+func TestSBOMScanRetrySucceedsOnSecondAttempt(t *testing.T) {
+	scanner := &flakyScanner{
+		fakeScanner: fakeScanner{scans: make(map[string]int)},
+		failures:    1,
+		err:         errors.New("layer not unpacked yet"),
+	}
+
+	c, store := newRetryTestCollector(scanner, 3)
+	defer close(c.imagesToScan)
+	defer c.sbomScanRetrier.stop()
+
+	c.enqueueImageToScan(newTestImageToScan("sha256:1"))
+
+	select {
+	case event := <-store.events:
+		image := event.Entity.(*workloadmeta.ContainerImageMetadata)
+		require.NotNil(t, image.CycloneDXBOM)
+		assert.Equal(t, "sha256:1", image.CycloneDXBOM.SerialNumber)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for SBOM event")
+	}
+
+	assert.Equal(t, 2, scanner.scanCount("sha256:1"))
+	assert.True(t, c.scannedImages.isScanned("sha256:1"))
+}
+
+func TestSBOMScanRetriesExhausted(t *testing.T) {
+	scanner := &flakyScanner{
+		fakeScanner: fakeScanner{scans: make(map[string]int)},
+		failures:    10,
+		err:         errors.New("registry unavailable"),
+	}
+
+	c, store := newRetryTestCollector(scanner, 3)
+	defer close(c.imagesToScan)
+	defer c.sbomScanRetrier.stop()
+
+	c.enqueueImageToScan(newTestImageToScan("sha256:1"))
+
+	// Once the retries are exhausted, the image is scanned again only the
+	// next time it's seen
+	assert.Eventually(t, func() bool {
+		return scanner.scanCount("sha256:1") == 3 && !c.scannedImages.isScanned("sha256:1")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case event := <-store.events:
+		assert.Failf(t, "unexpected event", "%+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 3, scanner.scanCount("sha256:1"))
+}
+
+func TestSBOMAnalyzersAreThreadedToScanner(t *testing.T) {
+	tests := []struct {
+		name              string
+		analyzers         []string
+		expectedAnalyzers []string
+	}{
+		{
+			name:              "selected analyzers",
+			analyzers:         []string{"os", "python"},
+			expectedAnalyzers: []string{"os", "python"},
+		},
+		{
+			name:              "all analyzers",
+			analyzers:         []string{},
+			expectedAnalyzers: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Mock(t)
+			cfg.Set("container_image_collection.metadata.enabled", true)
+			cfg.Set("container_image_collection.sbom.enabled", true)
+			cfg.Set("container_image_collection.sbom.cache.enabled", false)
+			cfg.Set("container_image_collection.sbom.analyzers", test.analyzers)
+
+			scanner := &fakeScanner{scans: make(map[string]int)}
+
+			var enabledAnalyzers []string
+			originalNewTrivyCollector := newTrivyCollector
+			defer func() { newTrivyCollector = originalNewTrivyCollector }()
+			newTrivyCollector = func(analyzers []string, _ func() (cutil.ContainerdItf, error)) (trivy.Collector, error) {
+				enabledAnalyzers = analyzers
+				return scanner, nil
+			}
+
+			c := collector{
+				store:         newFakeImageStore(),
+				scannedImages: newScannedImages(),
+			}
+			require.NoError(t, c.startSBOMCollection())
+			defer c.stopSBOMScans(time.Second)
+
+			assert.Equal(t, test.expectedAnalyzers, enabledAnalyzers)
+			assert.Same(t, scanner, c.trivyClient)
+		})
+	}
+}
+
+func TestSBOMCollectionDisabledByInvalidFilters(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.metadata.enabled", true)
+	cfg.Set("container_image_collection.sbom.enabled", true)
+	cfg.Set("container_image_collection.sbom.include_repositories", []string{"docker.io/datadog/["})
+
+	originalNewTrivyCollector := newTrivyCollector
+	defer func() { newTrivyCollector = originalNewTrivyCollector }()
+	newTrivyCollector = func(_ []string, _ func() (cutil.ContainerdItf, error)) (trivy.Collector, error) {
+		return &fakeScanner{scans: make(map[string]int)}, nil
+	}
+
+	c := collector{
+		store:         newFakeImageStore(),
+		scannedImages: newScannedImages(),
+	}
+	require.NoError(t, c.startSBOMCollection())
+
+	assert.Nil(t, c.imagesToScan)
+	assert.Nil(t, c.trivyClient)
+}
+
+func TestSBOMScanStatusEvents(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		maxAttempts      int
+		expectedStatuses []workloadmeta.SBOMStatus
+	}{
+		{
+			name:        "successful scan",
+			failures:    0,
+			maxAttempts: 1,
+			expectedStatuses: []workloadmeta.SBOMStatus{
+				workloadmeta.SBOMStatusPending,
+				workloadmeta.SBOMStatusRunning,
+				workloadmeta.SBOMStatusSuccess,
+			},
+		},
+		{
+			name:        "failed scan",
+			failures:    10,
+			maxAttempts: 1,
+			expectedStatuses: []workloadmeta.SBOMStatus{
+				workloadmeta.SBOMStatusPending,
+				workloadmeta.SBOMStatusRunning,
+				workloadmeta.SBOMStatusFailed,
+			},
+		},
+		{
+			name:        "scan succeeding when retried",
+			failures:    1,
+			maxAttempts: 3,
+			expectedStatuses: []workloadmeta.SBOMStatus{
+				workloadmeta.SBOMStatusPending,
+				workloadmeta.SBOMStatusRunning,
+				workloadmeta.SBOMStatusPending,
+				workloadmeta.SBOMStatusRunning,
+				workloadmeta.SBOMStatusSuccess,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentStore, err := local.NewStore(t.TempDir())
+			require.NoError(t, err)
+
+			image, imageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "datadog"})
+
+			store := newFakeImageStore()
+			c := collector{
+				store: store,
+				trivyClient: &flakyScanner{
+					fakeScanner: fakeScanner{scans: make(map[string]int)},
+					failures:    test.failures,
+					err:         errors.New("layer not unpacked yet"),
+				},
+				knownImages:      newKnownImages(),
+				repoTags:         make(map[string][]string),
+				scannedImages:    newScannedImages(),
+				imagesToScan:     make(chan namespacedImage, 10),
+				sbomStatusEvents: true,
+			}
+			defer close(c.imagesToScan)
+
+			c.sbomScanRetrier = newSBOMScanRetrier(test.maxAttempts, time.Millisecond, 10*time.Millisecond, c.enqueueImageToScan)
+			if c.sbomScanRetrier != nil {
+				defer c.sbomScanRetrier.stop()
+			}
+
+			// The image is pending as soon as it's collected. The worker
+			// starts once the image is stored, as the fake store doesn't
+			// handle the events it's notified of.
+			require.NoError(t, c.notifyEventForImage(context.Background(), "default", image, nil))
+			event := <-store.events
+			store.Set(event.Entity)
+			go c.runSBOMScanWorker(context.Background())
+
+			statuses := []workloadmeta.SBOMStatus{event.Entity.(*workloadmeta.ContainerImageMetadata).SBOMStatus}
+			var lastImage *workloadmeta.ContainerImageMetadata
+			for len(statuses) < len(test.expectedStatuses) {
+				select {
+				case event := <-store.events:
+					lastImage = event.Entity.(*workloadmeta.ContainerImageMetadata)
+					assert.Equal(t, imageID, lastImage.ID)
+					statuses = append(statuses, lastImage.SBOMStatus)
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "timed out waiting for SBOM status events", "statuses so far: %v", statuses)
+				}
+			}
+
+			assert.Equal(t, test.expectedStatuses, statuses)
+
+			// Only the final event of a successful scan carries the SBOM
+			if test.expectedStatuses[len(test.expectedStatuses)-1] == workloadmeta.SBOMStatusSuccess {
+				assert.NotNil(t, lastImage.CycloneDXBOM)
+			} else {
+				assert.Nil(t, lastImage.CycloneDXBOM)
+			}
+
+			select {
+			case event := <-store.events:
+				assert.Failf(t, "unexpected event", "%+v", event)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// blockingScanner blocks the scans until they are released or cancelled.
+type blockingScanner struct {
+	fakeScanner
+	started   chan string
+	release   chan struct{}
+	cancelled atomic.Int32
+}
+
+func (s *blockingScanner) ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	s.started <- imageMeta.ID
+
+	select {
+	case <-s.release:
+		return s.fakeScanner.ScanContainerdImage(ctx, imageMeta, img)
+	case <-ctx.Done():
+		s.cancelled.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+func (s *blockingScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func newShutdownTestCollector(imageIDs ...string) (*collector, *fakeImageStore, *blockingScanner) {
+	var images []*workloadmeta.ContainerImageMetadata
+	for _, id := range imageIDs {
+		images = append(images, &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   id,
+			},
+		})
+	}
+
+	store := newFakeImageStore(images...)
+	scanner := &blockingScanner{
+		fakeScanner: fakeScanner{scans: make(map[string]int)},
+		started:     make(chan string, len(imageIDs)),
+		release:     make(chan struct{}),
+	}
+
+	c := &collector{
+		store:         store,
+		trivyClient:   scanner,
+		scannedImages: newScannedImages(),
+	}
+	c.startSBOMScanWorkers(1)
+
+	for _, id := range imageIDs {
+		c.enqueueImageToScan(namespacedImage{
+			namespace: "default",
+			image:     &mockedImage{mockName: func() string { return "agent" }},
+			imageID:   id,
+		})
+	}
+
+	return c, store, scanner
+}
+
+func TestSBOMScanDroppedStatus(t *testing.T) {
+	tests := []struct {
+		name                string
+		imageToScan         namespacedImage
+		storedBOM           *cyclonedx.BOM
+		expectedStatus      workloadmeta.SBOMStatus
+		expectedScanned     bool
+		expectedRescheduled bool
+	}{
+		{
+			name:           "first scan",
+			imageToScan:    namespacedImage{imageID: "sha256:1"},
+			expectedStatus: "",
+		},
+		{
+			name:           "retry",
+			imageToScan:    namespacedImage{imageID: "sha256:1", failedAttempts: 1},
+			expectedStatus: workloadmeta.SBOMStatusFailed,
+		},
+		{
+			name:                "rescan",
+			imageToScan:         namespacedImage{imageID: "sha256:1", rescan: true},
+			storedBOM:           &cyclonedx.BOM{},
+			expectedStatus:      workloadmeta.SBOMStatusSuccess,
+			expectedScanned:     true,
+			expectedRescheduled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeImageStore(&workloadmeta.ContainerImageMetadata{
+				EntityID: workloadmeta.EntityID{
+					Kind: workloadmeta.KindContainerImageMetadata,
+					ID:   "sha256:1",
+				},
+				SBOMStatus:   workloadmeta.SBOMStatusPending,
+				CycloneDXBOM: test.storedBOM,
+			})
+
+			c := collector{
+				store:         store,
+				scannedImages: newScannedImages(),
+				// Not buffered and without worker, so that the scans are
+				// dropped
+				imagesToScan:     make(chan namespacedImage),
+				sbomStatusEvents: true,
+				sbomRescanner:    newSBOMRescanner(time.Hour, func(namespacedImage) {}),
+			}
+			if test.imageToScan.rescan || test.imageToScan.failedAttempts > 0 {
+				c.scannedImages.markAsScanned("sha256:1")
+			}
+
+			imageToScan := test.imageToScan
+			imageToScan.namespace = "default"
+			imageToScan.image = &mockedImage{mockName: func() string { return "datadog/agent:7" }}
+			c.enqueueImageToScan(imageToScan)
+
+			select {
+			case event := <-store.events:
+				assert.Equal(t, test.expectedStatus, event.Entity.(*workloadmeta.ContainerImageMetadata).SBOMStatus)
+			default:
+				require.FailNow(t, "the status of the dropped scan wasn't notified")
+			}
+
+			assert.Equal(t, test.expectedScanned, c.scannedImages.isScanned("sha256:1"))
+			_, rescheduled := c.sbomRescanner.scheduled["sha256:1"]
+			assert.Equal(t, test.expectedRescheduled, rescheduled)
+		})
+	}
+}
+
+func TestStopSBOMScansWaitsForScansInProgress(t *testing.T) {
+	c, store, scanner := newShutdownTestCollector("sha256:1")
+
+	select {
+	case <-scanner.started:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the scan to start")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		c.stopSBOMScans(5 * time.Second)
+		close(stopped)
+	}()
+
+	// The scan in progress is waited for
+	select {
+	case <-stopped:
+		require.FailNow(t, "scans stopped before the scan in progress finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(scanner.release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the scans to stop")
+	}
+
+	// The SBOM was notified before the scans stopped
+	require.Len(t, store.events, 1)
+	event := <-store.events
+	assert.Equal(t, "sha256:1", event.Entity.GetID().ID)
+	assert.NotNil(t, event.Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM)
+	assert.Zero(t, scanner.cancelled.Load())
+}
+
+func TestStopSBOMScansCancelsScansAfterTimeout(t *testing.T) {
+	c, store, scanner := newShutdownTestCollector("sha256:1", "sha256:2")
+
+	select {
+	case <-scanner.started:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the scan to start")
+	}
+
+	start := time.Now()
+	c.stopSBOMScans(50 * time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The scan in progress is cancelled, and the queued one is dropped
+	require.Eventually(t, func() bool {
+		return scanner.cancelled.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	c.sbomScanWorkers.Wait()
+
+	assert.Empty(t, scanner.started)
+	assert.Empty(t, store.events)
+	assert.Zero(t, scanner.scanCount("sha256:2"))
+}
+
+func TestStopSBOMScansWithoutWorkers(t *testing.T) {
+	c := collector{
+		imagesToScan: make(chan namespacedImage, 1),
+	}
+
+	// Returns right away, there's nothing to wait for
+	c.stopSBOMScans(time.Hour)
+
+	_, open := <-c.imagesToScan
+	assert.False(t, open)
+}
+
+func TestScanWithTimeout(t *testing.T) {
+	bom := newTestBOM("urn:uuid:1")
+
+	t.Run("scan finishing in time", func(t *testing.T) {
+		result, err := scanWithTimeout(context.Background(), time.Minute, func(context.Context) (*cyclonedx.BOM, error) {
+			return bom, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, bom, result)
+	})
+
+	t.Run("scan honoring the cancellation", func(t *testing.T) {
+		var cancelled atomic.Bool
+		_, err := scanWithTimeout(context.Background(), 50*time.Millisecond, func(ctx context.Context) (*cyclonedx.BOM, error) {
+			<-ctx.Done()
+			cancelled.Store(true)
+			return nil, fmt.Errorf("analyzer stopped: %w", ctx.Err())
+		})
+		assert.ErrorIs(t, err, errScanTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// The timeout may be returned before the scan notices it
+		assert.Eventually(t, cancelled.Load, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("scan ignoring the cancellation", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		start := time.Now()
+		_, err := scanWithTimeout(context.Background(), 50*time.Millisecond, func(context.Context) (*cyclonedx.BOM, error) {
+			<-release
+			return bom, nil
+		})
+		assert.ErrorIs(t, err, errScanTimeout)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("too many scans ignoring the cancellation", func(t *testing.T) {
+		// Waits for the scans abandoned by the previous tests
+		require.Eventually(t, func() bool { return len(abandonedScans) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		release := make(chan struct{})
+		scanIgnoringCancellation := func(context.Context) (*cyclonedx.BOM, error) {
+			<-release
+			return bom, nil
+		}
+
+		for i := 0; i < maxAbandonedScans; i++ {
+			_, err := scanWithTimeout(context.Background(), 10*time.Millisecond, scanIgnoringCancellation)
+			assert.ErrorIs(t, err, errScanTimeout)
+		}
+
+		// The next scan isn't abandoned, it's waited for until it returns
+		returned := make(chan struct{})
+		go func() {
+			defer close(returned)
+			_, err := scanWithTimeout(context.Background(), 10*time.Millisecond, scanIgnoringCancellation)
+			assert.ErrorIs(t, err, errScanTimeout)
+		}()
+
+		select {
+		case <-returned:
+			assert.Fail(t, "the scan was abandoned")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		close(release)
+		<-returned
+
+		// The slots of the abandoned scans are released once they return
+		assert.Eventually(t, func() bool { return len(abandonedScans) == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("cancelled parent context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := scanWithTimeout(ctx, time.Minute, func(ctx context.Context) (*cyclonedx.BOM, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, errScanTimeout)
+	})
+}
+
+// hangingScanner never finishes its scans, ignoring their cancellation.
+type hangingScanner struct {
+	fakeScanner
+	release chan struct{}
+}
+
+func (s *hangingScanner) ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	<-s.release
+	return s.fakeScanner.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func (s *hangingScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func TestSBOMScanTimeoutFreesWorker(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("container_image_collection.sbom.scan_timeout", 1)
+
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		image := &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   "sha256:1",
+			},
+		}
+
+		scanner := &hangingScanner{
+			fakeScanner: fakeScanner{scans: make(map[string]int)},
+			release:     make(chan struct{}),
+		}
+		defer close(scanner.release)
+
+		store := newFakeImageStore(image)
+		c := collector{
+			store:         store,
+			trivyClient:   scanner,
+			scannedImages: newScannedImages(),
+			sbomTelemetry: newSBOMTelemetry(tel),
+		}
+
+		start := time.Now()
+		err := c.extractBOMWithTrivy(context.Background(), namespacedImage{
+			namespace: "default",
+			image: &mockedImage{
+				mockName: func() string { return "agent" },
+			},
+			imageID: "sha256:1",
+		})
+		assert.ErrorIs(t, err, errScanTimeout)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_scan_failures", scanFailureTimeout))
+		assert.Empty(t, store.events)
+	})
+}
+
+func TestSBOMScanAttachesVulnerabilities(t *testing.T) {
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+	}
+	imageToScan := namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	}
+
+	// The SBOM of the image comes from the cache, so that it has packages
+	cache, _ := newTestSBOMCache(t, time.Hour, 0)
+	require.NoError(t, cache.set("sha256:1", newTestVulnerableBOM()))
+
+	store := newFakeImageStore(image)
+	c := collector{
+		store:               store,
+		trivyClient:         &fakeScanner{scans: make(map[string]int)},
+		scannedImages:       newScannedImages(),
+		sbomCache:           cache,
+		vulnerabilitySource: &stubVulnerabilitySource{vulnerabilities: []Vulnerability{testCurlVulnerability}},
+	}
+
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	bom := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM
+	require.NotNil(t, bom.Vulnerabilities)
+	require.Len(t, *bom.Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2023-23914", (*bom.Vulnerabilities)[0].ID)
+
+	// The cached SBOM doesn't have the vulnerabilities
+	cachedBOM, found := cache.get("sha256:1")
+	require.True(t, found)
+	assert.Nil(t, cachedBOM.Vulnerabilities)
+}
+
+// packagesScanner returns an SBOM with its current packages for every image.
+type packagesScanner struct {
+	fakeScanner
+	packages []cyclonedx.Component
+}
+
+func (s *packagesScanner) ScanContainerdImage(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	bom, _ := s.fakeScanner.ScanContainerdImage(ctx, imageMeta, img)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	components := append([]cyclonedx.Component{}, s.packages...)
+	bom.Components = &components
+	return bom, nil
+}
+
+func (s *packagesScanner) ScanContainerdImageFromFilesystem(ctx context.Context, imageMeta *workloadmeta.ContainerImageMetadata, img containerd.Image) (*cyclonedx.BOM, error) {
+	return s.ScanContainerdImage(ctx, imageMeta, img)
+}
+
+func (s *packagesScanner) setPackages(packages ...cyclonedx.Component) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.packages = packages
+}
+
+func TestSBOMRescanDeduplication(t *testing.T) {
+	fxutil.Test(t, telemetry.MockModule, func(tel telemetry.Component) {
+		mock := tel.(telemetry.Mock)
+
+		image := &workloadmeta.ContainerImageMetadata{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainerImageMetadata,
+				ID:   "sha256:1",
+			},
+		}
+		imageToScan := namespacedImage{
+			namespace: "default",
+			image: &mockedImage{
+				mockName: func() string { return "agent" },
+			},
+			imageID: "sha256:1",
+		}
+
+		curl := newTestDebComponent("curl", "7.74.0")
+		libc := newTestDebComponent("libc6", "2.31-13")
+
+		store := newFakeImageStore(image)
+		scanner := &packagesScanner{fakeScanner: fakeScanner{scans: make(map[string]int)}}
+		scanner.setPackages(curl, libc)
+
+		c := collector{
+			store:         store,
+			trivyClient:   scanner,
+			scannedImages: newScannedImages(),
+			sbomTelemetry: newSBOMTelemetry(tel),
+		}
+
+		// The initial scan is notified
+		require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+		event := <-store.events
+		store.Set(event.Entity)
+
+		// Rescan of the same packages, in another order: only a heartbeat,
+		// keeping the stored SBOM, is notified
+		rescan := imageToScan
+		rescan.rescan = true
+		scanner.setPackages(libc, curl)
+		require.NoError(t, c.extractBOMWithTrivy(context.Background(), rescan))
+		require.Len(t, store.events, 1)
+		heartbeat := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+		assert.Same(t, event.Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM, heartbeat.CycloneDXBOM)
+		assert.Equal(t, 2, scanner.scanCount("sha256:1"))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_unchanged_rescans"))
+
+		// Rescan after an update of a package: notified
+		scanner.setPackages(newTestDebComponent("curl", "7.88.0"), libc)
+		require.NoError(t, c.extractBOMWithTrivy(context.Background(), rescan))
+		require.Len(t, store.events, 1)
+		bom := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM
+		assert.Contains(t, *bom.Components, newTestDebComponent("curl", "7.88.0"))
+		assert.Equal(t, 1.0, mock.Value(telemetrySubsystem, "containerd_sbom_unchanged_rescans"))
+	})
+}
+
+func TestSBOMUnchangedRescanHeartbeat(t *testing.T) {
+	for _, statusEvents := range []bool{true, false} {
+		t.Run(fmt.Sprintf("status events enabled: %t", statusEvents), func(t *testing.T) {
+			image := &workloadmeta.ContainerImageMetadata{
+				EntityID: workloadmeta.EntityID{
+					Kind: workloadmeta.KindContainerImageMetadata,
+					ID:   "sha256:1",
+				},
+				CycloneDXBOM: newTestBOM("sha256:1"),
+			}
+			if statusEvents {
+				image.SBOMStatus = workloadmeta.SBOMStatusPending
+			}
+
+			store := newFakeImageStore(image)
+			c := collector{
+				store:            store,
+				trivyClient:      &fakeScanner{scans: make(map[string]int)},
+				scannedImages:    newScannedImages(),
+				sbomStatusEvents: statusEvents,
+			}
+
+			require.NoError(t, c.extractBOMWithTrivy(context.Background(), namespacedImage{
+				namespace: "default",
+				image: &mockedImage{
+					mockName: func() string { return "agent" },
+				},
+				imageID: "sha256:1",
+				rescan:  true,
+			}))
+
+			// Only the status and the scan time change, the stored SBOM is
+			// kept
+			var events []*workloadmeta.ContainerImageMetadata
+			for len(store.events) > 0 {
+				event := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+				assert.Same(t, image.CycloneDXBOM, event.CycloneDXBOM)
+				events = append(events, event)
+			}
+
+			if statusEvents {
+				require.Len(t, events, 2)
+				assert.Equal(t, workloadmeta.SBOMStatusRunning, events[0].SBOMStatus)
+				assert.Equal(t, workloadmeta.SBOMStatusSuccess, events[1].SBOMStatus)
+			} else {
+				require.Len(t, events, 1)
+				assert.Empty(t, events[0].SBOMStatus)
+			}
+
+			heartbeat := events[len(events)-1]
+			assert.False(t, heartbeat.SBOMScannedAt.IsZero())
+		})
+	}
+}
+
+func TestSBOMScanFiltersPackageTypes(t *testing.T) {
+	image := &workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   "sha256:1",
+		},
+	}
+	imageToScan := namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	}
+
+	curl := newTestDebComponent("curl", "7.74.0")
+	lodash := cyclonedx.Component{
+		Type:       cyclonedx.ComponentTypeLibrary,
+		Name:       "lodash",
+		Version:    "4.17.20",
+		PackageURL: "pkg:npm/lodash@4.17.20",
+	}
+
+	store := newFakeImageStore(image)
+	scanner := &packagesScanner{fakeScanner: fakeScanner{scans: make(map[string]int)}}
+	scanner.setPackages(curl, lodash)
+
+	c := collector{
+		store:             store,
+		trivyClient:       scanner,
+		scannedImages:     newScannedImages(),
+		sbomPackageFilter: newPackageTypeFilter([]string{"os"}, nil),
+	}
+
+	require.NoError(t, c.extractBOMWithTrivy(context.Background(), imageToScan))
+	bom := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata).CycloneDXBOM
+	assert.Equal(t, []cyclonedx.Component{curl}, *bom.Components)
+}
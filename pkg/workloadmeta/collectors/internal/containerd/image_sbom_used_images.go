@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"github.com/containerd/containerd"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func usedImagesOnlyAreScanned() bool { // nolint: unused
+	return config.Datadog.GetBool("container_image_collection.sbom.used_images_only")
+}
+
+// usedImages keeps the images used by the running containers, when only
+// those images are scanned. It's only accessed by the goroutine handling the
+// events, so it's not thread-safe.
+type usedImages struct {
+	imageByContainer  map[string]string              // map container ID => image ID
+	containersByImage map[string]map[string]struct{} // map image ID => set of container IDs
+}
+
+func newUsedImages() *usedImages { // nolint: unused
+	return &usedImages{
+		imageByContainer:  make(map[string]string),
+		containersByImage: make(map[string]map[string]struct{}),
+	}
+}
+
+// use records that the running container uses the image, and returns whether
+// the image was not used by any other running container.
+func (u *usedImages) use(containerID string, imageID string) bool {
+	if previousImageID, found := u.imageByContainer[containerID]; found {
+		if previousImageID == imageID {
+			return false
+		}
+		u.release(containerID)
+	}
+
+	firstUser := !u.isUsed(imageID)
+
+	u.imageByContainer[containerID] = imageID
+	if _, found := u.containersByImage[imageID]; !found {
+		u.containersByImage[imageID] = make(map[string]struct{})
+	}
+	u.containersByImage[imageID][containerID] = struct{}{}
+
+	return firstUser
+}
+
+// release records that the container is no longer running, and returns the
+// ID of its image and whether it was the last running container using it.
+func (u *usedImages) release(containerID string) (string, bool) {
+	imageID, found := u.imageByContainer[containerID]
+	if !found {
+		return "", false
+	}
+
+	delete(u.imageByContainer, containerID)
+	delete(u.containersByImage[imageID], containerID)
+	if len(u.containersByImage[imageID]) > 0 {
+		return imageID, false
+	}
+
+	delete(u.containersByImage, imageID)
+	return imageID, true
+}
+
+func (u *usedImages) isUsed(imageID string) bool {
+	return len(u.containersByImage[imageID]) > 0
+}
+
+// isImageUsed returns whether the image can be scanned because it's used by a
+// running container. All the images can be scanned when the scans are not
+// restricted to the used ones.
+func (c *collector) isImageUsed(imageID string) bool {
+	return c.usedImages == nil || c.usedImages.isUsed(imageID)
+}
+
+// handleImageUsage updates the images used by the running containers with the
+// state of a container that is updated. When the container is the first one
+// using its image, the image is sent to the scan workers if it hasn't been
+// scanned yet, and its rescans are resumed otherwise.
+func (c *collector) handleImageUsage(namespace string, container containerd.Container, entity *workloadmeta.Container) {
+	if c.usedImages == nil {
+		return
+	}
+
+	if !entity.State.Running || container == nil {
+		c.releaseImage(entity.ID)
+		return
+	}
+
+	imageID, found := c.knownImages.getImageID(namespace, entity.Image.RawName)
+	if !found {
+		log.Debugf("Image %s/%s of container %s is unknown, it won't be scanned", namespace, entity.Image.RawName, entity.ID)
+		return
+	}
+
+	if !c.usedImages.use(entity.ID, imageID) {
+		return
+	}
+
+	img, err := c.containerdClient.ImageOfContainer(namespace, container)
+	if err != nil {
+		log.Debugf("Cannot get the image of container %s/%s, it won't be scanned: %s", namespace, entity.ID, err)
+		return
+	}
+
+	imageToScan := namespacedImage{
+		namespace: namespace,
+		image:     img,
+		imageID:   imageID,
+	}
+
+	if c.scannedImages.isScanned(imageID) {
+		log.Debugf("Image: %s/%s (id %s) used again by container %s, resuming its rescans", namespace, img.Name(), imageID, entity.ID)
+		if c.sbomRescanner != nil {
+			c.sbomRescanner.schedule(imageToScan)
+		}
+		return
+	}
+
+	if c.imagesToScan == nil {
+		return
+	}
+
+	storedImage := workloadmeta.ContainerImageMetadata{
+		EntityID: workloadmeta.EntityID{
+			Kind: workloadmeta.KindContainerImageMetadata,
+			ID:   imageID,
+		},
+		EntityMeta: workloadmeta.EntityMeta{
+			Name:      img.Name(),
+			Namespace: namespace,
+			Labels:    img.Labels(),
+		},
+		RepoTags: c.repoTags[imageID],
+	}
+	if !c.shouldScanImage(&storedImage) {
+		return
+	}
+
+	log.Debugf("Image: %s/%s (id %s) used by container %s, scanning it", namespace, img.Name(), imageID, entity.ID)
+	c.afterNotify(func() { c.enqueueImageToScan(imageToScan) })
+}
+
+// releaseImage records that a container is no longer running. When it was the
+// last one using its image, the image is no longer rescanned until a
+// container uses it again. Its SBOM is kept.
+func (c *collector) releaseImage(containerID string) {
+	if c.usedImages == nil {
+		return
+	}
+
+	imageID, lastUser := c.usedImages.release(containerID)
+	if !lastUser {
+		return
+	}
+
+	log.Debugf("Image %s is not used by any running container anymore, stopping its rescans", imageID)
+	if c.sbomRescanner != nil {
+		c.sbomRescanner.forget(imageID)
+	}
+}
@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content/local"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestUsedImages(t *testing.T) {
+	images := newUsedImages()
+
+	assert.True(t, images.use("container1", "image1"))
+	assert.False(t, images.use("container2", "image1"))
+	assert.False(t, images.use("container1", "image1"))
+	assert.True(t, images.isUsed("image1"))
+
+	// A container using another image releases the previous one
+	assert.True(t, images.use("container2", "image2"))
+
+	imageID, lastUser := images.release("container1")
+	assert.Equal(t, "image1", imageID)
+	assert.True(t, lastUser)
+	assert.False(t, images.isUsed("image1"))
+
+	_, lastUser = images.release("container1")
+	assert.False(t, lastUser)
+
+	imageID, lastUser = images.release("container2")
+	assert.Equal(t, "image2", imageID)
+	assert.True(t, lastUser)
+	assert.False(t, images.isUsed("image2"))
+}
+
+func TestUsedImagesOnlyAreScanned(t *testing.T) {
+	namespace := "default"
+	imageName := "docker.io/datadog/agent:7"
+
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+	image, imageID := newFakeImage(t, contentStore, imageName, ocispec.Image{})
+
+	rescanner := newSBOMRescanner(time.Hour, func(namespacedImage) {})
+
+	c := collector{
+		store: newFakeImageStore(),
+		containerdClient: &fake.MockedContainerdClient{
+			MockImageOfContainer: func(namespace string, ctn containerd.Container) (containerd.Image, error) {
+				return image, nil
+			},
+		},
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+		imagesToScan:  make(chan namespacedImage, 10),
+		sbomRescanner: rescanner,
+		usedImages:    newUsedImages(),
+	}
+
+	newContainer := func(id string, running bool) (containerd.Container, *workloadmeta.Container) {
+		return &mockedContainer{mockID: func() string { return id }}, &workloadmeta.Container{
+			EntityID: workloadmeta.EntityID{
+				Kind: workloadmeta.KindContainer,
+				ID:   id,
+			},
+			Image: workloadmeta.ContainerImage{
+				RawName: imageName,
+			},
+			State: workloadmeta.ContainerState{
+				Running: running,
+			},
+		}
+	}
+
+	// The image is not scanned while no container uses it
+	require.NoError(t, c.notifyEventForImage(context.Background(), namespace, image, nil))
+	assert.Empty(t, c.imagesToScan)
+
+	// A created container that is not running doesn't use its image yet
+	container1, entity1 := newContainer("container1", false)
+	c.handleImageUsage(namespace, container1, entity1)
+	assert.Empty(t, c.imagesToScan)
+
+	// The image is scanned once its first container starts
+	container1, entity1 = newContainer("container1", true)
+	c.handleImageUsage(namespace, container1, entity1)
+	require.Len(t, c.imagesToScan, 1)
+	imageToScan := <-c.imagesToScan
+	assert.Equal(t, imageID, imageToScan.imageID)
+	assert.False(t, imageToScan.rescan)
+
+	// What the scan worker does
+	c.scannedImages.markAsScanned(imageID)
+	rescanner.schedule(imageToScan)
+
+	// Other containers using the image don't scan it again
+	container2, entity2 := newContainer("container2", true)
+	c.handleImageUsage(namespace, container2, entity2)
+	assert.Empty(t, c.imagesToScan)
+
+	// The image is still used until its last container stops
+	container1, entity1 = newContainer("container1", false)
+	c.handleImageUsage(namespace, container1, entity1)
+	assert.True(t, c.usedImages.isUsed(imageID))
+	assert.Contains(t, rescanner.scheduled, imageID)
+
+	// Once the last container is deleted, the image can be cleaned up: it's
+	// not rescanned anymore
+	c.releaseImage("container2")
+	assert.False(t, c.usedImages.isUsed(imageID))
+	assert.NotContains(t, rescanner.scheduled, imageID)
+
+	// A new container using the image resumes its rescans, without scanning
+	// it right away
+	container3, entity3 := newContainer("container3", true)
+	c.handleImageUsage(namespace, container3, entity3)
+	assert.Empty(t, c.imagesToScan)
+	assert.Contains(t, rescanner.scheduled, imageID)
+}
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/CycloneDX/cyclonedx-go"
+)
+
+// Vulnerability is a vulnerability affecting a package of an SBOM
+type Vulnerability struct {
+	// ID of the vulnerability, like a CVE ID
+	ID       string
+	Severity cyclonedx.Severity
+	// PackageURL is the PURL of the affected component
+	PackageURL string
+	// FixedVersion is the first version of the package that is not
+	// affected. Empty when there's no fix.
+	FixedVersion string
+}
+
+// VulnerabilitySource finds the vulnerabilities affecting the components of
+// SBOMs.
+type VulnerabilitySource interface {
+	Vulnerabilities(ctx context.Context, components []cyclonedx.Component) ([]Vulnerability, error)
+}
+
+var (
+	registeredVulnerabilitySource    VulnerabilitySource
+	registeredVulnerabilitySourceMut sync.Mutex
+)
+
+// RegisterVulnerabilitySource sets the source used to attach vulnerabilities
+// to the SBOMs of the images and containers. It must be called before the
+// collector starts. When no source is registered, the vulnerabilities are
+// found in the vulnerability DB of trivy if it's enabled, see
+// prepareVulnerabilityDB, and the SBOMs are not enriched otherwise.
+func RegisterVulnerabilitySource(source VulnerabilitySource) {
+	registeredVulnerabilitySourceMut.Lock()
+	defer registeredVulnerabilitySourceMut.Unlock()
+
+	registeredVulnerabilitySource = source
+}
@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CycloneDX/cyclonedx-go"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/trivy"
+)
+
+// Maximum time given to the vulnerability source to enrich an SBOM
+const vulnerabilityEnrichmentTimeout = time.Minute
+
+func getVulnerabilitySource() VulnerabilitySource {
+	registeredVulnerabilitySourceMut.Lock()
+	defer registeredVulnerabilitySourceMut.Unlock()
+
+	return registeredVulnerabilitySource
+}
+
+// enrichSBOM returns a copy of the SBOM with the vulnerabilities of its
+// components found by the vulnerability source, replacing the ones it might
+// already have. The SBOM is returned as is when there's no source or when the
+// source fails, as the SBOM is still useful without them.
+func (c *collector) enrichSBOM(ctx context.Context, bom *cyclonedx.BOM) *cyclonedx.BOM {
+	if c.vulnerabilitySource == nil || bom == nil || bom.Components == nil {
+		return bom
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vulnerabilityEnrichmentTimeout)
+	defer cancel()
+
+	vulnerabilities, err := c.vulnerabilitySource.Vulnerabilities(ctx, *bom.Components)
+	if errors.Is(err, trivy.ErrDBUnavailable) {
+		// Until the DB is downloaded, not worth a warning for every SBOM
+		log.Debugf("vulnerability DB not available, reporting SBOM %s without vulnerabilities", bom.SerialNumber)
+		return bom
+	}
+	if err != nil {
+		log.Warnf("error getting the vulnerabilities of SBOM %s, reporting it without them: %s", bom.SerialNumber, err)
+		return bom
+	}
+
+	refs := make(map[string]cyclonedx.Component)
+	for _, component := range *bom.Components {
+		if component.PackageURL != "" {
+			refs[component.PackageURL] = component
+		}
+	}
+
+	var bomVulnerabilities []cyclonedx.Vulnerability
+	for _, vulnerability := range vulnerabilities {
+		component, found := refs[vulnerability.PackageURL]
+		if !found {
+			log.Debugf("vulnerability %s affects package %s, which is not in SBOM %s, ignoring it", vulnerability.ID, vulnerability.PackageURL, bom.SerialNumber)
+			continue
+		}
+
+		bomVulnerabilities = append(bomVulnerabilities, toCycloneDXVulnerability(vulnerability, component))
+	}
+
+	enriched := *bom
+	enriched.Vulnerabilities = &bomVulnerabilities
+	return &enriched
+}
+
+// toCycloneDXVulnerability returns the vulnerability in the CycloneDX format,
+// where the fixed version is the unaffected version of the component.
+func toCycloneDXVulnerability(vulnerability Vulnerability, component cyclonedx.Component) cyclonedx.Vulnerability {
+	ref := component.BOMRef
+	if ref == "" {
+		ref = component.PackageURL
+	}
+
+	versions := []cyclonedx.AffectedVersions{
+		{
+			Version: component.Version,
+			Status:  cyclonedx.VulnerabilityStatusAffected,
+		},
+	}
+	if vulnerability.FixedVersion != "" {
+		versions = append(versions, cyclonedx.AffectedVersions{
+			Version: vulnerability.FixedVersion,
+			Status:  cyclonedx.VulnerabilityStatusNotAffected,
+		})
+	}
+
+	severity := vulnerability.Severity
+	if severity == "" {
+		severity = cyclonedx.SeverityUnknown
+	}
+
+	return cyclonedx.Vulnerability{
+		ID: vulnerability.ID,
+		Ratings: &[]cyclonedx.VulnerabilityRating{
+			{Severity: severity},
+		},
+		Affects: &[]cyclonedx.Affects{
+			{
+				Ref:   ref,
+				Range: &versions,
+			},
+		},
+	}
+}
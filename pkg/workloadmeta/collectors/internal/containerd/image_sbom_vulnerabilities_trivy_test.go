@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd && trivy
+// +build containerd,trivy
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubVulnerabilitySource returns its vulnerabilities for every SBOM, or its
+// error.
+type stubVulnerabilitySource struct {
+	vulnerabilities []Vulnerability
+	err             error
+}
+
+func (s *stubVulnerabilitySource) Vulnerabilities(context.Context, []cyclonedx.Component) ([]Vulnerability, error) {
+	return s.vulnerabilities, s.err
+}
+
+func newTestVulnerableBOM() *cyclonedx.BOM {
+	bom := newTestBOM("urn:uuid:1")
+	bom.Components = &[]cyclonedx.Component{
+		{
+			BOMRef:     "pkg:deb/debian/curl@7.74.0",
+			Type:       cyclonedx.ComponentTypeLibrary,
+			Name:       "curl",
+			Version:    "7.74.0",
+			PackageURL: "pkg:deb/debian/curl@7.74.0",
+		},
+		{
+			Type:       cyclonedx.ComponentTypeLibrary,
+			Name:       "libc6",
+			Version:    "2.31-13",
+			PackageURL: "pkg:deb/debian/libc6@2.31-13",
+		},
+	}
+	return bom
+}
+
+var testCurlVulnerability = Vulnerability{
+	ID:           "CVE-2023-23914",
+	Severity:     cyclonedx.SeverityCritical,
+	PackageURL:   "pkg:deb/debian/curl@7.74.0",
+	FixedVersion: "7.88.0",
+}
+
+func TestEnrichSBOM(t *testing.T) {
+	bom := newTestVulnerableBOM()
+
+	c := collector{
+		vulnerabilitySource: &stubVulnerabilitySource{
+			vulnerabilities: []Vulnerability{
+				testCurlVulnerability,
+				// Not in the SBOM, ignored
+				{ID: "CVE-2023-0001", PackageURL: "pkg:deb/debian/openssl@1.1.1n"},
+			},
+		},
+	}
+
+	enriched := c.enrichSBOM(context.Background(), bom)
+	require.NotNil(t, enriched.Vulnerabilities)
+	assert.Equal(t, []cyclonedx.Vulnerability{
+		{
+			ID: "CVE-2023-23914",
+			Ratings: &[]cyclonedx.VulnerabilityRating{
+				{Severity: cyclonedx.SeverityCritical},
+			},
+			Affects: &[]cyclonedx.Affects{
+				{
+					Ref: "pkg:deb/debian/curl@7.74.0",
+					Range: &[]cyclonedx.AffectedVersions{
+						{Version: "7.74.0", Status: cyclonedx.VulnerabilityStatusAffected},
+						{Version: "7.88.0", Status: cyclonedx.VulnerabilityStatusNotAffected},
+					},
+				},
+			},
+		},
+	}, *enriched.Vulnerabilities)
+	assert.Equal(t, bom.Components, enriched.Components)
+
+	// The SBOM is not modified, it might be cached
+	assert.Nil(t, bom.Vulnerabilities)
+
+	// Enriching again replaces the vulnerabilities instead of adding to them
+	assert.Len(t, *c.enrichSBOM(context.Background(), enriched).Vulnerabilities, 1)
+}
+
+func TestEnrichSBOMWithoutFix(t *testing.T) {
+	vulnerability := testCurlVulnerability
+	vulnerability.Severity = ""
+	vulnerability.FixedVersion = ""
+	vulnerability.PackageURL = "pkg:deb/debian/libc6@2.31-13"
+
+	c := collector{
+		vulnerabilitySource: &stubVulnerabilitySource{vulnerabilities: []Vulnerability{vulnerability}},
+	}
+
+	enriched := c.enrichSBOM(context.Background(), newTestVulnerableBOM())
+	require.Len(t, *enriched.Vulnerabilities, 1)
+
+	// The PURL is the reference of the components without one
+	found := (*enriched.Vulnerabilities)[0]
+	assert.Equal(t, cyclonedx.SeverityUnknown, (*found.Ratings)[0].Severity)
+	assert.Equal(t, "pkg:deb/debian/libc6@2.31-13", (*found.Affects)[0].Ref)
+	assert.Equal(t, []cyclonedx.AffectedVersions{
+		{Version: "2.31-13", Status: cyclonedx.VulnerabilityStatusAffected},
+	}, *(*found.Affects)[0].Range)
+}
+
+func TestEnrichSBOMDegradesToSBOMOnly(t *testing.T) {
+	bom := newTestVulnerableBOM()
+
+	// No source
+	c := collector{}
+	assert.Same(t, bom, c.enrichSBOM(context.Background(), bom))
+
+	// Failing source
+	c.vulnerabilitySource = &stubVulnerabilitySource{err: errors.New("vulnerability database unavailable")}
+	assert.Same(t, bom, c.enrichSBOM(context.Background(), bom))
+	assert.Nil(t, bom.Vulnerabilities)
+}
+
+func TestRegisterVulnerabilitySource(t *testing.T) {
+	t.Cleanup(func() { RegisterVulnerabilitySource(nil) })
+
+	assert.Nil(t, getVulnerabilitySource())
+
+	source := &stubVulnerabilitySource{}
+	RegisterVulnerabilitySource(source)
+	assert.Same(t, source, getVulnerabilitySource())
+}
@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+const (
+	// cosign stores the signatures and attestations of an image in the same
+	// repository, under the tag of the digest of the image with these
+	// suffixes, e.g. sha256-<hex>.sig
+	cosignSignatureTagSuffix   = ".sig"
+	cosignAttestationTagSuffix = ".att"
+
+	// Artifact types of the referrers of an image, listed in the index of
+	// the referrers tag schema of OCI, under the tag of the digest of the
+	// image without suffix, e.g. sha256-<hex>
+	cosignSignatureArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	dsseEnvelopeArtifactType    = "application/vnd.dsse.envelope.v1+json"
+	inTotoArtifactType          = "application/vnd.in-toto+json"
+)
+
+// artifactTagRegexp matches the tags of the signatures, attestations and
+// referrers of an image. The submatch is the hex part of the digest of the
+// image.
+var artifactTagRegexp = regexp.MustCompile(`:sha256-([a-f0-9]{64})(\.sig|\.att)?$`)
+
+// artifactTag returns the tag of the supply chain artifacts of the image with
+// the given digest, without suffix.
+func artifactTag(imageDigest digest.Digest) string {
+	return imageDigest.Algorithm().String() + "-" + imageDigest.Encoded()
+}
+
+// getImageSignatures returns the summary of the signatures and attestations
+// of the image with the given name and digest, found in the namespace under
+// the tags of the cosign convention or of the referrers tag schema. It returns
+// nil when they can't be looked up, as whether the image is signed is
+// unknown then.
+func (c *collector) getImageSignatures(ctx context.Context, namespace string, imageName string, imageDigest digest.Digest) *workloadmeta.ContainerImageSignatures {
+	repo := imageRepository(imageName)
+	tag := repo + ":" + artifactTag(imageDigest)
+
+	signatures := &workloadmeta.ContainerImageSignatures{}
+
+	for _, suffix := range []string{cosignSignatureTagSuffix, cosignAttestationTagSuffix} {
+		name := tag + suffix
+		found, err := c.hasImage(namespace, name)
+		if err != nil {
+			log.Debugf("Cannot look up artifact %s of image %s: %s", name, imageName, err)
+			return nil
+		}
+		if !found {
+			continue
+		}
+
+		signatures.Artifacts = append(signatures.Artifacts, name)
+		if suffix == cosignSignatureTagSuffix {
+			signatures.Signed = true
+		} else {
+			signatures.Attested = true
+		}
+	}
+
+	referrers, err := c.getReferrers(ctx, namespace, tag)
+	if err != nil {
+		log.Debugf("Cannot look up the referrers %s of image %s: %s", tag, imageName, err)
+		return nil
+	}
+
+	for _, referrer := range referrers {
+		switch referrer.ArtifactType {
+		case cosignSignatureArtifactType:
+			signatures.Signed = true
+		case dsseEnvelopeArtifactType, inTotoArtifactType:
+			signatures.Attested = true
+		default:
+			continue
+		}
+		signatures.Artifacts = append(signatures.Artifacts, repo+"@"+referrer.Digest.String())
+	}
+
+	return signatures
+}
+
+// hasImage returns whether the image with the given name exists in the
+// namespace.
+func (c *collector) hasImage(namespace string, name string) (bool, error) {
+	if _, err := c.containerdClient.Image(namespace, name); err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// getReferrers returns the descriptors of the index of the referrers tag
+// schema with the given name, or nil when there's none.
+func (c *collector) getReferrers(ctx context.Context, namespace string, name string) ([]ocispec.Descriptor, error) {
+	img, err := c.containerdClient.Image(namespace, name)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if img.Target().MediaType != ocispec.MediaTypeImageIndex && img.Target().MediaType != images.MediaTypeDockerSchema2ManifestList {
+		return nil, nil
+	}
+
+	blob, err := content.ReadBlob(ctx, img.ContentStore(), img.Target())
+	if err != nil {
+		return nil, err
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(blob, &index); err != nil {
+		return nil, err
+	}
+
+	return index.Manifests, nil
+}
+
+// signedImageDigest returns the digest of the image that the artifact with
+// the given name, following the tag conventions of the supply chain
+// artifacts, is for.
+func signedImageDigest(artifactName string) (string, bool) {
+	matches := artifactTagRegexp.FindStringSubmatch(artifactName)
+	if matches == nil {
+		return "", false
+	}
+	return "sha256:" + matches[1], true
+}
+
+// refreshSignedImage notifies again the image that the artifact with the
+// given name is for, when it's known, as its signatures changed.
+func (c *collector) refreshSignedImage(ctx context.Context, artifactName string) {
+	if !c.collectImageSignatures {
+		return
+	}
+
+	imageDigest, ok := signedImageDigest(artifactName)
+	if !ok {
+		return
+	}
+
+	signedImage, err := c.store.GetImageByDigest(imageDigest)
+	if err != nil {
+		return
+	}
+
+	reference, found := c.knownImages.getReference(signedImage.ID, signedImage.Name)
+	if !found {
+		return
+	}
+
+	if err := c.handleImageCreateOrUpdate(ctx, reference.namespace, reference.name, nil); err != nil {
+		log.Debugf("Cannot refresh the signatures of image %s: %s", reference.name, err)
+	}
+}
@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
+	containerdevents "github.com/containerd/containerd/events"
+	"github.com/containerd/typeurl"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// newSignaturesTestCollector returns a collector looking for the signatures
+// of the images, which are looked up by name in the given images.
+func newSignaturesTestCollector(store workloadmeta.Store, images map[string]containerd.Image) *collector {
+	return &collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockImage: func(namespace string, name string) (containerd.Image, error) {
+				if img, found := images[name]; found {
+					return img, nil
+				}
+				return nil, fmt.Errorf("image %q: %w", name, errdefs.ErrNotFound)
+			},
+		},
+		knownImages:            newKnownImages(),
+		repoTags:               make(map[string][]string),
+		scannedImages:          newScannedImages(),
+		collectImageSignatures: true,
+	}
+}
+
+func TestImageSignatures(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	signedImage, _ := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "signed"})
+	unsignedImage, _ := newFakeImage(t, contentStore, "docker.io/datadog/cluster-agent:7", ocispec.Image{Author: "unsigned"})
+
+	// The signature follows the tag convention of cosign, the attestation is
+	// a referrer
+	signatureName := "docker.io/datadog/agent:" + artifactTag(signedImage.target.Digest) + cosignSignatureTagSuffix
+	signature, _ := newFakeImage(t, contentStore, signatureName, ocispec.Image{})
+
+	attestationDigest := digest.FromString("attestation")
+	referrersIndex := ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			{
+				MediaType:    ocispec.MediaTypeImageManifest,
+				ArtifactType: dsseEnvelopeArtifactType,
+				Digest:       attestationDigest,
+			},
+			{
+				MediaType:    ocispec.MediaTypeImageManifest,
+				ArtifactType: "application/vnd.example.unrelated",
+				Digest:       digest.FromString("unrelated"),
+			},
+		},
+	}
+	referrersIndex.SchemaVersion = 2
+	referrersName := "docker.io/datadog/agent:" + artifactTag(signedImage.target.Digest)
+	referrers := &fakeImage{
+		name:   referrersName,
+		store:  contentStore,
+		target: writeBlob(t, contentStore, ocispec.MediaTypeImageIndex, referrersIndex),
+	}
+
+	store := newFakeImageStore()
+	c := newSignaturesTestCollector(store, map[string]containerd.Image{
+		signatureName: signature,
+		referrersName: referrers,
+	})
+
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", signedImage, nil))
+	reported := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, &workloadmeta.ContainerImageSignatures{
+		Signed:    true,
+		Attested:  true,
+		Artifacts: []string{signatureName, "docker.io/datadog/agent@" + attestationDigest.String()},
+	}, reported.Signatures)
+
+	// The absence of signatures is reported
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", unsignedImage, nil))
+	reported = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, &workloadmeta.ContainerImageSignatures{}, reported.Signatures)
+
+	// The signatures are unknown when they can't be looked up
+	c.containerdClient = &fake.MockedContainerdClient{
+		MockImage: func(namespace string, name string) (containerd.Image, error) {
+			return nil, fmt.Errorf("connection refused: %w", errdefs.ErrUnavailable)
+		},
+	}
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", unsignedImage, nil))
+	reported = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Nil(t, reported.Signatures)
+
+	// And aren't looked up when it's disabled
+	c.collectImageSignatures = false
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", signedImage, nil))
+	reported = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Nil(t, reported.Signatures)
+}
+
+func TestImageSignaturesRefreshed(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	image, imageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "signed"})
+	images := map[string]containerd.Image{image.Name(): image}
+
+	store := newFakeImageStore()
+	c := newSignaturesTestCollector(store, images)
+
+	require.NoError(t, c.handleImageCreateOrUpdate(context.Background(), "default", image.Name(), nil))
+	reported := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.False(t, reported.Signatures.Signed)
+	store.Set(reported)
+
+	// The image is notified again when its signature is pulled
+	signatureName := "docker.io/datadog/agent:" + artifactTag(image.target.Digest) + cosignSignatureTagSuffix
+	images[signatureName], _ = newFakeImage(t, contentStore, signatureName, ocispec.Image{})
+
+	creation, err := typeurl.MarshalAny(&events.ImageCreate{Name: signatureName})
+	require.NoError(t, err)
+	require.NoError(t, c.handleEvent(context.Background(), &containerdevents.Envelope{
+		Namespace: "default",
+		Topic:     imageCreationTopic,
+		Event:     creation,
+	}))
+
+	var refreshed *workloadmeta.ContainerImageMetadata
+	for len(store.events) > 0 {
+		event := <-store.events
+		if event.Entity.GetID().ID == imageID {
+			refreshed = event.Entity.(*workloadmeta.ContainerImageMetadata)
+		}
+	}
+	require.NotNil(t, refreshed)
+	assert.True(t, refreshed.Signatures.Signed)
+	assert.Equal(t, []string{signatureName}, refreshed.Signatures.Artifacts)
+	store.Set(refreshed)
+
+	// And when it's deleted
+	delete(images, signatureName)
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "default", signatureName)))
+
+	refreshed = nil
+	for len(store.events) > 0 {
+		event := <-store.events
+		if event.Type == workloadmeta.EventTypeSet && event.Entity.GetID().ID == imageID {
+			refreshed = event.Entity.(*workloadmeta.ContainerImageMetadata)
+		}
+	}
+	require.NotNil(t, refreshed)
+	assert.False(t, refreshed.Signatures.Signed)
+}
+
+func TestSignedImageDigest(t *testing.T) {
+	hex := digest.FromString("image").Encoded()
+
+	for _, name := range []string{
+		"docker.io/datadog/agent:sha256-" + hex + ".sig",
+		"docker.io/datadog/agent:sha256-" + hex + ".att",
+		"localhost:5000/agent:sha256-" + hex,
+	} {
+		imageDigest, ok := signedImageDigest(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, "sha256:"+hex, imageDigest, name)
+	}
+
+	for _, name := range []string{
+		"docker.io/datadog/agent:7",
+		"docker.io/datadog/agent@sha256:" + hex,
+		"docker.io/datadog/agent:sha256-" + hex + ".sbom",
+	} {
+		_, ok := signedImageDigest(name)
+		assert.False(t, ok, name)
+	}
+}
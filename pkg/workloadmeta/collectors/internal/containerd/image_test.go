@@ -9,37 +9,560 @@
 package containerd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	containerdevents "github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/typeurl"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+	workloadmetaTesting "github.com/DataDog/datadog-agent/pkg/workloadmeta/testing"
 )
 
+// fakeImageStore is a workloadmeta store, pre-filled with images, which
+// records the events it's notified of.
+type fakeImageStore struct {
+	*workloadmetaTesting.Store
+	events chan workloadmeta.CollectorEvent
+}
+
+func newFakeImageStore(images ...*workloadmeta.ContainerImageMetadata) *fakeImageStore {
+	store := &fakeImageStore{
+		Store:  workloadmetaTesting.NewStore(),
+		events: make(chan workloadmeta.CollectorEvent, 100),
+	}
+
+	for _, image := range images {
+		store.Set(image)
+	}
+
+	return store
+}
+
+func (s *fakeImageStore) Notify(events []workloadmeta.CollectorEvent) {
+	for _, event := range events {
+		s.events <- event
+	}
+}
+
+// fakeImage is a containerd image whose manifest and config are stored in a
+// local content store.
+type fakeImage struct {
+	containerd.Image
+	name      string
+	labels    map[string]string
+	store     content.Store
+	target    ocispec.Descriptor
+	createdAt time.Time
+}
+
+func (i *fakeImage) Name() string                      { return i.name }
+func (i *fakeImage) Labels() map[string]string         { return i.labels }
+func (i *fakeImage) ContentStore() content.Store       { return i.store }
+func (i *fakeImage) Target() ocispec.Descriptor        { return i.target }
+func (i *fakeImage) Platform() platforms.MatchComparer { return platforms.Default() }
+
+func (i *fakeImage) Metadata() images.Image {
+	return images.Image{
+		Name:      i.name,
+		Labels:    i.labels,
+		Target:    i.target,
+		CreatedAt: i.createdAt,
+	}
+}
+
+// newFakeImage writes an image made of the given config to the content store,
+// and returns it with its ID (the digest of its config).
+func newFakeImage(t *testing.T, store content.Store, name string, config ocispec.Image) (*fakeImage, string) {
+	return newFakeImageWithLayers(t, store, name, config)
+}
+
+// newFakeImageWithLayers is like newFakeImage, for an image made of the given
+// layers. The content of the layers is not written to the content store.
+func newFakeImageWithLayers(t *testing.T, store content.Store, name string, config ocispec.Image, layers ...ocispec.Descriptor) (*fakeImage, string) {
+	platform := platforms.DefaultSpec()
+	config.OS = platform.OS
+	config.Architecture = platform.Architecture
+	configDesc := writeBlob(t, store, ocispec.MediaTypeImageConfig, config)
+
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    layers,
+	}
+	manifest.SchemaVersion = 2
+	manifestDesc := writeBlob(t, store, ocispec.MediaTypeImageManifest, manifest)
+
+	return &fakeImage{
+		name:   name,
+		store:  store,
+		target: manifestDesc,
+	}, configDesc.Digest.String()
+}
+
+func writeBlob(t *testing.T, store content.Store, mediaType string, v interface{}) ocispec.Descriptor {
+	blob, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	require.NoError(t, content.WriteBlob(context.Background(), store, desc.Digest.String(), bytes.NewReader(blob), desc))
+
+	return desc
+}
+
+func newImageDeletionEvent(t *testing.T, namespace string, imageName string) *containerdevents.Envelope {
+	event, err := typeurl.MarshalAny(&events.ImageDelete{Name: imageName})
+	require.NoError(t, err)
+
+	return &containerdevents.Envelope{
+		Namespace: namespace,
+		Topic:     imageDeletionTopic,
+		Event:     event,
+	}
+}
+
 func TestKnownImages(t *testing.T) {
 	images := newKnownImages()
 
 	// Add a first image name that refers to the "123" ID
-	images.addAssociation("agent:7", "123")
-	imageID, found := images.getImageID("agent:7")
+	images.addAssociation("default", "agent:7", "123")
+	imageID, found := images.getImageID("default", "agent:7")
 	assert.True(t, found)
 	assert.Equal(t, "123", imageID)
 	assert.True(t, images.isReferenced("123"))
 
 	// Add a second image that refers to the "123" ID
-	images.addAssociation("agent:latest", "123")
-	imageID, found = images.getImageID("agent:latest")
+	images.addAssociation("default", "agent:latest", "123")
+	imageID, found = images.getImageID("default", "agent:latest")
 	assert.True(t, found)
 	assert.Equal(t, "123", imageID)
 	assert.True(t, images.isReferenced("123"))
 
 	// Delete one of the associations
-	images.deleteAssociation("agent:latest", "123")
-	_, found = images.getImageID("agent:latest")
+	images.deleteAssociation("default", "agent:latest", "123")
+	_, found = images.getImageID("default", "agent:latest")
 	assert.False(t, found)
 	assert.True(t, images.isReferenced("123")) // Still referenced by the other
 
 	// Delete the other association
-	images.deleteAssociation("agent:7", "123")
-	_, found = images.getImageID("agent:7")
+	images.deleteAssociation("default", "agent:7", "123")
+	_, found = images.getImageID("default", "agent:7")
 	assert.False(t, found)
 	assert.False(t, images.isReferenced("123"))
 }
+
+func TestKnownImagesMultipleNamespaces(t *testing.T) {
+	images := newKnownImages()
+
+	// The same name refers to different images in different namespaces
+	images.addAssociation("ns1", "agent:7", "123")
+	images.addAssociation("ns2", "agent:7", "456")
+
+	imageID, found := images.getImageID("ns1", "agent:7")
+	assert.True(t, found)
+	assert.Equal(t, "123", imageID)
+
+	imageID, found = images.getImageID("ns2", "agent:7")
+	assert.True(t, found)
+	assert.Equal(t, "456", imageID)
+
+	_, found = images.getImageID("ns3", "agent:7")
+	assert.False(t, found)
+
+	// The same image is referenced in two namespaces
+	images.addAssociation("ns3", "agent:7", "123")
+	images.deleteAssociation("ns1", "agent:7", "123")
+	assert.True(t, images.isReferenced("123"))
+	assert.True(t, images.isReferencedWithName("123", "agent:7"))
+
+	reference, found := images.getReference("123", "")
+	assert.True(t, found)
+	assert.Equal(t, namespacedName{namespace: "ns3", name: "agent:7"}, reference)
+
+	images.deleteAssociation("ns3", "agent:7", "123")
+	assert.False(t, images.isReferenced("123"))
+	assert.False(t, images.isReferencedWithName("123", "agent:7"))
+	assert.True(t, images.isReferenced("456"))
+
+	_, found = images.getReference("123", "")
+	assert.False(t, found)
+}
+
+func TestScannedImages(t *testing.T) {
+	images := newScannedImages()
+
+	assert.False(t, images.isScanned("123"))
+
+	assert.True(t, images.markAsScanned("123"))
+	assert.True(t, images.isScanned("123"))
+
+	// Marking it again reports that it was already scanned
+	assert.False(t, images.markAsScanned("123"))
+
+	images.forget("123")
+	assert.False(t, images.isScanned("123"))
+	assert.True(t, images.markAsScanned("123"))
+}
+
+func TestEnqueueImageToScanDoesNotBlock(t *testing.T) {
+	c := collector{
+		imagesToScan: make(chan namespacedImage, 1),
+	}
+
+	imageToScan := namespacedImage{
+		namespace: "default",
+		image: &mockedImage{
+			mockName: func() string { return "agent" },
+		},
+		imageID: "sha256:1",
+	}
+
+	c.enqueueImageToScan(imageToScan)
+
+	// The queue is full, the image is dropped
+	c.enqueueImageToScan(imageToScan)
+
+	assert.Len(t, c.imagesToScan, 1)
+}
+
+func TestImageDeletionSingleTag(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	image, imageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "datadog"})
+
+	cache, _ := newTestSBOMCache(t, 0, 0)
+	require.NoError(t, cache.set(imageID, newTestBOM("urn:uuid:1")))
+
+	store := newFakeImageStore()
+	c := collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockImage: func(namespace string, name string) (containerd.Image, error) {
+				return image, nil
+			},
+		},
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+		sbomCache:     cache,
+	}
+
+	require.NoError(t, c.handleImageCreateOrUpdate(context.Background(), "default", image.Name(), nil))
+	event := <-store.events
+	assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+	assert.Equal(t, imageID, event.Entity.GetID().ID)
+	c.scannedImages.markAsScanned(imageID)
+
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "default", image.Name())))
+
+	event = <-store.events
+	assert.Equal(t, workloadmeta.EventTypeUnset, event.Type)
+	assert.Equal(t, imageID, event.Entity.GetID().ID)
+
+	_, found := cache.get(imageID)
+	assert.False(t, found, "the cached SBOM should be purged")
+	assert.False(t, c.scannedImages.isScanned(imageID))
+	assert.False(t, c.knownImages.isReferenced(imageID))
+	assert.NotContains(t, c.repoTags, imageID)
+	assert.Empty(t, store.events)
+}
+
+func TestImageDeletionMultipleTags(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	// The same image, referenced by two names
+	image7, imageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "datadog"})
+	imageLatest, otherImageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:latest", ocispec.Image{Author: "datadog"})
+	require.Equal(t, imageID, otherImageID)
+	images := map[string]*fakeImage{
+		image7.Name():      image7,
+		imageLatest.Name(): imageLatest,
+	}
+
+	cache, _ := newTestSBOMCache(t, 0, 0)
+	require.NoError(t, cache.set(imageID, newTestBOM("urn:uuid:1")))
+
+	store := newFakeImageStore()
+	c := collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockImage: func(namespace string, name string) (containerd.Image, error) {
+				return images[name], nil
+			},
+		},
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+		sbomCache:     cache,
+	}
+
+	for _, image := range []*fakeImage{image7, imageLatest} {
+		require.NoError(t, c.handleImageCreateOrUpdate(context.Background(), "default", image.Name(), nil))
+		<-store.events
+	}
+	assert.ElementsMatch(t, []string{image7.Name(), imageLatest.Name()}, c.repoTags[imageID])
+
+	// Deleting the first tag only updates the repo tags of the image
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "default", image7.Name())))
+
+	event := <-store.events
+	assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+	assert.Equal(t, []string{imageLatest.Name()}, event.Entity.(*workloadmeta.ContainerImageMetadata).RepoTags)
+
+	_, found := cache.get(imageID)
+	assert.True(t, found, "the cached SBOM should be kept while the image is referenced")
+	assert.True(t, c.knownImages.isReferenced(imageID))
+
+	// Deleting the last tag unsets the image and purges its SBOM
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "default", imageLatest.Name())))
+
+	event = <-store.events
+	assert.Equal(t, workloadmeta.EventTypeUnset, event.Type)
+	assert.Equal(t, imageID, event.Entity.GetID().ID)
+
+	_, found = cache.get(imageID)
+	assert.False(t, found, "the cached SBOM should be purged")
+	assert.Empty(t, store.events)
+}
+
+func TestImageDeletionMultipleNamespaces(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	// The same image, pulled with the same name in two namespaces
+	image, imageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "datadog"})
+
+	// A different image with the same name in a third namespace
+	otherImage, otherImageID := newFakeImage(t, contentStore, "docker.io/datadog/agent:7", ocispec.Image{Author: "someone else"})
+	require.NotEqual(t, imageID, otherImageID)
+
+	imagesByNamespace := map[string]*fakeImage{
+		"ns1": image,
+		"ns2": image,
+		"ns3": otherImage,
+	}
+
+	store := newFakeImageStore()
+	c := collector{
+		store: store,
+		containerdClient: &fake.MockedContainerdClient{
+			MockImage: func(namespace string, name string) (containerd.Image, error) {
+				return imagesByNamespace[namespace], nil
+			},
+		},
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+	}
+
+	for _, namespace := range []string{"ns1", "ns2", "ns3"} {
+		require.NoError(t, c.handleImageCreateOrUpdate(context.Background(), namespace, image.Name(), nil))
+		event := <-store.events
+		assert.Equal(t, imagesByNamespace[namespace].Name(), event.Entity.(*workloadmeta.ContainerImageMetadata).Name)
+		assert.Equal(t, namespace, event.Entity.(*workloadmeta.ContainerImageMetadata).Namespace)
+	}
+	assert.Empty(t, store.events, "the image of ns3 should not remove the name of the image of ns1 and ns2")
+
+	// Deleting the image in ns1 keeps it, as it's still referenced in ns2
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "ns1", image.Name())))
+
+	event := <-store.events
+	assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+	assert.Equal(t, imageID, event.Entity.GetID().ID)
+	assert.Equal(t, "ns2", event.Entity.(*workloadmeta.ContainerImageMetadata).Namespace)
+	assert.Equal(t, []string{image.Name()}, event.Entity.(*workloadmeta.ContainerImageMetadata).RepoTags)
+
+	// Deleting it in ns2 unsets it, without affecting the image of ns3
+	require.NoError(t, c.handleEvent(context.Background(), newImageDeletionEvent(t, "ns2", image.Name())))
+
+	event = <-store.events
+	assert.Equal(t, workloadmeta.EventTypeUnset, event.Type)
+	assert.Equal(t, imageID, event.Entity.GetID().ID)
+
+	assert.True(t, c.knownImages.isReferenced(otherImageID))
+	assert.Equal(t, []string{otherImage.Name()}, c.repoTags[otherImageID])
+	assert.Empty(t, store.events)
+}
+
+func TestImageSizeAndPullTime(t *testing.T) {
+	contentStore, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	platform := platforms.DefaultSpec()
+	configDesc := writeBlob(t, contentStore, ocispec.MediaTypeImageConfig, ocispec.Image{
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+	})
+
+	newImage := func(name string, createdAt time.Time, layerSizes ...int64) *fakeImage {
+		manifest := ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    configDesc,
+		}
+		manifest.SchemaVersion = 2
+		for i, size := range layerSizes {
+			manifest.Layers = append(manifest.Layers, ocispec.Descriptor{
+				MediaType: ocispec.MediaTypeImageLayerGzip,
+				Digest:    digest.FromString(name + string(rune('0'+i))),
+				Size:      size,
+			})
+		}
+
+		return &fakeImage{
+			name:      name,
+			store:     contentStore,
+			target:    writeBlob(t, contentStore, ocispec.MediaTypeImageManifest, manifest),
+			createdAt: createdAt,
+		}
+	}
+
+	pulledAt := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	store := newFakeImageStore()
+	c := collector{
+		store:         store,
+		knownImages:   newKnownImages(),
+		repoTags:      make(map[string][]string),
+		scannedImages: newScannedImages(),
+	}
+
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", newImage("docker.io/datadog/agent:7", pulledAt, 1000, 234), nil))
+	image := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, int64(1234), image.SizeBytes)
+	assert.Equal(t, pulledAt, image.PulledAt)
+
+	// The image was pulled when its first name was created
+	store.Set(image)
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", newImage("docker.io/datadog/agent:latest", pulledAt.Add(time.Hour), 1000, 234), nil))
+	image = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, pulledAt, image.PulledAt)
+
+	// The size is left unset when the size of a layer is unknown
+	require.NoError(t, c.notifyEventForImage(context.Background(), "default", newImage("docker.io/datadog/cluster-agent:7", pulledAt, 1000, 0), nil))
+	image = (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+	assert.Equal(t, int64(0), image.SizeBytes)
+	assert.Equal(t, pulledAt, image.PulledAt)
+}
+
+func TestImagePlatform(t *testing.T) {
+	defaultPlatform := platforms.DefaultSpec()
+
+	tests := []struct {
+		name             string
+		newTarget        func(t *testing.T, store content.Store) ocispec.Descriptor
+		expectedPlatform ocispec.Platform
+	}{
+		{
+			name: "single-platform image",
+			newTarget: func(t *testing.T, store content.Store) ocispec.Descriptor {
+				manifest := ocispec.Manifest{
+					MediaType: ocispec.MediaTypeImageManifest,
+					Config: writeBlob(t, store, ocispec.MediaTypeImageConfig, ocispec.Image{
+						OS:           "linux",
+						Architecture: "arm",
+						Variant:      "v6",
+					}),
+				}
+				manifest.SchemaVersion = 2
+
+				return writeBlob(t, store, ocispec.MediaTypeImageManifest, manifest)
+			},
+			expectedPlatform: ocispec.Platform{
+				OS:           "linux",
+				Architecture: "arm",
+				Variant:      "v6",
+			},
+		},
+		{
+			name: "multi-platform image",
+			newTarget: func(t *testing.T, store content.Store) ocispec.Descriptor {
+				newManifest := func(architecture string) ocispec.Descriptor {
+					manifest := ocispec.Manifest{
+						MediaType: ocispec.MediaTypeImageManifest,
+						Config: writeBlob(t, store, ocispec.MediaTypeImageConfig, ocispec.Image{
+							OS:           "linux",
+							Architecture: architecture,
+						}),
+					}
+					manifest.SchemaVersion = 2
+
+					return writeBlob(t, store, ocispec.MediaTypeImageManifest, manifest)
+				}
+
+				// The platform of the image is not the first one of the index,
+				// and its entry has fields that are not in the config
+				other := newManifest("s390x")
+				other.Platform = &ocispec.Platform{OS: "linux", Architecture: "s390x"}
+
+				resolved := newManifest(defaultPlatform.Architecture)
+				resolvedPlatform := defaultPlatform
+				resolvedPlatform.OSVersion = "5.10"
+				resolved.Platform = &resolvedPlatform
+
+				index := ocispec.Index{
+					MediaType: ocispec.MediaTypeImageIndex,
+					Manifests: []ocispec.Descriptor{other, resolved},
+				}
+				index.SchemaVersion = 2
+
+				return writeBlob(t, store, ocispec.MediaTypeImageIndex, index)
+			},
+			expectedPlatform: ocispec.Platform{
+				OS:           defaultPlatform.OS,
+				OSVersion:    "5.10",
+				Architecture: defaultPlatform.Architecture,
+				Variant:      defaultPlatform.Variant,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentStore, err := local.NewStore(t.TempDir())
+			require.NoError(t, err)
+
+			store := newFakeImageStore()
+			c := collector{
+				store:         store,
+				knownImages:   newKnownImages(),
+				repoTags:      make(map[string][]string),
+				scannedImages: newScannedImages(),
+			}
+
+			img := &fakeImage{
+				name:   "docker.io/datadog/agent:7",
+				store:  contentStore,
+				target: test.newTarget(t, contentStore),
+			}
+			require.NoError(t, c.notifyEventForImage(context.Background(), "default", img, nil))
+
+			image := (<-store.events).Entity.(*workloadmeta.ContainerImageMetadata)
+			assert.Equal(t, test.expectedPlatform, ocispec.Platform{
+				OS:           image.OS,
+				OSVersion:    image.OSVersion,
+				Architecture: image.Architecture,
+				Variant:      image.Variant,
+			})
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+
+	"github.com/containerd/containerd"
+
+	cutil "github.com/DataDog/datadog-agent/pkg/util/containerd"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+// unknownRuntimeVersion is reported when containerd doesn't tell its version
+const unknownRuntimeVersion = "unknown"
+
+// notifyRuntimeEvent sets the entity describing the containerd daemon the
+// collector talks to. It's only done once at startup, as the version can't
+// change without restarting containerd, which closes the event subscription
+// and restarts the collector. The entity is unset when the collector stops,
+// see notifyRuntimeUnsetEvent.
+func (c *collector) notifyRuntimeEvent(ctx context.Context) {
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeSet,
+			Source: workloadmeta.SourceRuntime,
+			Entity: c.runtimeMetadata(ctx),
+		},
+	})
+}
+
+// notifyRuntimeUnsetEvent unsets the entity set by notifyRuntimeEvent.
+func (c *collector) notifyRuntimeUnsetEvent() {
+	c.store.Notify([]workloadmeta.CollectorEvent{
+		{
+			Type:   workloadmeta.EventTypeUnset,
+			Source: workloadmeta.SourceRuntime,
+			Entity: &workloadmeta.ContainerRuntimeMetadata{
+				EntityID: runtimeEntityID(),
+			},
+		},
+	})
+}
+
+func runtimeEntityID() workloadmeta.EntityID {
+	return workloadmeta.EntityID{
+		Kind: workloadmeta.KindContainerRuntime,
+		ID:   string(workloadmeta.ContainerRuntimeContainerd),
+	}
+}
+
+func (c *collector) runtimeMetadata(ctx context.Context) *workloadmeta.ContainerRuntimeMetadata {
+	runtime := &workloadmeta.ContainerRuntimeMetadata{
+		EntityID:    runtimeEntityID(),
+		Runtime:     workloadmeta.ContainerRuntimeContainerd,
+		Version:     unknownRuntimeVersion,
+		Revision:    unknownRuntimeVersion,
+		Snapshotter: c.runtimeSnapshotter(ctx),
+	}
+
+	version, err := c.containerdClient.Metadata()
+	if err != nil {
+		log.Warnf("error getting the version of containerd, reporting it as %s: %s", unknownRuntimeVersion, err)
+		return runtime
+	}
+
+	if version.Version != "" {
+		runtime.Version = version.Version
+	}
+	if version.Revision != "" {
+		runtime.Revision = version.Revision
+	}
+
+	return runtime
+}
+
+// runtimeSnapshotter returns the snapshotter of the containers of the first
+// watched namespace that has some. It's the default snapshotter of containerd
+// when there are no containers.
+func (c *collector) runtimeSnapshotter(ctx context.Context) string {
+	namespaces, err := cutil.NamespacesToWatch(ctx, c.containerdClient)
+	if err != nil {
+		log.Warnf("error listing the containerd namespaces, reporting the default snapshotter: %s", err)
+		return containerd.DefaultSnapshotter
+	}
+
+	for _, namespace := range namespaces {
+		snapshotter, err := c.containerdClient.Snapshotter(namespace)
+		if err != nil {
+			log.Debugf("error getting the snapshotter of containerd namespace %s: %s", namespace, err)
+			continue
+		}
+
+		if snapshotter != "" {
+			return snapshotter
+		}
+	}
+
+	return containerd.DefaultSnapshotter
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build containerd
+// +build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/util/containerd/fake"
+	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
+)
+
+func TestNotifyRuntimeEvent(t *testing.T) {
+	tests := []struct {
+		name            string
+		metadata        func() (containerd.Version, error)
+		snapshotters    map[string]string
+		expectedRuntime *workloadmeta.ContainerRuntimeMetadata
+	}{
+		{
+			name: "version available",
+			metadata: func() (containerd.Version, error) {
+				return containerd.Version{Version: "v1.6.20", Revision: "2806fc1057397dbaeefbea0e4e17bddfbd388f38"}, nil
+			},
+			snapshotters: map[string]string{"default": "", "k8s.io": "zfs"},
+			expectedRuntime: &workloadmeta.ContainerRuntimeMetadata{
+				EntityID: workloadmeta.EntityID{
+					Kind: workloadmeta.KindContainerRuntime,
+					ID:   "containerd",
+				},
+				Runtime:     workloadmeta.ContainerRuntimeContainerd,
+				Version:     "v1.6.20",
+				Revision:    "2806fc1057397dbaeefbea0e4e17bddfbd388f38",
+				Snapshotter: "zfs",
+			},
+		},
+		{
+			name: "version API unavailable",
+			metadata: func() (containerd.Version, error) {
+				return containerd.Version{}, errors.New("unimplemented")
+			},
+			snapshotters: map[string]string{"default": ""},
+			expectedRuntime: &workloadmeta.ContainerRuntimeMetadata{
+				EntityID: workloadmeta.EntityID{
+					Kind: workloadmeta.KindContainerRuntime,
+					ID:   "containerd",
+				},
+				Runtime:     workloadmeta.ContainerRuntimeContainerd,
+				Version:     "unknown",
+				Revision:    "unknown",
+				Snapshotter: "overlayfs",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeImageStore()
+			c := collector{
+				store: store,
+				containerdClient: &fake.MockedContainerdClient{
+					MockMetadata: test.metadata,
+					MockNamespaces: func(context.Context) ([]string, error) {
+						return []string{"default", "k8s.io"}, nil
+					},
+					MockSnapshotter: func(namespace string) (string, error) {
+						return test.snapshotters[namespace], nil
+					},
+				},
+			}
+
+			c.notifyRuntimeEvent(context.Background())
+
+			assert.Len(t, store.events, 1)
+			event := <-store.events
+			assert.Equal(t, workloadmeta.EventTypeSet, event.Type)
+			assert.Equal(t, workloadmeta.SourceRuntime, event.Source)
+			assert.Equal(t, test.expectedRuntime, event.Entity)
+		})
+	}
+}
+
+func TestNotifyRuntimeUnsetEvent(t *testing.T) {
+	store := newFakeImageStore()
+	c := collector{store: store}
+
+	c.notifyRuntimeUnsetEvent()
+
+	assert.Len(t, store.events, 1)
+	event := <-store.events
+	assert.Equal(t, workloadmeta.EventTypeUnset, event.Type)
+	assert.Equal(t, workloadmeta.SourceRuntime, event.Source)
+	assert.Equal(t, workloadmeta.EntityID{
+		Kind: workloadmeta.KindContainerRuntime,
+		ID:   "containerd",
+	}, event.Entity.GetID())
+}
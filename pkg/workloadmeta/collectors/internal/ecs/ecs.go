@@ -48,6 +48,10 @@ func init() {
 			seen:         make(map[workloadmeta.EntityID]struct{}),
 		}
 	})
+
+	// The tasks are all set again on every pull, unlike the containers that
+	// the kubelet collector sets with the same source
+	workloadmeta.RegisterExpiringEntities(workloadmeta.SourceNodeOrchestrator, workloadmeta.KindECSTask)
 }
 
 func (c *collector) Start(ctx context.Context, store workloadmeta.Store) error {
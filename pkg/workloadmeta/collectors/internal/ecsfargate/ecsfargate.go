@@ -38,6 +38,10 @@ func init() {
 			seen: make(map[workloadmeta.EntityID]struct{}),
 		}
 	})
+
+	// The task is set again on every pull, unlike the containers that the
+	// runtime collectors set with the same source
+	workloadmeta.RegisterExpiringEntities(workloadmeta.SourceRuntime, workloadmeta.KindECSTask)
 }
 
 func (c *collector) Start(ctx context.Context, store workloadmeta.Store) error {
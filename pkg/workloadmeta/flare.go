@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package workloadmeta
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+)
+
+const (
+	flareDumpFile = "workloadmeta.json"
+
+	// maxFlareDumpEntities is the maximum number of entities of each kind
+	// added to the flare
+	maxFlareDumpEntities = 2000
+
+	redactedEnvVarValue = "********"
+)
+
+// flareDump is the content of the store added to the flare, by kind.
+type flareDump struct {
+	Kinds map[Kind]flareDumpKind `json:"kinds"`
+}
+
+// flareDumpKind lists the entities of a kind, sorted by ID. Omitted is the
+// number of entities left out once maxFlareDumpEntities are listed.
+type flareDumpKind struct {
+	Entities []flareDumpEntity `json:"entities"`
+	Omitted  int               `json:"omitted,omitempty"`
+}
+
+// flareDumpEntity is an entity merged from all its sources.
+type flareDumpEntity struct {
+	Sources []string `json:"sources"`
+	Entity  Entity   `json:"entity"`
+}
+
+// NewFlareProvider returns a flare provider adding the content of the global
+// store to the flare as JSON. The values of the env vars of the containers and
+// images are redacted, and their SBOMs are left out.
+func NewFlareProvider() helpers.Provider {
+	return helpers.NewProvider(func(fb helpers.FlareBuilder) error {
+		// Nothing to dump when this process has no store
+		s, ok := GetGlobalStore().(*store)
+		if !ok {
+			return nil
+		}
+
+		content, err := json.MarshalIndent(s.flareDump(maxFlareDumpEntities), "", "  ")
+		if err != nil {
+			return err
+		}
+		return fb.AddFile(flareDumpFile, content)
+	})
+}
+
+// flareDump returns the content of the store, with at most maxEntities
+// entities of each kind.
+func (s *store) flareDump(maxEntities int) flareDump {
+	s.storeMut.RLock()
+	defer s.storeMut.RUnlock()
+
+	dump := flareDump{Kinds: make(map[Kind]flareDumpKind)}
+	for kind, entitiesByID := range s.store {
+		ids := make([]string, 0, len(entitiesByID))
+		for id := range entitiesByID {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		dumpKind := flareDumpKind{Entities: []flareDumpEntity{}}
+		if len(ids) > maxEntities {
+			dumpKind.Omitted = len(ids) - maxEntities
+			ids = ids[:maxEntities]
+		}
+
+		for _, id := range ids {
+			cachedEntity := entitiesByID[id]
+			dumpKind.Entities = append(dumpKind.Entities, flareDumpEntity{
+				Sources: cachedEntity.sortedSources,
+				Entity:  redactEntity(cachedEntity.cached),
+			})
+		}
+
+		dump.Kinds[kind] = dumpKind
+	}
+
+	return dump
+}
+
+// redactEntity returns a copy of the entity without the values of its env
+// vars, nor its SBOM. The copy is shallow, as it's only encoded.
+func redactEntity(entity Entity) Entity {
+	switch e := entity.(type) {
+	case *Container:
+		redacted := *e
+		redacted.EnvVars = redactEnvVars(e.EnvVars)
+		redacted.CycloneDXBOM = nil
+		return &redacted
+	case *ContainerImageMetadata:
+		redacted := *e
+		redacted.EnvVars = redactEnvVars(e.EnvVars)
+		redacted.CycloneDXBOM = nil
+		return &redacted
+	default:
+		return e
+	}
+}
+
+func redactEnvVars(envVars map[string]string) map[string]string {
+	if envVars == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(envVars))
+	for name := range envVars {
+		redacted[name] = redactedEnvVarValue
+	}
+	return redacted
+}
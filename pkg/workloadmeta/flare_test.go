@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package workloadmeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+)
+
+type testFlareDump struct {
+	Kinds map[Kind]struct {
+		Entities []struct {
+			Sources []string               `json:"sources"`
+			Entity  map[string]interface{} `json:"entity"`
+		} `json:"entities"`
+		Omitted int `json:"omitted"`
+	} `json:"kinds"`
+}
+
+// setTestGlobalStore replaces the global store for the duration of the test.
+func setTestGlobalStore(t *testing.T, s Store) {
+	previous := globalStore
+	globalStore = s
+	t.Cleanup(func() { globalStore = previous })
+}
+
+func TestFlareProvider(t *testing.T) {
+	s := newTestStore()
+	s.handleEvents([]CollectorEvent{
+		{
+			Type:   EventTypeSet,
+			Source: SourceRuntime,
+			Entity: &Container{
+				EntityID:     EntityID{Kind: KindContainer, ID: "ctr-id"},
+				EntityMeta:   EntityMeta{Name: "ctr-name"},
+				Runtime:      ContainerRuntimeDocker,
+				EnvVars:      map[string]string{"DD_API_KEY": "secret"},
+				CycloneDXBOM: &cyclonedx.BOM{SerialNumber: "ctr-bom"},
+			},
+		},
+		{
+			Type:   EventTypeSet,
+			Source: SourceNodeOrchestrator,
+			Entity: &Container{
+				EntityID:   EntityID{Kind: KindContainer, ID: "ctr-id"},
+				EntityMeta: EntityMeta{Labels: map[string]string{"team": "containers"}},
+			},
+		},
+		{
+			Type:   EventTypeSet,
+			Source: SourceNodeOrchestrator,
+			Entity: &KubernetesPod{
+				EntityID:   EntityID{Kind: KindKubernetesPod, ID: "pod-id"},
+				EntityMeta: EntityMeta{Name: "pod-name", Namespace: "default"},
+			},
+		},
+	})
+
+	setTestGlobalStore(t, s)
+
+	flareBuilder := helpers.NewFlareBuilderMock(t)
+	require.NoError(t, NewFlareProvider().Provider.Callback(flareBuilder.Fb))
+
+	content, err := os.ReadFile(filepath.Join(flareBuilder.Root, flareDumpFile))
+	require.NoError(t, err)
+
+	var dump testFlareDump
+	require.NoError(t, json.Unmarshal(content, &dump))
+	require.Len(t, dump.Kinds, 2)
+
+	containers := dump.Kinds[KindContainer]
+	require.Len(t, containers.Entities, 1)
+	assert.Zero(t, containers.Omitted)
+	container := containers.Entities[0]
+	assert.Equal(t, []string{string(SourceNodeOrchestrator), string(SourceRuntime)}, container.Sources)
+	assert.Equal(t, "ctr-id", container.Entity["ID"])
+	assert.Equal(t, "ctr-name", container.Entity["Name"])
+	assert.Equal(t, map[string]interface{}{"team": "containers"}, container.Entity["Labels"])
+	assert.Equal(t, map[string]interface{}{"DD_API_KEY": redactedEnvVarValue}, container.Entity["EnvVars"])
+	assert.Nil(t, container.Entity["CycloneDXBOM"])
+
+	pods := dump.Kinds[KindKubernetesPod]
+	require.Len(t, pods.Entities, 1)
+	assert.Equal(t, []string{string(SourceNodeOrchestrator)}, pods.Entities[0].Sources)
+	assert.Equal(t, "pod-name", pods.Entities[0].Entity["Name"])
+
+	// The entities of the store are left untouched
+	stored, err := s.GetContainer("ctr-id")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", stored.EnvVars["DD_API_KEY"])
+	assert.NotNil(t, stored.CycloneDXBOM)
+}
+
+func TestFlareDumpTruncated(t *testing.T) {
+	s := newTestStore()
+	for i := 0; i < 5; i++ {
+		s.handleEvents([]CollectorEvent{
+			{
+				Type:   EventTypeSet,
+				Source: SourceRuntime,
+				Entity: &Container{EntityID: EntityID{Kind: KindContainer, ID: fmt.Sprintf("ctr-%d", i)}},
+			},
+		})
+	}
+
+	dump := s.flareDump(3)
+	containers := dump.Kinds[KindContainer]
+	require.Len(t, containers.Entities, 3)
+	assert.Equal(t, 2, containers.Omitted)
+	for i, entity := range containers.Entities {
+		assert.Equal(t, fmt.Sprintf("ctr-%d", i), entity.Entity.GetID().ID)
+	}
+}
+
+func TestFlareProviderWithoutStore(t *testing.T) {
+	setTestGlobalStore(t, nil)
+
+	flareBuilder := helpers.NewFlareBuilderMock(t)
+	require.NoError(t, NewFlareProvider().Provider.Callback(flareBuilder.Fb))
+	flareBuilder.AssertNoFileExists(flareDumpFile)
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package workloadmeta
+
+import (
+	"sort"
+	"strings"
+)
+
+// imageIndex indexes the image IDs by digest and by repository. It is not
+// thread-safe, it's protected by the `Store.storeMut` lock, like the
+// entities it indexes.
+type imageIndex struct {
+	byDigest map[string]map[string]struct{}
+	byRepo   map[string]map[string]struct{}
+
+	// keys has the digests and the repositories under which each image is
+	// indexed, to remove them when the image changes
+	keys map[string]imageIndexKeys
+}
+
+type imageIndexKeys struct {
+	digests []string
+	repos   []string
+}
+
+func newImageIndex() *imageIndex {
+	return &imageIndex{
+		byDigest: make(map[string]map[string]struct{}),
+		byRepo:   make(map[string]map[string]struct{}),
+		keys:     make(map[string]imageIndexKeys),
+	}
+}
+
+// update indexes the image with the given ID, replacing its previous keys. A
+// nil image removes it from the index.
+func (i *imageIndex) update(imageID string, image *ContainerImageMetadata) {
+	if previous, found := i.keys[imageID]; found {
+		removeFromIndex(i.byDigest, previous.digests, imageID)
+		removeFromIndex(i.byRepo, previous.repos, imageID)
+		delete(i.keys, imageID)
+	}
+
+	if image == nil {
+		return
+	}
+
+	keys := imageIndexKeys{
+		digests: imageDigests(image),
+		repos:   imageRepos(image),
+	}
+	addToIndex(i.byDigest, keys.digests, imageID)
+	addToIndex(i.byRepo, keys.repos, imageID)
+	i.keys[imageID] = keys
+}
+
+// imageIDsByDigest returns the sorted IDs of the images with the given digest.
+func (i *imageIndex) imageIDsByDigest(digest string) []string {
+	return sortedIDs(i.byDigest[digest])
+}
+
+// imageIDsByRepo returns the sorted IDs of the images of the given repository.
+func (i *imageIndex) imageIDsByRepo(repo string) []string {
+	return sortedIDs(i.byRepo[repo])
+}
+
+// imageDigests returns the digests identifying an image: its ID, which is the
+// digest of its config, and the digests of its manifests in the repo digests.
+func imageDigests(image *ContainerImageMetadata) []string {
+	digests := []string{image.ID}
+
+	for _, repoDigest := range image.RepoDigests {
+		if _, digest, found := strings.Cut(repoDigest, "@"); found {
+			digests = append(digests, digest)
+		}
+	}
+
+	return digests
+}
+
+// imageRepos returns the repositories of an image, found in its repo tags
+// and repo digests.
+func imageRepos(image *ContainerImageMetadata) []string {
+	var repos []string
+
+	for _, repoTag := range image.RepoTags {
+		repos = append(repos, repositoryOf(repoTag))
+	}
+
+	for _, repoDigest := range image.RepoDigests {
+		repos = append(repos, repositoryOf(repoDigest))
+	}
+
+	return repos
+}
+
+// repositoryOf returns the repository of an image reference, that is, the
+// reference without its tag and digest. The registry can include a port, so
+// the tag is the part after the last colon only if it's after the last slash.
+func repositoryOf(reference string) string {
+	reference, _, _ = strings.Cut(reference, "@")
+
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		reference = reference[:i]
+	}
+
+	return reference
+}
+
+func addToIndex(index map[string]map[string]struct{}, keys []string, imageID string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if _, found := index[key]; !found {
+			index[key] = make(map[string]struct{})
+		}
+		index[key][imageID] = struct{}{}
+	}
+}
+
+func removeFromIndex(index map[string]map[string]struct{}, keys []string, imageID string) {
+	for _, key := range keys {
+		delete(index[key], imageID)
+		if len(index[key]) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+func sortedIDs(ids map[string]struct{}) []string {
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -34,14 +34,14 @@ func (merger) Transformer(typ reflect.Type) func(dst, src reflect.Value) error {
 	return nil
 }
 
+// timeMerge sets the times that are zero in dst, like mergo does for the
+// other fields, so that the times of the entity merged into are kept.
 func timeMerge(dst, src reflect.Value) error {
 	if !dst.CanSet() {
 		return nil
 	}
 
-	isZero := src.MethodByName("IsZero")
-	result := isZero.Call([]reflect.Value{})
-	if !result[0].Bool() {
+	if dst.Interface().(time.Time).IsZero() {
 		dst.Set(src)
 	}
 	return nil
@@ -11,6 +11,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/errors"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -25,6 +28,7 @@ var (
 const (
 	retryCollectorInterval = 30 * time.Second
 	pullCollectorInterval  = 5 * time.Second
+	expireEntitiesInterval = 1 * time.Minute
 	eventBundleChTimeout   = 1 * time.Second
 	eventChBufferSize      = 50
 )
@@ -43,6 +47,9 @@ type store struct {
 	storeMut sync.RWMutex
 	store    map[Kind]map[string]*cachedEntity // store[entity.Kind][entity.ID] = &cachedEntity{}
 
+	// images indexes the images in the store, it's protected by storeMut
+	images *imageIndex
+
 	subscribersMut sync.RWMutex
 	subscribers    []subscriber
 
@@ -51,6 +58,18 @@ type store struct {
 	collectors   map[string]Collector
 
 	eventCh chan []CollectorEvent
+
+	// sourcePriorities has the rank of the sources configured to win the
+	// conflicts when merging entities, see sourcePriorities
+	sourcePriorities map[Source]int
+
+	// entityTTL is the time after which the sources of the entities that
+	// didn't refresh them are unset, e.g. as their collector died, for the
+	// expiringEntities only. Zero disables the expiry.
+	entityTTL        time.Duration
+	expiringEntities map[Source]map[Kind]struct{}
+
+	clock clock.Clock
 }
 
 var _ Store = &store{}
@@ -68,12 +87,41 @@ func newStore(catalog CollectorCatalog) *store {
 		candidates[id] = c()
 	}
 
+	expiring := make(map[Source]map[Kind]struct{}, len(expiringEntities))
+	for source, kinds := range expiringEntities {
+		expiring[source] = make(map[Kind]struct{}, len(kinds))
+		for kind := range kinds {
+			expiring[source][kind] = struct{}{}
+		}
+	}
+
 	return &store{
-		store:      make(map[Kind]map[string]*cachedEntity),
-		candidates: candidates,
-		collectors: make(map[string]Collector),
-		eventCh:    make(chan []CollectorEvent, eventChBufferSize),
+		store:            make(map[Kind]map[string]*cachedEntity),
+		images:           newImageIndex(),
+		candidates:       candidates,
+		collectors:       make(map[string]Collector),
+		eventCh:          make(chan []CollectorEvent, eventChBufferSize),
+		sourcePriorities: sourcePriorities(config.Datadog.GetStringSlice("workloadmeta.source_priority")),
+		entityTTL:        time.Duration(config.Datadog.GetInt("workloadmeta.entity_ttl")) * time.Second,
+		expiringEntities: expiring,
+		clock:            clock.New(),
+	}
+}
+
+// sourcePriorities returns the rank of each of the sources, from the highest
+// to the lowest priority. A source listed several times keeps its first rank.
+func sourcePriorities(sources []string) map[Source]int {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	priorities := make(map[Source]int, len(sources))
+	for _, source := range sources {
+		if _, found := priorities[Source(source)]; !found {
+			priorities[Source(source)] = len(priorities)
+		}
 	}
+	return priorities
 }
 
 // Start starts the workload metadata store.
@@ -104,6 +152,14 @@ func (s *store) Start(ctx context.Context) {
 		health := health.RegisterLiveness("workloadmeta-puller")
 		pullCtx, pullCancel := context.WithTimeout(ctx, pullCollectorInterval)
 
+		// the entities are only expired when they have a TTL
+		var expireTicker *clock.Ticker
+		var expireTickerC <-chan time.Time
+		if s.entityTTL > 0 {
+			expireTicker = s.clock.Ticker(expireEntitiesInterval)
+			expireTickerC = expireTicker.C
+		}
+
 		// Start a pull immediately to fill the store without waiting for the
 		// next tick.
 		s.pull(pullCtx)
@@ -128,9 +184,15 @@ func (s *store) Start(ctx context.Context) {
 					retryTicker.Stop()
 				}
 
+			case <-expireTickerC:
+				s.Notify(s.expiredEvents())
+
 			case <-ctx.Done():
 				retryTicker.Stop()
 				pullTicker.Stop()
+				if expireTicker != nil {
+					expireTicker.Stop()
+				}
 
 				pullCancel()
 
@@ -333,6 +395,42 @@ func (s *store) GetImage(id string) (*ContainerImageMetadata, error) {
 	return entity.(*ContainerImageMetadata), nil
 }
 
+// GetImageByDigest implements Store#GetImageByDigest
+func (s *store) GetImageByDigest(digest string) (*ContainerImageMetadata, error) {
+	s.storeMut.RLock()
+	defer s.storeMut.RUnlock()
+
+	// Several images can have the same manifest digest, for instance when
+	// it's the digest of a multi-platform index, the lowest ID is returned
+	// to be deterministic
+	for _, imageID := range s.images.imageIDsByDigest(digest) {
+		if image, found := s.store[KindContainerImageMetadata][imageID]; found {
+			return image.cached.(*ContainerImageMetadata), nil
+		}
+	}
+
+	return nil, errors.NewNotFound(digest)
+}
+
+// ListImagesByRepo implements Store#ListImagesByRepo
+func (s *store) ListImagesByRepo(repo string) ([]*ContainerImageMetadata, error) {
+	s.storeMut.RLock()
+	defer s.storeMut.RUnlock()
+
+	var images []*ContainerImageMetadata
+	for _, imageID := range s.images.imageIDsByRepo(repo) {
+		if image, found := s.store[KindContainerImageMetadata][imageID]; found {
+			images = append(images, image.cached.(*ContainerImageMetadata))
+		}
+	}
+
+	if len(images) == 0 {
+		return nil, errors.NewNotFound(repo)
+	}
+
+	return images, nil
+}
+
 // Notify implements Store#Notify
 func (s *store) Notify(events []CollectorEvent) {
 	if len(events) > 0 {
@@ -380,6 +478,35 @@ func (s *store) Reset(newEntities []Entity, source Source) {
 	s.Notify(events)
 }
 
+// expiredEvents returns an unset event for each source of the expiring entities
+// that didn't refresh them within the TTL of the entities.
+func (s *store) expiredEvents() []CollectorEvent {
+	s.storeMut.RLock()
+	defer s.storeMut.RUnlock()
+
+	expiry := s.clock.Now().Add(-s.entityTTL)
+
+	var events []CollectorEvent
+	for kind, entitiesOfKind := range s.store {
+		for _, cachedEntity := range entitiesOfKind {
+			for source, refreshed := range cachedEntity.refreshed {
+				if _, found := s.expiringEntities[source][kind]; !found || !refreshed.Before(expiry) {
+					continue
+				}
+
+				log.Debugf("%s %s not refreshed by source %s for %s, expiring it", kind, cachedEntity.cached.GetID().ID, source, s.entityTTL)
+				events = append(events, CollectorEvent{
+					Type:   EventTypeUnset,
+					Source: source,
+					Entity: cachedEntity.sources[source],
+				})
+			}
+		}
+	}
+
+	return events
+}
+
 func (s *store) startCandidates(ctx context.Context) bool {
 	s.collectorMut.Lock()
 	defer s.collectorMut.Unlock()
@@ -453,11 +580,12 @@ func (s *store) handleEvents(evs []CollectorEvent) {
 		switch ev.Type {
 		case EventTypeSet:
 			if !ok {
-				entitiesOfKind[entityID.ID] = newCachedEntity()
+				entitiesOfKind[entityID.ID] = newCachedEntity(s.sourcePriorities)
 				cachedEntity = entitiesOfKind[entityID.ID]
 			}
 
 			found, changed := cachedEntity.set(ev.Source, ev.Entity)
+			cachedEntity.refresh(ev.Source, s.clock.Now())
 
 			if !found {
 				telemetry.StoredEntities.Inc(
@@ -505,6 +633,14 @@ func (s *store) handleEvents(evs []CollectorEvent) {
 			log.Errorf("cannot handle event of type %d. event dump: %+v", ev.Type, ev)
 		}
 
+		if entityID.Kind == KindContainerImageMetadata {
+			var image *ContainerImageMetadata
+			if stored, found := entitiesOfKind[entityID.ID]; found {
+				image = stored.cached.(*ContainerImageMetadata)
+			}
+			s.images.update(entityID.ID, image)
+		}
+
 		for _, sub := range s.subscribers {
 			filter := sub.filter
 			if !filter.MatchKind(entityID.Kind) || !filter.MatchSource(ev.Source) || !filter.MatchEventType(ev.Type) {
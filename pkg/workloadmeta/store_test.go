@@ -8,9 +8,12 @@ package workloadmeta
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"gotest.tools/assert"
 
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/errors"
 )
 
@@ -61,6 +64,150 @@ func TestHandleEvents(t *testing.T) {
 	}
 }
 
+func TestHandleEventsWithSourcePriority(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("workloadmeta.source_priority", []string{barSource, fooSource})
+
+	s := newStore(nil)
+	s.handleEvents([]CollectorEvent{
+		{
+			Type:   EventTypeSet,
+			Source: barSource,
+			Entity: &Container{
+				EntityID: EntityID{Kind: KindContainer, ID: "deadbeef"},
+				Image:    ContainerImage{Tag: "7.42"},
+			},
+		},
+		{
+			Type:   EventTypeSet,
+			Source: fooSource,
+			Entity: &Container{
+				EntityID: EntityID{Kind: KindContainer, ID: "deadbeef"},
+				Image:    ContainerImage{Tag: "7.43"},
+			},
+		},
+	})
+
+	container, err := s.GetContainer("deadbeef")
+	assert.NilError(t, err)
+	assert.Equal(t, "7.42", container.Image.Tag)
+}
+
+func TestExpiredEvents(t *testing.T) {
+	mockClock := clock.NewMock()
+	s := newTestStore()
+	s.clock = mockClock
+	s.entityTTL = time.Minute
+	s.expiringEntities = map[Source]map[Kind]struct{}{
+		fooSource: {KindContainer: {}},
+		barSource: {KindContainer: {}},
+	}
+
+	staleContainer := &Container{EntityID: EntityID{Kind: KindContainer, ID: "stale"}}
+	refreshedContainer := &Container{EntityID: EntityID{Kind: KindContainer, ID: "refreshed"}}
+	image := &ContainerImageMetadata{EntityID: EntityID{Kind: KindContainerImageMetadata, ID: "image"}}
+
+	s.handleEvents([]CollectorEvent{
+		{Type: EventTypeSet, Source: fooSource, Entity: staleContainer},
+		{Type: EventTypeSet, Source: fooSource, Entity: refreshedContainer},
+		{Type: EventTypeSet, Source: barSource, Entity: refreshedContainer},
+		{Type: EventTypeSet, Source: fooSource, Entity: image},
+	})
+
+	// refreshing an entity, even unchanged, keeps it from expiring
+	mockClock.Add(40 * time.Second)
+	s.handleEvents([]CollectorEvent{
+		{Type: EventTypeSet, Source: fooSource, Entity: refreshedContainer},
+	})
+	assert.Equal(t, 0, len(s.expiredEvents()))
+
+	ch := s.Subscribe(dummySubscriber, NormalPriority, nil)
+	<-ch // initial set events
+
+	mockClock.Add(30 * time.Second)
+	expired := s.expiredEvents()
+	assert.DeepEqual(t, []CollectorEvent{
+		{Type: EventTypeUnset, Source: barSource, Entity: refreshedContainer},
+	}, filterExpiredEvents(expired, "refreshed"))
+	assert.DeepEqual(t, []CollectorEvent{
+		{Type: EventTypeUnset, Source: fooSource, Entity: staleContainer},
+	}, filterExpiredEvents(expired, "stale"))
+	assert.Equal(t, 2, len(expired))
+
+	go s.handleEvents(expired)
+	bundle := <-ch
+	close(bundle.Ch)
+
+	var unset []string
+	for _, ev := range bundle.Events {
+		if ev.Type == EventTypeUnset {
+			unset = append(unset, ev.Entity.GetID().ID)
+		}
+	}
+	assert.DeepEqual(t, []string{"stale"}, unset)
+
+	_, err := s.GetContainer("stale")
+	assert.Assert(t, errors.IsNotFound(err))
+
+	// the sources that refreshed the entity are kept
+	container, err := s.GetContainer("refreshed")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{fooSource}, s.store[KindContainer]["refreshed"].sortedSources)
+	assert.Equal(t, "refreshed", container.ID)
+
+	// the entities not opted in to the expiry never expire
+	_, err = s.GetImage("image")
+	assert.NilError(t, err)
+
+	s.Unsubscribe(ch)
+}
+
+func TestExpiredEventsOfEventDrivenCollectors(t *testing.T) {
+	cfg := config.Mock(t)
+	cfg.Set("workloadmeta.entity_ttl", 60)
+
+	previousExpiringEntities := expiringEntities
+	expiringEntities = make(map[Source]map[Kind]struct{})
+	t.Cleanup(func() { expiringEntities = previousExpiringEntities })
+	// as registered by the ECS Fargate collector
+	RegisterExpiringEntities(SourceRuntime, KindECSTask)
+
+	mockClock := clock.NewMock()
+	s := newStore(nil)
+	s.clock = mockClock
+
+	// the containerd collector only sets its containers again on their
+	// events
+	container := &Container{EntityID: EntityID{Kind: KindContainer, ID: "containerd"}}
+	task := &ECSTask{EntityID: EntityID{Kind: KindECSTask, ID: "task"}}
+	s.handleEvents([]CollectorEvent{
+		{Type: EventTypeSet, Source: SourceRuntime, Entity: container},
+		{Type: EventTypeSet, Source: SourceRuntime, Entity: task},
+	})
+
+	mockClock.Add(2 * time.Minute)
+	assert.DeepEqual(t, []CollectorEvent{
+		{Type: EventTypeUnset, Source: SourceRuntime, Entity: task},
+	}, s.expiredEvents())
+
+	s.handleEvents(s.expiredEvents())
+	_, err := s.GetContainer("containerd")
+	assert.NilError(t, err)
+	_, err = s.GetECSTask("task")
+	assert.Assert(t, errors.IsNotFound(err))
+}
+
+// filterExpiredEvents returns the events for the entity with the given ID.
+func filterExpiredEvents(events []CollectorEvent, id string) []CollectorEvent {
+	var filtered []CollectorEvent
+	for _, ev := range events {
+		if ev.Entity.GetID().ID == id {
+			filtered = append(filtered, ev)
+		}
+	}
+	return filtered
+}
+
 func TestSubscribe(t *testing.T) {
 	fooContainer := &Container{
 		EntityID: EntityID{
@@ -107,6 +254,20 @@ func TestSubscribe(t *testing.T) {
 		},
 	}
 
+	fooPod := &KubernetesPod{
+		EntityID: EntityID{
+			Kind: KindKubernetesPod,
+			ID:   "foo-pod",
+		},
+	}
+
+	barPod := &KubernetesPod{
+		EntityID: EntityID{
+			Kind: KindKubernetesPod,
+			ID:   "bar-pod",
+		},
+	}
+
 	tests := []struct {
 		name       string
 		preEvents  []CollectorEvent
@@ -495,6 +656,51 @@ func TestSubscribe(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:   "filters by kind",
+			filter: NewFilter([]Kind{KindContainer}, SourceAll, EventTypeAll),
+			preEvents: []CollectorEvent{
+				{
+					Type:   EventTypeSet,
+					Source: fooSource,
+					Entity: fooPod,
+				},
+			},
+			postEvents: [][]CollectorEvent{
+				{
+					{
+						Type:   EventTypeSet,
+						Source: fooSource,
+						Entity: fooContainer,
+					},
+					{
+						Type:   EventTypeSet,
+						Source: fooSource,
+						Entity: barPod,
+					},
+				},
+				{
+					// events of other kinds only are not
+					// dispatched to the subscriber at all
+					{
+						Type:   EventTypeUnset,
+						Source: fooSource,
+						Entity: fooPod,
+					},
+				},
+			},
+			expected: []EventBundle{
+				{},
+				{
+					Events: []Event{
+						{
+							Type:   EventTypeSet,
+							Entity: fooContainer,
+						},
+					},
+				},
+			},
+		},
 		{
 			name:   "filters by event type",
 			filter: NewFilter(nil, SourceAll, EventTypeUnset),
@@ -776,6 +982,199 @@ func TestGetImage(t *testing.T) {
 	}
 }
 
+func newTestImageEvents(images ...*ContainerImageMetadata) []CollectorEvent {
+	var events []CollectorEvent
+	for _, image := range images {
+		events = append(events, CollectorEvent{
+			Type:   EventTypeSet,
+			Source: fooSource,
+			Entity: image,
+		})
+	}
+	return events
+}
+
+func TestGetImageByDigest(t *testing.T) {
+	image := &ContainerImageMetadata{
+		EntityID: EntityID{
+			Kind: KindContainerImageMetadata,
+			ID:   "sha256:abc",
+		},
+		RepoTags: []string{"docker.io/datadog/agent:7", "docker.io/datadog/agent:latest"},
+		RepoDigests: []string{
+			"docker.io/datadog/agent@sha256:123",
+			"gcr.io/datadoghq/agent@sha256:456",
+		},
+	}
+
+	tests := []struct {
+		name          string
+		digest        string
+		preEvents     []CollectorEvent
+		expectedImage *ContainerImageMetadata
+		expectsError  bool
+	}{
+		{
+			name:          "image ID",
+			digest:        "sha256:abc",
+			preEvents:     newTestImageEvents(image),
+			expectedImage: image,
+		},
+		{
+			name:          "repo digest",
+			digest:        "sha256:456",
+			preEvents:     newTestImageEvents(image),
+			expectedImage: image,
+		},
+		{
+			name:         "unknown digest",
+			digest:       "sha256:def",
+			preEvents:    newTestImageEvents(image),
+			expectsError: true,
+		},
+		{
+			name:         "no images stored",
+			digest:       "sha256:abc",
+			expectsError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testStore := newTestStore()
+			testStore.handleEvents(test.preEvents)
+
+			actualImage, err := testStore.GetImageByDigest(test.digest)
+
+			if test.expectsError {
+				assert.Assert(t, errors.IsNotFound(err))
+			} else {
+				assert.NilError(t, err)
+				assert.DeepEqual(t, test.expectedImage, actualImage)
+			}
+		})
+	}
+}
+
+func TestListImagesByRepo(t *testing.T) {
+	agent7 := &ContainerImageMetadata{
+		EntityID: EntityID{
+			Kind: KindContainerImageMetadata,
+			ID:   "sha256:abc",
+		},
+		RepoTags:    []string{"docker.io/datadog/agent:7", "docker.io/datadog/agent:latest"},
+		RepoDigests: []string{"docker.io/datadog/agent@sha256:123"},
+	}
+	agent6 := &ContainerImageMetadata{
+		EntityID: EntityID{
+			Kind: KindContainerImageMetadata,
+			ID:   "sha256:def",
+		},
+		RepoTags: []string{"docker.io/datadog/agent:6"},
+	}
+	localAgent := &ContainerImageMetadata{
+		EntityID: EntityID{
+			Kind: KindContainerImageMetadata,
+			ID:   "sha256:ghi",
+		},
+		// The port of the registry is not a tag
+		RepoDigests: []string{"localhost:5000/datadog/agent@sha256:789"},
+	}
+
+	tests := []struct {
+		name           string
+		repo           string
+		preEvents      []CollectorEvent
+		expectedImages []*ContainerImageMetadata
+		expectsError   bool
+	}{
+		{
+			name:           "images with multiple tags",
+			repo:           "docker.io/datadog/agent",
+			preEvents:      newTestImageEvents(agent7, agent6, localAgent),
+			expectedImages: []*ContainerImageMetadata{agent7, agent6},
+		},
+		{
+			name:           "image only in repo digests",
+			repo:           "localhost:5000/datadog/agent",
+			preEvents:      newTestImageEvents(agent7, agent6, localAgent),
+			expectedImages: []*ContainerImageMetadata{localAgent},
+		},
+		{
+			name:         "unknown repo",
+			repo:         "docker.io/datadog/cluster-agent",
+			preEvents:    newTestImageEvents(agent7, agent6, localAgent),
+			expectsError: true,
+		},
+		{
+			name:         "tag instead of repo",
+			repo:         "docker.io/datadog/agent:7",
+			preEvents:    newTestImageEvents(agent7),
+			expectsError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testStore := newTestStore()
+			testStore.handleEvents(test.preEvents)
+
+			actualImages, err := testStore.ListImagesByRepo(test.repo)
+
+			if test.expectsError {
+				assert.Assert(t, errors.IsNotFound(err))
+			} else {
+				assert.NilError(t, err)
+				assert.DeepEqual(t, test.expectedImages, actualImages)
+			}
+		})
+	}
+}
+
+func TestImageIndexIsUpdated(t *testing.T) {
+	image := &ContainerImageMetadata{
+		EntityID: EntityID{
+			Kind: KindContainerImageMetadata,
+			ID:   "sha256:abc",
+		},
+		RepoTags:    []string{"docker.io/datadog/agent:7"},
+		RepoDigests: []string{"docker.io/datadog/agent@sha256:123"},
+	}
+
+	testStore := newTestStore()
+	testStore.handleEvents(newTestImageEvents(image))
+
+	// The image is retagged, and pushed to another repository
+	retagged := image.DeepCopy().(*ContainerImageMetadata)
+	retagged.RepoTags = []string{"gcr.io/datadoghq/agent:7"}
+	retagged.RepoDigests = []string{"gcr.io/datadoghq/agent@sha256:456"}
+	testStore.handleEvents(newTestImageEvents(retagged))
+
+	_, err := testStore.ListImagesByRepo("docker.io/datadog/agent")
+	assert.Assert(t, errors.IsNotFound(err))
+	_, err = testStore.GetImageByDigest("sha256:123")
+	assert.Assert(t, errors.IsNotFound(err))
+
+	images, err := testStore.ListImagesByRepo("gcr.io/datadoghq/agent")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []*ContainerImageMetadata{retagged}, images)
+
+	// The image is deleted
+	testStore.handleEvents([]CollectorEvent{
+		{
+			Type:   EventTypeUnset,
+			Source: fooSource,
+			Entity: retagged,
+		},
+	})
+
+	_, err = testStore.ListImagesByRepo("gcr.io/datadoghq/agent")
+	assert.Assert(t, errors.IsNotFound(err))
+	_, err = testStore.GetImageByDigest("sha256:abc")
+	assert.Assert(t, errors.IsNotFound(err))
+	assert.Equal(t, 0, len(testStore.images.keys))
+}
+
 func TestReset(t *testing.T) {
 	fooContainer := &Container{
 		EntityID: EntityID{
@@ -970,6 +1369,8 @@ func TestReset(t *testing.T) {
 func newTestStore() *store {
 	return &store{
 		store:   make(map[Kind]map[string]*cachedEntity),
+		images:  newImageIndex(),
 		eventCh: make(chan []CollectorEvent, eventChBufferSize),
+		clock:   clock.New(),
 	}
 }
@@ -7,6 +7,8 @@ package testing
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/errors"
@@ -127,6 +129,53 @@ func (s *Store) GetImage(id string) (*workloadmeta.ContainerImageMetadata, error
 	return entity.(*workloadmeta.ContainerImageMetadata), nil
 }
 
+// GetImageByDigest implements Store#GetImageByDigest
+func (s *Store) GetImageByDigest(digest string) (*workloadmeta.ContainerImageMetadata, error) {
+	// Not indexed, unlike in the actual store
+	for _, image := range s.sortedImages() {
+		if image.ID == digest {
+			return image, nil
+		}
+
+		for _, repoDigest := range image.RepoDigests {
+			if strings.HasSuffix(repoDigest, "@"+digest) {
+				return image, nil
+			}
+		}
+	}
+
+	return nil, errors.NewNotFound(digest)
+}
+
+// ListImagesByRepo implements Store#ListImagesByRepo
+func (s *Store) ListImagesByRepo(repo string) ([]*workloadmeta.ContainerImageMetadata, error) {
+	var images []*workloadmeta.ContainerImageMetadata
+
+	for _, image := range s.sortedImages() {
+		for _, reference := range append(append([]string{}, image.RepoTags...), image.RepoDigests...) {
+			if reference == repo || strings.HasPrefix(reference, repo+":") || strings.HasPrefix(reference, repo+"@") {
+				images = append(images, image)
+				break
+			}
+		}
+	}
+
+	if len(images) == 0 {
+		return nil, errors.NewNotFound(repo)
+	}
+
+	return images, nil
+}
+
+func (s *Store) sortedImages() []*workloadmeta.ContainerImageMetadata {
+	images := s.ListImages()
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+
+	return images
+}
+
 // Set sets an entity in the store.
 func (s *Store) Set(entity workloadmeta.Entity) {
 	s.mu.Lock()
@@ -83,6 +83,16 @@ type Store interface {
 	// with kind KindContainerImageMetadata and the given ID.
 	GetImage(id string) (*ContainerImageMetadata, error)
 
+	// GetImageByDigest returns metadata about the container image with the
+	// given digest, which is either the ID of the image or the digest of one
+	// of its manifests, as found in its repo digests.
+	GetImageByDigest(digest string) (*ContainerImageMetadata, error)
+
+	// ListImagesByRepo returns metadata about the container images of the
+	// given repository, like "docker.io/datadog/agent", found in their repo
+	// tags or repo digests. It returns a not found error when there's none.
+	ListImagesByRepo(repo string) ([]*ContainerImageMetadata, error)
+
 	// Notify notifies the store with a slice of events.  It should only be
 	// used by workloadmeta collectors.
 	Notify(events []CollectorEvent)
@@ -109,6 +119,7 @@ const (
 	KindKubernetesPod          Kind = "kubernetes_pod"
 	KindECSTask                Kind = "ecs_task"
 	KindContainerImageMetadata Kind = "container_image_metadata"
+	KindContainerRuntime       Kind = "container_runtime"
 )
 
 // Source is the source name of an entity.
@@ -365,6 +376,25 @@ func (c ContainerPort) String(verbose bool) string {
 	return sb.String()
 }
 
+// ContainerMount is a filesystem mounted in a container.
+type ContainerMount struct {
+	// Source is the path mounted in the container, usually on the host, or
+	// the name of a special filesystem like "proc" or "tmpfs".
+	Source      string
+	Destination string
+	ReadOnly    bool
+}
+
+// String returns a string representation of ContainerMount.
+func (c ContainerMount) String(verbose bool) string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintln(&sb, "Source:", c.Source)
+	_, _ = fmt.Fprintln(&sb, "Destination:", c.Destination)
+	_, _ = fmt.Fprintln(&sb, "Read only:", c.ReadOnly)
+
+	return sb.String()
+}
+
 // OrchestratorContainer is a reference to a Container with
 // orchestrator-specific data attached to it.
 type OrchestratorContainer struct {
@@ -388,11 +418,21 @@ type Container struct {
 	NetworkIPs map[string]string
 	PID        int
 	Ports      []ContainerPort
+	Mounts     []ContainerMount
 	Runtime    ContainerRuntime
 	State      ContainerState
 	// CollectorTags represent tags coming from the collector itself
 	// and that it would impossible to compute later on
 	CollectorTags []string
+	// IsSandbox is true for the sandbox (a.k.a. pause) containers of pods,
+	// which only hold the namespaces shared by the other containers of the
+	// pod. They are only reported by the runtimes that are configured to
+	// collect them.
+	IsSandbox bool
+	// CycloneDXBOM is the SBOM of the running container, which includes the
+	// packages added at runtime on top of the ones of its image. It's only
+	// collected when container SBOM collection is enabled.
+	CycloneDXBOM *cyclonedx.BOM
 }
 
 // GetID implements Entity#GetID.
@@ -438,6 +478,14 @@ func (c Container) String(verbose bool) string {
 		_, _ = fmt.Fprintln(&sb, "Hostname:", c.Hostname)
 		_, _ = fmt.Fprintln(&sb, "Network IPs:", mapToString(c.NetworkIPs))
 		_, _ = fmt.Fprintln(&sb, "PID:", c.PID)
+
+		if c.IsSandbox {
+			_, _ = fmt.Fprintln(&sb, "Sandbox: true")
+		}
+
+		if c.CycloneDXBOM != nil {
+			_, _ = fmt.Fprintln(&sb, "SBOM: stored")
+		}
 	}
 
 	if len(c.Ports) > 0 && verbose {
@@ -447,6 +495,13 @@ func (c Container) String(verbose bool) string {
 		}
 	}
 
+	if len(c.Mounts) > 0 && verbose {
+		_, _ = fmt.Fprintln(&sb, "----------- Mounts -----------")
+		for _, m := range c.Mounts {
+			_, _ = fmt.Fprint(&sb, m.String(verbose))
+		}
+	}
+
 	return sb.String()
 }
 
@@ -637,8 +692,53 @@ type ContainerImageMetadata struct {
 	Architecture string
 	Variant      string
 	Layers       []ContainerImageLayer
+	// PulledAt is when the image was pulled or imported into the runtime
+	PulledAt time.Time
+	// EnvVars, Entrypoint and Cmd are the defaults of the containers
+	// created from the image, read from its config. The values of the env
+	// vars with secret-like names are redacted.
+	EnvVars    map[string]string
+	Entrypoint []string
+	Cmd        []string
+	// SBOMStatus is the status of the SBOM scan of the image. It's empty
+	// when the collector doesn't report it.
+	SBOMStatus   SBOMStatus
 	CycloneDXBOM *cyclonedx.BOM
-}
+	// SBOMScannedAt is when the SBOM was last generated, or found unchanged
+	// by a rescan. Only this field changes in the heartbeats of the unchanged
+	// rescans.
+	SBOMScannedAt time.Time
+	// Signatures summarizes the signatures and attestations found for the
+	// image. It's nil when the collector doesn't look for them, so an image
+	// without any has a summary saying so.
+	Signatures *ContainerImageSignatures
+}
+
+// ContainerImageSignatures summarizes the supply chain artifacts of an image,
+// like the signatures and attestations of cosign, found in the runtime next to
+// the image.
+type ContainerImageSignatures struct {
+	Signed   bool
+	Attested bool
+	// Artifacts are the references of the signature and attestation
+	// artifacts found
+	Artifacts []string
+}
+
+// SBOMStatus is the status of the SBOM scan of an image
+type SBOMStatus string
+
+const (
+	// SBOMStatusPending means that the image is waiting to be scanned
+	SBOMStatusPending SBOMStatus = "Pending"
+	// SBOMStatusRunning means that the image is being scanned
+	SBOMStatusRunning SBOMStatus = "Running"
+	// SBOMStatusSuccess means that the SBOM of the image has been collected
+	SBOMStatusSuccess SBOMStatus = "Success"
+	// SBOMStatusFailed means that the last scan of the image failed. The
+	// image can still be scanned again, by a rescan or when it's seen again
+	SBOMStatusFailed SBOMStatus = "Failed"
+)
 
 // ContainerImageLayer represents a layer of a container image
 type ContainerImageLayer struct {
@@ -691,6 +791,17 @@ func (i ContainerImageMetadata) String(verbose bool) string {
 		_, _ = fmt.Fprintln(&sb, "OS Version:", i.OSVersion)
 		_, _ = fmt.Fprintln(&sb, "Architecture:", i.Architecture)
 		_, _ = fmt.Fprintln(&sb, "Variant:", i.Variant)
+		_, _ = fmt.Fprintln(&sb, "Pulled At:", i.PulledAt)
+
+		if len(i.EnvVars) > 0 {
+			_, _ = fmt.Fprintln(&sb, "Env Variables:", mapToString(i.EnvVars))
+		}
+		if len(i.Entrypoint) > 0 {
+			_, _ = fmt.Fprintln(&sb, "Entrypoint:", i.Entrypoint)
+		}
+		if len(i.Cmd) > 0 {
+			_, _ = fmt.Fprintln(&sb, "Cmd:", i.Cmd)
+		}
 
 		if i.CycloneDXBOM != nil {
 			_, _ = fmt.Fprintln(&sb, "SBOM: stored")
@@ -698,6 +809,19 @@ func (i ContainerImageMetadata) String(verbose bool) string {
 			_, _ = fmt.Fprintln(&sb, "SBOM: not stored")
 		}
 
+		if i.SBOMStatus != "" {
+			_, _ = fmt.Fprintln(&sb, "SBOM status:", i.SBOMStatus)
+		}
+
+		if !i.SBOMScannedAt.IsZero() {
+			_, _ = fmt.Fprintln(&sb, "SBOM scanned at:", i.SBOMScannedAt)
+		}
+
+		if i.Signatures != nil {
+			_, _ = fmt.Fprintln(&sb, "Signed:", i.Signatures.Signed)
+			_, _ = fmt.Fprintln(&sb, "Attested:", i.Signatures.Attested)
+		}
+
 		_, _ = fmt.Fprintln(&sb, "----------- Layers -----------")
 		for _, layer := range i.Layers {
 			if layer.SizeBytes != 0 { // Skip layers that have a history command associated but are empty
@@ -726,6 +850,62 @@ func (layer ContainerImageLayer) String() string {
 
 var _ Entity = &ContainerImageMetadata{}
 
+// ContainerRuntimeMetadata is an Entity representing the container runtime
+// of the node that a collector talks to. Its ID is the name of the runtime.
+type ContainerRuntimeMetadata struct {
+	EntityID
+	Runtime ContainerRuntime
+	// Version and Revision of the runtime, "unknown" when the runtime doesn't
+	// report them
+	Version  string
+	Revision string
+	// Snapshotter used to access the filesystems of the images and
+	// containers, when the runtime has one
+	Snapshotter string
+}
+
+// GetID implements Entity#GetID.
+func (r ContainerRuntimeMetadata) GetID() EntityID {
+	return r.EntityID
+}
+
+// Merge implements Entity#Merge.
+func (r *ContainerRuntimeMetadata) Merge(e Entity) error {
+	otherRuntime, ok := e.(*ContainerRuntimeMetadata)
+	if !ok {
+		return fmt.Errorf("cannot merge ContainerRuntimeMetadata with different kind %T", e)
+	}
+
+	return merge(r, otherRuntime)
+}
+
+// DeepCopy implements Entity#DeepCopy.
+func (r ContainerRuntimeMetadata) DeepCopy() Entity {
+	cp := deepcopy.Copy(r).(ContainerRuntimeMetadata)
+	return &cp
+}
+
+// String implements Entity#String.
+func (r ContainerRuntimeMetadata) String(verbose bool) string {
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintln(&sb, "----------- Entity ID -----------")
+	_, _ = fmt.Fprint(&sb, r.EntityID.String(verbose))
+
+	_, _ = fmt.Fprintln(&sb, "----------- Runtime Info -----------")
+	_, _ = fmt.Fprintln(&sb, "Runtime:", r.Runtime)
+	_, _ = fmt.Fprintln(&sb, "Version:", r.Version)
+
+	if verbose {
+		_, _ = fmt.Fprintln(&sb, "Revision:", r.Revision)
+		_, _ = fmt.Fprintln(&sb, "Snapshotter:", r.Snapshotter)
+	}
+
+	return sb.String()
+}
+
+var _ Entity = &ContainerRuntimeMetadata{}
+
 // CollectorEvent is an event generated by a metadata collector, to be handled
 // by the metadata store.
 type CollectorEvent struct {